@@ -31,12 +31,16 @@ export interface Agent {
 	 * @param sessionId - Optional session ID to send prompt to. If not provided, uses default session.
 	 * @param model - Optional model to use for this request. If not provided, uses agent's default.
 	 * @param reasoning - Extended thinking: "enabled", "disabled", or undefined for default
+	 * @param history - Earlier messages of the conversation as the server sent
+	 *   them, already trimmed to its chat history window. Used as context when
+	 *   the agent has no conversation of its own to resume for the session.
 	 */
 	prompt(
 		message: UIMessage,
 		sessionId?: string,
 		model?: string,
 		reasoning?: "enabled" | "disabled" | "",
+		history?: UIMessage[],
 	): AsyncGenerator<UIMessageChunk, void, unknown>;
 
 	/**
@@ -229,6 +229,169 @@ export interface RenameFileResponse {
 	newPath: string;
 }
 
+/**
+ * POST /files/uploads request body - start a resumable upload
+ */
+export interface CreateUploadRequest {
+	path: string;
+	size: number; // Total size of the file in bytes
+}
+
+/**
+ * Resumable upload state, returned by every /files/uploads endpoint
+ */
+export interface UploadStatus {
+	uploadId: string;
+	path: string;
+	size: number;
+	offset: number; // Bytes received so far; the next chunk must start here
+	complete: boolean; // The file has been moved into place
+}
+
+/**
+ * PUT /files/uploads/:id 409 response - the chunk didn't start at the
+ * current offset
+ */
+export interface UploadOffsetConflictResponse {
+	error: string;
+	offset: number;
+}
+
+/**
+ * DELETE /files/uploads/:id response
+ */
+export interface AbortUploadResponse {
+	uploadId: string;
+}
+
+// ============================================================================
+// Key-Value Store Types
+// ============================================================================
+
+/**
+ * GET /kv/:key and PUT /kv/:key response - a stored value
+ */
+export interface KVEntry {
+	key: string;
+	value: string;
+	size: number;
+	updatedAt: string;
+}
+
+/**
+ * PUT /kv/:key request body
+ */
+export interface PutKVRequest {
+	value: string;
+}
+
+/**
+ * DELETE /kv/:key response
+ */
+export interface DeleteKVResponse {
+	key: string;
+}
+
+// ============================================================================
+// Network Test Types
+// ============================================================================
+
+/**
+ * POST /network/test request body
+ */
+export interface NetworkTestRequest {
+	/** URL or host[:port] to reach (bare hosts are treated as https) */
+	target: string;
+	/** Timeout for each step in milliseconds (default 10000, max 30000) */
+	timeoutMs?: number;
+}
+
+/**
+ * Outcome of one step of a network test
+ */
+export interface NetworkTestStep {
+	ok: boolean;
+	durationMs: number;
+	error?: string;
+}
+
+/**
+ * POST /network/test response
+ */
+export interface NetworkTestResponse {
+	target: string;
+	url: string;
+	/** True if an HTTP response was received and the proxy didn't block it */
+	reachable: boolean;
+	dns: NetworkTestStep & { addresses?: string[] };
+	proxy: {
+		used: boolean;
+		url?: string;
+		/** Whether the proxy let the request through (unset if not reached) */
+		allowed?: boolean;
+		error?: string;
+	};
+	/** TCP connection to the target, or to the proxy when one is used */
+	connect: NetworkTestStep;
+	tls?: NetworkTestStep & { protocol?: string; issuer?: string };
+	http?: NetworkTestStep & { status?: number };
+}
+
+// ============================================================================
+// Proxy Stats Types
+// ============================================================================
+
+/**
+ * Traffic counters for one domain, as reported by the MITM proxy
+ */
+export interface ProxyDomainStats {
+	requests: number;
+	blocked: number;
+	bytes_sent: number;
+	bytes_received: number;
+	cache_hits: number;
+	cache_misses: number;
+	cache_hit_rate: number;
+}
+
+/**
+ * GET /proxy/stats response (relayed from the proxy's GET /stats)
+ */
+export interface ProxyStatsResponse {
+	since: string;
+	totals: ProxyDomainStats;
+	domains: Record<string, ProxyDomainStats>;
+}
+
+/**
+ * Open file descriptors of a process in the sandbox
+ */
+export interface ProcessFDStats {
+	pid: number;
+	command: string;
+	open: number;
+	/** Soft RLIMIT_NOFILE (null if unlimited) */
+	softLimit: number | null;
+	/** Hard RLIMIT_NOFILE (null if unlimited) */
+	hardLimit: number | null;
+}
+
+/**
+ * GET /fds response
+ */
+export interface FileDescriptorsResponse {
+	/** The agent API's own process */
+	self: ProcessFDStats;
+	/** Processes with the most open file descriptors, most first */
+	top: ProcessFDStats[];
+	/** Open file descriptors across the processes that could be inspected */
+	totalOpen: number;
+	/** Kernel-wide file handles allocated and the maximum (fs.file-max) */
+	system: { allocated: number; max: number } | null;
+	/** Processes, or the system, at or above 80% of their limit */
+	warnings: string[];
+}
+
 /**
  * Single file diff entry
  */
@@ -340,6 +503,8 @@ export interface ServiceConfig {
 	 * Detected automatically when the service file has no executable body.
 	 */
 	passive?: boolean;
+	/** Environment variables for the service, from "env: NAME=value" lines */
+	env?: Record<string, string>;
 }
 
 /**
@@ -377,6 +542,8 @@ export interface Service {
 	 * Passive services are not started/stopped - they just declare an HTTP port.
 	 */
 	passive?: boolean;
+	/** Environment variables set for the service (from config) */
+	env?: Record<string, string>;
 	/** PID if running */
 	pid?: number;
 	/** Start time (ISO string) */
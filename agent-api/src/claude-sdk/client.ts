@@ -17,7 +17,10 @@ import {
 	type SessionData as StoreSessionData,
 	saveSession,
 } from "../store/session.js";
-import { messageToContentBlocks } from "./content-blocks.js";
+import {
+	historyToContentBlock,
+	messageToContentBlocks,
+} from "./content-blocks.js";
 import { DiskBackedSession } from "./disk-backed-session.js";
 import {
 	type ClaudeSessionInfo,
@@ -270,6 +273,7 @@ export class ClaudeSDKClient implements Agent {
 		sessionId?: string,
 		model?: string,
 		reasoning?: "enabled" | "disabled" | "",
+		history?: UIMessage[],
 	): AsyncGenerator<UIMessageChunk, void, unknown> {
 		const sid = await this.ensureSession(sessionId);
 		const ctx = this.sessions.get(sid);
@@ -289,6 +293,17 @@ export class ClaudeSDKClient implements Agent {
 		// This includes text and image attachments
 		const contentBlocks = messageToContentBlocks(message);
 
+		// Without a Claude session to resume (e.g. the sandbox was rebuilt),
+		// the SDK knows nothing of the conversation so far. Start it from the
+		// history the server sent, which its chat history policy has already
+		// trimmed or summarized to fit the context window.
+		if (!ctx.claudeSessionId && history && history.length > 0) {
+			const historyBlock = historyToContentBlock(history);
+			if (historyBlock) {
+				contentBlocks.unshift(historyBlock);
+			}
+		}
+
 		// Create abort controller for this prompt
 		this.activeAbortController = new AbortController();
 
@@ -1,7 +1,11 @@
 import assert from "node:assert/strict";
 import { describe, it } from "node:test";
 import type { UIMessage } from "ai";
-import { messageToContentBlocks, parseDataUrl } from "./content-blocks.js";
+import {
+	historyToContentBlock,
+	messageToContentBlocks,
+	parseDataUrl,
+} from "./content-blocks.js";
 
 describe("content-blocks", () => {
 	describe("parseDataUrl", () => {
@@ -394,4 +398,59 @@ describe("content-blocks", () => {
 			assert.strictEqual(blocks[0].text, "");
 		});
 	});
+
+	describe("historyToContentBlock", () => {
+		it("renders text parts by role, oldest first", () => {
+			const history: UIMessage[] = [
+				{
+					id: "history-summary-msg-1",
+					role: "system",
+					parts: [{ type: "text", text: "Summary of 1 earlier messages" }],
+				},
+				{
+					id: "msg-2",
+					role: "user",
+					parts: [
+						{ type: "text", text: "Fix the build" },
+						{
+							type: "file",
+							url: "data:image/png;base64,AAAA",
+							mediaType: "image/png",
+						},
+					],
+				},
+				{
+					id: "msg-3",
+					role: "assistant",
+					parts: [{ type: "text", text: " Done. " }],
+				},
+			];
+
+			const block = historyToContentBlock(history);
+
+			assert.ok(block);
+			assert.strictEqual(block.type, "text");
+			if (block.type === "text") {
+				assert.ok(block.text.startsWith("<conversation-history>\n"));
+				assert.ok(
+					block.text.includes(
+						"system: Summary of 1 earlier messages\n\nuser: Fix the build\n\nassistant: Done.\n</conversation-history>",
+					),
+				);
+			}
+		});
+
+		it("returns null without text", () => {
+			const history: UIMessage[] = [
+				{
+					id: "msg-1",
+					role: "assistant",
+					parts: [{ type: "text", text: " " }],
+				},
+			];
+
+			assert.strictEqual(historyToContentBlock(history), null);
+			assert.strictEqual(historyToContentBlock([]), null);
+		});
+	});
 });
@@ -107,3 +107,36 @@ export function messageToContentBlocks(message: UIMessage): ContentBlock[] {
 
 	return contentBlocks;
 }
+
+/**
+ * Render earlier conversation messages as a single text block to prepend to
+ * a prompt, so a new Claude session starts with the conversation's context.
+ * Only text parts are included; system messages (e.g. the server's summary of
+ * messages outside its history window) are kept as-is.
+ *
+ * @param history - The messages before the prompt, oldest first
+ * @returns A text content block, or null if the messages have no text
+ */
+export function historyToContentBlock(
+	history: UIMessage[],
+): ContentBlock | null {
+	const lines: string[] = [];
+	for (const message of history) {
+		const text = message.parts
+			.flatMap((part) =>
+				part.type === "text" && part.text.trim() ? [part.text.trim()] : [],
+			)
+			.join("\n");
+		if (text) {
+			lines.push(`${message.role}: ${text}`);
+		}
+	}
+	if (lines.length === 0) {
+		return null;
+	}
+
+	return {
+		type: "text",
+		text: `<conversation-history>\nThe conversation so far, for context. Older messages may have been summarized or left out.\n\n${lines.join("\n\n")}\n</conversation-history>\n\n`,
+	};
+}
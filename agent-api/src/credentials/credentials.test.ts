@@ -0,0 +1,87 @@
+import assert from "node:assert/strict";
+import {
+	existsSync,
+	readFileSync,
+	rmSync,
+	statSync,
+	writeFileSync,
+} from "node:fs";
+import { join } from "node:path";
+import { after, before, describe, it } from "node:test";
+import { checkCredentialsChanged, credentialsToEnv } from "./credentials.js";
+
+describe("credentialsToEnv", () => {
+	const testDir = "/tmp/agent-api-credentials-test";
+	const originalDir = process.env.DISCOBOT_CREDENTIALS_DIR;
+
+	before(() => {
+		rmSync(testDir, { recursive: true, force: true });
+		process.env.DISCOBOT_CREDENTIALS_DIR = testDir;
+	});
+
+	after(() => {
+		rmSync(testDir, { recursive: true, force: true });
+		if (originalDir === undefined) {
+			delete process.env.DISCOBOT_CREDENTIALS_DIR;
+		} else {
+			process.env.DISCOBOT_CREDENTIALS_DIR = originalDir;
+		}
+	});
+
+	it("maps env vars to values", () => {
+		const env = credentialsToEnv([
+			{ envVar: "ANTHROPIC_API_KEY", value: "sk-test" },
+			{ envVar: "EMPTY", value: "" },
+		]);
+		assert.deepEqual(env, { ANTHROPIC_API_KEY: "sk-test" });
+	});
+
+	it("writes file credentials and points the env var at them", () => {
+		const content = "//registry.npmjs.org/:_authToken=npm_test\n";
+		const env = credentialsToEnv([
+			{ envVar: "NPM_CONFIG_GLOBALCONFIG", value: content, file: "npmrc" },
+		]);
+
+		const path = join(testDir, "npmrc");
+		assert.equal(env.NPM_CONFIG_GLOBALCONFIG, path);
+		assert.equal(readFileSync(path, "utf8"), content);
+		assert.equal(statSync(path).mode & 0o777, 0o600);
+	});
+
+	it("ignores file names that could escape the directory", () => {
+		const env = credentialsToEnv([
+			{ envVar: "BAD", value: "x", file: "../escape" },
+			{ envVar: "HIDDEN", value: "x", file: ".hidden" },
+		]);
+		assert.deepEqual(env, {});
+		assert.equal(existsSync("/tmp/escape"), false);
+	});
+
+	it("throws when a credential file can't be written", () => {
+		// A file where the directory should be makes the write fail
+		rmSync(testDir, { recursive: true, force: true });
+		writeFileSync(testDir, "");
+		try {
+			assert.throws(
+				() =>
+					credentialsToEnv([
+						{ envVar: "NPM_CONFIG_GLOBALCONFIG", value: "x", file: "npmrc" },
+					]),
+				/Failed to write credential file npmrc/,
+			);
+		} finally {
+			rmSync(testDir, { force: true });
+		}
+	});
+
+	it("removes files for credentials that were revoked", () => {
+		const withFile = JSON.stringify([
+			{ envVar: "NPM_CONFIG_GLOBALCONFIG", value: "token", file: "npmrc" },
+		]);
+		checkCredentialsChanged(withFile);
+		assert.equal(existsSync(join(testDir, "npmrc")), true);
+
+		checkCredentialsChanged(JSON.stringify([]));
+		assert.equal(existsSync(join(testDir, "npmrc")), false);
+	});
+});
@@ -1,10 +1,33 @@
 import { createHash } from "node:crypto";
+import {
+	mkdirSync,
+	readdirSync,
+	rmSync,
+	writeFileSync,
+} from "node:fs";
+import { tmpdir } from "node:os";
+import { basename, join } from "node:path";
 
 // CredentialEnvVar matches the server's CredentialEnvVar type
 // The server resolves the provider -> env var mapping, so we just use it directly
 export interface CredentialEnvVar {
 	envVar: string;
 	value: string;
+	// If set, value is written to this file in the credentials directory and
+	// envVar points at the file (for tools like npm that read secrets from files)
+	file?: string;
+}
+
+/**
+ * Returns the directory for credential files: DISCOBOT_CREDENTIALS_DIR (a
+ * tmpfs set up by the sandbox init, so secrets never reach the home overlay),
+ * or a per-user temp directory when running locally.
+ */
+export function getCredentialsDir(): string {
+	return (
+		process.env.DISCOBOT_CREDENTIALS_DIR ||
+		join(tmpdir(), `discobot-credentials-${process.getuid?.() ?? "user"}`)
+	);
 }
 
 // In-memory credential storage
@@ -59,29 +82,82 @@ export function getCredentials(): CredentialEnvVar[] {
 }
 
 // Convert credentials to environment variables
-// Simple: just map envVar -> value
+// Map envVar -> value, or envVar -> path for file credentials
 export function credentialsToEnv(
 	creds: CredentialEnvVar[],
 ): Record<string, string> {
 	const env: Record<string, string> = {};
 
 	for (const cred of creds) {
-		if (cred.envVar && cred.value) {
-			env[cred.envVar] = cred.value;
+		if (!cred.envVar || !cred.value) {
+			continue;
+		}
+		if (cred.file) {
+			const path = writeCredentialFile(cred.file, cred.value);
+			if (path) {
+				env[cred.envVar] = path;
+			}
+			continue;
 		}
+		env[cred.envVar] = cred.value;
 	}
 
 	return env;
 }
 
-// Check if credentials have changed and return the new environment if so
+// Write a credential file readable only by the agent user, returning its
+// path, or null if the name is unsafe. Throws if the file can't be written,
+// so a tool isn't silently left without its credentials.
+function writeCredentialFile(name: string, content: string): string | null {
+	if (basename(name) !== name || name.startsWith(".")) {
+		console.warn(`Ignoring credential file with unsafe name: ${name}`);
+		return null;
+	}
+	try {
+		const dir = getCredentialsDir();
+		mkdirSync(dir, { recursive: true, mode: 0o700 });
+		const path = join(dir, name);
+		writeFileSync(path, content, { mode: 0o600 });
+		return path;
+	} catch (error) {
+		throw new Error(
+			`Failed to write credential file ${name}: ${error instanceof Error ? error.message : String(error)}`,
+		);
+	}
+}
+
+// Remove credential files that are no longer in the current credentials,
+// so revoked registry tokens don't linger
+function removeStaleCredentialFiles(creds: CredentialEnvVar[]): void {
+	const keep = new Set(creds.map((c) => c.file).filter(Boolean));
+	const dir = getCredentialsDir();
+	let names: string[];
+	try {
+		names = readdirSync(dir);
+	} catch {
+		return;
+	}
+	for (const name of names) {
+		if (!keep.has(name)) {
+			rmSync(join(dir, name), { recursive: true, force: true });
+		}
+	}
+}
+
+// Check if credentials have changed and return the new environment if so.
+// Throws if a credential file can't be written.
 export function checkCredentialsChanged(headerValue: string | null): {
 	changed: boolean;
 	env: Record<string, string>;
 } {
 	const creds = parseCredentialsHeader(headerValue);
-	const changed = updateCredentials(creds);
+	// Files are written before the credentials are recorded, so a failed
+	// write is retried on the next request
 	const env = credentialsToEnv(creds);
+	const changed = updateCredentials(creds);
+	if (changed) {
+		removeStaleCredentialFiles(creds);
+	}
 
 	return { changed, env };
 }
@@ -4,21 +4,30 @@ import { logger } from "hono/logger";
 import { streamSSE } from "hono/streaming";
 import type { Agent } from "../agent/interface.js";
 import type {
+	AbortUploadResponse,
 	ChatRequest,
 	ChatStatusResponse,
 	ClearSessionResponse,
 	CommitsErrorResponse,
 	CommitsResponse,
+	CreateUploadRequest,
 	DeleteFileRequest,
 	DeleteFileResponse,
+	DeleteKVResponse,
 	DiffFilesResponse,
 	DiffResponse,
 	ErrorResponse,
+	FileDescriptorsResponse,
 	GetMessagesResponse,
 	HealthResponse,
+	KVEntry,
 	ListFilesResponse,
 	ListServicesResponse,
 	ModelsResponse,
+	NetworkTestRequest,
+	NetworkTestResponse,
+	ProxyStatsResponse,
+	PutKVRequest,
 	ReadFileResponse,
 	RenameFileRequest,
 	RenameFileResponse,
@@ -32,6 +41,8 @@ import type {
 	SingleFileDiffResponse,
 	StartServiceResponse,
 	StopServiceResponse,
+	UploadOffsetConflictResponse,
+	UploadStatus,
 	UserResponse,
 	WriteFileRequest,
 	WriteFileResponse,
@@ -62,6 +73,7 @@ import {
 	tryCancelCompletion,
 	tryStartCompletion,
 } from "./completion.js";
+import { getFileDescriptorStats } from "./fds.js";
 import {
 	deleteFile,
 	getDiff,
@@ -71,6 +83,17 @@ import {
 	renameFile,
 	writeFile,
 } from "./files.js";
+import { deleteValue, getValue, isKVError, putValue } from "./kv.js";
+import {
+	abortUpload,
+	appendUpload,
+	createUpload,
+	getUpload,
+	isUploadError,
+	MAX_UPLOAD_CHUNK_SIZE,
+} from "./uploads.js";
+import { testNetwork } from "./network.js";
+import { getProxyStats } from "./proxy-stats.js";
 
 // Header names for credentials and git config passed from server
 const CREDENTIALS_HEADER = "X-Discobot-Credentials";
@@ -505,6 +528,82 @@ export function createApp(options: AppOptions) {
 		return c.json<RenameFileResponse>(result);
 	});
 
+	// POST /files/uploads - Start a resumable upload
+	app.post("/files/uploads", async (c) => {
+		const body = await c.req.json<CreateUploadRequest>();
+
+		if (!body.path) {
+			return c.json<ErrorResponse>({ error: "path is required" }, 400);
+		}
+		if (body.size === undefined) {
+			return c.json<ErrorResponse>({ error: "size is required" }, 400);
+		}
+
+		const result = await createUpload(body.path, body.size, options.agentCwd);
+
+		if (isUploadError(result)) {
+			return c.json<ErrorResponse>({ error: result.error }, result.status);
+		}
+		return c.json<UploadStatus>(result);
+	});
+
+	// GET /files/uploads/:id - Get upload progress (to resume from its offset)
+	app.get("/files/uploads/:id", async (c) => {
+		const result = await getUpload(c.req.param("id"));
+
+		if (isUploadError(result)) {
+			return c.json<ErrorResponse>({ error: result.error }, result.status);
+		}
+		return c.json<UploadStatus>(result);
+	});
+
+	// PUT /files/uploads/:id?offset=N - Append a chunk (raw request body)
+	app.put("/files/uploads/:id", async (c) => {
+		const offset = Number(c.req.query("offset"));
+		if (!c.req.query("offset") || !Number.isSafeInteger(offset)) {
+			return c.json<ErrorResponse>(
+				{ error: "offset query parameter required" },
+				400,
+			);
+		}
+		const length = Number(c.req.header("Content-Length"));
+		if (length > MAX_UPLOAD_CHUNK_SIZE) {
+			return c.json<ErrorResponse>(
+				{ error: `Chunk exceeds ${MAX_UPLOAD_CHUNK_SIZE} bytes` },
+				413,
+			);
+		}
+
+		const data = Buffer.from(await c.req.arrayBuffer());
+		const result = await appendUpload(
+			c.req.param("id"),
+			offset,
+			data,
+			options.agentCwd,
+		);
+
+		if (isUploadError(result)) {
+			if (result.status === 409 && result.offset !== undefined) {
+				return c.json<UploadOffsetConflictResponse>(
+					{ error: result.error, offset: result.offset },
+					409,
+				);
+			}
+			return c.json<ErrorResponse>({ error: result.error }, result.status);
+		}
+		return c.json<UploadStatus>(result);
+	});
+
+	// DELETE /files/uploads/:id - Abort an upload
+	app.delete("/files/uploads/:id", async (c) => {
+		const result = await abortUpload(c.req.param("id"));
+
+		if (isUploadError(result)) {
+			return c.json<ErrorResponse>({ error: result.error }, result.status);
+		}
+		return c.json<AbortUploadResponse>(result);
+	});
+
 	// GET /diff - Get session diff
 	// Query params:
 	//   - path: optional single file path to get diff for
@@ -534,6 +633,103 @@ export function createApp(options: AppOptions) {
 		return c.json<DiffResponse>(result as DiffResponse);
 	});
 
+	// =========================================================================
+	// Key-Value Store Endpoints
+	// =========================================================================
+
+	// GET /kv/:key - Read a scratchpad value
+	app.get("/kv/:key", async (c) => {
+		const result = await getValue(c.req.param("key"));
+
+		if (isKVError(result)) {
+			return c.json<ErrorResponse>({ error: result.error }, result.status);
+		}
+		return c.json<KVEntry>(result);
+	});
+
+	// PUT /kv/:key - Create or replace a scratchpad value
+	app.put("/kv/:key", async (c) => {
+		const body = await c.req.json<PutKVRequest>();
+
+		if (typeof body.value !== "string") {
+			return c.json<ErrorResponse>({ error: "value must be a string" }, 400);
+		}
+
+		const result = await putValue(c.req.param("key"), body.value);
+
+		if (isKVError(result)) {
+			return c.json<ErrorResponse>({ error: result.error }, result.status);
+		}
+		return c.json<KVEntry>(result);
+	});
+
+	// DELETE /kv/:key - Delete a scratchpad value
+	app.delete("/kv/:key", async (c) => {
+		const result = await deleteValue(c.req.param("key"));
+
+		if (isKVError(result)) {
+			return c.json<ErrorResponse>({ error: result.error }, result.status);
+		}
+		return c.json<DeleteKVResponse>(result);
+	});
+
+	// =========================================================================
+	// Network Test Endpoint
+	// =========================================================================
+
+	// POST /network/test - Check whether the sandbox can reach a URL or host
+	app.post("/network/test", async (c) => {
+		const body = await c.req.json<NetworkTestRequest>();
+
+		if (typeof body.target !== "string") {
+			return c.json<ErrorResponse>({ error: "target must be a string" }, 400);
+		}
+		if (body.timeoutMs !== undefined && typeof body.timeoutMs !== "number") {
+			return c.json<ErrorResponse>(
+				{ error: "timeoutMs must be a number" },
+				400,
+			);
+		}
+
+		const result = await testNetwork(body);
+		if (!result) {
+			return c.json<ErrorResponse>(
+				{ error: "target must be an http(s) URL or host" },
+				400,
+			);
+		}
+		return c.json<NetworkTestResponse>(result);
+	});
+
+	// =========================================================================
+	// Proxy Stats Endpoint
+	// =========================================================================
+
+	// GET /proxy/stats - Per-domain traffic statistics from the MITM proxy
+	app.get("/proxy/stats", async (c) => {
+		const stats = await getProxyStats();
+		if (!stats) {
+			return c.json<ErrorResponse>({ error: "proxy is not running" }, 503);
+		}
+		return c.json<ProxyStatsResponse>(stats);
+	});
+
+	// =========================================================================
+	// File Descriptor Stats Endpoint
+	// =========================================================================
+
+	// GET /fds - Open file descriptors of the sandbox's processes and limits
+	app.get("/fds", async (c) => {
+		const stats = await getFileDescriptorStats();
+		if (!stats) {
+			return c.json<ErrorResponse>(
+				{ error: "file descriptor stats are not available" },
+				503,
+			);
+		}
+		return c.json<FileDescriptorsResponse>(stats);
+	});
+
 	// =========================================================================
 	// Git Commits Endpoint (for commit workflow)
 	// =========================================================================
@@ -39,7 +39,8 @@ let hookRetryCount = 0;
 export type StartCompletionResult =
 	| { ok: true; status: 202; response: ChatStartedResponse }
 	| { ok: false; status: 409; response: ChatConflictResponse }
-	| { ok: false; status: 400; response: ErrorResponse };
+	| { ok: false; status: 400; response: ErrorResponse }
+	| { ok: false; status: 500; response: ErrorResponse };
 
 /**
  * Attempt to start a chat completion. Validates the request, checks for
@@ -99,7 +100,8 @@ export function tryStartCompletion(
 	}
 
 	// Get the last user message to send
-	const lastUserMessage = inputMessages.filter((m) => m.role === "user").pop();
+	const lastUserIndex = inputMessages.findLastIndex((m) => m.role === "user");
+	const lastUserMessage = inputMessages[lastUserIndex];
 	if (!lastUserMessage) {
 		return {
 			ok: false,
@@ -108,6 +110,19 @@ export function tryStartCompletion(
 		};
 	}
 
+	// Check for credential changes. A credential file that can't be written
+	// fails the request rather than running without it.
+	let credentialsChanged: boolean;
+	let credentialEnv: Record<string, string>;
+	try {
+		({ changed: credentialsChanged, env: credentialEnv } =
+			checkCredentialsChanged(credentialsHeader));
+	} catch (error) {
+		const message = error instanceof Error ? error.message : String(error);
+		log({ event: "credentials_failed", error: message });
+		return { ok: false, status: 500, response: { error: message } };
+	}
+
 	// Mark completion as started (atomically check and set)
 	if (!startCompletion(completionId)) {
 		// Race condition - another request started between our check and now
@@ -128,10 +143,6 @@ export function tryStartCompletion(
 
 	log({ event: "started" });
 
-	// Check for credential changes
-	const { changed: credentialsChanged, env: credentialEnv } =
-		checkCredentialsChanged(credentialsHeader);
-
 	// Store agent and session references for cancellation
 	currentAgent = agent;
 	currentSessionId = sessionId;
@@ -142,6 +153,9 @@ export function tryStartCompletion(
 		agent,
 		completionId,
 		lastUserMessage,
+		// The messages before it, as the server forwards them (possibly
+		// trimmed or summarized by its chat history policy)
+		inputMessages.slice(0, lastUserIndex),
 		credentialsChanged,
 		credentialEnv,
 		gitUserName,
@@ -246,6 +260,7 @@ function runCompletion(
 	agent: Agent,
 	_completionId: string,
 	lastUserMessage: UIMessage,
+	history: UIMessage[],
 	credentialsChanged: boolean,
 	credentialEnv: Record<string, string>,
 	gitUserName: string | null,
@@ -302,6 +317,7 @@ function runCompletion(
 				sessionId,
 				model,
 				reasoning,
+				history,
 			)) {
 				if (abortSignal.aborted) {
 					addCompletionEvent({
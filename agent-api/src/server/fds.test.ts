@@ -0,0 +1,85 @@
+import assert from "node:assert/strict";
+import { mkdir, rm, writeFile } from "node:fs/promises";
+import { join } from "node:path";
+import { after, before, describe, it } from "node:test";
+import { getFileDescriptorStats, parseNoFileLimits } from "./fds.js";
+
+const LIMITS_HEADER =
+	"Limit                     Soft Limit           Hard Limit           Units\n";
+
+function limits(soft: string, hard: string): string {
+	return `${LIMITS_HEADER}Max open files            ${soft}                 ${hard}              files\n`;
+}
+
+describe("parseNoFileLimits", () => {
+	it("parses soft and hard limits", () => {
+		assert.deepEqual(parseNoFileLimits(limits("1024", "524288")), {
+			soft: 1024,
+			hard: 524288,
+		});
+	});
+
+	it("treats unlimited as null", () => {
+		assert.deepEqual(parseNoFileLimits(limits("unlimited", "unlimited")), {
+			soft: null,
+			hard: null,
+		});
+	});
+
+	it("returns null without the row", () => {
+		assert.equal(parseNoFileLimits(LIMITS_HEADER), null);
+	});
+});
+
+describe("getFileDescriptorStats", () => {
+	const procRoot = "/tmp/agent-api-fds-test";
+
+	async function fakeProcess(
+		pid: number,
+		command: string,
+		open: number,
+		soft: string,
+	) {
+		const dir = join(procRoot, String(pid));
+		await mkdir(join(dir, "fd"), { recursive: true });
+		for (let fd = 0; fd < open; fd++) {
+			await writeFile(join(dir, "fd", String(fd)), "");
+		}
+		await writeFile(join(dir, "limits"), limits(soft, "4096"));
+		await writeFile(join(dir, "comm"), `${command}\n`);
+	}
+
+	before(async () => {
+		await rm(procRoot, { recursive: true, force: true });
+		await fakeProcess(1, "node", 5, "1024");
+		await fakeProcess(2, "leaky-tool", 9, "10");
+		await fakeProcess(3, "bash", 3, "unlimited");
+		// Not a process, and a process that can't be read
+		await mkdir(join(procRoot, "sys/fs"), { recursive: true });
+		await writeFile(join(procRoot, "sys/fs/file-nr"), "900\t0\t1000\n");
+		await mkdir(join(procRoot, "4"));
+	});
+
+	after(async () => {
+		await rm(procRoot, { recursive: true, force: true });
+	});
+
+	it("reports processes by open descriptors, with warnings", async () => {
+		const stats = await getFileDescriptorStats(procRoot, 1);
+		assert.ok(stats);
+		assert.equal(stats.self.command, "node");
+		assert.equal(stats.self.open, 5);
+		assert.deepEqual(stats.top.map((p) => p.pid), [2, 1, 3]);
+		assert.equal(stats.top[2].softLimit, null);
+		assert.equal(stats.totalOpen, 17);
+		assert.deepEqual(stats.system, { allocated: 900, max: 1000 });
+		assert.deepEqual(stats.warnings, [
+			"leaky-tool (pid 2) has 9 of 10 file descriptors open",
+			"The system has 900 of 1000 file handles allocated",
+		]);
+	});
+
+	it("returns null when the process can't be inspected", async () => {
+		assert.equal(await getFileDescriptorStats(procRoot, 99), null);
+	});
+});
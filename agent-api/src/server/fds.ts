@@ -0,0 +1,129 @@
+/**
+ * File Descriptor Monitoring
+ *
+ * Counts the open file descriptors of the processes in the sandbox from
+ * /proc, so tools that leak them are noticed before they hit the limit and
+ * fail in ways that are hard to trace back.
+ */
+
+import { readdir, readFile } from "node:fs/promises";
+import { join } from "node:path";
+import type { FileDescriptorsResponse, ProcessFDStats } from "../api/types.js";
+
+/** Share of a limit at or above which a warning is reported */
+export const FD_WARNING_RATIO = 0.8;
+
+/** Number of processes reported in `top` */
+const TOP_PROCESSES = 10;
+
+/**
+ * Parses the "Max open files" row of /proc/<pid>/limits. Returns null if the
+ * row is missing; a limit of "unlimited" is null.
+ */
+export function parseNoFileLimits(
+	limits: string,
+): { soft: number | null; hard: number | null } | null {
+	const row = "Max open files";
+	for (const line of limits.split("\n")) {
+		if (!line.startsWith(row)) {
+			continue;
+		}
+		const [soft, hard] = line.slice(row.length).trim().split(/\s+/);
+		return { soft: parseLimit(soft), hard: parseLimit(hard) };
+	}
+	return null;
+}
+
+function parseLimit(value: string | undefined): number | null {
+	if (!value || value === "unlimited") {
+		return null;
+	}
+	const n = Number(value);
+	return Number.isFinite(n) ? n : null;
+}
+
+async function processStats(
+	procRoot: string,
+	pid: string,
+): Promise<ProcessFDStats | null> {
+	const dir = join(procRoot, pid);
+	try {
+		const [fds, limits, comm] = await Promise.all([
+			readdir(join(dir, "fd")),
+			readFile(join(dir, "limits"), "utf8"),
+			readFile(join(dir, "comm"), "utf8"),
+		]);
+		const nofile = parseNoFileLimits(limits);
+		return {
+			pid: Number(pid),
+			command: comm.trim(),
+			open: fds.length,
+			softLimit: nofile?.soft ?? null,
+			hardLimit: nofile?.hard ?? null,
+		};
+	} catch {
+		// Exited, or owned by another user (root's processes aren't readable)
+		return null;
+	}
+}
+
+async function systemStats(
+	procRoot: string,
+): Promise<FileDescriptorsResponse["system"]> {
+	try {
+		// Allocated handles, allocated but unused (always 0), and the maximum
+		const fileNr = await readFile(join(procRoot, "sys/fs/file-nr"), "utf8");
+		const [allocated, , max] = fileNr.trim().split(/\s+/).map(Number);
+		if (!Number.isFinite(allocated) || !Number.isFinite(max)) {
+			return null;
+		}
+		return { allocated, max };
+	} catch {
+		return null;
+	}
+}
+
+/**
+ * Reports the open file descriptors of the agent API and of the processes
+ * with the most open, with warnings for any at or above FD_WARNING_RATIO of
+ * their soft limit. Only processes of the agent API's user can be inspected.
+ * Returns null if /proc isn't available.
+ */
+export async function getFileDescriptorStats(
+	procRoot = "/proc",
+	selfPid: number = process.pid,
+): Promise<FileDescriptorsResponse | null> {
+	const self = await processStats(procRoot, String(selfPid));
+	if (!self) {
+		return null;
+	}
+
+	const pids = (await readdir(procRoot)).filter((name) => /^\d+$/.test(name));
+	const processes = (
+		await Promise.all(pids.map((pid) => processStats(procRoot, pid)))
+	).filter((p): p is ProcessFDStats => p !== null);
+	processes.sort((a, b) => b.open - a.open);
+
+	const warnings: string[] = [];
+	for (const p of processes) {
+		if (p.softLimit && p.open >= p.softLimit * FD_WARNING_RATIO) {
+			warnings.push(
+				`${p.command} (pid ${p.pid}) has ${p.open} of ${p.softLimit} file descriptors open`,
+			);
+		}
+	}
+	const system = await systemStats(procRoot);
+	if (system && system.allocated >= system.max * FD_WARNING_RATIO) {
+		warnings.push(
+			`The system has ${system.allocated} of ${system.max} file handles allocated`,
+		);
+	}
+
+	return {
+		self,
+		top: processes.slice(0, TOP_PROCESSES),
+		totalOpen: processes.reduce((sum, p) => sum + p.open, 0),
+		system,
+		warnings,
+	};
+}
@@ -0,0 +1,113 @@
+import assert from "node:assert/strict";
+import { readdir, rm } from "node:fs/promises";
+import { after, before, describe, it } from "node:test";
+import {
+	deleteValue,
+	getValue,
+	isKVError,
+	isValidKey,
+	MAX_KV_TOTAL_SIZE,
+	MAX_KV_VALUE_SIZE,
+	putValue,
+} from "./kv.js";
+
+describe("isValidKey", () => {
+	it("allows simple names", () => {
+		assert.equal(isValidKey("task-progress"), true);
+		assert.equal(isValidKey("tool.config_v2"), true);
+	});
+
+	it("rejects names that could escape the store", () => {
+		assert.equal(isValidKey(""), false);
+		assert.equal(isValidKey(".."), false);
+		assert.equal(isValidKey(".hidden"), false);
+		assert.equal(isValidKey("a/b"), false);
+		assert.equal(isValidKey("a~tmp"), false);
+		assert.equal(isValidKey("x".repeat(129)), false);
+	});
+});
+
+describe("kv store", () => {
+	const testDir = "/tmp/agent-api-kv-test";
+
+	before(async () => {
+		await rm(testDir, { recursive: true, force: true });
+	});
+
+	after(async () => {
+		await rm(testDir, { recursive: true, force: true });
+	});
+
+	it("round-trips a value", async () => {
+		const put = await putValue("progress", "step 3 of 5", testDir);
+		assert.equal(isKVError(put), false);
+
+		const got = await getValue("progress", testDir);
+		assert.equal(isKVError(got), false);
+		if (!isKVError(got)) {
+			assert.equal(got.value, "step 3 of 5");
+			assert.equal(got.size, 11);
+		}
+	});
+
+	it("returns 404 for missing keys", async () => {
+		const got = await getValue("missing", testDir);
+		assert.ok(isKVError(got));
+		assert.equal(got.status, 404);
+
+		const deleted = await deleteValue("missing", testDir);
+		assert.ok(isKVError(deleted));
+		assert.equal(deleted.status, 404);
+	});
+
+	it("deletes values", async () => {
+		await putValue("temp", "x", testDir);
+		const deleted = await deleteValue("temp", testDir);
+		assert.deepEqual(deleted, { key: "temp" });
+
+		const got = await getValue("temp", testDir);
+		assert.ok(isKVError(got));
+		assert.equal(got.status, 404);
+	});
+
+	it("rejects oversized values", async () => {
+		const put = await putValue(
+			"big",
+			"x".repeat(MAX_KV_VALUE_SIZE + 1),
+			testDir,
+		);
+		assert.ok(isKVError(put));
+		assert.equal(put.status, 413);
+	});
+
+	it("enforces the total size limit, excluding the replaced value", async () => {
+		const dir = `${testDir}/total`;
+		const chunk = "x".repeat(MAX_KV_VALUE_SIZE);
+		const count = MAX_KV_TOTAL_SIZE / MAX_KV_VALUE_SIZE;
+		for (let i = 0; i < count; i++) {
+			const put = await putValue(`chunk-${i}`, chunk, dir);
+			assert.equal(isKVError(put), false);
+		}
+
+		const over = await putValue("one-more", "x", dir);
+		assert.ok(isKVError(over));
+		assert.equal(over.status, 413);
+
+		const replace = await putValue("chunk-0", chunk, dir);
+		assert.equal(isKVError(replace), false);
+	});
+
+	it("serializes concurrent writes", async () => {
+		const dir = `${testDir}/concurrent`;
+		await Promise.all(
+			Array.from({ length: 20 }, (_, i) => putValue("shared", `v${i}`, dir)),
+		);
+
+		const got = await getValue("shared", dir);
+		assert.equal(isKVError(got), false);
+		if (!isKVError(got)) {
+			assert.equal(got.value, "v19");
+		}
+		assert.deepEqual(await readdir(dir), ["shared"]);
+	});
+});
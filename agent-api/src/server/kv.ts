@@ -0,0 +1,197 @@
+/**
+ * Session Key-Value Store
+ *
+ * A small persistent scratch space for agents and tools, kept outside the
+ * workspace (and outside the home overlay) so it survives restarts without
+ * showing up in git. Each key is stored as one file in the store directory;
+ * writes go through a temp file and rename so readers never see partial values.
+ */
+
+import {
+	readFile as fsReadFile,
+	mkdir,
+	readdir,
+	rename,
+	rm,
+	stat,
+	writeFile,
+} from "node:fs/promises";
+import { homedir } from "node:os";
+import { join } from "node:path";
+import type { DeleteKVResponse, KVEntry } from "../api/types.js";
+
+// Maximum size of a single value (64KB)
+export const MAX_KV_VALUE_SIZE = 64 * 1024;
+
+// Maximum combined size of all values (1MB)
+export const MAX_KV_TOTAL_SIZE = 1024 * 1024;
+
+// Keys are used as file names, so keep them to a safe character set
+const KEY_PATTERN = /^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$/;
+
+// Suffix for in-progress writes (never a valid key since keys can't contain "~")
+const TEMP_SUFFIX = "~tmp";
+
+/**
+ * Returns the store directory: DISCOBOT_KV_DIR (set by the sandbox init to a
+ * directory under /.data), or ~/.config/discobot/kv when running locally.
+ */
+export function getKVDir(): string {
+	return (
+		process.env.DISCOBOT_KV_DIR ||
+		join(homedir(), ".config", "discobot", "kv")
+	);
+}
+
+/** HTTP status codes used for KV errors */
+export type KVErrorStatus = 400 | 404 | 413;
+
+export interface KVError {
+	error: string;
+	status: KVErrorStatus;
+}
+
+export type KVResult<T> = T | KVError;
+
+export function isKVError(result: KVResult<unknown>): result is KVError {
+	return (
+		typeof result === "object" &&
+		result !== null &&
+		"error" in result &&
+		"status" in result
+	);
+}
+
+export function isValidKey(key: string): boolean {
+	return KEY_PATTERN.test(key);
+}
+
+// Writes and deletes are serialized so the total size check can't race
+let writeQueue: Promise<unknown> = Promise.resolve();
+
+function serialize<T>(fn: () => Promise<T>): Promise<T> {
+	const result = writeQueue.then(fn);
+	writeQueue = result.catch(() => {});
+	return result;
+}
+
+/**
+ * Reads a value.
+ */
+export async function getValue(
+	key: string,
+	dir: string = getKVDir(),
+): Promise<KVResult<KVEntry>> {
+	if (!isValidKey(key)) {
+		return { error: "Invalid key", status: 400 };
+	}
+
+	const path = join(dir, key);
+	try {
+		const [content, stats] = await Promise.all([
+			fsReadFile(path, "utf8"),
+			stat(path),
+		]);
+		return {
+			key,
+			value: content,
+			size: stats.size,
+			updatedAt: stats.mtime.toISOString(),
+		};
+	} catch (err) {
+		if ((err as NodeJS.ErrnoException).code === "ENOENT") {
+			return { error: "Key not found", status: 404 };
+		}
+		throw err;
+	}
+}
+
+/**
+ * Creates or replaces a value, enforcing the per-value and total size limits.
+ */
+export function putValue(
+	key: string,
+	value: string,
+	dir: string = getKVDir(),
+): Promise<KVResult<KVEntry>> {
+	if (!isValidKey(key)) {
+		return Promise.resolve({ error: "Invalid key", status: 400 });
+	}
+	const size = Buffer.byteLength(value, "utf8");
+	if (size > MAX_KV_VALUE_SIZE) {
+		return Promise.resolve({
+			error: `Value exceeds ${MAX_KV_VALUE_SIZE} bytes`,
+			status: 413,
+		});
+	}
+
+	return serialize(async () => {
+		await mkdir(dir, { recursive: true });
+
+		// The replaced value (if any) doesn't count toward the new total
+		const sizes = await entrySizes(dir);
+		const total = Array.from(sizes.values()).reduce((a, b) => a + b, 0);
+		if (total - (sizes.get(key) ?? 0) + size > MAX_KV_TOTAL_SIZE) {
+			return {
+				error: `Store exceeds ${MAX_KV_TOTAL_SIZE} bytes`,
+				status: 413,
+			};
+		}
+
+		const path = join(dir, key);
+		const tempPath = path + TEMP_SUFFIX;
+		await writeFile(tempPath, value, "utf8");
+		await rename(tempPath, path);
+
+		return {
+			key,
+			value,
+			size,
+			updatedAt: new Date().toISOString(),
+		};
+	});
+}
+
+/**
+ * Deletes a value.
+ */
+export function deleteValue(
+	key: string,
+	dir: string = getKVDir(),
+): Promise<KVResult<DeleteKVResponse>> {
+	if (!isValidKey(key)) {
+		return Promise.resolve({ error: "Invalid key", status: 400 });
+	}
+
+	return serialize(async () => {
+		try {
+			await stat(join(dir, key));
+		} catch (err) {
+			if ((err as NodeJS.ErrnoException).code === "ENOENT") {
+				return { error: "Key not found", status: 404 };
+			}
+			throw err;
+		}
+		await rm(join(dir, key), { force: true });
+		return { key };
+	});
+}
+
+/**
+ * Returns the size of every stored value, keyed by name.
+ */
+async function entrySizes(dir: string): Promise<Map<string, number>> {
+	const sizes = new Map<string, number>();
+	const names = await readdir(dir);
+	for (const name of names) {
+		if (!isValidKey(name)) {
+			continue;
+		}
+		try {
+			sizes.set(name, (await stat(join(dir, name))).size);
+		} catch {
+			// Removed concurrently
+		}
+	}
+	return sizes;
+}
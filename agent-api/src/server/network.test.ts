@@ -0,0 +1,135 @@
+import assert from "node:assert/strict";
+import { createServer, type Server } from "node:http";
+import type { AddressInfo } from "node:net";
+import { after, before, describe, it } from "node:test";
+import { parseTarget, proxyFor, testNetwork } from "./network.js";
+
+function listen(server: Server): Promise<number> {
+	return new Promise((resolve) => {
+		server.listen(0, "127.0.0.1", () => {
+			resolve((server.address() as AddressInfo).port);
+		});
+	});
+}
+
+describe("parseTarget", () => {
+	it("treats bare hosts as https", () => {
+		assert.equal(
+			parseTarget("example.com")?.toString(),
+			"https://example.com/",
+		);
+		assert.equal(
+			parseTarget("example.com:8443")?.toString(),
+			"https://example.com:8443/",
+		);
+	});
+
+	it("rejects non-http targets", () => {
+		assert.equal(parseTarget(""), null);
+		assert.equal(parseTarget("ftp://example.com"), null);
+		assert.equal(parseTarget("http://"), null);
+	});
+});
+
+describe("proxyFor", () => {
+	const env = {
+		HTTPS_PROXY: "http://localhost:17080",
+		NO_PROXY: "localhost,.internal.example.com",
+	};
+
+	it("uses the proxy for other hosts", () => {
+		assert.equal(
+			proxyFor(new URL("https://example.com"), env)?.origin,
+			"http://localhost:17080",
+		);
+	});
+
+	it("honors NO_PROXY", () => {
+		assert.equal(proxyFor(new URL("https://localhost:3000"), env), null);
+		assert.equal(
+			proxyFor(new URL("https://api.internal.example.com"), env),
+			null,
+		);
+		assert.equal(proxyFor(new URL("http://example.com"), env), null);
+	});
+});
+
+describe("testNetwork", () => {
+	const target = createServer((_req, res) => {
+		res.writeHead(204);
+		res.end();
+	});
+
+	// Stands in for the sandbox proxy with every host blocked
+	const proxy = createServer((_req, res) => {
+		res.writeHead(403, { "Content-Type": "text/plain" });
+		res.end("Blocked by proxy");
+	});
+	proxy.on("connect", (_req, socket) => socket.end());
+
+	let targetPort = 0;
+	let proxyEnv: NodeJS.ProcessEnv = {};
+
+	before(async () => {
+		targetPort = await listen(target);
+		const proxyURL = `http://127.0.0.1:${await listen(proxy)}`;
+		proxyEnv = { HTTP_PROXY: proxyURL, HTTPS_PROXY: proxyURL };
+	});
+
+	after(() => {
+		target.close();
+		proxy.close();
+	});
+
+	it("reaches a server directly", async () => {
+		const result = await testNetwork(
+			{ target: `http://127.0.0.1:${targetPort}/health` },
+			{},
+		);
+		assert.ok(result);
+		assert.equal(result.reachable, true);
+		assert.equal(result.dns.ok, true);
+		assert.equal(result.proxy.used, false);
+		assert.equal(result.connect.ok, true);
+		assert.equal(result.http?.status, 204);
+	});
+
+	it("reports refused connections", async () => {
+		target.close();
+		const result = await testNetwork(
+			{ target: `http://127.0.0.1:${targetPort}` },
+			{},
+		);
+		assert.ok(result);
+		assert.equal(result.reachable, false);
+		assert.equal(result.connect.ok, false);
+		assert.match(result.connect.error ?? "", /ECONNREFUSED/);
+	});
+
+	it("reports hosts blocked on CONNECT", async () => {
+		const result = await testNetwork(
+			{ target: "blocked.example.com" },
+			proxyEnv,
+		);
+		assert.ok(result);
+		assert.equal(result.reachable, false);
+		assert.equal(result.proxy.used, true);
+		assert.equal(result.proxy.allowed, false);
+		assert.equal(result.tls, undefined);
+	});
+
+	it("reports plain HTTP requests blocked by the proxy", async () => {
+		const result = await testNetwork(
+			{ target: "http://blocked.example.com" },
+			proxyEnv,
+		);
+		assert.ok(result);
+		assert.equal(result.reachable, false);
+		assert.equal(result.proxy.allowed, false);
+		assert.equal(result.http?.status, 403);
+	});
+
+	it("rejects invalid targets", async () => {
+		assert.equal(await testNetwork({ target: "ftp://example.com" }, {}), null);
+	});
+});
@@ -0,0 +1,308 @@
+/**
+ * Network Reachability Test
+ *
+ * Checks whether the sandbox can reach a URL or host the same way agent tools
+ * do: through the MITM proxy when HTTP(S)_PROXY is set, directly otherwise.
+ * Each step (DNS, connect, proxy decision, TLS, HTTP) is timed and reported
+ * separately so users can tell which one is failing.
+ */
+
+import { lookup } from "node:dns/promises";
+import { connect as netConnect, type Socket } from "node:net";
+import { connect as tlsConnect, type TLSSocket } from "node:tls";
+import type {
+	NetworkTestRequest,
+	NetworkTestResponse,
+	NetworkTestStep,
+} from "../api/types.js";
+
+// Default and maximum time allowed for each step
+export const DEFAULT_NETWORK_TEST_TIMEOUT_MS = 10_000;
+export const MAX_NETWORK_TEST_TIMEOUT_MS = 30_000;
+
+// Body of the proxy's 403 for blocked plain HTTP requests
+const PROXY_BLOCKED_BODY = "Blocked by proxy";
+
+/**
+ * Parses a network test target. Bare hosts ("example.com", "example.com:8443")
+ * are treated as HTTPS URLs. Returns null for anything but http(s) URLs.
+ */
+export function parseTarget(target: string): URL | null {
+	const trimmed = target.trim();
+	if (trimmed === "") {
+		return null;
+	}
+	try {
+		const url = new URL(
+			trimmed.includes("://") ? trimmed : `https://${trimmed}`,
+		);
+		if (url.protocol !== "http:" && url.protocol !== "https:") {
+			return null;
+		}
+		return url.hostname === "" ? null : url;
+	} catch {
+		return null;
+	}
+}
+
+/**
+ * Returns the proxy used for a target given the environment, honoring
+ * NO_PROXY (exact hosts, domain suffixes like ".example.com", and "*").
+ */
+export function proxyFor(
+	url: URL,
+	env: NodeJS.ProcessEnv = process.env,
+): URL | null {
+	const raw =
+		url.protocol === "https:"
+			? env.HTTPS_PROXY || env.https_proxy || env.ALL_PROXY || env.all_proxy
+			: env.HTTP_PROXY || env.http_proxy || env.ALL_PROXY || env.all_proxy;
+	if (!raw) {
+		return null;
+	}
+
+	const host = stripBrackets(url.hostname).toLowerCase();
+	const noProxy = (env.NO_PROXY || env.no_proxy || "")
+		.split(",")
+		.map((entry) => entry.trim().toLowerCase())
+		.filter(Boolean);
+	for (const entry of noProxy) {
+		if (entry === "*") {
+			return null;
+		}
+		const domain = entry.replace(/^\*?\./, "");
+		if (host === domain || host.endsWith(`.${domain}`)) {
+			return null;
+		}
+	}
+
+	try {
+		return new URL(raw.includes("://") ? raw : `http://${raw}`);
+	} catch {
+		return null;
+	}
+}
+
+/**
+ * Tests whether the sandbox can reach the request's target.
+ * Returns null if the target isn't a valid http(s) URL or host.
+ */
+export async function testNetwork(
+	req: NetworkTestRequest,
+	env: NodeJS.ProcessEnv = process.env,
+): Promise<NetworkTestResponse | null> {
+	const url = parseTarget(req.target);
+	if (!url) {
+		return null;
+	}
+	const timeoutMs = Math.min(
+		Math.max(req.timeoutMs || DEFAULT_NETWORK_TEST_TIMEOUT_MS, 1),
+		MAX_NETWORK_TEST_TIMEOUT_MS,
+	);
+	const https = url.protocol === "https:";
+	const host = stripBrackets(url.hostname);
+	const port = Number(url.port) || (https ? 443 : 80);
+	const proxy = proxyFor(url, env);
+
+	// DNS is resolved locally even when proxied; the proxy runs in the same
+	// sandbox and uses the same resolver
+	const [dns, addresses] = await timed(timeoutMs, async () =>
+		(await lookup(host, { all: true })).map((a) => a.address),
+	);
+	const result: NetworkTestResponse = {
+		target: req.target,
+		url: url.toString(),
+		reachable: false,
+		dns: { ...dns, addresses },
+		proxy: proxy ? { used: true, url: proxy.origin } : { used: false },
+		connect: { ok: false, durationMs: 0 },
+	};
+	if (!dns.ok && !proxy) {
+		return result;
+	}
+
+	// Connect to the target, or to the proxy when one is used
+	const [connect, tcpSocket] = await timed(timeoutMs, () =>
+		proxy
+			? openSocket(stripBrackets(proxy.hostname), Number(proxy.port) || 80)
+			: openSocket(host, port),
+	);
+	result.connect = connect;
+	if (!tcpSocket) {
+		return result;
+	}
+
+	let socket: Socket = tcpSocket;
+	try {
+		// HTTPS through the proxy: the proxy decides on CONNECT, and closes
+		// the connection without a response when the host is blocked
+		if (proxy && https) {
+			const [step, head] = await timed(timeoutMs, () =>
+				exchange(
+					socket,
+					`CONNECT ${host}:${port} HTTP/1.1\r\nHost: ${host}:${port}\r\n\r\n`,
+				),
+			);
+			const status = head ? statusOf(head) : undefined;
+			result.proxy.allowed = status === 200;
+			if (status !== 200) {
+				result.proxy.error = !step.ok
+					? step.error
+					: status === undefined
+						? "Proxy closed the connection (host blocked)"
+						: `Proxy returned status ${status}`;
+				return result;
+			}
+		}
+
+		if (https) {
+			const [tls, tlsSocket] = await timed(timeoutMs, () =>
+				startTLS(socket, host),
+			);
+			result.tls = tls;
+			if (!tlsSocket) {
+				return result;
+			}
+			const issuer = tlsSocket.getPeerCertificate().issuer;
+			result.tls.protocol = tlsSocket.getProtocol() ?? undefined;
+			result.tls.issuer = issuer?.O || issuer?.CN;
+			socket = tlsSocket;
+		}
+
+		// Plain HTTP through the proxy sends the absolute URL
+		const path = proxy && !https ? url.toString() : url.pathname + url.search;
+		const [http, response] = await timed(timeoutMs, async () => {
+			const response = await exchange(
+				socket,
+				`GET ${path} HTTP/1.1\r\nHost: ${url.host}\r\nUser-Agent: discobot-network-test\r\nConnection: close\r\n\r\n`,
+				PROXY_BLOCKED_BODY.length,
+			);
+			if (response === null) {
+				throw new Error("Connection closed without a response");
+			}
+			return response;
+		});
+		const status = response ? statusOf(response) : undefined;
+		result.http = { ...http, status };
+
+		if (proxy && !https && response) {
+			result.proxy.allowed = !(
+				status === 403 && response.includes(PROXY_BLOCKED_BODY)
+			);
+		}
+		result.reachable = status !== undefined && result.proxy.allowed !== false;
+		return result;
+	} finally {
+		socket.destroy();
+		tcpSocket.destroy();
+	}
+}
+
+/**
+ * Runs a step with a timeout, returning its outcome and value if it succeeded.
+ */
+async function timed<T>(
+	timeoutMs: number,
+	fn: () => Promise<T>,
+): Promise<[NetworkTestStep, T | undefined]> {
+	const start = Date.now();
+	let timer: ReturnType<typeof setTimeout> | undefined;
+	try {
+		const value = await Promise.race([
+			fn(),
+			new Promise<never>((_, reject) => {
+				timer = setTimeout(
+					() => reject(new Error(`Timed out after ${timeoutMs}ms`)),
+					timeoutMs,
+				);
+			}),
+		]);
+		return [{ ok: true, durationMs: Date.now() - start }, value];
+	} catch (err) {
+		const error = err instanceof Error ? err.message : String(err);
+		return [{ ok: false, durationMs: Date.now() - start, error }, undefined];
+	} finally {
+		clearTimeout(timer);
+	}
+}
+
+function openSocket(host: string, port: number): Promise<Socket> {
+	return new Promise((resolve, reject) => {
+		const socket = netConnect({ host, port });
+		socket.once("connect", () => {
+			socket.removeListener("error", reject);
+			// Later errors surface through exchange(); don't crash on them
+			socket.on("error", () => {});
+			resolve(socket);
+		});
+		socket.once("error", reject);
+	});
+}
+
+function startTLS(socket: Socket, servername: string): Promise<TLSSocket> {
+	return new Promise((resolve, reject) => {
+		const tlsSocket = tlsConnect({ socket, servername });
+		tlsSocket.once("secureConnect", () => {
+			tlsSocket.removeListener("error", reject);
+			tlsSocket.on("error", () => {});
+			resolve(tlsSocket);
+		});
+		tlsSocket.once("error", reject);
+	});
+}
+
+/**
+ * Writes a request and reads the response head (up to the blank line). For
+ * 403 responses, also waits for up to bodyBytesFor403 bytes of the body so
+ * the proxy's block message can be recognized. Returns null if the peer
+ * closes the connection without responding.
+ */
+function exchange(
+	socket: Socket,
+	request: string,
+	bodyBytesFor403 = 0,
+): Promise<string | null> {
+	return new Promise((resolve, reject) => {
+		let received = "";
+		const cleanup = () => {
+			socket.removeListener("data", onData);
+			socket.removeListener("end", onEnd);
+			socket.removeListener("close", onEnd);
+			socket.removeListener("error", onError);
+		};
+		const onData = (chunk: Buffer) => {
+			received += chunk.toString("latin1");
+			const end = received.indexOf("\r\n\r\n");
+			if (end === -1) {
+				return;
+			}
+			const bodyLength = received.length - end - 4;
+			if (statusOf(received) !== 403 || bodyLength >= bodyBytesFor403) {
+				cleanup();
+				resolve(received);
+			}
+		};
+		const onEnd = () => {
+			cleanup();
+			resolve(received === "" ? null : received);
+		};
+		const onError = (err: Error) => {
+			cleanup();
+			reject(err);
+		};
+		socket.on("data", onData);
+		socket.once("end", onEnd);
+		socket.once("close", onEnd);
+		socket.once("error", onError);
+		socket.write(request);
+	});
+}
+
+function statusOf(head: string): number | undefined {
+	const match = /^HTTP\/\d(?:\.\d)? (\d{3})/.exec(head);
+	return match ? Number(match[1]) : undefined;
+}
+
+function stripBrackets(host: string): string {
+	return host.startsWith("[") && host.endsWith("]") ? host.slice(1, -1) : host;
+}
@@ -0,0 +1,50 @@
+import assert from "node:assert/strict";
+import { createServer, type Server } from "node:http";
+import type { AddressInfo } from "node:net";
+import { after, before, describe, it } from "node:test";
+import { getProxyStats } from "./proxy-stats.js";
+
+function listen(server: Server): Promise<number> {
+	return new Promise((resolve) => {
+		server.listen(0, "127.0.0.1", () => {
+			resolve((server.address() as AddressInfo).port);
+		});
+	});
+}
+
+describe("getProxyStats", () => {
+	let server: Server;
+	let baseUrl: string;
+
+	before(async () => {
+		server = createServer((req, res) => {
+			if (req.url !== "/stats") {
+				res.writeHead(404).end();
+				return;
+			}
+			res.writeHead(200, { "Content-Type": "application/json" });
+			res.end(
+				JSON.stringify({
+					since: "2026-01-01T00:00:00Z",
+					totals: { requests: 1 },
+					domains: { "example.com": { requests: 1 } },
+				}),
+			);
+		});
+		baseUrl = `http://127.0.0.1:${await listen(server)}`;
+	});
+
+	after(() => {
+		server.close();
+	});
+
+	it("returns the proxy's stats", async () => {
+		const stats = await getProxyStats(baseUrl);
+		assert.equal(stats?.totals.requests, 1);
+		assert.equal(stats?.domains["example.com"]?.requests, 1);
+	});
+
+	it("returns null when the proxy is unreachable", async () => {
+		assert.equal(await getProxyStats("http://127.0.0.1:1"), null);
+	});
+});
@@ -0,0 +1,33 @@
+/**
+ * Proxy Traffic Statistics
+ *
+ * Relays per-domain traffic counters from the MITM proxy's API, which only
+ * listens inside the sandbox, so the server can audit what the agent accessed.
+ */
+
+import type { ProxyStatsResponse } from "../api/types.js";
+
+// The proxy API port the agent starts the proxy with
+export const PROXY_API_URL = "http://localhost:17081";
+
+const PROXY_STATS_TIMEOUT_MS = 5_000;
+
+/**
+ * Fetches traffic statistics from the proxy. Returns null if the proxy isn't
+ * running or doesn't answer.
+ */
+export async function getProxyStats(
+	baseUrl: string = PROXY_API_URL,
+): Promise<ProxyStatsResponse | null> {
+	try {
+		const res = await fetch(`${baseUrl}/stats`, {
+			signal: AbortSignal.timeout(PROXY_STATS_TIMEOUT_MS),
+		});
+		if (!res.ok) {
+			return null;
+		}
+		return (await res.json()) as ProxyStatsResponse;
+	} catch {
+		return null;
+	}
+}
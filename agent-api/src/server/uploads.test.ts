@@ -0,0 +1,149 @@
+import assert from "node:assert/strict";
+import { mkdir, readdir, readFile, rm } from "node:fs/promises";
+import { join } from "node:path";
+import { after, before, describe, it } from "node:test";
+import type { UploadStatus } from "../api/types.js";
+import {
+	abortUpload,
+	appendUpload,
+	createUpload,
+	getUpload,
+	isUploadError,
+	MAX_UPLOAD_SIZE,
+	type UploadResult,
+} from "./uploads.js";
+
+function status(result: UploadResult<UploadStatus>): UploadStatus {
+	if (isUploadError(result)) {
+		assert.fail(`unexpected upload error: ${result.error}`);
+	}
+	return result;
+}
+
+describe("resumable uploads", () => {
+	const testRoot = "/tmp/agent-api-uploads-test";
+	const workspace = join(testRoot, "workspace");
+	const uploadsDir = join(testRoot, "uploads");
+
+	before(async () => {
+		await rm(testRoot, { recursive: true, force: true });
+		await mkdir(workspace, { recursive: true });
+	});
+
+	after(async () => {
+		await rm(testRoot, { recursive: true, force: true });
+	});
+
+	it("assembles chunks into the destination file", async () => {
+		const created = status(
+			await createUpload("data/big.bin", 10, workspace, uploadsDir),
+		);
+		assert.equal(created.offset, 0);
+		assert.equal(created.complete, false);
+
+		const id = created.uploadId;
+		const first = status(
+			await appendUpload(id, 0, Buffer.from("hello"), workspace, uploadsDir),
+		);
+		assert.equal(first.offset, 5);
+		assert.equal(first.complete, false);
+
+		// Nothing is visible at the destination until the last chunk
+		await assert.rejects(readFile(join(workspace, "data/big.bin")));
+
+		const last = status(
+			await appendUpload(id, 5, Buffer.from("world"), workspace, uploadsDir),
+		);
+		assert.equal(last.complete, true);
+		assert.equal(
+			await readFile(join(workspace, "data/big.bin"), "utf8"),
+			"helloworld",
+		);
+		assert.deepEqual(await readdir(uploadsDir), []);
+		assert.deepEqual(await readdir(join(workspace, "data")), ["big.bin"]);
+	});
+
+	it("rejects chunks at the wrong offset with the current offset", async () => {
+		const { uploadId } = status(
+			await createUpload("resume.txt", 6, workspace, uploadsDir),
+		);
+		await appendUpload(uploadId, 0, Buffer.from("abc"), workspace, uploadsDir);
+
+		const result = await appendUpload(
+			uploadId,
+			0,
+			Buffer.from("abc"),
+			workspace,
+			uploadsDir,
+		);
+		assert.ok(isUploadError(result));
+		assert.equal(result.status, 409);
+		assert.equal(result.offset, 3);
+
+		// A client resumes from the offset reported by getUpload
+		assert.equal(status(await getUpload(uploadId, uploadsDir)).offset, 3);
+		const done = status(
+			await appendUpload(
+				uploadId,
+				3,
+				Buffer.from("def"),
+				workspace,
+				uploadsDir,
+			),
+		);
+		assert.equal(done.complete, true);
+	});
+
+	it("rejects chunks past the declared size", async () => {
+		const { uploadId } = status(
+			await createUpload("small.txt", 2, workspace, uploadsDir),
+		);
+		const result = await appendUpload(
+			uploadId,
+			0,
+			Buffer.from("abc"),
+			workspace,
+			uploadsDir,
+		);
+		assert.ok(isUploadError(result));
+		assert.equal(result.status, 400);
+	});
+
+	it("writes empty files immediately", async () => {
+		const result = status(
+			await createUpload("empty.txt", 0, workspace, uploadsDir),
+		);
+		assert.equal(result.complete, true);
+		assert.equal(await readFile(join(workspace, "empty.txt"), "utf8"), "");
+	});
+
+	it("aborts uploads", async () => {
+		const { uploadId } = status(
+			await createUpload("aborted.txt", 4, workspace, uploadsDir),
+		);
+		assert.deepEqual(await abortUpload(uploadId, uploadsDir), { uploadId });
+
+		const result = await getUpload(uploadId, uploadsDir);
+		assert.ok(isUploadError(result));
+		assert.equal(result.status, 404);
+	});
+
+	it("validates paths, sizes and upload IDs", async () => {
+		const escape = await createUpload("../x", 1, workspace, uploadsDir);
+		assert.ok(isUploadError(escape));
+		assert.equal(escape.status, 400);
+
+		const tooBig = await createUpload(
+			"huge.bin",
+			MAX_UPLOAD_SIZE + 1,
+			workspace,
+			uploadsDir,
+		);
+		assert.ok(isUploadError(tooBig));
+		assert.equal(tooBig.status, 413);
+
+		const badId = await getUpload("../../etc/passwd", uploadsDir);
+		assert.ok(isUploadError(badId));
+		assert.equal(badId.status, 400);
+	});
+});
@@ -0,0 +1,311 @@
+/**
+ * Resumable File Uploads
+ *
+ * Large files are uploaded as chunks appended at an explicit offset, so a
+ * client whose connection drops can ask for the current offset and resume
+ * instead of starting over. Partial data is kept in DISCOBOT_UPLOADS_DIR,
+ * outside the workspace and home overlay, so it survives restarts without
+ * showing up in git. When the last byte arrives the file is copied next to
+ * its destination and renamed into place, so readers never see a partial file.
+ */
+
+import { randomBytes } from "node:crypto";
+import {
+	appendFile,
+	copyFile,
+	readFile as fsReadFile,
+	writeFile as fsWriteFile,
+	mkdir,
+	readdir,
+	rename,
+	rm,
+	stat,
+} from "node:fs/promises";
+import { homedir } from "node:os";
+import { basename, dirname, join, relative } from "node:path";
+import type { AbortUploadResponse, UploadStatus } from "../api/types.js";
+import { validatePath } from "./files.js";
+
+// Maximum total size of an upload (10GB)
+export const MAX_UPLOAD_SIZE = 10 * 1024 * 1024 * 1024;
+
+// Maximum size of a single chunk (16MB)
+export const MAX_UPLOAD_CHUNK_SIZE = 16 * 1024 * 1024;
+
+// Partial uploads untouched this long are removed when a new upload starts
+export const UPLOAD_MAX_AGE_MS = 24 * 60 * 60 * 1000;
+
+// Upload IDs are used as file names, so only accept what createUpload makes
+const UPLOAD_ID_PATTERN = /^[a-f0-9]{32}$/;
+
+/**
+ * Returns the uploads directory: DISCOBOT_UPLOADS_DIR (set by the sandbox
+ * init to a directory under /.data), or ~/.cache/discobot/uploads when
+ * running locally.
+ */
+export function getUploadsDir(): string {
+	return (
+		process.env.DISCOBOT_UPLOADS_DIR ||
+		join(homedir(), ".cache", "discobot", "uploads")
+	);
+}
+
+/** HTTP status codes used for upload errors */
+export type UploadErrorStatus = 400 | 403 | 404 | 409 | 413;
+
+export interface UploadError {
+	error: string;
+	status: UploadErrorStatus;
+	offset?: number; // Current offset, for 409 responses
+}
+
+export type UploadResult<T> = T | UploadError;
+
+export function isUploadError(
+	result: UploadResult<unknown>,
+): result is UploadError {
+	return (
+		typeof result === "object" &&
+		result !== null &&
+		"error" in result &&
+		"status" in result
+	);
+}
+
+/** Upload metadata stored next to the partial data */
+interface UploadMeta {
+	uploadId: string;
+	path: string; // Destination, relative to the workspace root
+	size: number;
+}
+
+// Operations on one upload are serialized so concurrent chunks can't both
+// append at the same offset
+const queues = new Map<string, Promise<unknown>>();
+
+function serialize<T>(uploadId: string, fn: () => Promise<T>): Promise<T> {
+	const result = (queues.get(uploadId) ?? Promise.resolve()).then(fn);
+	const tail = result.catch(() => {});
+	queues.set(uploadId, tail);
+	tail.then(() => {
+		if (queues.get(uploadId) === tail) {
+			queues.delete(uploadId);
+		}
+	});
+	return result;
+}
+
+function metaPath(dir: string, uploadId: string): string {
+	return join(dir, `${uploadId}.json`);
+}
+
+function partPath(dir: string, uploadId: string): string {
+	return join(dir, `${uploadId}.part`);
+}
+
+async function readMeta(
+	dir: string,
+	uploadId: string,
+): Promise<UploadMeta | null> {
+	try {
+		return JSON.parse(
+			await fsReadFile(metaPath(dir, uploadId), "utf8"),
+		) as UploadMeta;
+	} catch (err) {
+		if ((err as NodeJS.ErrnoException).code === "ENOENT") {
+			return null;
+		}
+		throw err;
+	}
+}
+
+async function removeUpload(dir: string, uploadId: string): Promise<void> {
+	await rm(partPath(dir, uploadId), { force: true });
+	await rm(metaPath(dir, uploadId), { force: true });
+}
+
+/**
+ * Starts an upload of size bytes to path (relative to the workspace root).
+ * An empty file is written immediately.
+ */
+export async function createUpload(
+	path: string,
+	size: number,
+	workspaceRoot: string,
+	dir: string = getUploadsDir(),
+): Promise<UploadResult<UploadStatus>> {
+	const resolved = validatePath(path, workspaceRoot);
+	if (!resolved || resolved === workspaceRoot) {
+		return { error: "Invalid path", status: 400 };
+	}
+	if (!Number.isSafeInteger(size) || size < 0) {
+		return { error: "size must be a non-negative integer", status: 400 };
+	}
+	if (size > MAX_UPLOAD_SIZE) {
+		return { error: `Upload exceeds ${MAX_UPLOAD_SIZE} bytes`, status: 413 };
+	}
+
+	await mkdir(dir, { recursive: true });
+	await removeStaleUploads(dir);
+
+	const meta: UploadMeta = {
+		uploadId: randomBytes(16).toString("hex"),
+		path: relative(workspaceRoot, resolved),
+		size,
+	};
+	await fsWriteFile(partPath(dir, meta.uploadId), "");
+	await fsWriteFile(metaPath(dir, meta.uploadId), JSON.stringify(meta));
+
+	if (size === 0) {
+		return serialize(meta.uploadId, () =>
+			finishUpload(meta, workspaceRoot, dir),
+		);
+	}
+	return { ...meta, offset: 0, complete: false };
+}
+
+/**
+ * Returns an upload's progress, so a client can resume from its offset.
+ */
+export async function getUpload(
+	uploadId: string,
+	dir: string = getUploadsDir(),
+): Promise<UploadResult<UploadStatus>> {
+	if (!UPLOAD_ID_PATTERN.test(uploadId)) {
+		return { error: "Invalid upload ID", status: 400 };
+	}
+	const meta = await readMeta(dir, uploadId);
+	if (!meta) {
+		return { error: "Upload not found", status: 404 };
+	}
+	const offset = (await stat(partPath(dir, uploadId))).size;
+	return { ...meta, offset, complete: false };
+}
+
+/**
+ * Appends a chunk that starts at offset. A chunk at any other offset is
+ * rejected with 409 and the current offset. Once the declared size has been
+ * received, the file is moved into place and the upload is complete.
+ */
+export function appendUpload(
+	uploadId: string,
+	offset: number,
+	data: Buffer,
+	workspaceRoot: string,
+	dir: string = getUploadsDir(),
+): Promise<UploadResult<UploadStatus>> {
+	if (!UPLOAD_ID_PATTERN.test(uploadId)) {
+		return Promise.resolve({ error: "Invalid upload ID", status: 400 });
+	}
+	if (data.length > MAX_UPLOAD_CHUNK_SIZE) {
+		return Promise.resolve({
+			error: `Chunk exceeds ${MAX_UPLOAD_CHUNK_SIZE} bytes`,
+			status: 413,
+		});
+	}
+
+	return serialize(uploadId, async (): Promise<UploadResult<UploadStatus>> => {
+		const meta = await readMeta(dir, uploadId);
+		if (!meta) {
+			return { error: "Upload not found", status: 404 };
+		}
+
+		const current = (await stat(partPath(dir, uploadId))).size;
+		if (offset !== current) {
+			return {
+				error: `Chunk must start at offset ${current}`,
+				status: 409,
+				offset: current,
+			};
+		}
+		if (current + data.length > meta.size) {
+			return { error: "Chunk exceeds the upload size", status: 400 };
+		}
+
+		await appendFile(partPath(dir, uploadId), data);
+		const received = current + data.length;
+		if (received < meta.size) {
+			return { ...meta, offset: received, complete: false };
+		}
+		return finishUpload(meta, workspaceRoot, dir);
+	});
+}
+
+/**
+ * Cancels an upload and discards the data received so far.
+ */
+export function abortUpload(
+	uploadId: string,
+	dir: string = getUploadsDir(),
+): Promise<UploadResult<AbortUploadResponse>> {
+	if (!UPLOAD_ID_PATTERN.test(uploadId)) {
+		return Promise.resolve({ error: "Invalid upload ID", status: 400 });
+	}
+
+	return serialize(
+		uploadId,
+		async (): Promise<UploadResult<AbortUploadResponse>> => {
+			if (!(await readMeta(dir, uploadId))) {
+				return { error: "Upload not found", status: 404 };
+			}
+			await removeUpload(dir, uploadId);
+			return { uploadId };
+		},
+	);
+}
+
+/**
+ * Moves a fully received upload to its destination. The data is copied to a
+ * temp file in the destination directory first (the uploads directory is on
+ * a different filesystem) and then renamed over the destination.
+ */
+async function finishUpload(
+	meta: UploadMeta,
+	workspaceRoot: string,
+	dir: string,
+): Promise<UploadResult<UploadStatus>> {
+	const resolved = validatePath(meta.path, workspaceRoot);
+	if (!resolved) {
+		await removeUpload(dir, meta.uploadId);
+		return { error: "Invalid path", status: 400 };
+	}
+
+	const tempPath = join(
+		dirname(resolved),
+		`.${basename(resolved)}.upload-${meta.uploadId}`,
+	);
+	try {
+		await mkdir(dirname(resolved), { recursive: true });
+		await copyFile(partPath(dir, meta.uploadId), tempPath);
+		await rename(tempPath, resolved);
+	} catch (err) {
+		await rm(tempPath, { force: true });
+		if ((err as NodeJS.ErrnoException).code === "EACCES") {
+			return { error: "Permission denied", status: 403 };
+		}
+		throw err;
+	}
+
+	await removeUpload(dir, meta.uploadId);
+	return { ...meta, offset: meta.size, complete: true };
+}
+
+/**
+ * Removes partial uploads that haven't received data in UPLOAD_MAX_AGE_MS.
+ */
+async function removeStaleUploads(dir: string): Promise<void> {
+	const cutoff = Date.now() - UPLOAD_MAX_AGE_MS;
+	for (const name of await readdir(dir)) {
+		const uploadId = name.replace(/\.(json|part)$/, "");
+		if (!name.endsWith(".part") || !UPLOAD_ID_PATTERN.test(uploadId)) {
+			continue;
+		}
+		try {
+			if ((await stat(join(dir, name))).mtimeMs < cutoff) {
+				await serialize(uploadId, () => removeUpload(dir, uploadId));
+			}
+		} catch {
+			// Removed concurrently
+		}
+	}
+}
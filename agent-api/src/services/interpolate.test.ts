@@ -0,0 +1,60 @@
+/**
+ * Unit tests for front matter variable interpolation
+ */
+
+import assert from "node:assert";
+import { describe, it } from "node:test";
+import { InterpolationError, interpolate } from "./interpolate.js";
+
+const vars = {
+	SESSION_ID: "sess-123",
+	WORKSPACE_PATH: "/home/discobot/workspace",
+};
+
+describe("interpolate", () => {
+	it("returns values without references unchanged", () => {
+		assert.strictEqual(interpolate("My Service", vars), "My Service");
+	});
+
+	it("substitutes known variables", () => {
+		assert.strictEqual(
+			interpolate("/preview/${SESSION_ID}", vars),
+			"/preview/sess-123",
+		);
+		assert.strictEqual(
+			interpolate("${WORKSPACE_PATH}/${SESSION_ID}", vars),
+			"/home/discobot/workspace/sess-123",
+		);
+	});
+
+	it("escapes a reference with $$", () => {
+		assert.strictEqual(interpolate("$${SESSION_ID}", vars), "${SESSION_ID}");
+		assert.strictEqual(
+			interpolate("$${SESSION_ID}-${SESSION_ID}", vars),
+			"${SESSION_ID}-sess-123",
+		);
+	});
+
+	it("keeps every other $ literal", () => {
+		assert.strictEqual(interpolate("$HOME and $", vars), "$HOME and $");
+		assert.strictEqual(interpolate("costs $$5", vars), "costs $$5");
+		assert.strictEqual(interpolate("${SESSION_ID", vars), "${SESSION_ID");
+		assert.strictEqual(interpolate("${}", vars), "${}");
+		assert.strictEqual(interpolate("${A-B}", vars), "${A-B}");
+		assert.strictEqual(
+			interpolate("${A-B} ${SESSION_ID}", vars),
+			"${A-B} sess-123",
+		);
+	});
+
+	it("rejects undefined variables", () => {
+		assert.throws(() => interpolate("${HOME}", vars), InterpolationError);
+	});
+
+	it("does not read variables from the prototype", () => {
+		assert.throws(
+			() => interpolate("${constructor}", vars),
+			InterpolationError,
+		);
+	});
+});
@@ -0,0 +1,80 @@
+/**
+ * Front Matter Variable Interpolation
+ *
+ * Substitutes ${NAME} references in service front matter values with a
+ * fixed set of discobot-provided variables and the service's env keys
+ * defined on earlier lines. Interpolation is a plain string substitution:
+ * nothing is evaluated by a shell, and only the variables passed in are
+ * visible (the agent's own environment is not exposed).
+ *
+ * Only a well-formed reference, "${" followed by a variable name and "}",
+ * is special, and it must name a defined variable. Every other "$" is kept
+ * literally, as values were before interpolation existed: "$HOME", "$$5",
+ * "${" without a closing brace, and "${A-B}" are all left as written.
+ * "$${NAME}" escapes a reference and yields "${NAME}".
+ */
+
+/**
+ * Error thrown when a value references an undefined variable or contains
+ * a malformed reference.
+ */
+export class InterpolationError extends Error {
+	constructor(message: string) {
+		super(message);
+		this.name = "InterpolationError";
+	}
+}
+
+const VARIABLE_NAME = /^[A-Za-z_][A-Za-z0-9_]*$/;
+
+/**
+ * Whether name can be referenced as ${name} and defined as an env key.
+ */
+export function isVariableName(name: string): boolean {
+	return VARIABLE_NAME.test(name);
+}
+
+/**
+ * Interpolate ${NAME} references in a value.
+ *
+ * @param value - The raw value from front matter
+ * @param vars - Variables available for substitution
+ * @returns The value with all references substituted
+ * @throws InterpolationError for references to undefined variables
+ */
+export function interpolate(
+	value: string,
+	vars: Readonly<Record<string, string>>,
+): string {
+	let out = "";
+	let i = 0;
+
+	while (i < value.length) {
+		const start = value.indexOf("${", i);
+		if (start === -1) {
+			break;
+		}
+		const end = value.indexOf("}", start + 2);
+		const name = end === -1 ? "" : value.slice(start + 2, end);
+		if (!VARIABLE_NAME.test(name)) {
+			// Not a reference, keep the "$" and look further on
+			out += value.slice(i, start + 1);
+			i = start + 1;
+			continue;
+		}
+
+		if (start > i && value[start - 1] === "$") {
+			// Escaped: "$${NAME}" yields "${NAME}"
+			out += value.slice(i, start - 1) + value.slice(start, end + 1);
+			i = end + 1;
+			continue;
+		}
+		if (!Object.hasOwn(vars, name)) {
+			throw new InterpolationError(`undefined variable "${name}"`);
+		}
+		out += value.slice(i, start) + vars[name];
+		i = end + 1;
+	}
+
+	return out + value.slice(i);
+}
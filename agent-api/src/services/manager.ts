@@ -55,6 +55,16 @@ const STOPPED_SERVICE_GRACE_PERIOD = 30000;
  */
 const SERVICES_DIR = ".discobot/services";
 
+/**
+ * Variables available to ${NAME} references in service front matter.
+ */
+function serviceVariables(workspaceRoot: string): Record<string, string> {
+	return {
+		SESSION_ID: process.env.SESSION_ID || "",
+		WORKSPACE_PATH: workspaceRoot,
+	};
+}
+
 // ============================================================================
 // Result Types
 // ============================================================================
@@ -78,7 +88,10 @@ export type StopServiceResult =
  */
 export async function getServices(workspaceRoot: string): Promise<Service[]> {
 	const servicesDir = join(workspaceRoot, SERVICES_DIR);
-	const discoveredServices = await discoverServices(servicesDir);
+	const discoveredServices = await discoverServices(
+		servicesDir,
+		serviceVariables(workspaceRoot),
+	);
 
 	// Merge with runtime state
 	return discoveredServices.map((service) => {
@@ -141,7 +154,10 @@ export async function startService(
 
 	// Discover the service
 	const servicesDir = join(workspaceRoot, SERVICES_DIR);
-	const services = await discoverServices(servicesDir);
+	const services = await discoverServices(
+		servicesDir,
+		serviceVariables(workspaceRoot),
+	);
 	const serviceTemplate = services.find((s) => s.id === serviceId);
 
 	if (!serviceTemplate) {
@@ -260,7 +276,11 @@ function spawnService(workspaceRoot: string, serviceTemplate: Service): void {
 	const proc = spawn(serviceTemplate.path, [], {
 		cwd: workspaceRoot,
 		stdio: ["pipe", "pipe", "pipe"],
-		env: { ...process.env },
+		env: {
+			...process.env,
+			...serviceVariables(workspaceRoot),
+			...serviceTemplate.env,
+		},
 		detached: true,
 	});
 
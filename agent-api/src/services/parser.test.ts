@@ -4,6 +4,7 @@
 
 import assert from "node:assert";
 import { describe, it } from "node:test";
+import { InterpolationError } from "./interpolate.js";
 import { normalizeServiceId, parseFrontMatter } from "./parser.js";
 
 describe("parseFrontMatter", () => {
@@ -441,6 +442,105 @@ http: 8080
 	});
 });
 
+describe("parseFrontMatter interpolation", () => {
+	const vars = { SESSION_ID: "sess-123", WORKSPACE_PATH: "/workspace" };
+
+	it("interpolates variables in values", () => {
+		const content = `#!/bin/bash
+---
+name: Preview \${SESSION_ID}
+path: /s/\${SESSION_ID}
+---
+echo "hello"`;
+
+		const result = parseFrontMatter(content, vars);
+		assert.strictEqual(result.config.name, "Preview sess-123");
+		assert.strictEqual(result.config.urlPath, "/s/sess-123");
+	});
+
+	it("interpolates before parsing ports", () => {
+		const content = `---
+http: \${PORT}
+---
+`;
+
+		const result = parseFrontMatter(content, { PORT: "5173" });
+		assert.strictEqual(result.config.http, 5173);
+	});
+
+	it("throws on undefined variables", () => {
+		const content = `#!/bin/bash
+---
+description: \${UNKNOWN}
+---
+echo "hello"`;
+
+		assert.throws(() => parseFrontMatter(content, vars), InterpolationError);
+	});
+
+	it("defines env keys that later values can reference", () => {
+		const content = `#!/bin/bash
+---
+env: APP_DIR=\${WORKSPACE_PATH}/app
+env: PORT=5173
+env: APP_URL=http://localhost:\${PORT}
+http: \${PORT}
+description: Serves \${APP_DIR}
+---
+npm run dev`;
+
+		const result = parseFrontMatter(content, vars);
+		assert.deepStrictEqual(result.config.env, {
+			APP_DIR: "/workspace/app",
+			PORT: "5173",
+			APP_URL: "http://localhost:5173",
+		});
+		assert.strictEqual(result.config.http, 5173);
+		assert.strictEqual(result.config.description, "Serves /workspace/app");
+	});
+
+	it("only sees env keys defined on earlier lines", () => {
+		const content = `#!/bin/bash
+---
+env: A=\${B}
+env: B=b
+---
+echo "hello"`;
+
+		assert.throws(() => parseFrontMatter(content, vars), InterpolationError);
+	});
+
+	it("rejects invalid env entries and discobot variables", () => {
+		for (const entry of ["NO_EQUALS", "A-B=x", "=x", "SESSION_ID=x"]) {
+			const content = `#!/bin/bash
+---
+env: ${entry}
+---
+echo "hello"`;
+
+			assert.throws(
+				() => parseFrontMatter(content, vars),
+				InterpolationError,
+				entry,
+			);
+		}
+	});
+
+	it("keeps values with other uses of $ literal", () => {
+		const content = `#!/bin/bash
+---
+description: costs $$5, see $HOME or \${not-a-name}
+---
+echo "hello"`;
+
+		const result = parseFrontMatter(content, vars);
+		assert.strictEqual(
+			result.config.description,
+			"costs $$5, see $HOME or \${not-a-name}",
+		);
+	});
+});
+
 describe("normalizeServiceId", () => {
 	it("removes common script extensions", () => {
 		assert.strictEqual(normalizeServiceId("dev.sh"), "dev");
@@ -9,11 +9,19 @@
  *
  * Whitespace after comment prefix is allowed, but must be consistent
  * (subsequent lines must have <= whitespace than the first content line).
+ *
+ * Values may reference discobot-provided variables and earlier env keys as
+ * ${NAME}; see interpolate.ts for the escaping rules.
  */
 
 import { readdir, readFile, stat } from "node:fs/promises";
 import { join } from "node:path";
 import type { Service, ServiceConfig } from "../api/types.js";
+import {
+	InterpolationError,
+	interpolate,
+	isVariableName,
+} from "./interpolate.js";
 
 /**
  * Result of parsing front matter from a service file
@@ -82,10 +90,17 @@ function stripPrefixAndWhitespace(line: string, prefix: string): string {
 
 /**
  * Parse simple YAML key-value pairs
- * Only supports flat structure with string and number values
+ * Only supports flat structure with string and number values.
+ * Values are interpolated before they are converted, so ports may also
+ * come from variables. Each "env: NAME=value" line defines an environment
+ * variable for the service, which later lines can reference.
  */
-function parseSimpleYaml(content: string): ServiceConfig {
+function parseSimpleYaml(
+	content: string,
+	vars: Readonly<Record<string, string>>,
+): ServiceConfig {
 	const config: ServiceConfig = {};
+	const scope: Record<string, string> = { ...vars };
 
 	for (const line of content.split("\n")) {
 		const trimmed = line.trim();
@@ -111,6 +126,23 @@ function parseSimpleYaml(content: string): ServiceConfig {
 			value = value.slice(1, -1);
 		}
 
+		if (key === "env") {
+			const eqIndex = value.indexOf("=");
+			const name = eqIndex === -1 ? "" : value.slice(0, eqIndex).trim();
+			if (!isVariableName(name)) {
+				throw new InterpolationError(`invalid env entry "${value}"`);
+			}
+			if (Object.hasOwn(vars, name)) {
+				throw new InterpolationError(`env ${name} is set by discobot`);
+			}
+			const envValue = interpolate(value.slice(eqIndex + 1).trim(), scope);
+			config.env = { ...config.env, [name]: envValue };
+			scope[name] = envValue;
+			continue;
+		}
+
+		value = interpolate(value, scope);
+
 		switch (key) {
 			case "name":
 				config.name = value;
@@ -162,9 +194,15 @@ function isBodyEmpty(lines: string[], bodyStart: number): boolean {
  * 2. Passive services: Start directly with front matter delimiter (no shebang)
  *
  * @param content - The full content of the service file
+ * @param vars - Variables available to ${NAME} references in values, along
+ *   with env keys defined on earlier lines
  * @returns ParseResult with config, body info, and flags
+ * @throws InterpolationError if a value references an undefined variable
  */
-export function parseFrontMatter(content: string): ParseResult {
+export function parseFrontMatter(
+	content: string,
+	vars: Readonly<Record<string, string>> = {},
+): ParseResult {
 	const lines = content.split("\n");
 
 	if (lines.length === 0) {
@@ -253,7 +291,7 @@ export function parseFrontMatter(content: string): ParseResult {
 
 	// Parse the YAML content
 	const yamlContent = yamlLines.join("\n");
-	const config = parseSimpleYaml(yamlContent);
+	const config = parseSimpleYaml(yamlContent, vars);
 	const bodyStart = closingLineIndex + 1;
 
 	return {
@@ -336,11 +374,15 @@ export function normalizeServiceId(filename: string): string {
  * - The file has front matter with http or https port defined
  * - The body after front matter is empty (whitespace only)
  *
+ * Files whose front matter fails interpolation are skipped with a warning.
+ *
  * @param servicesDir - Path to .discobot/services directory
+ * @param vars - Variables available to ${NAME} references in front matter
  * @returns Array of Service objects (with status: "stopped")
  */
 export async function discoverServices(
 	servicesDir: string,
+	vars: Readonly<Record<string, string>> = {},
 ): Promise<Service[]> {
 	const services: Service[] = [];
 
@@ -362,7 +404,7 @@ export async function discoverServices(
 
 				// Read and parse the file
 				const content = await readFile(filePath, "utf-8");
-				const result = parseFrontMatter(content);
+				const result = parseFrontMatter(content, vars);
 
 				// Determine if this is a passive service:
 				// - Has http or https port defined
@@ -386,12 +428,17 @@ export async function discoverServices(
 					https: result.config.https,
 					path: filePath,
 					urlPath: result.config.urlPath,
+					env: result.config.env,
 					status: "stopped",
 					passive: isPassive || undefined,
 				};
 
 				services.push(service);
-			} catch {}
+			} catch (err) {
+				if (err instanceof InterpolationError) {
+					console.warn(`Skipping service ${entry.name}: ${err.message}`);
+				}
+			}
 		}
 	} catch {
 		// Directory doesn't exist or can't be read - return empty list
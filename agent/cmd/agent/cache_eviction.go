@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// cacheIndexFile records when each cache directory in the volume was last
+	// mounted by a sandbox, relative to the volume root.
+	cacheIndexFile = ".discobot-cache-index.json"
+
+	// cacheLockFile serializes index updates and eviction between the
+	// sandboxes sharing a project's cache volume.
+	cacheLockFile = ".discobot-cache.lock"
+
+	// cacheTouchInterval is how often a running sandbox refreshes the
+	// last-used time of the cache directories it has mounted.
+	cacheTouchInterval = 10 * time.Minute
+
+	// cacheEvictMinIdle protects directories that a running sandbox may
+	// still be using: anything refreshed more recently is never evicted.
+	cacheEvictMinIdle = 3 * cacheTouchInterval
+)
+
+// cachePolicy bounds the size of the shared cache volume.
+type cachePolicy struct {
+	maxBytes    int64 // Evict when the volume is larger than this
+	targetBytes int64 // Evict down to this size
+}
+
+// loadCachePolicy reads the eviction policy from CACHE_MAX_SIZE_MB and
+// CACHE_EVICT_TARGET_PERCENT (default 80). It returns false if the cache is
+// unbounded.
+func loadCachePolicy() (cachePolicy, bool) {
+	maxMB, err := strconv.ParseInt(os.Getenv("CACHE_MAX_SIZE_MB"), 10, 64)
+	if err != nil || maxMB <= 0 {
+		return cachePolicy{}, false
+	}
+	percent, err := strconv.ParseInt(os.Getenv("CACHE_EVICT_TARGET_PERCENT"), 10, 64)
+	if err != nil || percent < 1 || percent > 100 {
+		percent = 80
+	}
+	maxBytes := maxMB << 20
+	return cachePolicy{maxBytes: maxBytes, targetBytes: maxBytes * percent / 100}, true
+}
+
+// withCacheLock runs fn while holding an exclusive lock on the cache volume.
+func withCacheLock(cacheBase string, fn func() error) error {
+	f, err := os.OpenFile(filepath.Join(cacheBase, cacheLockFile), os.O_CREATE|os.O_RDWR, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer func() { _ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN) }()
+	return fn()
+}
+
+// readCacheIndex returns the last-used time of each cache directory. A
+// missing or corrupt index is treated as empty.
+func readCacheIndex(cacheBase string) map[string]time.Time {
+	index := map[string]time.Time{}
+	data, err := os.ReadFile(filepath.Join(cacheBase, cacheIndexFile))
+	if err != nil {
+		return index
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		fmt.Printf("discobot-agent: warning: ignoring corrupt cache index: %v\n", err)
+		return map[string]time.Time{}
+	}
+	return index
+}
+
+// writeCacheIndex replaces the index atomically.
+func writeCacheIndex(cacheBase string, index map[string]time.Time) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(cacheBase, cacheIndexFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// recordCacheUse marks cache directories (relative to the volume root) as
+// used at now.
+func recordCacheUse(cacheBase string, subDirs []string, now time.Time) error {
+	return withCacheLock(cacheBase, func() error {
+		index := readCacheIndex(cacheBase)
+		for _, subDir := range subDirs {
+			index[subDir] = now
+		}
+		return writeCacheIndex(cacheBase, index)
+	})
+}
+
+// touchCacheDirectories refreshes the last-used time of the mounted cache
+// directories for as long as the sandbox runs, so other sandboxes sharing
+// the volume don't evict them.
+func touchCacheDirectories(cacheBase string, subDirs []string) {
+	ticker := time.NewTicker(cacheTouchInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := recordCacheUse(cacheBase, subDirs, time.Now()); err != nil {
+			fmt.Printf("discobot-agent: warning: failed to record cache use: %v\n", err)
+		}
+	}
+}
+
+// evictCache removes the contents of the least recently used cache
+// directories until the volume is no larger than the policy's target. It
+// does nothing while the volume is within the max size. Directories used
+// within cacheEvictMinIdle are skipped, so the volume may stay above the
+// target if everything in it is in use.
+func evictCache(cacheBase string, policy cachePolicy, now time.Time) error {
+	return withCacheLock(cacheBase, func() error {
+		total, err := diskUsage(cacheBase)
+		if err != nil {
+			return err
+		}
+		if total <= policy.maxBytes {
+			return nil
+		}
+
+		index := readCacheIndex(cacheBase)
+		subDirs := make([]string, 0, len(index))
+		for subDir := range index {
+			subDirs = append(subDirs, subDir)
+		}
+		sort.Slice(subDirs, func(i, j int) bool {
+			return index[subDirs[i]].Before(index[subDirs[j]])
+		})
+
+		fmt.Printf("discobot-agent: cache volume is %d MB, over the %d MB limit; evicting down to %d MB\n",
+			total>>20, policy.maxBytes>>20, policy.targetBytes>>20)
+
+		for _, subDir := range subDirs {
+			if total <= policy.targetBytes {
+				break
+			}
+			lastUsed := index[subDir]
+			if now.Sub(lastUsed) < cacheEvictMinIdle {
+				break // Sorted oldest first, so everything after is in use too
+			}
+			dir, ok := cacheSubDirPath(cacheBase, subDir)
+			if !ok {
+				delete(index, subDir)
+				continue
+			}
+			size, err := diskUsage(dir)
+			if err != nil && !os.IsNotExist(err) {
+				fmt.Printf("discobot-agent: warning: failed to size cache dir %s: %v\n", dir, err)
+				continue
+			}
+			if err := removeDirContents(dir); err != nil {
+				fmt.Printf("discobot-agent: warning: failed to evict cache dir %s: %v\n", dir, err)
+				continue
+			}
+			delete(index, subDir)
+			total -= size
+			fmt.Printf("discobot-agent: evicted cache dir %s (%d MB, last used %s)\n",
+				subDir, size>>20, lastUsed.Format(time.RFC3339))
+		}
+
+		if total > policy.targetBytes {
+			fmt.Printf("discobot-agent: warning: cache volume is still %d MB; remaining directories are in use\n", total>>20)
+		}
+		return writeCacheIndex(cacheBase, index)
+	})
+}
+
+// cacheSubDirPath resolves an index entry to a directory inside the volume,
+// rejecting entries that would escape it.
+func cacheSubDirPath(cacheBase, subDir string) (string, bool) {
+	clean := filepath.Clean(subDir)
+	if clean == "." || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", false
+	}
+	return filepath.Join(cacheBase, clean), true
+}
+
+// diskUsage returns the disk space used by the files under root.
+func diskUsage(root string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path != root && os.IsNotExist(err) {
+				return nil // Removed while walking
+			}
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if st, ok := info.Sys().(*syscall.Stat_t); ok {
+			total += st.Blocks * 512
+		} else {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// removeDirContents removes everything in dir but keeps dir itself, since
+// it may be the source of a bind mount.
+func removeDirContents(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCacheFile creates a file of the given size under the cache volume.
+func writeCacheFile(t *testing.T, base, subDir string, size int) {
+	t.Helper()
+	dir := filepath.Join(base, subDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data"), make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadCachePolicy(t *testing.T) {
+	tests := []struct {
+		maxMB, percent string
+		wantOK         bool
+		wantTarget     int64
+	}{
+		{"", "", false, 0},
+		{"0", "80", false, 0},
+		{"bogus", "80", false, 0},
+		{"100", "50", true, 50 << 20},
+		{"100", "", true, 80 << 20},
+		{"100", "150", true, 80 << 20},
+	}
+	for _, tt := range tests {
+		t.Setenv("CACHE_MAX_SIZE_MB", tt.maxMB)
+		t.Setenv("CACHE_EVICT_TARGET_PERCENT", tt.percent)
+		policy, ok := loadCachePolicy()
+		if ok != tt.wantOK || policy.targetBytes != tt.wantTarget {
+			t.Errorf("max=%q percent=%q: got %+v, %v; want target %d, %v", tt.maxMB, tt.percent, policy, ok, tt.wantTarget, tt.wantOK)
+		}
+	}
+}
+
+func TestEvictCache_LeastRecentlyUsedFirst(t *testing.T) {
+	base := t.TempDir()
+	now := time.Now()
+
+	writeCacheFile(t, base, "home/discobot/.npm", 400<<10)
+	writeCacheFile(t, base, "home/discobot/.cache", 400<<10)
+	writeCacheFile(t, base, "home/discobot/go/pkg/mod", 400<<10)
+
+	if err := recordCacheUse(base, []string{"home/discobot/.npm"}, now.Add(-3*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordCacheUse(base, []string{"home/discobot/.cache"}, now.Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordCacheUse(base, []string{"home/discobot/go/pkg/mod"}, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// ~1.2 MB used; evicting the oldest directory gets under 1 MB
+	policy := cachePolicy{maxBytes: 1 << 20, targetBytes: 1 << 20}
+	if err := evictCache(base, policy, now); err != nil {
+		t.Fatalf("evictCache failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(base, "home/discobot/.npm/data")); !os.IsNotExist(err) {
+		t.Error("Expected least recently used directory to be evicted")
+	}
+	if _, err := os.Stat(filepath.Join(base, "home/discobot/.npm")); err != nil {
+		t.Errorf("Expected evicted directory itself to be kept: %v", err)
+	}
+	for _, kept := range []string{"home/discobot/.cache/data", "home/discobot/go/pkg/mod/data"} {
+		if _, err := os.Stat(filepath.Join(base, kept)); err != nil {
+			t.Errorf("Expected %s to be kept: %v", kept, err)
+		}
+	}
+
+	index := readCacheIndex(base)
+	if _, ok := index["home/discobot/.npm"]; ok {
+		t.Error("Expected evicted directory to be removed from the index")
+	}
+	if len(index) != 2 {
+		t.Errorf("Expected 2 index entries, got %v", index)
+	}
+}
+
+func TestEvictCache_UnderLimit(t *testing.T) {
+	base := t.TempDir()
+	writeCacheFile(t, base, "home/discobot/.npm", 100<<10)
+	if err := recordCacheUse(base, []string{"home/discobot/.npm"}, time.Now().Add(-24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := evictCache(base, cachePolicy{maxBytes: 1 << 20, targetBytes: 0}, time.Now()); err != nil {
+		t.Fatalf("evictCache failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "home/discobot/.npm/data")); err != nil {
+		t.Errorf("Expected nothing to be evicted under the limit: %v", err)
+	}
+}
+
+func TestEvictCache_SkipsRecentlyUsed(t *testing.T) {
+	base := t.TempDir()
+	now := time.Now()
+	writeCacheFile(t, base, "home/discobot/.npm", 400<<10)
+	if err := recordCacheUse(base, []string{"home/discobot/.npm"}, now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := evictCache(base, cachePolicy{maxBytes: 0, targetBytes: 0}, now); err != nil {
+		t.Fatalf("evictCache failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "home/discobot/.npm/data")); err != nil {
+		t.Errorf("Expected directory in use to be kept: %v", err)
+	}
+}
+
+func TestCacheSubDirPath(t *testing.T) {
+	for _, subDir := range []string{"", ".", "..", "../etc", "/etc", "home/../../etc"} {
+		if _, ok := cacheSubDirPath("/.data/cache", subDir); ok {
+			t.Errorf("Expected %q to be rejected", subDir)
+		}
+	}
+	if path, ok := cacheSubDirPath("/.data/cache", "home/discobot/.npm"); !ok || path != "/.data/cache/home/discobot/.npm" {
+		t.Errorf("Unexpected path %q, %v", path, ok)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dockerBridgeInterface is the default bridge created by the nested Docker daemon.
+// Nested containers reach the sandbox (and its proxy) through this interface.
+const dockerBridgeInterface = "docker0"
+
+// configureNestedDocker writes the Docker client config for the sandbox user so
+// that nested `docker build` and `docker run` route through the filtering proxy.
+// The Docker CLI injects these settings as HTTP_PROXY/HTTPS_PROXY/NO_PROXY into
+// build steps and new containers. Must run after dockerd has created docker0.
+func configureNestedDocker(u *userInfo) error {
+	bridgeIP, err := interfaceIPv4(dockerBridgeInterface)
+	if err != nil {
+		return fmt.Errorf("failed to determine %s address: %w", dockerBridgeInterface, err)
+	}
+
+	// localhost inside a nested container is the container itself, so point
+	// at the sandbox's address on the bridge. The proxy listens on all interfaces.
+	proxyURL := fmt.Sprintf("http://%s:%d", bridgeIP, proxyPort)
+	noProxy := "localhost,127.0.0.1,::1"
+
+	dockerDir := filepath.Join(mountHome, ".docker")
+	configPath := filepath.Join(dockerDir, "config.json")
+
+	if err := os.MkdirAll(dockerDir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dockerDir, err)
+	}
+	if err := os.Chown(dockerDir, u.uid, u.gid); err != nil {
+		return fmt.Errorf("failed to chown %s: %w", dockerDir, err)
+	}
+
+	existing, err := os.ReadFile(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", configPath, err)
+	}
+
+	updated, err := mergeDockerProxyConfig(existing, proxyURL, noProxy)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", configPath, err)
+	}
+
+	if err := os.WriteFile(configPath, updated, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", configPath, err)
+	}
+	if err := os.Chown(configPath, u.uid, u.gid); err != nil {
+		return fmt.Errorf("failed to chown %s: %w", configPath, err)
+	}
+
+	fmt.Printf("discobot-agent: configured nested Docker client to use proxy at %s\n", proxyURL)
+	return nil
+}
+
+// mergeDockerProxyConfig sets the default proxies in a Docker client config,
+// preserving all other settings (auths, credential helpers, etc.).
+func mergeDockerProxyConfig(existing []byte, proxyURL, noProxy string) ([]byte, error) {
+	config := map[string]any{}
+	if len(strings.TrimSpace(string(existing))) > 0 {
+		if err := json.Unmarshal(existing, &config); err != nil {
+			return nil, fmt.Errorf("invalid docker config: %w", err)
+		}
+	}
+
+	proxies, _ := config["proxies"].(map[string]any)
+	if proxies == nil {
+		proxies = map[string]any{}
+	}
+	proxies["default"] = map[string]any{
+		"httpProxy":  proxyURL,
+		"httpsProxy": proxyURL,
+		"noProxy":    noProxy,
+	}
+	config["proxies"] = proxies
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// dockerDNSServers returns the DNS servers for nested containers from the
+// DOCKER_DNS environment variable (comma-separated), or nil to use Docker's default.
+func dockerDNSServers() []string {
+	var servers []string
+	for _, s := range strings.Split(os.Getenv("DOCKER_DNS"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			servers = append(servers, s)
+		}
+	}
+	return servers
+}
+
+// interfaceIPv4 returns the first IPv4 address of the named interface.
+func interfaceIPv4(name string) (string, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			if ip4 := ipNet.IP.To4(); ip4 != nil {
+				return ip4.String(), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no IPv4 address on %s", name)
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// dockerdSocketPath is where dockerd listens when the network mode is
+// restricted. Only root can reach it; the sandbox user talks to Docker
+// through the filter serving dockerSocketPath instead.
+const dockerdSocketPath = "/run/discobot/dockerd.sock"
+
+// maxDockerRequestBody caps the request bodies the Docker filter inspects.
+const maxDockerRequestBody = 4 << 20
+
+// dockerAPIVersion matches the optional /v1.xx prefix of Docker API paths.
+var dockerAPIVersion = regexp.MustCompile(`^/v[0-9.]+/`)
+
+// dockerExecCreate matches the path of exec creation requests.
+var dockerExecCreate = regexp.MustCompile(`^/containers/[^/]+/exec$`)
+
+// dockerContainerUpdate matches the path of container update requests, whose
+// resources are checked like those of new containers.
+var dockerContainerUpdate = regexp.MustCompile(`^/containers/[^/]+/update$`)
+
+// allowedDockerCaps are the capabilities nested containers may add: Docker's
+// defaults plus a few that don't reach outside the container's namespaces.
+var allowedDockerCaps = []string{
+	"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "IPC_LOCK",
+	"KILL", "MKNOD", "NET_BIND_SERVICE", "SETFCAP", "SETGID", "SETPCAP",
+	"SETUID", "SYS_CHROOT", "SYS_NICE", "SYS_PTRACE", "SYS_RESOURCE",
+}
+
+// dockerHostConfigChecks are the HostConfig fields that could let a nested
+// container escape its namespaces or reach the sandbox's files, with the
+// check each value must pass. HostConfig fields not listed here and not in
+// allowedDockerHostConfig may only be left at their zero value.
+var dockerHostConfigChecks = map[string]func(json.RawMessage) error{
+	"privileged":        requireZero("privileged containers are disabled"),
+	"networkmode":       checkDockerNetworkMode,
+	"pidmode":           requireZero("containers can't share another PID namespace"),
+	"ipcmode":           checkDockerIpcMode,
+	"utsmode":           requireZero("containers can't share the host UTS namespace"),
+	"usernsmode":        requireZero("containers can't change user namespace"),
+	"cgroupnsmode":      checkDockerCgroupnsMode,
+	"cgroup":            requireZero("containers can't share another cgroup"),
+	"cgroupparent":      requireZero("containers can't choose their parent cgroup"),
+	"runtime":           checkDockerRuntime,
+	"capadd":            checkDockerCapAdd,
+	"securityopt":       checkDockerSecurityOpt,
+	"devices":           requireZero("containers can't add host devices"),
+	"devicecgrouprules": requireZero("containers can't add device cgroup rules"),
+	"devicerequests":    requireZero("containers can't request host devices"),
+	"maskedpaths":       requireNull("containers can't change masked paths"),
+	"readonlypaths":     requireNull("containers can't change read-only paths"),
+	"volumedriver":      checkDockerVolumeDriver,
+	"binds":             checkDockerBinds,
+	"mounts":            checkDockerMounts,
+}
+
+// allowedDockerHostConfig are the HostConfig fields, lowercased, that nested
+// containers may set freely: resource limits, ports, DNS, logging and the
+// like, none of which reach outside the container.
+var allowedDockerHostConfig = []string{
+	"annotations", "autoremove", "blkiodevicereadbps", "blkiodevicereadiops",
+	"blkiodevicewritebps", "blkiodevicewriteiops", "blkioweight",
+	"blkioweightdevice", "capdrop", "consolesize", "containeridfile",
+	"cpucount", "cpupercent", "cpuperiod", "cpuquota", "cpurealtimeperiod",
+	"cpurealtimeruntime", "cpusetcpus", "cpusetmems", "cpushares", "dns",
+	"dnsoptions", "dnssearch", "extrahosts", "groupadd", "init",
+	"iomaximumbandwidth", "iomaximumiops", "isolation", "kernelmemory",
+	"kernelmemorytcp", "links", "logconfig", "memory", "memoryreservation",
+	"memoryswap", "memoryswappiness", "nanocpus", "oomkilldisable",
+	"oomscoreadj", "pidslimit", "portbindings", "publishallports",
+	"readonlyrootfs", "restartpolicy", "shmsize", "storageopt", "sysctls",
+	"tmpfs", "ulimits", "volumesfrom",
+}
+
+// serveDockerFilter serves the Docker API on listenPath, passing requests on
+// to the daemon at upstreamPath unless checkDockerRequest refuses them. The
+// egress firewall exempts root in proxied mode and only filters nested
+// containers' forwarded traffic, so a container sharing the sandbox's network
+// namespace or privileged enough to rewrite its firewall would bypass the
+// network mode.
+func serveDockerFilter(listenPath, upstreamPath string) error {
+	_ = os.Remove(listenPath)
+	listener, err := net.Listen("unix", listenPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", listenPath, err)
+	}
+	if err := os.Chmod(listenPath, 0666); err != nil {
+		_ = listener.Close()
+		return fmt.Errorf("failed to chmod %s: %w", listenPath, err)
+	}
+
+	go func() {
+		if err := http.Serve(listener, dockerFilter(upstreamPath)); err != nil {
+			fmt.Printf("discobot-agent: docker API filter stopped: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// dockerFilter returns a handler proxying allowed requests to the Docker
+// daemon at upstreamPath, including upgraded attach and exec connections.
+func dockerFilter(upstreamPath string) http.Handler {
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL.Scheme = "http"
+			r.Out.URL.Host = "docker"
+		},
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", upstreamPath)
+			},
+		},
+		// Stream logs, events and build output as they arrive
+		FlushInterval: -1,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := checkDockerRequest(r); err != nil {
+			fmt.Printf("discobot-agent: refused docker API request %s %s: %v\n", r.Method, r.URL.Path, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"message": fmt.Sprintf("%v (not allowed in this sandbox's network mode)", err),
+			})
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// checkDockerRequest returns an error if r would create a container, exec,
+// build, network or volume able to bypass the sandbox's network mode or reach
+// the sandbox's own files. Container HostConfigs are checked against an
+// allowlist (see checkDockerHostConfig); privileged execs, host-network
+// builds, non-bridge networks, volumes backed by driver options, and swarm
+// services and plugins are refused. Bodies it reads are restored for the
+// daemon.
+func checkDockerRequest(r *http.Request) error {
+	endpoint := dockerAPIVersion.ReplaceAllString(path.Clean(r.URL.Path), "/")
+
+	for _, prefix := range []string{"/plugins", "/swarm", "/services", "/nodes"} {
+		if endpoint == prefix || strings.HasPrefix(endpoint, prefix+"/") {
+			return fmt.Errorf("%s endpoints are disabled", strings.TrimPrefix(prefix, "/"))
+		}
+	}
+	if r.Method != http.MethodPost {
+		return nil
+	}
+
+	switch {
+	case endpoint == "/build":
+		switch strings.ToLower(r.URL.Query().Get("networkmode")) {
+		case "", "default", "bridge", "none":
+		default:
+			return fmt.Errorf("builds can only use the default network")
+		}
+	case endpoint == "/containers/create":
+		var body struct {
+			HostConfig map[string]json.RawMessage
+		}
+		if err := readDockerBody(r, &body); err != nil {
+			return err
+		}
+		return checkDockerHostConfig(body.HostConfig)
+	case dockerContainerUpdate.MatchString(endpoint):
+		// Updates carry the resource fields of a HostConfig
+		var body map[string]json.RawMessage
+		if err := readDockerBody(r, &body); err != nil {
+			return err
+		}
+		return checkDockerHostConfig(body)
+	case dockerExecCreate.MatchString(endpoint):
+		var body struct {
+			Privileged bool
+		}
+		if err := readDockerBody(r, &body); err != nil {
+			return err
+		}
+		if body.Privileged {
+			return fmt.Errorf("privileged exec is disabled")
+		}
+	case endpoint == "/networks/create":
+		var body struct {
+			Driver string
+		}
+		if err := readDockerBody(r, &body); err != nil {
+			return err
+		}
+		// Other drivers, like macvlan, attach containers to the sandbox's
+		// interfaces without going through its firewall
+		if body.Driver != "" && body.Driver != "bridge" {
+			return fmt.Errorf("networks can only use the bridge driver")
+		}
+	case endpoint == "/volumes/create":
+		var body struct {
+			Driver     string
+			DriverOpts map[string]string
+		}
+		if err := readDockerBody(r, &body); err != nil {
+			return err
+		}
+		// The local driver's options can bind-mount any sandbox path
+		if (body.Driver != "" && body.Driver != "local") || len(body.DriverOpts) > 0 {
+			return fmt.Errorf("volumes can't use driver options")
+		}
+	}
+	return nil
+}
+
+// checkDockerHostConfig returns an error unless every field of hostConfig is
+// either in allowedDockerHostConfig, passes its dockerHostConfigChecks check,
+// or is left at its zero value. Field names are matched case-insensitively,
+// as the daemon does.
+func checkDockerHostConfig(hostConfig map[string]json.RawMessage) error {
+	for key, value := range hostConfig {
+		name := strings.ToLower(key)
+		if check, ok := dockerHostConfigChecks[name]; ok {
+			if err := check(value); err != nil {
+				return err
+			}
+			continue
+		}
+		if slices.Contains(allowedDockerHostConfig, name) || isZeroJSON(value) {
+			continue
+		}
+		return fmt.Errorf("HostConfig.%s is not allowed", key)
+	}
+	return nil
+}
+
+// isZeroJSON reports whether value is null, false, 0, or an empty string,
+// array or object.
+func isZeroJSON(value json.RawMessage) bool {
+	switch string(bytes.TrimSpace(value)) {
+	case "", "null", "false", "0", `""`, "[]", "{}":
+		return true
+	}
+	return false
+}
+
+// requireZero returns a check refusing any value but the zero value with msg.
+func requireZero(msg string) func(json.RawMessage) error {
+	return func(value json.RawMessage) error {
+		if !isZeroJSON(value) {
+			return errors.New(msg)
+		}
+		return nil
+	}
+}
+
+// requireNull returns a check refusing any value but null with msg, for
+// fields where an empty value isn't the default.
+func requireNull(msg string) func(json.RawMessage) error {
+	return func(value json.RawMessage) error {
+		if string(bytes.TrimSpace(value)) != "null" {
+			return errors.New(msg)
+		}
+		return nil
+	}
+}
+
+// checkDockerNetworkMode allows the default, bridge, none and user-defined
+// networks, refusing the host network and other containers' namespaces.
+func checkDockerNetworkMode(value json.RawMessage) error {
+	var mode string
+	if err := json.Unmarshal(value, &mode); err != nil {
+		return fmt.Errorf("invalid HostConfig.NetworkMode: %w", err)
+	}
+	if mode == "host" || strings.HasPrefix(mode, "container:") {
+		return fmt.Errorf("containers can't use the %s network", mode)
+	}
+	return nil
+}
+
+// checkDockerIpcMode allows the container's own IPC namespace only.
+func checkDockerIpcMode(value json.RawMessage) error {
+	var mode string
+	if err := json.Unmarshal(value, &mode); err != nil {
+		return fmt.Errorf("invalid HostConfig.IpcMode: %w", err)
+	}
+	switch mode {
+	case "", "private", "shareable", "none":
+		return nil
+	}
+	return fmt.Errorf("containers can't share another IPC namespace")
+}
+
+// checkDockerCgroupnsMode allows the container's own cgroup namespace only.
+func checkDockerCgroupnsMode(value json.RawMessage) error {
+	var mode string
+	if err := json.Unmarshal(value, &mode); err != nil {
+		return fmt.Errorf("invalid HostConfig.CgroupnsMode: %w", err)
+	}
+	if mode != "" && mode != "private" {
+		return fmt.Errorf("containers can't share the host cgroup namespace")
+	}
+	return nil
+}
+
+// checkDockerRuntime allows the default runtime only.
+func checkDockerRuntime(value json.RawMessage) error {
+	var runtime string
+	if err := json.Unmarshal(value, &runtime); err != nil {
+		return fmt.Errorf("invalid HostConfig.Runtime: %w", err)
+	}
+	if runtime != "" && runtime != "runc" {
+		return fmt.Errorf("containers can't use the %s runtime", runtime)
+	}
+	return nil
+}
+
+// checkDockerCapAdd allows adding allowedDockerCaps only.
+func checkDockerCapAdd(value json.RawMessage) error {
+	var caps []string
+	if err := json.Unmarshal(value, &caps); err != nil {
+		return fmt.Errorf("invalid HostConfig.CapAdd: %w", err)
+	}
+	for _, capability := range caps {
+		name := strings.TrimPrefix(strings.ToUpper(capability), "CAP_")
+		if !slices.Contains(allowedDockerCaps, name) {
+			return fmt.Errorf("containers can't add the %s capability", name)
+		}
+	}
+	return nil
+}
+
+// checkDockerSecurityOpt allows no-new-privileges only, so seccomp, AppArmor
+// and SELinux confinement can't be lifted.
+func checkDockerSecurityOpt(value json.RawMessage) error {
+	var opts []string
+	if err := json.Unmarshal(value, &opts); err != nil {
+		return fmt.Errorf("invalid HostConfig.SecurityOpt: %w", err)
+	}
+	for _, opt := range opts {
+		switch opt {
+		case "no-new-privileges", "no-new-privileges:true", "no-new-privileges=true":
+		default:
+			return fmt.Errorf("containers can't set the %s security option", opt)
+		}
+	}
+	return nil
+}
+
+// checkDockerVolumeDriver allows the local volume driver only.
+func checkDockerVolumeDriver(value json.RawMessage) error {
+	var driver string
+	if err := json.Unmarshal(value, &driver); err != nil {
+		return fmt.Errorf("invalid HostConfig.VolumeDriver: %w", err)
+	}
+	if driver != "" && driver != "local" {
+		return fmt.Errorf("containers can't use the %s volume driver", driver)
+	}
+	return nil
+}
+
+// checkDockerBinds allows named volumes and binds of paths under mountHome.
+func checkDockerBinds(value json.RawMessage) error {
+	var binds []string
+	if err := json.Unmarshal(value, &binds); err != nil {
+		return fmt.Errorf("invalid HostConfig.Binds: %w", err)
+	}
+	for _, bind := range binds {
+		source, _, _ := strings.Cut(bind, ":")
+		if !strings.HasPrefix(source, "/") {
+			continue
+		}
+		if err := checkDockerBindSource(source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDockerMounts allows volume and tmpfs mounts without driver options,
+// and bind mounts of paths under mountHome.
+func checkDockerMounts(value json.RawMessage) error {
+	var mounts []struct {
+		Type          string
+		Source        string
+		VolumeOptions *struct {
+			DriverConfig *struct {
+				Name    string
+				Options map[string]string
+			}
+		}
+	}
+	if err := json.Unmarshal(value, &mounts); err != nil {
+		return fmt.Errorf("invalid HostConfig.Mounts: %w", err)
+	}
+	for _, m := range mounts {
+		switch m.Type {
+		case "bind":
+			if err := checkDockerBindSource(m.Source); err != nil {
+				return err
+			}
+		case "volume":
+			if opts := m.VolumeOptions; opts != nil && opts.DriverConfig != nil &&
+				((opts.DriverConfig.Name != "" && opts.DriverConfig.Name != "local") || len(opts.DriverConfig.Options) > 0) {
+				return fmt.Errorf("volume mounts can't use driver options")
+			}
+		case "tmpfs":
+		default:
+			return fmt.Errorf("%s mounts are not allowed", m.Type)
+		}
+	}
+	return nil
+}
+
+// checkDockerBindSource returns an error unless source, with symlinks
+// resolved, is mountHome or below it: the sandbox user's own files. Anything
+// else, like / or the Docker socket, would give the container the sandbox's
+// root-owned files.
+func checkDockerBindSource(source string) error {
+	resolved := resolveExistingPath(filepath.Clean(source))
+	if resolved != mountHome && !strings.HasPrefix(resolved, mountHome+"/") {
+		return fmt.Errorf("containers can only bind-mount paths under %s", mountHome)
+	}
+	return nil
+}
+
+// readDockerBody decodes r's JSON body into v and puts it back for the
+// daemon. An empty body leaves v unchanged.
+func readDockerBody(r *http.Request, v any) error {
+	if r.Body == nil {
+		return nil
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxDockerRequestBody+1))
+	_ = r.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+	if len(data) > maxDockerRequestBody {
+		return fmt.Errorf("request body is too large to check")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+// resolveExistingPath resolves the symlinks in the longest existing prefix of
+// the clean absolute path p: Docker creates missing bind sources below the
+// resolved prefix.
+func resolveExistingPath(p string) string {
+	rest := ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(p); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return filepath.Join(p, rest)
+		}
+		rest = filepath.Join(filepath.Base(p), rest)
+		p = parent
+	}
+}
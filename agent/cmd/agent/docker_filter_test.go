@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDockerFilter(t *testing.T) {
+	// A fake dockerd recording the requests that reach it
+	var mu sync.Mutex
+	var received []string
+	upstreamPath := filepath.Join(t.TempDir(), "dockerd.sock")
+	listener, err := net.Listen("unix", upstreamPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, r.Method+" "+r.URL.RequestURI()+" "+string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	upstream.Listener = listener
+	upstream.Start()
+	defer upstream.Close()
+
+	socketPath := filepath.Join(t.TempDir(), "docker.sock")
+	if err := serveDockerFilter(socketPath, upstreamPath); err != nil {
+		t.Fatalf("serveDockerFilter() error = %v", err)
+	}
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		allowed bool
+	}{
+		{"bridge container", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"NetworkMode":"bridge"}}`, true},
+		{"container without host config", "POST", "/containers/create?name=web", `{"Image":"alpine"}`, true},
+		{"list containers", "GET", "/v1.47/containers/json", "", true},
+		{"exec", "POST", "/v1.47/containers/abc/exec", `{"Cmd":["sh"]}`, true},
+		{"build", "POST", "/v1.47/build?networkmode=default", "", true},
+		{"host network", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"NetworkMode":"host"}}`, false},
+		{"privileged", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"Privileged":true}}`, false},
+		{"lowercase keys", "POST", "/containers/create", `{"image":"alpine","hostconfig":{"privileged":true}}`, false},
+		{"host pid", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"PidMode":"host"}}`, false},
+		{"net admin", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"CapAdd":["cap_net_admin"]}}`, false},
+		{"sys admin", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"CapAdd":["SYS_ADMIN"]}}`, false},
+		{"privileged exec", "POST", "/v1.47/containers/abc/exec", `{"Cmd":["sh"],"Privileged":true}`, false},
+		{"host network build", "POST", "/v1.47/build?networkmode=host", "", false},
+		{"swarm service", "POST", "/v1.47/services/create", `{}`, false},
+		{"plugin install", "POST", "/v1.47/plugins/pull?remote=x", `[]`, false},
+		{"path tricks", "POST", "/v1.47/containers/../containers/create", `{"HostConfig":{"NetworkMode":"host"}}`, false},
+		{"cli defaults", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"Binds":null,"NetworkMode":"default","Privileged":false,"PidMode":"","IpcMode":"","UsernsMode":"","Devices":[],"SecurityOpt":null,"MaskedPaths":null,"Memory":0,"Runtime":""}}`, true},
+		{"workspace bind", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"Binds":["/home/discobot/workspace:/src:ro","cache:/cache"]}}`, true},
+		{"resources and ports", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"Memory":1073741824,"PortBindings":{"80/tcp":[{"HostPort":"8080"}]},"CapAdd":["SYS_PTRACE"],"SecurityOpt":["no-new-privileges"]}}`, true},
+		{"tmpfs and volume mounts", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"Mounts":[{"Type":"tmpfs","Target":"/tmp"},{"Type":"volume","Source":"data","Target":"/data"}]}}`, true},
+		{"bridge network", "POST", "/v1.47/networks/create", `{"Name":"app","Driver":"bridge"}`, true},
+		{"local volume", "POST", "/v1.47/volumes/create", `{"Name":"data"}`, true},
+		{"root bind", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"Binds":["/:/host"]}}`, false},
+		{"docker socket bind", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"Binds":["/var/run/docker.sock:/var/run/docker.sock"]}}`, false},
+		{"bind escaping home", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"Binds":["/home/discobot/../../etc:/etc"]}}`, false},
+		{"root bind mount", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"Mounts":[{"Type":"bind","Source":"/","Target":"/host"}]}}`, false},
+		{"volume mount with driver options", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"Mounts":[{"Type":"volume","Target":"/host","VolumeOptions":{"DriverConfig":{"Options":{"type":"none","o":"bind","device":"/"}}}}]}}`, false},
+		{"devices", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"Devices":[{"PathOnHost":"/dev/sda","PathInContainer":"/dev/sda"}]}}`, false},
+		{"unconfined seccomp", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"SecurityOpt":["seccomp=unconfined"]}}`, false},
+		{"unconfined apparmor", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"SecurityOpt":["apparmor=unconfined"]}}`, false},
+		{"container network", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"NetworkMode":"container:abc"}}`, false},
+		{"container pid", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"PidMode":"container:abc"}}`, false},
+		{"host ipc", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"IpcMode":"host"}}`, false},
+		{"host userns", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"UsernsMode":"host"}}`, false},
+		{"unmasked paths", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"MaskedPaths":[],"ReadonlyPaths":[]}}`, false},
+		{"unknown field", "POST", "/v1.47/containers/create", `{"Image":"alpine","HostConfig":{"SomethingNew":"x"}}`, false},
+		{"update with devices", "POST", "/v1.47/containers/abc/update", `{"Devices":[{"PathOnHost":"/dev/sda"}]}`, false},
+		{"macvlan network", "POST", "/v1.47/networks/create", `{"Name":"lan","Driver":"macvlan"}`, false},
+		{"bind volume", "POST", "/v1.47/volumes/create", `{"Name":"root","DriverOpts":{"type":"none","o":"bind","device":"/"}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			received = nil
+			mu.Unlock()
+
+			req, err := http.NewRequest(tt.method, "http://docker"+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			respBody, _ := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !tt.allowed {
+				if resp.StatusCode != http.StatusForbidden {
+					t.Errorf("status = %d, want 403", resp.StatusCode)
+				}
+				if !strings.Contains(string(respBody), `"message"`) {
+					t.Errorf("expected a Docker API error message, got %s", respBody)
+				}
+				if len(received) != 0 {
+					t.Errorf("refused request reached dockerd: %v", received)
+				}
+				return
+			}
+			if resp.StatusCode != http.StatusCreated {
+				t.Errorf("status = %d, want the daemon's 201: %s", resp.StatusCode, respBody)
+			}
+			// The daemon gets the request, body included, unchanged
+			if want := tt.method + " " + tt.path + " " + tt.body; len(received) != 1 || received[0] != want {
+				t.Errorf("dockerd received %q, want %q", received, want)
+			}
+		})
+	}
+}
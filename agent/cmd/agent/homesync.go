@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Base home sync strategies
+const (
+	// homeSyncAdditive copies files missing from the base home and never
+	// overwrites existing ones (the default).
+	homeSyncAdditive = "additive"
+	// homeSyncOverwriteManaged also overwrites files under the manifest's
+	// managed paths when the image copy differs, so image updates reach
+	// existing sessions. Files outside managed paths are treated additively.
+	homeSyncOverwriteManaged = "overwrite-managed"
+)
+
+// homeSyncManifestPath is where the sandbox image declares its sync strategy.
+const homeSyncManifestPath = "/etc/discobot/home-sync.json"
+
+// defaultManagedPaths are used by overwrite-managed when the manifest lists none.
+var defaultManagedPaths = []string{".config/discobot"}
+
+// homeSyncManifest controls how /home/discobot in the image is synced into an
+// existing base home. Managed paths are relative to the home directory; a
+// path manages itself and everything beneath it.
+type homeSyncManifest struct {
+	Strategy string   `json:"strategy"`
+	Managed  []string `json:"managed,omitempty"`
+}
+
+// loadHomeSyncManifest reads the manifest at path and applies the
+// HOME_SYNC_STRATEGY override. A missing manifest means additive sync.
+func loadHomeSyncManifest(path string) (*homeSyncManifest, error) {
+	manifest := &homeSyncManifest{}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, manifest); err != nil {
+			return nil, fmt.Errorf("invalid home sync manifest %s: %w", path, err)
+		}
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("failed to read home sync manifest: %w", err)
+	}
+
+	// The environment overrides the image's strategy, but not its managed paths
+	if strategy := os.Getenv("HOME_SYNC_STRATEGY"); strategy != "" {
+		manifest.Strategy = strategy
+	}
+	if manifest.Strategy == "" {
+		manifest.Strategy = homeSyncAdditive
+	}
+
+	switch manifest.Strategy {
+	case homeSyncAdditive:
+	case homeSyncOverwriteManaged:
+		if len(manifest.Managed) == 0 {
+			manifest.Managed = slices.Clone(defaultManagedPaths)
+		}
+		for i, p := range manifest.Managed {
+			clean := filepath.Clean(strings.TrimPrefix(p, "/"))
+			if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+				return nil, fmt.Errorf("invalid managed path %q in home sync manifest", p)
+			}
+			manifest.Managed[i] = clean
+		}
+	default:
+		return nil, fmt.Errorf("unknown home sync strategy %q (must be %s or %s)", manifest.Strategy, homeSyncAdditive, homeSyncOverwriteManaged)
+	}
+	return manifest, nil
+}
+
+// isManaged reports whether relPath (relative to the home directory) may be
+// overwritten from the image.
+func (m *homeSyncManifest) isManaged(relPath string) bool {
+	if m.Strategy != homeSyncOverwriteManaged {
+		return false
+	}
+	for _, managed := range m.Managed {
+		if relPath == managed || strings.HasPrefix(relPath, managed+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// syncHome copies files from src to dst according to the manifest. Missing
+// files are always copied; existing regular files are overwritten only when
+// managed and different from the image copy. Nothing in dst is deleted.
+func syncHome(src, dst string, u *userInfo, manifest *homeSyncManifest) error {
+	return filepath.Walk(src, func(srcPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Calculate relative path and destination path
+		relPath, err := filepath.Rel(src, srcPath)
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(dst, relPath)
+
+		// Check if destination already exists
+		dstInfo, dstErr := os.Lstat(dstPath)
+		if dstErr == nil {
+			if !info.Mode().IsRegular() || !dstInfo.Mode().IsRegular() || !manifest.isManaged(relPath) {
+				// Destination exists, skip (don't overwrite)
+				return nil
+			}
+			same, err := sameFileContent(srcPath, dstPath)
+			if err != nil || same {
+				return err
+			}
+			fmt.Printf("discobot-agent: updating managed file %s\n", relPath)
+			if err := copyFile(srcPath, dstPath); err != nil {
+				return err
+			}
+			// copyFile only applies the mode on create
+			if err := os.Chmod(dstPath, info.Mode().Perm()); err != nil {
+				return err
+			}
+			return os.Chown(dstPath, u.uid, u.gid)
+		}
+		if !os.IsNotExist(dstErr) {
+			// Some other error
+			return dstErr
+		}
+
+		// Destination doesn't exist, copy it
+		if info.IsDir() {
+			fmt.Printf("discobot-agent: syncing new directory %s\n", relPath)
+			if err := os.MkdirAll(dstPath, info.Mode().Perm()); err != nil {
+				return err
+			}
+			if err := os.Chown(dstPath, u.uid, u.gid); err != nil {
+				return err
+			}
+		} else if info.Mode()&os.ModeSymlink != 0 {
+			// Handle symlinks
+			link, err := os.Readlink(srcPath)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("discobot-agent: syncing new symlink %s\n", relPath)
+			if err := os.Symlink(link, dstPath); err != nil {
+				return err
+			}
+			if err := os.Lchown(dstPath, u.uid, u.gid); err != nil {
+				return err
+			}
+		} else if info.Mode().IsRegular() {
+			fmt.Printf("discobot-agent: syncing new file %s\n", relPath)
+			if err := copyFile(srcPath, dstPath); err != nil {
+				return err
+			}
+			if err := os.Chown(dstPath, u.uid, u.gid); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// sameFileContent reports whether two regular files have identical contents.
+func sameFileContent(a, b string) (bool, error) {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	if aInfo.Size() != bInfo.Size() {
+		return false, nil
+	}
+	aData, err := os.ReadFile(a)
+	if err != nil {
+		return false, err
+	}
+	bData, err := os.ReadFile(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(aData, bData), nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestLoadHomeSyncManifest(t *testing.T) {
+	t.Setenv("HOME_SYNC_STRATEGY", "")
+	dir := t.TempDir()
+
+	manifest, err := loadHomeSyncManifest(filepath.Join(dir, "missing.json"))
+	if err != nil || manifest.Strategy != homeSyncAdditive {
+		t.Fatalf("Expected additive default for missing manifest, got %+v, %v", manifest, err)
+	}
+
+	path := filepath.Join(dir, "home-sync.json")
+	writeTestFile(t, path, `{"strategy": "overwrite-managed"}`)
+	manifest, err = loadHomeSyncManifest(path)
+	if err != nil {
+		t.Fatalf("loadHomeSyncManifest failed: %v", err)
+	}
+	if !manifest.isManaged(".config/discobot/settings.json") || manifest.isManaged(".config/other") {
+		t.Errorf("Expected default managed paths, got %v", manifest.Managed)
+	}
+
+	writeTestFile(t, path, `{"strategy": "overwrite-managed", "managed": ["../etc"]}`)
+	if _, err := loadHomeSyncManifest(path); err == nil {
+		t.Error("Expected error for managed path outside home")
+	}
+
+	// The environment overrides the manifest's strategy
+	t.Setenv("HOME_SYNC_STRATEGY", "additive")
+	writeTestFile(t, path, `{"strategy": "overwrite-managed", "managed": [".bashrc"]}`)
+	manifest, err = loadHomeSyncManifest(path)
+	if err != nil || manifest.isManaged(".bashrc") {
+		t.Errorf("Expected HOME_SYNC_STRATEGY to force additive sync, got %+v, %v", manifest, err)
+	}
+
+	t.Setenv("HOME_SYNC_STRATEGY", "mirror")
+	if _, err := loadHomeSyncManifest(path); err == nil {
+		t.Error("Expected error for unknown strategy")
+	}
+}
+
+func TestSyncHome(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+	u := &userInfo{uid: os.Getuid(), gid: os.Getgid()}
+
+	writeTestFile(t, filepath.Join(src, ".bashrc"), "image bashrc\n")
+	writeTestFile(t, filepath.Join(src, ".config/discobot/settings.json"), "image settings\n")
+	writeTestFile(t, filepath.Join(src, ".config/discobot/new.json"), "new\n")
+
+	writeTestFile(t, filepath.Join(dst, ".bashrc"), "user bashrc\n")
+	writeTestFile(t, filepath.Join(dst, ".config/discobot/settings.json"), "old settings\n")
+	writeTestFile(t, filepath.Join(dst, ".config/discobot/user.json"), "user\n")
+
+	additive := &homeSyncManifest{Strategy: homeSyncAdditive}
+	if err := syncHome(src, dst, u, additive); err != nil {
+		t.Fatalf("syncHome failed: %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(dst, ".config/discobot/settings.json")); got != "old settings\n" {
+		t.Errorf("Additive sync overwrote an existing file: %q", got)
+	}
+	if got := readTestFile(t, filepath.Join(dst, ".config/discobot/new.json")); got != "new\n" {
+		t.Errorf("Additive sync didn't copy a new file: %q", got)
+	}
+
+	managed := &homeSyncManifest{Strategy: homeSyncOverwriteManaged, Managed: []string{".config/discobot"}}
+	if err := syncHome(src, dst, u, managed); err != nil {
+		t.Fatalf("syncHome failed: %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(dst, ".config/discobot/settings.json")); got != "image settings\n" {
+		t.Errorf("Expected managed file to be updated, got %q", got)
+	}
+	if got := readTestFile(t, filepath.Join(dst, ".bashrc")); got != "user bashrc\n" {
+		t.Errorf("Expected unmanaged file to be preserved, got %q", got)
+	}
+	if got := readTestFile(t, filepath.Join(dst, ".config/discobot/user.json")); got != "user\n" {
+		t.Errorf("Expected files missing from the image to be kept, got %q", got)
+	}
+}
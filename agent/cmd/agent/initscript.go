@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// initScriptPath is where the operator's init script is written before it runs.
+	initScriptPath = "/run/discobot/init-script"
+	// initScriptLogPath keeps the output of the last run, readable by root only.
+	initScriptLogPath = "/var/log/discobot-init-script.log"
+	// initScriptTimeout bounds how long the init script may delay startup.
+	initScriptTimeout = 10 * time.Minute
+)
+
+// runInitScript runs the operator-provided init script from INIT_SCRIPT
+// (base64) as root, before session hooks and the agent start. The script
+// comes only from the server's SANDBOX_INIT_SCRIPT setting, never from the
+// workspace, and is removed from the environment and disk once read so
+// hooks and the agent can't see it. Returns nil if no script is configured.
+func runInitScript() error {
+	script, err := initScriptData(os.Getenv("INIT_SCRIPT"))
+	_ = os.Unsetenv("INIT_SCRIPT")
+	if err != nil || script == nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(initScriptPath), 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(initScriptPath), err)
+	}
+	if err := os.WriteFile(initScriptPath, script, 0700); err != nil {
+		return fmt.Errorf("failed to write init script: %w", err)
+	}
+	defer func() { _ = os.Remove(initScriptPath) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), initScriptTimeout)
+	defer cancel()
+
+	// Scripts without a shebang are run by /bin/sh
+	var cmd *exec.Cmd
+	if bytes.HasPrefix(script, []byte("#!")) {
+		cmd = exec.CommandContext(ctx, initScriptPath)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", initScriptPath)
+	}
+	cmd.Dir = "/"
+	cmd.Env = initScriptEnv(os.Environ())
+
+	var outputBuf bytes.Buffer
+	cmd.Stdout = io.MultiWriter(&outputBuf, &prefixWriter{prefix: "  [init-script] ", w: os.Stdout})
+	cmd.Stderr = io.MultiWriter(&outputBuf, &prefixWriter{prefix: "  [init-script] ", w: os.Stderr})
+
+	fmt.Printf("discobot-agent: running init script as root (%d bytes)\n", len(script))
+	runErr := cmd.Run()
+
+	if err := os.WriteFile(initScriptLogPath, outputBuf.Bytes(), 0600); err != nil {
+		fmt.Printf("discobot-agent: warning: failed to save init script output: %v\n", err)
+	}
+
+	if runErr != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("init script timed out after %s (output in %s)", initScriptTimeout, initScriptLogPath)
+		}
+		return fmt.Errorf("init script failed: %w (output in %s)", runErr, initScriptLogPath)
+	}
+	return nil
+}
+
+// initScriptData decodes the INIT_SCRIPT value. Returns nil if it's unset.
+func initScriptData(encoded string) ([]byte, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	script, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid INIT_SCRIPT: %w", err)
+	}
+	if len(bytes.TrimSpace(script)) == 0 {
+		return nil, fmt.Errorf("invalid INIT_SCRIPT: empty script")
+	}
+	return script, nil
+}
+
+// initScriptEnv returns the environment for the init script: the agent's
+// own, with HOME set for root.
+func initScriptEnv(parentEnv []string) []string {
+	env := make([]string, 0, len(parentEnv)+1)
+	for _, e := range parentEnv {
+		if name, _, _ := strings.Cut(e, "="); name == "HOME" {
+			continue
+		}
+		env = append(env, e)
+	}
+	return append(env, "HOME=/root")
+}
+
+// initScriptFatal reports whether a failing init script should stop the
+// sandbox from starting. Controlled by INIT_SCRIPT_FATAL (default: false).
+func initScriptFatal() bool {
+	fatal, err := strconv.ParseBool(os.Getenv("INIT_SCRIPT_FATAL"))
+	return err == nil && fatal
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/base64"
+	"slices"
+	"testing"
+)
+
+func TestInitScriptData(t *testing.T) {
+	script, err := initScriptData("")
+	if err != nil || script != nil {
+		t.Errorf("expected no script without INIT_SCRIPT, got %q (err %v)", script, err)
+	}
+
+	custom := "#!/bin/sh\nupdate-ca-certificates\n"
+	script, err = initScriptData(base64.StdEncoding.EncodeToString([]byte(custom)))
+	if err != nil || string(script) != custom {
+		t.Errorf("expected server-provided script, got %q (err %v)", script, err)
+	}
+
+	if _, err := initScriptData("not base64!"); err == nil {
+		t.Error("expected error for invalid base64")
+	}
+	if _, err := initScriptData(base64.StdEncoding.EncodeToString([]byte("\n\t"))); err == nil {
+		t.Error("expected error for empty script")
+	}
+}
+
+func TestInitScriptEnv(t *testing.T) {
+	env := initScriptEnv([]string{"PATH=/usr/bin", "HOME=/home/discobot", "SESSION_ID=abc"})
+	want := []string{"PATH=/usr/bin", "SESSION_ID=abc", "HOME=/root"}
+	if !slices.Equal(env, want) {
+		t.Errorf("expected %v, got %v", want, env)
+	}
+}
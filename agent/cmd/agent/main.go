@@ -9,11 +9,13 @@
 package main
 
 import (
+	"bytes"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	_ "embed"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
@@ -21,6 +23,7 @@ import (
 	"io"
 	"math/big"
 	"net"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -28,8 +31,11 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 //go:embed default-proxy-config.yaml
@@ -57,6 +63,9 @@ const (
 	// Proxy binary path
 	proxyBinary = "/opt/discobot/bin/proxy"
 
+	// How often to verify the /workspace symlink is still intact
+	symlinkCheckInterval = 5 * time.Second
+
 	// Proxy ports
 	proxyPort    = 17080
 	proxyAPIPort = 17081
@@ -68,9 +77,20 @@ const (
 	stagingDir      = "/.data/discobot/workspace.staging"
 	agentFSDir      = "/.data/.agentfs"
 	overlayFSDir    = "/.data/.overlayfs"
+	kvDir           = "/.data/kv"         // Session key-value store (outside the home overlay)
+	uploadsDir      = "/.data/uploads"    // Partial resumable file uploads (outside the home overlay)
 	mountHome       = "/home/discobot"    // Where agentfs/overlayfs mounts
 	symlinkPath     = "/workspace"        // Symlink to /home/discobot/workspace
 	tempMigrationFS = "/.data/.migration" // Temporary mount point for migration
+
+	// Upstream proxy credentials (user:password), written root-only by the
+	// server so they stay out of the container environment
+	proxyUpstreamAuthFile = "/.data/proxy-upstream-auth"
+
+	// Registry credential files (tmpfs, never persisted). Not under
+	// /run/discobot, which is root-only, since the agent API writes them as
+	// the user.
+	credentialsDir = "/run/discobot-credentials"
 )
 
 // filesystemType represents the type of filesystem to use for session isolation
@@ -93,21 +113,12 @@ func (f filesystemType) String() string {
 }
 
 // detectFilesystemType determines which filesystem to use based on existing data.
-// If an agentfs database exists for the session, use agentfs for backwards compatibility.
-// Otherwise, use overlayfs for new sessions.
+// A session already migrated to overlayfs stays on it, whatever the override
+// says, since its data now lives in the overlay. Otherwise DISCOBOT_FILESYSTEM
+// (the workspace's preference) decides, and without it an existing agentfs
+// database selects agentfs for backwards compatibility, to be migrated.
+// New sessions use overlayfs.
 func detectFilesystemType(sessionID string) filesystemType {
-	// Check for environment variable override
-	if fsOverride := os.Getenv("DISCOBOT_FILESYSTEM"); fsOverride != "" {
-		switch strings.ToLower(fsOverride) {
-		case "agentfs":
-			fmt.Printf("discobot-agent: filesystem override: agentfs\n")
-			return fsTypeAgentFS
-		case "overlayfs":
-			fmt.Printf("discobot-agent: filesystem override: overlayfs\n")
-			return fsTypeOverlayFS
-		}
-	}
-
 	// Check if migration marker exists (session has already been migrated)
 	migrationMarker := filepath.Join(overlayFSDir, sessionID, ".migrated")
 	if _, err := os.Stat(migrationMarker); err == nil {
@@ -115,6 +126,16 @@ func detectFilesystemType(sessionID string) filesystemType {
 		return fsTypeOverlayFS
 	}
 
+	// Check for environment variable override
+	switch filesystemOverride() {
+	case "agentfs":
+		fmt.Printf("discobot-agent: filesystem override: agentfs\n")
+		return fsTypeAgentFS
+	case "overlayfs":
+		fmt.Printf("discobot-agent: filesystem override: overlayfs\n")
+		return fsTypeOverlayFS
+	}
+
 	// Default: check for existing agentfs database
 	dbPath := filepath.Join(agentFSDir, sessionID+".db")
 	if _, err := os.Stat(dbPath); err == nil {
@@ -123,6 +144,12 @@ func detectFilesystemType(sessionID string) filesystemType {
 	return fsTypeOverlayFS
 }
 
+// filesystemOverride returns the filesystem DISCOBOT_FILESYSTEM asks for,
+// lowercased, or "" if it's unset.
+func filesystemOverride() string {
+	return strings.ToLower(os.Getenv("DISCOBOT_FILESYSTEM"))
+}
+
 // migrateAgentFSToOverlayFS migrates an existing agentfs session to overlayfs.
 // It mounts agentfs at a temporary location, creates overlayfs at the target,
 // rsyncs the data, and marks the migration as complete.
@@ -301,6 +328,12 @@ func run() error {
 		fmt.Printf("discobot-agent: warning: failed to fix MTU for nested Docker: %v\n", err)
 	}
 
+	// Raise the open file limit before starting anything that inherits it
+	if err := raiseNoFileLimit(); err != nil {
+		// Log but don't fail - the default limit still works
+		fmt.Printf("discobot-agent: warning: %v\n", err)
+	}
+
 	// Determine configuration from environment
 	agentBinary := envOrDefault("AGENT_BINARY", defaultAgentBinary)
 	runAsUser := envOrDefault("AGENT_USER", defaultUser)
@@ -332,6 +365,12 @@ func run() error {
 	if err := setupBaseHome(userInfo); err != nil {
 		return fmt.Errorf("base home setup failed: %w", err)
 	}
+	if err := setupAgentAPIDirs(userInfo); err != nil {
+		return fmt.Errorf("agent API directory setup failed: %w", err)
+	}
+	if err := setupCredentialsDir(userInfo); err != nil {
+		return fmt.Errorf("credentials directory setup failed: %w", err)
+	}
 	fmt.Printf("discobot-agent: [%.3fs] base home setup completed\n", time.Since(stepStart).Seconds())
 
 	// Step 2: Clone workspace (must complete before overlayfs mount)
@@ -343,6 +382,12 @@ func run() error {
 	}
 	fmt.Printf("discobot-agent: [%.3fs] workspace setup completed\n", time.Since(stepStart).Seconds())
 
+	// Step 2.5: Discard home directory changes if a reset was requested
+	// (must happen before the filesystem is mounted)
+	if err := resetHomeIfRequested(sessionID); err != nil {
+		return fmt.Errorf("home reset failed: %w", err)
+	}
+
 	// Step 3: Detect filesystem type (overlayfs for new sessions, agentfs for existing)
 	fsType := detectFilesystemType(sessionID)
 
@@ -350,8 +395,6 @@ func run() error {
 	stepStart = time.Now()
 	switch fsType {
 	case fsTypeAgentFS:
-		fmt.Printf("discobot-agent: agentfs session detected, migrating to overlayfs\n")
-
 		// Ensure agentfs directory exists with correct ownership
 		if err := os.MkdirAll(agentFSDir, 0755); err != nil {
 			return fmt.Errorf("failed to create agentfs directory: %w", err)
@@ -360,16 +403,37 @@ func run() error {
 			return fmt.Errorf("failed to chown agentfs directory: %w", err)
 		}
 
+		// Sessions with an agentfs database from before overlayfs are
+		// migrated, unless the workspace asks to stay on agentfs
+		_, dbErr := os.Stat(filepath.Join(agentFSDir, sessionID+".db"))
+		migrate := dbErr == nil && filesystemOverride() != "agentfs"
+
 		// Initialize agentfs database if needed (as discobot user)
 		if err := initAgentFS(sessionID, userInfo); err != nil {
 			return fmt.Errorf("agentfs init failed: %w", err)
 		}
 
-		// Perform migration from agentfs to overlayfs
+		if !migrate {
+			fmt.Printf("discobot-agent: using AgentFS\n")
+			if err := mountAgentFS(sessionID, userInfo); err != nil {
+				return fmt.Errorf("agentfs mount failed: %w", err)
+			}
+			break
+		}
+
+		// The migration marker makes later boots use the overlay directly,
+		// so this only happens once
+		fmt.Printf("discobot-agent: agentfs session detected, migrating to overlayfs\n")
 		if err := migrateAgentFSToOverlayFS(sessionID, userInfo); err != nil {
 			return fmt.Errorf("migration from agentfs to overlayfs failed: %w", err)
 		}
 
+		// A reset requested before the migration applies to the migrated
+		// home, so the workspace copied out of agentfs is kept
+		if err := resetMigratedHomeIfRequested(sessionID); err != nil {
+			return fmt.Errorf("home reset failed: %w", err)
+		}
+
 	case fsTypeOverlayFS:
 		fmt.Printf("discobot-agent: using OverlayFS (new session)\n")
 
@@ -416,6 +480,18 @@ func run() error {
 		return fmt.Errorf("symlink creation failed: %w", err)
 	}
 	fmt.Printf("discobot-agent: [%.3fs] workspace symlink created\n", time.Since(stepStart).Seconds())
+	go watchWorkspaceSymlink()
+
+	// Step 5.2: Run the operator's init script as root (before hooks and the
+	// proxy, so packages and CA certificates it installs are available to both)
+	stepStart = time.Now()
+	if err := runInitScript(); err != nil {
+		if initScriptFatal() {
+			return fmt.Errorf("init script failed (INIT_SCRIPT_FATAL=true): %w", err)
+		}
+		fmt.Printf("discobot-agent: WARNING: %v\n", err)
+	}
+	fmt.Printf("discobot-agent: [%.3fs] init script completed\n", time.Since(stepStart).Seconds())
 
 	// Step 5.5: Run session hooks from .discobot/hooks/
 	// Blocking hooks run synchronously here; non-blocking hooks launch in background goroutines.
@@ -444,23 +520,64 @@ func run() error {
 	proxyCmd, err := startProxyDaemon(userInfo)
 	proxyEnabled := (err == nil && proxyCmd != nil)
 	if err != nil {
-		// Log but don't fail - Proxy is optional
+		// Fail hard when policy requires all traffic to go through the proxy
+		if proxyRequired() {
+			return fmt.Errorf("proxy daemon failed to start (PROXY_REQUIRED=true): %w", err)
+		}
+
+		// Otherwise degrade to direct network access. Proxy env vars are only
+		// set when the proxy is running, but a profile script from a previous
+		// boot may still point login shells at the dead proxy.
 		fmt.Printf("discobot-agent: Proxy daemon not started: %v\n", err)
+		fmt.Printf("discobot-agent: WARNING: proxy unavailable, sandbox will use direct network access (filtering and credential injection disabled)\n")
+		if err := clearProxyFromProfile(); err != nil {
+			fmt.Printf("discobot-agent: warning: failed to clear stale proxy settings: %v\n", err)
+		}
 	} else {
 		fmt.Printf("discobot-agent: [%.3fs] proxy daemon started\n", time.Since(stepStart).Seconds())
 	}
 
+	mode, err := networkMode()
+	if err != nil {
+		return err
+	}
+
 	// Step 9: Start Docker daemon if available (after proxy so Docker can use it)
 	stepStart = time.Now()
-	dockerCmd, err := startDockerDaemon(proxyEnabled)
+	dockerCmd, err := startDockerDaemon(proxyEnabled, mode != networkModeOpen)
 	if err != nil {
 		// Log but don't fail - Docker is optional
 		fmt.Printf("discobot-agent: Docker daemon not started: %v\n", err)
 	} else {
 		fmt.Printf("discobot-agent: [%.3fs] Docker daemon started\n", time.Since(stepStart).Seconds())
+
+		// Route nested builds and containers through the proxy as well. The
+		// daemon itself trusts the proxy CA via the system trust store, which
+		// also covers image pulls made by the built-in BuildKit builder.
+		if proxyEnabled {
+			if err := configureNestedDocker(userInfo); err != nil {
+				fmt.Printf("discobot-agent: warning: failed to configure nested Docker proxy: %v\n", err)
+			}
+		}
 	}
 
-	// Step 10: Run the agent API
+	// Step 10: Enforce the workspace network mode (after dockerd so DOCKER-USER exists)
+	stepStart = time.Now()
+	if mode == networkModeProxied && !proxyEnabled {
+		// The sandbox already fell back to direct access above
+		fmt.Printf("discobot-agent: WARNING: network mode proxied but proxy is not running, egress firewall not applied\n")
+	} else if err := applyNetworkMode(mode, dockerCmd != nil); err != nil {
+		// Running without the egress firewall would silently grant the open
+		// mode, so only that mode tolerates a failure
+		if mode != networkModeOpen {
+			return fmt.Errorf("failed to enforce network mode %s: %w", mode, err)
+		}
+		fmt.Printf("discobot-agent: WARNING: failed to enforce network mode %s: %v\n", mode, err)
+	} else {
+		fmt.Printf("discobot-agent: [%.3fs] network mode applied\n", time.Since(stepStart).Seconds())
+	}
+
+	// Step 11: Run the agent API
 	fmt.Printf("discobot-agent: [%.3fs] total startup time\n", time.Since(startupStart).Seconds())
 	fmt.Printf("discobot-agent: starting agent API\n")
 	return runAgent(agentBinary, userInfo, dockerCmd, proxyCmd)
@@ -630,15 +747,19 @@ func setupGitSafeDirectories(workspacePath string) error {
 }
 
 // setupBaseHome copies /home/discobot to /.data/discobot if it doesn't exist,
-// or syncs new files if it already exists
+// or syncs files according to the image's home sync manifest if it already exists
 func setupBaseHome(u *userInfo) error {
 	// Check if base home already exists
 	if _, err := os.Stat(baseHomeDir); err == nil {
-		fmt.Printf("discobot-agent: base home already exists at %s, syncing new files\n", baseHomeDir)
-		// Sync any new files from /home/discobot to /.data/discobot
-		// This ensures new files added to the container image get propagated
-		if err := syncNewFiles(mountHome, baseHomeDir, u); err != nil {
-			return fmt.Errorf("failed to sync new files: %w", err)
+		manifest, err := loadHomeSyncManifest(homeSyncManifestPath)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("discobot-agent: base home already exists at %s, syncing files (strategy: %s)\n", baseHomeDir, manifest.Strategy)
+		// Sync files from /home/discobot to /.data/discobot
+		// This ensures new (and, if managed, updated) files in the container image get propagated
+		if err := syncHome(mountHome, baseHomeDir, u, manifest); err != nil {
+			return fmt.Errorf("failed to sync home files: %w", err)
 		}
 		return nil
 	}
@@ -664,66 +785,35 @@ func setupBaseHome(u *userInfo) error {
 	return nil
 }
 
-// syncNewFiles copies files from src to dst that don't exist in dst.
-// It does not overwrite existing files to preserve user modifications.
-func syncNewFiles(src, dst string, u *userInfo) error {
-	return filepath.Walk(src, func(srcPath string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// setupAgentAPIDirs creates the agent API's key-value store and upload
+// directories, owned by the user so the agent API can write to them. They
+// live directly in /.data rather than the base home so their contents aren't
+// part of the home overlay, and partial uploads survive restarts.
+func setupAgentAPIDirs(u *userInfo) error {
+	for _, dir := range []string{kvDir, uploadsDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
 		}
-
-		// Calculate relative path and destination path
-		relPath, err := filepath.Rel(src, srcPath)
-		if err != nil {
+		if err := os.Chown(dir, u.uid, u.gid); err != nil {
 			return err
 		}
-		dstPath := filepath.Join(dst, relPath)
-
-		// Check if destination already exists
-		_, dstErr := os.Lstat(dstPath)
-		if dstErr == nil {
-			// Destination exists, skip (don't overwrite)
-			return nil
-		}
-		if !os.IsNotExist(dstErr) {
-			// Some other error
-			return dstErr
-		}
-
-		// Destination doesn't exist, copy it
-		if info.IsDir() {
-			fmt.Printf("discobot-agent: syncing new directory %s\n", relPath)
-			if err := os.MkdirAll(dstPath, info.Mode().Perm()); err != nil {
-				return err
-			}
-			if err := os.Chown(dstPath, u.uid, u.gid); err != nil {
-				return err
-			}
-		} else if info.Mode()&os.ModeSymlink != 0 {
-			// Handle symlinks
-			link, err := os.Readlink(srcPath)
-			if err != nil {
-				return err
-			}
-			fmt.Printf("discobot-agent: syncing new symlink %s\n", relPath)
-			if err := os.Symlink(link, dstPath); err != nil {
-				return err
-			}
-			if err := os.Lchown(dstPath, u.uid, u.gid); err != nil {
-				return err
-			}
-		} else if info.Mode().IsRegular() {
-			fmt.Printf("discobot-agent: syncing new file %s\n", relPath)
-			if err := copyFile(srcPath, dstPath); err != nil {
-				return err
-			}
-			if err := os.Chown(dstPath, u.uid, u.gid); err != nil {
-				return err
-			}
-		}
+	}
+	return nil
+}
 
-		return nil
-	})
+// setupCredentialsDir mounts a small tmpfs, owned by the user, where the
+// agent API writes registry credential files (e.g. an npmrc with a token).
+// Being in memory and outside the home overlay, the files are never written
+// to the session's persistent layer and disappear when the sandbox stops.
+func setupCredentialsDir(u *userInfo) error {
+	if err := os.MkdirAll(credentialsDir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", credentialsDir, err)
+	}
+	opts := fmt.Sprintf("mode=0700,size=1m,uid=%d,gid=%d", u.uid, u.gid)
+	if err := syscall.Mount("tmpfs", credentialsDir, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, opts); err != nil {
+		return fmt.Errorf("failed to mount tmpfs at %s: %w", credentialsDir, err)
+	}
+	return nil
 }
 
 // copyDir recursively copies a directory preserving permissions
@@ -802,10 +892,17 @@ func copyFile(src, dst string) error {
 	return err
 }
 
-// setupWorkspace clones the workspace if it doesn't exist.
+// setupWorkspace clones the workspace if it doesn't exist, or re-clones it
+// if an existing git workspace is corrupt (see repairWorkspace).
 func setupWorkspace(workspacePath, workspaceCommit string, u *userInfo) error {
-	// If workspace already exists, nothing to do
+	// If workspace already exists and is healthy, nothing to do
 	if _, err := os.Stat(workspaceDir); err == nil {
+		if workspacePath != "" {
+			if err := checkWorkspaceRepo(workspaceDir, u); err != nil {
+				fmt.Printf("discobot-agent: WARNING: existing workspace at %s is corrupt: %v\n", workspaceDir, err)
+				return repairWorkspace(workspacePath, workspaceCommit, u)
+			}
+		}
 		fmt.Printf("discobot-agent: workspace already exists at %s\n", workspaceDir)
 		return nil
 	}
@@ -822,37 +919,203 @@ func setupWorkspace(workspacePath, workspaceCommit string, u *userInfo) error {
 		return nil
 	}
 
-	fmt.Printf("discobot-agent: cloning workspace from %s\n", workspacePath)
+	if err := cloneWorkspaceToStaging(workspacePath, workspaceCommit, u); err != nil {
+		return err
+	}
 
-	// Clean up any existing staging directory
-	if err := os.RemoveAll(stagingDir); err != nil {
-		return fmt.Errorf("failed to remove staging directory: %w", err)
+	// Atomically move staging to final location
+	if err := os.Rename(stagingDir, workspaceDir); err != nil {
+		return fmt.Errorf("failed to move staging to workspace: %w", err)
 	}
 
+	fmt.Printf("discobot-agent: workspace cloned successfully\n")
+	return nil
+}
+
+// cloneOptions controls how the workspace is cloned.
+type cloneOptions struct {
+	depth       int  // Commits of history to fetch, 0 for all
+	allBranches bool // Clone every branch, not just the current one
+	submodules  bool // Clone and initialize submodules
+}
+
+// workspaceCloneOptions reads WORKSPACE_CLONE_DEPTH (commits of history to
+// fetch, 0 or unset for all), WORKSPACE_CLONE_ALL_BRANCHES, and
+// WORKSPACE_SUBMODULES.
+func workspaceCloneOptions() (cloneOptions, error) {
+	var opts cloneOptions
+	if v := os.Getenv("WORKSPACE_CLONE_DEPTH"); v != "" {
+		depth, err := strconv.Atoi(v)
+		if err != nil || depth < 0 {
+			return cloneOptions{}, fmt.Errorf("invalid WORKSPACE_CLONE_DEPTH %q: must be a non-negative integer", v)
+		}
+		opts.depth = depth
+	}
+	opts.allBranches = os.Getenv("WORKSPACE_CLONE_ALL_BRANCHES") == "true"
+	opts.submodules = os.Getenv("WORKSPACE_SUBMODULES") == "true"
+	return opts, nil
+}
+
+// submoduleConfigArgs returns the git config needed to fetch the submodules
+// of workspacePath. Git refuses to fetch submodules over the file transport
+// by default, but a local workspace's submodules usually have relative URLs
+// that resolve to local paths.
+func submoduleConfigArgs(workspacePath string) []string {
+	if filepath.IsAbs(workspacePath) || strings.HasPrefix(workspacePath, "file://") {
+		return []string{"-c", "protocol.file.allow=always"}
+	}
+	return nil
+}
+
+// workspaceCloneArgs builds the git clone arguments. By default only the
+// source's current branch is cloned, with its full history, and submodules
+// are left uninitialized.
+func workspaceCloneArgs(workspacePath, dest string, opts cloneOptions) []string {
+	var args []string
+	if opts.submodules {
+		args = append(args, submoduleConfigArgs(workspacePath)...)
+	}
+	args = append(args, "clone")
+	if opts.allBranches {
+		// --depth implies --single-branch, so ask for all branches explicitly
+		args = append(args, "--no-single-branch")
+	} else {
+		args = append(args, "--single-branch")
+	}
+	if opts.submodules {
+		// Submodules are cloned with their full history, since the commit
+		// a workspace pins may not be on a submodule's default branch
+		args = append(args, "--recurse-submodules")
+	}
+	if opts.depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(opts.depth))
+		// Local clones ignore --depth unless the source is a file:// URL
+		if filepath.IsAbs(workspacePath) {
+			workspacePath = "file://" + workspacePath
+		}
+	}
+	return append(args, workspacePath, dest)
+}
+
+// errCloneAuthFailed marks a clone that failed because the credentials were
+// missing or rejected. Retrying can't fix that, so it fails immediately.
+var errCloneAuthFailed = errors.New("authentication failed")
+
+const cloneMaxAttempts = 6
+
+// cloneRetryBackoff is the wait after the first failed clone attempt. It
+// doubles after each further failure (1s, 2s, 4s, ... for 31s in total).
+var cloneRetryBackoff = time.Second
+
+// cloneWithRetry runs git clone with cloneArgs, retrying with exponential
+// backoff so a transient network failure during container startup doesn't
+// permanently fail the session. dest is removed before each attempt since git
+// refuses to clone into a non-empty directory.
+func cloneWithRetry(cloneArgs []string, dest string) error {
+	backoff := cloneRetryBackoff
+
+	var lastErr error
+	for attempt := 1; attempt <= cloneMaxAttempts; attempt++ {
+		if err := os.RemoveAll(dest); err != nil {
+			return fmt.Errorf("failed to remove staging directory: %w", err)
+		}
+
+		fmt.Printf("discobot-agent: running: git %v (attempt %d/%d)\n", cloneArgs, attempt, cloneMaxAttempts)
+
+		// Capture stderr as well as showing it, to tell auth failures apart
+		var stderr bytes.Buffer
+		cmd := exec.Command("git", cloneArgs...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+		err := cmd.Run()
+		if err == nil {
+			return nil
+		}
+
+		if isCloneAuthFailure(stderr.String()) {
+			return fmt.Errorf("git clone failed: %w: %v", errCloneAuthFailed, err)
+		}
+
+		lastErr = err
+		fmt.Fprintf(os.Stderr, "discobot-agent: git clone attempt %d failed: %v\n", attempt, err)
+		if attempt < cloneMaxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	return fmt.Errorf("git clone failed after %d attempts: %w", cloneMaxAttempts, lastErr)
+}
+
+// isCloneAuthFailure reports whether git clone's stderr shows that the remote
+// rejected (or could not be given) credentials, as opposed to a network error.
+func isCloneAuthFailure(stderr string) bool {
+	stderr = strings.ToLower(stderr)
+	for _, marker := range []string{
+		"authentication failed",
+		"could not read username",
+		"could not read password",
+		"terminal prompts disabled",
+		"permission denied (publickey",
+		"access denied",
+		"the requested url returned error: 401",
+		"the requested url returned error: 403",
+	} {
+		if strings.Contains(stderr, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneWorkspaceToStaging clones the workspace into stagingDir, checks out
+// the requested commit, and hands ownership to the user.
+func cloneWorkspaceToStaging(workspacePath, workspaceCommit string, u *userInfo) error {
+	fmt.Printf("discobot-agent: cloning workspace from %s\n", workspacePath)
+
 	// Note: git safe.directory is configured system-wide in setupGitSafeDirectories()
 
+	opts, err := workspaceCloneOptions()
+	if err != nil {
+		return err
+	}
+
 	// Clone to staging directory first
-	cloneArgs := []string{"clone", "--single-branch", workspacePath, stagingDir}
+	cloneArgs := workspaceCloneArgs(workspacePath, stagingDir, opts)
 
-	cmd := exec.Command("git", cloneArgs...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	fmt.Printf("discobot-agent: running: git %v\n", cloneArgs)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("git clone failed: %w", err)
+	if err := cloneWithRetry(cloneArgs, stagingDir); err != nil {
+		return err
 	}
 
 	// If specific commit requested, create a branch at that commit to avoid detached HEAD
 	if workspaceCommit != "" {
+		if opts.depth > 0 {
+			if err := fetchWorkspaceCommit(stagingDir, workspaceCommit, opts.depth); err != nil {
+				return err
+			}
+		}
+
 		// Create a temporary branch at the target commit
 		branchName := "discobot-session"
-		cmd = exec.Command("git", "-C", stagingDir, "checkout", "-B", branchName, workspaceCommit)
+		cmd := exec.Command("git", "-C", stagingDir, "checkout", "-B", branchName, workspaceCommit)
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		fmt.Printf("discobot-agent: creating branch %s at commit %s\n", branchName, workspaceCommit)
 		if err := cmd.Run(); err != nil {
 			return fmt.Errorf("git checkout -B %s %s failed: %w", branchName, workspaceCommit, err)
 		}
+
+		// Move submodules to the commits recorded at the requested commit
+		if opts.submodules {
+			args := append(submoduleConfigArgs(workspacePath), "-C", stagingDir, "submodule", "update", "--init", "--recursive")
+			cmd := exec.Command("git", args...)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			fmt.Printf("discobot-agent: updating submodules\n")
+			if err := cmd.Run(); err != nil {
+				return fmt.Errorf("git submodule update failed: %w", err)
+			}
+		}
 	}
 
 	// Change ownership of all files to the target user
@@ -860,13 +1123,131 @@ func setupWorkspace(workspacePath, workspaceCommit string, u *userInfo) error {
 	if err := chownRecursive(stagingDir, u.uid, u.gid); err != nil {
 		return fmt.Errorf("failed to chown workspace: %w", err)
 	}
+	return nil
+}
 
-	// Atomically move staging to final location
+// fetchWorkspaceCommit makes sure commit is in the shallow clone in dir,
+// which only has the last depth commits of the cloned branches. A missing
+// commit is fetched with depth commits of its own history, or, if the
+// source won't serve it by hash, the clone is unshallowed.
+func fetchWorkspaceCommit(dir, commit string, depth int) error {
+	hasCommit := func() bool {
+		return exec.Command("git", "-C", dir, "cat-file", "-e", commit+"^{commit}").Run() == nil
+	}
+	if hasCommit() {
+		return nil
+	}
+
+	fmt.Printf("discobot-agent: commit %s is outside the shallow clone, fetching it\n", commit)
+	cmd := exec.Command("git", "-C", dir, "fetch", "--depth", strconv.Itoa(depth), "origin", commit)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err == nil && hasCommit() {
+		return nil
+	}
+
+	fmt.Printf("discobot-agent: fetching full history for commit %s\n", commit)
+	cmd = exec.Command("git", "-C", dir, "fetch", "--unshallow", "origin", "+refs/heads/*:refs/remotes/origin/*")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git fetch --unshallow failed: %w", err)
+	}
+	if !hasCommit() {
+		return fmt.Errorf("commit %s not found in %s", commit, dir)
+	}
+	return nil
+}
+
+// checkWorkspaceRepo reports whether dir is a usable git working tree: git
+// must recognize it as the top of a repository and be able to read its
+// status (which fails on a missing or broken HEAD, index, or objects).
+// The workspace's config is the user's, so git runs as u (unless u is nil)
+// with fsmonitor and hooks disabled, so it can't run the user's commands as
+// root.
+func checkWorkspaceRepo(dir string, u *userInfo) error {
+	out, err := workspaceGitCommand(u, "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return fmt.Errorf("not a git repository: %w", err)
+	}
+	if top := strings.TrimSpace(string(out)); filepath.Clean(top) != filepath.Clean(dir) {
+		return fmt.Errorf("not a git repository (inside %s)", top)
+	}
+	if out, err := workspaceGitCommand(u, "-C", dir, "status", "--porcelain").CombinedOutput(); err != nil {
+		return fmt.Errorf("git status failed: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// workspaceGitCommand returns a git command reading a user workspace, run as
+// u when it isn't nil, with the config that would run commands (fsmonitor
+// and hooks) overridden.
+func workspaceGitCommand(u *userInfo, args ...string) *exec.Cmd {
+	args = append([]string{"-c", "core.fsmonitor=", "-c", "core.hooksPath=/dev/null"}, args...)
+	cmd := exec.Command("git", args...)
+	if u != nil {
+		cmd.Env = append(os.Environ(), "HOME="+u.homeDir)
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Credential: &syscall.Credential{
+				Uid:    uint32(u.uid),
+				Gid:    uint32(u.gid),
+				Groups: u.groups,
+			},
+		}
+	}
+	return cmd
+}
+
+// hasWorkingTreeFiles reports whether dir contains anything besides .git.
+// Git can't tell what's uncommitted in a corrupt repository, so any file
+// there is treated as possible user work.
+func hasWorkingTreeFiles(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if entry.Name() != ".git" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// repairWorkspace replaces a corrupt workspace (e.g. left by a boot that was
+// interrupted) with a fresh clone. If the old workspace has files that may be
+// user work, it is kept next to the workspace rather than deleted. If the
+// clone fails, the old workspace is left in place.
+func repairWorkspace(workspacePath, workspaceCommit string, u *userInfo) error {
+	hasFiles, err := hasWorkingTreeFiles(workspaceDir)
+	if err != nil {
+		return fmt.Errorf("failed to read corrupt workspace: %w", err)
+	}
+
+	if err := cloneWorkspaceToStaging(workspacePath, workspaceCommit, u); err != nil {
+		fmt.Printf("discobot-agent: WARNING: failed to re-clone corrupt workspace, keeping it: %v\n", err)
+		return nil
+	}
+
+	// Move the old workspace aside and the new one into place
+	backupDir := fmt.Sprintf("%s.corrupt-%d", workspaceDir, time.Now().Unix())
+	if err := os.Rename(workspaceDir, backupDir); err != nil {
+		return fmt.Errorf("failed to move corrupt workspace aside: %w", err)
+	}
 	if err := os.Rename(stagingDir, workspaceDir); err != nil {
+		// Put the old workspace back rather than leave none
+		_ = os.Rename(backupDir, workspaceDir)
 		return fmt.Errorf("failed to move staging to workspace: %w", err)
 	}
 
-	fmt.Printf("discobot-agent: workspace cloned successfully\n")
+	if hasFiles {
+		fmt.Printf("discobot-agent: workspace re-cloned; previous contents preserved at %s\n", backupDir)
+	} else {
+		if err := os.RemoveAll(backupDir); err != nil {
+			fmt.Printf("discobot-agent: warning: failed to remove corrupt workspace %s: %v\n", backupDir, err)
+		}
+		fmt.Printf("discobot-agent: workspace re-cloned\n")
+	}
 	return nil
 }
 
@@ -1016,40 +1397,113 @@ func mountOverlayFS(sessionID string) error {
 	// lowerdir = read-only base layer
 	// upperdir = writable layer for changes
 	// workdir = scratch space for overlayfs internal use
-	opts := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", baseHomeDir, upperDir, workDir)
+	baseOpts := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", baseHomeDir, upperDir, workDir)
+
+	// Append tuning options from OVERLAY_OPTIONS (e.g. metacopy=on)
+	opts := baseOpts
+	extra := overlayOptions()
+	if len(extra) > 0 {
+		opts += "," + strings.Join(extra, ",")
+	}
 
 	fmt.Printf("discobot-agent: mounting overlayfs at %s\n", mountHome)
 	fmt.Printf("discobot-agent: overlayfs options: %s\n", opts)
 
-	if err := syscall.Mount("overlay", mountHome, "overlay", 0, opts); err != nil {
+	err := syscall.Mount("overlay", mountHome, "overlay", 0, opts)
+	if err != nil && len(extra) > 0 {
+		// The kernel may reject options it supports in general but not for
+		// these directories (e.g. index=on with a different lower dir)
+		fmt.Printf("discobot-agent: warning: overlayfs mount with %s failed (%v), retrying without them\n", strings.Join(extra, ","), err)
+		opts = baseOpts
+		err = syscall.Mount("overlay", mountHome, "overlay", 0, opts)
+	}
+	if err != nil {
 		return fmt.Errorf("overlayfs mount failed: %w", err)
 	}
 
-	fmt.Printf("discobot-agent: overlayfs mounted successfully\n")
+	fmt.Printf("discobot-agent: overlayfs mounted successfully (effective options: %s)\n", opts)
 	return nil
 }
 
 // createWorkspaceSymlink creates /workspace -> /home/discobot/workspace symlink
 func createWorkspaceSymlink() error {
-	target := filepath.Join(mountHome, "workspace")
+	return createSymlink(symlinkPath, filepath.Join(mountHome, "workspace"))
+}
 
-	// Remove existing symlink or file if present
-	if _, err := os.Lstat(symlinkPath); err == nil {
-		if err := os.Remove(symlinkPath); err != nil {
-			return fmt.Errorf("failed to remove existing %s: %w", symlinkPath, err)
-		}
+// symlinkMu serializes createSymlink, which the periodic check and SIGUSR1
+// can both reach at once.
+var symlinkMu sync.Mutex
+
+// createSymlink creates path -> target, replacing any existing symlink, file
+// or empty directory. The new link is made beside path and renamed over it,
+// so path is swapped in one step and only the entry that was there before is
+// removed.
+func createSymlink(path, target string) error {
+	symlinkMu.Lock()
+	defer symlinkMu.Unlock()
+
+	tmp := path + ".new"
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale %s: %w", tmp, err)
 	}
 
-	fmt.Printf("discobot-agent: creating symlink %s -> %s\n", symlinkPath, target)
-	if err := os.Symlink(target, symlinkPath); err != nil {
+	fmt.Printf("discobot-agent: creating symlink %s -> %s\n", path, target)
+	if err := os.Symlink(target, tmp); err != nil {
 		return fmt.Errorf("failed to create symlink: %w", err)
 	}
+	err := os.Rename(tmp, path)
+	if err != nil {
+		// A directory can't be renamed over; remove it if it's empty and retry
+		if info, lerr := os.Lstat(path); lerr == nil && info.IsDir() {
+			if err = os.Remove(path); err == nil {
+				err = os.Rename(tmp, path)
+			}
+		}
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
 
 	return nil
 }
 
-// startDockerDaemon starts the Docker daemon if dockerd is available on PATH.
-// Returns the running command (for cleanup) or nil if Docker is not available.
+// repairSymlink re-creates path -> target if it is missing, replaced, or
+// points elsewhere. Returns true if a repair was made.
+func repairSymlink(path, target string) (bool, error) {
+	if current, err := os.Readlink(path); err == nil && current == target {
+		return false, nil
+	}
+	if err := createSymlink(path, target); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// repairWorkspaceSymlink verifies the /workspace symlink and re-creates it if
+// a user or cleanup script deleted or replaced it mid-session.
+func repairWorkspaceSymlink() {
+	repaired, err := repairSymlink(symlinkPath, filepath.Join(mountHome, "workspace"))
+	if err != nil {
+		fmt.Printf("discobot-agent: warning: failed to repair %s symlink: %v\n", symlinkPath, err)
+		return
+	}
+	if repaired {
+		fmt.Printf("discobot-agent: repaired broken %s symlink\n", symlinkPath)
+	}
+}
+
+// watchWorkspaceSymlink periodically verifies the /workspace symlink for the
+// lifetime of the agent. A repair can also be requested on demand by sending
+// SIGUSR1 to the agent.
+func watchWorkspaceSymlink() {
+	ticker := time.NewTicker(symlinkCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		repairWorkspaceSymlink()
+	}
+}
+
 // getProxyEnvVars returns the proxy environment variables if proxy is enabled.
 func getProxyEnvVars() []string {
 	proxyURL := fmt.Sprintf("http://localhost:%d", proxyPort)
@@ -1152,7 +1606,86 @@ export NODE_EXTRA_CA_CERTS=%s
 	return nil
 }
 
-func startDockerDaemon(proxyEnabled bool) (*exec.Cmd, error) {
+// proxyRequired reports whether the proxy must be running for the sandbox to start.
+// Controlled by the PROXY_REQUIRED environment variable (default: false).
+func proxyRequired() bool {
+	required, err := strconv.ParseBool(os.Getenv("PROXY_REQUIRED"))
+	return err == nil && required
+}
+
+// clearProxyFromProfile removes proxy settings written by setProxyInProfile or
+// setProxyInEtcProfile, so login shells don't route traffic to a proxy that isn't running.
+func clearProxyFromProfile() error {
+	profileScript := filepath.Join("/etc/profile.d", "discobot-proxy.sh")
+	if err := os.Remove(profileScript); err == nil {
+		fmt.Printf("discobot-agent: removed stale proxy settings from %s\n", profileScript)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", profileScript, err)
+	}
+
+	profilePath := "/etc/profile"
+	data, err := os.ReadFile(profilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %w", profilePath, err)
+	}
+
+	stripped, changed := stripProxyProfileBlock(string(data))
+	if !changed {
+		return nil
+	}
+	if err := os.WriteFile(profilePath, []byte(stripped), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", profilePath, err)
+	}
+
+	fmt.Printf("discobot-agent: removed stale proxy settings from %s\n", profilePath)
+	return nil
+}
+
+// stripProxyProfileBlock removes every proxy block appended by setProxyInEtcProfile,
+// including the blank lines that precede it. Returns the new content and whether
+// anything was removed.
+func stripProxyProfileBlock(content string) (string, bool) {
+	const (
+		blockStart = "# Discobot Proxy Configuration (added by discobot-agent)"
+		blockEnd   = "export NODE_EXTRA_CA_CERTS="
+	)
+
+	lines := strings.Split(content, "\n")
+	result := make([]string, 0, len(lines))
+	inBlock := false
+	changed := false
+
+	for _, line := range lines {
+		if inBlock {
+			if strings.HasPrefix(line, blockEnd) {
+				inBlock = false
+			}
+			continue
+		}
+		if line == blockStart {
+			inBlock = true
+			changed = true
+			// Drop the blank separator lines written before the block
+			for len(result) > 0 && result[len(result)-1] == "" {
+				result = result[:len(result)-1]
+			}
+			continue
+		}
+		result = append(result, line)
+	}
+
+	return strings.Join(result, "\n"), changed
+}
+
+// startDockerDaemon starts the Docker daemon if dockerd is available on PATH.
+// Returns the running command (for cleanup) or nil if Docker is not available.
+// When restricted is set, dockerd listens on a root-only socket and the
+// sandbox user reaches it through the filter started by serveDockerFilter,
+// so nested containers can't bypass the network mode.
+func startDockerDaemon(proxyEnabled, restricted bool) (*exec.Cmd, error) {
 	// Check if dockerd is on PATH
 	dockerdPath, err := exec.LookPath("dockerd")
 	if err != nil {
@@ -1193,6 +1726,12 @@ func startDockerDaemon(proxyEnabled bool) (*exec.Cmd, error) {
 	daemonConfig := map[string]interface{}{
 		"mtu": dockerMTU,
 	}
+	// Nested containers copy the sandbox's resolv.conf, which may point at a
+	// resolver they can't reach. DOCKER_DNS overrides it.
+	if dns := dockerDNSServers(); len(dns) > 0 {
+		daemonConfig["dns"] = dns
+		fmt.Printf("discobot-agent: configured Docker daemon with DNS servers %v\n", dns)
+	}
 	configBytes, err := json.MarshalIndent(daemonConfig, "", "  ")
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal daemon config: %w", err)
@@ -1210,10 +1749,18 @@ func startDockerDaemon(proxyEnabled bool) (*exec.Cmd, error) {
 		return nil, fmt.Errorf("failed to create docker data dir: %w", err)
 	}
 
+	daemonSocket := dockerSocketPath
+	if restricted {
+		daemonSocket = dockerdSocketPath
+		if err := os.MkdirAll(filepath.Dir(daemonSocket), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", filepath.Dir(daemonSocket), err)
+		}
+	}
+
 	cmd := exec.Command(dockerdPath,
 		"--data-root", dockerDataDir,
 		"--storage-driver", "overlay2",
-		"--host", "unix://"+dockerSocketPath,
+		"--host", "unix://"+daemonSocket,
 		"--log-level", "error",
 	)
 	cmd.Stdout = os.Stdout
@@ -1233,14 +1780,21 @@ func startDockerDaemon(proxyEnabled bool) (*exec.Cmd, error) {
 	fmt.Printf("discobot-agent: dockerd started (pid=%d), waiting for socket...\n", cmd.Process.Pid)
 
 	// Wait for the Docker socket to become available
-	if err := waitForDockerSocket(); err != nil {
+	if err := waitForDockerSocket(daemonSocket); err != nil {
 		// Kill dockerd if socket never appeared
 		_ = cmd.Process.Kill()
 		return nil, fmt.Errorf("docker socket did not become available: %w", err)
 	}
 
-	// Make the socket world-readable and writable
-	if err := os.Chmod(dockerSocketPath, 0666); err != nil {
+	if restricted {
+		// The filter's socket is world-writable; dockerd's stays root-only
+		if err := serveDockerFilter(dockerSocketPath, daemonSocket); err != nil {
+			_ = cmd.Process.Kill()
+			return nil, err
+		}
+		fmt.Printf("discobot-agent: docker API filtered for the network mode at %s\n", dockerSocketPath)
+	} else if err := os.Chmod(dockerSocketPath, 0666); err != nil {
+		// Make the socket world-readable and writable
 		fmt.Printf("discobot-agent: warning: failed to chmod docker socket: %v\n", err)
 	} else {
 		fmt.Printf("discobot-agent: docker socket permissions set to 0666\n")
@@ -1250,16 +1804,16 @@ func startDockerDaemon(proxyEnabled bool) (*exec.Cmd, error) {
 	return cmd, nil
 }
 
-// waitForDockerSocket waits for the Docker socket to become available.
-func waitForDockerSocket() error {
+// waitForDockerSocket waits for the Docker socket at path to become available.
+func waitForDockerSocket(path string) error {
 	deadline := time.Now().Add(dockerStartupTimeout)
 
 	for time.Now().Before(deadline) {
 		// Check if socket exists
-		info, err := os.Stat(dockerSocketPath)
+		info, err := os.Stat(path)
 		if err == nil && info.Mode()&os.ModeSocket != 0 {
 			// Socket exists, try to connect to verify it's ready
-			conn, err := net.DialTimeout("unix", dockerSocketPath, 2*time.Second)
+			conn, err := net.DialTimeout("unix", path, 2*time.Second)
 			if err == nil {
 				_ = conn.Close()
 				return nil
@@ -1268,7 +1822,7 @@ func waitForDockerSocket() error {
 		time.Sleep(500 * time.Millisecond)
 	}
 
-	return fmt.Errorf("timeout waiting for docker socket at %s", dockerSocketPath)
+	return fmt.Errorf("timeout waiting for docker socket at %s", path)
 }
 
 // startProxyDaemon starts the HTTP proxy if the binary is available.
@@ -1357,6 +1911,8 @@ func waitForProxyReady() error {
 
 // setupProxyCertificate generates a CA certificate for the proxy and installs it in the system trust store.
 // The certificate is stored in /.data/proxy/certs/ (session-scoped) and will be used by the proxy for HTTPS MITM.
+// An existing certificate is reused only if its key is present and matches; otherwise (or when
+// DISCOBOT_PROXY_CA_ROTATE=true) both are regenerated.
 func setupProxyCertificate() error {
 	certDir := filepath.Join(dataDir, "proxy", "certs")
 	certPath := filepath.Join(certDir, "ca.crt")
@@ -1367,11 +1923,20 @@ func setupProxyCertificate() error {
 		return fmt.Errorf("failed to create cert dir: %w", err)
 	}
 
-	// Check if certificate already exists
-	if _, err := os.Stat(certPath); err == nil {
-		fmt.Printf("discobot-agent: proxy CA certificate already exists at %s\n", certPath)
-		// Certificate exists, ensure it's installed in system trust store
-		return installCertificateInSystemTrust(certPath)
+	if os.Getenv("DISCOBOT_PROXY_CA_ROTATE") == "true" {
+		fmt.Printf("discobot-agent: DISCOBOT_PROXY_CA_ROTATE set, regenerating proxy CA certificate\n")
+	} else if _, err := os.Stat(certPath); err == nil {
+		err := checkCAKeyPair(certPath, keyPath)
+		if err == nil {
+			fmt.Printf("discobot-agent: proxy CA certificate already exists at %s\n", certPath)
+			// The key may have been written with looser permissions by an older agent
+			if err := os.Chmod(keyPath, 0600); err != nil {
+				return fmt.Errorf("failed to restrict CA key permissions: %w", err)
+			}
+			// Certificate exists, ensure it's installed in system trust store
+			return installCertificateInSystemTrust(certPath)
+		}
+		fmt.Printf("discobot-agent: warning: existing proxy CA is unusable, regenerating: %v\n", err)
 	}
 
 	fmt.Printf("discobot-agent: generating proxy CA certificate...\n")
@@ -1389,8 +1954,49 @@ func setupProxyCertificate() error {
 	return installCertificateInSystemTrust(certPath)
 }
 
+// checkCAKeyPair verifies that certPath holds a CA certificate and keyPath
+// the private key for it, so a pair left inconsistent by a partial write (or
+// a missing key) isn't reused.
+func checkCAKeyPair(certPath, keyPath string) error {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return fmt.Errorf("read certificate: %w", err)
+	}
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil || certBlock.Type != "CERTIFICATE" {
+		return fmt.Errorf("%s does not contain a PEM certificate", certPath)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return fmt.Errorf("parse certificate: %w", err)
+	}
+	if !cert.IsCA {
+		return fmt.Errorf("%s is not a CA certificate", certPath)
+	}
+
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("read private key: %w", err)
+	}
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil || keyBlock.Type != "RSA PRIVATE KEY" {
+		return fmt.Errorf("%s does not contain a PEM RSA private key", keyPath)
+	}
+	key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return fmt.Errorf("parse private key: %w", err)
+	}
+
+	if !key.PublicKey.Equal(cert.PublicKey) {
+		return fmt.Errorf("private key does not match certificate")
+	}
+	return nil
+}
+
 // generateCACertificate creates a CA certificate and private key using Go crypto libraries.
 // Includes localhost in SANs for proper HTTPS interception.
+// Each file is written atomically (write-to-temp + rename), key first, so an
+// interrupted write never leaves a truncated certificate or key behind.
 func generateCACertificate(certPath, keyPath string) error {
 	// Generate RSA private key (2048-bit)
 	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
@@ -1431,30 +2037,47 @@ func generateCACertificate(certPath, keyPath string) error {
 		return fmt.Errorf("create certificate: %w", err)
 	}
 
-	// Save certificate (PEM format)
-	certFile, err := os.OpenFile(certPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		return fmt.Errorf("create cert file: %w", err)
+	// Save private key (PEM format)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	if err := writeFileAtomic(keyPath, keyPEM, 0600); err != nil {
+		return fmt.Errorf("write private key: %w", err)
 	}
-	defer func() { _ = certFile.Close() }()
 
-	if err := pem.Encode(certFile, &pem.Block{Type: "CERTIFICATE", Bytes: certDER}); err != nil {
-		return fmt.Errorf("encode certificate: %w", err)
+	// Save certificate (PEM format)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	if err := writeFileAtomic(certPath, certPEM, 0644); err != nil {
+		return fmt.Errorf("write certificate: %w", err)
 	}
 
-	// Save private key (PEM format)
-	keyFile, err := os.OpenFile(keyPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file with the given permissions,
+// syncs it, and renames it over path.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmpPath := path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
 	if err != nil {
-		return fmt.Errorf("create key file: %w", err)
+		return err
 	}
-	defer func() { _ = keyFile.Close() }()
-
-	keyDER := x509.MarshalPKCS1PrivateKey(privateKey)
-	if err := pem.Encode(keyFile, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: keyDER}); err != nil {
-		return fmt.Errorf("encode private key: %w", err)
+	// OpenFile only applies perm to new files, and is subject to the umask
+	if err := f.Chmod(perm); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmpPath)
+		return err
 	}
-
-	return nil
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
 }
 
 // installCertificateInSystemTrust installs the CA certificate in the system trust store.
@@ -1550,9 +2173,11 @@ func installCertFedoraStyle(certPath string) error {
 	return nil
 }
 
-// setupProxyConfig configures the proxy using embedded defaults only.
+// setupProxyConfig writes the proxy config: the server-provided PROXY_CONFIG
+// (base64-encoded YAML set by the operator) if present, else embedded defaults.
 // Note: Reading workspace config would be a security risk since untrusted code could be executed
-// before the sandbox is fully set up. The proxy always uses safe, built-in defaults.
+// before the sandbox is fully set up. The server config comes from the sandbox's
+// environment, which workspace code can't change.
 func setupProxyConfig(userInfo *userInfo) error {
 	proxyDataDir := filepath.Join(dataDir, "proxy")
 	configDest := filepath.Join(proxyDataDir, "config.yaml")
@@ -1562,14 +2187,45 @@ func setupProxyConfig(userInfo *userInfo) error {
 		return fmt.Errorf("failed to create proxy data dir: %w", err)
 	}
 
-	// Always use built-in defaults (with Docker caching enabled)
-	// Security: Never read workspace config during init as it's untrusted code
-	fmt.Printf("discobot-agent: using default proxy config with Docker caching enabled\n")
+	config, err := proxyConfigData(os.Getenv("PROXY_CONFIG"))
+	if err != nil {
+		return err
+	}
+
+	// Set by the server for workspaces that opt out of caching. Filtering
+	// and header injection are unaffected.
+	if os.Getenv("PROXY_CACHE_DISABLED") == "true" {
+		config, err = disableProxyCache(config)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("discobot-agent: proxy response caching disabled\n")
+	}
+
+	// Set by the server to chain the proxy through an upstream proxy. Its
+	// credentials come from a root-only file, not the environment every exec
+	// in the container inherits, and only ever land in the root-only config.
+	if upstream := os.Getenv("PROXY_UPSTREAM"); upstream != "" {
+		upstream, err = proxyUpstreamWithAuth(upstream, proxyUpstreamAuthFile)
+		if err != nil {
+			return err
+		}
+		config, err = setProxyUpstream(config, upstream, os.Getenv("PROXY_UPSTREAM_NO_PROXY"))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("discobot-agent: proxy chained through an upstream proxy\n")
+	}
 
-	// Write config with restrictive permissions (0644) and keep as root-owned
-	// This prevents the discobot user from modifying the proxy configuration
-	if err := os.WriteFile(configDest, defaultProxyConfig, 0644); err != nil {
-		return fmt.Errorf("failed to write default proxy config: %w", err)
+	// Write config root-owned and readable only by root (the proxy runs as
+	// root). This prevents the discobot user from modifying the proxy
+	// configuration or reading credentials in it.
+	if err := os.WriteFile(configDest, config, 0600); err != nil {
+		return fmt.Errorf("failed to write proxy config: %w", err)
+	}
+	// WriteFile keeps the mode of an existing file, e.g. from an older agent
+	if err := os.Chmod(configDest, 0600); err != nil {
+		return fmt.Errorf("failed to restrict proxy config: %w", err)
 	}
 
 	// Config remains root-owned for security (no chown needed)
@@ -1577,6 +2233,138 @@ func setupProxyConfig(userInfo *userInfo) error {
 	return nil
 }
 
+// proxyConfigData returns the proxy config to write: the decoded server-provided
+// config if encoded is set, otherwise the built-in defaults (with Docker caching enabled).
+func proxyConfigData(encoded string) ([]byte, error) {
+	if encoded == "" {
+		fmt.Printf("discobot-agent: using default proxy config with Docker caching enabled\n")
+		return defaultProxyConfig, nil
+	}
+
+	config, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROXY_CONFIG: %w", err)
+	}
+	if len(bytes.TrimSpace(config)) == 0 {
+		return nil, fmt.Errorf("invalid PROXY_CONFIG: empty config")
+	}
+	fmt.Printf("discobot-agent: using server-provided proxy config (%d bytes)\n", len(config))
+	return config, nil
+}
+
+// disableProxyCache returns config with cache.enabled set to false, keeping
+// the rest of the config (and its comments) as is. A config without a cache
+// section is returned unchanged, since caching is off by default.
+func disableProxyCache(config []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(config, &doc); err != nil {
+		return nil, fmt.Errorf("invalid proxy config: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid proxy config: expected a mapping")
+	}
+
+	cache := yamlMappingValue(doc.Content[0], "cache")
+	if cache == nil || cache.Kind != yaml.MappingNode {
+		return config, nil
+	}
+	disabled := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: "false"}
+	if enabled := yamlMappingValue(cache, "enabled"); enabled != nil {
+		*enabled = *disabled
+	} else {
+		cache.Content = append(cache.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "enabled"}, disabled)
+	}
+
+	return yaml.Marshal(&doc)
+}
+
+// proxyUpstreamWithAuth returns rawURL with the user:password in authFile as
+// its credentials, or rawURL unchanged if the file is missing or empty.
+func proxyUpstreamWithAuth(rawURL, authFile string) (string, error) {
+	data, err := os.ReadFile(authFile)
+	if os.IsNotExist(err) {
+		return rawURL, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read proxy upstream credentials: %w", err)
+	}
+	auth := strings.TrimRight(string(data), "\r\n")
+	if auth == "" {
+		return rawURL, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid PROXY_UPSTREAM: expected a URL like http://host:port")
+	}
+	username, password, hasPassword := strings.Cut(auth, ":")
+	if username == "" {
+		return "", fmt.Errorf("invalid proxy upstream credentials in %s: expected user:password", authFile)
+	}
+	if hasPassword {
+		u.User = url.UserPassword(username, password)
+	} else {
+		u.User = url.User(username)
+	}
+	return u.String(), nil
+}
+
+// setProxyUpstream returns config with proxy.upstream set to rawURL and the
+// comma-separated noProxy hosts, replacing any upstream already configured.
+func setProxyUpstream(config []byte, rawURL, noProxy string) ([]byte, error) {
+	if u, err := url.Parse(rawURL); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid PROXY_UPSTREAM: expected a URL like http://host:port")
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(config, &doc); err != nil {
+		return nil, fmt.Errorf("invalid proxy config: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid proxy config: expected a mapping")
+	}
+
+	upstream := struct {
+		URL     string   `yaml:"url"`
+		NoProxy []string `yaml:"no_proxy,omitempty"`
+	}{URL: rawURL}
+	for host := range strings.SplitSeq(noProxy, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			upstream.NoProxy = append(upstream.NoProxy, host)
+		}
+	}
+	var value yaml.Node
+	if err := value.Encode(upstream); err != nil {
+		return nil, err
+	}
+
+	root := doc.Content[0]
+	proxy := yamlMappingValue(root, "proxy")
+	if proxy == nil || proxy.Kind != yaml.MappingNode {
+		if proxy != nil {
+			return nil, fmt.Errorf("invalid proxy config: proxy is not a mapping")
+		}
+		proxy = &yaml.Node{Kind: yaml.MappingNode}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "proxy"}, proxy)
+	}
+	if existing := yamlMappingValue(proxy, "upstream"); existing != nil {
+		*existing = value
+	} else {
+		proxy.Content = append(proxy.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "upstream"}, &value)
+	}
+
+	return yaml.Marshal(&doc)
+}
+
+// yamlMappingValue returns the value node for key in a YAML mapping, or nil.
+func yamlMappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
 // runAgent starts the agent API process and manages its lifecycle
 func runAgent(agentBinary string, u *userInfo, dockerCmd, proxyCmd *exec.Cmd) error {
 	// Check if we're running as PID 1
@@ -1617,7 +2405,7 @@ func runAgent(agentBinary string, u *userInfo, dockerCmd, proxyCmd *exec.Cmd) er
 
 	// Set up signal handling
 	signals := make(chan os.Signal, 10)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP, syscall.SIGUSR1)
 
 	// If running as PID 1, also handle SIGCHLD for process reaping
 	if isPID1 {
@@ -1678,6 +2466,10 @@ func eventLoop(cmd *exec.Cmd, dockerCmd, proxyCmd *exec.Cmd, signals chan os.Sig
 				if cmd.Process != nil {
 					_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGHUP)
 				}
+
+			case syscall.SIGUSR1:
+				// On-demand repair of the /workspace symlink
+				repairWorkspaceSymlink()
 			}
 
 		case err := <-childDone:
@@ -1827,6 +2619,10 @@ func buildChildEnv(u *userInfo, proxyEnabled bool) []string {
 		"HOME":    true,
 		"USER":    true,
 		"LOGNAME": true,
+		// Already written to the proxy config; no need to expose it to the agent
+		"PROXY_CONFIG":            true,
+		"PROXY_UPSTREAM":          true,
+		"PROXY_UPSTREAM_NO_PROXY": true,
 	}
 
 	for _, e := range parentEnv {
@@ -1846,6 +2642,13 @@ func buildChildEnv(u *userInfo, proxyEnabled bool) []string {
 	// Enable hooks in the agent-api (only in container context)
 	env = append(env, "DISCOBOT_HOOKS_ENABLED=true")
 
+	// Persist the agent-api key-value store and partial uploads outside the home overlay
+	env = append(env, "DISCOBOT_KV_DIR="+kvDir)
+	env = append(env, "DISCOBOT_UPLOADS_DIR="+uploadsDir)
+
+	// Write registry credential files to the tmpfs, not the home overlay
+	env = append(env, "DISCOBOT_CREDENTIALS_DIR="+credentialsDir)
+
 	// Add proxy environment variables if proxy is running
 	if proxyEnabled {
 		env = append(env, getProxyEnvVars()...)
@@ -2013,7 +2816,14 @@ func mountCacheDirectories() error {
 	// Get all cache paths
 	cachePaths := getAllCachePaths(cfg)
 
-	mounted := 0
+	// Keep the shared volume bounded before this sandbox starts adding to it
+	if policy, ok := loadCachePolicy(); ok {
+		if err := evictCache(cacheVolumeBase, policy, time.Now()); err != nil {
+			fmt.Printf("discobot-agent: warning: cache eviction failed: %v\n", err)
+		}
+	}
+
+	var mountedSubDirs []string
 	for _, cachePath := range cachePaths {
 		// Clean the path to create a safe subdirectory name in the cache volume
 		// e.g., "/home/discobot/.npm" -> "home/discobot/.npm"
@@ -2048,11 +2858,17 @@ func mountCacheDirectories() error {
 			continue
 		}
 
-		mounted++
+		mountedSubDirs = append(mountedSubDirs, subDir)
 	}
 
-	if mounted > 0 {
-		fmt.Printf("discobot-agent: mounted %d cache directories\n", mounted)
+	if len(mountedSubDirs) > 0 {
+		fmt.Printf("discobot-agent: mounted %d cache directories\n", len(mountedSubDirs))
+
+		// Record use for LRU eviction, and keep refreshing it while we run
+		if err := recordCacheUse(cacheVolumeBase, mountedSubDirs, time.Now()); err != nil {
+			fmt.Printf("discobot-agent: warning: failed to record cache use: %v\n", err)
+		}
+		go touchCacheDirectories(cacheVolumeBase, mountedSubDirs)
 	}
 
 	return nil
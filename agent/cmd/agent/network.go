@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Network modes, set by the server via the NETWORK_MODE environment variable.
+const (
+	networkModeProxied  = "proxied"  // Outbound only through the proxy (default)
+	networkModeIsolated = "isolated" // No outbound network access
+	networkModeOpen     = "open"     // Unrestricted outbound access
+)
+
+// egressChain is the iptables chain holding the sandbox's egress rules.
+const egressChain = "DISCOBOT-EGRESS"
+
+// networkMode returns the sandbox network mode from NETWORK_MODE (default: proxied).
+func networkMode() (string, error) {
+	mode := strings.TrimSpace(os.Getenv("NETWORK_MODE"))
+	switch mode {
+	case "":
+		return networkModeProxied, nil
+	case networkModeProxied, networkModeIsolated, networkModeOpen:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid NETWORK_MODE %q (must be proxied, isolated, or open)", mode)
+	}
+}
+
+// egressRules returns the rules appended to egressChain for the given mode.
+// Loopback and local Docker bridges stay reachable, replies to inbound
+// connections (such as the server calling the agent API) are allowed, and
+// everything else is rejected. In proxied mode root-owned processes (the proxy
+// and dockerd) may still connect out, while the discobot user can't bypass
+// the proxy by unsetting HTTP_PROXY.
+func egressRules(mode string) [][]string {
+	rules := [][]string{
+		{"-o", "lo", "-j", "RETURN"},
+		{"-o", "docker+", "-j", "RETURN"},
+		{"-o", "br-+", "-j", "RETURN"},
+		{"-m", "conntrack", "--ctstate", "ESTABLISHED,RELATED", "-j", "RETURN"},
+	}
+	if mode == networkModeProxied {
+		rules = append(rules, []string{"-m", "owner", "--uid-owner", "0", "-j", "RETURN"})
+	}
+	return append(rules, []string{"-j", "REJECT"})
+}
+
+// forwardRule returns the DOCKER-USER rule that stops nested containers from
+// opening connections to the outside network directly. Nested containers
+// still reach the proxy, since that traffic is delivered locally.
+func forwardRule() []string {
+	return []string{"-o", "eth+", "-m", "conntrack", "--ctstate", "NEW", "-j", "REJECT"}
+}
+
+// applyNetworkMode installs firewall rules enforcing mode. The IPv4 rules must
+// apply; IPv6 rules are best-effort since many sandboxes have IPv6 disabled.
+func applyNetworkMode(mode string, dockerEnabled bool) error {
+	if mode == networkModeOpen {
+		fmt.Printf("discobot-agent: network mode open, outbound access unrestricted\n")
+		return nil
+	}
+
+	if err := applyEgressFirewall("iptables", mode, dockerEnabled); err != nil {
+		return err
+	}
+	if err := applyEgressFirewall("ip6tables", mode, dockerEnabled); err != nil {
+		fmt.Printf("discobot-agent: warning: failed to apply IPv6 egress rules: %v\n", err)
+	}
+
+	fmt.Printf("discobot-agent: network mode %s enforced\n", mode)
+	return nil
+}
+
+// applyEgressFirewall installs the egress chain with the given iptables binary.
+// It is idempotent: the chain is flushed and rebuilt on every call.
+func applyEgressFirewall(bin, mode string, dockerEnabled bool) error {
+	if _, err := exec.LookPath(bin); err != nil {
+		return fmt.Errorf("%s not found: %w", bin, err)
+	}
+
+	// Create the chain, ignoring the error if it already exists
+	_ = runIPTables(bin, "-N", egressChain)
+	if err := runIPTables(bin, "-F", egressChain); err != nil {
+		return err
+	}
+	for _, rule := range egressRules(mode) {
+		if err := runIPTables(bin, append([]string{"-A", egressChain}, rule...)...); err != nil {
+			return err
+		}
+	}
+	if err := ensureRule(bin, "OUTPUT", "-j", egressChain); err != nil {
+		return err
+	}
+
+	// DOCKER-USER only exists once dockerd has started
+	if dockerEnabled {
+		if err := ensureRule(bin, "DOCKER-USER", forwardRule()...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureRule inserts rule at the top of chain unless it is already present.
+func ensureRule(bin, chain string, rule ...string) error {
+	if runIPTables(bin, append([]string{"-C", chain}, rule...)...) == nil {
+		return nil
+	}
+	return runIPTables(bin, append([]string{"-I", chain, "1"}, rule...)...)
+}
+
+func runIPTables(bin string, args ...string) error {
+	cmd := exec.Command(bin, append([]string{"-w"}, args...)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %s: %w (output: %s)", bin, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
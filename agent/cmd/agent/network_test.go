@@ -0,0 +1,52 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNetworkMode(t *testing.T) {
+	tests := map[string]struct {
+		want    string
+		wantErr bool
+	}{
+		"":         {want: networkModeProxied},
+		"proxied":  {want: networkModeProxied},
+		"isolated": {want: networkModeIsolated},
+		"open":     {want: networkModeOpen},
+		"none":     {wantErr: true},
+	}
+	for value, tt := range tests {
+		t.Setenv("NETWORK_MODE", value)
+		got, err := networkMode()
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("networkMode() with %q = %q, %v; want %q, error %v", value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestEgressRules(t *testing.T) {
+	allowsRoot := func(rules [][]string) bool {
+		return slices.ContainsFunc(rules, func(rule []string) bool {
+			return slices.Contains(rule, "--uid-owner")
+		})
+	}
+
+	for _, mode := range []string{networkModeProxied, networkModeIsolated} {
+		rules := egressRules(mode)
+		if first := strings.Join(rules[0], " "); first != "-o lo -j RETURN" {
+			t.Errorf("%s: expected loopback to be allowed first, got %q", mode, first)
+		}
+		if last := strings.Join(rules[len(rules)-1], " "); last != "-j REJECT" {
+			t.Errorf("%s: expected final reject rule, got %q", mode, last)
+		}
+	}
+
+	if !allowsRoot(egressRules(networkModeProxied)) {
+		t.Error("proxied mode should allow root-owned traffic for the proxy")
+	}
+	if allowsRoot(egressRules(networkModeIsolated)) {
+		t.Error("isolated mode should not allow root-owned traffic")
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// raiseNoFileLimit raises the soft open file limit (RLIMIT_NOFILE) to
+// NOFILE_LIMIT, capped at the hard limit, so the Docker daemon, the agent
+// API, and the tools it runs inherit it. Long sessions with tools that leak
+// file descriptors otherwise run into the default soft limit (often 1024)
+// long before the hard one. The limit is never lowered.
+func raiseNoFileLimit() error {
+	raw := os.Getenv("NOFILE_LIMIT")
+	if raw == "" {
+		return nil
+	}
+	want, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || want == 0 {
+		return fmt.Errorf("invalid NOFILE_LIMIT %q", raw)
+	}
+
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return fmt.Errorf("failed to get open file limit: %w", err)
+	}
+	if want > limit.Max {
+		fmt.Printf("discobot-agent: warning: NOFILE_LIMIT %d is above the hard limit, using %d\n", want, limit.Max)
+		want = limit.Max
+	}
+	if want <= limit.Cur {
+		return nil
+	}
+
+	limit.Cur = want
+	if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+		return fmt.Errorf("failed to set open file limit: %w", err)
+	}
+	fmt.Printf("discobot-agent: raised open file limit to %d (hard limit %d)\n", limit.Cur, limit.Max)
+	return nil
+}
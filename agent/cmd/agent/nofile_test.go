@@ -0,0 +1,46 @@
+package main
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestRaiseNoFileLimit(t *testing.T) {
+	var before syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &before); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = syscall.Setrlimit(syscall.RLIMIT_NOFILE, &before) })
+
+	for _, bad := range []string{"0", "bogus", "-1"} {
+		t.Setenv("NOFILE_LIMIT", bad)
+		if err := raiseNoFileLimit(); err == nil {
+			t.Errorf("NOFILE_LIMIT=%q: expected an error", bad)
+		}
+	}
+
+	// Requests above the hard limit are capped at it
+	t.Setenv("NOFILE_LIMIT", "18446744073709551615")
+	if err := raiseNoFileLimit(); err != nil {
+		t.Fatal(err)
+	}
+	var after syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.Cur != before.Max || after.Max != before.Max {
+		t.Errorf("got soft %d hard %d, want both %d", after.Cur, after.Max, before.Max)
+	}
+
+	// Lower requests leave the limit alone
+	t.Setenv("NOFILE_LIMIT", "1")
+	if err := raiseNoFileLimit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.Cur != before.Max {
+		t.Errorf("limit was lowered to %d", after.Cur)
+	}
+}
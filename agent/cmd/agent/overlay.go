@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// kernelVersion is a kernel major.minor version.
+type kernelVersion struct {
+	major, minor int
+}
+
+func (v kernelVersion) atLeast(other kernelVersion) bool {
+	return v.major > other.major || (v.major == other.major && v.minor >= other.minor)
+}
+
+func (v kernelVersion) String() string {
+	return fmt.Sprintf("%d.%d", v.major, v.minor)
+}
+
+// overlayOptionKernels lists the kernel that introduced each feature-gated
+// overlayfs mount option. Options not listed are passed to the kernel as-is.
+var overlayOptionKernels = map[string]kernelVersion{
+	"redirect_dir": {4, 10},
+	"index":        {4, 13},
+	"nfs_export":   {4, 16},
+	"xino":         {4, 17},
+	"metacopy":     {4, 19},
+	"volatile":     {5, 10},
+	"userxattr":    {5, 11},
+}
+
+// reservedOverlayOptions are set by the agent and can't be overridden.
+var reservedOverlayOptions = map[string]bool{
+	"lowerdir": true,
+	"upperdir": true,
+	"workdir":  true,
+}
+
+// overlayOptionPattern matches a single "name" or "name=value" option.
+var overlayOptionPattern = regexp.MustCompile(`^[a-z_]+(=[A-Za-z0-9_]+)?$`)
+
+// overlayOptions returns the extra overlayfs mount options from
+// OVERLAY_OPTIONS (comma-separated, e.g. "metacopy=on,redirect_dir=on"),
+// gated on the running kernel.
+func overlayOptions() []string {
+	raw := strings.TrimSpace(os.Getenv("OVERLAY_OPTIONS"))
+	if raw == "" {
+		return nil
+	}
+	kernel, ok := runningKernelVersion()
+	if !ok {
+		fmt.Printf("discobot-agent: warning: could not determine kernel version, not checking overlayfs options\n")
+	}
+	return filterOverlayOptions(raw, kernel, ok)
+}
+
+// filterOverlayOptions parses a comma-separated option list and drops
+// malformed, reserved, duplicate, and (if the kernel is known) unsupported
+// options, logging each one dropped.
+func filterOverlayOptions(raw string, kernel kernelVersion, kernelKnown bool) []string {
+	var opts []string
+	seen := make(map[string]bool)
+	for _, opt := range strings.Split(raw, ",") {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(opt, "=")
+		switch {
+		case !overlayOptionPattern.MatchString(opt):
+			fmt.Printf("discobot-agent: warning: ignoring malformed overlayfs option %q\n", opt)
+		case reservedOverlayOptions[name]:
+			fmt.Printf("discobot-agent: warning: ignoring overlayfs option %q (set by the agent)\n", opt)
+		case seen[name]:
+			fmt.Printf("discobot-agent: warning: ignoring duplicate overlayfs option %q\n", opt)
+		default:
+			if minKernel, gated := overlayOptionKernels[name]; gated && kernelKnown && !kernel.atLeast(minKernel) {
+				fmt.Printf("discobot-agent: warning: ignoring overlayfs option %q (needs kernel %s, running %s)\n", opt, minKernel, kernel)
+				continue
+			}
+			seen[name] = true
+			opts = append(opts, opt)
+		}
+	}
+	return opts
+}
+
+// runningKernelVersion returns the running kernel's major.minor version.
+func runningKernelVersion() (kernelVersion, bool) {
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return kernelVersion{}, false
+	}
+	return parseKernelVersion(string(release))
+}
+
+// parseKernelVersion parses the major.minor prefix of a kernel release
+// string such as "6.8.0-45-generic".
+func parseKernelVersion(release string) (kernelVersion, bool) {
+	parts := strings.SplitN(strings.TrimSpace(release), ".", 3)
+	if len(parts) < 2 {
+		return kernelVersion{}, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return kernelVersion{}, false
+	}
+	// The minor version may run straight into a suffix ("15-rc1")
+	minorDigits := parts[1]
+	if i := strings.IndexFunc(minorDigits, func(r rune) bool { return r < '0' || r > '9' }); i != -1 {
+		minorDigits = minorDigits[:i]
+	}
+	minor, err := strconv.Atoi(minorDigits)
+	if err != nil {
+		return kernelVersion{}, false
+	}
+	return kernelVersion{major, minor}, true
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseKernelVersion(t *testing.T) {
+	tests := []struct {
+		release string
+		want    kernelVersion
+		ok      bool
+	}{
+		{"6.8.0-45-generic\n", kernelVersion{6, 8}, true},
+		{"5.15.153.1-microsoft-standard-WSL2", kernelVersion{5, 15}, true},
+		{"4.19-rc1", kernelVersion{4, 19}, true},
+		{"6", kernelVersion{}, false},
+		{"linux", kernelVersion{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseKernelVersion(tt.release)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseKernelVersion(%q) = %v, %v; want %v, %v", tt.release, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFilterOverlayOptions(t *testing.T) {
+	raw := "metacopy=on, redirect_dir=on,volatile,workdir=/tmp,bad option,metacopy=off,userxattr"
+
+	got := filterOverlayOptions(raw, kernelVersion{5, 10}, true)
+	want := []string{"metacopy=on", "redirect_dir=on", "volatile"}
+	if !slices.Equal(got, want) {
+		t.Errorf("on 5.10: got %v, want %v", got, want)
+	}
+
+	got = filterOverlayOptions(raw, kernelVersion{4, 15}, true)
+	want = []string{"redirect_dir=on"}
+	if !slices.Equal(got, want) {
+		t.Errorf("on 4.15: got %v, want %v", got, want)
+	}
+
+	// Unknown kernel: pass everything well-formed through and let the mount decide
+	got = filterOverlayOptions(raw, kernelVersion{}, false)
+	want = []string{"metacopy=on", "redirect_dir=on", "volatile", "userxattr"}
+	if !slices.Equal(got, want) {
+		t.Errorf("unknown kernel: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// homeResetMarker requests that the session's home directory changes be
+// discarded on the next boot. The server creates it (POST .../reset) and then
+// restarts the sandbox. It lives on the data volume, outside the home overlay.
+const homeResetMarker = "/.data/.reset-home"
+
+// resetKeepEntries are upper layer entries kept by a home reset, so the
+// session's workspace (including uncommitted changes) survives it.
+var resetKeepEntries = map[string]bool{"workspace": true}
+
+// resetHomeIfRequested discards the session's home directory changes if a
+// reset was requested, so /home/discobot is back to the base home when the
+// filesystem is mounted. It must run before the overlay is mounted. The
+// workspace, caches, and KV store are kept. The marker is removed only once
+// the reset succeeds, so a failed reset is retried on the next boot.
+//
+// Sessions still on agentfs keep their changes, workspace included, in a
+// database that can't be partially reset. Their reset is deferred until
+// migrateAgentFSToOverlayFS has copied them to overlayfs; see
+// resetMigratedHomeIfRequested.
+func resetHomeIfRequested(sessionID string) error {
+	requested, err := homeResetRequested()
+	if err != nil || !requested {
+		return err
+	}
+	if agentFSMigrationPending(sessionID) {
+		fmt.Printf("discobot-agent: home reset requested, deferring until the agentfs session is migrated\n")
+		return nil
+	}
+	return resetHome(sessionID)
+}
+
+// resetMigratedHomeIfRequested performs a reset deferred by
+// resetHomeIfRequested, once an agentfs session has been migrated and its
+// overlay mounted. The overlay is unmounted while its upper layer is cleared
+// and then mounted again. The agentfs database itself is kept as the
+// migration's backup.
+func resetMigratedHomeIfRequested(sessionID string) error {
+	requested, err := homeResetRequested()
+	if err != nil || !requested {
+		return err
+	}
+	if err := syscall.Unmount(mountHome, 0); err != nil {
+		return fmt.Errorf("failed to unmount overlayfs for reset: %w", err)
+	}
+	if err := resetHome(sessionID); err != nil {
+		return err
+	}
+	return mountOverlayFS(sessionID)
+}
+
+// homeResetRequested reports whether the home reset marker exists.
+func homeResetRequested() (bool, error) {
+	if _, err := os.Stat(homeResetMarker); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check reset marker: %w", err)
+	}
+	return true, nil
+}
+
+// agentFSMigrationPending reports whether the session has an agentfs database
+// that hasn't been migrated to overlayfs yet.
+func agentFSMigrationPending(sessionID string) bool {
+	if _, err := os.Stat(filepath.Join(overlayFSDir, sessionID, ".migrated")); err == nil {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(agentFSDir, sessionID+".db"))
+	return err == nil
+}
+
+// resetHome empties the session's overlay and removes the reset marker.
+func resetHome(sessionID string) error {
+	fmt.Printf("discobot-agent: home reset requested, discarding session changes\n")
+	if err := resetOverlayDirs(filepath.Join(overlayFSDir, sessionID)); err != nil {
+		return err
+	}
+	if err := os.Remove(homeResetMarker); err != nil {
+		return fmt.Errorf("failed to remove reset marker: %w", err)
+	}
+	fmt.Printf("discobot-agent: home reset completed\n")
+	return nil
+}
+
+// resetOverlayDirs empties a session's overlay upper layer, except for
+// resetKeepEntries, and its work directory. Missing directories are fine:
+// setupOverlayFS creates them.
+func resetOverlayDirs(sessionDir string) error {
+	upperDir := filepath.Join(sessionDir, "upper")
+	entries, err := os.ReadDir(upperDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read overlay upper dir: %w", err)
+	}
+	for _, entry := range entries {
+		if resetKeepEntries[entry.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(upperDir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to remove %s from overlay upper dir: %w", entry.Name(), err)
+		}
+	}
+
+	if err := os.RemoveAll(filepath.Join(sessionDir, "work")); err != nil {
+		return fmt.Errorf("failed to clear overlay work dir: %w", err)
+	}
+	return nil
+}
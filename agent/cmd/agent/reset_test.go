@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResetOverlayDirs(t *testing.T) {
+	sessionDir := t.TempDir()
+	upper := filepath.Join(sessionDir, "upper")
+	writeTestFile(t, filepath.Join(upper, ".bashrc"), "changed")
+	writeTestFile(t, filepath.Join(upper, ".cache", "tool", "state"), "changed")
+	writeTestFile(t, filepath.Join(upper, "workspace", "main.go"), "uncommitted")
+	writeTestFile(t, filepath.Join(sessionDir, "work", "work", "tmp"), "scratch")
+	writeTestFile(t, filepath.Join(sessionDir, ".migrated"), "")
+
+	if err := resetOverlayDirs(sessionDir); err != nil {
+		t.Fatalf("resetOverlayDirs failed: %v", err)
+	}
+
+	for _, path := range []string{".bashrc", ".cache"} {
+		if _, err := os.Stat(filepath.Join(upper, path)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed from the upper dir, got %v", path, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(sessionDir, "work")); !os.IsNotExist(err) {
+		t.Errorf("Expected work dir to be removed, got %v", err)
+	}
+	if got := readTestFile(t, filepath.Join(upper, "workspace", "main.go")); got != "uncommitted" {
+		t.Errorf("Expected workspace changes to be kept, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(sessionDir, ".migrated")); err != nil {
+		t.Errorf("Expected migration marker to be kept, got %v", err)
+	}
+}
+
+func TestResetOverlayDirs_Missing(t *testing.T) {
+	if err := resetOverlayDirs(filepath.Join(t.TempDir(), "never-booted")); err != nil {
+		t.Errorf("Expected no error for a session without overlay dirs, got %v", err)
+	}
+}
@@ -226,7 +226,16 @@ function ConfiguredCredentialRow({
 					<div className="font-medium text-sm truncate">
 						{provider?.name ?? credential.name}
 					</div>
-					<div className="text-xs text-muted-foreground">{authLabel}</div>
+					<div className="text-xs text-muted-foreground">
+						{authLabel}
+						{credential.expiringSoon && credential.expiresAt && (
+							<span className="text-destructive">
+								{" "}
+								· Expires {new Date(credential.expiresAt).toLocaleString()},
+								re-authenticate
+							</span>
+						)}
+					</div>
 				</div>
 			</div>
 			<div className="flex items-center gap-1 shrink-0">
@@ -31,6 +31,7 @@ require (
 	github.com/docker/go-sdk/context v0.1.0-alpha012
 	github.com/google/go-containerregistry v0.19.0
 	github.com/klauspost/compress v1.18.3
+	github.com/opencontainers/image-spec v1.1.1
 	github.com/ulikunitz/xz v0.5.15
 	golang.org/x/crypto v0.45.0
 	golang.org/x/sys v0.41.0
@@ -207,7 +208,6 @@ require (
 	github.com/nishanths/predeclared v0.2.2 // indirect
 	github.com/nunnatsa/ginkgolinter v0.21.2 // indirect
 	github.com/opencontainers/go-digest v1.0.0 // indirect
-	github.com/opencontainers/image-spec v1.1.1 // indirect
 	github.com/pelletier/go-toml v1.9.5 // indirect
 	github.com/pelletier/go-toml/v2 v2.2.4 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
@@ -17,6 +17,8 @@ import type {
 	AnswerQuestionRequest,
 	AnswerQuestionResponse,
 	AuthProvider,
+	BulkDeleteSessionsRequest,
+	BulkDeleteSessionsResponse,
 	CancelChatResponse,
 	ChatMessage,
 	CodexAuthorizeResponse,
@@ -53,6 +55,7 @@ import type {
 	SessionDiffFilesResponse,
 	SessionDiffResponse,
 	SessionSingleFileDiffResponse,
+	SessionStatus,
 	StartServiceResponse,
 	StopServiceResponse,
 	Suggestion,
@@ -63,6 +66,7 @@ import type {
 	UpdateSessionRequest,
 	UserPreference,
 	Workspace,
+	WorkspaceDefaultAgent,
 	WriteSessionFileRequest,
 	WriteSessionFileResponse,
 } from "./api-types";
@@ -174,10 +178,41 @@ class ApiClient {
 		await this.fetch(`/workspaces/${id}${params}`, { method: "DELETE" });
 	}
 
+	async getWorkspaceDefaultAgent(id: string): Promise<WorkspaceDefaultAgent> {
+		return this.fetch<WorkspaceDefaultAgent>(
+			`/workspaces/${id}/default-agent`,
+		);
+	}
+
+	async setWorkspaceDefaultAgent(
+		id: string,
+		agentId: string | null,
+	): Promise<WorkspaceDefaultAgent> {
+		return this.fetch<WorkspaceDefaultAgent>(
+			`/workspaces/${id}/default-agent`,
+			{
+				method: "PUT",
+				body: JSON.stringify({ agentId }),
+			},
+		);
+	}
+
 	// Sessions
-	async getSessions(workspaceId: string): Promise<{ sessions: Session[] }> {
+	async getSessions(
+		workspaceId: string,
+		options?: {
+			status?: SessionStatus[];
+			q?: string;
+			sort?: "updated" | "created";
+		},
+	): Promise<{ sessions: Session[] }> {
+		const params = new URLSearchParams();
+		if (options?.status?.length) params.set("status", options.status.join(","));
+		if (options?.q) params.set("q", options.q);
+		if (options?.sort) params.set("sort", options.sort);
+		const query = params.toString();
 		return this.fetch<{ sessions: Session[] }>(
-			`/workspaces/${workspaceId}/sessions`,
+			`/workspaces/${workspaceId}/sessions${query ? `?${query}` : ""}`,
 		);
 	}
 
@@ -201,12 +236,32 @@ class ApiClient {
 		await this.fetch(`/sessions/${id}`, { method: "DELETE" });
 	}
 
+	async bulkDeleteSessions(
+		data: BulkDeleteSessionsRequest,
+	): Promise<BulkDeleteSessionsResponse> {
+		return this.fetch<BulkDeleteSessionsResponse>("/sessions/bulk-delete", {
+			method: "POST",
+			body: JSON.stringify(data),
+		});
+	}
+
 	async commitSession(id: string): Promise<{ success: boolean }> {
 		return this.fetch<{ success: boolean }>(`/sessions/${id}/commit`, {
 			method: "POST",
 		});
 	}
 
+	/**
+	 * Discard a session's home directory changes, keeping its workspace and
+	 * caches. The sandbox restarts, so the session reinitializes.
+	 */
+	async resetSession(id: string): Promise<{ success: boolean }> {
+		return this.fetch<{ success: boolean }>(`/sessions/${id}/reset`, {
+			method: "POST",
+			body: JSON.stringify({ confirm: true }),
+		});
+	}
+
 	// Session Files
 	/**
 	 * List files in a session's workspace directory.
@@ -10,6 +10,7 @@ export const SessionStatus = {
 	READY: "ready",
 	RUNNING: "running",
 	STOPPED: "stopped",
+	PAUSED: "paused",
 	ERROR: "error",
 	REMOVING: "removing",
 	REMOVED: "removed",
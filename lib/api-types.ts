@@ -72,6 +72,17 @@ export interface Session {
 	agentId?: string;
 	model?: string;
 	reasoning?: string;
+	/** Session this one was forked from */
+	forkedFrom?: string;
+	/** Workspace's extra sandbox ports (only returned for a single session) */
+	ports?: SessionPort[];
+}
+
+export interface SessionPort {
+	/** Port inside the sandbox */
+	port: number;
+	/** Published host port (unset if not running or only reachable inside a VM) */
+	hostPort?: number;
 }
 
 // Workspace status values representing the lifecycle of a workspace
@@ -90,6 +101,12 @@ export interface ProvidersResponse {
 	default: string;
 }
 
+export type WorkspaceNetworkMode = "proxied" | "isolated" | "open";
+
+export type WorkspaceRestartPolicy = "no" | "on-failure" | "unless-stopped";
+
+export type WorkspaceFilesystem = "overlayfs" | "agentfs";
+
 export interface Workspace {
 	id: string;
 	path: string;
@@ -98,6 +115,32 @@ export interface Workspace {
 	sourceType: "local" | "git";
 	/** Sandbox provider (empty string = use platform default) */
 	provider?: string;
+	/** Outbound network access for new sandboxes (default: "proxied") */
+	networkMode?: WorkspaceNetworkMode;
+	/** Restart policy for new sandboxes (unset = server default) */
+	restartPolicy?: WorkspaceRestartPolicy;
+	/** Signal sent to stop new sandboxes, e.g. "SIGQUIT" (unset = server default) */
+	stopSignal?: string;
+	/** CPU core limit for new sandboxes (unset = server default) */
+	cpuCores?: number;
+	/** Memory limit in MB for new sandboxes, at least 256 (unset = server default) */
+	memoryMB?: number;
+	/** Disk limit in MB for new sandboxes (unset = no limit) */
+	diskMB?: number;
+	/** Sandbox ports exposed besides the agent API, for new sandboxes */
+	extraPorts?: number[];
+	/** Turn off the sandbox proxy's response cache for new sandboxes (filtering still applies) */
+	disableProxyCache?: boolean;
+	/** Commits of history cloned into new sessions' sandboxes (unset = full history) */
+	cloneDepth?: number;
+	/** Clone every branch into new sessions' sandboxes, not just the current one */
+	cloneAllBranches?: boolean;
+	/** Clone and initialize git submodules in new sessions' sandboxes */
+	cloneSubmodules?: boolean;
+	/** Session filesystem for new sandboxes (unset = detected by the agent) */
+	filesystem?: WorkspaceFilesystem;
+	/** Agent for sessions without one, ahead of the project default */
+	defaultAgentId?: string;
 	status: WorkspaceStatus;
 	/** Error message if status is "error" */
 	errorMessage?: string;
@@ -142,6 +185,10 @@ export interface SupportedAgentType {
 	highlightedAuthProviders?: string[];
 	/** Whether this agent can work without authentication */
 	allowNoAuth?: boolean;
+	/** Environment variables set in the sandbox of sessions using this agent */
+	defaultEnv?: Record<string, string>;
+	/** Auth providers that must have a stored credential before this agent can be created */
+	requiredCredentialProviders?: string[];
 }
 
 export interface AgentMode {
@@ -179,6 +226,26 @@ export interface CreateWorkspaceRequest {
 	displayName?: string;
 	sourceType: "local" | "git";
 	provider?: string;
+	networkMode?: WorkspaceNetworkMode;
+	restartPolicy?: WorkspaceRestartPolicy;
+	stopSignal?: string;
+	cpuCores?: number;
+	memoryMB?: number;
+	diskMB?: number;
+	extraPorts?: number[];
+	disableProxyCache?: boolean;
+	cloneDepth?: number;
+	cloneAllBranches?: boolean;
+	cloneSubmodules?: boolean;
+	filesystem?: WorkspaceFilesystem;
+}
+
+/** GET/PUT /workspaces/{workspaceId}/default-agent response */
+export interface WorkspaceDefaultAgent {
+	/** The workspace's own default (null uses the project's) */
+	agentId: string | null;
+	/** The agent sessions without one use (null if none is configured) */
+	effectiveAgentId: string | null;
 }
 
 export interface CreateSessionRequest {
@@ -194,6 +261,37 @@ export interface UpdateSessionRequest {
 	status?: SessionStatus;
 }
 
+/** POST /sessions/bulk-delete body: either sessionIds or filter */
+export interface BulkDeleteSessionsRequest {
+	sessionIds?: string[];
+	/** "completed" selects sessions whose changes were committed */
+	filter?: "stopped" | "error" | "completed";
+}
+
+export interface BulkDeleteSessionsResponse {
+	accepted: string[];
+	alreadyRemoving: string[];
+	commitInProgress: string[];
+	notFound: string[];
+	failed: string[];
+}
+
+export interface ForkSessionRequest {
+	/** Name for the new session (defaults to "<name> (fork)") */
+	name?: string;
+}
+
+/** Snapshot of a session's sandbox, for comparing workspace states */
+export interface SessionSnapshot {
+	id: string;
+	createdAt: string;
+}
+
+export interface ListSessionSnapshotsResponse {
+	/** Oldest first */
+	snapshots: SessionSnapshot[];
+}
+
 export interface CreateAgentRequest {
 	agentType: string;
 }
@@ -215,6 +313,35 @@ export interface TerminalMessage {
 	rows?: number;
 }
 
+/** Message on the GET /api/jobs/{jobId}/logs WebSocket */
+export type JobLogMessage =
+	| { type: "log"; time: string; message: string }
+	| { type: "done"; status: string; error?: string };
+
+/** Open file descriptors of a sandbox process */
+export interface ProcessFDStats {
+	pid: number;
+	command: string;
+	open: number;
+	/** Soft RLIMIT_NOFILE (null if unlimited) */
+	softLimit: number | null;
+	/** Hard RLIMIT_NOFILE (null if unlimited) */
+	hardLimit: number | null;
+}
+
+/** GET /api/projects/{projectId}/sessions/{sessionId}/fds response */
+export interface SessionFileDescriptors {
+	/** The agent API's process */
+	self: ProcessFDStats;
+	/** Processes with the most open file descriptors, descending */
+	top: ProcessFDStats[];
+	totalOpen: number;
+	/** Kernel-wide file handles (null if unavailable) */
+	system: { allocated: number; max: number } | null;
+	/** Processes, or the system, at or above 80% of their limit */
+	warnings: string[];
+}
+
 export interface Icon {
 	/**
 	 * A standard URI pointing to an icon resource. May be an HTTP/HTTPS URL or a
@@ -278,6 +405,10 @@ export interface CredentialInfo {
 	authType: CredentialAuthType;
 	isConfigured: boolean;
 	expiresAt?: string; // For OAuth credentials
+	/** Expires within the warning window and can't be refreshed automatically */
+	expiringSoon?: boolean;
+	/** Last time the credential was passed to a sandbox */
+	lastUsedAt?: string;
 	updatedAt?: string;
 }
 
@@ -533,6 +664,36 @@ export interface RenameSessionFileResponse {
 	newPath: string;
 }
 
+/** Request to start a resumable session file upload */
+export interface CreateSessionUploadRequest {
+	path: string;
+	/** Total bytes the upload will contain */
+	size: number;
+}
+
+/** Progress of a resumable session file upload */
+export interface SessionUploadStatus {
+	uploadId: string;
+	path: string;
+	size: number;
+	/** Bytes received so far; the next chunk must start here */
+	offset: number;
+	/** True once the file has been moved into place */
+	complete: boolean;
+}
+
+/** 409 response to an upload chunk sent at the wrong offset */
+export interface SessionUploadOffsetConflictResponse {
+	error: string;
+	/** Offset to resume from */
+	offset: number;
+}
+
+/** Response from aborting a resumable session file upload */
+export interface AbortSessionUploadResponse {
+	uploadId: string;
+}
+
 /** Error response when file content has changed (optimistic locking conflict) */
 export interface WriteSessionFileConflictError {
 	error: "conflict";
@@ -541,6 +702,35 @@ export interface WriteSessionFileConflictError {
 	currentContent: string;
 }
 
+/**
+ * Local changes, unmerged files, or a diverged upstream that need the user to
+ * choose what to do
+ */
+export interface GitConflict {
+	op: "checkout" | "fetch";
+	reason: "dirty_work_tree" | "untracked_files" | "unmerged" | "diverged";
+	/** Conflicting paths, relative to the repository root */
+	files: string[];
+	/** Suggested resolutions to offer */
+	options: ("stash" | "force" | "rebase" | "merge" | "abort")[];
+	/** Commits only on the local branch (diverged only) */
+	ahead?: number;
+	/** Commits only on the upstream branch (diverged only) */
+	behind?: number;
+}
+
+/** 409 response from workspace git checkout when the checkout is blocked */
+export interface GitConflictResponse {
+	error: string;
+	conflict: GitConflict;
+}
+
+/** Response from workspace git fetch, with any divergence from upstream */
+export interface GitFetchResponse {
+	success: true;
+	conflict?: GitConflict;
+}
+
 /** Single file diff entry */
 export interface SessionFileDiffEntry {
 	path: string;
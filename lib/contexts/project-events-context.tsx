@@ -2,7 +2,9 @@ import * as React from "react";
 import { api } from "@/lib/api-client";
 import type { StartupTask } from "@/lib/api-types";
 import { StartupStatusContext } from "@/lib/contexts/startup-status-context";
+import { invalidateCredentials } from "@/lib/hooks/use-credentials";
 import {
+	type CredentialExpiringData,
 	type SessionUpdatedData,
 	useProjectEvents,
 	type WorkspaceUpdatedData,
@@ -59,6 +61,14 @@ export function ProjectEventsProvider({
 		[],
 	);
 
+	// Refetch credentials so their expiringSoon flags prompt re-authentication
+	const handleCredentialExpiring = React.useCallback(
+		(_data: CredentialExpiringData) => {
+			invalidateCredentials();
+		},
+		[],
+	);
+
 	const handleStartupTaskUpdated = React.useCallback((task: StartupTask) => {
 		setTasksMap((prev) => {
 			const next = new Map(prev);
@@ -71,6 +81,7 @@ export function ProjectEventsProvider({
 		onSessionUpdated: handleSessionUpdated,
 		onWorkspaceUpdated: handleWorkspaceUpdated,
 		onStartupTaskUpdated: handleStartupTaskUpdated,
+		onCredentialExpiring: handleCredentialExpiring,
 	});
 
 	const tasks = React.useMemo(() => Array.from(tasksMap.values()), [tasksMap]);
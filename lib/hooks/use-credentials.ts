@@ -1,7 +1,16 @@
-import useSWR from "swr";
+import useSWR, { mutate as globalMutate } from "swr";
 import { api } from "../api-client";
 import type { CreateCredentialRequest } from "../api-types";
 
+const CREDENTIALS_KEY = "credentials";
+
+/**
+ * Invalidate the credentials cache, triggering a refetch.
+ */
+export function invalidateCredentials() {
+	globalMutate(CREDENTIALS_KEY);
+}
+
 /**
  * Hook for managing API credentials
  *
@@ -9,7 +18,7 @@ import type { CreateCredentialRequest } from "../api-types";
  * OAuth flows are handled by auth plugins directly.
  */
 export function useCredentials() {
-	const { data, error, isLoading, mutate } = useSWR("credentials", () =>
+	const { data, error, isLoading, mutate } = useSWR(CREDENTIALS_KEY, () =>
 		api.getCredentials(),
 	);
 
@@ -6,7 +6,8 @@ import type { StartupTask } from "../api-types";
 export type ProjectEventType =
 	| "session_updated"
 	| "workspace_updated"
-	| "startup_task_updated";
+	| "startup_task_updated"
+	| "credential_expiring";
 
 export interface ProjectEvent {
 	id: string;
@@ -25,6 +26,25 @@ export interface WorkspaceUpdatedData {
 	status: string;
 }
 
+export interface CredentialExpiringData {
+	provider: string;
+	expiresAt: string;
+}
+
+/** Sent by the server before it closes a long-lived or idle stream */
+interface ReconnectData {
+	/** ID of the last event sent on the stream (empty if none) */
+	after: string;
+	/** When the stream was closed (RFC3339) */
+	since: string;
+}
+
+/** Where to resume the stream from on reconnect */
+interface ResumePoint {
+	after?: string;
+	since?: string;
+}
+
 interface UseProjectEventsOptions {
 	/** Called when a session_updated event is received */
 	onSessionUpdated?: (data: SessionUpdatedData) => void;
@@ -32,6 +52,8 @@ interface UseProjectEventsOptions {
 	onWorkspaceUpdated?: (data: WorkspaceUpdatedData) => void;
 	/** Called when a startup_task_updated event is received */
 	onStartupTaskUpdated?: (data: StartupTask) => void;
+	/** Called when a credential_expiring event is received */
+	onCredentialExpiring?: (data: CredentialExpiringData) => void;
 	/** Whether to auto-reconnect on disconnect (default: true) */
 	autoReconnect?: boolean;
 	/** Reconnect delay in ms (default: 3000) */
@@ -50,6 +72,7 @@ export function useProjectEvents(options: UseProjectEventsOptions = {}) {
 		onSessionUpdated,
 		onWorkspaceUpdated,
 		onStartupTaskUpdated,
+		onCredentialExpiring,
 		autoReconnect = true,
 		reconnectDelay = 3000,
 	} = options;
@@ -57,11 +80,13 @@ export function useProjectEvents(options: UseProjectEventsOptions = {}) {
 	const eventSourceRef = useRef<EventSource | null>(null);
 	const reconnectTimeoutRef = useRef<NodeJS.Timeout | null>(null);
 	const isConnectedRef = useRef(false);
+	const resumeRef = useRef<ResumePoint>({});
 
 	// Store callbacks and options in refs so they don't cause reconnection when changed
 	const onSessionUpdatedRef = useRef(onSessionUpdated);
 	const onWorkspaceUpdatedRef = useRef(onWorkspaceUpdated);
 	const onStartupTaskUpdatedRef = useRef(onStartupTaskUpdated);
+	const onCredentialExpiringRef = useRef(onCredentialExpiring);
 	const autoReconnectRef = useRef(autoReconnect);
 	const reconnectDelayRef = useRef(reconnectDelay);
 
@@ -78,6 +103,10 @@ export function useProjectEvents(options: UseProjectEventsOptions = {}) {
 		onStartupTaskUpdatedRef.current = onStartupTaskUpdated;
 	}, [onStartupTaskUpdated]);
 
+	useEffect(() => {
+		onCredentialExpiringRef.current = onCredentialExpiring;
+	}, [onCredentialExpiring]);
+
 	useEffect(() => {
 		autoReconnectRef.current = autoReconnect;
 	}, [autoReconnect]);
@@ -97,10 +126,26 @@ export function useProjectEvents(options: UseProjectEventsOptions = {}) {
 			eventSourceRef.current.close();
 		}
 
-		const url = appendAuthToken(`${getApiBase()}/events`);
+		// Resume after the last event we saw so nothing is missed across reconnects
+		const params = new URLSearchParams();
+		if (resumeRef.current.after) {
+			params.set("after", resumeRef.current.after);
+		} else if (resumeRef.current.since) {
+			params.set("since", resumeRef.current.since);
+		}
+		const query = params.toString();
+		const url = appendAuthToken(
+			`${getApiBase()}/events${query ? `?${query}` : ""}`,
+		);
 		const eventSource = new EventSource(url);
 		eventSourceRef.current = eventSource;
 
+		const trackEvent = (event: MessageEvent) => {
+			if (event.lastEventId) {
+				resumeRef.current = { after: event.lastEventId };
+			}
+		};
+
 		eventSource.onopen = () => {
 			isConnectedRef.current = true;
 			console.log("[SSE] Connected to project events");
@@ -135,8 +180,26 @@ export function useProjectEvents(options: UseProjectEventsOptions = {}) {
 			}
 		});
 
+		// The server recycles long-lived and idle streams; reconnect right away
+		eventSource.addEventListener("reconnect", (event) => {
+			try {
+				const data: ReconnectData = JSON.parse(event.data);
+				resumeRef.current = data.after
+					? { after: data.after }
+					: { since: data.since };
+			} catch {
+				// Keep the previous resume point
+			}
+			console.log("[SSE] Server requested reconnect");
+			eventSource.close();
+			eventSourceRef.current = null;
+			isConnectedRef.current = false;
+			connect();
+		});
+
 		// Handle session_updated events
 		eventSource.addEventListener("session_updated", (event) => {
+			trackEvent(event);
 			try {
 				const payload: ProjectEvent = JSON.parse(event.data);
 				const sessionData = payload.data as SessionUpdatedData;
@@ -156,6 +219,7 @@ export function useProjectEvents(options: UseProjectEventsOptions = {}) {
 
 		// Handle workspace_updated events
 		eventSource.addEventListener("workspace_updated", (event) => {
+			trackEvent(event);
 			try {
 				const payload: ProjectEvent = JSON.parse(event.data);
 				const workspaceData = payload.data as WorkspaceUpdatedData;
@@ -175,6 +239,7 @@ export function useProjectEvents(options: UseProjectEventsOptions = {}) {
 
 		// Handle startup_task_updated events
 		eventSource.addEventListener("startup_task_updated", (event) => {
+			trackEvent(event);
 			try {
 				const payload: ProjectEvent = JSON.parse(event.data);
 				const taskData = payload.data as StartupTask;
@@ -183,6 +248,26 @@ export function useProjectEvents(options: UseProjectEventsOptions = {}) {
 				console.error("[SSE] Failed to parse startup_task_updated event:", err);
 			}
 		});
+
+		// Handle credential_expiring events
+		eventSource.addEventListener("credential_expiring", (event) => {
+			trackEvent(event);
+			try {
+				const payload: ProjectEvent = JSON.parse(event.data);
+				const credentialData = payload.data as CredentialExpiringData;
+
+				console.log(
+					"[SSE] Credential expiring:",
+					credentialData.provider,
+					"at",
+					credentialData.expiresAt,
+				);
+
+				onCredentialExpiringRef.current?.(credentialData);
+			} catch (err) {
+				console.error("[SSE] Failed to parse credential_expiring event:", err);
+			}
+		});
 	}, []); // No dependencies - uses refs for all dynamic values
 
 	const disconnect = useCallback(() => {
@@ -81,6 +81,7 @@ export function getSessionStatusIndicator(
 		case SessionStatusConstants.RUNNING:
 			return <Loader2 className={`${iconSize} text-blue-500 animate-spin`} />;
 		case SessionStatusConstants.STOPPED:
+		case SessionStatusConstants.PAUSED:
 			return <Pause className={`${iconSize} text-muted-foreground`} />;
 		case SessionStatusConstants.ERROR:
 			return size === "small" ? (
@@ -54,6 +54,9 @@ func (s *Server) setupRoutes() {
 	r.Get("/api/cache/stats", s.handleCacheStats)
 	r.Delete("/api/cache", s.handleClearCache)
 
+	// Traffic statistics
+	r.Get("/stats", s.handleStats)
+
 	s.router = r
 }
 
@@ -177,6 +180,7 @@ func (s *Server) handleCacheStats(w http.ResponseWriter, _ *http.Request) {
 		"stores":       stats.Stores,
 		"evictions":    stats.Evictions,
 		"errors":       stats.Errors,
+		"corruptions":  stats.Corruptions,
 		"current_size": stats.CurrentSize,
 		"hit_rate":     calculateHitRate(stats),
 	}
@@ -196,6 +200,11 @@ func (s *Server) handleClearCache(w http.ResponseWriter, _ *http.Request) {
 	s.jsonOK(w, map[string]string{"status": "ok"})
 }
 
+// handleStats handles GET /stats.
+func (s *Server) handleStats(w http.ResponseWriter, _ *http.Request) {
+	s.jsonOK(w, s.proxy.GetStats().Snapshot())
+}
+
 func calculateHitRate(stats cache.Stats) float64 {
 	total := stats.Hits + stats.Misses
 	if total == 0 {
@@ -10,6 +10,7 @@ import (
 	"github.com/obot-platform/discobot/proxy/internal/config"
 	"github.com/obot-platform/discobot/proxy/internal/logger"
 	"github.com/obot-platform/discobot/proxy/internal/proxy"
+	"github.com/obot-platform/discobot/proxy/internal/stats"
 )
 
 func testLogger(t *testing.T) *logger.Logger {
@@ -269,6 +270,37 @@ func TestAPI_HealthCheck(t *testing.T) {
 	}
 }
 
+func TestAPI_Stats(t *testing.T) {
+	proxyServer := createTestProxyServer(t)
+	log := testLogger(t)
+	apiServer := New(proxyServer, log)
+
+	proxyServer.GetStats().RecordRequest("api.example.com:443")
+	proxyServer.GetStats().AddBytesReceived("api.example.com", 42)
+	proxyServer.GetStats().RecordCacheHit("api.example.com")
+
+	req := httptest.NewRequest("GET", "/stats", nil)
+	w := httptest.NewRecorder()
+
+	apiServer.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp stats.Snapshot
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	d := resp.Domains["api.example.com"]
+	if d.Requests != 1 || d.BytesReceived != 42 || d.CacheHitRate != 1 {
+		t.Errorf("Unexpected stats for api.example.com: %+v", d)
+	}
+	if resp.Totals.Requests != 1 {
+		t.Errorf("Expected 1 total request, got %d", resp.Totals.Requests)
+	}
+}
+
 func TestAPI_POSTConfig_DisableAllowlist(t *testing.T) {
 	proxyServer := createTestProxyServer(t)
 	log := testLogger(t)
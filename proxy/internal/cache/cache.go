@@ -22,6 +22,11 @@ var (
 	ErrCacheMiss = errors.New("cache miss")
 	// ErrCacheDisabled indicates the cache is not enabled.
 	ErrCacheDisabled = errors.New("cache disabled")
+	// ErrResponseTooLarge indicates a response exceeds the size that may be cached.
+	ErrResponseTooLarge = errors.New("response too large to cache")
+	// ErrCacheCorrupt indicates a cached entry was unreadable or its body no
+	// longer matched its stored digest. The entry has been evicted.
+	ErrCacheCorrupt = errors.New("cache entry corrupt")
 )
 
 // Cache provides content caching with LRU eviction.
@@ -43,6 +48,7 @@ type Stats struct {
 	Stores      int64
 	Evictions   int64
 	Errors      int64
+	Corruptions int64 // Entries evicted because their content failed verification
 	CurrentSize int64
 }
 
@@ -51,6 +57,7 @@ type Entry struct {
 	StatusCode int
 	Headers    http.Header
 	Body       []byte
+	Digest     string // Hex SHA-256 of Body, stored alongside the entry
 	CachedAt   time.Time
 	Size       int64
 }
@@ -83,6 +90,14 @@ func New(dir string, maxSize int64, enabled bool, logger *zap.Logger) (*Cache, e
 
 // Get retrieves a cached response.
 func (c *Cache) Get(key string) (*Entry, error) {
+	return c.GetVerified(key, "")
+}
+
+// GetVerified retrieves a cached response whose body must have the hex
+// SHA-256 digest (any body if empty), such as the digest a blob's URL asks
+// for. An entry that doesn't match is evicted, counted as a corruption and
+// a miss, and ErrCacheCorrupt is returned.
+func (c *Cache) GetVerified(key, digest string) (*Entry, error) {
 	if !c.enabled {
 		return nil, ErrCacheDisabled
 	}
@@ -98,6 +113,16 @@ func (c *Cache) Get(key string) (*Entry, error) {
 
 	// Read from disk
 	entry, err := c.readEntry(key)
+	if err == nil && digest != "" && entry.Digest != digest {
+		err = ErrCacheCorrupt
+	}
+	if errors.Is(err, ErrCacheCorrupt) {
+		c.logger.Warn("evicted corrupt cache entry", zap.String("key", key))
+		c.stats.Corruptions++
+		c.stats.Misses++
+		c.removeEntry(key)
+		return nil, err
+	}
 	if err != nil {
 		c.stats.Errors++
 		c.logger.Debug("cache read error", zap.String("key", key), zap.Error(err))
@@ -120,15 +145,24 @@ func (c *Cache) Put(key string, entry *Entry) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	entry.Digest = bodyDigest(entry.Body)
+
 	// Write to disk
 	if err := c.writeEntry(key, entry); err != nil {
 		c.stats.Errors++
 		return err
 	}
 
+	c.stored(key, entry.Size)
+	return nil
+}
+
+// stored adds an entry just written to disk to the index, evicting others
+// if the cache is over its size limit. c.mu must be held.
+func (c *Cache) stored(key string, size int64) {
 	// Add to index
-	c.index.add(key, entry.Size)
-	c.stats.CurrentSize += entry.Size
+	c.index.add(key, size)
+	c.stats.CurrentSize += size
 	c.stats.Stores++
 
 	// Evict if over size limit
@@ -138,8 +172,21 @@ func (c *Cache) Put(key string, entry *Entry) error {
 			break
 		}
 	}
+}
 
-	return nil
+// EvictCorrupt removes an entry whose content the caller found to be wrong,
+// such as a blob not matching the digest its URL asks for, and counts it as a
+// corruption.
+func (c *Cache) EvictCorrupt(key string) {
+	if !c.enabled {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Corruptions++
+	c.removeEntry(key)
 }
 
 // GetStats returns current cache statistics.
@@ -177,9 +224,18 @@ func cacheKey(path string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// readEntry reads a cache entry from disk.
+// bodyDigest returns the hex SHA-256 of body.
+func bodyDigest(body []byte) string {
+	hash := sha256.Sum256(body)
+	return hex.EncodeToString(hash[:])
+}
+
+// readEntry reads a cache entry from disk, verifying the body against the
+// stored digest. Returns ErrCacheCorrupt if the entry is unreadable or
+// doesn't match.
 func (c *Cache) readEntry(key string) (*Entry, error) {
-	path := filepath.Join(c.dir, cacheKey(key))
+	hash := cacheKey(key)
+	path := filepath.Join(c.dir, hash)
 
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -191,10 +247,18 @@ func (c *Cache) readEntry(key string) (*Entry, error) {
 
 	entry, err := deserializeEntry(data)
 	if err != nil {
-		// Corrupt cache file, remove it
-		_ = os.Remove(path)
-		c.index.remove(key)
-		return nil, ErrCacheMiss
+		return nil, ErrCacheCorrupt
+	}
+
+	entry.Digest = bodyDigest(entry.Body)
+	stored, err := os.ReadFile(filepath.Join(c.dir, hash+".sha256"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read digest file: %w", err)
+	}
+	// Entries cached before digests were stored have no digest file; the
+	// proxy still checks them against the digest in the request URL.
+	if err == nil && string(stored) != entry.Digest {
+		return nil, ErrCacheCorrupt
 	}
 
 	return entry, nil
@@ -214,6 +278,12 @@ func (c *Cache) writeEntry(key string, entry *Entry) error {
 		return fmt.Errorf("write cache file: %w", err)
 	}
 
+	// Write digest file for verification on read
+	digestPath := filepath.Join(c.dir, hash+".sha256")
+	if err := os.WriteFile(digestPath, []byte(entry.Digest), 0644); err != nil {
+		return fmt.Errorf("write digest file: %w", err)
+	}
+
 	// Write metadata file with original key
 	metaPath := filepath.Join(c.dir, hash+".meta")
 	if err := os.WriteFile(metaPath, []byte(key), 0644); err != nil {
@@ -223,6 +293,15 @@ func (c *Cache) writeEntry(key string, entry *Entry) error {
 	return nil
 }
 
+// removeEntry deletes an entry's files and drops it from the index.
+func (c *Cache) removeEntry(key string) {
+	hash := cacheKey(key)
+	for _, name := range []string{hash, hash + ".sha256", hash + ".meta"} {
+		_ = os.Remove(filepath.Join(c.dir, name))
+	}
+	c.stats.CurrentSize -= c.index.remove(key)
+}
+
 // evictLRU evicts the least recently used entry.
 func (c *Cache) evictLRU() error {
 	key, size := c.index.evict()
@@ -236,7 +315,8 @@ func (c *Cache) evictLRU() error {
 		return fmt.Errorf("remove cache file: %w", err)
 	}
 
-	// Remove metadata file
+	// Remove digest and metadata files
+	_ = os.Remove(filepath.Join(c.dir, hash+".sha256")) // Ignore error if digest file doesn't exist
 	metaPath := filepath.Join(c.dir, hash+".meta")
 	_ = os.Remove(metaPath) // Ignore error if meta file doesn't exist
 
@@ -246,7 +326,12 @@ func (c *Cache) evictLRU() error {
 	return nil
 }
 
-// loadIndex rebuilds the LRU index from existing cache files.
+// tempExt is the extension of the files responses are captured into before
+// they become cache entries (see Capture).
+const tempExt = ".tmp"
+
+// loadIndex rebuilds the LRU index from existing cache files, removing any
+// captures left unfinished when the proxy last stopped.
 func (c *Cache) loadIndex() error {
 	entries, err := os.ReadDir(c.dir)
 	if err != nil {
@@ -258,8 +343,11 @@ func (c *Cache) loadIndex() error {
 			continue
 		}
 
-		// Skip metadata files
-		if filepath.Ext(entry.Name()) == ".meta" {
+		// Skip metadata and digest files
+		if ext := filepath.Ext(entry.Name()); ext == ".meta" || ext == ".sha256" {
+			continue
+		} else if ext == tempExt {
+			_ = os.Remove(filepath.Join(c.dir, entry.Name()))
 			continue
 		}
 
@@ -286,22 +374,25 @@ func (c *Cache) loadIndex() error {
 
 // serializeEntry converts an Entry to bytes.
 func serializeEntry(entry *Entry) ([]byte, error) {
+	return append(serializePrefix(entry), entry.Body...), nil
+}
+
+// serializePrefix returns the part of a serialized entry before the body:
+// the status code and timestamp, then the length-prefixed headers.
+func serializePrefix(entry *Entry) []byte {
 	var buf bytes.Buffer
 
 	// Write status code (4 bytes)
-	statusBytes := []byte{
+	buf.Write([]byte{
 		byte(entry.StatusCode >> 24),
 		byte(entry.StatusCode >> 16),
 		byte(entry.StatusCode >> 8),
 		byte(entry.StatusCode),
-	}
-	if _, err := buf.Write(statusBytes); err != nil {
-		return nil, err
-	}
+	})
 
 	// Write timestamp (8 bytes)
 	timestamp := entry.CachedAt.Unix()
-	timeBytes := []byte{
+	buf.Write([]byte{
 		byte(timestamp >> 56),
 		byte(timestamp >> 48),
 		byte(timestamp >> 40),
@@ -310,33 +401,20 @@ func serializeEntry(entry *Entry) ([]byte, error) {
 		byte(timestamp >> 16),
 		byte(timestamp >> 8),
 		byte(timestamp),
-	}
-	if _, err := buf.Write(timeBytes); err != nil {
-		return nil, err
-	}
+	})
 
 	// Write headers (length-prefixed)
 	headersData := serializeHeaders(entry.Headers)
 	headerLen := len(headersData)
-	headerLenBytes := []byte{
+	buf.Write([]byte{
 		byte(headerLen >> 24),
 		byte(headerLen >> 16),
 		byte(headerLen >> 8),
 		byte(headerLen),
-	}
-	if _, err := buf.Write(headerLenBytes); err != nil {
-		return nil, err
-	}
-	if _, err := buf.Write(headersData); err != nil {
-		return nil, err
-	}
-
-	// Write body
-	if _, err := buf.Write(entry.Body); err != nil {
-		return nil, err
-	}
+	})
+	buf.Write(headersData)
 
-	return buf.Bytes(), nil
+	return buf.Bytes()
 }
 
 // deserializeEntry converts bytes to an Entry.
@@ -404,16 +482,30 @@ func deserializeHeaders(data []byte) http.Header {
 	return headers
 }
 
-// CaptureResponse captures an HTTP response for caching.
-func CaptureResponse(resp *http.Response) (*Entry, error) {
+// CaptureResponse captures an HTTP response for caching. If the body is
+// larger than maxSize (0 = unlimited) it returns ErrResponseTooLarge, having
+// buffered at most maxSize+1 bytes; resp.Body still yields the whole body, so
+// the response streams through to the client uncached.
+func CaptureResponse(resp *http.Response, maxSize int64) (*Entry, error) {
 	if resp == nil {
 		return nil, errors.New("nil response")
 	}
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		return nil, ErrResponseTooLarge
+	}
 
-	body, err := io.ReadAll(resp.Body)
+	var reader io.Reader = resp.Body
+	if maxSize > 0 {
+		reader = io.LimitReader(resp.Body, maxSize+1)
+	}
+	body, err := io.ReadAll(reader)
 	if err != nil {
 		return nil, fmt.Errorf("read response body: %w", err)
 	}
+	if maxSize > 0 && int64(len(body)) > maxSize {
+		resp.Body = &prefixedBody{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return nil, ErrResponseTooLarge
+	}
 	resp.Body.Close()
 
 	// Restore body for downstream use
@@ -430,6 +522,13 @@ func CaptureResponse(resp *http.Response) (*Entry, error) {
 	return entry, nil
 }
 
+// prefixedBody is a response body whose already-read prefix is put back in
+// front of the unread remainder.
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
+
 // RestoreResponse creates an HTTP response from a cache entry.
 func RestoreResponse(entry *Entry, req *http.Request) *http.Response {
 	resp := &http.Response{
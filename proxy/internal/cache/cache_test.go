@@ -1,9 +1,12 @@
 package cache
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"go.uber.org/zap"
@@ -141,6 +144,83 @@ func TestCache_Clear(t *testing.T) {
 	}
 }
 
+func TestCache_Corruption(t *testing.T) {
+	tmpDir := t.TempDir()
+	c, err := New(tmpDir, 10*1024*1024, true, zap.NewNop())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	entry := &Entry{StatusCode: 200, Headers: http.Header{}, Body: []byte("layer data"), Size: 10}
+	if err := c.Put("key1", entry); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if entry.Digest != "2c227bb559a109dc00c04d2b74655891e9773fd58f2ea5a81b200defe51f1c8e" {
+		t.Errorf("Digest = %q", entry.Digest)
+	}
+	retrieved, err := c.Get("key1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if retrieved.Digest != entry.Digest {
+		t.Errorf("retrieved digest = %q, want %q", retrieved.Digest, entry.Digest)
+	}
+
+	// Flip a byte of the body on disk
+	path := filepath.Join(tmpDir, cacheKey("key1"))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get("key1"); !errors.Is(err, ErrCacheCorrupt) {
+		t.Fatalf("expected ErrCacheCorrupt, got %v", err)
+	}
+	stats := c.GetStats()
+	if stats.Corruptions != 1 || stats.Misses != 1 || stats.CurrentSize != 0 {
+		t.Errorf("stats = %+v, want 1 corruption counted as a miss and an empty cache", stats)
+	}
+	for _, name := range []string{cacheKey("key1"), cacheKey("key1") + ".sha256", cacheKey("key1") + ".meta"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s removed, got %v", name, err)
+		}
+	}
+	if _, err := c.Get("key1"); err != ErrCacheMiss {
+		t.Errorf("expected cache miss after eviction, got %v", err)
+	}
+
+	// Entries the caller finds wrong are evicted the same way
+	if err := c.Put("key2", entry); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	c.EvictCorrupt("key2")
+	if _, err := c.Get("key2"); err != ErrCacheMiss {
+		t.Errorf("expected cache miss after EvictCorrupt, got %v", err)
+	}
+	if stats := c.GetStats(); stats.Corruptions != 2 || stats.CurrentSize != 0 {
+		t.Errorf("stats = %+v, want 2 corruptions and an empty cache", stats)
+	}
+
+	// So are entries that aren't the digest asked for, without counting a hit
+	if err := c.Put("key3", entry); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, err := c.GetVerified("key3", entry.Digest); err != nil {
+		t.Fatalf("GetVerified with the entry's digest failed: %v", err)
+	}
+	hits := c.GetStats().Hits
+	if _, err := c.GetVerified("key3", strings.Repeat("0", 64)); !errors.Is(err, ErrCacheCorrupt) {
+		t.Fatalf("expected ErrCacheCorrupt, got %v", err)
+	}
+	if stats := c.GetStats(); stats.Hits != hits || stats.Corruptions != 3 || stats.CurrentSize != 0 {
+		t.Errorf("stats = %+v, want no new hit, 3 corruptions and an empty cache", stats)
+	}
+}
+
 func TestCache_Disabled(t *testing.T) {
 	tmpDir := t.TempDir()
 	logger := zap.NewNop()
@@ -297,7 +377,7 @@ func TestCaptureAndRestoreResponse(t *testing.T) {
 	}
 
 	// Capture response
-	entry, err := CaptureResponse(resp)
+	entry, err := CaptureResponse(resp, 0)
 	if err != nil {
 		t.Fatalf("CaptureResponse failed: %v", err)
 	}
@@ -322,3 +402,142 @@ func TestCaptureAndRestoreResponse(t *testing.T) {
 		t.Error("expected X-Cache: HIT header")
 	}
 }
+
+func TestCaptureResponse_MaxSize(t *testing.T) {
+	body := strings.Repeat("x", 100)
+
+	tests := []struct {
+		name          string
+		contentLength int64
+		maxSize       int64
+		wantErr       error
+	}{
+		{"unlimited", -1, 0, nil},
+		{"within limit", 100, 100, nil},
+		{"known length over limit", 100, 99, ErrResponseTooLarge},
+		{"unknown length over limit", -1, 99, ErrResponseTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode:    200,
+				Header:        http.Header{},
+				Body:          io.NopCloser(strings.NewReader(body)),
+				ContentLength: tt.contentLength,
+			}
+
+			entry, err := CaptureResponse(resp, tt.maxSize)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CaptureResponse error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && string(entry.Body) != body {
+				t.Errorf("entry body has %d bytes, want %d", len(entry.Body), len(body))
+			}
+
+			// The client still gets the whole body either way
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("read response body: %v", err)
+			}
+			if string(got) != body {
+				t.Errorf("response body has %d bytes, want %d", len(got), len(body))
+			}
+		})
+	}
+}
+
+func TestCache_Capture(t *testing.T) {
+	body := strings.Repeat("x", 100)
+	digest := "09ecb6ebc8bcefc733f6f2ec44f791abeed6a99edf0cc31519637898aebd52d8"
+	errMismatch := errors.New("digest mismatch")
+
+	tests := []struct {
+		name          string
+		contentLength int64
+		maxSize       int64
+		readAll       bool
+		verifyErr     error
+		wantErr       error
+	}{
+		{"stored", -1, 0, true, nil, nil},
+		{"within limit", 100, 100, true, nil, nil},
+		{"known length over limit", 100, 99, true, nil, ErrResponseTooLarge},
+		{"unknown length over limit", -1, 99, true, nil, ErrResponseTooLarge},
+		{"closed early", -1, 0, false, nil, ErrIncompleteResponse},
+		{"rejected digest", -1, 0, true, errMismatch, errMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			c, err := New(dir, 10*1024*1024, true, zap.NewNop())
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			resp := &http.Response{
+				StatusCode:    200,
+				Header:        http.Header{"Content-Type": []string{"text/plain"}},
+				Body:          io.NopCloser(strings.NewReader(body)),
+				ContentLength: tt.contentLength,
+			}
+
+			var calls int
+			var gotErr error
+			var gotDigest string
+			c.Capture("key", resp, tt.maxSize, func(d string) error {
+				gotDigest = d
+				return tt.verifyErr
+			}, func(_ int64, err error) {
+				calls++
+				gotErr = err
+			})
+
+			// The client gets the body as it streams, whether or not it's cached
+			if tt.readAll {
+				got, err := io.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatalf("read response body: %v", err)
+				}
+				if string(got) != body {
+					t.Errorf("response body has %d bytes, want %d", len(got), len(body))
+				}
+			} else {
+				if _, err := resp.Body.Read(make([]byte, 10)); err != nil {
+					t.Fatalf("read response body: %v", err)
+				}
+			}
+			resp.Body.Close()
+
+			if calls != 1 {
+				t.Fatalf("done called %d times, want 1", calls)
+			}
+			if !errors.Is(gotErr, tt.wantErr) {
+				t.Fatalf("Capture error = %v, want %v", gotErr, tt.wantErr)
+			}
+
+			entry, err := c.Get("key")
+			if tt.wantErr != nil {
+				if !errors.Is(err, ErrCacheMiss) {
+					t.Errorf("expected nothing cached, got %v", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Get failed: %v", err)
+				}
+				if string(entry.Body) != body || entry.StatusCode != 200 || entry.Headers.Get("Content-Type") != "text/plain" {
+					t.Errorf("unexpected entry: %d %v %q", entry.StatusCode, entry.Headers, entry.Body)
+				}
+				if gotDigest != digest || entry.Digest != digest {
+					t.Errorf("digest = %q (entry %q), want %q", gotDigest, entry.Digest, digest)
+				}
+			}
+
+			// No capture files are left behind
+			files, _ := filepath.Glob(filepath.Join(dir, "*"+tempExt))
+			if len(files) != 0 {
+				t.Errorf("capture files left behind: %v", files)
+			}
+		})
+	}
+}
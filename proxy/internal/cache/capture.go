@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrIncompleteResponse indicates a captured response body was closed before
+// it was read to the end, so it wasn't cached.
+var ErrIncompleteResponse = errors.New("response not read to the end")
+
+// Capture caches resp under key as its body streams through to the client.
+// resp.Body is replaced with a reader that writes what it reads to a
+// temporary file in the cache directory, so the body is never held in
+// memory and the client isn't kept waiting for it to be buffered. Once the
+// body has been read to the end, verify is called with its hex SHA-256
+// digest, and if it returns nil the file becomes the cache entry.
+//
+// done is called once with the outcome: nil if the entry was stored,
+// ErrResponseTooLarge if the body is over maxSize (0 = unlimited),
+// ErrIncompleteResponse if the body was closed early, or the error from
+// verify or from writing the entry. It is called from Read or Close on the
+// body, or from Capture itself if the response can't be cached at all.
+func (c *Cache) Capture(key string, resp *http.Response, maxSize int64, verify func(digest string) error, done func(size int64, err error)) {
+	if !c.enabled {
+		done(0, ErrCacheDisabled)
+		return
+	}
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		done(0, ErrResponseTooLarge)
+		return
+	}
+
+	file, err := os.CreateTemp(c.dir, "capture-*"+tempExt)
+	if err != nil {
+		done(0, fmt.Errorf("create capture file: %w", err))
+		return
+	}
+	entry := &Entry{StatusCode: resp.StatusCode, Headers: resp.Header.Clone(), CachedAt: time.Now()}
+	if err = file.Chmod(0644); err == nil {
+		_, err = file.Write(serializePrefix(entry))
+	}
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		done(0, fmt.Errorf("write capture file: %w", err))
+		return
+	}
+
+	resp.Body = &capture{
+		ReadCloser: resp.Body,
+		cache:      c,
+		key:        key,
+		file:       file,
+		hash:       sha256.New(),
+		maxSize:    maxSize,
+		verify:     verify,
+		done:       done,
+	}
+}
+
+// capture is a response body that copies what is read from it into a
+// temporary file and stores the file as a cache entry at the end.
+type capture struct {
+	io.ReadCloser
+	cache   *Cache
+	key     string
+	file    *os.File // nil once the capture has finished
+	hash    hash.Hash
+	size    int64 // body bytes read so far
+	maxSize int64
+	verify  func(digest string) error
+	done    func(size int64, err error)
+}
+
+func (c *capture) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if c.file == nil {
+		return n, err
+	}
+	if n > 0 {
+		c.size += int64(n)
+		if c.maxSize > 0 && c.size > c.maxSize {
+			c.finish(ErrResponseTooLarge)
+			return n, err
+		}
+		c.hash.Write(p[:n])
+		if _, werr := c.file.Write(p[:n]); werr != nil {
+			c.finish(fmt.Errorf("write capture file: %w", werr))
+			return n, err
+		}
+	}
+	if err == io.EOF {
+		c.finish(nil)
+	}
+	return n, err
+}
+
+func (c *capture) Close() error {
+	if c.file != nil {
+		c.finish(ErrIncompleteResponse)
+	}
+	return c.ReadCloser.Close()
+}
+
+// finish stores the captured entry if err is nil, discards it otherwise,
+// and reports the outcome.
+func (c *capture) finish(err error) {
+	file := c.file
+	c.file = nil
+	if cerr := file.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("write capture file: %w", cerr)
+	}
+	if err == nil {
+		digest := hex.EncodeToString(c.hash.Sum(nil))
+		if err = c.verify(digest); err == nil {
+			err = c.cache.storeFile(c.key, file.Name(), digest)
+		}
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+	}
+	c.done(c.size, err)
+}
+
+// storeFile moves a complete serialized entry at tmpPath into place as the
+// entry for key, with its body's digest.
+func (c *Cache) storeFile(key, tmpPath, digest string) error {
+	info, err := os.Stat(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Replace any existing entry for the key
+	c.removeEntry(key)
+
+	hash := cacheKey(key)
+	if err := os.Rename(tmpPath, filepath.Join(c.dir, hash)); err != nil {
+		c.stats.Errors++
+		return fmt.Errorf("write cache file: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(c.dir, hash+".sha256"), []byte(digest), 0644); err != nil {
+		c.stats.Errors++
+		c.removeEntry(key)
+		return fmt.Errorf("write digest file: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(c.dir, hash+".meta"), []byte(key), 0644); err != nil {
+		c.stats.Errors++
+		c.removeEntry(key)
+		return fmt.Errorf("write meta file: %w", err)
+	}
+
+	c.stored(key, info.Size())
+	return nil
+}
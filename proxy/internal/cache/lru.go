@@ -62,12 +62,16 @@ func (idx *lruIndex) exists(key string) bool {
 	return exists
 }
 
-// remove removes an item from the index.
-func (idx *lruIndex) remove(key string) {
-	if item, exists := idx.items[key]; exists {
-		idx.list.Remove(item.element)
-		delete(idx.items, key)
+// remove removes an item from the index, returning its size (0 if it
+// wasn't indexed).
+func (idx *lruIndex) remove(key string) int64 {
+	item, exists := idx.items[key]
+	if !exists {
+		return 0
 	}
+	idx.list.Remove(item.element)
+	delete(idx.items, key)
+	return item.size
 }
 
 // evict removes and returns the least recently used item.
@@ -150,6 +150,20 @@ func (m *Matcher) GenerateKey(req *http.Request) string {
 	return req.URL.Host + req.URL.Path
 }
 
+// ExpectedDigest returns the lowercase hex sha256 digest embedded in the URL
+// path (in either format VerifyDigest recognises), or "" if there is none.
+func (m *Matcher) ExpectedDigest(path string) string {
+	matches := sha256DigestRe.FindStringSubmatch(path)
+	if len(matches) < 3 {
+		return ""
+	}
+	// matches[1] = OCI colon format, matches[2] = path-component format
+	if matches[1] != "" {
+		return strings.ToLower(matches[1])
+	}
+	return strings.ToLower(matches[2])
+}
+
 // VerifyDigest checks that body's sha256 hash matches the digest embedded in the
 // URL path. Recognises two formats:
 //   - OCI standard:          "sha256:HEX64"   (e.g. /v2/…/blobs/sha256:abc…)
@@ -158,21 +172,17 @@ func (m *Matcher) GenerateKey(req *http.Request) string {
 // Returns nil if no digest is found in the path or the digest matches.
 // Returns an error describing the mismatch otherwise.
 func (m *Matcher) VerifyDigest(path string, body []byte) error {
-	matches := sha256DigestRe.FindStringSubmatch(path)
-	if len(matches) < 2 {
-		return nil // no digest in path, nothing to verify
-	}
+	return m.CheckDigest(path, fmt.Sprintf("%x", sha256.Sum256(body)))
+}
 
-	// matches[1] = OCI colon format, matches[2] = path-component format
-	expected := strings.ToLower(matches[1])
-	if expected == "" && len(matches) > 2 {
-		expected = strings.ToLower(matches[2])
-	}
+// CheckDigest is VerifyDigest for a body whose lowercase hex sha256 digest
+// is already known, such as one captured while streaming.
+func (m *Matcher) CheckDigest(path, actual string) error {
+	expected := m.ExpectedDigest(path)
 	if expected == "" {
-		return nil
+		return nil // no digest in path, nothing to verify
 	}
 
-	actual := fmt.Sprintf("%x", sha256.Sum256(body))
 	if expected != actual {
 		return fmt.Errorf("sha256 mismatch: URL claims %s, body hashes to %s", expected, actual)
 	}
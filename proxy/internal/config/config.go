@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -18,6 +19,7 @@ type Config struct {
 	Proxy     ProxyConfig     `yaml:"proxy" json:"proxy"`
 	TLS       TLSConfig       `yaml:"tls" json:"tls"`
 	Allowlist AllowlistConfig `yaml:"allowlist" json:"allowlist"`
+	Policy    PolicyConfig    `yaml:"policy" json:"policy"`
 	Headers   HeadersConfig   `yaml:"headers" json:"headers"`
 	Logging   LoggingConfig   `yaml:"logging" json:"logging"`
 	Cache     CacheConfig     `yaml:"cache" json:"cache"`
@@ -25,10 +27,25 @@ type Config struct {
 
 // ProxyConfig contains proxy server settings.
 type ProxyConfig struct {
-	Port         int           `yaml:"port" json:"port"`
-	APIPort      int           `yaml:"api_port" json:"api_port"`
-	ReadTimeout  time.Duration `yaml:"read_timeout" json:"read_timeout"`
-	WriteTimeout time.Duration `yaml:"write_timeout" json:"write_timeout"`
+	Port                   int            `yaml:"port" json:"port"`
+	APIPort                int            `yaml:"api_port" json:"api_port"`
+	ReadTimeout            time.Duration  `yaml:"read_timeout" json:"read_timeout"`
+	WriteTimeout           time.Duration  `yaml:"write_timeout" json:"write_timeout"`
+	MaxRequestBodyBytes    int64          `yaml:"max_request_body_bytes" json:"max_request_body_bytes"`     // Default 100MB (0 = unlimited)
+	MaxResponseBodyBytes   int64          `yaml:"max_response_body_bytes" json:"max_response_body_bytes"`   // Larger responses are streamed, not cached. Default 512MB (0 = unlimited)
+	BlockOversizedRequests bool           `yaml:"block_oversized_requests" json:"block_oversized_requests"` // Reject larger request bodies with 413 instead of forwarding them
+	Upstream               UpstreamConfig `yaml:"upstream" json:"upstream"`
+}
+
+// UpstreamConfig chains the proxy to another HTTP proxy. Allowed requests
+// are forwarded through it, except to loopback hosts and hosts matching
+// NoProxy (domain patterns, IPs, or CIDRs). Leave URL empty to connect
+// directly.
+type UpstreamConfig struct {
+	URL      string   `yaml:"url" json:"url"`                               // http:// or https://, may include user:password@
+	Username string   `yaml:"username,omitempty" json:"username,omitempty"` // Overrides credentials in URL
+	Password string   `yaml:"password,omitempty" json:"-"`
+	NoProxy  []string `yaml:"no_proxy,omitempty" json:"no_proxy,omitempty"`
 }
 
 // TLSConfig contains TLS/certificate settings.
@@ -43,6 +60,14 @@ type AllowlistConfig struct {
 	IPs     []string `yaml:"ips" json:"ips"`
 }
 
+// PolicyConfig restricts which domains may be reached. Blocked domains take
+// precedence; if allowed domains are set, all others are blocked. Loopback
+// hosts are always allowed.
+type PolicyConfig struct {
+	AllowedDomains []string `yaml:"allowed_domains" json:"allowed_domains"`
+	BlockedDomains []string `yaml:"blocked_domains" json:"blocked_domains"`
+}
+
 // HeadersConfig maps domain patterns to header rules.
 type HeadersConfig map[string]HeaderRule
 
@@ -97,10 +122,12 @@ type RuntimeAllowlistConfig struct {
 func Default() *Config {
 	return &Config{
 		Proxy: ProxyConfig{
-			Port:         17080,
-			APIPort:      17081,
-			ReadTimeout:  30 * time.Second,
-			WriteTimeout: 30 * time.Second,
+			Port:                 17080,
+			APIPort:              17081,
+			ReadTimeout:          30 * time.Second,
+			WriteTimeout:         30 * time.Second,
+			MaxRequestBodyBytes:  100 * 1024 * 1024, // 100MB default
+			MaxResponseBodyBytes: 512 * 1024 * 1024, // 512MB default
 		},
 		TLS: TLSConfig{
 			CertDir: "./certs",
@@ -155,6 +182,16 @@ func (c *Config) Validate() error {
 	if c.Proxy.Port == c.Proxy.APIPort {
 		return errors.New("proxy and API ports must be different")
 	}
+	if c.Proxy.MaxRequestBodyBytes < 0 {
+		return errors.New("proxy max_request_body_bytes cannot be negative")
+	}
+	if c.Proxy.MaxResponseBodyBytes < 0 {
+		return errors.New("proxy max_response_body_bytes cannot be negative")
+	}
+
+	if err := c.Proxy.Upstream.Validate(); err != nil {
+		return fmt.Errorf("invalid proxy upstream: %w", err)
+	}
 
 	// Validate domain patterns and conditions in headers
 	for pattern, rule := range c.Headers {
@@ -173,6 +210,18 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// Validate domain patterns in policy
+	for _, pattern := range c.Policy.AllowedDomains {
+		if !IsValidDomainPattern(pattern) {
+			return fmt.Errorf("invalid policy allowed domain pattern: %s", pattern)
+		}
+	}
+	for _, pattern := range c.Policy.BlockedDomains {
+		if !IsValidDomainPattern(pattern) {
+			return fmt.Errorf("invalid policy blocked domain pattern: %s", pattern)
+		}
+	}
+
 	// Validate IPs/CIDRs in allowlist
 	for _, ip := range c.Allowlist.IPs {
 		if _, _, err := net.ParseCIDR(ip); err != nil {
@@ -253,6 +302,39 @@ func isValidDomainChar(c rune) bool {
 		c == '-' || c == '.'
 }
 
+// Validate checks if an UpstreamConfig is valid. An empty URL is valid and
+// disables chaining.
+func (u *UpstreamConfig) Validate() error {
+	if u.URL == "" {
+		if u.Username != "" || u.Password != "" || len(u.NoProxy) > 0 {
+			return errors.New("url is required")
+		}
+		return nil
+	}
+	parsed, err := url.Parse(u.URL)
+	if err != nil {
+		return errors.New("url cannot be parsed")
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("unsupported url scheme %q (use http or https)", parsed.Scheme)
+	}
+	if parsed.Hostname() == "" {
+		return errors.New("url has no host")
+	}
+	if parsed.Path != "" && parsed.Path != "/" {
+		return errors.New("url cannot have a path")
+	}
+	for _, entry := range u.NoProxy {
+		if _, _, err := net.ParseCIDR(entry); err == nil || net.ParseIP(entry) != nil {
+			continue
+		}
+		if !IsValidDomainPattern(entry) {
+			return fmt.Errorf("invalid no_proxy entry: %s", entry)
+		}
+	}
+	return nil
+}
+
 // Validate checks if a HeaderRule is valid.
 func (r *HeaderRule) Validate() error {
 	// Validate conditions
@@ -0,0 +1,88 @@
+package filter
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/obot-platform/discobot/proxy/internal/injector"
+)
+
+// Policy reasons reported in a Decision.
+const (
+	ReasonLoopback   = "loopback"
+	ReasonBlocked    = "blocked_domain"
+	ReasonNotAllowed = "domain_not_allowed"
+	ReasonAllowed    = "allowed"
+)
+
+// Decision is the result of evaluating a host against a Policy.
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Host    string `json:"host"`
+	Reason  string `json:"reason"`
+	Pattern string `json:"pattern,omitempty"` // The pattern that matched, if any
+}
+
+// Policy allows or blocks hosts by domain pattern. Blocked domains take
+// precedence over allowed ones; if allowed domains are set, any host that
+// matches none of them is blocked. Loopback hosts are always allowed.
+type Policy struct {
+	mu      sync.RWMutex
+	allowed []string
+	blocked []string
+}
+
+// NewPolicy creates a Policy that allows everything.
+func NewPolicy() *Policy {
+	return &Policy{}
+}
+
+// SetDomains replaces the allowed and blocked domain patterns.
+func (p *Policy) SetDomains(allowed, blocked []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.allowed = append([]string(nil), allowed...)
+	p.blocked = append([]string(nil), blocked...)
+}
+
+// Evaluate decides whether host (optionally with a port) may be reached.
+func (p *Policy) Evaluate(host string) Decision {
+	hostOnly, _, err := net.SplitHostPort(host)
+	if err != nil {
+		hostOnly = host
+	}
+	hostOnly = strings.TrimSuffix(strings.ToLower(hostOnly), ".")
+
+	if isLoopback(hostOnly) {
+		return Decision{Allowed: true, Host: hostOnly, Reason: ReasonLoopback}
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, pattern := range p.blocked {
+		if injector.MatchDomain(pattern, hostOnly) {
+			return Decision{Allowed: false, Host: hostOnly, Reason: ReasonBlocked, Pattern: pattern}
+		}
+	}
+
+	if len(p.allowed) == 0 {
+		return Decision{Allowed: true, Host: hostOnly, Reason: ReasonAllowed}
+	}
+	for _, pattern := range p.allowed {
+		if injector.MatchDomain(pattern, hostOnly) {
+			return Decision{Allowed: true, Host: hostOnly, Reason: ReasonAllowed, Pattern: pattern}
+		}
+	}
+	return Decision{Allowed: false, Host: hostOnly, Reason: ReasonNotAllowed}
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
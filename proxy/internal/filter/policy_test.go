@@ -0,0 +1,75 @@
+package filter
+
+import "testing"
+
+func TestPolicy_EmptyAllowsAll(t *testing.T) {
+	p := NewPolicy()
+
+	for _, host := range []string{"example.com", "api.github.com:443", "10.0.0.1"} {
+		if d := p.Evaluate(host); !d.Allowed {
+			t.Errorf("Evaluate(%q) blocked with reason %q, want allowed", host, d.Reason)
+		}
+	}
+}
+
+func TestPolicy_Evaluate(t *testing.T) {
+	p := NewPolicy()
+	p.SetDomains(
+		[]string{"*.github.com", "github.com", "registry.npmjs.org"},
+		[]string{"gist.github.com", "*.evil.com"},
+	)
+
+	tests := []struct {
+		host        string
+		wantAllowed bool
+		wantReason  string
+		wantPattern string
+	}{
+		{"github.com", true, ReasonAllowed, "github.com"},
+		{"api.github.com:443", true, ReasonAllowed, "*.github.com"},
+		{"API.GitHub.com", true, ReasonAllowed, "*.github.com"},
+		{"registry.npmjs.org", true, ReasonAllowed, "registry.npmjs.org"},
+		{"gist.github.com", false, ReasonBlocked, "gist.github.com"},
+		{"a.evil.com", false, ReasonBlocked, "*.evil.com"},
+		{"example.com", false, ReasonNotAllowed, ""},
+		{"notgithub.com", false, ReasonNotAllowed, ""},
+		{"localhost", true, ReasonLoopback, ""},
+		{"localhost:8080", true, ReasonLoopback, ""},
+		{"127.0.0.1:3000", true, ReasonLoopback, ""},
+		{"[::1]:3000", true, ReasonLoopback, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			d := p.Evaluate(tt.host)
+			if d.Allowed != tt.wantAllowed || d.Reason != tt.wantReason || d.Pattern != tt.wantPattern {
+				t.Errorf("Evaluate(%q) = %+v, want allowed=%v reason=%q pattern=%q",
+					tt.host, d, tt.wantAllowed, tt.wantReason, tt.wantPattern)
+			}
+		})
+	}
+}
+
+func TestPolicy_LoopbackCannotBeBlocked(t *testing.T) {
+	p := NewPolicy()
+	p.SetDomains([]string{"example.com"}, []string{"*", "localhost"})
+
+	for _, host := range []string{"localhost", "127.0.0.1", "::1"} {
+		if d := p.Evaluate(host); !d.Allowed {
+			t.Errorf("Evaluate(%q) blocked, want loopback always allowed", host)
+		}
+	}
+}
+
+func TestPolicy_SetDomainsReplaces(t *testing.T) {
+	p := NewPolicy()
+	p.SetDomains(nil, []string{"example.com"})
+	if p.Evaluate("example.com").Allowed {
+		t.Fatal("expected example.com to be blocked")
+	}
+
+	p.SetDomains(nil, nil)
+	if !p.Evaluate("example.com").Allowed {
+		t.Error("expected example.com to be allowed after clearing the policy")
+	}
+}
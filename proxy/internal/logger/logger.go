@@ -132,6 +132,24 @@ func (l *Logger) LogBlocked(host string, reason string) {
 	)
 }
 
+// LogPolicyDecision logs a domain policy decision. Blocks are logged at
+// info level, allows at debug.
+func (l *Logger) LogPolicyDecision(host string, allowed bool, reason, pattern string) {
+	if allowed {
+		l.sugar.Debugw("policy_allowed",
+			"host", host,
+			"reason", reason,
+			"pattern", pattern,
+		)
+	} else {
+		l.sugar.Infow("policy_blocked",
+			"host", host,
+			"reason", reason,
+			"pattern", pattern,
+		)
+	}
+}
+
 // LogHeaderInjection logs when headers are injected into a request.
 func (l *Logger) LogHeaderInjection(host, pattern string, headers []string) {
 	l.sugar.Infow("header_injection",
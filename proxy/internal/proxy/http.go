@@ -1,10 +1,16 @@
 package proxy
 
 import (
+	"bytes"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/elazarl/goproxy"
@@ -14,6 +20,7 @@ import (
 	"github.com/obot-platform/discobot/proxy/internal/filter"
 	"github.com/obot-platform/discobot/proxy/internal/injector"
 	"github.com/obot-platform/discobot/proxy/internal/logger"
+	"github.com/obot-platform/discobot/proxy/internal/stats"
 )
 
 // HTTPProxy wraps goproxy for HTTP/HTTPS proxying.
@@ -21,9 +28,26 @@ type HTTPProxy struct {
 	proxy        *goproxy.ProxyHttpServer
 	injector     *injector.Injector
 	filter       *filter.Filter
+	policy       *filter.Policy
+	upstream     *Upstream
 	logger       *logger.Logger
 	cache        *cache.Cache
 	cacheMatcher *cache.Matcher
+	stats        *stats.Collector
+
+	limitsMu sync.RWMutex
+	limits   BodyLimits
+}
+
+// BodyLimits caps the request and response bodies the proxy handles.
+// Bodies are streamed, and cached responses are copied to disk as they pass
+// through, so the limits only bound what is kept: responses over
+// MaxResponseBytes aren't cached, and requests over MaxRequestBytes are
+// rejected if BlockOversizedRequests is set.
+type BodyLimits struct {
+	MaxRequestBytes        int64 // 0 = unlimited
+	MaxResponseBytes       int64 // 0 = unlimited
+	BlockOversizedRequests bool
 }
 
 // requestMeta is stored in goproxy's ctx.UserData to carry per-request state
@@ -31,20 +55,36 @@ type HTTPProxy struct {
 type requestMeta struct {
 	startTime time.Time
 	cacheHit  bool
+	blocked   bool
 }
 
 // NewHTTPProxy creates a new HTTP proxy.
-func NewHTTPProxy(certMgr *cert.Manager, inj *injector.Injector, flt *filter.Filter, log *logger.Logger, c *cache.Cache, matcher *cache.Matcher) *HTTPProxy {
+func NewHTTPProxy(certMgr *cert.Manager, inj *injector.Injector, flt *filter.Filter, pol *filter.Policy, up *Upstream, log *logger.Logger, c *cache.Cache, matcher *cache.Matcher, st *stats.Collector, limits BodyLimits) *HTTPProxy {
 	proxy := goproxy.NewProxyHttpServer()
 	proxy.Verbose = false
 
+	// Requests that pass the filters go out through the upstream proxy, if
+	// one is configured. Without one, keep goproxy's environment defaults.
+	proxy.Tr.Proxy = up.Proxy
+	envDial := proxy.ConnectDial
+	proxy.ConnectDialWithReq = func(req *http.Request, network, addr string) (net.Conn, error) {
+		if !up.configured() && envDial != nil {
+			return envDial(network, addr)
+		}
+		return up.DialContext(req.Context(), network, addr)
+	}
+
 	h := &HTTPProxy{
 		proxy:        proxy,
 		injector:     inj,
 		filter:       flt,
+		policy:       pol,
+		upstream:     up,
 		logger:       log,
 		cache:        c,
 		cacheMatcher: matcher,
+		stats:        st,
+		limits:       limits,
 	}
 
 	h.setupMITM(certMgr)
@@ -53,6 +93,20 @@ func NewHTTPProxy(certMgr *cert.Manager, inj *injector.Injector, flt *filter.Fil
 	return h
 }
 
+// SetLimits replaces the body limits for requests that start after it returns.
+func (h *HTTPProxy) SetLimits(limits BodyLimits) {
+	h.limitsMu.Lock()
+	defer h.limitsMu.Unlock()
+	h.limits = limits
+}
+
+// bodyLimits returns the current body limits.
+func (h *HTTPProxy) bodyLimits() BodyLimits {
+	h.limitsMu.RLock()
+	defer h.limitsMu.RUnlock()
+	return h.limits
+}
+
 func (h *HTTPProxy) setupMITM(certMgr *cert.Manager) {
 	ca := certMgr.GetCA()
 
@@ -103,9 +157,16 @@ func (h *HTTPProxy) setupMITM(certMgr *cert.Manager) {
 
 func (h *HTTPProxy) setupHandlers() {
 	// Handle CONNECT requests (HTTPS)
-	h.proxy.OnRequest().HandleConnectFunc(func(host string, _ *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
+	h.proxy.OnRequest().HandleConnectFunc(func(host string, ctx *goproxy.ProxyCtx) (*goproxy.ConnectAction, string) {
 		if !h.filter.AllowHost(host) {
 			h.logger.LogBlocked(host, "filter")
+			h.stats.RecordBlocked(host)
+			return goproxy.RejectConnect, host
+		}
+		if resp := h.checkPolicy(ctx.Req, host); resp != nil {
+			// goproxy writes ctx.Resp to the client before closing a rejected CONNECT
+			ctx.Resp = resp
+			h.stats.RecordBlocked(host)
 			return goproxy.RejectConnect, host
 		}
 		return goproxy.MitmConnect, host
@@ -119,13 +180,42 @@ func (h *HTTPProxy) setupHandlers() {
 		// Filter check (for plain HTTP)
 		if !h.filter.AllowHost(req.Host) {
 			h.logger.LogBlocked(req.Host, "filter")
+			meta.blocked = true
+			h.stats.RecordBlocked(req.Host)
 			return req, goproxy.NewResponse(req, goproxy.ContentTypeText, http.StatusForbidden, "Blocked by proxy")
 		}
 
+		if resp := h.checkPolicy(req, req.Host); resp != nil {
+			meta.blocked = true
+			h.stats.RecordBlocked(req.Host)
+			return req, resp
+		}
+
+		if resp := h.checkRequestBody(req); resp != nil {
+			meta.blocked = true
+			h.stats.RecordBlocked(req.Host)
+			return req, resp
+		}
+
+		h.stats.RecordRequest(req.Host)
+		if req.Body != nil && req.Body != http.NoBody {
+			req.Body = h.stats.CountSent(req.Host, req.Body)
+		}
+
 		// Check cache
 		if h.cacheMatcher != nil && h.cacheMatcher.ShouldCache(req) {
 			key := h.cacheMatcher.GenerateKey(req)
-			if entry, err := h.cache.Get(key); err == nil {
+			// Besides matching its own stored digest, the entry must be the
+			// blob the URL asks for
+			entry, err := h.cache.GetVerified(key, h.cacheMatcher.ExpectedDigest(req.URL.Path))
+			if errors.Is(err, cache.ErrCacheCorrupt) {
+				h.logger.Warn("cached entry corrupt or not the requested digest, evicted",
+					"host", req.Host,
+					"path", req.URL.Path,
+				)
+				h.stats.RecordCacheCorruption(req.Host)
+			}
+			if err == nil {
 				meta.cacheHit = true
 				h.logger.Info("cache hit",
 					"host", req.Host,
@@ -133,9 +223,13 @@ func (h *HTTPProxy) setupHandlers() {
 					"size", entry.Size,
 					"cached_at", entry.CachedAt.Format(time.RFC3339),
 				)
-				return req, cache.RestoreResponse(entry, req)
+				h.stats.RecordCacheHit(req.Host)
+				resp := cache.RestoreResponse(entry, req)
+				resp.Body = h.stats.CountReceived(req.Host, resp.Body)
+				return req, resp
 			}
 			h.logger.Debug("cache miss", "host", req.Host, "path", req.URL.Path)
+			h.stats.RecordCacheMiss(req.Host)
 		}
 
 		// Inject headers
@@ -180,26 +274,106 @@ func (h *HTTPProxy) setupHandlers() {
 					"cache_control", resp.Header.Get("Cache-Control"),
 				)
 			} else {
-				entry, err := cache.CaptureResponse(resp)
-				if err != nil {
-					h.logger.Warn("failed to read response for caching", "path", ctx.Req.URL.Path, "error", err.Error())
-				} else if err := h.cacheMatcher.VerifyDigest(ctx.Req.URL.Path, entry.Body); err != nil {
-					h.logger.Warn("digest verification failed, not caching", "path", ctx.Req.URL.Path, "error", err.Error())
-				} else {
-					key := h.cacheMatcher.GenerateKey(ctx.Req)
-					if err := h.cache.Put(key, entry); err != nil {
-						h.logger.Warn("cache store failed", "path", ctx.Req.URL.Path, "error", err.Error())
-					} else {
-						h.logger.Info("cached response", "path", ctx.Req.URL.Path, "size", entry.Size)
+				maxBytes := h.bodyLimits().MaxResponseBytes
+				path := ctx.Req.URL.Path
+				verify := func(digest string) error { return h.cacheMatcher.CheckDigest(path, digest) }
+				// The response streams to the client while it's captured
+				h.cache.Capture(h.cacheMatcher.GenerateKey(ctx.Req), resp, maxBytes, verify, func(size int64, err error) {
+					switch {
+					case err == nil:
+						h.logger.Info("cached response", "path", path, "size", size)
+					case errors.Is(err, cache.ErrCacheDisabled):
+						// Caching is off, nothing to report
+					case errors.Is(err, cache.ErrResponseTooLarge):
+						h.logger.Info("response too large to cache",
+							"path", path,
+							"content_length", resp.ContentLength,
+							"limit", maxBytes,
+						)
+					case errors.Is(err, cache.ErrIncompleteResponse):
+						h.logger.Debug("response not read to the end, not caching", "path", path)
+					default:
+						h.logger.Warn("response not cached", "path", path, "error", err.Error())
 					}
-				}
+				})
 			}
 		}
 
+		if meta == nil || !meta.blocked {
+			resp.Body = h.stats.CountReceived(ctx.Req.Host, resp.Body)
+		}
 		return resp
 	})
 }
 
+// checkPolicy evaluates host against the domain policy and logs the
+// decision. It returns a 403 response with a JSON body explaining the block,
+// or nil if the host is allowed.
+func (h *HTTPProxy) checkPolicy(req *http.Request, host string) *http.Response {
+	if h.policy == nil {
+		return nil
+	}
+
+	d := h.policy.Evaluate(host)
+	h.logger.LogPolicyDecision(d.Host, d.Allowed, d.Reason, d.Pattern)
+	if d.Allowed {
+		return nil
+	}
+
+	body, _ := json.Marshal(struct {
+		Error string `json:"error"`
+		filter.Decision
+	}{
+		Error:    fmt.Sprintf("access to %s is blocked by proxy policy", d.Host),
+		Decision: d,
+	})
+	return goproxy.NewResponse(req, "application/json", http.StatusForbidden, string(body))
+}
+
+// checkRequestBody enforces MaxRequestBytes. Oversized bodies are only
+// logged unless BlockOversizedRequests is set, in which case it returns a 413
+// response. A body of unknown length is buffered up to the limit to find out.
+func (h *HTTPProxy) checkRequestBody(req *http.Request) *http.Response {
+	limits := h.bodyLimits()
+	limit := limits.MaxRequestBytes
+	if limit <= 0 || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	oversized := req.ContentLength > limit
+	if req.ContentLength < 0 && limits.BlockOversizedRequests {
+		body, err := io.ReadAll(io.LimitReader(req.Body, limit+1))
+		if err != nil {
+			return goproxy.NewResponse(req, goproxy.ContentTypeText, http.StatusBadRequest, "Failed to read request body")
+		}
+		oversized = int64(len(body)) > limit
+		req.Body = &bufferedBody{Reader: bytes.NewReader(body), Closer: req.Body}
+	}
+	if !oversized {
+		return nil
+	}
+
+	if !limits.BlockOversizedRequests {
+		h.logger.Warn("request body exceeds limit",
+			"host", req.Host,
+			"path", req.URL.Path,
+			"content_length", req.ContentLength,
+			"limit", limit,
+		)
+		return nil
+	}
+	h.logger.LogBlocked(req.Host, "request_body_too_large")
+	return goproxy.NewResponse(req, goproxy.ContentTypeText, http.StatusRequestEntityTooLarge,
+		fmt.Sprintf("Request body exceeds the proxy limit of %d bytes", limit))
+}
+
+// bufferedBody is a request body read into memory, keeping the original
+// body's Close.
+type bufferedBody struct {
+	io.Reader
+	io.Closer
+}
+
 // ServeConn serves an HTTP connection.
 func (h *HTTPProxy) ServeConn(conn *PeekedConn) {
 	// Create a listener that returns this single connection
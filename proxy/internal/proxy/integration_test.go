@@ -1,6 +1,10 @@
 package proxy
 
 import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -12,11 +16,15 @@ import (
 	"time"
 
 	"github.com/elazarl/goproxy"
+	"go.uber.org/zap"
 
+	"github.com/obot-platform/discobot/proxy/internal/cache"
+	"github.com/obot-platform/discobot/proxy/internal/cert"
 	"github.com/obot-platform/discobot/proxy/internal/config"
 	"github.com/obot-platform/discobot/proxy/internal/filter"
 	"github.com/obot-platform/discobot/proxy/internal/injector"
 	"github.com/obot-platform/discobot/proxy/internal/logger"
+	"github.com/obot-platform/discobot/proxy/internal/stats"
 )
 
 // testLogger creates a test logger
@@ -187,6 +195,344 @@ func TestIntegration_HTTPProxy_HeaderAppend(t *testing.T) {
 	}
 }
 
+// newTestHTTPProxy creates an HTTPProxy that caches every GET under /blobs/.
+func newTestHTTPProxy(t *testing.T, limits BodyLimits) (*HTTPProxy, *cache.Cache) {
+	t.Helper()
+	certMgr, err := cert.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cert manager: %v", err)
+	}
+	c, err := cache.New(t.TempDir(), 10*1024*1024, true, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	matcher, err := cache.NewMatcher([]string{`^/blobs/`}, false)
+	if err != nil {
+		t.Fatalf("Failed to create cache matcher: %v", err)
+	}
+	return NewHTTPProxy(certMgr, injector.New(), filter.New(), filter.NewPolicy(), nil, testLogger(t), c, matcher, stats.New(), limits), c
+}
+
+func TestIntegration_HTTPProxy_ResponseBodyLimit(t *testing.T) {
+	small := strings.Repeat("s", 512)
+	large := strings.Repeat("L", 64*1024)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/blobs/small":
+			_, _ = io.WriteString(w, small)
+		case "/blobs/large":
+			_, _ = io.WriteString(w, large)
+		case "/blobs/large-chunked":
+			// Flushing first omits Content-Length, so the size isn't known up front
+			w.(http.Flusher).Flush()
+			_, _ = io.WriteString(w, large)
+		}
+	}))
+	defer backend.Close()
+
+	h, c := newTestHTTPProxy(t, BodyLimits{MaxResponseBytes: 1024})
+	proxyServer := httptest.NewServer(h.GetProxy())
+	defer proxyServer.Close()
+
+	proxyURL, _ := url.Parse(proxyServer.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}, Timeout: 5 * time.Second}
+
+	get := func(path string) (string, string) {
+		t.Helper()
+		resp, err := client.Get(backend.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s through proxy failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("Reading %s response failed: %v", path, err)
+		}
+		return string(body), resp.Header.Get("X-Cache")
+	}
+
+	for _, tt := range []struct {
+		path       string
+		want       string
+		wantCached bool
+	}{
+		{"/blobs/small", small, true},
+		{"/blobs/large", large, false},
+		{"/blobs/large-chunked", large, false},
+	} {
+		for i := range 2 {
+			body, xCache := get(tt.path)
+			if body != tt.want {
+				t.Errorf("GET %s #%d: got %d bytes, want %d", tt.path, i+1, len(body), len(tt.want))
+			}
+			if cached := xCache == "HIT"; i == 1 && cached != tt.wantCached {
+				t.Errorf("GET %s #2: cache hit = %v, want %v", tt.path, cached, tt.wantCached)
+			}
+		}
+	}
+
+	if stats := c.GetStats(); stats.Stores != 1 {
+		t.Errorf("cache stores = %d, want 1 (only the small response)", stats.Stores)
+	}
+}
+
+func TestIntegration_HTTPProxy_CacheDigestMismatch(t *testing.T) {
+	const body = "layer data"
+	path := fmt.Sprintf("/blobs/sha256:%x", sha256.Sum256([]byte(body)))
+	var upstreamHits int
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHits++
+		_, _ = io.WriteString(w, body)
+	}))
+	defer backend.Close()
+
+	h, c := newTestHTTPProxy(t, BodyLimits{})
+	proxyServer := httptest.NewServer(h.GetProxy())
+	defer proxyServer.Close()
+
+	// Poison the cache with content that doesn't match the URL's digest
+	key := strings.TrimPrefix(backend.URL, "http://") + path
+	if err := c.Put(key, &cache.Entry{StatusCode: http.StatusOK, Headers: http.Header{}, Body: []byte("poisoned")}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	proxyURL, _ := url.Parse(proxyServer.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}, Timeout: 5 * time.Second}
+	for i, wantCache := range []string{"", "HIT"} {
+		resp, err := client.Get(backend.URL + path)
+		if err != nil {
+			t.Fatalf("GET #%d through proxy failed: %v", i+1, err)
+		}
+		got, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if string(got) != body {
+			t.Errorf("GET #%d: body = %q, want %q", i+1, got, body)
+		}
+		if xCache := resp.Header.Get("X-Cache"); xCache != wantCache {
+			t.Errorf("GET #%d: X-Cache = %q, want %q", i+1, xCache, wantCache)
+		}
+	}
+
+	if upstreamHits != 1 {
+		t.Errorf("upstream hits = %d, want 1 (the poisoned entry refetched, then cached)", upstreamHits)
+	}
+	if stats := c.GetStats(); stats.Corruptions != 1 || stats.Misses != 1 || stats.Hits != 1 {
+		t.Errorf("cache stats = %+v, want 1 corruption counted as a miss, and 1 hit", stats)
+	}
+	if totals := h.stats.Snapshot().Totals; totals.CacheCorruptions != 1 || totals.CacheMisses != 1 || totals.CacheHits != 1 {
+		t.Errorf("stats totals = %+v, want 1 corruption, 1 miss, 1 hit", totals)
+	}
+}
+
+func TestIntegration_HTTPProxy_RequestBodyLimit(t *testing.T) {
+	var received []string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	large := strings.Repeat("L", 2048)
+	tests := []struct {
+		name       string
+		block      bool
+		body       io.Reader
+		wantStatus int
+	}{
+		{"within limit", true, strings.NewReader("small"), http.StatusOK},
+		{"oversized, not blocked", false, strings.NewReader(large), http.StatusOK},
+		{"oversized, blocked", true, strings.NewReader(large), http.StatusRequestEntityTooLarge},
+		// Wrapping the reader hides its length, so the body is sent chunked
+		{"oversized chunked, blocked", true, io.MultiReader(strings.NewReader(large)), http.StatusRequestEntityTooLarge},
+		{"small chunked, blocked", true, io.MultiReader(strings.NewReader("small")), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received = nil
+			h, _ := newTestHTTPProxy(t, BodyLimits{MaxRequestBytes: 1024, BlockOversizedRequests: tt.block})
+			proxyServer := httptest.NewServer(h.GetProxy())
+			defer proxyServer.Close()
+
+			proxyURL, _ := url.Parse(proxyServer.URL)
+			client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}, Timeout: 5 * time.Second}
+
+			resp, err := client.Post(backend.URL+"/upload", "application/octet-stream", tt.body)
+			if err != nil {
+				t.Fatalf("POST through proxy failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusRequestEntityTooLarge {
+				if len(received) != 0 {
+					t.Error("blocked request reached the backend")
+				}
+				return
+			}
+			if len(received) != 1 || (received[0] != "small" && received[0] != large) {
+				t.Errorf("backend received %d requests, want the full body once", len(received))
+			}
+		})
+	}
+
+	// Limits changed by a config reload apply to the next request
+	h, _ := newTestHTTPProxy(t, BodyLimits{})
+	proxyServer := httptest.NewServer(h.GetProxy())
+	defer proxyServer.Close()
+	proxyURL, _ := url.Parse(proxyServer.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}, Timeout: 5 * time.Second}
+	h.SetLimits(BodyLimits{MaxRequestBytes: 1024, BlockOversizedRequests: true})
+	resp, err := client.Post(backend.URL+"/upload", "application/octet-stream", strings.NewReader(large))
+	if err != nil {
+		t.Fatalf("POST through proxy failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("status after SetLimits = %d, want %d", resp.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestIntegration_HTTPProxy_DomainPolicy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello from "+r.Host)
+	}))
+	defer backend.Close()
+	backendAddr := strings.TrimPrefix(backend.URL, "http://")
+
+	h, _ := newTestHTTPProxy(t, BodyLimits{})
+	h.policy.SetDomains([]string{"*.allowed.test"}, []string{"blocked.allowed.test"})
+	// Route every upstream hostname to the backend
+	h.GetProxy().Tr = &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, backendAddr)
+		},
+	}
+	proxyServer := httptest.NewServer(h.GetProxy())
+	defer proxyServer.Close()
+
+	proxyURL, _ := url.Parse(proxyServer.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}, Timeout: 5 * time.Second}
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+		wantReason string
+	}{
+		{"allowed wildcard", "http://api.allowed.test/", http.StatusOK, ""},
+		{"blocked overrides allowed", "http://blocked.allowed.test/", http.StatusForbidden, filter.ReasonBlocked},
+		{"not in allowed domains", "http://example.test/", http.StatusForbidden, filter.ReasonNotAllowed},
+		{"loopback always allowed", backend.URL + "/", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Get(tt.url)
+			if err != nil {
+				t.Fatalf("GET %s through proxy failed: %v", tt.url, err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusForbidden {
+				return
+			}
+
+			var body struct {
+				Error  string `json:"error"`
+				Host   string `json:"host"`
+				Reason string `json:"reason"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode block response: %v", err)
+			}
+			if body.Reason != tt.wantReason || body.Error == "" {
+				t.Errorf("block response = %+v, want reason %q", body, tt.wantReason)
+			}
+		})
+	}
+
+	t.Run("CONNECT blocked", func(t *testing.T) {
+		conn, err := net.Dial("tcp", strings.TrimPrefix(proxyServer.URL, "http://"))
+		if err != nil {
+			t.Fatalf("dial proxy: %v", err)
+		}
+		defer conn.Close()
+
+		_, _ = fmt.Fprintf(conn, "CONNECT blocked.allowed.test:443 HTTP/1.1\r\nHost: blocked.allowed.test:443\r\n\r\n")
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatalf("read CONNECT response: %v", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("CONNECT status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+	})
+}
+
+func TestIntegration_HTTPProxy_Stats(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = io.WriteString(w, "0123456789")
+	}))
+	defer backend.Close()
+	backendAddr := strings.TrimPrefix(backend.URL, "http://")
+
+	h, _ := newTestHTTPProxy(t, BodyLimits{})
+	h.policy.SetDomains(nil, []string{"blocked.test"})
+	h.GetProxy().Tr = &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, backendAddr)
+		},
+	}
+	proxyServer := httptest.NewServer(h.GetProxy())
+	defer proxyServer.Close()
+
+	proxyURL, _ := url.Parse(proxyServer.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}, Timeout: 5 * time.Second}
+
+	do := func(method, target, body string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, target, strings.NewReader(body))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s through proxy failed: %v", method, target, err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+
+	do(http.MethodPost, "http://api.example.test/upload", "hello")
+	do(http.MethodGet, "http://api.example.test/blobs/a", "")
+	do(http.MethodGet, "http://api.example.test/blobs/a", "")
+	do(http.MethodGet, "http://blocked.test/", "")
+
+	snap := h.stats.Snapshot()
+	api := snap.Domains["api.example.test"]
+	if api.Requests != 3 || api.BytesSent != 5 || api.BytesReceived != 30 {
+		t.Errorf("api.example.test stats = %+v, want 3 requests, 5 bytes sent, 30 received", api)
+	}
+	if api.CacheHits != 1 || api.CacheMisses != 1 || api.CacheHitRate != 0.5 {
+		t.Errorf("api.example.test cache stats = %+v, want 1 hit and 1 miss", api)
+	}
+	if blocked := snap.Domains["blocked.test"]; blocked.Requests != 1 || blocked.Blocked != 1 || blocked.BytesReceived != 0 {
+		t.Errorf("blocked.test stats = %+v, want 1 blocked request", blocked)
+	}
+}
+
 func TestIntegration_SOCKS5Proxy_TCP(t *testing.T) {
 	// Create a simple TCP echo server
 	echoListener, err := net.Listen("tcp", "127.0.0.1:0")
@@ -213,7 +559,8 @@ func TestIntegration_SOCKS5Proxy_TCP(t *testing.T) {
 	// Start SOCKS5 proxy
 	log := testLogger(t)
 	flt := filter.New()
-	socksProxy := NewSOCKSProxy(flt, log)
+	st := stats.New()
+	socksProxy := NewSOCKSProxy(flt, nil, nil, log, st)
 
 	socksListener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -300,6 +647,12 @@ func TestIntegration_SOCKS5Proxy_TCP(t *testing.T) {
 	if string(echoBuf) != testData {
 		t.Errorf("Echo response = %q, want %q", echoBuf, testData)
 	}
+
+	// Tunneled bytes are counted against the requested host
+	d := st.Snapshot().Domains[host]
+	if d.Requests != 1 || d.BytesSent != int64(len(testData)) || d.BytesReceived != int64(len(testData)) {
+		t.Errorf("stats for %s = %+v, want 1 request and %d bytes each way", host, d, len(testData))
+	}
 }
 
 func TestIntegration_SOCKS5Proxy_Filter(t *testing.T) {
@@ -308,7 +661,7 @@ func TestIntegration_SOCKS5Proxy_Filter(t *testing.T) {
 	flt := filter.New()
 	flt.SetEnabled(true)
 	flt.SetAllowlist([]string{"allowed.example.com"}, nil)
-	socksProxy := NewSOCKSProxy(flt, log)
+	socksProxy := NewSOCKSProxy(flt, nil, nil, log, nil)
 
 	socksListener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -368,7 +721,7 @@ func TestIntegration_ProtocolDetection_SOCKS5(t *testing.T) {
 	// Start multi-protocol server
 	log := testLogger(t)
 	flt := filter.New()
-	socksProxy := NewSOCKSProxy(flt, log)
+	socksProxy := NewSOCKSProxy(flt, nil, nil, log, nil)
 
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -450,7 +803,7 @@ func TestIntegration_SSHOverSOCKS5(t *testing.T) {
 	// Start SOCKS5 proxy
 	log := testLogger(t)
 	flt := filter.New()
-	socksProxy := NewSOCKSProxy(flt, log)
+	socksProxy := NewSOCKSProxy(flt, nil, nil, log, nil)
 
 	socksListener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -553,7 +906,7 @@ func TestIntegration_MySQLOverSOCKS5(t *testing.T) {
 	// Start SOCKS5 proxy
 	log := testLogger(t)
 	flt := filter.New()
-	socksProxy := NewSOCKSProxy(flt, log)
+	socksProxy := NewSOCKSProxy(flt, nil, nil, log, nil)
 
 	socksListener, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
@@ -12,6 +12,7 @@ import (
 	"github.com/obot-platform/discobot/proxy/internal/filter"
 	"github.com/obot-platform/discobot/proxy/internal/injector"
 	"github.com/obot-platform/discobot/proxy/internal/logger"
+	"github.com/obot-platform/discobot/proxy/internal/stats"
 )
 
 // Server is the main proxy server with protocol detection.
@@ -22,10 +23,13 @@ type Server struct {
 	socksProxy   *SOCKSProxy
 	injector     *injector.Injector
 	filter       *filter.Filter
+	policy       *filter.Policy
+	upstream     *Upstream
 	logger       *logger.Logger
 	certMgr      *cert.Manager
 	cache        *cache.Cache
 	cacheMatcher *cache.Matcher
+	stats        *stats.Collector
 
 	mu       sync.RWMutex
 	running  bool
@@ -42,6 +46,9 @@ func New(cfg *config.Config, log *logger.Logger) (*Server, error) {
 
 	inj := injector.New()
 	flt := filter.New()
+	pol := filter.NewPolicy()
+	up := NewUpstream()
+	st := stats.New()
 
 	// Initialize cache
 	c, err := cache.New(cfg.Cache.Dir, cfg.Cache.MaxSize, cfg.Cache.Enabled, log.Zap())
@@ -62,15 +69,18 @@ func New(cfg *config.Config, log *logger.Logger) (*Server, error) {
 		cfg:          cfg,
 		injector:     inj,
 		filter:       flt,
+		policy:       pol,
+		upstream:     up,
 		logger:       log,
 		certMgr:      certMgr,
 		cache:        c,
 		cacheMatcher: matcher,
+		stats:        st,
 		shutdown:     make(chan struct{}),
 	}
 
-	s.httpProxy = NewHTTPProxy(certMgr, inj, flt, log, c, matcher)
-	s.socksProxy = NewSOCKSProxy(flt, log)
+	s.httpProxy = NewHTTPProxy(certMgr, inj, flt, pol, up, log, c, matcher, st, bodyLimits(cfg))
+	s.socksProxy = NewSOCKSProxy(flt, pol, up, log, st)
 
 	// Apply initial configuration
 	s.ApplyConfig(cfg)
@@ -86,6 +96,22 @@ func (s *Server) ApplyConfig(cfg *config.Config) {
 	s.injector.SetRules(cfg.Headers)
 	s.filter.SetEnabled(cfg.Allowlist.Enabled)
 	s.filter.SetAllowlist(cfg.Allowlist.Domains, cfg.Allowlist.IPs)
+	s.policy.SetDomains(cfg.Policy.AllowedDomains, cfg.Policy.BlockedDomains)
+	s.httpProxy.SetLimits(bodyLimits(cfg))
+	if err := s.upstream.Set(cfg.Proxy.Upstream); err != nil {
+		s.logger.Error("invalid upstream proxy, keeping the previous one", "error", err)
+	} else if upstream := s.upstream.String(); upstream != "" {
+		s.logger.Info("chaining through upstream proxy", "upstream", upstream)
+	}
+}
+
+// bodyLimits returns the HTTP proxy's body limits from cfg.
+func bodyLimits(cfg *config.Config) BodyLimits {
+	return BodyLimits{
+		MaxRequestBytes:        cfg.Proxy.MaxRequestBodyBytes,
+		MaxResponseBytes:       cfg.Proxy.MaxResponseBodyBytes,
+		BlockOversizedRequests: cfg.Proxy.BlockOversizedRequests,
+	}
 }
 
 // ApplyRuntimeConfig applies runtime configuration from API.
@@ -229,6 +255,11 @@ func (s *Server) GetFilter() *filter.Filter {
 	return s.filter
 }
 
+// GetStats returns the traffic statistics collector.
+func (s *Server) GetStats() *stats.Collector {
+	return s.stats
+}
+
 // GetCACertPath returns the path to the CA certificate.
 func (s *Server) GetCACertPath() string {
 	return s.certMgr.GetCACertPath()
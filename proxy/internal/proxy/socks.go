@@ -4,29 +4,38 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"strconv"
 
 	"github.com/things-go/go-socks5"
 
 	"github.com/obot-platform/discobot/proxy/internal/filter"
 	"github.com/obot-platform/discobot/proxy/internal/logger"
+	"github.com/obot-platform/discobot/proxy/internal/stats"
 )
 
 // SOCKSProxy wraps go-socks5 for SOCKS5 proxying.
 type SOCKSProxy struct {
-	server *socks5.Server
-	filter *filter.Filter
-	logger *logger.Logger
+	server   *socks5.Server
+	filter   *filter.Filter
+	policy   *filter.Policy
+	upstream *Upstream
+	logger   *logger.Logger
+	stats    *stats.Collector
 }
 
 // NewSOCKSProxy creates a new SOCKS5 proxy.
-func NewSOCKSProxy(flt *filter.Filter, log *logger.Logger) *SOCKSProxy {
+func NewSOCKSProxy(flt *filter.Filter, pol *filter.Policy, up *Upstream, log *logger.Logger, st *stats.Collector) *SOCKSProxy {
 	s := &SOCKSProxy{
-		filter: flt,
-		logger: log,
+		filter:   flt,
+		policy:   pol,
+		upstream: up,
+		logger:   log,
+		stats:    st,
 	}
 
 	s.server = socks5.NewServer(
-		socks5.WithRule(&filterRule{filter: flt, logger: log}),
+		socks5.WithRule(&filterRule{filter: flt, policy: pol, logger: log, stats: st}),
+		socks5.WithDialAndRequest(s.dial),
 		socks5.WithLogger(&socksLogger{logger: log}),
 		// No authentication required
 		socks5.WithAuthMethods([]socks5.Authenticator{
@@ -42,23 +51,53 @@ func (s *SOCKSProxy) ServeConn(conn net.Conn) error {
 	return s.server.ServeConn(conn)
 }
 
-// filterRule implements socks5.RuleSet for allowlist filtering.
+// dial connects to the destination, through the upstream proxy if one is
+// configured, and counts the tunneled traffic against the requested host.
+func (s *SOCKSProxy) dial(ctx context.Context, network, addr string, req *socks5.Request) (net.Conn, error) {
+	if req.DestAddr.FQDN != "" && s.upstream.configured() {
+		// Let the upstream resolve the name, as it would for HTTP clients
+		addr = net.JoinHostPort(req.DestAddr.FQDN, strconv.Itoa(req.DestAddr.Port))
+	}
+	conn, err := s.upstream.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	host := socksHost(req)
+	s.stats.RecordRequest(host)
+	return s.stats.CountConn(host, conn), nil
+}
+
+// socksHost returns the destination host of a SOCKS request, preferring the
+// requested domain name over the resolved IP.
+func socksHost(req *socks5.Request) string {
+	if req.DestAddr.FQDN != "" {
+		return req.DestAddr.FQDN
+	}
+	return req.DestAddr.IP.String()
+}
+
+// filterRule implements socks5.RuleSet for allowlist and domain policy filtering.
 type filterRule struct {
 	filter *filter.Filter
+	policy *filter.Policy
 	logger *logger.Logger
+	stats  *stats.Collector
 }
 
 // Allow checks if a connection is allowed.
 func (r *filterRule) Allow(ctx context.Context, req *socks5.Request) (context.Context, bool) {
-	var host string
-	if req.DestAddr.FQDN != "" {
-		host = req.DestAddr.FQDN
-	} else {
-		host = req.DestAddr.IP.String()
-	}
+	host := socksHost(req)
 
 	allowed := r.filter.AllowHost(host)
+	if allowed && r.policy != nil {
+		d := r.policy.Evaluate(host)
+		r.logger.LogPolicyDecision(d.Host, d.Allowed, d.Reason, d.Pattern)
+		allowed = d.Allowed
+	}
 	r.logger.LogSOCKSConnect(host, req.DestAddr.Port, allowed)
+	if !allowed {
+		r.stats.RecordBlocked(host)
+	}
 
 	return ctx, allowed
 }
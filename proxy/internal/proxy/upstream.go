@@ -0,0 +1,240 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/obot-platform/discobot/proxy/internal/config"
+	"github.com/obot-platform/discobot/proxy/internal/injector"
+)
+
+// Upstream chains outbound connections through another HTTP proxy. Plain
+// HTTP requests are forwarded to it as proxy requests, everything else is
+// tunneled with CONNECT. Loopback hosts and hosts matching the no_proxy
+// list are always reached directly. A nil or unconfigured Upstream falls
+// back to the HTTPS_PROXY/HTTP_PROXY/NO_PROXY environment for HTTP and
+// dials directly otherwise. Safe for concurrent use; Set may be called
+// while the proxy is serving.
+type Upstream struct {
+	mu      sync.RWMutex
+	url     *url.URL // nil = not configured
+	noProxy []string // Domain patterns
+	noNets  []*net.IPNet
+}
+
+// NewUpstream creates an unconfigured Upstream.
+func NewUpstream() *Upstream {
+	return &Upstream{}
+}
+
+// Set replaces the upstream configuration. Username and password override
+// credentials in the URL. An empty URL removes the upstream.
+func (u *Upstream) Set(cfg config.UpstreamConfig) error {
+	var (
+		proxyURL *url.URL
+		noProxy  []string
+		noNets   []*net.IPNet
+	)
+	if cfg.URL != "" {
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+		parsed, err := url.Parse(cfg.URL)
+		if err != nil {
+			return err
+		}
+		parsed.Path = ""
+		if cfg.Username != "" || cfg.Password != "" {
+			parsed.User = url.UserPassword(cfg.Username, cfg.Password)
+		}
+		proxyURL = parsed
+
+		for _, entry := range cfg.NoProxy {
+			if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+				noNets = append(noNets, ipNet)
+				continue
+			}
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 8 * len(ip.To16())
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+				noNets = append(noNets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+			noProxy = append(noProxy, entry)
+		}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.url = proxyURL
+	u.noProxy = noProxy
+	u.noNets = noNets
+	return nil
+}
+
+// configured reports whether an upstream proxy is set.
+func (u *Upstream) configured() bool {
+	if u == nil {
+		return false
+	}
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.url != nil
+}
+
+// String returns the upstream proxy URL with its password redacted, or ""
+// if none is configured.
+func (u *Upstream) String() string {
+	if u == nil {
+		return ""
+	}
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	if u.url == nil {
+		return ""
+	}
+	return u.url.Redacted()
+}
+
+// For returns the upstream proxy to use for host (with or without a port),
+// or nil if host should be reached directly.
+func (u *Upstream) For(host string) *url.URL {
+	if u == nil {
+		return nil
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	if u.url == nil || host == "" || host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.IsLoopback() {
+			return nil
+		}
+		for _, ipNet := range u.noNets {
+			if ipNet.Contains(ip) {
+				return nil
+			}
+		}
+		return u.url
+	}
+	for _, pattern := range u.noProxy {
+		if injector.MatchDomain(pattern, host) {
+			return nil
+		}
+	}
+	return u.url
+}
+
+// Proxy is an http.Transport Proxy function that routes requests through
+// the upstream.
+func (u *Upstream) Proxy(req *http.Request) (*url.URL, error) {
+	if !u.configured() {
+		return http.ProxyFromEnvironment(req)
+	}
+	return u.For(req.URL.Host), nil
+}
+
+// DialContext connects to addr, through a CONNECT tunnel on the upstream
+// unless addr should be reached directly.
+func (u *Upstream) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	proxyURL := u.For(addr)
+	if proxyURL == nil {
+		return d.DialContext(ctx, network, addr)
+	}
+
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+	conn, err := d.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("dial upstream proxy: %w", err)
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: proxyURL.Hostname(), MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("upstream proxy TLS handshake: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	// Abort the handshake if ctx ends while waiting on the upstream
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	tunnel, err := connectThrough(conn, addr, proxyURL.User)
+	if !stop() {
+		_ = conn.Close()
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tunnel, nil
+}
+
+// connectThrough asks the proxy on conn to open a tunnel to addr.
+func connectThrough(conn net.Conn, addr string, user *url.Userinfo) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("send CONNECT to upstream proxy: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("read CONNECT response from upstream proxy: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream proxy refused CONNECT to %s: %s", addr, resp.Status)
+	}
+
+	// The upstream may have sent tunneled bytes along with its response
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: io.MultiReader(br, conn)}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn whose reads drain a buffer first.
+type bufferedConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
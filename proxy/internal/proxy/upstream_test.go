@@ -0,0 +1,199 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/discobot/proxy/internal/cert"
+	"github.com/obot-platform/discobot/proxy/internal/config"
+	"github.com/obot-platform/discobot/proxy/internal/filter"
+	"github.com/obot-platform/discobot/proxy/internal/injector"
+	"github.com/obot-platform/discobot/proxy/internal/stats"
+)
+
+// fakeUpstream is an HTTP proxy that answers plain requests itself and
+// echoes bytes back over CONNECT tunnels, recording what it was asked for.
+type fakeUpstream struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	hosts []string
+	auths []string
+}
+
+func newFakeUpstream(t *testing.T) *fakeUpstream {
+	t.Helper()
+	f := &fakeUpstream{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.hosts = append(f.hosts, r.Host)
+		f.auths = append(f.auths, r.Header.Get("Proxy-Authorization"))
+		f.mu.Unlock()
+
+		if r.Method != http.MethodConnect {
+			_, _ = io.WriteString(w, "upstream served "+r.URL.String())
+			return
+		}
+		if r.Host == "refused.test:443" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		conn, buf, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+		_, _ = io.Copy(conn, buf)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeUpstream) requests() (hosts, auths []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.hosts...), append([]string(nil), f.auths...)
+}
+
+func basicAuth(user, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+func TestUpstream_For(t *testing.T) {
+	up := NewUpstream()
+	if up.For("example.com:443") != nil {
+		t.Fatal("unconfigured upstream should connect directly")
+	}
+
+	if err := up.Set(config.UpstreamConfig{
+		URL:     "http://corp-proxy.test:3128",
+		NoProxy: []string{"*.internal.test", "10.0.0.0/8", "192.168.1.1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host   string
+		direct bool
+	}{
+		{"example.com", false},
+		{"example.com:443", false},
+		{"api.internal.test:443", true},
+		{"internal.test", false},
+		{"10.1.2.3:80", true},
+		{"192.168.1.1", true},
+		{"192.168.1.2", false},
+		{"localhost:8080", true},
+		{"127.0.0.1", true},
+		{"[::1]:443", true},
+	}
+	for _, tt := range tests {
+		if got := up.For(tt.host) == nil; got != tt.direct {
+			t.Errorf("For(%q) direct = %v, want %v", tt.host, got, tt.direct)
+		}
+	}
+
+	if err := up.Set(config.UpstreamConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if up.For("example.com") != nil {
+		t.Error("clearing the upstream should connect directly")
+	}
+}
+
+func TestUpstream_DialContext(t *testing.T) {
+	f := newFakeUpstream(t)
+	up := NewUpstream()
+	if err := up.Set(config.UpstreamConfig{URL: f.URL, Username: "user", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := up.String(); strings.Contains(got, "secret") {
+		t.Errorf("String() = %q, leaks the password", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := up.DialContext(ctx, "tcp", "api.example.test:443")
+	if err != nil {
+		t.Fatalf("DialContext: %v", err)
+	}
+	defer conn.Close()
+	_, _ = io.WriteString(conn, "ping\n")
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Fatalf("tunnel echoed %q, %v", line, err)
+	}
+
+	hosts, auths := f.requests()
+	if len(hosts) != 1 || hosts[0] != "api.example.test:443" {
+		t.Errorf("upstream got CONNECT for %v", hosts)
+	}
+	if auths[0] != basicAuth("user", "secret") {
+		t.Errorf("Proxy-Authorization = %q", auths[0])
+	}
+
+	if _, err := up.DialContext(ctx, "tcp", "refused.test:443"); err == nil {
+		t.Error("expected an error when the upstream refuses the tunnel")
+	}
+}
+
+func TestIntegration_HTTPProxy_Upstream(t *testing.T) {
+	f := newFakeUpstream(t)
+	u, _ := url.Parse(f.URL)
+	u.User = url.UserPassword("user", "secret")
+
+	up := NewUpstream()
+	if err := up.Set(config.UpstreamConfig{URL: u.String()}); err != nil {
+		t.Fatal(err)
+	}
+	certMgr, err := cert.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cert manager: %v", err)
+	}
+	pol := filter.NewPolicy()
+	pol.SetDomains(nil, []string{"blocked.test"})
+	h := NewHTTPProxy(certMgr, injector.New(), filter.New(), pol, up, testLogger(t), nil, nil, stats.New(), BodyLimits{})
+
+	proxyServer := httptest.NewServer(h.GetProxy())
+	defer proxyServer.Close()
+	proxyURL, _ := url.Parse(proxyServer.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}, Timeout: 5 * time.Second}
+
+	resp, err := client.Get("http://api.example.test/path")
+	if err != nil {
+		t.Fatalf("GET through proxy failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "upstream served http://api.example.test/path" {
+		t.Fatalf("got %d %q, want the upstream's response", resp.StatusCode, body)
+	}
+
+	// Blocked requests never reach the upstream
+	resp, err = client.Get("http://blocked.test/")
+	if err != nil {
+		t.Fatalf("GET through proxy failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("blocked status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	hosts, auths := f.requests()
+	if len(hosts) != 1 || hosts[0] != "api.example.test" {
+		t.Errorf("upstream got requests for %v, want only api.example.test", hosts)
+	}
+	if len(auths) == 1 && auths[0] != basicAuth("user", "secret") {
+		t.Errorf("Proxy-Authorization = %q", auths[0])
+	}
+}
@@ -0,0 +1,225 @@
+// Package stats aggregates per-domain traffic statistics for the proxy.
+package stats
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DomainStats holds the traffic counters for one domain.
+type DomainStats struct {
+	Requests         int64   `json:"requests"`       // Requests and SOCKS connections, including blocked ones
+	Blocked          int64   `json:"blocked"`        // Requests rejected by the allowlist, policy, or body limits
+	BytesSent        int64   `json:"bytes_sent"`     // Request body bytes sent upstream
+	BytesReceived    int64   `json:"bytes_received"` // Response body bytes returned to the client, including cache hits
+	CacheHits        int64   `json:"cache_hits"`
+	CacheMisses      int64   `json:"cache_misses"`
+	CacheHitRate     float64 `json:"cache_hit_rate"`
+	CacheCorruptions int64   `json:"cache_corruptions"` // Cached responses evicted because they failed digest verification
+}
+
+// Snapshot is a point-in-time copy of the collected statistics.
+type Snapshot struct {
+	Since   time.Time              `json:"since"`
+	Totals  DomainStats            `json:"totals"`
+	Domains map[string]DomainStats `json:"domains"`
+}
+
+// MaxDomains caps how many domains are tracked separately, since the
+// domains a sandbox can reach (and so the map's size) are chosen by the
+// code running in it. Traffic to further domains is counted under
+// OtherDomains.
+const MaxDomains = 1000
+
+// OtherDomains is the key that collects traffic to domains beyond
+// MaxDomains.
+const OtherDomains = "(other)"
+
+// Collector records traffic statistics by domain. A nil Collector discards
+// everything, so callers don't need to check for one.
+type Collector struct {
+	mu      sync.Mutex
+	since   time.Time
+	domains map[string]*DomainStats
+}
+
+// New creates an empty Collector.
+func New() *Collector {
+	return &Collector{
+		since:   time.Now(),
+		domains: make(map[string]*DomainStats),
+	}
+}
+
+// RecordRequest counts a request (or SOCKS connection) to host.
+func (c *Collector) RecordRequest(host string) {
+	c.update(host, func(d *DomainStats) { d.Requests++ })
+}
+
+// RecordBlocked counts a request to host that the proxy rejected.
+func (c *Collector) RecordBlocked(host string) {
+	c.update(host, func(d *DomainStats) {
+		d.Requests++
+		d.Blocked++
+	})
+}
+
+// RecordCacheHit counts a request to host served from the cache.
+func (c *Collector) RecordCacheHit(host string) {
+	c.update(host, func(d *DomainStats) { d.CacheHits++ })
+}
+
+// RecordCacheMiss counts a cacheable request to host that wasn't cached.
+func (c *Collector) RecordCacheMiss(host string) {
+	c.update(host, func(d *DomainStats) { d.CacheMisses++ })
+}
+
+// RecordCacheCorruption counts a cached response for host that failed digest
+// verification and was evicted instead of served.
+func (c *Collector) RecordCacheCorruption(host string) {
+	c.update(host, func(d *DomainStats) { d.CacheCorruptions++ })
+}
+
+// AddBytesSent adds n bytes sent upstream to host.
+func (c *Collector) AddBytesSent(host string, n int64) {
+	c.update(host, func(d *DomainStats) { d.BytesSent += n })
+}
+
+// AddBytesReceived adds n bytes received from host.
+func (c *Collector) AddBytesReceived(host string, n int64) {
+	c.update(host, func(d *DomainStats) { d.BytesReceived += n })
+}
+
+// CountSent wraps a request body so bytes read from it are counted as sent
+// to host.
+func (c *Collector) CountSent(host string, body io.ReadCloser) io.ReadCloser {
+	if c == nil || body == nil {
+		return body
+	}
+	return &countingBody{ReadCloser: body, add: func(n int64) { c.AddBytesSent(host, n) }}
+}
+
+// CountReceived wraps a response body so bytes read from it are counted as
+// received from host.
+func (c *Collector) CountReceived(host string, body io.ReadCloser) io.ReadCloser {
+	if c == nil || body == nil {
+		return body
+	}
+	return &countingBody{ReadCloser: body, add: func(n int64) { c.AddBytesReceived(host, n) }}
+}
+
+// CountConn wraps a tunneled connection to host so writes are counted as
+// sent and reads as received.
+func (c *Collector) CountConn(host string, conn net.Conn) net.Conn {
+	if c == nil {
+		return conn
+	}
+	return &countingConn{Conn: conn, host: host, stats: c}
+}
+
+// Snapshot returns a copy of the current statistics.
+func (c *Collector) Snapshot() Snapshot {
+	if c == nil {
+		return Snapshot{Domains: map[string]DomainStats{}}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	snap := Snapshot{
+		Since:   c.since,
+		Domains: make(map[string]DomainStats, len(c.domains)),
+	}
+	for host, d := range c.domains {
+		ds := *d
+		ds.CacheHitRate = hitRate(ds.CacheHits, ds.CacheMisses)
+		snap.Domains[host] = ds
+
+		snap.Totals.Requests += ds.Requests
+		snap.Totals.Blocked += ds.Blocked
+		snap.Totals.BytesSent += ds.BytesSent
+		snap.Totals.BytesReceived += ds.BytesReceived
+		snap.Totals.CacheHits += ds.CacheHits
+		snap.Totals.CacheMisses += ds.CacheMisses
+		snap.Totals.CacheCorruptions += ds.CacheCorruptions
+	}
+	snap.Totals.CacheHitRate = hitRate(snap.Totals.CacheHits, snap.Totals.CacheMisses)
+	return snap
+}
+
+func (c *Collector) update(host string, fn func(*DomainStats)) {
+	if c == nil {
+		return
+	}
+	host = normalizeHost(host)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d, ok := c.domains[host]
+	if !ok {
+		if len(c.domains) >= MaxDomains {
+			host = OtherDomains
+		}
+		if d, ok = c.domains[host]; !ok {
+			d = &DomainStats{}
+			c.domains[host] = d
+		}
+	}
+	fn(d)
+}
+
+// normalizeHost strips the port and lowercases host so "Example.com:443"
+// and "example.com" share counters.
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+func hitRate(hits, misses int64) float64 {
+	total := hits + misses
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+type countingBody struct {
+	io.ReadCloser
+	add func(int64)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.add(int64(n))
+	}
+	return n, err
+}
+
+type countingConn struct {
+	net.Conn
+	host  string
+	stats *Collector
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.stats.AddBytesReceived(c.host, int64(n))
+	}
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.stats.AddBytesSent(c.host, int64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,127 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestCollector_Snapshot(t *testing.T) {
+	c := New()
+	c.RecordRequest("Example.com:443")
+	c.RecordRequest("example.com")
+	c.RecordBlocked("evil.com")
+	c.RecordCacheHit("example.com")
+	c.RecordCacheMiss("example.com:443")
+	c.RecordCacheMiss("example.com")
+	c.AddBytesSent("example.com", 10)
+	c.AddBytesReceived("example.com", 100)
+
+	snap := c.Snapshot()
+
+	ex, ok := snap.Domains["example.com"]
+	if !ok {
+		t.Fatalf("expected stats for example.com, got %v", snap.Domains)
+	}
+	if ex.Requests != 2 || ex.BytesSent != 10 || ex.BytesReceived != 100 || ex.CacheHits != 1 || ex.CacheMisses != 2 {
+		t.Errorf("unexpected example.com stats: %+v", ex)
+	}
+	if ex.CacheHitRate < 0.33 || ex.CacheHitRate > 0.34 {
+		t.Errorf("expected cache hit rate 1/3, got %f", ex.CacheHitRate)
+	}
+
+	if evil := snap.Domains["evil.com"]; evil.Requests != 1 || evil.Blocked != 1 {
+		t.Errorf("unexpected evil.com stats: %+v", evil)
+	}
+
+	if snap.Totals.Requests != 3 || snap.Totals.Blocked != 1 || snap.Totals.BytesReceived != 100 {
+		t.Errorf("unexpected totals: %+v", snap.Totals)
+	}
+	if snap.Since.IsZero() {
+		t.Error("expected since to be set")
+	}
+}
+
+func TestCollector_MaxDomains(t *testing.T) {
+	c := New()
+	for i := range MaxDomains + 10 {
+		c.RecordRequest(fmt.Sprintf("host%d.example.com", i))
+	}
+	c.RecordRequest("host0.example.com")
+
+	snap := c.Snapshot()
+	if len(snap.Domains) != MaxDomains+1 {
+		t.Fatalf("expected %d domains plus %s, got %d", MaxDomains, OtherDomains, len(snap.Domains))
+	}
+	if other := snap.Domains[OtherDomains]; other.Requests != 10 {
+		t.Errorf("expected 10 requests under %s, got %+v", OtherDomains, other)
+	}
+	if d := snap.Domains["host0.example.com"]; d.Requests != 2 {
+		t.Errorf("expected tracked domains to keep counting, got %+v", d)
+	}
+	if snap.Totals.Requests != MaxDomains+11 {
+		t.Errorf("expected totals to include every request, got %d", snap.Totals.Requests)
+	}
+}
+
+func TestCollector_CountBodies(t *testing.T) {
+	c := New()
+
+	sent := c.CountSent("api.example.com", io.NopCloser(strings.NewReader("hello")))
+	if _, err := io.Copy(io.Discard, sent); err != nil {
+		t.Fatalf("read sent body: %v", err)
+	}
+	received := c.CountReceived("api.example.com", io.NopCloser(strings.NewReader("hello world")))
+	if _, err := io.Copy(io.Discard, received); err != nil {
+		t.Fatalf("read received body: %v", err)
+	}
+
+	d := c.Snapshot().Domains["api.example.com"]
+	if d.BytesSent != 5 || d.BytesReceived != 11 {
+		t.Errorf("expected 5 bytes sent and 11 received, got %+v", d)
+	}
+}
+
+func TestCollector_CountConn(t *testing.T) {
+	c := New()
+	client, server := net.Pipe()
+	defer func() { _ = server.Close() }()
+
+	conn := c.CountConn("db.example.com", client)
+	defer func() { _ = conn.Close() }()
+
+	go func() {
+		buf := make([]byte, 4)
+		_, _ = io.ReadFull(server, buf)
+		_, _ = server.Write([]byte("pong!"))
+	}()
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	d := c.Snapshot().Domains["db.example.com"]
+	if d.BytesSent != 4 || d.BytesReceived != 5 {
+		t.Errorf("expected 4 bytes sent and 5 received, got %+v", d)
+	}
+}
+
+func TestCollector_Nil(t *testing.T) {
+	var c *Collector
+	c.RecordRequest("example.com")
+	c.AddBytesSent("example.com", 1)
+
+	body := io.NopCloser(strings.NewReader("x"))
+	if c.CountSent("example.com", body) != body {
+		t.Error("expected nil collector to return the body unchanged")
+	}
+	if snap := c.Snapshot(); len(snap.Domains) != 0 {
+		t.Errorf("expected empty snapshot, got %+v", snap)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/database"
+	"github.com/obot-platform/discobot/server/internal/git"
+	"github.com/obot-platform/discobot/server/internal/sandbox/docker"
+)
+
+// diagnoseTimeout bounds each individual diagnostic check.
+const diagnoseTimeout = 10 * time.Second
+
+// diagnosticResult is the outcome of a single preflight check.
+type diagnosticResult struct {
+	Name    string
+	OK      bool
+	Message string
+}
+
+// diagnosticCheck runs a single preflight check.
+type diagnosticCheck struct {
+	name string
+	run  func(ctx context.Context, cfg *config.Config) diagnosticResult
+}
+
+// runDiagnostics runs all preflight checks, prints a report to w, and returns
+// the process exit code (0 if every check passed).
+func runDiagnostics(cfg *config.Config, w io.Writer) int {
+	checks := []diagnosticCheck{
+		{name: "Database", run: checkDatabase},
+		{name: "Workspace directory", run: checkWorkspaceDir},
+		{name: "Git", run: checkGit},
+	}
+	if runtime.GOOS == "darwin" {
+		checks = append(checks, diagnosticCheck{name: "VZ", run: checkVZ})
+	} else {
+		checks = append(checks, diagnosticCheck{name: "Docker", run: checkDocker})
+	}
+
+	_, _ = fmt.Fprintf(w, "Discobot preflight diagnostics\n\n")
+
+	failed := 0
+	for _, check := range checks {
+		ctx, cancel := context.WithTimeout(context.Background(), diagnoseTimeout)
+		result := check.run(ctx, cfg)
+		cancel()
+		result.Name = check.name
+
+		status := "PASS"
+		if !result.OK {
+			status = "FAIL"
+			failed++
+		}
+		_, _ = fmt.Fprintf(w, "[%s] %s: %s\n", status, result.Name, result.Message)
+	}
+
+	_, _ = fmt.Fprintln(w)
+	if failed > 0 {
+		_, _ = fmt.Fprintf(w, "%d check(s) failed\n", failed)
+		return 1
+	}
+	_, _ = fmt.Fprintf(w, "All checks passed\n")
+	return 0
+}
+
+func pass(format string, args ...any) diagnosticResult {
+	return diagnosticResult{OK: true, Message: fmt.Sprintf(format, args...)}
+}
+
+func fail(format string, args ...any) diagnosticResult {
+	return diagnosticResult{Message: fmt.Sprintf(format, args...)}
+}
+
+// checkDatabase verifies the database is reachable.
+func checkDatabase(ctx context.Context, cfg *config.Config) diagnosticResult {
+	db, err := database.New(cfg)
+	if err != nil {
+		return fail("cannot connect (%s): %v - check DATABASE_DSN", cfg.DatabaseDriver, err)
+	}
+	defer func() { _ = db.Close() }()
+
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return fail("cannot access connection pool: %v", err)
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return fail("ping failed (%s): %v - check DATABASE_DSN", cfg.DatabaseDriver, err)
+	}
+	return pass("%s connection OK", cfg.DatabaseDriver)
+}
+
+// checkWorkspaceDir verifies the workspace directory exists (or can be created) and is writable.
+func checkWorkspaceDir(_ context.Context, cfg *config.Config) diagnosticResult {
+	if _, err := git.NewLocalProvider(cfg.WorkspaceDir); err != nil {
+		return fail("cannot initialize %s: %v - check WORKSPACE_DIR", cfg.WorkspaceDir, err)
+	}
+
+	probe, err := os.CreateTemp(cfg.WorkspaceDir, ".discobot-diagnose-*")
+	if err != nil {
+		return fail("%s is not writable: %v - check WORKSPACE_DIR permissions", cfg.WorkspaceDir, err)
+	}
+	_ = probe.Close()
+	_ = os.Remove(probe.Name())
+
+	return pass("%s is writable", cfg.WorkspaceDir)
+}
+
+// checkGit verifies the git binary is available.
+func checkGit(ctx context.Context, _ *config.Config) diagnosticResult {
+	path, err := exec.LookPath("git")
+	if err != nil {
+		return fail("git not found on PATH - install git")
+	}
+	out, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		return fail("%s --version failed: %v", path, err)
+	}
+	return pass("%s (%s)", strings.TrimSpace(string(out)), path)
+}
+
+// checkDocker verifies the Docker daemon is reachable and the sandbox image is present.
+func checkDocker(ctx context.Context, cfg *config.Config) diagnosticResult {
+	host := cfg.DockerHost
+	source := "DOCKER_HOST"
+	if host == "" {
+		host = docker.DetectDockerHost()
+		source = "Docker context"
+	}
+	if host == "" {
+		host = "SDK default"
+		source = "default"
+	}
+
+	cli, err := docker.NewClient(cfg)
+	if err != nil {
+		return fail("cannot create client for %s: %v", host, err)
+	}
+	defer func() { _ = cli.Close() }()
+
+	ping, err := cli.Ping(ctx)
+	if err != nil {
+		return fail("Docker host %s (from %s) not reachable: %v - start the Docker daemon or set DOCKER_HOST", host, source, err)
+	}
+
+	if _, err := cli.ImageInspect(ctx, cfg.SandboxImage); err != nil {
+		return fail("Docker host %s ping OK (API %s), but sandbox image missing - run: docker pull %s", host, ping.APIVersion, cfg.SandboxImage)
+	}
+
+	return pass("Docker host %s (from %s) ping OK (API %s), sandbox image %s present", host, source, ping.APIVersion, cfg.SandboxImage)
+}
+
+// checkVZ verifies the VZ provider has a usable kernel and base disk, either
+// from explicit paths or an image reference to download them from.
+func checkVZ(_ context.Context, cfg *config.Config) diagnosticResult {
+	if err := os.MkdirAll(cfg.VZDataDir, 0755); err != nil {
+		return fail("cannot create VZ data dir %s: %v - check VZ_DATA_DIR", cfg.VZDataDir, err)
+	}
+
+	if cfg.VZKernelPath != "" || cfg.VZBaseDiskPath != "" {
+		for _, f := range []struct{ name, path string }{
+			{"VZ_KERNEL_PATH", cfg.VZKernelPath},
+			{"VZ_BASE_DISK_PATH", cfg.VZBaseDiskPath},
+		} {
+			name, path := f.name, f.path
+			if path == "" {
+				return fail("%s is not set - set both VZ_KERNEL_PATH and VZ_BASE_DISK_PATH, or use VZ_IMAGE_REF", name)
+			}
+			if _, err := os.Stat(path); err != nil {
+				return fail("%s %s not found: %v", name, path, err)
+			}
+		}
+		return pass("kernel %s and base disk %s present", cfg.VZKernelPath, cfg.VZBaseDiskPath)
+	}
+
+	if cfg.VZImageRef == "" {
+		return fail("no kernel or image configured - set VZ_IMAGE_REF or VZ_KERNEL_PATH/VZ_BASE_DISK_PATH")
+	}
+	return pass("images will be downloaded from %s into %s", cfg.VZImageRef, filepath.Clean(cfg.VZDataDir))
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"log/slog"
@@ -15,12 +16,12 @@ import (
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
-	"github.com/joho/godotenv"
 
 	"github.com/obot-platform/discobot/server/internal/config"
 	"github.com/obot-platform/discobot/server/internal/database"
 	"github.com/obot-platform/discobot/server/internal/dispatcher"
 	"github.com/obot-platform/discobot/server/internal/events"
+	"github.com/obot-platform/discobot/server/internal/exporter"
 	"github.com/obot-platform/discobot/server/internal/git"
 	"github.com/obot-platform/discobot/server/internal/handler"
 	"github.com/obot-platform/discobot/server/internal/jobs"
@@ -31,6 +32,7 @@ import (
 	"github.com/obot-platform/discobot/server/internal/sandbox"
 	"github.com/obot-platform/discobot/server/internal/sandbox/docker"
 	"github.com/obot-platform/discobot/server/internal/sandbox/local"
+	"github.com/obot-platform/discobot/server/internal/sandbox/podman"
 	"github.com/obot-platform/discobot/server/internal/sandbox/vm"
 	"github.com/obot-platform/discobot/server/internal/sandbox/vz"
 	"github.com/obot-platform/discobot/server/internal/service"
@@ -43,7 +45,10 @@ import (
 
 func main() {
 	// Load .env file if present
-	_ = godotenv.Load()
+	config.LoadDotEnv()
+
+	diagnose := flag.Bool("diagnose", false, "Run preflight checks (database, git, Docker/VZ) and exit")
+	flag.Parse()
 
 	// Load configuration
 	cfg, err := config.Load()
@@ -51,6 +56,10 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	if *diagnose {
+		os.Exit(runDiagnostics(cfg, os.Stdout))
+	}
+
 	// Redirect stdout/stderr to log file if configured (must be before any logging)
 	if cfg.LogFile != "" {
 		if err := logfile.Truncate(cfg.LogFile); err != nil {
@@ -96,11 +105,18 @@ func main() {
 	// Initialize git provider (required)
 	// Create workspace source for git provider to lookup workspace info
 	workspaceSource := git.NewStoreWorkspaceSource(s)
-	gitProvider, err := git.NewLocalProvider(cfg.WorkspaceDir, git.WithWorkspaceSource(workspaceSource))
+	gitOpts := []git.LocalProviderOption{
+		git.WithWorkspaceSource(workspaceSource),
+		git.WithWorkspaceLayout(git.WorkspaceLayout(cfg.WorkspaceLayout)),
+	}
+	if cfg.GitMirrorDir != "" {
+		gitOpts = append(gitOpts, git.WithMirrorCache(cfg.GitMirrorDir, cfg.GitMirrorMaxAge))
+	}
+	gitProvider, err := git.NewLocalProvider(cfg.WorkspaceDir, gitOpts...)
 	if err != nil {
 		log.Fatalf("Failed to initialize git provider: %v", err)
 	}
-	log.Printf("Git provider initialized at %s", cfg.WorkspaceDir)
+	log.Printf("Git provider initialized at %s (%s layout)", cfg.WorkspaceDir, cfg.WorkspaceLayout)
 
 	// Initialize sandbox providers
 	// Create a manager that can route to different providers based on workspace configuration
@@ -141,7 +157,19 @@ func main() {
 			MemoryMB:      cfg.VZMemoryMB,
 			DataDiskGB:    cfg.VZDataDiskGB,
 		}
-		if vmProvider, vzErr := vz.NewProvider(cfg, vzCfg, sessionProjectResolver, systemManager); vzErr != nil {
+		// Warm project VMs with recently active sessions first, so the
+		// projects most likely to be used next are ready soonest. Only a
+		// few recently active projects are warmed, since each warm VM takes
+		// host memory and CPU.
+		warmLister := func(ctx context.Context) ([]string, error) {
+			var since time.Time
+			if cfg.VZWarmRecentWindow > 0 {
+				since = time.Now().Add(-cfg.VZWarmRecentWindow)
+			}
+			return s.ListProjectIDsByRecentActivity(ctx, since, cfg.VZWarmMaxProjects)
+		}
+		warming := vm.WithWarming(warmLister, cfg.VZWarmWorkers, cfg.VZWarmTimeout)
+		if vmProvider, vzErr := vz.NewProvider(cfg, vzCfg, sessionProjectResolver, systemManager, warming); vzErr != nil {
 			log.Printf("Warning: Failed to initialize VZ sandbox provider: %v", vzErr)
 		} else {
 			sandboxManager.RegisterProvider("vz", vmProvider)
@@ -160,7 +188,17 @@ func main() {
 			log.Printf("Docker sandbox provider initialized (image: %s)", cfg.SandboxImage)
 		}
 	}
-	//
+
+	// Initialize rootless Podman provider (only if enabled via config)
+	if cfg.PodmanEnabled {
+		if podmanProvider, podmanErr := podman.NewProvider(cfg, sessionProjectResolver, docker.WithSystemManager(systemManager)); podmanErr != nil {
+			log.Printf("Warning: Failed to initialize Podman sandbox provider: %v", podmanErr)
+		} else {
+			sandboxManager.RegisterProvider(model.WorkspaceProviderPodman, podmanProvider)
+			log.Printf("Podman sandbox provider initialized (image: %s)", podmanProvider.Image())
+		}
+	}
+
 	// Initialize local provider (only if enabled via config)
 	if cfg.LocalProviderEnabled {
 		if localProvider, localErr := local.NewProvider(cfg); localErr != nil {
@@ -200,13 +238,37 @@ func main() {
 			return workspace.Provider, nil
 		}
 
-		sandboxProvider = sandbox.NewProviderProxy(sandboxManager, providerGetter)
+		providerProxy := sandbox.NewProviderProxy(sandboxManager, providerGetter)
+		if hooks := sandboxHooks(cfg); hooks != nil {
+			providerProxy.SetHooks(hooks)
+			log.Printf("Sandbox lifecycle hooks enabled (%s)", cfg.SandboxHookFailurePolicy)
+		}
+		sandboxProvider = providerProxy
 		log.Printf("Sandbox provider proxy initialized with %d providers", len(sandboxManager.ListProviders()))
 	}
 
 	// Create job queue early so it can be passed to services
 	jobQueue := jobs.NewQueue(s, cfg)
 
+	// Push lifecycle events and metrics to an external sink, if configured
+	var eventExporter *exporter.Exporter
+	if cfg.ExporterURL != "" {
+		eventExporter = exporter.New(exporter.Config{
+			URL:             cfg.ExporterURL,
+			Format:          cfg.ExporterFormat,
+			Headers:         cfg.ExporterHeaders,
+			BufferSize:      cfg.ExporterBufferSize,
+			BatchSize:       cfg.ExporterBatchSize,
+			FlushInterval:   cfg.ExporterFlushInterval,
+			MaxRetries:      cfg.ExporterMaxRetries,
+			Timeout:         cfg.ExporterTimeout,
+			MetricsInterval: cfg.ExporterMetricsInterval,
+		})
+		eventExporter.SetCollector(exporter.ServerCollector(s, sandboxManager))
+		eventExporter.Start(context.Background(), eventBroker)
+		log.Printf("Event exporter started (format: %s)", cfg.ExporterFormat)
+	}
+
 	// Start sandbox watcher to sync session states with sandbox states
 	// This handles external changes (e.g., Docker containers deleted outside Discobot)
 	var sandboxWatcherCancel context.CancelFunc
@@ -242,6 +304,7 @@ func main() {
 			HostKeyPath:     cfg.SSHHostKeyPath,
 			SandboxProvider: sandboxProvider,
 			UserInfoFetcher: &sshUserInfoAdapter{svc: sshSandboxSvc},
+			ResumePaused:    cfg.ServiceProxyAutoStart,
 		})
 		if err != nil {
 			log.Printf("Warning: Failed to create SSH server: %v", err)
@@ -274,9 +337,13 @@ func main() {
 			if err != nil {
 				log.Fatalf("Failed to create credential service for dispatcher: %v", err)
 			}
+			credSvc.SetEventBroker(eventBroker)
 			credFetcher := service.MakeCredentialFetcher(s, credSvc)
 			dispSandboxSvc = service.NewSandboxService(s, sandboxProvider, cfg, credFetcher, eventBroker, jobQueue)
 			sessionSvc = service.NewSessionService(s, gitSvc, sandboxProvider, dispSandboxSvc, eventBroker, jobQueue)
+			sessionSvc.SetStartupProbe(service.NewStartupProbe(cfg.SandboxStartupProbeSuccesses, cfg.SandboxStartupProbeInterval,
+				cfg.SandboxStartupTimeout, cfg.SandboxStartupMaxRestarts))
+			sessionSvc.SetAgentTypes(handler.ServiceAgentTypes())
 			dispSandboxSvc.SetSessionInitializer(sessionSvc)
 			disp.RegisterExecutor(dispatcher.NewSessionInitExecutor(sessionSvc))
 			disp.RegisterExecutor(dispatcher.NewSessionDeleteExecutor(sessionSvc))
@@ -346,7 +413,7 @@ func main() {
 
 	// Global middleware
 	r.Use(chimiddleware.RequestID)
-	r.Use(chimiddleware.RealIP)
+	r.Use(middleware.RealIP(cfg.TrustedProxies))
 	r.Use(middleware.SanitizedLogger)
 	r.Use(chimiddleware.Recoverer)
 	// Note: No global timeout - SSE endpoints need long-lived connections
@@ -356,7 +423,16 @@ func main() {
 	// IMPORTANT: This must run BEFORE CORS middleware so that OPTIONS requests
 	// are forwarded to the service (which handles its own CORS).
 	if sandboxProvider != nil {
-		r.Use(middleware.ServiceProxy(sandboxProvider))
+		var startSession middleware.SessionStarter
+		if cfg.ServiceProxyAutoStart {
+			proxySandboxSvc := service.NewSandboxService(s, sandboxProvider, cfg, nil, eventBroker, jobQueue)
+			startSession = proxySandboxSvc.EnsureRunning
+		}
+		r.Use(middleware.ServiceProxy(sandboxProvider, startSession, middleware.ServiceProxyLimits{
+			MaxConnsPerSession: cfg.ServiceProxyMaxConnsPerSession,
+			MaxConns:           cfg.ServiceProxyMaxConns,
+			QueueTimeout:       cfg.ServiceProxyQueueTimeout,
+		}))
 	}
 
 	if len(cfg.CORSOrigins) > 0 {
@@ -378,9 +454,34 @@ func main() {
 	// Initialize handlers
 	h := handler.New(s, cfg, gitProvider, sandboxProvider, sandboxManager, eventBroker, jobQueue, systemManager)
 
+	// Warn about OAuth credentials nearing expiry once they come within the
+	// warning window, not only when a sandbox next uses them
+	var credentialWatchCancel context.CancelFunc
+	if cfg.CredentialExpiryCheckInterval > 0 {
+		credWatchSvc, err := service.NewCredentialService(s, cfg)
+		if err != nil {
+			log.Fatalf("Failed to create credential service for expiry checks: %v", err)
+		}
+		credWatchSvc.SetEventBroker(eventBroker)
+		var credWatchCtx context.Context
+		credWatchCtx, credentialWatchCancel = context.WithCancel(context.Background())
+		go credWatchSvc.WatchExpiry(credWatchCtx, cfg.CredentialExpiryCheckInterval)
+	}
+
 	// Wire up job queue notification to dispatcher for immediate execution
 	if disp != nil {
 		h.JobQueue().SetNotifyFunc(disp.NotifyNewJob)
+		h.SetDispatcher(disp)
+	}
+
+	// Share the quiesce state so session init jobs honor it too
+	if sessionSvc != nil {
+		sessionSvc.SetQuiesceState(h.QuiesceState())
+	}
+
+	// Expose active SSH connections via the API
+	if sshServer != nil {
+		h.SetSSHServer(sshServer)
 	}
 
 	// Route registry for metadata
@@ -396,6 +497,12 @@ func main() {
 		Meta: routes.Meta{Group: "Health", Description: "Health check"},
 	})
 
+	reg.Register(r, routes.Route{
+		Method: "GET", Pattern: "/readyz",
+		Handler: h.Readyz,
+		Meta:    routes.Meta{Group: "Health", Description: "Readiness check (503 while quiesced or the default sandbox provider is unhealthy)"},
+	})
+
 	reg.Register(r, routes.Route{
 		Method: "GET", Pattern: "/api/status",
 		Handler: h.GetSystemStatus,
@@ -428,6 +535,9 @@ func main() {
 	// API Routes endpoint (returns route metadata for API UI)
 	r.Get("/api/routes", h.GetRoutes)
 
+	// OpenAPI document generated from the same route metadata
+	r.Get("/api/openapi.json", h.GetOpenAPI)
+
 	// ===== Auth routes (no auth required) =====
 	r.Route("/auth", func(r chi.Router) {
 		authReg := reg.WithPrefix("/auth")
@@ -470,6 +580,11 @@ func main() {
 		r.Use(middleware.Auth(s, cfg))
 		apiReg := reg.WithPrefix("/api")
 
+		// Rate limits expensive routes: those that start sandboxes, run the
+		// agent, or clone and commit. SSE and terminal routes are long-lived
+		// connections and aren't limited.
+		limiter := middleware.NewRateLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurst)
+
 		// User Preferences (user-scoped, not project-scoped)
 		r.Route("/preferences", func(r chi.Router) {
 			prefReg := apiReg.WithPrefix("/preferences")
@@ -522,6 +637,41 @@ func main() {
 			})
 		})
 
+		// Server administration (ADMIN_EMAILS)
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(middleware.ServerAdmin(cfg))
+			adminReg := apiReg.WithPrefix("/admin")
+
+			adminReg.Register(r, routes.Route{
+				Method: "GET", Pattern: "/config",
+				Handler: h.GetEffectiveConfig,
+				Meta:    routes.Meta{Group: "Admin", Description: "Get effective server configuration (secrets redacted)"},
+			})
+
+			adminReg.Register(r, routes.Route{
+				Method: "POST", Pattern: "/quiesce",
+				Handler: h.Quiesce,
+				Meta:    routes.Meta{Group: "Admin", Description: "Stop accepting new sessions (existing sessions keep working)"},
+			})
+
+			adminReg.Register(r, routes.Route{
+				Method: "DELETE", Pattern: "/quiesce",
+				Handler: h.Unquiesce,
+				Meta:    routes.Meta{Group: "Admin", Description: "Resume accepting new sessions"},
+			})
+		})
+
+		// Job logs (authorized by the job's project)
+		apiReg.Register(r, routes.Route{
+			Method: "GET", Pattern: "/jobs/{jobId}/logs",
+			Handler: h.StreamJobLogs,
+			Meta: routes.Meta{
+				Group:       "Jobs",
+				Description: "Stream a background job's log lines (WebSocket)",
+				Params:      []routes.Param{{Name: "jobId", Example: "job123"}},
+			},
+		})
+
 		// Project list
 		apiReg.Register(r, routes.Route{
 			Method: "GET", Pattern: "/projects",
@@ -531,17 +681,28 @@ func main() {
 
 		apiReg.Register(r, routes.Route{
 			Method: "POST", Pattern: "/projects",
-			Handler: h.CreateProject,
+			Handler: limiter.Limit(h.CreateProject),
 			Meta: routes.Meta{
 				Group:       "Projects",
-				Description: "Create project",
-				Body:        map[string]any{"name": "My Project", "slug": "my-project"},
+				Description: "Create project, optionally seeded with a workspace or from a template",
+				Body: map[string]any{
+					"name":             "My Project",
+					"template":         "",
+					"defaultWorkspace": map[string]any{"path": "~/code/my-project", "sourceType": "local"},
+				},
 			},
 		})
 
+		apiReg.Register(r, routes.Route{
+			Method: "GET", Pattern: "/project-templates",
+			Handler: h.ListProjectTemplates,
+			Meta:    routes.Meta{Group: "Projects", Description: "List project templates"},
+		})
+
 		// Project-specific routes
 		r.Route("/projects/{projectId}", func(r chi.Router) {
 			r.Use(middleware.ProjectMember(s))
+			r.Use(middleware.ProjectNotFrozen(s))
 			projReg := apiReg.WithPrefix("/projects/{projectId}")
 
 			// SSE events
@@ -591,6 +752,27 @@ func main() {
 				},
 			})
 
+			// Maintenance freeze
+			projReg.Register(r, routes.Route{
+				Method: "POST", Pattern: "/freeze",
+				Handler: h.FreezeProject,
+				Meta: routes.Meta{
+					Group:       "Projects",
+					Description: "Freeze project (pause jobs, reject changes)",
+					Params:      []routes.Param{{Name: "projectId", Example: "local"}},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "DELETE", Pattern: "/freeze",
+				Handler: h.UnfreezeProject,
+				Meta: routes.Meta{
+					Group:       "Projects",
+					Description: "Unfreeze project",
+					Params:      []routes.Param{{Name: "projectId", Example: "local"}},
+				},
+			})
+
 			// Members
 			projReg.Register(r, routes.Route{
 				Method: "GET", Pattern: "/members",
@@ -694,12 +876,12 @@ func main() {
 
 				wsReg.Register(r, routes.Route{
 					Method: "POST", Pattern: "/",
-					Handler: h.CreateWorkspace,
+					Handler: limiter.Limit(h.CreateWorkspace),
 					Meta: routes.Meta{
 						Group:       "Workspaces",
 						Description: "Create workspace",
 						Params:      []routes.Param{{Name: "projectId", Example: "local"}},
-						Body:        map[string]any{"name": "My Workspace", "path": "/home/user/code", "source_type": "local"},
+						Body:        map[string]any{"name": "My Workspace", "path": "/home/user/code", "source_type": "local", "networkMode": "proxied"},
 					},
 				})
 
@@ -734,6 +916,27 @@ func main() {
 					},
 				})
 
+				wsReg.Register(r, routes.Route{
+					Method: "GET", Pattern: "/{workspaceId}/default-agent",
+					Handler: h.GetWorkspaceDefaultAgent,
+					Meta: routes.Meta{
+						Group:       "Workspaces",
+						Description: "Get the workspace's default agent and the agent its sessions fall back to",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}},
+					},
+				})
+
+				wsReg.Register(r, routes.Route{
+					Method: "PUT", Pattern: "/{workspaceId}/default-agent",
+					Handler: h.SetWorkspaceDefaultAgent,
+					Meta: routes.Meta{
+						Group:       "Workspaces",
+						Description: "Set or clear the workspace's default agent",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}},
+						Body:        map[string]any{"agentId": ""},
+					},
+				})
+
 				// Sessions within workspace
 				wsReg.Register(r, routes.Route{
 					Method: "GET", Pattern: "/{workspaceId}/sessions",
@@ -758,7 +961,7 @@ func main() {
 
 				wsReg.Register(r, routes.Route{
 					Method: "POST", Pattern: "/{workspaceId}/git/fetch",
-					Handler: h.FetchWorkspace,
+					Handler: limiter.Limit(h.FetchWorkspace),
 					Meta: routes.Meta{
 						Group:       "Git",
 						Description: "Fetch from remote",
@@ -879,6 +1082,28 @@ func main() {
 			r.Route("/sessions", func(r chi.Router) {
 				sessReg := projReg.WithPrefix("/sessions")
 
+				sessReg.Register(r, routes.Route{
+					Method: "POST", Pattern: "/bulk-delete",
+					Handler: h.BulkDeleteSessions,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Delete many sessions by ID or filter",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}},
+						Body:        map[string]any{"filter": "stopped"},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "POST", Pattern: "/status",
+					Handler: h.GetSessionsStatus,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Get statuses for many sessions at once",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}},
+						Body:        map[string]any{"sessionIds": []string{"abc123"}, "includeSandbox": false},
+					},
+				})
+
 				sessReg.Register(r, routes.Route{
 					Method: "GET", Pattern: "/{sessionId}",
 					Handler: h.GetSession,
@@ -923,10 +1148,214 @@ func main() {
 
 				sessReg.Register(r, routes.Route{
 					Method: "POST", Pattern: "/{sessionId}/commit",
-					Handler: h.CommitSession,
+					Handler: limiter.Limit(h.CommitSession),
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Commit session changes (dryRun=true previews the patches without applying them)",
+						Params: []routes.Param{
+							{Name: "projectId", Example: "local"},
+							{Name: "sessionId", Example: "abc123"},
+							{Name: "dryRun", In: "query", Example: "true"},
+						},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "POST", Pattern: "/{sessionId}/prioritize",
+					Handler: h.PrioritizeSession,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Move session's queued init job to the front",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+						Body:        map[string]any{"priority": 100},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "GET", Pattern: "/{sessionId}/history",
+					Handler: h.GetSessionHistory,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Recent status and commit status transitions",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}, {Name: "limit", In: "query", Example: "50"}},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "POST", Pattern: "/{sessionId}/docker-socket",
+					Handler: h.ForwardSessionDockerSocket,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Forward the sandbox Docker daemon to a host Unix socket",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "DELETE", Pattern: "/{sessionId}/docker-socket",
+					Handler: h.StopSessionDockerSocket,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Stop forwarding the sandbox Docker socket",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "POST", Pattern: "/{sessionId}/network/test",
+					Handler: h.TestSessionNetwork,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Test network reachability from inside the sandbox",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+						Body:        map[string]any{"target": "https://registry.npmjs.org"},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "GET", Pattern: "/{sessionId}/proxy/stats",
+					Handler: h.GetSessionProxyStats,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Get per-domain traffic statistics from the sandbox proxy",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "GET", Pattern: "/{sessionId}/fds",
+					Handler: h.GetSessionFileDescriptors,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Get open file descriptor counts and limits of the sandbox's processes",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "POST", Pattern: "/{sessionId}/resize-disk",
+					Handler: limiter.Limit(h.ResizeSessionDisk),
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Grow the sandbox data volume",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+						Body:        map[string]any{"sizeMB": 204800},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "POST", Pattern: "/{sessionId}/reset",
+					Handler: h.ResetSession,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Discard home directory changes, keeping the workspace and caches",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+						Body:        map[string]any{"confirm": true},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "POST", Pattern: "/{sessionId}/pause",
+					Handler: h.PauseSession,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Pause the session's sandbox",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "POST", Pattern: "/{sessionId}/resume",
+					Handler: h.ResumeSession,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Resume a paused session's sandbox",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "GET", Pattern: "/{sessionId}/stats",
+					Handler: h.GetSessionStats,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Get sandbox CPU, memory, and network usage",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "POST", Pattern: "/{sessionId}/commit-image",
+					Handler: h.CommitSessionImage,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Save the sandbox filesystem as an image",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "POST", Pattern: "/{sessionId}/fork",
+					Handler: h.ForkSession,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Create a new session from a snapshot of this one",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+						Body:        map[string]any{"name": "Try another approach"},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "POST", Pattern: "/{sessionId}/snapshots",
+					Handler: h.CreateSessionSnapshot,
 					Meta: routes.Meta{
 						Group:       "Sessions",
-						Description: "Commit session changes",
+						Description: "Snapshot a session's sandbox",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "GET", Pattern: "/{sessionId}/snapshots",
+					Handler: h.ListSessionSnapshots,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "List a session's snapshots",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "DELETE", Pattern: "/{sessionId}/snapshots/{snapshotId}",
+					Handler: h.DeleteSessionSnapshot,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Delete a session snapshot",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}, {Name: "snapshotId", Example: "0123456789abcdef01234567"}},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "GET", Pattern: "/{sessionId}/snapshots/{snapshotId}/diff",
+					Handler: h.DiffSessionSnapshot,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Stream the workspace diff from a snapshot to another or to now",
+						Params: []routes.Param{
+							{Name: "projectId", Example: "local"},
+							{Name: "sessionId", Example: "abc123"},
+							{Name: "snapshotId", Example: "0123456789abcdef01234567"},
+							{Name: "to", In: "query"},
+						},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "GET", Pattern: "/{sessionId}/fsdiff",
+					Handler: h.GetSessionFilesystemDiff,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "List sandbox filesystem changes since creation",
 						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
 					},
 				})
@@ -1002,6 +1431,134 @@ func main() {
 					},
 				})
 
+				sessReg.Register(r, routes.Route{
+					Method: "POST", Pattern: "/{sessionId}/files/uploads",
+					Handler: h.CreateSessionUpload,
+					Meta: routes.Meta{
+						Group:       "Files",
+						Description: "Start resumable session file upload",
+						Params: []routes.Param{
+							{Name: "projectId", Example: "local"},
+							{Name: "sessionId", Example: "abc123"},
+						},
+						Body: map[string]any{"path": "data/model.bin", "size": 1073741824},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "GET", Pattern: "/{sessionId}/files/uploads/{uploadId}",
+					Handler: h.GetSessionUpload,
+					Meta: routes.Meta{
+						Group:       "Files",
+						Description: "Get session file upload progress",
+						Params: []routes.Param{
+							{Name: "projectId", Example: "local"},
+							{Name: "sessionId", Example: "abc123"},
+							{Name: "uploadId", Example: "9f2c4e6a8b0d1f3e5a7c9b1d3f5e7a9c"},
+						},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "PUT", Pattern: "/{sessionId}/files/uploads/{uploadId}",
+					Handler: h.AppendSessionUpload,
+					Meta: routes.Meta{
+						Group:       "Files",
+						Description: "Append session file upload chunk",
+						Params: []routes.Param{
+							{Name: "projectId", Example: "local"},
+							{Name: "sessionId", Example: "abc123"},
+							{Name: "uploadId", Example: "9f2c4e6a8b0d1f3e5a7c9b1d3f5e7a9c"},
+							{Name: "offset", In: "query", Required: true, Example: "0"},
+						},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "DELETE", Pattern: "/{sessionId}/files/uploads/{uploadId}",
+					Handler: h.AbortSessionUpload,
+					Meta: routes.Meta{
+						Group:       "Files",
+						Description: "Abort session file upload",
+						Params: []routes.Param{
+							{Name: "projectId", Example: "local"},
+							{Name: "sessionId", Example: "abc123"},
+							{Name: "uploadId", Example: "9f2c4e6a8b0d1f3e5a7c9b1d3f5e7a9c"},
+						},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "PUT", Pattern: "/{sessionId}/files/upload",
+					Handler: h.UploadSessionFiles,
+					Meta: routes.Meta{
+						Group:       "Files",
+						Description: "Copy files or a tar archive into session workspace",
+						Params: []routes.Param{
+							{Name: "projectId", Example: "local"},
+							{Name: "sessionId", Example: "abc123"},
+							{Name: "path", In: "query", Example: "data"},
+						},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "GET", Pattern: "/{sessionId}/files/download",
+					Handler: h.DownloadSessionFile,
+					Meta: routes.Meta{
+						Group:       "Files",
+						Description: "Download session file or directory as tar archive",
+						Params: []routes.Param{
+							{Name: "projectId", Example: "local"},
+							{Name: "sessionId", Example: "abc123"},
+							{Name: "path", In: "query", Required: true, Example: "data"},
+						},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "GET", Pattern: "/{sessionId}/kv/{key}",
+					Handler: h.GetSessionKV,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Get session key-value entry",
+						Params: []routes.Param{
+							{Name: "projectId", Example: "local"},
+							{Name: "sessionId", Example: "abc123"},
+							{Name: "key", Example: "task-progress"},
+						},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "PUT", Pattern: "/{sessionId}/kv/{key}",
+					Handler: h.PutSessionKV,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Set session key-value entry",
+						Params: []routes.Param{
+							{Name: "projectId", Example: "local"},
+							{Name: "sessionId", Example: "abc123"},
+							{Name: "key", Example: "task-progress"},
+						},
+						Body: map[string]any{"value": "step 3 of 5"},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "DELETE", Pattern: "/{sessionId}/kv/{key}",
+					Handler: h.DeleteSessionKV,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Delete session key-value entry",
+						Params: []routes.Param{
+							{Name: "projectId", Example: "local"},
+							{Name: "sessionId", Example: "abc123"},
+							{Name: "key", Example: "task-progress"},
+						},
+					},
+				})
+
 				sessReg.Register(r, routes.Route{
 					Method: "GET", Pattern: "/{sessionId}/diff",
 					Handler: h.GetSessionDiff,
@@ -1034,7 +1591,22 @@ func main() {
 					Meta: routes.Meta{
 						Group:       "Terminal",
 						Description: "Terminal WebSocket",
-						Params:      []routes.Param{{Name: "projectId", Example: "local"}},
+						Params: []routes.Param{
+							{Name: "projectId", Example: "local"},
+							{Name: "user", In: "query", Example: "root"},
+							{Name: "record", In: "query", Example: "true"},
+						},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "POST", Pattern: "/{sessionId}/exec",
+					Handler: h.ExecCommand,
+					Meta: routes.Meta{
+						Group:       "Terminal",
+						Description: "Run a command in the sandbox",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+						Body:        map[string]any{"command": []string{"apt-get", "install", "-y", "jq"}, "user": "root", "timeoutSeconds": 120},
 					},
 				})
 
@@ -1048,6 +1620,26 @@ func main() {
 					},
 				})
 
+				sessReg.Register(r, routes.Route{
+					Method: "GET", Pattern: "/{sessionId}/terminal/recordings",
+					Handler: h.ListTerminalRecordings,
+					Meta: routes.Meta{
+						Group:       "Terminal",
+						Description: "List terminal recordings",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+					},
+				})
+
+				sessReg.Register(r, routes.Route{
+					Method: "GET", Pattern: "/{sessionId}/terminal/{terminalId}/cast",
+					Handler: h.GetTerminalCast,
+					Meta: routes.Meta{
+						Group:       "Terminal",
+						Description: "Download a terminal recording (asciinema v2)",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}, {Name: "terminalId", Example: "rec123"}},
+					},
+				})
+
 				sessReg.Register(r, routes.Route{
 					Method: "GET", Pattern: "/{sessionId}/terminal/status",
 					Handler: h.GetTerminalStatus,
@@ -1058,6 +1650,28 @@ func main() {
 					},
 				})
 
+				// Sandbox output (no agent required)
+				sessReg.Register(r, routes.Route{
+					Method: "GET", Pattern: "/{sessionId}/logs",
+					Handler: h.GetSessionLogs,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Stream sandbox output (supports tail, since, follow, strip)",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+					},
+				})
+
+				// VM console (no agent required)
+				sessReg.Register(r, routes.Route{
+					Method: "GET", Pattern: "/{sessionId}/console",
+					Handler: h.GetSessionConsole,
+					Meta: routes.Meta{
+						Group:       "Sessions",
+						Description: "Get VM serial console output (supports tail, follow, strip)",
+						Params:      []routes.Param{{Name: "projectId", Example: "local"}, {Name: "sessionId", Example: "abc123"}},
+					},
+				})
+
 				// Hooks
 				sessReg.Register(r, routes.Route{
 					Method: "GET", Pattern: "/{sessionId}/hooks/status",
@@ -1380,12 +1994,15 @@ func main() {
 			// Chat endpoint
 			projReg.Register(r, routes.Route{
 				Method: "POST", Pattern: "/chat",
-				Handler: h.Chat,
+				Handler: limiter.Limit(h.Chat),
 				Meta: routes.Meta{
 					Group:       "Chat",
 					Description: "AI Chat (streaming)",
-					Params:      []routes.Param{{Name: "projectId", Example: "local"}},
-					Body:        map[string]any{"messages": []map[string]any{{"role": "user", "content": "Hello"}}},
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "events", In: "query", Example: "typed"},
+					},
+					Body: map[string]any{"messages": []map[string]any{{"role": "user", "content": "Hello"}}},
 				},
 			})
 
@@ -1399,6 +2016,7 @@ func main() {
 					Params: []routes.Param{
 						{Name: "projectId", Example: "local"},
 						{Name: "sessionId", Example: "abc123"},
+						{Name: "events", In: "query", Example: "typed"},
 					},
 				},
 			})
@@ -1444,6 +2062,30 @@ func main() {
 					},
 				},
 			})
+
+			// SSH connection endpoints
+			projReg.Register(r, routes.Route{
+				Method: "GET", Pattern: "/ssh/connections",
+				Handler: h.ListSSHConnections,
+				Meta: routes.Meta{
+					Group:       "SSH",
+					Description: "List active SSH connections",
+					Params:      []routes.Param{{Name: "projectId", Example: "local"}},
+				},
+			})
+
+			projReg.Register(r, routes.Route{
+				Method: "DELETE", Pattern: "/ssh/connections/{connectionId}",
+				Handler: h.CloseSSHConnection,
+				Meta: routes.Meta{
+					Group:       "SSH",
+					Description: "Terminate an active SSH connection",
+					Params: []routes.Param{
+						{Name: "projectId", Example: "local"},
+						{Name: "connectionId", Example: "abc123"},
+					},
+				},
+			})
 		})
 	})
 
@@ -1451,11 +2093,17 @@ func main() {
 	var debugDockerServer *handler.DebugDockerServer
 	if cfg.DebugDocker {
 		var err error
-		debugDockerServer, err = handler.NewDebugDockerServer(sandboxManager, "local", cfg.DebugDockerPort)
+		debugDockerServer, err = handler.NewDebugDockerServer(sandboxManager, "local", handler.DebugDockerOptions{
+			Port:        cfg.DebugDockerPort,
+			Token:       cfg.DebugDockerToken,
+			MaxConns:    cfg.DebugDockerMaxConns,
+			IdleTimeout: cfg.DebugDockerIdleTimeout,
+		})
 		if err != nil {
 			log.Printf("Warning: Failed to create debug Docker proxy: %v", err)
-		} else {
-			debugDockerServer.Start()
+		} else if err := debugDockerServer.Start(); err != nil {
+			log.Printf("Warning: Failed to start debug Docker proxy: %v", err)
+			debugDockerServer = nil
 		}
 	}
 
@@ -1514,6 +2162,11 @@ func main() {
 		sandboxWatcherCancel()
 	}
 
+	// Stop credential expiry checks
+	if credentialWatchCancel != nil {
+		credentialWatchCancel()
+	}
+
 	// Shutdown sandbox manager (gracefully stop all VMs and providers)
 	if sandboxManager != nil {
 		log.Println("Shutting down sandbox providers...")
@@ -1551,6 +2204,15 @@ func main() {
 		disp.Stop()
 	}
 
+	// Stop event exporter, sending what it has buffered
+	if eventExporter != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := eventExporter.Stop(shutdownCtx); err != nil {
+			log.Printf("Warning: failed to stop event exporter: %v", err)
+		}
+		shutdownCancel()
+	}
+
 	// Stop event poller
 	eventPoller.Stop()
 
@@ -1584,3 +2246,22 @@ func (a *sshUserInfoAdapter) GetUserInfo(ctx context.Context, sessionID string)
 	}
 	return userInfo.Username, userInfo.UID, userInfo.GID, nil
 }
+
+// sandboxHooks builds the sandbox lifecycle hooks from the configured
+// webhook URLs, or returns nil if none are configured.
+func sandboxHooks(cfg *config.Config) *sandbox.Hooks {
+	if cfg.SandboxHookPreCreateURL == "" && cfg.SandboxHookPostCreateURL == "" {
+		return nil
+	}
+	hooks := &sandbox.Hooks{
+		FailOpen: cfg.SandboxHookFailurePolicy == sandbox.HookFailOpen,
+		Logf:     log.Printf,
+	}
+	if cfg.SandboxHookPreCreateURL != "" {
+		hooks.PreCreate = append(hooks.PreCreate, sandbox.WebhookHook(cfg.SandboxHookPreCreateURL, cfg.SandboxHookTimeout))
+	}
+	if cfg.SandboxHookPostCreateURL != "" {
+		hooks.PostCreate = append(hooks.PostCreate, sandbox.WebhookHook(cfg.SandboxHookPostCreateURL, cfg.SandboxHookTimeout))
+	}
+	return hooks
+}
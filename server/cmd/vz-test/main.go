@@ -5,16 +5,14 @@ package main
 import (
 	"context"
 	"flag"
-	"fmt"
-	"io"
 	"log"
 	"net"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"syscall"
-	"time"
 
+	"github.com/obot-platform/discobot/server/internal/sandbox"
 	"github.com/obot-platform/discobot/server/internal/sandbox/vm"
 	"github.com/obot-platform/discobot/server/internal/sandbox/vz"
 )
@@ -108,25 +106,17 @@ func main() {
 	log.Printf("VM created successfully!")
 	log.Printf("")
 
-	// Remove existing socket if it exists
-	os.Remove(*socketPath)
-
 	// Start Unix socket proxy for Docker access
 	log.Printf("Starting Docker socket proxy...")
 	log.Printf("Unix socket: %s", *socketPath)
 
-	stopProxy := make(chan struct{})
-	proxyStopped := make(chan struct{})
-
-	go func() {
-		defer close(proxyStopped)
-		if err := startDockerProxy(*socketPath, pvm, stopProxy); err != nil {
-			log.Printf("Proxy error: %v", err)
-		}
-	}()
-
-	// Wait for socket to be ready
-	time.Sleep(100 * time.Millisecond)
+	dialer := pvm.DockerDialer()
+	proxy, err := sandbox.NewDockerSocketProxy(*socketPath, func(ctx context.Context) (net.Conn, error) {
+		return dialer(ctx, "vsock", "")
+	}, nil)
+	if err != nil {
+		log.Fatalf("Failed to start Docker socket proxy: %v", err)
+	}
 
 	log.Printf("")
 	log.Printf("✓ VM is ready!")
@@ -140,102 +130,24 @@ func main() {
 	log.Printf("")
 	log.Printf("Press Ctrl+C to shutdown...")
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal or proxy failure
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
-	<-sigCh
+	select {
+	case <-sigCh:
+	case <-proxy.Done():
+		log.Printf("Proxy error: %v", proxy.Err())
+	}
 
 	log.Printf("")
 	log.Printf("Shutting down...")
 
-	// Stop proxy
-	close(stopProxy)
-	<-proxyStopped
-
-	// Remove socket
-	os.Remove(*socketPath)
+	// Stop proxy (also removes the socket)
+	_ = proxy.Close()
 
 	log.Printf("Shutdown complete")
 }
 
-// startDockerProxy creates a Unix socket and proxies connections to the VM's Docker daemon via VSOCK.
-func startDockerProxy(socketPath string, pvm vm.ProjectVM, stopCh <-chan struct{}) error {
-	// Create Unix socket listener
-	listener, err := net.Listen("unix", socketPath)
-	if err != nil {
-		return fmt.Errorf("failed to create Unix socket: %w", err)
-	}
-	defer listener.Close()
-	defer os.Remove(socketPath)
-
-	// Make socket accessible
-	if err := os.Chmod(socketPath, 0666); err != nil {
-		return fmt.Errorf("failed to chmod socket: %w", err)
-	}
-
-	log.Printf("Docker proxy listening on: %s", socketPath)
-
-	// Accept connections
-	acceptCh := make(chan net.Conn)
-	acceptErrCh := make(chan error)
-
-	go func() {
-		for {
-			conn, err := listener.Accept()
-			if err != nil {
-				acceptErrCh <- err
-				return
-			}
-			acceptCh <- conn
-		}
-	}()
-
-	for {
-		select {
-		case <-stopCh:
-			return nil
-
-		case err := <-acceptErrCh:
-			return err
-
-		case clientConn := <-acceptCh:
-			// Handle connection in goroutine
-			go handleProxyConnection(clientConn, pvm)
-		}
-	}
-}
-
-// handleProxyConnection proxies a single connection from Unix socket to VSOCK.
-func handleProxyConnection(clientConn net.Conn, pvm vm.ProjectVM) {
-	defer clientConn.Close()
-
-	// Get Docker dialer
-	dialer := pvm.DockerDialer()
-
-	// Connect to Docker daemon via VSOCK
-	vmConn, err := dialer(context.Background(), "vsock", "")
-	if err != nil {
-		log.Printf("Failed to connect to VM Docker: %v", err)
-		return
-	}
-	defer vmConn.Close()
-
-	// Bidirectional copy
-	done := make(chan struct{}, 2)
-
-	go func() {
-		_, _ = io.Copy(vmConn, clientConn)
-		done <- struct{}{}
-	}()
-
-	go func() {
-		_, _ = io.Copy(clientConn, vmConn)
-		done <- struct{}{}
-	}()
-
-	<-done
-}
-
 // expandPath expands ~ to home directory.
 func expandPath(path string) string {
 	if path == "" {
@@ -1,15 +1,21 @@
 package config
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
+	"net/netip"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/adrg/xdg"
+	"gopkg.in/yaml.v3"
 
 	"github.com/obot-platform/discobot/server/internal/version"
 )
@@ -41,19 +47,130 @@ type Config struct {
 	DatabaseDriver string // "postgres" or "sqlite3", auto-detected from DSN
 
 	// Authentication
-	AuthEnabled bool // If false, uses anonymous user (default: false)
+	AuthEnabled bool     // If false, uses anonymous user (default: false)
+	AdminEmails []string // Users allowed to use /api/admin endpoints; everyone when auth is disabled
+
+	// Rate limiting of expensive endpoints (chat, workspace creation, commits),
+	// per user or per IP for anonymous requests
+	RateLimitPerMinute int // Average requests allowed per minute (0 = disabled, default: 60)
+	RateLimitBurst     int // Requests allowed in a burst (default: 20)
+
+	// Proxies whose X-Forwarded-For and X-Real-IP headers are honoured when
+	// identifying the client (default: none, so the connection's address is used)
+	TrustedProxies []netip.Prefix
+
+	// Project event streams (SSE). Streams past these limits get a
+	// "reconnect" event and are closed; the client resumes where it left off.
+	SSEMaxLifetime time.Duration // Recycle streams after this long, once quiet (0 = never, default: 1h)
+	SSEIdleTimeout time.Duration // Close streams that delivered no events for this long (0 = never, default: 30m)
 
 	// Security
 	SessionSecret []byte
 	EncryptionKey []byte // 32 bytes for AES-256-GCM
 
+	// Project templates offered when creating a project (PROJECT_TEMPLATES=path to a YAML list)
+	ProjectTemplatesFile string
+	ProjectTemplates     []ProjectTemplate
+
 	// Workspaces and Git
-	WorkspaceDir string // Base directory for workspaces and git cache
+	WorkspaceDir    string        // Base directory for workspaces and git cache
+	WorkspaceLayout string        // Directory layout for cloned workspaces: "project" (default) or "flat"
+	GitMirrorDir    string        // Bare mirror cache for remote repos (empty disables mirroring)
+	GitMirrorMaxAge time.Duration // Remove mirrors unused for this long (0 keeps them forever)
 
 	// Sandbox runtime settings
-	SandboxImage       string        // Default sandbox image
-	SandboxIdleTimeout time.Duration // Auto-stop sandboxes after idle period
-	IdleCheckInterval  time.Duration // How often to check for idle sessions
+	SandboxImage         string            // Default sandbox image
+	SandboxArchImages    map[string]string // Per-architecture images overriding SandboxImage (SANDBOX_ARCH_IMAGES=arm64=...,amd64=...)
+	SandboxAllowedImages []string          // Images projects may choose as their sandbox image; entries ending in * match by prefix (default: none)
+	SandboxPlatform      string            // Platform to pull and run sandbox images for, e.g. linux/amd64 (default: the daemon's)
+	SandboxIdleTimeout   time.Duration     // Auto-stop sandboxes after idle period
+	IdleCheckInterval    time.Duration     // How often to check for idle sessions
+	ProxyRequired        bool              // Fail sandbox startup if the MITM proxy can't start (default: false)
+	DockerDNS            []string          // DNS servers for sandbox containers and the containers nested in them (default: Docker's)
+	SandboxExtraLabels   map[string]string // Extra labels applied to every sandbox (SANDBOX_EXTRA_LABELS=key=value,...)
+	SandboxStopSignal    string            // Signal sent to sandboxes on stop (default: SIGTERM)
+	SandboxAllowedUsers  []string          // Users terminal and exec requests may run as, besides the default (default: discobot; root must be listed explicitly)
+	SandboxMaxExecs      int               // Max concurrent commands/terminals per sandbox (0 = unlimited, default: 32)
+	MaxSandboxesPerHost  int               // Max sandbox containers not stopped at once on the Docker host (0 = unlimited, default)
+	SandboxOverlayOpts   string            // Extra overlayfs mount options for the sandbox home (e.g. "metacopy=on")
+	SandboxLogDriver     string            // Docker log driver for sandbox containers (default: json-file; "daemon" uses the daemon's default)
+	SandboxLogOptions    map[string]string // Log driver options (default for json-file/local: max-size=10m,max-file=3)
+
+	// Sandbox resources. Limits are hard caps; requests are what a sandbox is
+	// guaranteed under contention, so requests below the limits oversubscribe
+	// the host.
+	SandboxMemoryLimitMB   int     // Memory limit in MB (0 = no limit, default)
+	SandboxCPULimit        float64 // CPU limit in cores (0 = no limit, default)
+	SandboxMemoryRequestMB int     // Memory reservation in MB (0 = none, default)
+	SandboxCPURequest      float64 // CPU share in cores (0 = runtime default, default)
+
+	// Project cache volume eviction (enforced by the agent when a sandbox starts)
+	SandboxCacheMaxSizeMB          int // Evict least recently used cache directories above this size (0 = unbounded, default)
+	SandboxCacheEvictTargetPercent int // Evict down to this percentage of the max size (default: 80)
+
+	// Open file limit the agent raises the sandbox's soft limit to, within the hard limit (0 = leave as is, default)
+	SandboxNoFileLimit int
+
+	// Largest multipart body accepted when copying files into a sandbox (default: 1024)
+	CopyUploadMaxMB int
+
+	// Trusted proxy config passed to every sandbox in place of the agent's
+	// built-in default (SANDBOX_PROXY_CONFIG=path to a YAML file)
+	SandboxProxyConfigFile string
+	SandboxProxyConfig     []byte // Contents of SandboxProxyConfigFile, read at startup
+
+	// Upstream proxy every sandbox's proxy chains allowed requests through
+	// (SANDBOX_PROXY_UPSTREAM=http[s]://[user:password@]host:port, default: none)
+	SandboxProxyUpstream        string
+	SandboxProxyUpstreamNoProxy []string // Hosts the sandbox proxy reaches directly instead (domain patterns, IPs, or CIDRs)
+
+	// Trusted script run as root in every sandbox before hooks and the agent
+	// start (SANDBOX_INIT_SCRIPT=path to the script)
+	SandboxInitScriptFile  string
+	SandboxInitScript      []byte // Contents of SandboxInitScriptFile, read at startup
+	SandboxInitScriptFatal bool   // Fail sandbox startup if the init script fails (default: false)
+
+	// Sandbox restart policy (applied by the runtime when a sandbox exits)
+	SandboxRestartPolicy     string // no, on-failure (default), or unless-stopped; workspaces can override
+	SandboxRestartMaxRetries int    // Restarts allowed by on-failure before giving up (default: 3)
+
+	// Sandbox lifecycle webhooks, POSTed session/sandbox metadata around
+	// sandbox creation; either can veto it
+	SandboxHookPreCreateURL  string        // Called before a sandbox is created
+	SandboxHookPostCreateURL string        // Called after a sandbox is created, before it starts
+	SandboxHookFailurePolicy string        // fail-closed (default) blocks creation if a hook errors; fail-open ignores it
+	SandboxHookTimeout       time.Duration // Per-call timeout (default: 10s)
+
+	// Sandbox startup probe (a started sandbox must stay healthy before its session is ready)
+	SandboxStartupProbeSuccesses int           // Consecutive healthy probes required (0 = disabled, default: 3)
+	SandboxStartupProbeInterval  time.Duration // Time between probes (default: 1s)
+	SandboxStartupTimeout        time.Duration // Max time to wait for the sandbox to stabilize (default: 2m)
+	SandboxStartupMaxRestarts    int           // Restarts tolerated during startup before reporting a crash loop (default: 2)
+
+	// Chat history settings
+	ChatHistoryMaxMessages int    // Messages forwarded verbatim to the agent per turn (0 = unlimited)
+	ChatHistoryMaxTokens   int    // Approximate token budget for forwarded history (0 = unlimited)
+	ChatHistoryStrategy    string // What to do with older messages: "drop-oldest" (default) or "summarize"
+
+	// Cancel a completion once no client has streamed it for this long
+	// (0 = never; completions keep running so clients can resume them)
+	ChatDisconnectCancelAfter time.Duration
+
+	// Name for sessions created without one, with {summary}, {workspace},
+	// {branch}, {date}, and {time} placeholders (empty = the first user message)
+	SessionNameTemplate string
+
+	// Event/metric exporter, pushing lifecycle events and periodic metrics to
+	// an external sink (disabled unless ExporterURL is set)
+	ExporterURL             string            // Webhook URL, or OTLP/HTTP base URL (e.g. http://collector:4318)
+	ExporterFormat          string            // "webhook" (default) or "otlp"
+	ExporterHeaders         map[string]string // Extra request headers, e.g. for authentication
+	ExporterBufferSize      int               // Events held while the sink is slow or down; newer ones are dropped (default: 1000)
+	ExporterBatchSize       int               // Events sent per request (default: 100)
+	ExporterFlushInterval   time.Duration     // Max time an event waits for its batch to fill (default: 5s)
+	ExporterMaxRetries      int               // Retries with backoff before a batch is dropped (default: 5)
+	ExporterTimeout         time.Duration     // Per-request timeout (default: 10s)
+	ExporterMetricsInterval time.Duration     // How often metrics are sent (0 = events only, default: 1m)
 
 	// Docker-specific settings
 	DockerHost    string // Docker socket/host (default: unix:///var/run/docker.sock)
@@ -71,15 +188,34 @@ type Config struct {
 	VZMemoryMB      int    // Memory per VM in MB (0 = half system memory, rounded down to nearest GB)
 	VZDataDiskGB    int    // Data disk size per VM in GB (0 = 100GB default)
 
+	// Startup VM warming, most recently active projects first
+	VZWarmWorkers      int           // Project VMs booted in parallel (default: 2)
+	VZWarmTimeout      time.Duration // Stop starting new warm-ups after this long (0 = no limit, default: 10m)
+	VZWarmMaxProjects  int           // Most projects warmed besides "local" (0 = no limit, default: 5)
+	VZWarmRecentWindow time.Duration // Only warm projects with a session updated this recently (0 = no limit, default: 168h)
+
 	// Local provider settings
-	LocalProviderEnabled bool   // Enable local sandbox provider (default: false)
-	LocalAgentBinary     string // Path to agent API binary for local provider (default: obot-agent-api in PATH)
+	LocalProviderEnabled   bool   // Enable local sandbox provider (default: false)
+	LocalAgentBinary       string // Path to agent API binary for local provider (default: obot-agent-api in PATH)
+	LocalProviderIsolation bool   // Run local provider commands in Linux namespaces rooted at the workspace, best effort (default: false)
+
+	// Podman provider settings
+	PodmanEnabled bool   // Enable the rootless Podman sandbox provider (default: false)
+	PodmanHost    string // Podman API socket (default: $XDG_RUNTIME_DIR/podman/podman.sock)
+	PodmanUserns  string // User namespace mode for sandbox containers (default: keep-id)
+	PodmanRuntime string // OCI runtime for sandbox containers (default: crun)
 
 	// SSH server settings
 	SSHEnabled     bool   // Enable SSH server (default: true)
 	SSHPort        int    // SSH server port (default: 3333)
 	SSHHostKeyPath string // Path to SSH host key file (default: ./ssh_host_key)
 
+	// Service subdomain proxy settings
+	ServiceProxyAutoStart          bool          // Start a session's sandbox on a proxied request if it isn't running (default: false)
+	ServiceProxyMaxConnsPerSession int           // Max concurrent proxied requests per session (0 = unlimited, default: 64)
+	ServiceProxyMaxConns           int           // Max concurrent proxied requests overall (0 = unlimited, default: 1024)
+	ServiceProxyQueueTimeout       time.Duration // How long a request over the limits waits for a slot before a 503 (default: 5s)
+
 	// Job Dispatcher settings
 	DispatcherEnabled            bool          // Enable job dispatcher (default: true)
 	DispatcherPollInterval       time.Duration // How often to poll for jobs (default: 1s)
@@ -102,9 +238,20 @@ type Config struct {
 	GitHubCopilotClientID string
 	CodexClientID         string
 
+	// Warn (via a credential_expiring event) when an OAuth credential that
+	// can't be refreshed automatically expires within this window (default: 24h)
+	CredentialExpiryWarning time.Duration
+	// How often all OAuth credentials are checked against that window, so the
+	// warning doesn't wait for a credential to be used (0 = never, default: 15m)
+	CredentialExpiryCheckInterval time.Duration
+
 	// Debug settings
-	DebugDocker     bool // Expose Docker API proxy for VZ VMs (default: false)
-	DebugDockerPort int  // Port for debug Docker proxy (default: 2375)
+	DebugDocker            bool          // Expose Docker API proxy for VZ VMs (default: false)
+	DebugDockerPort        int           // Loopback port for debug Docker proxy (default: 2375)
+	DebugDockerToken       string        // Token required by the debug Docker proxy (default: generated)
+	DebugDockerMaxConns    int           // Max concurrent debug Docker proxy connections (default: 16)
+	DebugDockerIdleTimeout time.Duration // Close idle debug Docker proxy connections (default: 5m)
+	DebugDockerSocket      bool          // Allow forwarding a session's VZ Docker daemon to a host Unix socket (default: false)
 
 	// Process lifecycle
 	LogFile        string // Redirect stdout/stderr to this file (Unix only)
@@ -131,6 +278,32 @@ func Load() (*Config, error) {
 
 	// Authentication - defaults to disabled (anonymous user mode)
 	cfg.AuthEnabled = getEnvBool("AUTH_ENABLED", false)
+	for _, email := range getEnvList("ADMIN_EMAILS", nil) {
+		if email = strings.TrimSpace(email); email != "" {
+			cfg.AdminEmails = append(cfg.AdminEmails, email)
+		}
+	}
+
+	// Rate limiting
+	cfg.RateLimitPerMinute = getEnvInt("RATE_LIMIT_PER_MINUTE", 60)
+	cfg.RateLimitBurst = getEnvInt("RATE_LIMIT_BURST", 20)
+	for _, entry := range getEnvList("TRUSTED_PROXIES", nil) {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		prefix, err := parseIPPrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q: %w", entry, err)
+		}
+		cfg.TrustedProxies = append(cfg.TrustedProxies, prefix)
+	}
+
+	// Project event streams
+	cfg.SSEMaxLifetime = getEnvDuration("SSE_MAX_LIFETIME", time.Hour)
+	cfg.SSEIdleTimeout = getEnvDuration("SSE_IDLE_TIMEOUT", 30*time.Minute)
+	if cfg.SSEMaxLifetime < 0 || cfg.SSEIdleTimeout < 0 {
+		return nil, fmt.Errorf("SSE_MAX_LIFETIME and SSE_IDLE_TIMEOUT must not be negative")
+	}
 
 	// Security - Session secret (required only if auth is enabled)
 	sessionSecret := getEnv("SESSION_SECRET", "")
@@ -161,13 +334,173 @@ func Load() (*Config, error) {
 	}
 	cfg.EncryptionKey = encryptionKey
 
+	// Project templates
+	cfg.ProjectTemplatesFile = getEnv("PROJECT_TEMPLATES", "")
+	if cfg.ProjectTemplatesFile != "" {
+		templates, err := loadProjectTemplates(cfg.ProjectTemplatesFile)
+		if err != nil {
+			return nil, fmt.Errorf("PROJECT_TEMPLATES: %w", err)
+		}
+		cfg.ProjectTemplates = templates
+	}
+
 	// Workspaces and Git - defaults to XDG_DATA_HOME/discobot/workspaces
 	cfg.WorkspaceDir = getEnv("WORKSPACE_DIR", filepath.Join(xdg.DataHome, appName, "workspaces"))
+	cfg.WorkspaceLayout = getEnv("WORKSPACE_LAYOUT", "project")
+	if cfg.WorkspaceLayout != "project" && cfg.WorkspaceLayout != "flat" {
+		return nil, fmt.Errorf("WORKSPACE_LAYOUT must be \"project\" or \"flat\", got %q", cfg.WorkspaceLayout)
+	}
+	cfg.GitMirrorDir = getEnv("GIT_MIRROR_DIR", "")
+	cfg.GitMirrorMaxAge = getEnvDuration("GIT_MIRROR_MAX_AGE", 7*24*time.Hour)
 
 	// Sandbox runtime settings
 	cfg.SandboxImage = getEnv("SANDBOX_IMAGE", DefaultSandboxImage())
+	cfg.SandboxArchImages = getEnvMap("SANDBOX_ARCH_IMAGES")
+	cfg.SandboxAllowedImages = getEnvList("SANDBOX_ALLOWED_IMAGES", nil)
+	cfg.SandboxPlatform = getEnv("SANDBOX_PLATFORM", "")
+	if cfg.SandboxPlatform != "" && !validPlatform(cfg.SandboxPlatform) {
+		return nil, fmt.Errorf("SANDBOX_PLATFORM must be os/arch or os/arch/variant (e.g. linux/amd64), got %q", cfg.SandboxPlatform)
+	}
 	cfg.SandboxIdleTimeout = getEnvDuration("SANDBOX_IDLE_TIMEOUT", 1*time.Hour)
 	cfg.IdleCheckInterval = getEnvDuration("IDLE_CHECK_INTERVAL", 5*time.Minute)
+	cfg.ProxyRequired = getEnvBool("PROXY_REQUIRED", false)
+	for _, server := range getEnvList("DOCKER_DNS", nil) {
+		if server = strings.TrimSpace(server); server == "" {
+			continue
+		}
+		if _, err := netip.ParseAddr(server); err != nil {
+			return nil, fmt.Errorf("DOCKER_DNS must be a comma-separated list of IP addresses, got %q", server)
+		}
+		cfg.DockerDNS = append(cfg.DockerDNS, server)
+	}
+	cfg.SandboxExtraLabels = getEnvMap("SANDBOX_EXTRA_LABELS")
+	cfg.SandboxStopSignal = getEnv("SANDBOX_STOP_SIGNAL", "")
+	switch cfg.SandboxStopSignal {
+	case "", "SIGINT", "INT", "2", "SIGTERM", "TERM", "15", "SIGQUIT", "QUIT", "3":
+	default:
+		return nil, fmt.Errorf("SANDBOX_STOP_SIGNAL must be SIGINT, SIGTERM, or SIGQUIT, got %q", cfg.SandboxStopSignal)
+	}
+	for _, user := range getEnvList("SANDBOX_ALLOWED_USERS", []string{"discobot"}) {
+		if user = strings.TrimSpace(user); user != "" {
+			cfg.SandboxAllowedUsers = append(cfg.SandboxAllowedUsers, user)
+		}
+	}
+	cfg.SandboxMaxExecs = getEnvInt("SANDBOX_MAX_EXECS", 32)
+	cfg.MaxSandboxesPerHost = getEnvInt("MAX_SANDBOXES_PER_HOST", 0)
+	cfg.SandboxOverlayOpts = getEnv("SANDBOX_OVERLAY_OPTIONS", "")
+	cfg.SandboxLogDriver = getEnv("SANDBOX_LOG_DRIVER", "json-file")
+	cfg.SandboxLogOptions = getEnvMap("SANDBOX_LOG_OPTIONS")
+	if _, set := os.LookupEnv("SANDBOX_LOG_OPTIONS"); !set && (cfg.SandboxLogDriver == "json-file" || cfg.SandboxLogDriver == "local") {
+		cfg.SandboxLogOptions = map[string]string{"max-size": "10m", "max-file": "3"}
+	}
+	if err := validateLogConfig(cfg.SandboxLogDriver, cfg.SandboxLogOptions); err != nil {
+		return nil, err
+	}
+	cfg.SandboxMemoryLimitMB = getEnvInt("SANDBOX_MEMORY_LIMIT_MB", 0)
+	cfg.SandboxCPULimit = getEnvFloat("SANDBOX_CPU_LIMIT", 0)
+	cfg.SandboxMemoryRequestMB = getEnvInt("SANDBOX_MEMORY_REQUEST_MB", 0)
+	cfg.SandboxCPURequest = getEnvFloat("SANDBOX_CPU_REQUEST", 0)
+	if err := validateSandboxResources(cfg); err != nil {
+		return nil, err
+	}
+	cfg.SandboxCacheMaxSizeMB = getEnvInt("SANDBOX_CACHE_MAX_SIZE_MB", 0)
+	cfg.SandboxCacheEvictTargetPercent = getEnvInt("SANDBOX_CACHE_EVICT_TARGET_PERCENT", 80)
+	if cfg.SandboxCacheMaxSizeMB < 0 {
+		return nil, fmt.Errorf("SANDBOX_CACHE_MAX_SIZE_MB must not be negative, got %d", cfg.SandboxCacheMaxSizeMB)
+	}
+	if cfg.SandboxCacheEvictTargetPercent < 1 || cfg.SandboxCacheEvictTargetPercent > 100 {
+		return nil, fmt.Errorf("SANDBOX_CACHE_EVICT_TARGET_PERCENT must be between 1 and 100, got %d", cfg.SandboxCacheEvictTargetPercent)
+	}
+	cfg.CopyUploadMaxMB = getEnvInt("COPY_UPLOAD_MAX_MB", 1024)
+	if cfg.CopyUploadMaxMB < 1 {
+		return nil, fmt.Errorf("COPY_UPLOAD_MAX_MB must be positive, got %d", cfg.CopyUploadMaxMB)
+	}
+	cfg.SandboxNoFileLimit = getEnvInt("SANDBOX_NOFILE_LIMIT", 0)
+	if cfg.SandboxNoFileLimit < 0 {
+		return nil, fmt.Errorf("SANDBOX_NOFILE_LIMIT must not be negative, got %d", cfg.SandboxNoFileLimit)
+	}
+	cfg.SandboxProxyConfigFile = getEnv("SANDBOX_PROXY_CONFIG", "")
+	if cfg.SandboxProxyConfigFile != "" {
+		data, err := loadProxyConfig(cfg.SandboxProxyConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("SANDBOX_PROXY_CONFIG: %w", err)
+		}
+		cfg.SandboxProxyConfig = data
+	}
+	cfg.SandboxProxyUpstream = getEnv("SANDBOX_PROXY_UPSTREAM", "")
+	if cfg.SandboxProxyUpstream != "" {
+		u, err := url.Parse(cfg.SandboxProxyUpstream)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("SANDBOX_PROXY_UPSTREAM must be an http:// or https:// proxy URL")
+		}
+	}
+	cfg.SandboxProxyUpstreamNoProxy = getEnvList("SANDBOX_PROXY_UPSTREAM_NO_PROXY", nil)
+	cfg.SandboxInitScriptFile = getEnv("SANDBOX_INIT_SCRIPT", "")
+	if cfg.SandboxInitScriptFile != "" {
+		data, err := loadInitScript(cfg.SandboxInitScriptFile)
+		if err != nil {
+			return nil, fmt.Errorf("SANDBOX_INIT_SCRIPT: %w", err)
+		}
+		cfg.SandboxInitScript = data
+	}
+	cfg.SandboxInitScriptFatal = getEnvBool("SANDBOX_INIT_SCRIPT_FATAL", false)
+	cfg.SandboxRestartPolicy = getEnv("SANDBOX_RESTART_POLICY", "on-failure")
+	cfg.SandboxRestartMaxRetries = getEnvInt("SANDBOX_RESTART_MAX_RETRIES", 3)
+	switch cfg.SandboxRestartPolicy {
+	case "no", "on-failure", "unless-stopped":
+	default:
+		return nil, fmt.Errorf("SANDBOX_RESTART_POLICY must be no, on-failure, or unless-stopped, got %q", cfg.SandboxRestartPolicy)
+	}
+	cfg.SandboxHookPreCreateURL = getEnv("SANDBOX_HOOK_PRE_CREATE_URL", "")
+	cfg.SandboxHookPostCreateURL = getEnv("SANDBOX_HOOK_POST_CREATE_URL", "")
+	cfg.SandboxHookFailurePolicy = getEnv("SANDBOX_HOOK_FAILURE_POLICY", "fail-closed")
+	cfg.SandboxHookTimeout = getEnvDuration("SANDBOX_HOOK_TIMEOUT", 10*time.Second)
+	if cfg.SandboxHookFailurePolicy != "fail-closed" && cfg.SandboxHookFailurePolicy != "fail-open" {
+		return nil, fmt.Errorf("SANDBOX_HOOK_FAILURE_POLICY must be fail-closed or fail-open, got %q", cfg.SandboxHookFailurePolicy)
+	}
+	if cfg.SandboxHookTimeout <= 0 {
+		return nil, fmt.Errorf("SANDBOX_HOOK_TIMEOUT must be positive, got %s", cfg.SandboxHookTimeout)
+	}
+	cfg.SandboxStartupProbeSuccesses = getEnvInt("SANDBOX_STARTUP_PROBE_SUCCESSES", 3)
+	cfg.SandboxStartupProbeInterval = getEnvDuration("SANDBOX_STARTUP_PROBE_INTERVAL", 1*time.Second)
+	cfg.SandboxStartupTimeout = getEnvDuration("SANDBOX_STARTUP_TIMEOUT", 2*time.Minute)
+	cfg.SandboxStartupMaxRestarts = getEnvInt("SANDBOX_STARTUP_MAX_RESTARTS", 2)
+	if cfg.SandboxStartupProbeSuccesses > 0 && cfg.SandboxStartupProbeInterval <= 0 {
+		return nil, fmt.Errorf("SANDBOX_STARTUP_PROBE_INTERVAL must be positive, got %s", cfg.SandboxStartupProbeInterval)
+	}
+	if cfg.SandboxStartupProbeSuccesses > 0 && cfg.SandboxStartupTimeout <= 0 {
+		return nil, fmt.Errorf("SANDBOX_STARTUP_TIMEOUT must be positive, got %s", cfg.SandboxStartupTimeout)
+	}
+
+	// Chat history settings
+	cfg.ChatHistoryMaxMessages = getEnvInt("CHAT_HISTORY_MAX_MESSAGES", 0)
+	cfg.ChatHistoryMaxTokens = getEnvInt("CHAT_HISTORY_MAX_TOKENS", 0)
+	cfg.ChatHistoryStrategy = getEnv("CHAT_HISTORY_STRATEGY", "drop-oldest")
+	if cfg.ChatHistoryStrategy != "drop-oldest" && cfg.ChatHistoryStrategy != "summarize" {
+		return nil, fmt.Errorf("CHAT_HISTORY_STRATEGY must be \"drop-oldest\" or \"summarize\", got %q", cfg.ChatHistoryStrategy)
+	}
+	cfg.ChatDisconnectCancelAfter = getEnvDuration("CHAT_DISCONNECT_CANCEL_AFTER", 0)
+	if cfg.ChatDisconnectCancelAfter < 0 {
+		return nil, fmt.Errorf("CHAT_DISCONNECT_CANCEL_AFTER must not be negative, got %s", cfg.ChatDisconnectCancelAfter)
+	}
+	cfg.SessionNameTemplate = getEnv("SESSION_NAME_TEMPLATE", "")
+	if err := validateSessionNameTemplate(cfg.SessionNameTemplate); err != nil {
+		return nil, fmt.Errorf("SESSION_NAME_TEMPLATE: %w", err)
+	}
+
+	// Event/metric exporter
+	cfg.ExporterURL = getEnv("EXPORTER_URL", "")
+	cfg.ExporterFormat = getEnv("EXPORTER_FORMAT", "webhook")
+	cfg.ExporterHeaders = getEnvMap("EXPORTER_HEADERS")
+	cfg.ExporterBufferSize = getEnvInt("EXPORTER_BUFFER_SIZE", 1000)
+	cfg.ExporterBatchSize = getEnvInt("EXPORTER_BATCH_SIZE", 100)
+	cfg.ExporterFlushInterval = getEnvDuration("EXPORTER_FLUSH_INTERVAL", 5*time.Second)
+	cfg.ExporterMaxRetries = getEnvInt("EXPORTER_MAX_RETRIES", 5)
+	cfg.ExporterTimeout = getEnvDuration("EXPORTER_TIMEOUT", 10*time.Second)
+	cfg.ExporterMetricsInterval = getEnvDuration("EXPORTER_METRICS_INTERVAL", time.Minute)
+	if err := validateExporter(cfg); err != nil {
+		return nil, err
+	}
 
 	// Docker-specific settings
 	// Empty default lets the Docker SDK auto-detect (works on Linux, macOS, and Windows)
@@ -187,10 +520,27 @@ func Load() (*Config, error) {
 	cfg.VZCPUCount = getEnvInt("VZ_CPU_COUNT", 0)
 	cfg.VZMemoryMB = getEnvInt("VZ_MEMORY_MB", 0)
 	cfg.VZDataDiskGB = getEnvInt("VZ_DATA_DISK_GB", 0)
+	cfg.VZWarmWorkers = getEnvInt("VZ_WARM_WORKERS", 2)
+	cfg.VZWarmTimeout = getEnvDuration("VZ_WARM_TIMEOUT", 10*time.Minute)
+	cfg.VZWarmMaxProjects = getEnvInt("VZ_WARM_MAX_PROJECTS", 5)
+	cfg.VZWarmRecentWindow = getEnvDuration("VZ_WARM_RECENT_WINDOW", 7*24*time.Hour)
+	if cfg.VZWarmMaxProjects < 0 {
+		return nil, fmt.Errorf("VZ_WARM_MAX_PROJECTS must not be negative, got %d", cfg.VZWarmMaxProjects)
+	}
+	if cfg.VZWarmRecentWindow < 0 {
+		return nil, fmt.Errorf("VZ_WARM_RECENT_WINDOW must not be negative, got %s", cfg.VZWarmRecentWindow)
+	}
 
 	// Local provider settings
 	cfg.LocalProviderEnabled = getEnvBool("LOCAL_PROVIDER_ENABLED", false)
 	cfg.LocalAgentBinary = getEnv("LOCAL_AGENT_BINARY", "obot-agent-api")
+	cfg.LocalProviderIsolation = getEnvBool("LOCAL_PROVIDER_ISOLATION", false)
+
+	// Podman provider settings
+	cfg.PodmanEnabled = getEnvBool("PODMAN_ENABLED", false)
+	cfg.PodmanHost = getEnv("PODMAN_HOST", "")
+	cfg.PodmanUserns = getEnv("PODMAN_USERNS", "keep-id")
+	cfg.PodmanRuntime = getEnv("PODMAN_RUNTIME", "crun")
 
 	// SSH server settings
 	// SSH host key defaults to XDG_STATE_HOME/discobot/ssh_host_key
@@ -198,6 +548,15 @@ func Load() (*Config, error) {
 	cfg.SSHPort = getEnvInt("SSH_PORT", 3333)
 	cfg.SSHHostKeyPath = getEnv("SSH_HOST_KEY_PATH", filepath.Join(xdg.StateHome, appName, "ssh_host_key"))
 
+	// Service subdomain proxy settings
+	cfg.ServiceProxyAutoStart = getEnvBool("SERVICE_PROXY_AUTO_START", false)
+	cfg.ServiceProxyMaxConnsPerSession = getEnvInt("SERVICE_PROXY_MAX_CONNS_PER_SESSION", 64)
+	cfg.ServiceProxyMaxConns = getEnvInt("SERVICE_PROXY_MAX_CONNS", 1024)
+	cfg.ServiceProxyQueueTimeout = getEnvDuration("SERVICE_PROXY_QUEUE_TIMEOUT", 5*time.Second)
+	if cfg.ServiceProxyMaxConnsPerSession < 0 || cfg.ServiceProxyMaxConns < 0 || cfg.ServiceProxyQueueTimeout < 0 {
+		return nil, fmt.Errorf("SERVICE_PROXY_MAX_CONNS_PER_SESSION, SERVICE_PROXY_MAX_CONNS, and SERVICE_PROXY_QUEUE_TIMEOUT must not be negative")
+	}
+
 	// Job Dispatcher settings
 	cfg.DispatcherEnabled = getEnvBool("DISPATCHER_ENABLED", true)
 	cfg.DispatcherPollInterval = getEnvDuration("DISPATCHER_POLL_INTERVAL", 5*time.Second)
@@ -219,10 +578,16 @@ func Load() (*Config, error) {
 	cfg.AnthropicClientID = getEnv("ANTHROPIC_CLIENT_ID", "9d1c250a-e61b-44d9-88ed-5944d1962f5e")
 	cfg.GitHubCopilotClientID = getEnv("GITHUB_COPILOT_CLIENT_ID", "Iv1.b507a08c87ecfe98")
 	cfg.CodexClientID = getEnv("CODEX_CLIENT_ID", "app_EMoamEEZ73f0CkXaXp7hrann")
+	cfg.CredentialExpiryWarning = getEnvDuration("CREDENTIAL_EXPIRY_WARNING", 24*time.Hour)
+	cfg.CredentialExpiryCheckInterval = getEnvDuration("CREDENTIAL_EXPIRY_CHECK_INTERVAL", 15*time.Minute)
 
 	// Debug settings
 	cfg.DebugDocker = getEnvBool("DEBUG_DOCKER", false)
 	cfg.DebugDockerPort = getEnvInt("DEBUG_DOCKER_PORT", 2375)
+	cfg.DebugDockerToken = getEnv("DEBUG_DOCKER_TOKEN", "")
+	cfg.DebugDockerMaxConns = getEnvInt("DEBUG_DOCKER_MAX_CONNS", 16)
+	cfg.DebugDockerIdleTimeout = getEnvDuration("DEBUG_DOCKER_IDLE_TIMEOUT", 5*time.Minute)
+	cfg.DebugDockerSocket = getEnvBool("DEBUG_DOCKER_SOCKET", false)
 
 	// Process lifecycle
 	cfg.LogFile = getEnv("LOG_FILE", "")
@@ -238,6 +603,97 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// logSizePattern matches Docker log size values such as "10m" or "512k".
+var logSizePattern = regexp.MustCompile(`^[0-9]+[kmgKMG]?$`)
+
+// SessionNamePlaceholders are the placeholders SESSION_NAME_TEMPLATE may use.
+var SessionNamePlaceholders = []string{"{summary}", "{workspace}", "{branch}", "{date}", "{time}"}
+
+// sessionNamePlaceholder matches a placeholder in a session name template.
+var sessionNamePlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// validateSessionNameTemplate rejects placeholders the session namer doesn't
+// know, which would otherwise show up verbatim in session names.
+func validateSessionNameTemplate(tmpl string) error {
+	for _, placeholder := range sessionNamePlaceholder.FindAllString(tmpl, -1) {
+		if !slices.Contains(SessionNamePlaceholders, placeholder) {
+			return fmt.Errorf("unknown placeholder %s (use %s)", placeholder, strings.Join(SessionNamePlaceholders, ", "))
+		}
+	}
+	return nil
+}
+
+// validateExporter checks the exporter settings when it is enabled.
+func validateExporter(cfg *Config) error {
+	if cfg.ExporterURL == "" {
+		return nil
+	}
+	if u, err := url.Parse(cfg.ExporterURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("EXPORTER_URL must be an http or https URL")
+	}
+	if cfg.ExporterFormat != "webhook" && cfg.ExporterFormat != "otlp" {
+		return fmt.Errorf("EXPORTER_FORMAT must be \"webhook\" or \"otlp\", got %q", cfg.ExporterFormat)
+	}
+	if cfg.ExporterBufferSize <= 0 || cfg.ExporterBatchSize <= 0 {
+		return fmt.Errorf("EXPORTER_BUFFER_SIZE and EXPORTER_BATCH_SIZE must be positive")
+	}
+	if cfg.ExporterFlushInterval <= 0 || cfg.ExporterTimeout <= 0 {
+		return fmt.Errorf("EXPORTER_FLUSH_INTERVAL and EXPORTER_TIMEOUT must be positive")
+	}
+	if cfg.ExporterMaxRetries < 0 || cfg.ExporterMetricsInterval < 0 {
+		return fmt.Errorf("EXPORTER_MAX_RETRIES and EXPORTER_METRICS_INTERVAL must not be negative")
+	}
+	return nil
+}
+
+// validPlatform reports whether platform has the form os/arch or os/arch/variant.
+func validPlatform(platform string) bool {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// validateLogConfig checks the sandbox log driver and the options Docker's
+// built-in file drivers accept. Other drivers' options are left to the daemon,
+// which also reports drivers it doesn't support when the provider starts.
+func validateLogConfig(driver string, opts map[string]string) error {
+	switch driver {
+	case "":
+		return fmt.Errorf("SANDBOX_LOG_DRIVER must not be empty (use \"daemon\" for the daemon's default)")
+	case "daemon", "none":
+		if len(opts) > 0 {
+			return fmt.Errorf("SANDBOX_LOG_OPTIONS can't be used with SANDBOX_LOG_DRIVER=%s", driver)
+		}
+	case "json-file", "local":
+		for k, v := range opts {
+			var ok bool
+			switch k {
+			case "max-size":
+				ok = logSizePattern.MatchString(v)
+			case "max-file":
+				n, err := strconv.Atoi(v)
+				ok = err == nil && n > 0
+			case "compress":
+				_, err := strconv.ParseBool(v)
+				ok = err == nil
+			default:
+				return fmt.Errorf("SANDBOX_LOG_OPTIONS: %s doesn't support option %q", driver, k)
+			}
+			if !ok {
+				return fmt.Errorf("SANDBOX_LOG_OPTIONS: invalid %s value %q", k, v)
+			}
+		}
+	}
+	return nil
+}
+
 // detectDriver determines the database driver from DSN
 func detectDriver(dsn string) string {
 	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
@@ -253,6 +709,32 @@ func detectDriver(dsn string) string {
 	return "postgres"
 }
 
+// SandboxImageFor returns the sandbox image for a CPU architecture (in
+// GOARCH form, e.g. arm64): the SANDBOX_ARCH_IMAGES entry if there is one,
+// otherwise SandboxImage.
+func (c *Config) SandboxImageFor(arch string) string {
+	if image := c.SandboxArchImages[arch]; image != "" {
+		return image
+	}
+	return c.SandboxImage
+}
+
+// SandboxImageAllowed reports whether a project may use image as its sandbox
+// image: it must match a SANDBOX_ALLOWED_IMAGES entry exactly, or start with
+// the part of an entry before a trailing *.
+func (c *Config) SandboxImageAllowed(image string) bool {
+	for _, allowed := range c.SandboxAllowedImages {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+			if strings.HasPrefix(image, prefix) {
+				return true
+			}
+		} else if image == allowed {
+			return true
+		}
+	}
+	return false
+}
+
 // CleanDSN removes the driver prefix from DSN for database/sql
 func (c *Config) CleanDSN() string {
 	dsn := c.DatabaseDSN
@@ -284,6 +766,78 @@ func getEnvInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// validateSandboxResources checks that sandbox resource settings are
+// non-negative and that requests don't exceed the limits.
+func validateSandboxResources(cfg *Config) error {
+	switch {
+	case cfg.SandboxMemoryLimitMB < 0:
+		return fmt.Errorf("SANDBOX_MEMORY_LIMIT_MB must not be negative, got %d", cfg.SandboxMemoryLimitMB)
+	case cfg.SandboxCPULimit < 0:
+		return fmt.Errorf("SANDBOX_CPU_LIMIT must not be negative, got %g", cfg.SandboxCPULimit)
+	case cfg.SandboxMemoryRequestMB < 0:
+		return fmt.Errorf("SANDBOX_MEMORY_REQUEST_MB must not be negative, got %d", cfg.SandboxMemoryRequestMB)
+	case cfg.SandboxCPURequest < 0:
+		return fmt.Errorf("SANDBOX_CPU_REQUEST must not be negative, got %g", cfg.SandboxCPURequest)
+	case cfg.SandboxMemoryLimitMB > 0 && cfg.SandboxMemoryRequestMB > cfg.SandboxMemoryLimitMB:
+		return fmt.Errorf("SANDBOX_MEMORY_REQUEST_MB (%d) must not exceed SANDBOX_MEMORY_LIMIT_MB (%d)", cfg.SandboxMemoryRequestMB, cfg.SandboxMemoryLimitMB)
+	case cfg.SandboxCPULimit > 0 && cfg.SandboxCPURequest > cfg.SandboxCPULimit:
+		return fmt.Errorf("SANDBOX_CPU_REQUEST (%g) must not exceed SANDBOX_CPU_LIMIT (%g)", cfg.SandboxCPURequest, cfg.SandboxCPULimit)
+	}
+	return nil
+}
+
+// maxProxyConfigSize keeps the encoded proxy config well below the kernel's
+// limit on a single environment variable (128KB).
+const maxProxyConfigSize = 64 * 1024
+
+// maxInitScriptSize limits the init script for the same reason.
+const maxInitScriptSize = 64 * 1024
+
+// loadProxyConfig reads a proxy config file and checks that it is YAML
+// small enough to pass to sandboxes through their environment.
+func loadProxyConfig(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxProxyConfigSize {
+		return nil, fmt.Errorf("%s is %d bytes, must be at most %d", path, len(data), maxProxyConfigSize)
+	}
+	var parsed map[string]any
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("%s is not valid YAML: %w", path, err)
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	return data, nil
+}
+
+// loadInitScript reads the sandbox init script, which is passed to every
+// sandbox in an environment variable.
+func loadInitScript(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxInitScriptSize {
+		return nil, fmt.Errorf("%s is %d bytes, must be at most %d", path, len(data), maxInitScriptSize)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	return data, nil
+}
+
 func getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if b, err := strconv.ParseBool(value); err == nil {
@@ -293,6 +847,24 @@ func getEnvBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// parseIPPrefix parses a CIDR prefix or a single IP address, which is
+// treated as a prefix matching only itself.
+func parseIPPrefix(value string) (netip.Prefix, error) {
+	if !strings.Contains(value, "/") {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix.Masked(), nil
+}
+
 func getEnvList(key string, defaultValue []string) []string {
 	if value := os.Getenv(key); value != "" {
 		return strings.Split(value, ",")
@@ -300,6 +872,25 @@ func getEnvList(key string, defaultValue []string) []string {
 	return defaultValue
 }
 
+// getEnvMap parses a comma-separated list of key=value pairs.
+// Entries without a key are skipped.
+func getEnvMap(key string) map[string]string {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		k, v, _ := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		result[k] = strings.TrimSpace(v)
+	}
+	return result
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if d, err := time.ParseDuration(value); err == nil {
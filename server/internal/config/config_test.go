@@ -0,0 +1,124 @@
+package config
+
+import "testing"
+
+func TestValidateLogConfig(t *testing.T) {
+	tests := []struct {
+		driver  string
+		opts    map[string]string
+		wantErr bool
+	}{
+		{driver: "json-file", opts: map[string]string{"max-size": "10m", "max-file": "3", "compress": "true"}},
+		{driver: "local", opts: map[string]string{"max-size": "512k"}},
+		{driver: "none"},
+		{driver: "daemon"},
+		{driver: "syslog", opts: map[string]string{"syslog-address": "udp://logs:514"}},
+		{driver: "", wantErr: true},
+		{driver: "none", opts: map[string]string{"max-size": "10m"}, wantErr: true},
+		{driver: "json-file", opts: map[string]string{"max-size": "ten megs"}, wantErr: true},
+		{driver: "json-file", opts: map[string]string{"max-file": "0"}, wantErr: true},
+		{driver: "json-file", opts: map[string]string{"syslog-address": "udp://logs:514"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := validateLogConfig(tt.driver, tt.opts)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateLogConfig(%q, %v) error = %v, wantErr %v", tt.driver, tt.opts, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateSandboxResources(t *testing.T) {
+	tests := []struct {
+		cfg     Config
+		wantErr bool
+	}{
+		{cfg: Config{}},
+		{cfg: Config{SandboxMemoryLimitMB: 4096, SandboxMemoryRequestMB: 1024, SandboxCPULimit: 2, SandboxCPURequest: 0.5}},
+		{cfg: Config{SandboxMemoryRequestMB: 1024, SandboxCPURequest: 4}}, // No limits
+		{cfg: Config{SandboxMemoryLimitMB: -1}, wantErr: true},
+		{cfg: Config{SandboxCPURequest: -0.5}, wantErr: true},
+		{cfg: Config{SandboxMemoryLimitMB: 1024, SandboxMemoryRequestMB: 2048}, wantErr: true},
+		{cfg: Config{SandboxCPULimit: 1, SandboxCPURequest: 1.5}, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := validateSandboxResources(&tt.cfg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateSandboxResources(%+v) error = %v, wantErr %v", tt.cfg, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidPlatform(t *testing.T) {
+	tests := []struct {
+		platform string
+		want     bool
+	}{
+		{platform: "linux/amd64", want: true},
+		{platform: "linux/arm/v7", want: true},
+		{platform: "amd64"},
+		{platform: "linux/"},
+		{platform: "linux/arm/v7/extra"},
+	}
+	for _, tt := range tests {
+		if got := validPlatform(tt.platform); got != tt.want {
+			t.Errorf("validPlatform(%q) = %v, want %v", tt.platform, got, tt.want)
+		}
+	}
+}
+
+func TestSandboxImageFor(t *testing.T) {
+	cfg := Config{
+		SandboxImage:      "sandbox:latest",
+		SandboxArchImages: map[string]string{"arm64": "sandbox:arm64"},
+	}
+	if got := cfg.SandboxImageFor("arm64"); got != "sandbox:arm64" {
+		t.Errorf("SandboxImageFor(arm64) = %q, want sandbox:arm64", got)
+	}
+	if got := cfg.SandboxImageFor("amd64"); got != "sandbox:latest" {
+		t.Errorf("SandboxImageFor(amd64) = %q, want sandbox:latest", got)
+	}
+}
+
+func TestSandboxImageAllowed(t *testing.T) {
+	cfg := Config{SandboxAllowedImages: []string{"ghcr.io/org/sandbox:cuda", "registry.corp/sandboxes/*"}}
+	tests := []struct {
+		image string
+		want  bool
+	}{
+		{"ghcr.io/org/sandbox:cuda", true},
+		{"ghcr.io/org/sandbox:latest", false},
+		{"registry.corp/sandboxes/python:3.12", true},
+		{"registry.corp/other:1", false},
+		{"docker.io/library/alpine", false},
+	}
+	for _, tt := range tests {
+		if got := cfg.SandboxImageAllowed(tt.image); got != tt.want {
+			t.Errorf("SandboxImageAllowed(%q) = %v, want %v", tt.image, got, tt.want)
+		}
+	}
+
+	if (&Config{}).SandboxImageAllowed("ghcr.io/org/sandbox:cuda") {
+		t.Error("expected no images to be allowed without SANDBOX_ALLOWED_IMAGES")
+	}
+}
+
+func TestValidateSessionNameTemplate(t *testing.T) {
+	tests := []struct {
+		tmpl    string
+		wantErr bool
+	}{
+		{tmpl: ""},
+		{tmpl: "Untitled"},
+		{tmpl: "{summary}"},
+		{tmpl: "{date} {workspace}@{branch}: {summary} ({time})"},
+		{tmpl: "{message}", wantErr: true},
+		{tmpl: "{Summary}", wantErr: true},
+		{tmpl: "{summary} {}", wantErr: true},
+	}
+	for _, tt := range tests {
+		err := validateSessionNameTemplate(tt.tmpl)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validateSessionNameTemplate(%q) error = %v, wantErr %v", tt.tmpl, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package config
+
+import (
+	"maps"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Sources of an effective setting.
+const (
+	SourceDefault = "default"
+	SourceEnv     = "env"
+	SourceDotEnv  = ".env"
+)
+
+// redactedValue replaces secret values in Settings.
+const redactedValue = "[redacted]"
+
+// dotEnvKeys are the variables set from the .env file by LoadDotEnv.
+var dotEnvKeys = map[string]bool{}
+
+// LoadDotEnv loads .env from the working directory, if present, without
+// overriding variables that are already set. It remembers which variables
+// came from the file so Settings can report their source.
+func LoadDotEnv() {
+	values, err := godotenv.Read()
+	if err != nil {
+		return
+	}
+	for key, value := range values {
+		if _, set := os.LookupEnv(key); set {
+			continue
+		}
+		if err := os.Setenv(key, value); err == nil {
+			dotEnvKeys[key] = true
+		}
+	}
+}
+
+// Setting is one effective configuration value, named by its environment variable.
+type Setting struct {
+	Name     string `json:"name"`
+	Value    any    `json:"value"`
+	Source   string `json:"source"` // "default", "env", or ".env"
+	Redacted bool   `json:"redacted,omitempty"`
+}
+
+// Settings returns the effective configuration with secrets redacted, in
+// the order of the Config fields. Values read from files (proxy config,
+// init script, templates) are reported by path only.
+func (c *Config) Settings() []Setting {
+	return []Setting{
+		setting("PORT", c.Port),
+		setting("CORS_ORIGINS", c.CORSOrigins),
+		setting("CORS_DEBUG", c.CORSDebug),
+		setting("SUGGESTIONS_ENABLED", c.SuggestionsEnabled),
+		dsnSetting(c.DatabaseDSN),
+		setting("AUTH_ENABLED", c.AuthEnabled),
+		setting("ADMIN_EMAILS", c.AdminEmails),
+		setting("RATE_LIMIT_PER_MINUTE", c.RateLimitPerMinute),
+		setting("RATE_LIMIT_BURST", c.RateLimitBurst),
+		setting("TRUSTED_PROXIES", c.TrustedProxies),
+		setting("SSE_MAX_LIFETIME", c.SSEMaxLifetime),
+		setting("SSE_IDLE_TIMEOUT", c.SSEIdleTimeout),
+		secretSetting("SESSION_SECRET", string(c.SessionSecret)),
+		secretSetting("ENCRYPTION_KEY", string(c.EncryptionKey)),
+		setting("PROJECT_TEMPLATES", c.ProjectTemplatesFile),
+		setting("WORKSPACE_DIR", c.WorkspaceDir),
+		setting("WORKSPACE_LAYOUT", c.WorkspaceLayout),
+		setting("GIT_MIRROR_DIR", c.GitMirrorDir),
+		setting("GIT_MIRROR_MAX_AGE", c.GitMirrorMaxAge),
+		setting("SANDBOX_IMAGE", c.SandboxImage),
+		setting("SANDBOX_ARCH_IMAGES", c.SandboxArchImages),
+		setting("SANDBOX_ALLOWED_IMAGES", c.SandboxAllowedImages),
+		setting("SANDBOX_PLATFORM", c.SandboxPlatform),
+		setting("SANDBOX_IDLE_TIMEOUT", c.SandboxIdleTimeout),
+		setting("IDLE_CHECK_INTERVAL", c.IdleCheckInterval),
+		setting("PROXY_REQUIRED", c.ProxyRequired),
+		setting("DOCKER_DNS", c.DockerDNS),
+		setting("SANDBOX_EXTRA_LABELS", c.SandboxExtraLabels),
+		setting("SANDBOX_STOP_SIGNAL", c.SandboxStopSignal),
+		setting("SANDBOX_ALLOWED_USERS", c.SandboxAllowedUsers),
+		setting("SANDBOX_MAX_EXECS", c.SandboxMaxExecs),
+		setting("MAX_SANDBOXES_PER_HOST", c.MaxSandboxesPerHost),
+		setting("SANDBOX_OVERLAY_OPTIONS", c.SandboxOverlayOpts),
+		setting("SANDBOX_LOG_DRIVER", c.SandboxLogDriver),
+		setting("SANDBOX_LOG_OPTIONS", c.SandboxLogOptions),
+		setting("SANDBOX_MEMORY_LIMIT_MB", c.SandboxMemoryLimitMB),
+		setting("SANDBOX_CPU_LIMIT", c.SandboxCPULimit),
+		setting("SANDBOX_MEMORY_REQUEST_MB", c.SandboxMemoryRequestMB),
+		setting("SANDBOX_CPU_REQUEST", c.SandboxCPURequest),
+		setting("SANDBOX_CACHE_MAX_SIZE_MB", c.SandboxCacheMaxSizeMB),
+		setting("SANDBOX_CACHE_EVICT_TARGET_PERCENT", c.SandboxCacheEvictTargetPercent),
+		setting("SANDBOX_NOFILE_LIMIT", c.SandboxNoFileLimit),
+		setting("COPY_UPLOAD_MAX_MB", c.CopyUploadMaxMB),
+		setting("SANDBOX_PROXY_CONFIG", c.SandboxProxyConfigFile),
+		proxyURLSetting("SANDBOX_PROXY_UPSTREAM", c.SandboxProxyUpstream),
+		setting("SANDBOX_PROXY_UPSTREAM_NO_PROXY", c.SandboxProxyUpstreamNoProxy),
+		setting("SANDBOX_INIT_SCRIPT", c.SandboxInitScriptFile),
+		setting("SANDBOX_INIT_SCRIPT_FATAL", c.SandboxInitScriptFatal),
+		setting("SANDBOX_RESTART_POLICY", c.SandboxRestartPolicy),
+		setting("SANDBOX_RESTART_MAX_RETRIES", c.SandboxRestartMaxRetries),
+		secretSetting("SANDBOX_HOOK_PRE_CREATE_URL", c.SandboxHookPreCreateURL),
+		secretSetting("SANDBOX_HOOK_POST_CREATE_URL", c.SandboxHookPostCreateURL),
+		setting("SANDBOX_HOOK_FAILURE_POLICY", c.SandboxHookFailurePolicy),
+		setting("SANDBOX_HOOK_TIMEOUT", c.SandboxHookTimeout),
+		setting("SANDBOX_STARTUP_PROBE_SUCCESSES", c.SandboxStartupProbeSuccesses),
+		setting("SANDBOX_STARTUP_PROBE_INTERVAL", c.SandboxStartupProbeInterval),
+		setting("SANDBOX_STARTUP_TIMEOUT", c.SandboxStartupTimeout),
+		setting("SANDBOX_STARTUP_MAX_RESTARTS", c.SandboxStartupMaxRestarts),
+		setting("CHAT_HISTORY_MAX_MESSAGES", c.ChatHistoryMaxMessages),
+		setting("CHAT_HISTORY_MAX_TOKENS", c.ChatHistoryMaxTokens),
+		setting("CHAT_HISTORY_STRATEGY", c.ChatHistoryStrategy),
+		setting("CHAT_DISCONNECT_CANCEL_AFTER", c.ChatDisconnectCancelAfter),
+		setting("SESSION_NAME_TEMPLATE", c.SessionNameTemplate),
+		secretSetting("EXPORTER_URL", c.ExporterURL),
+		setting("EXPORTER_FORMAT", c.ExporterFormat),
+		secretSetting("EXPORTER_HEADERS", strings.Join(slices.Sorted(maps.Keys(c.ExporterHeaders)), ",")),
+		setting("EXPORTER_BUFFER_SIZE", c.ExporterBufferSize),
+		setting("EXPORTER_BATCH_SIZE", c.ExporterBatchSize),
+		setting("EXPORTER_FLUSH_INTERVAL", c.ExporterFlushInterval),
+		setting("EXPORTER_MAX_RETRIES", c.ExporterMaxRetries),
+		setting("EXPORTER_TIMEOUT", c.ExporterTimeout),
+		setting("EXPORTER_METRICS_INTERVAL", c.ExporterMetricsInterval),
+		setting("DOCKER_HOST", c.DockerHost),
+		setting("DOCKER_NETWORK", c.DockerNetwork),
+		setting("VZ_DATA_DIR", c.VZDataDir),
+		setting("VZ_CONSOLE_LOG_DIR", c.VZConsoleLogDir),
+		setting("VZ_KERNEL_PATH", c.VZKernelPath),
+		setting("VZ_INITRD_PATH", c.VZInitrdPath),
+		setting("VZ_BASE_DISK_PATH", c.VZBaseDiskPath),
+		setting("VZ_IMAGE_REF", c.VZImageRef),
+		setting("VZ_HOME_DIR", c.VZHomeDir),
+		setting("VZ_CPU_COUNT", c.VZCPUCount),
+		setting("VZ_MEMORY_MB", c.VZMemoryMB),
+		setting("VZ_DATA_DISK_GB", c.VZDataDiskGB),
+		setting("VZ_WARM_WORKERS", c.VZWarmWorkers),
+		setting("VZ_WARM_TIMEOUT", c.VZWarmTimeout),
+		setting("VZ_WARM_MAX_PROJECTS", c.VZWarmMaxProjects),
+		setting("VZ_WARM_RECENT_WINDOW", c.VZWarmRecentWindow),
+		setting("LOCAL_PROVIDER_ENABLED", c.LocalProviderEnabled),
+		setting("LOCAL_AGENT_BINARY", c.LocalAgentBinary),
+		setting("LOCAL_PROVIDER_ISOLATION", c.LocalProviderIsolation),
+		setting("PODMAN_ENABLED", c.PodmanEnabled),
+		setting("PODMAN_HOST", c.PodmanHost),
+		setting("PODMAN_USERNS", c.PodmanUserns),
+		setting("PODMAN_RUNTIME", c.PodmanRuntime),
+		setting("SSH_ENABLED", c.SSHEnabled),
+		setting("SSH_PORT", c.SSHPort),
+		setting("SSH_HOST_KEY_PATH", c.SSHHostKeyPath),
+		setting("SERVICE_PROXY_AUTO_START", c.ServiceProxyAutoStart),
+		setting("SERVICE_PROXY_MAX_CONNS_PER_SESSION", c.ServiceProxyMaxConnsPerSession),
+		setting("SERVICE_PROXY_MAX_CONNS", c.ServiceProxyMaxConns),
+		setting("SERVICE_PROXY_QUEUE_TIMEOUT", c.ServiceProxyQueueTimeout),
+		setting("DISPATCHER_ENABLED", c.DispatcherEnabled),
+		setting("DISPATCHER_POLL_INTERVAL", c.DispatcherPollInterval),
+		setting("DISPATCHER_HEARTBEAT_INTERVAL", c.DispatcherHeartbeatInterval),
+		setting("DISPATCHER_HEARTBEAT_TIMEOUT", c.DispatcherHeartbeatTimeout),
+		setting("DISPATCHER_JOB_TIMEOUT", c.DispatcherJobTimeout),
+		setting("DISPATCHER_STALE_JOB_TIMEOUT", c.DispatcherStaleJobTimeout),
+		setting("DISPATCHER_IMMEDIATE_EXECUTION", c.DispatcherImmediateExecution),
+		setting("JOB_RETRY_BACKOFF", c.JobRetryBackoff),
+		setting("JOB_MAX_ATTEMPTS", c.JobMaxAttempts),
+		setting("GITHUB_CLIENT_ID", c.GitHubClientID),
+		secretSetting("GITHUB_CLIENT_SECRET", c.GitHubClientSecret),
+		setting("GOOGLE_CLIENT_ID", c.GoogleClientID),
+		secretSetting("GOOGLE_CLIENT_SECRET", c.GoogleClientSecret),
+		setting("ANTHROPIC_CLIENT_ID", c.AnthropicClientID),
+		setting("GITHUB_COPILOT_CLIENT_ID", c.GitHubCopilotClientID),
+		setting("CODEX_CLIENT_ID", c.CodexClientID),
+		setting("CREDENTIAL_EXPIRY_WARNING", c.CredentialExpiryWarning),
+		setting("CREDENTIAL_EXPIRY_CHECK_INTERVAL", c.CredentialExpiryCheckInterval),
+		setting("DEBUG_DOCKER", c.DebugDocker),
+		setting("DEBUG_DOCKER_PORT", c.DebugDockerPort),
+		secretSetting("DEBUG_DOCKER_TOKEN", c.DebugDockerToken),
+		setting("DEBUG_DOCKER_MAX_CONNS", c.DebugDockerMaxConns),
+		setting("DEBUG_DOCKER_IDLE_TIMEOUT", c.DebugDockerIdleTimeout),
+		setting("DEBUG_DOCKER_SOCKET", c.DebugDockerSocket),
+		setting("LOG_FILE", c.LogFile),
+		setting("STDIN_KEEPALIVE", c.StdinKeepalive),
+		setting("TAURI", c.TauriMode),
+		secretSetting("DISCOBOT_SECRET", c.TauriSecret),
+	}
+}
+
+func setting(name string, value any) Setting {
+	if d, ok := value.(time.Duration); ok {
+		value = d.String()
+	}
+	return Setting{Name: name, Value: value, Source: settingSource(name)}
+}
+
+// secretSetting reports only whether a secret is set.
+func secretSetting(name, value string) Setting {
+	s := Setting{Name: name, Value: "", Source: settingSource(name)}
+	if value != "" {
+		s.Value = redactedValue
+		s.Redacted = true
+	}
+	return s
+}
+
+// settingSource reports where an environment variable's value came from.
+func settingSource(name string) string {
+	if _, set := os.LookupEnv(name); !set {
+		return SourceDefault
+	}
+	if dotEnvKeys[name] {
+		return SourceDotEnv
+	}
+	return SourceEnv
+}
+
+// proxyURLSetting reports a proxy URL with any password masked.
+func proxyURLSetting(name, value string) Setting {
+	s := setting(name, value)
+	if u, err := url.Parse(value); err == nil && u.User != nil {
+		s.Value = u.Redacted()
+		s.Redacted = s.Value != value
+	}
+	return s
+}
+
+// dsnPasswordPattern matches the password in a key/value Postgres DSN.
+var dsnPasswordPattern = regexp.MustCompile(`(?i)(password\s*=\s*)('[^']*'|\S+)`)
+
+// dsnSetting reports the database DSN with any password masked.
+func dsnSetting(dsn string) Setting {
+	s := setting("DATABASE_DSN", redactDSN(dsn))
+	s.Redacted = s.Value != dsn
+	return s
+}
+
+// redactDSN masks the password in a database DSN, in URL or key/value form,
+// the way url.URL.Redacted does.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" && u.Host != "" {
+		if q := u.Query(); q.Has("password") {
+			q.Set("password", "xxxxx")
+			u.RawQuery = q.Encode()
+		}
+		return u.Redacted()
+	}
+	return dsnPasswordPattern.ReplaceAllString(dsn, "${1}xxxxx")
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectTemplate seeds a new project with workspaces and agents.
+type ProjectTemplate struct {
+	Name        string                     `yaml:"name" json:"name"`
+	Description string                     `yaml:"description" json:"description,omitempty"`
+	Workspaces  []ProjectTemplateWorkspace `yaml:"workspaces" json:"workspaces,omitempty"`
+	// Agent types to create; the first becomes the project's default agent
+	Agents []string `yaml:"agents" json:"agents,omitempty"`
+}
+
+// ProjectTemplateWorkspace is a workspace created with a templated project.
+type ProjectTemplateWorkspace struct {
+	Path        string `yaml:"path" json:"path"`
+	SourceType  string `yaml:"sourceType" json:"sourceType,omitempty"` // "local" (default) or "git"
+	DisplayName string `yaml:"displayName" json:"displayName,omitempty"`
+}
+
+// ProjectTemplate returns the template with the given name, or nil.
+func (c *Config) ProjectTemplate(name string) *ProjectTemplate {
+	for i := range c.ProjectTemplates {
+		if c.ProjectTemplates[i].Name == name {
+			return &c.ProjectTemplates[i]
+		}
+	}
+	return nil
+}
+
+// loadProjectTemplates reads project templates from a YAML file containing
+// a list of templates.
+func loadProjectTemplates(path string) ([]ProjectTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var templates []ProjectTemplate
+	if err := yaml.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("%s is not valid YAML: %w", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range templates {
+		t := &templates[i]
+		if t.Name == "" {
+			return nil, fmt.Errorf("%s: template %d has no name", path, i+1)
+		}
+		if seen[t.Name] {
+			return nil, fmt.Errorf("%s: duplicate template %q", path, t.Name)
+		}
+		seen[t.Name] = true
+		for j := range t.Workspaces {
+			ws := &t.Workspaces[j]
+			if ws.Path == "" {
+				return nil, fmt.Errorf("%s: template %q: workspace %d has no path", path, t.Name, j+1)
+			}
+			if ws.SourceType == "" {
+				ws.SourceType = "local"
+			}
+			if ws.SourceType != "local" && ws.SourceType != "git" {
+				return nil, fmt.Errorf("%s: template %q: workspace %q: sourceType must be \"local\" or \"git\"", path, t.Name, ws.Path)
+			}
+		}
+	}
+	return templates, nil
+}
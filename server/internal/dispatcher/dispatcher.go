@@ -35,6 +35,10 @@ type Service struct {
 	isLeader   bool
 	isLeaderMu sync.RWMutex
 
+	// Per-job logs, streamed to subscribers while the job runs
+	jobLogs   map[string]*jobLog
+	jobLogsMu sync.Mutex
+
 	// Notification channel for immediate job execution
 	// When a job is enqueued, send to this channel to wake up the processor
 	notifyCh chan struct{}
@@ -55,6 +59,7 @@ func NewService(s *store.Store, cfg *config.Config, eventBroker *events.Broker)
 		singleNode:  cfg.DatabaseDriver == "sqlite",
 		executors:   make(map[jobs.JobType]JobExecutor),
 		runningJobs: make(map[jobs.JobType]int),
+		jobLogs:     make(map[string]*jobLog),
 		notifyCh:    make(chan struct{}, 100), // Buffered to avoid blocking enqueuers
 	}
 }
@@ -288,14 +293,19 @@ func (d *Service) getAvailableJobTypes() []string {
 	return available
 }
 
-// executeJob processes a single job.
+// executeJob processes a single job. Lines logged with jobs.Logf during the
+// job are also written to its log, which SubscribeLogs streams.
 func (d *Service) executeJob(job *model.Job) {
-	log.Printf("Processing job %s (type: %s)", job.ID, job.Type)
+	jobLog := d.startJobLog(job.ID)
+	defer d.finishJobLog(jobLog)
+	logCtx := jobs.WithLogger(d.ctx, jobLog)
+
+	jobs.Logf(logCtx, "Processing job %s (type: %s, attempt %d/%d)", job.ID, job.Type, job.Attempts, job.MaxAttempts)
 
 	executor, ok := d.executors[jobs.JobType(job.Type)]
 	if !ok {
 		errMsg := "no executor registered for job type"
-		log.Printf("Job %s failed: %s", job.ID, errMsg)
+		jobs.Logf(logCtx, "Job %s failed: %s", job.ID, errMsg)
 		if err := d.store.FailJob(d.ctx, job.ID, errMsg, d.cfg.JobRetryBackoff); err != nil {
 			log.Printf("Failed to mark job %s as failed: %v", job.ID, err)
 		}
@@ -303,12 +313,12 @@ func (d *Service) executeJob(job *model.Job) {
 	}
 
 	// Execute with timeout
-	ctx, cancel := context.WithTimeout(d.ctx, d.cfg.DispatcherJobTimeout)
+	ctx, cancel := context.WithTimeout(logCtx, d.cfg.DispatcherJobTimeout)
 	defer cancel()
 
 	err := executor.Execute(ctx, job)
 	if err != nil {
-		log.Printf("Job %s failed: %v", job.ID, err)
+		jobs.Logf(logCtx, "Job %s failed: %v", job.ID, err)
 		if err := d.store.FailJob(d.ctx, job.ID, err.Error(), d.cfg.JobRetryBackoff); err != nil {
 			log.Printf("Failed to mark job %s as failed: %v", job.ID, err)
 		}
@@ -317,7 +327,7 @@ func (d *Service) executeJob(job *model.Job) {
 		return
 	}
 
-	log.Printf("Job %s completed successfully", job.ID)
+	jobs.Logf(logCtx, "Job %s completed successfully", job.ID)
 	if err := d.store.CompleteJob(d.ctx, job.ID); err != nil {
 		log.Printf("Failed to mark job %s as completed: %v", job.ID, err)
 	}
@@ -0,0 +1,140 @@
+package dispatcher
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// maxJobLogLines caps the lines kept per job; older lines are dropped.
+	maxJobLogLines = 1000
+
+	// jobLogRetention is how long a finished job's log is kept for clients
+	// that subscribe after it ended.
+	jobLogRetention = 10 * time.Minute
+
+	// jobLogSubscriberBuffer is the number of lines buffered per subscriber.
+	// Lines are dropped for subscribers that fall further behind.
+	jobLogSubscriberBuffer = 256
+)
+
+// LogLine is a line of a job's log.
+type LogLine struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// jobLog holds a job's log lines and fans them out to subscribers.
+// It implements jobs.Logger.
+type jobLog struct {
+	mu      sync.Mutex
+	lines   []LogLine
+	subs    map[chan LogLine]struct{}
+	running bool
+	ended   time.Time // When the last run ended; zero until then
+}
+
+// Log appends a line and sends it to the subscribers.
+func (l *jobLog) Log(message string) {
+	line := LogLine{Time: time.Now(), Message: message}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lines = append(l.lines, line)
+	if len(l.lines) > maxJobLogLines {
+		l.lines = l.lines[len(l.lines)-maxJobLogLines:]
+	}
+	for ch := range l.subs {
+		select {
+		case ch <- line:
+		default:
+			// Subscriber is too slow; drop the line rather than block the job
+		}
+	}
+}
+
+// startJobLog returns the log for a job run, creating it if needed, and
+// prunes logs of jobs that ended more than jobLogRetention ago.
+func (d *Service) startJobLog(jobID string) *jobLog {
+	d.jobLogsMu.Lock()
+	defer d.jobLogsMu.Unlock()
+
+	for id, l := range d.jobLogs {
+		l.mu.Lock()
+		expired := !l.running && !l.ended.IsZero() && time.Since(l.ended) > jobLogRetention && len(l.subs) == 0
+		l.mu.Unlock()
+		if expired {
+			delete(d.jobLogs, id)
+		}
+	}
+
+	l, ok := d.jobLogs[jobID]
+	if !ok {
+		l = &jobLog{subs: make(map[chan LogLine]struct{})}
+		d.jobLogs[jobID] = l
+	}
+	l.mu.Lock()
+	l.running = true
+	l.ended = time.Time{}
+	l.mu.Unlock()
+	return l
+}
+
+// finishJobLog ends a job run, closing the subscribers' channels.
+func (d *Service) finishJobLog(l *jobLog) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.running = false
+	l.ended = time.Now()
+	for ch := range l.subs {
+		close(ch)
+		delete(l.subs, ch)
+	}
+}
+
+// SubscribeLogs returns the log lines a job has written so far and a channel
+// of the lines it writes from now on, which is closed when the current run
+// of the job ends. A job that already ended (within jobLogRetention) returns
+// a closed channel. A job that hasn't started yet, or that runs on another
+// server, returns an open channel that receives nothing until it runs here.
+// Call cancel when done reading.
+func (d *Service) SubscribeLogs(jobID string) (backlog []LogLine, lines <-chan LogLine, cancel func()) {
+	d.jobLogsMu.Lock()
+	defer d.jobLogsMu.Unlock()
+
+	l, ok := d.jobLogs[jobID]
+	if !ok {
+		l = &jobLog{subs: make(map[chan LogLine]struct{})}
+		d.jobLogs[jobID] = l
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	backlog = append([]LogLine(nil), l.lines...)
+	ch := make(chan LogLine, jobLogSubscriberBuffer)
+	if !l.running && !l.ended.IsZero() {
+		close(ch)
+		return backlog, ch, func() {}
+	}
+	l.subs[ch] = struct{}{}
+
+	cancel = func() {
+		d.jobLogsMu.Lock()
+		defer d.jobLogsMu.Unlock()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.subs[ch]; ok {
+			close(ch)
+			delete(l.subs, ch)
+		}
+		// Don't keep logs created only for a subscriber of a job that never ran here
+		if len(l.subs) == 0 && len(l.lines) == 0 && !l.running && l.ended.IsZero() && d.jobLogs[jobID] == l {
+			delete(d.jobLogs, jobID)
+		}
+	}
+	return backlog, ch, cancel
+}
@@ -43,5 +43,6 @@ func (e *SessionCommitExecutor) Execute(ctx context.Context, job *model.Job) err
 		return fmt.Errorf("projectId is required")
 	}
 
-	return e.sessionService.PerformCommit(ctx, payload.ProjectID, payload.SessionID)
+	jobs.Logf(ctx, "Committing session %s", payload.SessionID)
+	return e.sessionService.PerformCommit(ctx, payload.ProjectID, payload.SessionID, payload.UserID)
 }
@@ -44,5 +44,6 @@ func (e *SessionDeleteExecutor) Execute(ctx context.Context, job *model.Job) err
 		return fmt.Errorf("projectId is required")
 	}
 
+	jobs.Logf(ctx, "Deleting session %s", payload.SessionID)
 	return e.sessionService.PerformDeletion(ctx, payload.ProjectID, payload.SessionID)
 }
@@ -43,5 +43,6 @@ func (e *SessionInitExecutor) Execute(ctx context.Context, job *model.Job) error
 		return fmt.Errorf("workspaceId is required")
 	}
 
+	jobs.Logf(ctx, "Initializing session %s", payload.SessionID)
 	return e.sessionService.Initialize(ctx, payload.SessionID)
 }
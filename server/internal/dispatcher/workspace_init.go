@@ -40,5 +40,6 @@ func (e *WorkspaceInitExecutor) Execute(ctx context.Context, job *model.Job) err
 		return fmt.Errorf("workspaceId is required")
 	}
 
+	jobs.Logf(ctx, "Initializing workspace %s", payload.WorkspaceID)
 	return e.workspaceService.Initialize(ctx, payload.WorkspaceID)
 }
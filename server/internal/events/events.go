@@ -23,6 +23,8 @@ const (
 	EventTypeWorkspaceUpdated EventType = "workspace_updated"
 	// EventTypeJobCompleted indicates a job has completed (success or failure)
 	EventTypeJobCompleted EventType = "job_completed"
+	// EventTypeCredentialExpiring indicates a credential will soon need re-authentication
+	EventTypeCredentialExpiring EventType = "credential_expiring"
 )
 
 // Event represents a server-sent event
@@ -32,12 +34,17 @@ type Event struct {
 	Type      EventType       `json:"type"`
 	Timestamp time.Time       `json:"timestamp"`
 	Data      json.RawMessage `json:"data"`
+
+	// ProjectID is the project the event belongs to. It isn't sent to SSE
+	// clients, which subscribe per project.
+	ProjectID string `json:"-"`
 }
 
 // FromModel converts a model.ProjectEvent to an Event
 func FromModel(e *model.ProjectEvent) *Event {
 	return &Event{
 		ID:        e.ID,
+		ProjectID: e.ProjectID,
 		Seq:       e.Seq,
 		Type:      EventType(e.Type),
 		Timestamp: e.CreatedAt,
@@ -68,6 +75,12 @@ type JobCompletedData struct {
 	Error        string `json:"error,omitempty"`
 }
 
+// CredentialExpiringData is the payload for credential_expiring events
+type CredentialExpiringData struct {
+	Provider  string    `json:"provider"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
 // Subscriber represents a client subscribed to events for a specific project.
 type Subscriber struct {
 	ID        string
@@ -117,6 +130,11 @@ func (b *Broker) Subscribe(projectID string) *Subscriber {
 	return b.poller.Subscribe(projectID)
 }
 
+// SubscribeAll creates a subscription for the events of every project.
+func (b *Broker) SubscribeAll() *Subscriber {
+	return b.poller.Subscribe("")
+}
+
 // Unsubscribe removes a subscription.
 func (b *Broker) Unsubscribe(sub *Subscriber) {
 	b.poller.Unsubscribe(sub)
@@ -217,6 +235,28 @@ func (b *Broker) PublishJobCompleted(ctx context.Context, projectID, jobID, jobT
 	return b.Publish(ctx, projectID, event)
 }
 
+// PublishCredentialExpiring is a convenience method to publish credential expiry warnings.
+func (b *Broker) PublishCredentialExpiring(ctx context.Context, projectID, provider string, expiresAt time.Time) error {
+	data := CredentialExpiringData{
+		Provider:  provider,
+		ExpiresAt: expiresAt,
+	}
+
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event data: %w", err)
+	}
+
+	event := &Event{
+		ID:        generateEventID(),
+		Type:      EventTypeCredentialExpiring,
+		Timestamp: time.Now(),
+		Data:      dataBytes,
+	}
+
+	return b.Publish(ctx, projectID, event)
+}
+
 // GetEventsSince returns all persisted events for a project since the given time.
 func (b *Broker) GetEventsSince(ctx context.Context, projectID string, since time.Time) ([]*Event, error) {
 	modelEvents, err := b.store.ListProjectEventsSince(ctx, projectID, since)
@@ -549,3 +549,42 @@ func TestPoller_MultipleSubscribersSameProject(t *testing.T) {
 		}
 	}
 }
+
+func TestBroker_SubscribeAll(t *testing.T) {
+	env := testSetup(t)
+	defer env.Cleanup()
+
+	ctx := context.Background()
+	projectB := env.createSecondProject(t)
+
+	pollerCfg := DefaultPollerConfig()
+	pollerCfg.PollInterval = 10 * time.Millisecond
+	poller := NewPoller(env.Store, pollerCfg)
+	if err := poller.Start(ctx); err != nil {
+		t.Fatalf("Failed to start poller: %v", err)
+	}
+	defer poller.Stop()
+	broker := NewBroker(env.Store, poller)
+
+	sub := broker.SubscribeAll()
+	defer broker.Unsubscribe(sub)
+
+	for _, projectID := range []string{env.ProjectID, projectB} {
+		if err := broker.PublishSessionUpdated(ctx, projectID, "session-"+projectID, "ready", ""); err != nil {
+			t.Fatalf("Failed to publish: %v", err)
+		}
+	}
+
+	var got []string
+	for range 2 {
+		select {
+		case received := <-sub.Events:
+			got = append(got, received.ProjectID)
+		case <-time.After(1 * time.Second):
+			t.Fatalf("Timeout waiting for events, got projects %v", got)
+		}
+	}
+	if got[0] != env.ProjectID || got[1] != projectB {
+		t.Errorf("Expected events from both projects in order, got %v", got)
+	}
+}
@@ -3,6 +3,7 @@ package events
 import (
 	"context"
 	"log"
+	"strconv"
 	"sync"
 	"time"
 
@@ -118,13 +119,14 @@ func (p *Poller) NotifyNewEvent() {
 }
 
 // Subscribe creates a new subscription for events.
-// The subscriber receives all events and should filter by project ID.
+// The subscriber receives the events of projectID, or of every project if
+// projectID is empty.
 func (p *Poller) Subscribe(projectID string) *Subscriber {
 	p.subscribersMu.Lock()
 	defer p.subscribersMu.Unlock()
 
 	p.nextSubID++
-	subID := string(rune('a' + (p.nextSubID % 26)))
+	subID := strconv.Itoa(p.nextSubID)
 
 	sub := &Subscriber{
 		ID:        subID,
@@ -226,7 +228,7 @@ func (p *Poller) pollAndBroadcast() {
 
 		for _, sub := range p.subscribers {
 			// Only send events matching the subscriber's project
-			if sub.ProjectID != dbEvent.ProjectID {
+			if sub.ProjectID != "" && sub.ProjectID != dbEvent.ProjectID {
 				continue
 			}
 
@@ -0,0 +1,393 @@
+// Package exporter pushes session lifecycle events and periodic metrics to an
+// external observability sink: a webhook receiving JSON batches, or an
+// OTLP/HTTP collector receiving them as logs and gauges.
+//
+// Exporting never blocks the server. Events are buffered in memory and sent
+// in batches by a single goroutine, with retries and exponential backoff
+// while the sink is unavailable. Once the buffer is full, new events are
+// dropped and counted.
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/events"
+	"github.com/obot-platform/discobot/server/internal/model"
+)
+
+// Sink formats.
+const (
+	FormatWebhook = "webhook"
+	FormatOTLP    = "otlp"
+)
+
+// maxBackoff caps the delay between retries of a failed request.
+const maxBackoff = 30 * time.Second
+
+// Config configures an Exporter.
+type Config struct {
+	URL             string            // Webhook URL, or OTLP/HTTP base URL
+	Format          string            // FormatWebhook or FormatOTLP
+	Headers         map[string]string // Extra request headers
+	BufferSize      int               // Events held before new ones are dropped
+	BatchSize       int               // Events per request
+	FlushInterval   time.Duration     // Max time an event waits for its batch to fill
+	MaxRetries      int               // Retries before a request is dropped
+	Timeout         time.Duration     // Per-request timeout
+	MetricsInterval time.Duration     // How often metrics are collected and sent (0 = never)
+	RetryBackoff    time.Duration     // Delay before the first retry (default: 1s)
+}
+
+// Event is a lifecycle event as sent to the sink.
+type Event struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"` // e.g. "session.ready", "commit.completed"
+	Type      string          `json:"type"` // The server's event type, e.g. "session_updated"
+	ProjectID string          `json:"projectId"`
+	Timestamp time.Time       `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Metric is a gauge sample as sent to the sink.
+type Metric struct {
+	Name       string            `json:"name"`
+	Value      float64           `json:"value"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Timestamp  time.Time         `json:"timestamp"`
+}
+
+// Collector returns the current value of the exported metrics.
+type Collector func(ctx context.Context) []Metric
+
+// batch is the webhook request body.
+type batch struct {
+	Source  string   `json:"source"`
+	Events  []Event  `json:"events,omitempty"`
+	Metrics []Metric `json:"metrics,omitempty"`
+}
+
+// request is an encoded request to the sink.
+type request struct {
+	url     string
+	body    []byte
+	events  int // Events carried
+	metrics int // Metrics carried
+}
+
+// Exporter forwards events and metrics to the configured sink.
+type Exporter struct {
+	cfg       Config
+	client    *http.Client
+	collector Collector
+	queue     chan Event
+
+	dropped  atomic.Int64 // Events dropped because the buffer was full
+	failed   atomic.Int64 // Events and metrics dropped after exhausting retries
+	exported atomic.Int64 // Events sent
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates an exporter. Call Start to begin sending.
+func New(cfg Config) *Exporter {
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = time.Second
+	}
+	return &Exporter{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		queue:  make(chan Event, cfg.BufferSize),
+	}
+}
+
+// SetCollector sets where metrics come from. Without one, only events and
+// the exporter's own counters are sent.
+func (e *Exporter) SetCollector(c Collector) {
+	e.collector = c
+}
+
+// Export queues an event for the sink without blocking. If the buffer is
+// full the event is dropped.
+func (e *Exporter) Export(ev Event) {
+	select {
+	case e.queue <- ev:
+	default:
+		if e.dropped.Add(1) == 1 {
+			log.Printf("Exporter buffer full, dropping events until the sink catches up")
+		}
+	}
+}
+
+// Start subscribes to every project's events on broker (if not nil) and
+// starts sending. The exporter stops when ctx is cancelled or Stop is called.
+func (e *Exporter) Start(ctx context.Context, broker *events.Broker) {
+	ctx, e.cancel = context.WithCancel(ctx)
+
+	if broker != nil {
+		sub := broker.SubscribeAll()
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			defer broker.Unsubscribe(sub)
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case ev, ok := <-sub.Events:
+					if !ok {
+						return
+					}
+					e.Export(FromEvent(ev))
+				}
+			}
+		}()
+	}
+
+	e.wg.Add(1)
+	go e.run(ctx)
+}
+
+// Stop stops the exporter, making one last attempt to send buffered events
+// within ctx's deadline.
+func (e *Exporter) Stop(ctx context.Context) error {
+	if e.cancel == nil {
+		return nil
+	}
+	e.cancel()
+	e.wg.Wait()
+
+	pending := make([]Event, 0, len(e.queue))
+	for len(e.queue) > 0 {
+		pending = append(pending, <-e.queue)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	var errs []string
+	for chunk := range slices.Chunk(pending, e.cfg.BatchSize) {
+		for _, req := range e.encode(chunk, nil) {
+			if err := e.post(ctx, req); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to send %d buffered events: %s", len(pending), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// run batches queued events and sends them, along with periodic metrics.
+func (e *Exporter) run(ctx context.Context) {
+	defer e.wg.Done()
+
+	flush := time.NewTicker(e.cfg.FlushInterval)
+	defer flush.Stop()
+	var metricsTick <-chan time.Time
+	if e.cfg.MetricsInterval > 0 {
+		ticker := time.NewTicker(e.cfg.MetricsInterval)
+		defer ticker.Stop()
+		metricsTick = ticker.C
+	}
+
+	var pending []Event
+	send := func() {
+		if len(pending) > 0 {
+			e.send(ctx, pending, nil)
+			pending = nil
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			// Leave unsent events to Stop
+			for _, ev := range pending {
+				e.Export(ev)
+			}
+			return
+		case ev := <-e.queue:
+			pending = append(pending, ev)
+			if len(pending) >= e.cfg.BatchSize {
+				send()
+			}
+		case <-flush.C:
+			send()
+		case <-metricsTick:
+			e.send(ctx, nil, e.metrics(ctx))
+		}
+	}
+}
+
+// metrics collects the current metrics and the exporter's own counters.
+func (e *Exporter) metrics(ctx context.Context) []Metric {
+	var metrics []Metric
+	if e.collector != nil {
+		collectCtx, cancel := context.WithTimeout(ctx, e.cfg.Timeout)
+		metrics = e.collector(collectCtx)
+		cancel()
+	}
+	now := time.Now()
+	return append(metrics,
+		Metric{Name: "discobot.exporter.events_exported", Value: float64(e.exported.Load()), Timestamp: now},
+		Metric{Name: "discobot.exporter.events_dropped", Value: float64(e.dropped.Load()), Timestamp: now},
+		Metric{Name: "discobot.exporter.send_failures", Value: float64(e.failed.Load()), Timestamp: now},
+	)
+}
+
+// send delivers events and metrics to the sink, retrying each request with
+// exponential backoff. Requests still failing after MaxRetries are dropped.
+func (e *Exporter) send(ctx context.Context, evs []Event, metrics []Metric) {
+	for _, req := range e.encode(evs, metrics) {
+		if err := e.postWithRetry(ctx, req); err != nil {
+			e.failed.Add(int64(req.events + req.metrics))
+			log.Printf("Exporter dropped %d events and %d metrics: %v", req.events, req.metrics, err)
+			continue
+		}
+		e.exported.Add(int64(req.events))
+	}
+}
+
+// postWithRetry sends a request, retrying failures that may be temporary.
+func (e *Exporter) postWithRetry(ctx context.Context, req request) error {
+	backoff := e.cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := e.post(ctx, req)
+		if err == nil || !retryable(err) || attempt == e.cfg.MaxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// encode builds the requests carrying events and metrics in the sink's format.
+func (e *Exporter) encode(evs []Event, metrics []Metric) []request {
+	if e.cfg.Format == FormatOTLP {
+		base := strings.TrimRight(e.cfg.URL, "/")
+		var reqs []request
+		if len(evs) > 0 {
+			reqs = append(reqs, request{url: base + "/v1/logs", body: otlpLogs(evs), events: len(evs)})
+		}
+		if len(metrics) > 0 {
+			reqs = append(reqs, request{url: base + "/v1/metrics", body: otlpMetrics(metrics), metrics: len(metrics)})
+		}
+		return reqs
+	}
+
+	body, err := json.Marshal(batch{Source: "discobot", Events: evs, Metrics: metrics})
+	if err != nil {
+		log.Printf("Exporter failed to encode batch: %v", err)
+		return nil
+	}
+	return []request{{url: e.cfg.URL, body: body, events: len(evs), metrics: len(metrics)}}
+}
+
+// statusError is a non-2xx response from the sink.
+type statusError struct {
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return "sink returned " + e.status
+}
+
+// retryable reports whether a failed request may succeed if sent again:
+// connection errors, timeouts, 408, 429, and 5xx responses.
+func retryable(err error) bool {
+	var se *statusError
+	if !errors.As(err, &se) {
+		return true
+	}
+	return se.code == http.StatusRequestTimeout || se.code == http.StatusTooManyRequests || se.code >= 500
+}
+
+// post sends one request to the sink.
+func (e *Exporter) post(ctx context.Context, req request) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.url, bytes.NewReader(req.body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.Headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("request to sink failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &statusError{code: resp.StatusCode, status: resp.Status}
+	}
+	return nil
+}
+
+// FromEvent converts a server event into an exported Event.
+func FromEvent(ev *events.Event) Event {
+	return Event{
+		ID:        ev.ID,
+		Name:      eventName(ev),
+		Type:      string(ev.Type),
+		ProjectID: ev.ProjectID,
+		Timestamp: ev.Timestamp,
+		Data:      ev.Data,
+	}
+}
+
+// eventName names a server event as a lifecycle event, such as
+// "session.created", "session.ready", "session.failed", or
+// "commit.completed". Events without a more specific name keep their type.
+func eventName(ev *events.Event) string {
+	switch ev.Type {
+	case events.EventTypeSessionUpdated:
+		var data events.SessionUpdatedData
+		if err := json.Unmarshal(ev.Data, &data); err != nil {
+			break
+		}
+		switch data.Status {
+		case "":
+			if data.CommitStatus != "" {
+				return "commit." + data.CommitStatus
+			}
+		case model.SessionStatusInitializing:
+			return "session.created"
+		case model.SessionStatusError:
+			return "session.failed"
+		default:
+			return "session." + data.Status
+		}
+	case events.EventTypeWorkspaceUpdated:
+		var data events.WorkspaceUpdatedData
+		if err := json.Unmarshal(ev.Data, &data); err == nil && data.Status != "" {
+			return "workspace." + data.Status
+		}
+	case events.EventTypeJobCompleted:
+		var data events.JobCompletedData
+		if err := json.Unmarshal(ev.Data, &data); err == nil && data.Status != "" {
+			return "job." + data.Status
+		}
+	case events.EventTypeCredentialExpiring:
+		return "credential.expiring"
+	}
+	return string(ev.Type)
+}
@@ -0,0 +1,326 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/database"
+	"github.com/obot-platform/discobot/server/internal/events"
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/sandbox/mock"
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// fakeSink records the requests it receives, answering with the status
+// codes in statuses (200 once they run out).
+type fakeSink struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	paths    []string
+	headers  []http.Header
+	bodies   [][]byte
+}
+
+func newFakeSink(t *testing.T, statuses ...int) *fakeSink {
+	t.Helper()
+	f := &fakeSink{statuses: statuses}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		f.mu.Lock()
+		f.paths = append(f.paths, r.URL.Path)
+		f.headers = append(f.headers, r.Header.Clone())
+		f.bodies = append(f.bodies, body)
+		status := http.StatusOK
+		if len(f.statuses) > 0 {
+			status, f.statuses = f.statuses[0], f.statuses[1:]
+		}
+		f.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeSink) requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.bodies)
+}
+
+// webhookEvents returns the IDs of the events received in webhook batches.
+func (f *fakeSink) webhookEvents(t *testing.T) []string {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ids []string
+	for _, body := range f.bodies {
+		var b batch
+		if err := json.Unmarshal(body, &b); err != nil {
+			t.Fatalf("Invalid webhook body %s: %v", body, err)
+		}
+		for _, ev := range b.Events {
+			ids = append(ids, ev.ID)
+		}
+	}
+	return ids
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func testConfig(url string) Config {
+	return Config{
+		URL:           url,
+		Format:        FormatWebhook,
+		BufferSize:    100,
+		BatchSize:     2,
+		FlushInterval: 20 * time.Millisecond,
+		MaxRetries:    3,
+		Timeout:       time.Second,
+		RetryBackoff:  10 * time.Millisecond,
+	}
+}
+
+func TestExporter_Webhook(t *testing.T) {
+	sink := newFakeSink(t)
+	cfg := testConfig(sink.URL)
+	cfg.Headers = map[string]string{"Authorization": "Bearer token"}
+	e := New(cfg)
+	e.Start(context.Background(), nil)
+
+	for i := range 3 {
+		e.Export(Event{ID: fmt.Sprintf("ev-%d", i), Name: "session.ready"})
+	}
+	waitFor(t, "3 events", func() bool { return len(sink.webhookEvents(t)) == 3 })
+	if err := e.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	if got := sink.webhookEvents(t); got[0] != "ev-0" || got[1] != "ev-1" || got[2] != "ev-2" {
+		t.Errorf("Expected events in order, got %v", got)
+	}
+	// A full batch goes out at once, the rest on the flush interval
+	if n := sink.requests(); n != 2 {
+		t.Errorf("Expected 2 requests, got %d", n)
+	}
+	if got := sink.headers[0].Get("Authorization"); got != "Bearer token" {
+		t.Errorf("Authorization = %q", got)
+	}
+}
+
+func TestExporter_Retries(t *testing.T) {
+	sink := newFakeSink(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	e := New(testConfig(sink.URL))
+	e.Start(context.Background(), nil)
+	defer func() { _ = e.Stop(context.Background()) }()
+
+	e.Export(Event{ID: "ev-1"})
+	waitFor(t, "the event", func() bool { return len(sink.webhookEvents(t)) == 3 })
+	if got := e.exported.Load(); got != 1 {
+		t.Errorf("Expected 1 event exported after retries, got %d", got)
+	}
+}
+
+func TestExporter_DropsAfterPermanentFailure(t *testing.T) {
+	sink := newFakeSink(t, http.StatusBadRequest, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	cfg := testConfig(sink.URL)
+	cfg.BatchSize = 1
+	cfg.MaxRetries = 1
+	e := New(cfg)
+	e.Start(context.Background(), nil)
+	defer func() { _ = e.Stop(context.Background()) }()
+
+	e.Export(Event{ID: "rejected"})
+	e.Export(Event{ID: "unavailable"})
+	e.Export(Event{ID: "delivered"})
+	waitFor(t, "the last event", func() bool { return e.exported.Load() == 1 })
+
+	// 400 isn't retried, 503 is retried once
+	if n := sink.requests(); n != 4 {
+		t.Errorf("Expected 4 requests, got %d", n)
+	}
+	if got := e.failed.Load(); got != 2 {
+		t.Errorf("Expected 2 failed events, got %d", got)
+	}
+}
+
+func TestExporter_NeverBlocks(t *testing.T) {
+	sink := newFakeSink(t)
+	cfg := testConfig(sink.URL)
+	cfg.BufferSize = 2
+	e := New(cfg) // Not started, so nothing drains the buffer
+
+	done := make(chan struct{})
+	go func() {
+		for i := range 5 {
+			e.Export(Event{ID: fmt.Sprintf("ev-%d", i)})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Export blocked on a full buffer")
+	}
+	if got := e.dropped.Load(); got != 3 {
+		t.Errorf("Expected 3 dropped events, got %d", got)
+	}
+}
+
+func TestExporter_StopFlushes(t *testing.T) {
+	sink := newFakeSink(t)
+	cfg := testConfig(sink.URL)
+	cfg.FlushInterval = time.Hour
+	cfg.BatchSize = 10
+	e := New(cfg)
+	e.Start(context.Background(), nil)
+
+	e.Export(Event{ID: "ev-1"})
+	e.Export(Event{ID: "ev-2"})
+	if err := e.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if got := sink.webhookEvents(t); len(got) != 2 {
+		t.Errorf("Expected buffered events sent on stop, got %v", got)
+	}
+}
+
+func TestExporter_OTLP(t *testing.T) {
+	sink := newFakeSink(t)
+	cfg := testConfig(sink.URL + "/")
+	cfg.Format = FormatOTLP
+	cfg.BatchSize = 1
+	cfg.MetricsInterval = 20 * time.Millisecond
+	e := New(cfg)
+	e.SetCollector(func(context.Context) []Metric {
+		return []Metric{
+			{Name: "discobot.sessions", Value: 2, Attributes: map[string]string{"status": "ready"}},
+			{Name: "discobot.sessions", Value: 1, Attributes: map[string]string{"status": "error"}},
+		}
+	})
+	e.Start(context.Background(), nil)
+
+	e.Export(Event{ID: "ev-1", Name: "session.failed", ProjectID: "proj-1", Timestamp: time.Unix(1, 0)})
+	var logs otlpLogsRequest
+	var metrics otlpMetricsRequest
+	waitFor(t, "logs and metrics", func() bool {
+		sink.mu.Lock()
+		defer sink.mu.Unlock()
+		for i, path := range sink.paths {
+			switch path {
+			case "/v1/logs":
+				_ = json.Unmarshal(sink.bodies[i], &logs)
+			case "/v1/metrics":
+				_ = json.Unmarshal(sink.bodies[i], &metrics)
+			}
+		}
+		return len(logs.ResourceLogs) > 0 && len(metrics.ResourceMetrics) > 0
+	})
+	if err := e.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	record := logs.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	if record.EventName != "session.failed" || record.SeverityText != "ERROR" || record.TimeUnixNano != "1000000000" {
+		t.Errorf("Unexpected log record %+v", record)
+	}
+	gauges := metrics.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if gauges[0].Name != "discobot.sessions" || len(gauges[0].Gauge.DataPoints) != 2 {
+		t.Errorf("Expected one sessions gauge with 2 points, got %+v", gauges[0])
+	}
+	if len(gauges) != 4 { // Plus the exporter's own counters
+		t.Errorf("Expected 4 gauges, got %d", len(gauges))
+	}
+}
+
+func TestEventName(t *testing.T) {
+	tests := []struct {
+		typ  events.EventType
+		data string
+		want string
+	}{
+		{events.EventTypeSessionUpdated, `{"sessionId":"s","status":"initializing"}`, "session.created"},
+		{events.EventTypeSessionUpdated, `{"sessionId":"s","status":"ready"}`, "session.ready"},
+		{events.EventTypeSessionUpdated, `{"sessionId":"s","status":"error"}`, "session.failed"},
+		{events.EventTypeSessionUpdated, `{"sessionId":"s","commitStatus":"completed"}`, "commit.completed"},
+		{events.EventTypeWorkspaceUpdated, `{"workspaceId":"w","status":"ready"}`, "workspace.ready"},
+		{events.EventTypeJobCompleted, `{"jobId":"j","status":"failed"}`, "job.failed"},
+		{events.EventTypeCredentialExpiring, `{"provider":"github"}`, "credential.expiring"},
+		{events.EventTypeSessionUpdated, `not json`, "session_updated"},
+	}
+	for _, tt := range tests {
+		got := eventName(&events.Event{Type: tt.typ, Data: json.RawMessage(tt.data)})
+		if got != tt.want {
+			t.Errorf("eventName(%s %s) = %q, want %q", tt.typ, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestServerCollector(t *testing.T) {
+	db, err := database.New(&config.Config{
+		DatabaseDSN:    fmt.Sprintf("sqlite3://%s/test.db", t.TempDir()),
+		DatabaseDriver: "sqlite",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	ctx := context.Background()
+	s := store.New(db.DB)
+
+	project := &model.Project{Name: "Test Project", Slug: "test-project"}
+	if err := s.CreateProject(ctx, project); err != nil {
+		t.Fatal(err)
+	}
+	workspace := &model.Workspace{ProjectID: project.ID, Path: "/test", SourceType: "local"}
+	if err := s.CreateWorkspace(ctx, workspace); err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range []string{model.SessionStatusReady, model.SessionStatusReady, model.SessionStatusError} {
+		if err := s.CreateSession(ctx, &model.Session{ProjectID: project.ID, WorkspaceID: workspace.ID, Status: status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	provider := mock.NewProvider()
+	provider.HealthyFunc = func(context.Context) error { return sandbox.ErrNotReady }
+	manager := sandbox.NewManager()
+	manager.RegisterProvider("mock", provider)
+
+	got := make(map[string]float64)
+	for _, m := range ServerCollector(s, manager)(ctx) {
+		got[m.Name+" "+fmt.Sprint(m.Attributes)] = m.Value
+	}
+	want := map[string]float64{
+		"discobot.sessions map[status:ready]":          2,
+		"discobot.sessions map[status:error]":          1,
+		"discobot.provider.healthy map[provider:mock]": 0,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v (got %v)", k, got[k], v, got)
+		}
+	}
+}
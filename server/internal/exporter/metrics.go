@@ -0,0 +1,52 @@
+package exporter
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// ServerCollector returns a Collector for the server's key metrics:
+//
+//   - discobot.sessions: sessions by status
+//   - discobot.jobs: background jobs by status
+//   - discobot.provider.healthy: 1 if a sandbox provider can create
+//     sandboxes, 0 if not (see sandbox.Provider.Healthy)
+//
+// The manager may be nil if no sandbox provider is configured.
+func ServerCollector(s *store.Store, manager *sandbox.Manager) Collector {
+	return func(ctx context.Context) []Metric {
+		now := time.Now()
+		var metrics []Metric
+
+		if sessions, err := s.CountSessionsByStatus(ctx); err == nil {
+			for status, count := range sessions {
+				metrics = append(metrics, Metric{Name: "discobot.sessions", Value: float64(count), Attributes: map[string]string{"status": status}, Timestamp: now})
+			}
+		} else {
+			log.Printf("Exporter failed to count sessions: %v", err)
+		}
+
+		if jobs, err := s.CountJobsByStatus(ctx); err == nil {
+			for status, count := range jobs {
+				metrics = append(metrics, Metric{Name: "discobot.jobs", Value: float64(count), Attributes: map[string]string{"status": status}, Timestamp: now})
+			}
+		} else {
+			log.Printf("Exporter failed to count jobs: %v", err)
+		}
+
+		if manager != nil {
+			for name, err := range manager.Health(ctx) {
+				healthy := 0.0
+				if err == nil {
+					healthy = 1
+				}
+				metrics = append(metrics, Metric{Name: "discobot.provider.healthy", Value: healthy, Attributes: map[string]string{"provider": name}, Timestamp: now})
+			}
+		}
+		return metrics
+	}
+}
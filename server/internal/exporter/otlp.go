@@ -0,0 +1,164 @@
+package exporter
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// OTLP/HTTP JSON encoding (https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding)
+// of the subset of logs and metrics the exporter sends. Events become log
+// records and metrics become gauges, from a "discobot" service.
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	EventName      string         `json:"eventName"`
+	Body           otlpAnyValue   `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpLogsRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpDataPoint struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	AsDouble     float64        `json:"asDouble"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpMetric struct {
+	Name  string    `json:"name"`
+	Gauge otlpGauge `json:"gauge"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+// OTLP severity numbers.
+const (
+	otlpSeverityInfo  = 9
+	otlpSeverityError = 17
+)
+
+var (
+	otlpServiceResource = otlpResource{Attributes: []otlpKeyValue{attr("service.name", "discobot")}}
+	otlpDiscobotScope   = otlpScope{Name: "discobot"}
+)
+
+func attr(key, value string) otlpKeyValue {
+	return otlpKeyValue{Key: key, Value: otlpAnyValue{StringValue: value}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// otlpLogs encodes events as an OTLP logs export request. Failures
+// ("session.failed", "job.failed", ...) are logged at ERROR severity.
+func otlpLogs(evs []Event) []byte {
+	records := make([]otlpLogRecord, len(evs))
+	for i, ev := range evs {
+		severity, severityText := otlpSeverityInfo, "INFO"
+		if slices.Contains([]string{"session.failed", "commit.failed", "job.failed", "workspace.error"}, ev.Name) {
+			severity, severityText = otlpSeverityError, "ERROR"
+		}
+		records[i] = otlpLogRecord{
+			TimeUnixNano:   unixNano(ev.Timestamp),
+			SeverityNumber: severity,
+			SeverityText:   severityText,
+			EventName:      ev.Name,
+			Body:           otlpAnyValue{StringValue: ev.Name},
+			Attributes: []otlpKeyValue{
+				attr("event.id", ev.ID),
+				attr("discobot.event.type", ev.Type),
+				attr("discobot.project_id", ev.ProjectID),
+				attr("discobot.event.data", string(ev.Data)),
+			},
+		}
+	}
+
+	req := otlpLogsRequest{ResourceLogs: []otlpResourceLogs{{
+		Resource:  otlpServiceResource,
+		ScopeLogs: []otlpScopeLogs{{Scope: otlpDiscobotScope, LogRecords: records}},
+	}}}
+	body, _ := json.Marshal(req) // Plain structs of strings and numbers always marshal
+	return body
+}
+
+// otlpMetrics encodes metrics as an OTLP metrics export request, with one
+// gauge per metric name holding a data point per sample.
+func otlpMetrics(metrics []Metric) []byte {
+	var names []string
+	gauges := make(map[string]*otlpMetric)
+	for _, m := range metrics {
+		g, ok := gauges[m.Name]
+		if !ok {
+			g = &otlpMetric{Name: m.Name}
+			gauges[m.Name] = g
+			names = append(names, m.Name)
+		}
+		point := otlpDataPoint{TimeUnixNano: unixNano(m.Timestamp), AsDouble: m.Value}
+		for _, k := range slices.Sorted(maps.Keys(m.Attributes)) {
+			point.Attributes = append(point.Attributes, attr(k, m.Attributes[k]))
+		}
+		g.Gauge.DataPoints = append(g.Gauge.DataPoints, point)
+	}
+	otlp := make([]otlpMetric, len(names))
+	for i, name := range names {
+		otlp[i] = *gauges[name]
+	}
+
+	req := otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     otlpServiceResource,
+		ScopeMetrics: []otlpScopeMetrics{{Scope: otlpDiscobotScope, Metrics: otlp}},
+	}}}
+	body, _ := json.Marshal(req) // Plain structs of strings and numbers always marshal
+	return body
+}
@@ -0,0 +1,121 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// Conflict reasons reported in ConflictError.Reason.
+const (
+	// ConflictDirtyWorkTree means uncommitted changes would be overwritten.
+	ConflictDirtyWorkTree = "dirty_work_tree"
+	// ConflictUntrackedFiles means untracked files would be overwritten.
+	ConflictUntrackedFiles = "untracked_files"
+	// ConflictUnmerged means the index has unresolved merge conflicts.
+	ConflictUnmerged = "unmerged"
+	// ConflictDiverged means the branch and its upstream both have new commits.
+	ConflictDiverged = "diverged"
+)
+
+// Conflict resolutions suggested in ConflictError.Options.
+const (
+	ResolveStash  = "stash"  // stash local changes and retry
+	ResolveForce  = "force"  // discard local changes (or reset to upstream)
+	ResolveRebase = "rebase" // rebase local commits onto upstream
+	ResolveMerge  = "merge"  // merge upstream into the local branch
+	ResolveAbort  = "abort"  // leave the workspace as it is
+)
+
+// ConflictError is returned by Checkout when the operation is blocked,
+// instead of a raw git error, and by Fetch alongside success when the branch
+// is left diverged, so callers can present the conflicting files and the
+// ways out.
+type ConflictError struct {
+	Op      string   `json:"op"`      // "checkout" or "fetch"
+	Reason  string   `json:"reason"`  // one of the Conflict* constants
+	Files   []string `json:"files"`   // conflicting paths, relative to the repo root
+	Options []string `json:"options"` // suggested Resolve* actions
+	Ahead   int      `json:"ahead,omitempty"`
+	Behind  int      `json:"behind,omitempty"`
+}
+
+func (e *ConflictError) Error() string {
+	switch e.Reason {
+	case ConflictDirtyWorkTree:
+		return fmt.Sprintf("%s blocked: local changes to %d file(s) would be overwritten", e.Op, len(e.Files))
+	case ConflictUntrackedFiles:
+		return fmt.Sprintf("%s blocked: %d untracked file(s) would be overwritten", e.Op, len(e.Files))
+	case ConflictUnmerged:
+		return fmt.Sprintf("%s blocked: %d file(s) have unresolved merge conflicts", e.Op, len(e.Files))
+	case ConflictDiverged:
+		return fmt.Sprintf("branch has diverged from upstream (%d ahead, %d behind)", e.Ahead, e.Behind)
+	}
+	return e.Op + " conflict: " + e.Reason
+}
+
+// Is lets errors.Is match the failure a conflict stands in for, so existing
+// checks for ErrCheckoutFailed and ErrDirtyWorkTree still work.
+func (e *ConflictError) Is(target error) bool {
+	switch target {
+	case ErrCheckoutFailed:
+		return e.Op == "checkout"
+	case ErrDirtyWorkTree:
+		return e.Reason == ConflictDirtyWorkTree
+	}
+	return false
+}
+
+// parseCheckoutConflict recognizes the messages git checkout prints when it
+// refuses to switch refs and returns the matching ConflictError, or nil if
+// the failure is not a conflict (e.g. an unknown ref).
+func parseCheckoutConflict(output string) *ConflictError {
+	var conflict *ConflictError
+	inList := false
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Files are listed one per line, tab-indented, after the header
+		if inList && strings.HasPrefix(line, "\t") {
+			conflict.Files = append(conflict.Files, strings.TrimSpace(line))
+			continue
+		}
+		inList = false
+
+		switch {
+		case strings.Contains(line, "Your local changes to the following files would be overwritten"):
+			conflict = newConflict(conflict, "checkout", ConflictDirtyWorkTree)
+			inList = true
+		case strings.Contains(line, "untracked working tree files would be overwritten"):
+			conflict = newConflict(conflict, "checkout", ConflictUntrackedFiles)
+			inList = true
+		case strings.HasSuffix(line, ": needs merge"):
+			// "error: you need to resolve your current index first" is
+			// followed by "<path>: needs merge" for each unmerged path
+			conflict = newConflict(conflict, "checkout", ConflictUnmerged)
+			conflict.Files = append(conflict.Files, strings.TrimSuffix(line, ": needs merge"))
+		}
+	}
+
+	if conflict == nil {
+		return nil
+	}
+	switch conflict.Reason {
+	case ConflictUnmerged:
+		conflict.Options = []string{ResolveForce, ResolveAbort}
+	default:
+		conflict.Options = []string{ResolveStash, ResolveForce, ResolveAbort}
+	}
+	return conflict
+}
+
+// newConflict returns existing if set, so the first reason git reports wins
+// and files from later sections are collected under it.
+func newConflict(existing *ConflictError, op, reason string) *ConflictError {
+	if existing != nil {
+		return existing
+	}
+	return &ConflictError{Op: op, Reason: reason, Files: []string{}}
+}
@@ -18,6 +18,9 @@ var (
 	ErrFetchFailed    = errors.New("fetch failed")
 	ErrCheckoutFailed = errors.New("checkout failed")
 	ErrDirtyWorkTree  = errors.New("working tree has uncommitted changes")
+	// ErrSubmoduleCommitMissing means patches point a submodule at a commit
+	// that neither the workspace's submodule nor its remote has.
+	ErrSubmoduleCommitMissing = errors.New("submodule commit not available")
 )
 
 // WorkspaceSource provides workspace information to the git provider.
@@ -44,8 +47,10 @@ type Provider interface {
 	// Returns the absolute path to the working directory and the current HEAD commit SHA.
 	EnsureWorkspace(ctx context.Context, projectID, workspaceID, source, ref string) (workDir string, commit string, err error)
 
-	// Fetch fetches updates from remote to the workspace.
-	Fetch(ctx context.Context, workspaceID string) error
+	// Fetch fetches updates from remote to the workspace. If the current
+	// branch has diverged from its upstream afterwards, the fetch still
+	// succeeds and the divergence is returned as a ConflictError.
+	Fetch(ctx context.Context, workspaceID string) (*ConflictError, error)
 
 	// Checkout checks out a specific ref (branch, tag, or commit SHA).
 	Checkout(ctx context.Context, workspaceID, ref string) error
@@ -87,7 +92,7 @@ type Provider interface {
 	// ApplyPatches applies mbox-format patches (from git format-patch) to the workspace.
 	// Returns the final commit SHA after all patches are applied.
 	// If application fails, the working tree is reset to the original state.
-	ApplyPatches(ctx context.Context, workspaceID string, patches []byte) (finalCommit string, err error)
+	ApplyPatches(ctx context.Context, workspaceID string, patches []byte, opts ApplyOptions) (finalCommit string, err error)
 
 	// GetUserConfig retrieves the global git user name and email configuration.
 	// Returns empty strings if not configured.
@@ -173,6 +178,18 @@ type Commit struct {
 	Parents     []string  `json:"parents"`
 }
 
+// ApplyOptions configures patch application.
+type ApplyOptions struct {
+	// Don't run the workspace's git hooks (applypatch-msg, pre-applypatch,
+	// post-applypatch, and any others git am triggers)
+	SkipHooks bool
+
+	// Committer identity for the applied commits. Authors are kept from the
+	// patches; when empty, the workspace's git config is used.
+	CommitterName  string
+	CommitterEmail string
+}
+
 // LogOptions configures commit log retrieval.
 type LogOptions struct {
 	// Maximum number of commits to return (default: 50)
@@ -5,25 +5,49 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
+// WorkspaceLayout controls where cloned workspaces are stored under the base directory.
+type WorkspaceLayout string
+
+const (
+	// WorkspaceLayoutProject isolates each project in its own subdirectory:
+	// {baseDir}/{projectID}/workspaces/{workspaceID}. This is the default.
+	WorkspaceLayoutProject WorkspaceLayout = "project"
+	// WorkspaceLayoutFlat stores all workspaces side by side: {baseDir}/{workspaceID}.
+	WorkspaceLayoutFlat WorkspaceLayout = "flat"
+)
+
+// workspaceLayouts lists all supported layouts, used to find clones created
+// under a previously configured layout.
+var workspaceLayouts = []WorkspaceLayout{WorkspaceLayoutProject, WorkspaceLayoutFlat}
+
 // LocalProvider implements Provider using the local git CLI.
-// Workspaces are cloned directly to {baseDir}/{projectID}/workspaces/{workspaceID}.
+// Workspaces are cloned to a directory under baseDir determined by the layout
+// (by default {baseDir}/{projectID}/workspaces/{workspaceID}).
 type LocalProvider struct {
 	// baseDir is the root directory for all git operations
 	baseDir string
 
+	// layout determines where new workspaces are cloned
+	layout WorkspaceLayout
+
 	// workspaceSource provides workspace info for lookup operations
 	workspaceSource WorkspaceSource
 
+	// mirrors caches bare mirrors of remote repositories (nil if disabled)
+	mirrors *mirrorCache
+
 	// Per-project mutexes for EnsureWorkspace operations
 	projectMu    sync.Mutex
 	projectLocks map[string]*sync.Mutex
@@ -44,6 +68,14 @@ func WithWorkspaceSource(src WorkspaceSource) LocalProviderOption {
 	}
 }
 
+// WithWorkspaceLayout sets the directory layout for cloned workspaces.
+// Existing clones under a different layout are still found and used in place.
+func WithWorkspaceLayout(layout WorkspaceLayout) LocalProviderOption {
+	return func(p *LocalProvider) {
+		p.layout = layout
+	}
+}
+
 // workspaceInfo tracks information about a workspace's git setup
 type workspaceInfo struct {
 	projectID string // Project this workspace belongs to
@@ -54,15 +86,11 @@ type workspaceInfo struct {
 
 // NewLocalProvider creates a new local git provider.
 // baseDir is the root directory where workspaces will be stored.
-// Structure: {baseDir}/{projectID}/workspaces/{workspaceID}/
+// Default structure: {baseDir}/{projectID}/workspaces/{workspaceID}/
 func NewLocalProvider(baseDir string, opts ...LocalProviderOption) (*LocalProvider, error) {
-	// Ensure base directory exists
-	if err := os.MkdirAll(baseDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create base directory: %w", err)
-	}
-
 	p := &LocalProvider{
 		baseDir:        baseDir,
+		layout:         WorkspaceLayoutProject,
 		projectLocks:   make(map[string]*sync.Mutex),
 		workspaceIndex: make(map[string]*workspaceInfo),
 	}
@@ -71,9 +99,40 @@ func NewLocalProvider(baseDir string, opts ...LocalProviderOption) (*LocalProvid
 		opt(p)
 	}
 
+	if !slices.Contains(workspaceLayouts, p.layout) {
+		return nil, fmt.Errorf("unknown workspace layout %q", p.layout)
+	}
+
+	// Ensure base directory exists
+	if err := os.MkdirAll(baseDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create base directory: %w", err)
+	}
+
 	return p, nil
 }
 
+// workspaceDir returns the clone directory for a workspace under the given layout.
+func (p *LocalProvider) workspaceDir(layout WorkspaceLayout, projectID, workspaceID string) string {
+	if layout == WorkspaceLayoutFlat {
+		return filepath.Join(p.baseDir, workspaceID)
+	}
+	return filepath.Join(p.baseDir, projectID, "workspaces", workspaceID)
+}
+
+// existingWorkspaceDir returns the directory of an existing clone of the
+// workspace, checking the configured layout first and then the others so
+// clones made before a layout change remain usable. Returns "" if none exists.
+func (p *LocalProvider) existingWorkspaceDir(projectID, workspaceID string) string {
+	layouts := append([]WorkspaceLayout{p.layout}, workspaceLayouts...)
+	for _, layout := range layouts {
+		dir := p.workspaceDir(layout, projectID, workspaceID)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+	}
+	return ""
+}
+
 // getProjectLock returns a mutex for the given project, creating one if needed.
 func (p *LocalProvider) getProjectLock(projectID string) *sync.Mutex {
 	p.projectMu.Lock()
@@ -122,16 +181,8 @@ func (p *LocalProvider) EnsureWorkspace(ctx context.Context, projectID, workspac
 	}
 	p.mu.RUnlock()
 
-	// Create project-scoped workspaces directory
-	projectWorkspacesDir := filepath.Join(p.baseDir, projectID, "workspaces")
-	if err := os.MkdirAll(projectWorkspacesDir, 0755); err != nil {
-		return "", "", fmt.Errorf("failed to create project workspaces directory: %w", err)
-	}
-
-	workDir := filepath.Join(projectWorkspacesDir, workspaceID)
-
-	// Check if working directory already exists on disk
-	if _, err := os.Stat(filepath.Join(workDir, ".git")); err == nil {
+	// Check if working directory already exists on disk (under any layout)
+	if workDir := p.existingWorkspaceDir(projectID, workspaceID); workDir != "" {
 		info := &workspaceInfo{
 			projectID: projectID,
 			workDir:   workDir,
@@ -145,18 +196,30 @@ func (p *LocalProvider) EnsureWorkspace(ctx context.Context, projectID, workspac
 		return workDir, strings.TrimSpace(commit), nil
 	}
 
+	// Create the parent directory for the configured layout
+	workDir := p.workspaceDir(p.layout, projectID, workspaceID)
+	if err := os.MkdirAll(filepath.Dir(workDir), 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create workspaces directory: %w", err)
+	}
+
 	var info *workspaceInfo
 
 	if IsGitURL(source) {
-		// Remote repository - clone directly
-		args := []string{"clone"}
-		if ref != "" {
-			args = append(args, "-b", ref)
-		}
-		args = append(args, source, workDir)
+		// Remote repository - clone directly, or via the mirror cache
+		if p.mirrors != nil {
+			if err := p.cloneWithMirror(ctx, source, ref, workDir); err != nil {
+				return "", "", fmt.Errorf("%w: %v", ErrCloneFailed, err)
+			}
+		} else {
+			args := []string{"clone"}
+			if ref != "" {
+				args = append(args, "-b", ref)
+			}
+			args = append(args, source, workDir)
 
-		if err := p.runGit(ctx, "", args...); err != nil {
-			return "", "", fmt.Errorf("%w: %v", ErrCloneFailed, err)
+			if err := p.runGit(ctx, "", args...); err != nil {
+				return "", "", fmt.Errorf("%w: %v", ErrCloneFailed, err)
+			}
 		}
 
 		info = &workspaceInfo{
@@ -273,21 +336,78 @@ func (p *LocalProvider) registerLocalWorkspace(ctx context.Context, workspaceID,
 	return absPath, strings.TrimSpace(commit), nil
 }
 
-// Fetch fetches updates from remote.
-func (p *LocalProvider) Fetch(ctx context.Context, workspaceID string) error {
+// Fetch fetches updates from remote. If the current branch has diverged
+// from its upstream afterwards, it returns the divergence as a
+// *ConflictError alongside a nil error.
+func (p *LocalProvider) Fetch(ctx context.Context, workspaceID string) (*ConflictError, error) {
 	workDir := p.GetWorkDir(ctx, workspaceID)
 	if workDir == "" {
-		return fmt.Errorf("%w: workspace %s", ErrNotFound, workspaceID)
+		return nil, fmt.Errorf("%w: workspace %s", ErrNotFound, workspaceID)
 	}
 
 	if err := p.runGit(ctx, workDir, "fetch", "--all", "--prune"); err != nil {
-		return fmt.Errorf("%w: %v", ErrFetchFailed, err)
+		return nil, fmt.Errorf("%w: %v", ErrFetchFailed, err)
 	}
 
-	return nil
+	return p.divergence(ctx, workDir), nil
 }
 
-// Checkout checks out a specific ref.
+// divergence returns a ConflictError if the current branch and its upstream
+// both have commits the other lacks, listing the files changed on both sides.
+// Returns nil if there is no upstream or the branch can fast-forward.
+func (p *LocalProvider) divergence(ctx context.Context, workDir string) *ConflictError {
+	revList, err := p.runGitOutput(ctx, workDir, "rev-list", "--left-right", "--count", "HEAD...@{upstream}")
+	if err != nil {
+		return nil
+	}
+	parts := strings.Fields(strings.TrimSpace(revList))
+	if len(parts) != 2 {
+		return nil
+	}
+	ahead, _ := strconv.Atoi(parts[0])
+	behind, _ := strconv.Atoi(parts[1])
+	if ahead == 0 || behind == 0 {
+		return nil
+	}
+
+	conflict := &ConflictError{
+		Op:      "fetch",
+		Reason:  ConflictDiverged,
+		Files:   []string{},
+		Options: []string{ResolveRebase, ResolveMerge, ResolveForce, ResolveAbort},
+		Ahead:   ahead,
+		Behind:  behind,
+	}
+
+	// Files touched on both sides since the merge base are the likely conflicts
+	base, err := p.runGitOutput(ctx, workDir, "merge-base", "HEAD", "@{upstream}")
+	if err != nil {
+		return conflict
+	}
+	base = strings.TrimSpace(base)
+	ours, err := p.runGitOutput(ctx, workDir, "diff", "--name-only", base, "HEAD")
+	if err != nil {
+		return conflict
+	}
+	theirs, err := p.runGitOutput(ctx, workDir, "diff", "--name-only", base, "@{upstream}")
+	if err != nil {
+		return conflict
+	}
+	changed := make(map[string]bool)
+	for _, path := range strings.Split(strings.TrimSpace(ours), "\n") {
+		changed[path] = path != ""
+	}
+	for _, path := range strings.Split(strings.TrimSpace(theirs), "\n") {
+		if changed[path] {
+			conflict.Files = append(conflict.Files, path)
+		}
+	}
+
+	return conflict
+}
+
+// Checkout checks out a specific ref. If git refuses because local changes
+// or unmerged files are in the way, it returns a *ConflictError.
 func (p *LocalProvider) Checkout(ctx context.Context, workspaceID, ref string) error {
 	workDir := p.GetWorkDir(ctx, workspaceID)
 	if workDir == "" {
@@ -295,6 +415,9 @@ func (p *LocalProvider) Checkout(ctx context.Context, workspaceID, ref string) e
 	}
 
 	if err := p.runGit(ctx, workDir, "checkout", ref); err != nil {
+		if conflict := parseCheckoutConflict(err.Error()); conflict != nil {
+			return conflict
+		}
 		return fmt.Errorf("%w: %v", ErrCheckoutFailed, err)
 	}
 
@@ -683,13 +806,39 @@ func (p *LocalProvider) RemoveWorkspace(_ context.Context, workspaceID string) e
 	}
 
 	delete(p.workspaceIndex, workspaceID)
-	return os.RemoveAll(info.workDir)
+	if err := os.RemoveAll(info.workDir); err != nil {
+		return err
+	}
+	p.pruneEmptyDirs(filepath.Dir(info.workDir))
+	return nil
 }
 
+// pruneEmptyDirs removes empty directories from dir up to (but not including)
+// baseDir, cleaning up per-project directories once their last workspace is
+// removed. Directories outside baseDir (e.g. in-place local workspaces) are
+// left untouched.
+func (p *LocalProvider) pruneEmptyDirs(dir string) {
+	base := filepath.Clean(p.baseDir)
+	for {
+		rel, err := filepath.Rel(base, dir)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return
+		}
+		// os.Remove fails on non-empty directories, which ends the walk
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// amHooks are the hooks git am runs for each patch.
+var amHooks = []string{"applypatch-msg", "pre-applypatch", "post-applypatch"}
+
 // ApplyPatches applies mbox-format patches (from git format-patch) to the workspace.
 // Returns the final commit SHA after all patches are applied.
 // If application fails, the operation is aborted without losing local changes.
-func (p *LocalProvider) ApplyPatches(ctx context.Context, workspaceID string, patches []byte) (string, error) {
+func (p *LocalProvider) ApplyPatches(ctx context.Context, workspaceID string, patches []byte, opts ApplyOptions) (string, error) {
 	workDir := p.GetWorkDir(ctx, workspaceID)
 	if workDir == "" {
 		return "", fmt.Errorf("%w: workspace %s", ErrNotFound, workspaceID)
@@ -701,13 +850,38 @@ func (p *LocalProvider) ApplyPatches(ctx context.Context, workspaceID string, pa
 		return "", fmt.Errorf("patches will not apply cleanly: %w", err)
 	}
 
+	// Make sure initialized submodules can check out the commits the patches
+	// point them at, rather than recording a pointer the workspace can't use
+	submodules, err := p.checkSubmoduleCommits(ctx, workDir, SummarizePatches(patches).Files)
+	if err != nil {
+		return "", err
+	}
+
 	// Apply patches using git am
 	// --keep-cr preserves carriage returns (important for cross-platform)
 	// --no-gpg-sign disables GPG signing (GPG may not be available in sandboxed environments)
 	// We pipe the patches to stdin
-	if err := p.runGitWithStdin(ctx, workDir, patches, "am", "--keep-cr", "--no-gpg-sign"); err != nil {
+	args := []string{"am", "--keep-cr", "--no-gpg-sign"}
+	if opts.SkipHooks {
+		// Pointing hooksPath at an empty location disables every hook,
+		// including ones that can't run on this host (e.g. built for
+		// another architecture)
+		args = append([]string{"-c", "core.hooksPath=" + os.DevNull}, args...)
+	}
+	if opts.CommitterName != "" {
+		args = append([]string{"-c", "user.name=" + opts.CommitterName}, args...)
+	}
+	if opts.CommitterEmail != "" {
+		args = append([]string{"-c", "user.email=" + opts.CommitterEmail}, args...)
+	}
+	if err := p.runGitWithStdin(ctx, workDir, patches, args...); err != nil {
 		// Application failed - abort but do NOT reset to preserve local changes
 		_ = p.runGit(ctx, workDir, "am", "--abort")
+		if !opts.SkipHooks {
+			if hooks := p.enabledHooks(ctx, workDir, amHooks); len(hooks) > 0 {
+				return "", fmt.Errorf("failed to apply patches (workspace git hooks ran: %s): %w", strings.Join(hooks, ", "), err)
+			}
+		}
 		return "", fmt.Errorf("failed to apply patches: %w", err)
 	}
 
@@ -717,11 +891,71 @@ func (p *LocalProvider) ApplyPatches(ctx context.Context, workspaceID string, pa
 		return "", fmt.Errorf("failed to get final commit: %w", err)
 	}
 
+	// git am only moves the gitlinks; check out the new submodule commits too.
+	// The patches are already applied, so a failure here (e.g. local changes
+	// in a submodule) leaves the submodule for the user to update.
+	if len(submodules) > 0 {
+		if err := p.runGit(ctx, workDir, append([]string{"submodule", "update", "--"}, submodules...)...); err != nil {
+			log.Printf("git: applied patches to workspace %s but failed to update submodules: %v", workspaceID, err)
+		}
+	}
+
 	return strings.TrimSpace(finalCommit), nil
 }
 
+// checkSubmoduleCommits makes sure each initialized submodule the patches
+// update has the commit they point it at, fetching from the submodule's
+// remote if needed. It returns the paths of those submodules. Submodules
+// that aren't initialized in the workspace can't be checked and are skipped.
+func (p *LocalProvider) checkSubmoduleCommits(ctx context.Context, workDir string, files []PatchFileStat) ([]string, error) {
+	var paths []string
+	for _, f := range files {
+		if !f.Submodule || f.SubmoduleCommit == "" {
+			continue
+		}
+		subDir := filepath.Join(workDir, f.Path)
+		if _, err := os.Stat(filepath.Join(subDir, ".git")); err != nil {
+			continue
+		}
+
+		object := f.SubmoduleCommit + "^{commit}"
+		if p.runGit(ctx, subDir, "cat-file", "-e", object) != nil {
+			// Sessions usually point submodules at commits pushed upstream
+			_ = p.runGit(ctx, subDir, "fetch", "--quiet", "origin")
+			if p.runGit(ctx, subDir, "cat-file", "-e", object) != nil {
+				return nil, fmt.Errorf("%w: %s points at %s, which is not in the submodule or its remote; push it from the session first",
+					ErrSubmoduleCommitMissing, f.Path, f.SubmoduleCommit)
+			}
+		}
+		paths = append(paths, f.Path)
+	}
+	return paths, nil
+}
+
 // --- Internal helpers ---
 
+// enabledHooks returns which of names are executable hooks in the
+// repository, honoring core.hooksPath.
+func (p *LocalProvider) enabledHooks(ctx context.Context, workDir string, names []string) []string {
+	hooksDir, err := p.runGitOutput(ctx, workDir, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return nil
+	}
+	hooksDir = strings.TrimSpace(hooksDir)
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(workDir, hooksDir)
+	}
+
+	var enabled []string
+	for _, name := range names {
+		info, err := os.Stat(filepath.Join(hooksDir, name))
+		if err == nil && info.Mode().IsRegular() && info.Mode()&0o111 != 0 {
+			enabled = append(enabled, name)
+		}
+	}
+	return enabled
+}
+
 // cleanGitEnv returns the current environment with GIT_* variables removed that
 // are set by git during hook execution (e.g., GIT_DIR, GIT_INDEX_FILE).
 // Without this, subprocess git commands may operate on the wrong repository
@@ -742,7 +976,8 @@ func (p *LocalProvider) runGit(ctx context.Context, workDir string, args ...stri
 	if workDir != "" {
 		cmd.Dir = workDir
 	}
-	cmd.Env = cleanGitEnv()
+	// Callers such as parseCheckoutConflict match git's English messages
+	cmd.Env = append(cleanGitEnv(), "LC_ALL=C")
 
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
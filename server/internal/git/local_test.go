@@ -2,6 +2,7 @@ package git
 
 import (
 	"context"
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -215,21 +216,61 @@ func TestFetch(t *testing.T) {
 
 		provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
 
-		err := provider.Fetch(ctx, "ws1")
+		conflict, err := provider.Fetch(ctx, "ws1")
 		if err != nil {
 			t.Fatalf("Fetch failed: %v", err)
 		}
+		if conflict != nil {
+			t.Errorf("Expected no divergence, got %v", conflict)
+		}
 	})
 
 	t.Run("fails for unknown workspace", func(t *testing.T) {
 		baseDir := t.TempDir()
 		provider, _ := NewLocalProvider(baseDir)
 
-		err := provider.Fetch(ctx, "nonexistent")
+		_, err := provider.Fetch(ctx, "nonexistent")
 		if err == nil {
 			t.Error("Expected error for unknown workspace")
 		}
 	})
+
+	t.Run("reports diverged branch", func(t *testing.T) {
+		baseDir := t.TempDir()
+		provider, _ := NewLocalProvider(baseDir)
+		sourceRepo := createTestRepo(t)
+
+		workDir, _, err := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+		if err != nil {
+			t.Fatalf("EnsureWorkspace failed: %v", err)
+		}
+
+		// Both sides change main.go; only the remote changes README.md
+		os.WriteFile(filepath.Join(sourceRepo, "main.go"), []byte("package main\n\n// remote\n"), 0644)
+		os.WriteFile(filepath.Join(sourceRepo, "README.md"), []byte("# Remote\n"), 0644)
+		runGit(t, sourceRepo, "commit", "-am", "Remote change")
+		runGit(t, workDir, "config", "user.email", "test@example.com")
+		runGit(t, workDir, "config", "user.name", "Test User")
+		os.WriteFile(filepath.Join(workDir, "main.go"), []byte("package main\n\n// local\n"), 0644)
+		runGit(t, workDir, "commit", "-am", "Local change")
+
+		conflict, err := provider.Fetch(ctx, "ws1")
+		if err != nil {
+			t.Fatalf("Fetch failed: %v", err)
+		}
+		if conflict == nil {
+			t.Fatal("Expected divergence to be reported")
+		}
+		if conflict.Op != "fetch" {
+			t.Errorf("Expected op fetch, got %s", conflict.Op)
+		}
+		if conflict.Reason != ConflictDiverged || conflict.Ahead != 1 || conflict.Behind != 1 {
+			t.Errorf("Expected diverged 1/1, got %s %d/%d", conflict.Reason, conflict.Ahead, conflict.Behind)
+		}
+		if len(conflict.Files) != 1 || conflict.Files[0] != "main.go" {
+			t.Errorf("Expected conflicting files [main.go], got %v", conflict.Files)
+		}
+	})
 }
 
 func TestCheckout(t *testing.T) {
@@ -265,7 +306,98 @@ func TestCheckout(t *testing.T) {
 		if err == nil {
 			t.Error("Expected error for invalid ref")
 		}
+		var conflict *ConflictError
+		if errors.As(err, &conflict) {
+			t.Errorf("Expected plain checkout failure for invalid ref, got conflict %v", conflict)
+		}
 	})
+
+	t.Run("reports local changes in the way", func(t *testing.T) {
+		baseDir := t.TempDir()
+		provider, _ := NewLocalProvider(baseDir)
+		sourceRepo := createTestRepo(t)
+		runGit(t, sourceRepo, "checkout", "-b", "feature")
+		os.WriteFile(filepath.Join(sourceRepo, "main.go"), []byte("package main\n\n// feature\n"), 0644)
+		runGit(t, sourceRepo, "commit", "-am", "Feature change")
+		runGit(t, sourceRepo, "checkout", "-")
+
+		workDir, _, err := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+		if err != nil {
+			t.Fatalf("EnsureWorkspace failed: %v", err)
+		}
+		os.WriteFile(filepath.Join(workDir, "main.go"), []byte("package main\n\n// dirty\n"), 0644)
+
+		err = provider.Checkout(ctx, "ws1", "feature")
+		var conflict *ConflictError
+		if !errors.As(err, &conflict) {
+			t.Fatalf("Expected ConflictError, got %v", err)
+		}
+		if !errors.Is(err, ErrCheckoutFailed) || !errors.Is(err, ErrDirtyWorkTree) {
+			t.Error("Expected conflict to match ErrCheckoutFailed and ErrDirtyWorkTree")
+		}
+		if conflict.Reason != ConflictDirtyWorkTree {
+			t.Errorf("Expected reason %s, got %s", ConflictDirtyWorkTree, conflict.Reason)
+		}
+		if len(conflict.Files) != 1 || conflict.Files[0] != "main.go" {
+			t.Errorf("Expected conflicting files [main.go], got %v", conflict.Files)
+		}
+	})
+}
+
+func TestParseCheckoutConflict(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		reason string
+		files  []string
+	}{
+		{
+			name:   "local changes",
+			output: "git checkout main: exit status 1: error: Your local changes to the following files would be overwritten by checkout:\n\ta.txt\n\tdir/b.txt\nPlease commit your changes or stash them before you switch branches.\nAborting\n",
+			reason: ConflictDirtyWorkTree,
+			files:  []string{"a.txt", "dir/b.txt"},
+		},
+		{
+			name:   "untracked files",
+			output: "git checkout main: exit status 1: error: The following untracked working tree files would be overwritten by checkout:\n\tnew.txt\nPlease move or remove them before you switch branches.\nAborting\n",
+			reason: ConflictUntrackedFiles,
+			files:  []string{"new.txt"},
+		},
+		{
+			name:   "unmerged index",
+			output: "git checkout main: exit status 1: error: you need to resolve your current index first\na.txt: needs merge\nb.txt: needs merge\n",
+			reason: ConflictUnmerged,
+			files:  []string{"a.txt", "b.txt"},
+		},
+		{
+			name:   "unknown ref",
+			output: "git checkout nope: exit status 1: error: pathspec 'nope' did not match any file(s) known to git\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflict := parseCheckoutConflict(tt.output)
+			if tt.reason == "" {
+				if conflict != nil {
+					t.Fatalf("Expected no conflict, got %+v", conflict)
+				}
+				return
+			}
+			if conflict == nil {
+				t.Fatal("Expected conflict, got nil")
+			}
+			if conflict.Reason != tt.reason {
+				t.Errorf("Expected reason %s, got %s", tt.reason, conflict.Reason)
+			}
+			if strings.Join(conflict.Files, ",") != strings.Join(tt.files, ",") {
+				t.Errorf("Expected files %v, got %v", tt.files, conflict.Files)
+			}
+			if len(conflict.Options) == 0 {
+				t.Error("Expected suggested options")
+			}
+		})
+	}
 }
 
 func TestStatus(t *testing.T) {
@@ -794,6 +926,33 @@ func TestRemoveWorkspace(t *testing.T) {
 		}
 	})
 
+	t.Run("prunes empty project directory", func(t *testing.T) {
+		baseDir := t.TempDir()
+		provider, _ := NewLocalProvider(baseDir)
+		sourceRepo := createTestRepo(t)
+
+		_, _, _ = provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+		_, _, _ = provider.EnsureWorkspace(ctx, "project1", "ws2", sourceRepo, "")
+		projectDir := filepath.Join(baseDir, "project1")
+
+		if err := provider.RemoveWorkspace(ctx, "ws1"); err != nil {
+			t.Fatalf("RemoveWorkspace failed: %v", err)
+		}
+		if _, err := os.Stat(projectDir); err != nil {
+			t.Error("Expected project directory to remain while it has workspaces")
+		}
+
+		if err := provider.RemoveWorkspace(ctx, "ws2"); err != nil {
+			t.Fatalf("RemoveWorkspace failed: %v", err)
+		}
+		if _, err := os.Stat(projectDir); !os.IsNotExist(err) {
+			t.Error("Expected empty project directory to be removed")
+		}
+		if _, err := os.Stat(baseDir); err != nil {
+			t.Error("Expected base directory to remain")
+		}
+	})
+
 	t.Run("succeeds for unknown workspace", func(t *testing.T) {
 		baseDir := t.TempDir()
 		provider, _ := NewLocalProvider(baseDir)
@@ -805,6 +964,73 @@ func TestRemoveWorkspace(t *testing.T) {
 	})
 }
 
+func TestWorkspaceLayout(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("project layout isolates projects", func(t *testing.T) {
+		baseDir := t.TempDir()
+		provider, _ := NewLocalProvider(baseDir)
+		sourceRepo := createTestRepo(t)
+
+		workDir, _, err := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+		if err != nil {
+			t.Fatalf("EnsureWorkspace failed: %v", err)
+		}
+		expected := filepath.Join(baseDir, "project1", "workspaces", "ws1")
+		if workDir != expected {
+			t.Errorf("Expected workDir %s, got %s", expected, workDir)
+		}
+	})
+
+	t.Run("flat layout", func(t *testing.T) {
+		baseDir := t.TempDir()
+		provider, err := NewLocalProvider(baseDir, WithWorkspaceLayout(WorkspaceLayoutFlat))
+		if err != nil {
+			t.Fatalf("NewLocalProvider failed: %v", err)
+		}
+		sourceRepo := createTestRepo(t)
+
+		workDir, _, err := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+		if err != nil {
+			t.Fatalf("EnsureWorkspace failed: %v", err)
+		}
+		expected := filepath.Join(baseDir, "ws1")
+		if workDir != expected {
+			t.Errorf("Expected workDir %s, got %s", expected, workDir)
+		}
+	})
+
+	t.Run("existing clone under another layout is reused", func(t *testing.T) {
+		baseDir := t.TempDir()
+		sourceRepo := createTestRepo(t)
+
+		flat, _ := NewLocalProvider(baseDir, WithWorkspaceLayout(WorkspaceLayoutFlat))
+		flatDir, _, err := flat.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+		if err != nil {
+			t.Fatalf("EnsureWorkspace failed: %v", err)
+		}
+
+		// Switching to the project layout keeps using the existing clone
+		provider, _ := NewLocalProvider(baseDir, WithWorkspaceLayout(WorkspaceLayoutProject))
+		workDir, _, err := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+		if err != nil {
+			t.Fatalf("EnsureWorkspace failed: %v", err)
+		}
+		if workDir != flatDir {
+			t.Errorf("Expected existing clone %s to be reused, got %s", flatDir, workDir)
+		}
+		if _, err := os.Stat(filepath.Join(baseDir, "project1")); !os.IsNotExist(err) {
+			t.Error("Expected no project directory to be created for an existing clone")
+		}
+	})
+
+	t.Run("rejects unknown layout", func(t *testing.T) {
+		if _, err := NewLocalProvider(t.TempDir(), WithWorkspaceLayout("nested")); err == nil {
+			t.Error("Expected error for unknown layout")
+		}
+	})
+}
+
 func TestIsGitURL(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -871,7 +1097,7 @@ func TestApplyPatches(t *testing.T) {
 		patches := runGit(t, patchRepo, "format-patch", "--stdout", initialCommit+"..HEAD")
 
 		// Apply the patches
-		finalCommit, err := provider.ApplyPatches(ctx, "ws1", []byte(patches))
+		finalCommit, err := provider.ApplyPatches(ctx, "ws1", []byte(patches), ApplyOptions{})
 		if err != nil {
 			t.Fatalf("ApplyPatches failed: %v", err)
 		}
@@ -903,6 +1129,47 @@ func TestApplyPatches(t *testing.T) {
 		}
 	})
 
+	t.Run("uses committer identity from options", func(t *testing.T) {
+		baseDir := t.TempDir()
+		provider, _ := NewLocalProvider(baseDir)
+		sourceRepo := createTestRepo(t)
+
+		workDir, _, _ := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+		runGit(t, workDir, "config", "user.email", "committer@example.com")
+		runGit(t, workDir, "config", "user.name", "Test Committer")
+		initialCommit := strings.TrimSpace(runGit(t, workDir, "rev-parse", "HEAD"))
+
+		patchRepo := t.TempDir()
+		runGit(t, patchRepo, "init")
+		runGit(t, patchRepo, "config", "user.email", "patch@example.com")
+		runGit(t, patchRepo, "config", "user.name", "Patch Author")
+		runGit(t, patchRepo, "fetch", workDir, "HEAD")
+		runGit(t, patchRepo, "reset", "--hard", "FETCH_HEAD")
+		if err := os.WriteFile(filepath.Join(patchRepo, "patched.txt"), []byte("patched content\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		runGit(t, patchRepo, "add", "patched.txt")
+		runGit(t, patchRepo, "commit", "-m", "Add patched file")
+		patches := runGit(t, patchRepo, "format-patch", "--stdout", initialCommit+"..HEAD")
+
+		_, err := provider.ApplyPatches(ctx, "ws1", []byte(patches), ApplyOptions{
+			CommitterName:  "Session User",
+			CommitterEmail: "user@example.com",
+		})
+		if err != nil {
+			t.Fatalf("ApplyPatches failed: %v", err)
+		}
+
+		committer := strings.TrimSpace(runGit(t, workDir, "log", "-1", "--format=%cn <%ce>"))
+		if committer != "Session User <user@example.com>" {
+			t.Errorf("Expected committer 'Session User <user@example.com>', got %s", committer)
+		}
+		author := strings.TrimSpace(runGit(t, workDir, "log", "-1", "--format=%an"))
+		if author != "Patch Author" {
+			t.Errorf("Expected author 'Patch Author', got %s", author)
+		}
+	})
+
 	t.Run("applies multiple commit patches", func(t *testing.T) {
 		baseDir := t.TempDir()
 		provider, _ := NewLocalProvider(baseDir)
@@ -946,7 +1213,7 @@ func TestApplyPatches(t *testing.T) {
 
 		patches := runGit(t, patchRepo, "format-patch", "--stdout", initialCommit+"..HEAD")
 
-		finalCommit, err := provider.ApplyPatches(ctx, "ws1", []byte(patches))
+		finalCommit, err := provider.ApplyPatches(ctx, "ws1", []byte(patches), ApplyOptions{})
 		if err != nil {
 			t.Fatalf("ApplyPatches failed: %v", err)
 		}
@@ -971,7 +1238,7 @@ func TestApplyPatches(t *testing.T) {
 		baseDir := t.TempDir()
 		provider, _ := NewLocalProvider(baseDir)
 
-		_, err := provider.ApplyPatches(ctx, "nonexistent", []byte("patch content"))
+		_, err := provider.ApplyPatches(ctx, "nonexistent", []byte("patch content"), ApplyOptions{})
 		if err == nil {
 			t.Error("Expected error for unknown workspace")
 		}
@@ -986,7 +1253,7 @@ func TestApplyPatches(t *testing.T) {
 		initialCommit := strings.TrimSpace(runGit(t, workDir, "rev-parse", "HEAD"))
 
 		// Try to apply invalid patch
-		_, err := provider.ApplyPatches(ctx, "ws1", []byte("invalid patch content"))
+		_, err := provider.ApplyPatches(ctx, "ws1", []byte("invalid patch content"), ApplyOptions{})
 		if err == nil {
 			t.Error("Expected error for invalid patch")
 		}
@@ -1030,7 +1297,7 @@ func TestApplyPatches(t *testing.T) {
 		patches := runGit(t, patchRepo, "format-patch", "--stdout", initialCommit+"..HEAD")
 
 		// Try to apply the conflicting patch
-		_, err := provider.ApplyPatches(ctx, "ws1", []byte(patches))
+		_, err := provider.ApplyPatches(ctx, "ws1", []byte(patches), ApplyOptions{})
 		if err == nil {
 			t.Error("Expected error for conflicting patch")
 		}
@@ -1085,7 +1352,7 @@ func TestApplyPatches(t *testing.T) {
 
 		patches := runGit(t, patchRepo, "format-patch", "--stdout", initialCommit+"..HEAD")
 
-		_, err := provider.ApplyPatches(ctx, "ws1", []byte(patches))
+		_, err := provider.ApplyPatches(ctx, "ws1", []byte(patches), ApplyOptions{})
 		if err != nil {
 			t.Fatalf("ApplyPatches failed: %v", err)
 		}
@@ -1099,6 +1366,124 @@ func TestApplyPatches(t *testing.T) {
 			t.Errorf("Expected email 'special@example.com', got %s", commits[0].AuthorEmail)
 		}
 	})
+
+	t.Run("reports or skips failing workspace hooks", func(t *testing.T) {
+		baseDir := t.TempDir()
+		provider, _ := NewLocalProvider(baseDir)
+		sourceRepo := createTestRepo(t)
+
+		workDir, _, _ := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+		runGit(t, workDir, "config", "user.email", "committer@example.com")
+		runGit(t, workDir, "config", "user.name", "Test Committer")
+		initialCommit := strings.TrimSpace(runGit(t, workDir, "rev-parse", "HEAD"))
+
+		hook := filepath.Join(workDir, ".git", "hooks", "pre-applypatch")
+		if err := os.MkdirAll(filepath.Dir(hook), 0755); err != nil {
+			t.Fatalf("Failed to create hooks dir: %v", err)
+		}
+		if err := os.WriteFile(hook, []byte("#!/bin/sh\necho hook rejected patch >&2\nexit 1\n"), 0755); err != nil {
+			t.Fatalf("Failed to write hook: %v", err)
+		}
+
+		patchRepo := t.TempDir()
+		runGit(t, patchRepo, "init")
+		runGit(t, patchRepo, "config", "user.email", "patch@example.com")
+		runGit(t, patchRepo, "config", "user.name", "Patch Author")
+		runGit(t, patchRepo, "fetch", workDir, "HEAD")
+		runGit(t, patchRepo, "reset", "--hard", "FETCH_HEAD")
+		if err := os.WriteFile(filepath.Join(patchRepo, "hooked.txt"), []byte("content\n"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		runGit(t, patchRepo, "add", "hooked.txt")
+		runGit(t, patchRepo, "commit", "-m", "Add hooked file")
+		patches := runGit(t, patchRepo, "format-patch", "--stdout", initialCommit+"..HEAD")
+
+		_, err := provider.ApplyPatches(ctx, "ws1", []byte(patches), ApplyOptions{})
+		if err == nil {
+			t.Fatal("Expected the failing hook to abort applying patches")
+		}
+		if !strings.Contains(err.Error(), "pre-applypatch") || !strings.Contains(err.Error(), "hook rejected patch") {
+			t.Errorf("Expected error to name the hook and include its output, got: %v", err)
+		}
+
+		finalCommit, err := provider.ApplyPatches(ctx, "ws1", []byte(patches), ApplyOptions{SkipHooks: true})
+		if err != nil {
+			t.Fatalf("ApplyPatches with SkipHooks failed: %v", err)
+		}
+		if finalCommit == initialCommit {
+			t.Error("Expected a new commit with hooks skipped")
+		}
+	})
+}
+
+// createTestRepoWithSubmodule creates a test repository with the repository
+// lib checked out as a submodule at lib/. It returns both paths.
+func createTestRepoWithSubmodule(t *testing.T) (repo, lib string) {
+	t.Helper()
+
+	lib = createTestRepo(t)
+	repo = createTestRepo(t)
+	runGit(t, repo, "-c", "protocol.file.allow=always", "submodule", "add", lib, "lib")
+	runGit(t, repo, "commit", "-m", "Add lib submodule")
+	return repo, lib
+}
+
+func TestApplyPatches_Submodule(t *testing.T) {
+	ctx := context.Background()
+	provider, _ := NewLocalProvider(t.TempDir())
+	sourceRepo, libRepo := createTestRepoWithSubmodule(t)
+
+	workDir, baseCommit, err := provider.EnsureWorkspace(ctx, "project1", "ws1", sourceRepo, "")
+	if err != nil {
+		t.Fatalf("EnsureWorkspace failed: %v", err)
+	}
+	runGit(t, workDir, "-c", "protocol.file.allow=always", "submodule", "update", "--init")
+	runGit(t, workDir, "config", "user.email", "committer@example.com")
+	runGit(t, workDir, "config", "user.name", "Test Committer")
+
+	// The session's sandbox clones with submodules and commits a change to
+	// lib, then bumps the submodule pointer
+	sandbox := t.TempDir()
+	runGit(t, sandbox, "-c", "protocol.file.allow=always", "clone", "--recurse-submodules", sourceRepo, ".")
+	sandboxLib := filepath.Join(sandbox, "lib")
+	for _, dir := range []string{sandbox, sandboxLib} {
+		runGit(t, dir, "config", "user.email", "patch@example.com")
+		runGit(t, dir, "config", "user.name", "Patch Author")
+	}
+	if err := os.WriteFile(filepath.Join(sandboxLib, "lib.go"), []byte("package lib\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	runGit(t, sandboxLib, "add", "lib.go")
+	runGit(t, sandboxLib, "commit", "-m", "Add lib.go")
+	libCommit := strings.TrimSpace(runGit(t, sandboxLib, "rev-parse", "HEAD"))
+	runGit(t, sandbox, "add", "lib")
+	runGit(t, sandbox, "commit", "-m", "Bump lib")
+	patches := []byte(runGit(t, sandbox, "format-patch", "--stdout", baseCommit+"..HEAD"))
+
+	// The lib commit only exists in the sandbox, so applying the pointer
+	// would leave the workspace unable to check it out
+	_, err = provider.ApplyPatches(ctx, "ws1", patches, ApplyOptions{})
+	if !errors.Is(err, ErrSubmoduleCommitMissing) {
+		t.Fatalf("Expected ErrSubmoduleCommitMissing, got %v", err)
+	}
+	if head := strings.TrimSpace(runGit(t, workDir, "rev-parse", "HEAD")); head != baseCommit {
+		t.Errorf("Expected workspace to stay at %s, got %s", baseCommit, head)
+	}
+
+	// Once pushed, the workspace fetches it and checks it out
+	runGit(t, sandboxLib, "push", libRepo, "HEAD:refs/heads/session")
+	if _, err := provider.ApplyPatches(ctx, "ws1", patches, ApplyOptions{}); err != nil {
+		t.Fatalf("ApplyPatches failed: %v", err)
+	}
+	if tree := runGit(t, workDir, "ls-tree", "HEAD", "lib"); !strings.Contains(tree, "160000 commit "+libCommit) {
+		t.Errorf("Expected lib to point at %s, got %s", libCommit, tree)
+	}
+	if head := strings.TrimSpace(runGit(t, filepath.Join(workDir, "lib"), "rev-parse", "HEAD")); head != libCommit {
+		t.Errorf("Expected lib checked out at %s, got %s", libCommit, head)
+	}
+	if status := runGit(t, workDir, "status", "--porcelain"); status != "" {
+		t.Errorf("Expected a clean workspace, got:\n%s", status)
+	}
 }
 
 func TestWorkspaceIsolation(t *testing.T) {
@@ -0,0 +1,200 @@
+package git
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mirrorCache keeps bare mirrors of remote repositories so repeated clones
+// of the same repo only transfer objects the mirror doesn't already have.
+type mirrorCache struct {
+	// dir holds one bare mirror per repository: {dir}/{hash}.git
+	dir string
+
+	// maxAge is how long an unused mirror is kept (0 keeps mirrors forever)
+	maxAge time.Duration
+
+	// Per-repository mutexes serializing a mirror's fetches, clones, and
+	// removal; clones of different repositories don't wait on each other
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// WithMirrorCache enables a bare-repo mirror cache for remote workspaces.
+// Clones borrow objects from a local mirror of the remote (updated before
+// every clone), so only new objects are downloaded. Mirrors unused for longer
+// than maxAge are removed (0 keeps them forever).
+func WithMirrorCache(dir string, maxAge time.Duration) LocalProviderOption {
+	return func(p *LocalProvider) {
+		p.mirrors = &mirrorCache{
+			dir:    dir,
+			maxAge: maxAge,
+			locks:  make(map[string]*sync.Mutex),
+		}
+	}
+}
+
+// path returns the mirror directory for a remote URL's repository. The
+// repository is hashed so names from the URL never appear in file names.
+func (m *mirrorCache) path(source string) string {
+	sum := sha256.Sum256([]byte(mirrorRepository(source)))
+	return filepath.Join(m.dir, hex.EncodeToString(sum[:16])+".git")
+}
+
+// mirrorRepository identifies the repository a remote URL points at, so URLs
+// that differ only in credentials, host case, or a trailing ".git" or "/"
+// share one mirror (and its lock).
+func mirrorRepository(source string) string {
+	repo := source
+	if u, err := url.Parse(source); err == nil && u.Scheme != "" && u.Host != "" {
+		u.User = nil
+		u.Host = strings.ToLower(u.Host)
+		repo = u.String()
+	} else if at := strings.Index(source, "@"); at >= 0 && at < strings.Index(source, ":") {
+		// scp-like user@host:path
+		host, path, _ := strings.Cut(source[at+1:], ":")
+		repo = strings.ToLower(host) + ":" + path
+	}
+	repo = strings.TrimSuffix(repo, "/")
+	return strings.TrimSuffix(repo, ".git")
+}
+
+// lock returns the mutex for a mirror path, creating one if needed.
+func (m *mirrorCache) lock(path string) *sync.Mutex {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if lock, ok := m.locks[path]; ok {
+		return lock
+	}
+	lock := &sync.Mutex{}
+	m.locks[path] = lock
+	return lock
+}
+
+// cloneWithMirror clones source into workDir using the mirror cache.
+// The mirror is created or updated first; the clone then borrows its objects
+// with --reference and copies them with --dissociate, so the working copy
+// doesn't depend on the mirror and garbage collection can remove it safely.
+// Because the clone still talks to the remote, a stale or unusable mirror
+// only costs speed, never correctness.
+func (p *LocalProvider) cloneWithMirror(ctx context.Context, source, ref, workDir string) error {
+	if err := p.cloneFromMirror(ctx, source, ref, workDir); err != nil {
+		return err
+	}
+
+	// Collect unused mirrors once this repository's lock is released
+	if _, err := p.PruneMirrors(); err != nil {
+		log.Printf("git mirror: %v", err)
+	}
+	return nil
+}
+
+// cloneFromMirror updates the mirror for source's repository and clones from
+// it, holding only that repository's lock.
+func (p *LocalProvider) cloneFromMirror(ctx context.Context, source, ref, workDir string) error {
+	mirror := p.mirrors.path(source)
+
+	lock := p.mirrors.lock(mirror)
+	lock.Lock()
+	defer lock.Unlock()
+
+	args := []string{"clone"}
+	if err := p.updateMirror(ctx, source, mirror); err != nil {
+		log.Printf("git mirror: %v, cloning without it", err)
+	} else {
+		args = append(args, "--reference-if-able", mirror, "--dissociate")
+	}
+	if ref != "" {
+		args = append(args, "-b", ref)
+	}
+	args = append(args, source, workDir)
+
+	if err := p.runGit(ctx, "", args...); err != nil {
+		return err
+	}
+
+	// Mark the mirror as recently used
+	now := time.Now()
+	_ = os.Chtimes(mirror, now, now)
+	return nil
+}
+
+// updateMirror creates the mirror for source or fetches into an existing one.
+// The mirror may have been created through another URL for the same
+// repository, so it fetches from source, with source's credentials.
+// A mirror that can't be fetched is removed so the next clone recreates it.
+func (p *LocalProvider) updateMirror(ctx context.Context, source, mirror string) error {
+	if _, err := os.Stat(mirror); err == nil {
+		err := p.runGit(ctx, mirror, "remote", "set-url", "origin", source)
+		if err == nil {
+			err = p.runGit(ctx, mirror, "fetch", "--prune", "origin")
+		}
+		if err != nil {
+			_ = os.RemoveAll(mirror)
+			return fmt.Errorf("failed to update mirror %s: %w", filepath.Base(mirror), err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(p.mirrors.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create mirror directory: %w", err)
+	}
+	if err := p.runGit(ctx, "", "clone", "--mirror", source, mirror); err != nil {
+		_ = os.RemoveAll(mirror)
+		return fmt.Errorf("failed to create mirror %s: %w", filepath.Base(mirror), err)
+	}
+	return nil
+}
+
+// PruneMirrors removes mirrors that haven't been used within the cache's
+// maxAge. Mirrors in use are skipped. Returns the number of mirrors removed.
+// It is a no-op if the mirror cache is disabled or maxAge is 0.
+func (p *LocalProvider) PruneMirrors() (int, error) {
+	m := p.mirrors
+	if m == nil || m.maxAge <= 0 {
+		return 0, nil
+	}
+
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read mirror directory: %w", err)
+	}
+
+	removed := 0
+	cutoff := time.Now().Add(-m.maxAge)
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasSuffix(entry.Name(), ".git") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		mirror := filepath.Join(m.dir, entry.Name())
+		lock := m.lock(mirror)
+		if !lock.TryLock() {
+			continue
+		}
+		if err := os.RemoveAll(mirror); err != nil {
+			log.Printf("git mirror: failed to remove unused mirror %s: %v", entry.Name(), err)
+		} else {
+			removed++
+		}
+		lock.Unlock()
+	}
+	return removed, nil
+}
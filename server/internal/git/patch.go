@@ -0,0 +1,133 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PatchSummary summarizes mbox-format patches (from git format-patch)
+// without applying them.
+type PatchSummary struct {
+	Commits      int             `json:"commits"`
+	FilesChanged int             `json:"filesChanged"`
+	Additions    int             `json:"additions"`
+	Deletions    int             `json:"deletions"`
+	Files        []PatchFileStat `json:"files"`
+}
+
+// PatchFileStat is the change to one file across all patches.
+type PatchFileStat struct {
+	Path      string `json:"path"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary"`
+	// Submodule is set for a submodule (gitlink) path; SubmoduleCommit is
+	// the commit the patches leave it pointing at, empty if it was removed.
+	Submodule       bool   `json:"submodule,omitempty"`
+	SubmoduleCommit string `json:"submoduleCommit,omitempty"`
+}
+
+var (
+	// "From <sha> Mon Sep 17 00:00:00 2001" starts each patch in the mbox
+	mboxFromRe  = regexp.MustCompile(`^From [0-9a-f]+ Mon Sep 17 00:00:00 2001$`)
+	diffGitRe   = regexp.MustCompile(`^diff --git a/(.+) b/(.+)$`)
+	hunkHeadRe  = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+\d+(?:,(\d+))? @@`)
+	binaryDiffs = []string{"Binary files ", "GIT binary patch"}
+	// Extended header lines giving a submodule's 160000 (gitlink) mode
+	gitlinkModeRe = regexp.MustCompile(`^(?:index [0-9a-f]+\.\.[0-9a-f]+|new file mode|deleted file mode|new mode) 160000$`)
+)
+
+// SummarizePatches counts the commits, files, and changed lines in
+// mbox-format patches. Hunk line counts come from the hunk headers, so
+// content that looks like patch syntax isn't miscounted.
+func SummarizePatches(patches []byte) PatchSummary {
+	summary := PatchSummary{Files: []PatchFileStat{}}
+	files := map[string]*PatchFileStat{}
+	var current *PatchFileStat
+	oldLeft, newLeft := 0, 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(patches))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Inside a hunk, consume exactly the lines its header announced
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				current.Additions++
+				newLeft--
+				if current.Submodule && strings.HasPrefix(line, "+Subproject commit ") {
+					current.SubmoduleCommit = strings.TrimPrefix(line, "+Subproject commit ")
+				}
+			case strings.HasPrefix(line, "-"):
+				current.Deletions++
+				oldLeft--
+			case strings.HasPrefix(line, `\`):
+				// "\ No newline at end of file"
+			default:
+				oldLeft--
+				newLeft--
+			}
+			continue
+		}
+
+		switch {
+		case mboxFromRe.MatchString(line):
+			summary.Commits++
+			current = nil
+		case diffGitRe.MatchString(line):
+			// Renamed files are counted under their new path
+			path := diffGitRe.FindStringSubmatch(line)[2]
+			current = fileStat(files, &summary, path)
+		case current == nil:
+		case hunkHeadRe.MatchString(line):
+			m := hunkHeadRe.FindStringSubmatch(line)
+			oldLeft, newLeft = hunkCount(m[1]), hunkCount(m[2])
+		case gitlinkModeRe.MatchString(line):
+			current.Submodule = true
+			if strings.HasPrefix(line, "deleted file mode") {
+				current.SubmoduleCommit = ""
+			}
+		default:
+			for _, prefix := range binaryDiffs {
+				if strings.HasPrefix(line, prefix) {
+					current.Binary = true
+				}
+			}
+		}
+	}
+
+	for i := range summary.Files {
+		f := files[summary.Files[i].Path]
+		summary.Files[i] = *f
+		summary.Additions += f.Additions
+		summary.Deletions += f.Deletions
+	}
+	summary.FilesChanged = len(summary.Files)
+	return summary
+}
+
+// fileStat returns the stats for path, adding it to the summary in order of
+// first appearance.
+func fileStat(files map[string]*PatchFileStat, summary *PatchSummary, path string) *PatchFileStat {
+	if f, ok := files[path]; ok {
+		return f
+	}
+	f := &PatchFileStat{Path: path}
+	files[path] = f
+	summary.Files = append(summary.Files, PatchFileStat{Path: path})
+	return f
+}
+
+// hunkCount parses a hunk header line count, which defaults to 1 when omitted.
+func hunkCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,83 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSummarizePatches(t *testing.T) {
+	repo := createTestRepo(t)
+	runGit(t, repo, "config", "user.email", "patch@example.com")
+	runGit(t, repo, "config", "user.name", "Patch Author")
+	base := strings.TrimSpace(runGit(t, repo, "rev-parse", "HEAD"))
+
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		runGit(t, repo, "add", name)
+	}
+
+	// Lines that look like patch syntax must be counted as content
+	write("notes.txt", "one\n-- \n+++ plus\n--- minus\n")
+	runGit(t, repo, "commit", "-m", "Add notes")
+	write("notes.txt", "one\n-- \n+++ plus\nchanged\n")
+	write("image.bin", "\x00\x01\x02")
+	runGit(t, repo, "commit", "-m", "Change notes, add binary")
+
+	summary := SummarizePatches([]byte(runGit(t, repo, "format-patch", "--stdout", base+"..HEAD")))
+
+	if summary.Commits != 2 || summary.FilesChanged != 2 {
+		t.Fatalf("Expected 2 commits and 2 files, got %+v", summary)
+	}
+	// notes.txt: 4 lines added, then 1 replaced
+	if summary.Additions != 5 || summary.Deletions != 1 {
+		t.Errorf("Expected 5 additions and 1 deletion, got %d/%d", summary.Additions, summary.Deletions)
+	}
+	notes, image := summary.Files[0], summary.Files[1]
+	if notes.Path != "notes.txt" || notes.Additions != 5 || notes.Deletions != 1 || notes.Binary {
+		t.Errorf("Unexpected notes.txt stats: %+v", notes)
+	}
+	if image.Path != "image.bin" || !image.Binary {
+		t.Errorf("Expected image.bin to be binary, got %+v", image)
+	}
+}
+
+func TestSummarizePatches_Submodules(t *testing.T) {
+	repo, lib := createTestRepoWithSubmodule(t)
+	runGit(t, repo, "config", "user.email", "patch@example.com")
+	runGit(t, repo, "config", "user.name", "Patch Author")
+	base := strings.TrimSpace(runGit(t, repo, "rev-parse", "HEAD"))
+	libCommit := strings.TrimSpace(runGit(t, lib, "rev-parse", "HEAD"))
+
+	runGit(t, repo, "-c", "protocol.file.allow=always", "submodule", "add", lib, "vendor/lib2")
+	runGit(t, repo, "commit", "-m", "Add lib2")
+	runGit(t, repo, "rm", "lib")
+	runGit(t, repo, "commit", "-m", "Remove lib")
+
+	summary := SummarizePatches([]byte(runGit(t, repo, "format-patch", "--stdout", base+"..HEAD")))
+
+	stats := map[string]PatchFileStat{}
+	for _, f := range summary.Files {
+		stats[f.Path] = f
+	}
+	if f := stats["vendor/lib2"]; !f.Submodule || f.SubmoduleCommit != libCommit {
+		t.Errorf("Expected vendor/lib2 to be a submodule at %s, got %+v", libCommit, f)
+	}
+	if f := stats["lib"]; !f.Submodule || f.SubmoduleCommit != "" {
+		t.Errorf("Expected lib to be a removed submodule, got %+v", f)
+	}
+	if f := stats[".gitmodules"]; f.Submodule {
+		t.Errorf("Expected .gitmodules not to be a submodule, got %+v", f)
+	}
+}
+
+func TestSummarizePatches_Empty(t *testing.T) {
+	summary := SummarizePatches(nil)
+	if summary.Commits != 0 || summary.FilesChanged != 0 || len(summary.Files) != 0 {
+		t.Errorf("Expected empty summary, got %+v", summary)
+	}
+}
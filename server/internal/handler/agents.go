@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"log"
 	"net/http"
 
@@ -8,6 +9,7 @@ import (
 
 	"github.com/obot-platform/discobot/server/internal/middleware"
 	"github.com/obot-platform/discobot/server/internal/providers"
+	"github.com/obot-platform/discobot/server/internal/service"
 )
 
 // Icon is an alias for providers.Icon
@@ -31,6 +33,12 @@ type AgentType struct {
 	SupportedAuthProviders   []string `json:"supportedAuthProviders,omitempty"`   // Use ["*"] for all providers
 	HighlightedAuthProviders []string `json:"highlightedAuthProviders,omitempty"` // Featured auth providers for this agent
 	AllowNoAuth              bool     `json:"allowNoAuth,omitempty"`
+
+	// DefaultEnv is set in the sandbox of every session using the agent
+	DefaultEnv map[string]string `json:"defaultEnv,omitempty"`
+	// RequiredCredentialProviders must all have a stored credential in the
+	// project before an agent of this type can be created
+	RequiredCredentialProviders []string `json:"requiredCredentialProviders,omitempty"`
 }
 
 // Hardcoded agent types (matching TypeScript)
@@ -50,6 +58,8 @@ var agentTypes = []AgentType{
 		Enabled:                  true,
 		SupportedAuthProviders:   []string{"anthropic"},
 		HighlightedAuthProviders: []string{"anthropic"},
+		// Sandboxes get new versions from new images, not by updating in place
+		DefaultEnv: map[string]string{"DISABLE_AUTOUPDATER": "1"},
 	},
 	{
 		ID:          "opencode",
@@ -130,11 +140,26 @@ var agentTypes = []AgentType{
 			{Src: "https://cdn.simpleicons.org/githubcopilot", MimeType: "image/svg+xml", Theme: "light"},
 			{Src: "https://cdn.simpleicons.org/githubcopilot/white", MimeType: "image/svg+xml", Theme: "dark"},
 		},
-		Enabled:                false,
-		SupportedAuthProviders: []string{"github-copilot"},
+		Enabled:                     false,
+		SupportedAuthProviders:      []string{"github-copilot"},
+		RequiredCredentialProviders: []string{"github-copilot"},
 	},
 }
 
+// ServiceAgentTypes returns the agent types in the form the services use.
+func ServiceAgentTypes() []service.AgentType {
+	types := make([]service.AgentType, len(agentTypes))
+	for i, at := range agentTypes {
+		types[i] = service.AgentType{
+			ID:                          at.ID,
+			SupportedAuthProviders:      at.SupportedAuthProviders,
+			DefaultEnv:                  at.DefaultEnv,
+			RequiredCredentialProviders: at.RequiredCredentialProviders,
+		}
+	}
+	return types
+}
+
 // ListAgents returns all agents for a project
 func (h *Handler) ListAgents(w http.ResponseWriter, r *http.Request) {
 	projectID := middleware.GetProjectID(r.Context())
@@ -165,6 +190,10 @@ func (h *Handler) CreateAgent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	agent, err := h.agentService.CreateAgent(r.Context(), projectID, req.AgentType)
+	if errors.Is(err, service.ErrMissingCredentials) {
+		h.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		log.Printf("Failed to create agent: %v", err)
 		h.Error(w, http.StatusInternalServerError, "Failed to create agent")
@@ -174,6 +203,16 @@ func (h *Handler) CreateAgent(w http.ResponseWriter, r *http.Request) {
 	h.JSON(w, http.StatusCreated, agent)
 }
 
+// agentTypeEnabled reports whether id is a known, enabled agent type.
+func agentTypeEnabled(id string) bool {
+	for _, at := range agentTypes {
+		if at.ID == id {
+			return at.Enabled
+		}
+	}
+	return false
+}
+
 // GetAgentTypes returns supported agent types
 func (h *Handler) GetAgentTypes(w http.ResponseWriter, _ *http.Request) {
 	// Filter out disabled agent types
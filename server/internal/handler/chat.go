@@ -8,6 +8,8 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/obot-platform/discobot/server/internal/middleware"
 	"github.com/obot-platform/discobot/server/internal/model"
@@ -39,8 +41,58 @@ type ChatRequest struct {
 	Reasoning string `json:"reasoning,omitempty"`
 }
 
+// Typed SSE event names. When a chat stream is requested with ?events=typed,
+// each UIMessage stream part is preceded by an "event:" line naming its kind so
+// clients can render text, reasoning and tool activity separately. Parts of any
+// other kind are sent without an event line (the SSE default "message" event).
+const (
+	sseEventTextDelta  = "text-delta"
+	sseEventReasoning  = "reasoning"
+	sseEventToolCall   = "tool-call"
+	sseEventToolResult = "tool-result"
+)
+
+// sseEventName returns the typed SSE event name for a UIMessage stream part,
+// or "" if the part has no typed event.
+func sseEventName(data string) string {
+	var part struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(data), &part); err != nil {
+		return ""
+	}
+	switch part.Type {
+	case "text-delta":
+		return sseEventTextDelta
+	case "reasoning-delta":
+		return sseEventReasoning
+	case "tool-input-start", "tool-input-delta", "tool-input-available", "tool-input-error":
+		return sseEventToolCall
+	case "tool-output-available", "tool-output-error":
+		return sseEventToolResult
+	default:
+		return ""
+	}
+}
+
+// wantsTypedEvents reports whether the client asked for typed SSE events.
+func wantsTypedEvents(r *http.Request) bool {
+	return r.URL.Query().Get("events") == "typed"
+}
+
+// writeSSEData writes a UIMessage stream part as an SSE data line, preceded by
+// its event name when typed events are enabled.
+func writeSSEData(w http.ResponseWriter, data string, typed bool) {
+	if typed {
+		if name := sseEventName(data); name != "" {
+			_, _ = fmt.Fprintf(w, "event: %s\n", name)
+		}
+	}
+	_, _ = fmt.Fprintf(w, "data: %s\n\n", data)
+}
+
 // Chat handles AI chat streaming.
-// POST /api/chat
+// POST /api/chat[?events=typed]
 // Request body: { id, messages, workspaceId?, agentId?, trigger?, messageId? }
 // Response: SSE stream with AI SDK UI message protocol
 func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
@@ -66,6 +118,7 @@ func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	sessionID := req.ID
+	typed := wantsTypedEvents(r)
 
 	// Check if session exists
 	existingSession, err := h.chatService.GetSessionByID(ctx, sessionID)
@@ -109,6 +162,10 @@ func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
 			Messages:    req.Messages,
 		})
 		if err != nil {
+			if errors.Is(err, service.ErrQuiesced) {
+				h.Error(w, http.StatusServiceUnavailable, "The server is in maintenance and not accepting new sessions. Existing sessions are unaffected.")
+				return
+			}
 			h.Error(w, http.StatusBadRequest, err.Error())
 			return
 		}
@@ -165,12 +222,16 @@ func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.chatViewers.add(sessionID)
+	defer h.chatViewers.remove(sessionID)
+
 	// Pass through raw SSE lines from sandbox
 	for {
 		select {
 		case <-ctx.Done():
 			// Client disconnected
 			log.Printf("[Chat] Client disconnected, stopping SSE stream")
+			h.cancelAbandonedChat(projectID, sessionID)
 			return
 		case line, ok := <-sseCh:
 			if !ok {
@@ -212,20 +273,21 @@ func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
 					}()
 				}
 			}
-			// Pass through raw data line without parsing
-			_, _ = fmt.Fprintf(w, "data: %s\n\n", line.Data)
+			// Pass through raw data line without modification
+			writeSSEData(w, line.Data, typed)
 			flusher.Flush()
 		}
 	}
 }
 
 // ChatStream handles resuming an in-progress chat stream.
-// GET /api/chat/{sessionId}/stream
+// GET /api/chat/{sessionId}/stream[?events=typed]
 // Response: SSE stream if completion in progress, 204 No Content if not
 func (h *Handler) ChatStream(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	projectID := middleware.GetProjectID(ctx)
 	sessionID := r.PathValue("sessionId")
+	typed := wantsTypedEvents(r)
 
 	if sessionID == "" {
 		h.Error(w, http.StatusBadRequest, "sessionId is required")
@@ -290,6 +352,9 @@ func (h *Handler) ChatStream(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.chatViewers.add(sessionID)
+	defer h.chatViewers.remove(sessionID)
+
 	// Send the first message if we consumed one during the check
 	if firstLine != nil {
 		if firstLine.Done {
@@ -298,7 +363,7 @@ func (h *Handler) ChatStream(w http.ResponseWriter, r *http.Request) {
 			flusher.Flush()
 			return
 		}
-		_, _ = fmt.Fprintf(w, "data: %s\n\n", firstLine.Data)
+		writeSSEData(w, firstLine.Data, typed)
 		flusher.Flush()
 	}
 
@@ -308,6 +373,7 @@ func (h *Handler) ChatStream(w http.ResponseWriter, r *http.Request) {
 		case <-ctx.Done():
 			// Client disconnected
 			log.Printf("[ChatStream] Client disconnected, stopping SSE stream")
+			h.cancelAbandonedChat(projectID, sessionID)
 			return
 		case line, ok := <-sseCh:
 			if !ok {
@@ -322,7 +388,7 @@ func (h *Handler) ChatStream(w http.ResponseWriter, r *http.Request) {
 				flusher.Flush()
 				return
 			}
-			_, _ = fmt.Fprintf(w, "data: %s\n\n", line.Data)
+			writeSSEData(w, line.Data, typed)
 			flusher.Flush()
 		}
 	}
@@ -453,6 +519,67 @@ func (h *Handler) ChatCancel(w http.ResponseWriter, r *http.Request) {
 	h.JSON(w, http.StatusOK, result)
 }
 
+// chatViewers counts the clients streaming each session's completion.
+type chatViewers struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (v *chatViewers) add(sessionID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.counts == nil {
+		v.counts = make(map[string]int)
+	}
+	v.counts[sessionID]++
+}
+
+func (v *chatViewers) remove(sessionID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.counts[sessionID] <= 1 {
+		delete(v.counts, sessionID)
+	} else {
+		v.counts[sessionID]--
+	}
+}
+
+func (v *chatViewers) count(sessionID string) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.counts[sessionID]
+}
+
+// cancelAbandonedChat cancels the session's completion if no client is
+// streaming it cfg.ChatDisconnectCancelAfter after a client disconnected, so
+// an abandoned completion doesn't keep running the agent. The delay lets a
+// reloaded page resume the stream instead. Disabled when the delay is 0.
+func (h *Handler) cancelAbandonedChat(projectID, sessionID string) {
+	delay := h.cfg.ChatDisconnectCancelAfter
+	if delay <= 0 {
+		return
+	}
+
+	time.AfterFunc(delay, func() {
+		if h.chatViewers.count(sessionID) > 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if _, err := h.chatService.CancelCompletion(ctx, projectID, sessionID); err != nil {
+			if !errors.Is(err, service.ErrNoActiveCompletion) {
+				log.Printf("[Chat] Warning: failed to cancel abandoned completion for session %s: %v", sessionID, err)
+			}
+			return
+		}
+		log.Printf("[Chat] Cancelled completion for session %s after all clients disconnected", sessionID)
+		if _, err := h.sessionService.UpdateStatus(ctx, projectID, sessionID, model.SessionStatusReady, nil); err != nil {
+			log.Printf("[Chat] Warning: failed to reset session %s status to ready: %v", sessionID, err)
+		}
+	})
+}
+
 // writeSSEErrorAndDone sends an error SSE event followed by the [DONE] signal.
 // This ensures the AI SDK properly closes the stream after receiving the error.
 func writeSSEErrorAndDone(w http.ResponseWriter, errorText string) {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"sync"
@@ -343,6 +344,205 @@ func TestChat_ClientDisconnect_StatusRemainsRunning(t *testing.T) {
 	}
 }
 
+// TestChat_ClientDisconnect_CancelsAbandonedCompletion verifies that with
+// ChatDisconnectCancelAfter set, a completion no client is streaming is
+// cancelled in the sandbox once the delay passes, unless another client is
+// still watching it.
+func TestChat_ClientDisconnect_CancelsAbandonedCompletion(t *testing.T) {
+	for _, otherViewer := range []bool{false, true} {
+		t.Run(fmt.Sprintf("other viewer=%v", otherViewer), func(t *testing.T) {
+			s := setupChatTestStore(t)
+			provider := mocksandbox.NewProvider()
+			sessionID := "session-abandon-test"
+
+			seedSession(t, s, sessionID)
+
+			ctx := context.Background()
+			if _, err := provider.Create(ctx, sessionID, sandbox.CreateOptions{
+				SharedSecret:  "test-secret",
+				WorkspacePath: "/workspace",
+			}); err != nil {
+				t.Fatalf("failed to create sandbox: %v", err)
+			}
+			if err := provider.Start(ctx, sessionID); err != nil {
+				t.Fatalf("failed to start sandbox: %v", err)
+			}
+
+			// The completion runs until it is cancelled, like the agent-api
+			sseStarted := make(chan struct{})
+			cancelled := make(chan struct{})
+			var cancelOnce sync.Once
+			provider.HTTPHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == "/chat" && r.Method == "POST":
+					w.WriteHeader(http.StatusAccepted)
+				case r.URL.Path == "/chat" && r.Method == "GET":
+					w.Header().Set("Content-Type", "text/event-stream")
+					w.WriteHeader(http.StatusOK)
+					close(sseStarted)
+					<-r.Context().Done()
+				case r.URL.Path == "/chat/cancel" && r.Method == "POST":
+					cancelOnce.Do(func() { close(cancelled) })
+					w.Header().Set("Content-Type", "application/json")
+					_, _ = w.Write([]byte(`{"success":true,"status":"cancelled"}`))
+				default:
+					http.NotFound(w, r)
+				}
+			})
+
+			h := newChatTestHandler(t, s, provider)
+			h.cfg.ChatDisconnectCancelAfter = 50 * time.Millisecond
+			if otherViewer {
+				h.chatViewers.add(sessionID)
+			}
+
+			reqCtx, cancelReq := context.WithCancel(context.Background())
+			req := makeChatRequest(reqCtx, t, ChatRequest{
+				ID:       sessionID,
+				Messages: json.RawMessage(`[{"role":"user","parts":[{"type":"text","text":"hello"}]}]`),
+			})
+			handlerDone := make(chan struct{})
+			go func() {
+				defer close(handlerDone)
+				h.Chat(httptest.NewRecorder(), req)
+			}()
+
+			select {
+			case <-sseStarted:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for SSE stream to start")
+			}
+			cancelReq()
+			<-handlerDone
+
+			if otherViewer {
+				select {
+				case <-cancelled:
+					t.Fatal("completion was cancelled while another client was streaming it")
+				case <-time.After(300 * time.Millisecond):
+				}
+				return
+			}
+
+			select {
+			case <-cancelled:
+			case <-time.After(5 * time.Second):
+				t.Fatal("abandoned completion was not cancelled")
+			}
+
+			// The session goes back to ready once the cancel succeeds
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				session, err := s.GetSessionByID(context.Background(), sessionID)
+				if err != nil {
+					t.Fatalf("failed to get session: %v", err)
+				}
+				if session.Status == model.SessionStatusReady {
+					return
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("expected session status %q after cancel, got %q", model.SessionStatusReady, session.Status)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+// TestChatCancel_CancelsSandboxCompletion verifies that ChatCancel calls the
+// agent API's cancel endpoint and cancels the context the completion's stream
+// was started with, ending the in-progress Chat stream.
+func TestChatCancel_CancelsSandboxCompletion(t *testing.T) {
+	s := setupChatTestStore(t)
+	provider := mocksandbox.NewProvider()
+	sessionID := "session-cancel-test"
+
+	seedSession(t, s, sessionID)
+
+	ctx := context.Background()
+	if _, err := provider.Create(ctx, sessionID, sandbox.CreateOptions{
+		SharedSecret:  "test-secret",
+		WorkspacePath: "/workspace",
+	}); err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+	if err := provider.Start(ctx, sessionID); err != nil {
+		t.Fatalf("failed to start sandbox: %v", err)
+	}
+
+	// The completion runs until its stream is closed, like the agent-api
+	sseStarted := make(chan struct{})
+	streamClosed := make(chan struct{})
+	cancelled := make(chan struct{})
+	var cancelOnce sync.Once
+	provider.HTTPHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/chat" && r.Method == "POST":
+			w.WriteHeader(http.StatusAccepted)
+		case r.URL.Path == "/chat" && r.Method == "GET":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			close(sseStarted)
+			<-r.Context().Done()
+			close(streamClosed)
+		case r.URL.Path == "/chat/cancel" && r.Method == "POST":
+			cancelOnce.Do(func() { close(cancelled) })
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"success":true,"completionId":"c1","status":"cancelled"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	h := newChatTestHandler(t, s, provider)
+
+	req := makeChatRequest(context.Background(), t, ChatRequest{
+		ID:       sessionID,
+		Messages: json.RawMessage(`[{"role":"user","parts":[{"type":"text","text":"hello"}]}]`),
+	})
+	handlerDone := make(chan struct{})
+	go func() {
+		defer close(handlerDone)
+		h.Chat(httptest.NewRecorder(), req)
+	}()
+
+	select {
+	case <-sseStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for SSE stream to start")
+	}
+
+	cancelReq := httptest.NewRequest("POST", "/api/chat/"+sessionID+"/cancel", nil)
+	cancelReq.SetPathValue("sessionId", sessionID)
+	cancelReq = cancelReq.WithContext(context.WithValue(cancelReq.Context(), middleware.ProjectIDKey, testProjectID))
+	w := httptest.NewRecorder()
+	h.ChatCancel(w, cancelReq)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	select {
+	case <-cancelled:
+	default:
+		t.Fatal("ChatCancel did not call the sandbox cancel endpoint")
+	}
+	for name, done := range map[string]chan struct{}{"sandbox stream": streamClosed, "Chat handler": handlerDone} {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s still running after ChatCancel", name)
+		}
+	}
+
+	session, err := s.GetSessionByID(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	if session.Status != model.SessionStatusReady {
+		t.Errorf("expected session status %q after cancel, got %q", model.SessionStatusReady, session.Status)
+	}
+}
+
 // TestChat_CompletionFinishes_StatusResetsToReady verifies that when a completion
 // finishes normally (DONE signal), the session status is reset to "ready".
 func TestChat_CompletionFinishes_StatusResetsToReady(t *testing.T) {
@@ -397,3 +597,67 @@ func TestChat_CompletionFinishes_StatusResetsToReady(t *testing.T) {
 		t.Errorf("expected response to contain 'data: [DONE]', got: %s", body)
 	}
 }
+
+func TestChat_TypedEvents(t *testing.T) {
+	s := setupChatTestStore(t)
+	provider := mocksandbox.NewProvider()
+	sessionID := "session-typed-events"
+
+	seedSession(t, s, sessionID)
+
+	ctx := context.Background()
+	if _, err := provider.Create(ctx, sessionID, sandbox.CreateOptions{
+		SharedSecret:  "test-secret",
+		WorkspacePath: "/workspace",
+	}); err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+	if err := provider.Start(ctx, sessionID); err != nil {
+		t.Fatalf("failed to start sandbox: %v", err)
+	}
+
+	parts := []string{
+		`{"type":"start"}`,
+		`{"type":"text-delta","id":"t1","delta":"Hi"}`,
+		`{"type":"tool-input-available","toolCallId":"c1","toolName":"Bash","input":{}}`,
+		`{"type":"tool-output-available","toolCallId":"c1","output":"ok"}`,
+		`{"type":"finish"}`,
+	}
+	provider.HTTPHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		for _, p := range parts {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", p)
+		}
+		_, _ = fmt.Fprintf(w, "data: [DONE]\n\n")
+	})
+
+	h := newChatTestHandler(t, s, provider)
+
+	req := makeChatRequest(ctx, t, ChatRequest{
+		ID:       sessionID,
+		Messages: json.RawMessage(`[{"role":"user","parts":[{"type":"text","text":"hello"}]}]`),
+	})
+	req.URL.RawQuery = "events=typed"
+	w := httptest.NewRecorder()
+
+	h.Chat(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d; body: %s", w.Code, w.Body.String())
+	}
+
+	want := "data: " + parts[0] + "\n\n" +
+		"event: text-delta\ndata: " + parts[1] + "\n\n" +
+		"event: tool-call\ndata: " + parts[2] + "\n\n" +
+		"event: tool-result\ndata: " + parts[3] + "\n\n" +
+		"data: " + parts[4] + "\n\n" +
+		"data: [DONE]\n\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("unexpected stream:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/sandbox/vm"
+)
+
+const (
+	// consoleDefaultTailBytes is how much of the console log is returned by default.
+	consoleDefaultTailBytes = 64 * 1024
+	// consoleMaxTailBytes caps the amount of console history returned per request.
+	consoleMaxTailBytes = 1024 * 1024
+	// consolePollInterval is how often the console log is checked for new output when following.
+	consolePollInterval = 500 * time.Millisecond
+)
+
+// consoleCopyBufferSize is the size of each chunk copied from the console log.
+const consoleCopyBufferSize = 32 * 1024
+
+// States of controlStripper between escape sequences.
+const (
+	stripText   = iota // Plain text
+	stripEscape        // After ESC
+	stripCSI           // Inside a CSI sequence (ESC [)
+	stripOSC           // Inside an OSC sequence (ESC ])
+	stripOSCEsc        // After ESC inside an OSC sequence
+)
+
+// controlStripper is a writer that removes ANSI CSI/OSC escape sequences and
+// control characters, keeping newlines and tabs, before writing to w. It
+// keeps its place between writes, so a sequence split across chunks of the
+// log is still removed.
+type controlStripper struct {
+	w     io.Writer
+	state int
+	buf   []byte
+}
+
+func newControlStripper(w io.Writer) *controlStripper {
+	return &controlStripper{w: w}
+}
+
+// Write strips p and writes what is left. It reports all of p as written.
+func (s *controlStripper) Write(p []byte) (int, error) {
+	out := s.buf[:0]
+	for _, b := range p {
+		switch s.state {
+		case stripEscape:
+			switch {
+			case b == '[':
+				s.state = stripCSI
+				continue
+			case b == ']':
+				s.state = stripOSC
+				continue
+			case b >= '@' && b <= '_':
+				// Two-byte sequence
+				s.state = stripText
+				continue
+			}
+			s.state = stripText
+		case stripCSI:
+			switch {
+			case b >= 0x20 && b <= 0x3f:
+				// Parameter and intermediate bytes
+				continue
+			case b >= 0x40 && b <= 0x7e:
+				// Final byte
+				s.state = stripText
+				continue
+			}
+			s.state = stripText
+		case stripOSC:
+			switch b {
+			case 0x07:
+				s.state = stripText
+			case 0x1b:
+				s.state = stripOSCEsc
+			}
+			continue
+		case stripOSCEsc:
+			if b == '\\' {
+				s.state = stripText
+				continue
+			}
+			s.state = stripText
+		}
+
+		switch {
+		case b == 0x1b:
+			s.state = stripEscape
+		case b == '\n' || b == '\t' || (b >= 0x20 && b != 0x7f):
+			out = append(out, b)
+		}
+	}
+	s.buf = out
+	if len(out) == 0 {
+		return len(p), nil
+	}
+	if _, err := s.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// GetSessionConsole returns the serial console log of the VM hosting a session.
+// This works without the agent API, so it can be used to diagnose VZ sessions
+// whose guest failed to boot. The console belongs to the project VM and is
+// shared by all sessions in the project.
+// GET /api/projects/{projectId}/sessions/{sessionId}/console?tail=65536&follow=true&strip=true
+func (h *Handler) GetSessionConsole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+
+	session, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || session.ProjectID != projectID {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	tail := consoleDefaultTailBytes
+	if v := r.URL.Query().Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			h.Error(w, http.StatusBadRequest, "tail must be a non-negative integer")
+			return
+		}
+		tail = min(n, consoleMaxTailBytes)
+	}
+	follow := r.URL.Query().Get("follow") == "true"
+	strip := r.URL.Query().Get("strip") == "true"
+
+	f, err := os.Open(vm.ConsoleLogPath(h.cfg.VZConsoleLogDir, projectID))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			h.Error(w, http.StatusNotFound, "Console log not available (only VZ sandboxes have a console)")
+			return
+		}
+		h.Error(w, http.StatusInternalServerError, "Failed to open console log")
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		h.Error(w, http.StatusInternalServerError, "Failed to read console log")
+		return
+	}
+	offset := max(info.Size()-int64(tail), 0)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+
+	// The stripper is shared by every copy, so escape sequences written
+	// across two polls are removed too
+	var out io.Writer = w
+	if strip {
+		out = newControlStripper(w)
+	}
+
+	offset, err = copyConsole(out, f, offset, info.Size())
+	if err != nil || !follow {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(consolePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := f.Stat()
+		if err != nil {
+			return
+		}
+		size := info.Size()
+		if size < offset {
+			// Log was truncated (VM recreated), start over from the beginning
+			offset = 0
+		}
+		if size == offset {
+			continue
+		}
+		if offset, err = copyConsole(out, f, offset, size); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// copyConsole writes the console log bytes in [from, to) to w, a chunk at a
+// time, and returns the new offset.
+func copyConsole(w io.Writer, f *os.File, from, to int64) (int64, error) {
+	n, err := io.CopyBuffer(w, io.NewSectionReader(f, from, to-from), make([]byte, consoleCopyBufferSize))
+	return from + n, err
+}
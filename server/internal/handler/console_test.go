@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestControlStripper(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "plain text is unchanged",
+			input:    "Booting Linux\n\tok\n",
+			expected: "Booting Linux\n\tok\n",
+		},
+		{
+			name:     "ANSI color codes are removed",
+			input:    "\x1b[32m[  OK  ]\x1b[0m Started Docker\n",
+			expected: "[  OK  ] Started Docker\n",
+		},
+		{
+			name:     "OSC title sequence is removed",
+			input:    "\x1b]0;guest\x07login:",
+			expected: "login:",
+		},
+		{
+			name:     "OSC sequence ended by ST is removed",
+			input:    "\x1b]2;guest\x1b\\done\x1bMx\n",
+			expected: "donex\n",
+		},
+		{
+			name:     "carriage returns and bells are removed",
+			input:    "progress 50%\rprogress 100%\x07\r\n",
+			expected: "progress 50%progress 100%\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if _, err := newControlStripper(&buf).Write([]byte(tt.input)); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.expected {
+				t.Errorf("stripped %q = %q, want %q", tt.input, got, tt.expected)
+			}
+
+			// Written a byte at a time, as if every sequence were split
+			// across reads of the log
+			buf.Reset()
+			s := newControlStripper(&buf)
+			for i := range len(tt.input) {
+				if _, err := s.Write([]byte{tt.input[i]}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := buf.String(); got != tt.expected {
+				t.Errorf("stripped %q byte by byte = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCopyConsole(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "console.log")
+	data := bytes.Repeat([]byte("0123456789abcdef"), consoleCopyBufferSize/4)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	offset, err := copyConsole(&buf, f, 10, int64(len(data)))
+	if err != nil {
+		t.Fatalf("copyConsole() error = %v", err)
+	}
+	if offset != int64(len(data)) || !bytes.Equal(buf.Bytes(), data[10:]) {
+		t.Errorf("copyConsole() = offset %d and %d bytes, want offset %d and the log from byte 10", offset, buf.Len(), len(data))
+	}
+}
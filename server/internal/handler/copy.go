@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// maxCopyMemory is how much of a multipart upload is kept in memory; the
+// rest is spooled to temporary files.
+const maxCopyMemory = 32 << 20
+
+// UploadSessionFiles copies files into a directory of a session's workspace.
+// The multipart body carries either one or more "file" parts, each written
+// under its file name, or a single "archive" part holding a tar archive to
+// extract. The directory is created if needed.
+// PUT /api/projects/{projectId}/sessions/{sessionId}/files/upload?path=
+func (h *Handler) UploadSessionFiles(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	dest, err := sandbox.WorkspacePath(r.URL.Query().Get("path"))
+	if err != nil {
+		h.Error(w, http.StatusBadRequest, "path must be inside the workspace")
+		return
+	}
+
+	session, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || session.ProjectID != projectID {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, int64(h.cfg.CopyUploadMaxMB)<<20)
+	if err := r.ParseMultipartForm(maxCopyMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.Error(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Upload exceeds %dMB", h.cfg.CopyUploadMaxMB))
+			return
+		}
+		h.Error(w, http.StatusBadRequest, "Invalid multipart body")
+		return
+	}
+	defer func() { _ = r.MultipartForm.RemoveAll() }()
+
+	files := r.MultipartForm.File["file"]
+	archives := r.MultipartForm.File["archive"]
+	var archive io.Reader
+	switch {
+	case len(archives) == 1 && len(files) == 0:
+		f, err := archives[0].Open()
+		if err != nil {
+			h.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		defer func() { _ = f.Close() }()
+		archive = f
+	case len(archives) == 0 && len(files) > 0:
+		for _, fh := range files {
+			if name := path.Base(fh.Filename); name == "." || name == ".." || name == "/" {
+				h.Error(w, http.StatusBadRequest, fmt.Sprintf("Invalid file name %q", fh.Filename))
+				return
+			}
+		}
+		pr, pw := io.Pipe()
+		defer func() { _ = pr.Close() }()
+		go func() { pw.CloseWithError(writeTar(pw, files)) }()
+		archive = pr
+	default:
+		h.Error(w, http.StatusBadRequest, "Send one or more file parts or a single archive part")
+		return
+	}
+
+	if err := h.sandboxService.CopyTo(ctx, sessionID, dest, archive); err != nil {
+		h.copyError(w, err)
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]any{"path": dest})
+}
+
+// DownloadSessionFile streams a file or directory of a session's workspace
+// as a tar archive.
+// GET /api/projects/{projectId}/sessions/{sessionId}/files/download?path=
+func (h *Handler) DownloadSessionFile(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	query := r.URL.Query().Get("path")
+	if query == "" {
+		h.Error(w, http.StatusBadRequest, "path query parameter required")
+		return
+	}
+	src, err := sandbox.WorkspacePath(query)
+	if err != nil {
+		h.Error(w, http.StatusBadRequest, "path must be inside the workspace")
+		return
+	}
+
+	session, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || session.ProjectID != projectID {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	archive, err := h.sandboxService.CopyFrom(ctx, sessionID, src)
+	if err != nil {
+		h.copyError(w, err)
+		return
+	}
+	defer func() { _ = archive.Close() }()
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(src)+".tar"))
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, archive)
+}
+
+// copyError writes the response for a failed workspace copy.
+func (h *Handler) copyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sandbox.ErrOutsideWorkspace):
+		h.Error(w, http.StatusBadRequest, "path must be inside the workspace")
+	case errors.Is(err, fs.ErrNotExist):
+		h.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, sandbox.ErrNotSupported):
+		h.Error(w, http.StatusNotImplemented, "Session's sandbox provider cannot copy files")
+	case errors.Is(err, sandbox.ErrNotFound):
+		h.Error(w, http.StatusConflict, "Session's sandbox does not exist")
+	default:
+		h.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// writeTar writes the uploaded files to a tar archive, each under its base
+// name.
+func writeTar(w io.Writer, files []*multipart.FileHeader) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	for _, fh := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     path.Base(fh.Filename),
+			Size:     fh.Size,
+			Mode:     0644,
+			ModTime:  now,
+		}); err != nil {
+			return err
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, f)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
@@ -17,8 +17,11 @@ import (
 type CreateCredentialRequest struct {
 	Provider string `json:"provider"`
 	Name     string `json:"name"`
-	AuthType string `json:"authType"` // "api_key" or "oauth"
+	AuthType string `json:"authType"` // "api_key", "oauth", or "registry"
 	APIKey   string `json:"apiKey,omitempty"`
+
+	// Registry is the package registry token for the "registry" auth type
+	Registry *service.RegistryCredential `json:"registry,omitempty"`
 }
 
 // ListCredentials returns all credentials for a project (safe info only)
@@ -53,7 +56,7 @@ func (h *Handler) CreateCredential(w http.ResponseWriter, r *http.Request) {
 		req.Name = req.Provider // Default name to provider
 	}
 
-	// Currently only support API key creation via this endpoint
+	// API keys and registry tokens are created via this endpoint
 	// OAuth tokens are set via the OAuth flow endpoints
 	if req.AuthType == "" || req.AuthType == service.AuthTypeAPIKey {
 		if req.APIKey == "" {
@@ -75,6 +78,29 @@ func (h *Handler) CreateCredential(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.AuthType == service.AuthTypeRegistry {
+		if req.Registry == nil {
+			h.Error(w, http.StatusBadRequest, "registry is required for registry auth type")
+			return
+		}
+
+		info, err := h.credentialService.SetRegistryCredential(r.Context(), projectID, req.Provider, req.Name, req.Registry)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidProvider):
+				h.Error(w, http.StatusBadRequest, "Invalid registry provider")
+			case errors.Is(err, service.ErrInvalidRegistryCredential):
+				h.Error(w, http.StatusBadRequest, err.Error())
+			default:
+				h.Error(w, http.StatusInternalServerError, "Failed to create credential")
+			}
+			return
+		}
+
+		h.JSON(w, http.StatusOK, info)
+		return
+	}
+
 	h.Error(w, http.StatusBadRequest, "OAuth credentials must be set via OAuth flow endpoints")
 }
 
@@ -373,6 +399,7 @@ func (h *Handler) GitHubCopilotPoll(w http.ResponseWriter, r *http.Request) {
 		AccessToken:  pollResp.AccessToken,
 		RefreshToken: pollResp.RefreshToken,
 		TokenType:    pollResp.TokenType,
+		ExpiresAt:    pollResp.ExpiresAt,
 		Scope:        pollResp.Scope,
 	}
 
@@ -1,25 +1,51 @@
 package handler
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httputil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/obot-platform/discobot/server/internal/sandbox"
 )
 
+// DebugDockerTokenHeader carries the debug Docker proxy token. The Docker CLI
+// sends it when configured via HttpHeaders in ~/.docker/config.json.
+const DebugDockerTokenHeader = "X-Discobot-Debug-Token"
+
+// debugDockerReadHeaderTimeout bounds how long a client may take to send request headers.
+const debugDockerReadHeaderTimeout = 10 * time.Second
+
+// DebugDockerOptions configures the debug Docker proxy server.
+type DebugDockerOptions struct {
+	Port        int           // Loopback port to listen on
+	Token       string        // Required on every request (generated if empty)
+	MaxConns    int           // Max concurrent client connections (0 = unlimited)
+	IdleTimeout time.Duration // Close connections with no traffic for this long (0 = never)
+}
+
 // DebugDockerServer runs a standalone HTTP server that proxies Docker API requests
-// to the Docker daemon inside a VZ VM. This allows using standard Docker CLI:
+// to the Docker daemon inside a VZ VM. It listens on loopback only and requires
+// a token, so the standard Docker CLI needs the token header configured:
 //
+//	{"HttpHeaders": {"X-Discobot-Debug-Token": "<token>"}}
 //	DOCKER_HOST=tcp://localhost:2375 docker ps
 type DebugDockerServer struct {
 	server    *http.Server
 	projectID string
+	opts      DebugDockerOptions
 }
 
 // NewDebugDockerServer creates a new debug Docker proxy server for the given project.
-func NewDebugDockerServer(sandboxManager *sandbox.Manager, projectID string, port int) (*DebugDockerServer, error) {
+func NewDebugDockerServer(sandboxManager *sandbox.Manager, projectID string, opts DebugDockerOptions) (*DebugDockerServer, error) {
 	// Find a provider that supports Docker proxying
 	var proxyProvider sandbox.DockerProxyProvider
 	for _, name := range sandboxManager.ListProviders() {
@@ -37,11 +63,16 @@ func NewDebugDockerServer(sandboxManager *sandbox.Manager, projectID string, por
 		return nil, fmt.Errorf("no provider supports Docker proxying")
 	}
 
+	if opts.Token == "" {
+		opts.Token = rand.Text()
+	}
+
 	proxy := &httputil.ReverseProxy{
 		Director: func(req *http.Request) {
 			req.URL.Scheme = "http"
 			req.URL.Host = "localhost"
 			req.Host = "localhost"
+			req.Header.Del(DebugDockerTokenHeader)
 		},
 		Transport: &debugDockerTransport{
 			provider:  proxyProvider,
@@ -55,29 +86,162 @@ func NewDebugDockerServer(sandboxManager *sandbox.Manager, projectID string, por
 
 	return &DebugDockerServer{
 		projectID: projectID,
+		opts:      opts,
 		server: &http.Server{
-			Addr:    fmt.Sprintf(":%d", port),
-			Handler: proxy,
+			Addr:              net.JoinHostPort("127.0.0.1", fmt.Sprint(opts.Port)),
+			Handler:           armDebugDockerConn(requireDebugDockerToken(opts.Token, proxy)),
+			ReadHeaderTimeout: debugDockerReadHeaderTimeout,
+			IdleTimeout:       opts.IdleTimeout,
+			ConnContext:       withDebugDockerConn,
+			ConnState:         disarmIdleDebugDockerConn,
 		},
 	}, nil
 }
 
 // Start starts the debug Docker proxy server in the background.
-func (s *DebugDockerServer) Start() {
+func (s *DebugDockerServer) Start() error {
+	ln, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.server.Addr, err)
+	}
+	ln = newDebugDockerListener(ln, s.opts.MaxConns, s.opts.IdleTimeout)
+
+	log.Printf("Debug Docker proxy listening on %s (project: %s, max connections: %d, idle timeout: %s)",
+		s.server.Addr, s.projectID, s.opts.MaxConns, s.opts.IdleTimeout)
+	log.Printf("  Usage: add {\"HttpHeaders\": {%q: %q}} to ~/.docker/config.json, then", DebugDockerTokenHeader, s.opts.Token)
+	log.Printf("         DOCKER_HOST=tcp://%s docker ps", s.server.Addr)
 	go func() {
-		log.Printf("Debug Docker proxy listening on %s (project: %s)", s.server.Addr, s.projectID)
-		log.Printf("  Usage: DOCKER_HOST=tcp://localhost%s docker ps", s.server.Addr)
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := s.server.Serve(ln); err != nil && err != http.ErrServerClosed {
 			log.Printf("Debug Docker proxy error: %v", err)
 		}
 	}()
+	return nil
 }
 
-// Stop stops the debug Docker proxy server.
+// Stop stops the debug Docker proxy server, closing all client connections.
 func (s *DebugDockerServer) Stop() {
 	_ = s.server.Close()
 }
 
+// requireDebugDockerToken rejects requests that don't carry the proxy token,
+// either in DebugDockerTokenHeader or as a bearer token.
+func requireDebugDockerToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.Header.Get(DebugDockerTokenHeader)
+		if got == "" {
+			got, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "invalid or missing debug Docker token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// debugDockerConnKey is the request context key for the request's
+// *debugDockerConn.
+type debugDockerConnKey struct{}
+
+func withDebugDockerConn(ctx context.Context, c net.Conn) context.Context {
+	if dc, ok := c.(*debugDockerConn); ok {
+		return context.WithValue(ctx, debugDockerConnKey{}, dc)
+	}
+	return ctx
+}
+
+// armDebugDockerConn starts the idle timeout on the request's connection once
+// its headers have been read. Until then the server's own ReadHeaderTimeout
+// and IdleTimeout deadlines apply.
+func armDebugDockerConn(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if dc, ok := r.Context().Value(debugDockerConnKey{}).(*debugDockerConn); ok {
+			dc.armed.Store(true)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// disarmIdleDebugDockerConn stops the idle timeout when a keep-alive
+// connection goes back to waiting for its next request's headers.
+func disarmIdleDebugDockerConn(c net.Conn, state http.ConnState) {
+	if dc, ok := c.(*debugDockerConn); ok && state == http.StateIdle {
+		dc.armed.Store(false)
+	}
+}
+
+// debugDockerListener limits concurrent connections and applies an idle
+// timeout to each one. When the limit is reached, Accept blocks until a
+// connection closes, leaving further clients queued in the kernel backlog.
+type debugDockerListener struct {
+	net.Listener
+	sem         chan struct{}
+	idleTimeout time.Duration
+}
+
+func newDebugDockerListener(ln net.Listener, maxConns int, idleTimeout time.Duration) net.Listener {
+	l := &debugDockerListener{Listener: ln, idleTimeout: idleTimeout}
+	if maxConns > 0 {
+		l.sem = make(chan struct{}, maxConns)
+	}
+	return l
+}
+
+func (l *debugDockerListener) Accept() (net.Conn, error) {
+	if l.sem != nil {
+		l.sem <- struct{}{}
+	}
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		l.release()
+		return nil, err
+	}
+	return &debugDockerConn{Conn: conn, idleTimeout: l.idleTimeout, release: l.release}, nil
+}
+
+func (l *debugDockerListener) release() {
+	if l.sem != nil {
+		<-l.sem
+	}
+}
+
+// debugDockerConn extends its deadline on every read and write while a
+// request is being served, so it is closed only after IdleTimeout with no
+// traffic in either direction. While the server is reading request headers
+// it is left alone, so ReadHeaderTimeout still applies. This also
+// covers hijacked connections (docker attach/exec), where the reverse proxy
+// copies in both directions until one side fails: an idle or hung peer now
+// times out, which closes both sides instead of leaking the copy goroutines.
+type debugDockerConn struct {
+	net.Conn
+	idleTimeout time.Duration
+	release     func()
+	closeOnce   sync.Once
+	armed       atomic.Bool // set while a request is being served (see armDebugDockerConn)
+}
+
+func (c *debugDockerConn) Read(b []byte) (int, error) {
+	c.extendDeadline()
+	return c.Conn.Read(b)
+}
+
+func (c *debugDockerConn) Write(b []byte) (int, error) {
+	c.extendDeadline()
+	return c.Conn.Write(b)
+}
+
+func (c *debugDockerConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(c.release)
+	return err
+}
+
+func (c *debugDockerConn) extendDeadline() {
+	if c.idleTimeout > 0 && c.armed.Load() {
+		_ = c.Conn.SetDeadline(time.Now().Add(c.idleTimeout))
+	}
+}
+
 // debugDockerTransport lazily resolves the Docker transport for the project VM.
 // This allows the proxy to start before the VM is ready (e.g., during image download).
 type debugDockerTransport struct {
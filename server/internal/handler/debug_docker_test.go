@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRequireDebugDockerToken(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	h := requireDebugDockerToken("secret", next)
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{name: "missing", want: http.StatusUnauthorized},
+		{name: "wrong token", header: DebugDockerTokenHeader, value: "nope", want: http.StatusUnauthorized},
+		{name: "token header", header: DebugDockerTokenHeader, value: "secret", want: http.StatusOK},
+		{name: "bearer token", header: "Authorization", value: "Bearer secret", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/containers/json", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
+
+func TestDebugDockerListener_LimitsAndIdleTimeout(t *testing.T) {
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	ln := newDebugDockerListener(inner, 1, 50*time.Millisecond)
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	for range 2 {
+		client, err := net.Dial("tcp", inner.Addr().String())
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		defer client.Close()
+	}
+
+	first := <-accepted
+	select {
+	case <-accepted:
+		t.Fatal("second connection accepted while at the limit")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The idle first connection times out instead of blocking forever
+	first.(*debugDockerConn).armed.Store(true)
+	if _, err := first.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expected idle read to fail")
+	}
+	_ = first.Close()
+
+	select {
+	case conn := <-accepted:
+		_ = conn.Close()
+	case <-time.After(time.Second):
+		t.Fatal("second connection not accepted after the first closed")
+	}
+}
+
+func TestDebugDockerConn_KeepsServerDeadlineUntilArmed(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	conn := &debugDockerConn{Conn: server, idleTimeout: time.Hour, release: func() {}}
+	defer conn.Close()
+
+	// While headers are read, a deadline set by the server (ReadHeaderTimeout)
+	// isn't pushed back by the idle timeout
+	_ = conn.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected the server's read deadline to expire, got %v", err)
+	}
+
+	// Once armed, each read extends the deadline by the idle timeout
+	conn.armed.Store(true)
+	go func() { _, _ = client.Write([]byte("x")) }()
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		t.Fatalf("armed read failed: %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package handler
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
@@ -15,7 +16,14 @@ import (
 //   - since: RFC3339 timestamp to get events after (e.g., "2024-01-15T10:30:00Z")
 //   - after: Event ID to get events after (alternative to since)
 //
-// If neither is provided, only new events from the time of connection are streamed.
+// If neither is provided, the Last-Event-ID header (sent by browsers when an
+// EventSource reconnects on its own) is used like "after". Otherwise only new
+// events from the time of connection are streamed.
+//
+// Streams older than SSE_MAX_LIFETIME, or that delivered nothing for
+// SSE_IDLE_TIMEOUT, get a "reconnect" event and are closed. Its data holds
+// the last event ID sent ("after", if any) and the close time ("since"), so
+// the client can resume without missing events.
 func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
 	projectID := chi.URLParam(r, "projectId")
 	if projectID == "" {
@@ -33,6 +41,9 @@ func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
 	sinceStr := r.URL.Query().Get("since")
 	afterID := r.URL.Query().Get("after")
+	if afterID == "" && sinceStr == "" {
+		afterID = r.Header.Get("Last-Event-ID")
+	}
 
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
@@ -51,6 +62,7 @@ func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
 
 	// Track sent event IDs to avoid duplicates between history and live events
 	sentEventIDs := make(map[string]bool)
+	lastEventID := afterID
 
 	// Send historical events if requested
 	if afterID != "" {
@@ -65,8 +77,9 @@ func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
 				if err != nil {
 					continue
 				}
-				_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+				writeSSEEvent(w, event.ID, string(event.Type), data)
 				sentEventIDs[event.ID] = true
+				lastEventID = event.ID
 			}
 			flusher.Flush()
 		}
@@ -95,20 +108,39 @@ func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
 					if err != nil {
 						continue
 					}
-					_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+					writeSSEEvent(w, event.ID, string(event.Type), data)
 					sentEventIDs[event.ID] = true
+					lastEventID = event.ID
 				}
 				flusher.Flush()
 			}
 		}
 	}
 
-	// Stream new events until client disconnects
+	// Stream new events until client disconnects or the stream is recycled
+	lifetime := newSSELifetime(h.cfg.SSEMaxLifetime, h.cfg.SSEIdleTimeout, time.Now())
+	var check <-chan time.Time
+	if lifetime.enabled() {
+		ticker := time.NewTicker(sseLifetimeCheckInterval)
+		defer ticker.Stop()
+		check = ticker.C
+	}
+
 	for {
 		select {
 		case <-r.Context().Done():
 			// Client disconnected
 			return
+		case now := <-check:
+			if lifetime.expired(now) {
+				data, _ := json.Marshal(map[string]string{
+					"after": lastEventID,
+					"since": now.UTC().Format(time.RFC3339Nano),
+				})
+				writeSSEEvent(w, "", "reconnect", data)
+				flusher.Flush()
+				return
+			}
 		case event, ok := <-sub.Events:
 			if !ok {
 				// Channel closed
@@ -127,9 +159,60 @@ func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
-			// Write SSE format: event: <type>\ndata: <json>\n\n
-			_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			writeSSEEvent(w, event.ID, string(event.Type), data)
 			flusher.Flush()
+			lastEventID = event.ID
+			lifetime.delivered(time.Now())
 		}
 	}
 }
+
+// writeSSEEvent writes one event in SSE format. The id line lets browsers
+// send Last-Event-ID when an EventSource reconnects on its own.
+func writeSSEEvent(w io.Writer, id, eventType string, data []byte) {
+	if id != "" {
+		_, _ = fmt.Fprintf(w, "id: %s\n", id)
+	}
+	_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, data)
+}
+
+const (
+	// sseLifetimeCheckInterval is how often streams check their limits.
+	sseLifetimeCheckInterval = 5 * time.Second
+
+	// sseQuietPeriod is how long a stream must go without events before it
+	// is recycled for reaching its max lifetime, so bursts aren't cut off.
+	sseQuietPeriod = 5 * time.Second
+)
+
+// sseLifetime decides when an event stream should be recycled.
+type sseLifetime struct {
+	maxLifetime time.Duration
+	idleTimeout time.Duration
+	started     time.Time
+	lastEvent   time.Time
+}
+
+func newSSELifetime(maxLifetime, idleTimeout time.Duration, now time.Time) *sseLifetime {
+	return &sseLifetime{maxLifetime: maxLifetime, idleTimeout: idleTimeout, started: now, lastEvent: now}
+}
+
+func (l *sseLifetime) enabled() bool {
+	return l.maxLifetime > 0 || l.idleTimeout > 0
+}
+
+// delivered records that an event was sent.
+func (l *sseLifetime) delivered(now time.Time) {
+	l.lastEvent = now
+}
+
+// expired reports whether the stream should be closed: it has delivered
+// nothing for the idle timeout, or it is past its max lifetime and has been
+// quiet long enough not to interrupt a burst of events.
+func (l *sseLifetime) expired(now time.Time) bool {
+	quiet := now.Sub(l.lastEvent)
+	if l.idleTimeout > 0 && quiet >= l.idleTimeout {
+		return true
+	}
+	return l.maxLifetime > 0 && now.Sub(l.started) >= l.maxLifetime && quiet >= sseQuietPeriod
+}
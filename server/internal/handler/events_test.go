@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestSSELifetimeExpired(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		maxLifetime time.Duration
+		idleTimeout time.Duration
+		lastEvent   time.Duration // after start
+		now         time.Duration // after start
+		want        bool
+	}{
+		{name: "disabled", now: 48 * time.Hour},
+		{name: "within lifetime", maxLifetime: time.Hour, now: 30 * time.Minute},
+		{name: "past lifetime and quiet", maxLifetime: time.Hour, lastEvent: 50 * time.Minute, now: time.Hour, want: true},
+		{name: "past lifetime but active", maxLifetime: time.Hour, lastEvent: time.Hour - time.Second, now: time.Hour},
+		{name: "idle", idleTimeout: 10 * time.Minute, now: 10 * time.Minute, want: true},
+		{name: "recent event resets idle", idleTimeout: 10 * time.Minute, lastEvent: 5 * time.Minute, now: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newSSELifetime(tt.maxLifetime, tt.idleTimeout, start)
+			if tt.lastEvent > 0 {
+				l.delivered(start.Add(tt.lastEvent))
+			}
+			if got := l.expired(start.Add(tt.now)); got != tt.want {
+				t.Errorf("expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteSSEEvent(t *testing.T) {
+	var buf bytes.Buffer
+	writeSSEEvent(&buf, "evt-1", "session_updated", []byte(`{"id":"evt-1"}`))
+	writeSSEEvent(&buf, "", "reconnect", []byte(`{}`))
+
+	want := "id: evt-1\nevent: session_updated\ndata: {\"id\":\"evt-1\"}\n\nevent: reconnect\ndata: {}\n\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -228,6 +228,7 @@ func (h *Handler) ReadSessionFile(w http.ResponseWriter, r *http.Request) {
 
 // WriteSessionFile writes a file to a session's workspace.
 // PUT /api/projects/{projectId}/sessions/{sessionId}/files/write
+// If expectedHash is set, the write is rejected with 409 unless the file is unchanged.
 func (h *Handler) WriteSessionFile(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	projectID := middleware.GetProjectID(ctx)
@@ -251,6 +252,9 @@ func (h *Handler) WriteSessionFile(w http.ResponseWriter, r *http.Request) {
 
 	result, err := h.chatService.WriteFile(ctx, projectID, sessionID, &req)
 	if err != nil {
+		if h.fileConflict(w, err) {
+			return
+		}
 		status := http.StatusInternalServerError
 		if strings.Contains(err.Error(), "Invalid path") {
 			status = http.StatusBadRequest
@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/obot-platform/discobot/server/internal/git"
+	"github.com/obot-platform/discobot/server/internal/service"
 )
 
 // GetWorkspaceGitStatus returns the git status for a workspace
@@ -41,12 +43,19 @@ func (h *Handler) FetchWorkspace(w http.ResponseWriter, r *http.Request) {
 
 	workspaceID := chi.URLParam(r, "workspaceId")
 
-	if err := h.gitService.Fetch(r.Context(), workspaceID); err != nil {
+	conflict, err := h.gitService.Fetch(r.Context(), workspaceID)
+	if err != nil {
 		h.Error(w, http.StatusInternalServerError, "Failed to fetch: "+err.Error())
 		return
 	}
 
-	h.JSON(w, http.StatusOK, map[string]bool{"success": true})
+	// The fetch succeeded either way; a diverged branch is reported for the
+	// UI to offer a rebase or merge
+	resp := map[string]any{"success": true}
+	if conflict != nil {
+		resp["conflict"] = conflict
+	}
+	h.JSON(w, http.StatusOK, resp)
 }
 
 // CheckoutWorkspace checks out a specific ref in a workspace
@@ -67,6 +76,9 @@ func (h *Handler) CheckoutWorkspace(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.gitService.Checkout(r.Context(), workspaceID, req.Ref); err != nil {
+		if h.gitConflict(w, err) {
+			return
+		}
 		h.Error(w, http.StatusInternalServerError, "Failed to checkout: "+err.Error())
 		return
 	}
@@ -74,6 +86,20 @@ func (h *Handler) CheckoutWorkspace(w http.ResponseWriter, r *http.Request) {
 	h.JSON(w, http.StatusOK, map[string]bool{"success": true})
 }
 
+// gitConflict writes a 409 response if err is a *git.ConflictError, with the
+// reason, conflicting files and suggested resolutions for the UI to offer.
+func (h *Handler) gitConflict(w http.ResponseWriter, err error) bool {
+	var conflict *git.ConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+	h.JSON(w, http.StatusConflict, map[string]any{
+		"error":    conflict.Error(),
+		"conflict": conflict,
+	})
+	return true
+}
+
 // GetWorkspaceBranches returns all branches for a workspace
 func (h *Handler) GetWorkspaceBranches(w http.ResponseWriter, r *http.Request) {
 	if h.gitService == nil {
@@ -190,10 +216,12 @@ func (h *Handler) GetWorkspaceFileContent(w http.ResponseWriter, r *http.Request
 		"path":    path,
 		"ref":     ref,
 		"content": string(content),
+		"hash":    service.ContentHash(content),
 	})
 }
 
-// WriteWorkspaceFile writes content to a file in a workspace
+// WriteWorkspaceFile writes content to a file in a workspace.
+// If expectedHash is set, the write is rejected with 409 unless the file is unchanged.
 func (h *Handler) WriteWorkspaceFile(w http.ResponseWriter, r *http.Request) {
 	if h.gitService == nil {
 		h.Error(w, http.StatusServiceUnavailable, "Git service not configured")
@@ -203,8 +231,9 @@ func (h *Handler) WriteWorkspaceFile(w http.ResponseWriter, r *http.Request) {
 	workspaceID := chi.URLParam(r, "workspaceId")
 
 	var req struct {
-		Path    string `json:"path"`
-		Content string `json:"content"`
+		Path         string `json:"path"`
+		Content      string `json:"content"`
+		ExpectedHash string `json:"expectedHash"` // optional; write only if the file is unchanged
 	}
 	if err := h.DecodeJSON(r, &req); err != nil {
 		h.Error(w, http.StatusBadRequest, "Invalid request body")
@@ -222,12 +251,36 @@ func (h *Handler) WriteWorkspaceFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.gitService.WriteFile(r.Context(), workspaceID, req.Path, []byte(req.Content)); err != nil {
+	content := []byte(req.Content)
+	var err error
+	if req.ExpectedHash != "" {
+		err = h.gitService.WriteFileIfMatch(r.Context(), workspaceID, req.Path, content, req.ExpectedHash)
+	} else {
+		err = h.gitService.WriteFile(r.Context(), workspaceID, req.Path, content)
+	}
+	if err != nil {
+		if h.fileConflict(w, err) {
+			return
+		}
 		h.Error(w, http.StatusInternalServerError, "Failed to write file: "+err.Error())
 		return
 	}
 
-	h.JSON(w, http.StatusOK, map[string]bool{"success": true})
+	h.JSON(w, http.StatusOK, map[string]any{"success": true, "hash": service.ContentHash(content)})
+}
+
+// fileConflict writes a 409 response if err is a *service.FileConflictError,
+// including the file's current hash so the client can reload or merge.
+func (h *Handler) fileConflict(w http.ResponseWriter, err error) bool {
+	var conflict *service.FileConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+	h.JSON(w, http.StatusConflict, map[string]string{
+		"error":       conflict.Error(),
+		"currentHash": conflict.CurrentHash,
+	})
+	return true
 }
 
 // StageWorkspaceFiles stages files for commit
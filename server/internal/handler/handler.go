@@ -5,11 +5,13 @@ import (
 	"net/http"
 
 	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/dispatcher"
 	"github.com/obot-platform/discobot/server/internal/events"
 	"github.com/obot-platform/discobot/server/internal/git"
 	"github.com/obot-platform/discobot/server/internal/jobs"
 	"github.com/obot-platform/discobot/server/internal/sandbox"
 	"github.com/obot-platform/discobot/server/internal/service"
+	"github.com/obot-platform/discobot/server/internal/ssh"
 	"github.com/obot-platform/discobot/server/internal/startup"
 	"github.com/obot-platform/discobot/server/internal/store"
 )
@@ -41,6 +43,11 @@ type Handler struct {
 	eventBroker         *events.Broker
 	codexCallbackServer *CodexCallbackServer
 	systemManager       *startup.SystemManager
+	sshServer           *ssh.Server
+	terminalRecordings  *terminalRecordings
+	chatViewers         chatViewers
+	quiesce             *service.QuiesceState
+	dispatcher          *dispatcher.Service
 }
 
 // New creates a new Handler with the required git and sandbox providers.
@@ -50,6 +57,7 @@ func New(s *store.Store, cfg *config.Config, gitProvider git.Provider, sandboxPr
 		// This should only fail if the encryption key is invalid
 		panic("failed to create credential service: " + err.Error())
 	}
+	credSvc.SetEventBroker(eventBroker)
 
 	var gitSvc *service.GitService
 	if gitProvider != nil {
@@ -70,6 +78,12 @@ func New(s *store.Store, cfg *config.Config, gitProvider git.Provider, sandboxPr
 
 	// Create session service
 	sessionSvc := service.NewSessionService(s, gitSvc, sandboxProvider, sandboxSvc, eventBroker, jobQueue)
+	sessionSvc.SetStartupProbe(service.NewStartupProbe(cfg.SandboxStartupProbeSuccesses, cfg.SandboxStartupProbeInterval,
+		cfg.SandboxStartupTimeout, cfg.SandboxStartupMaxRestarts))
+	sessionSvc.SetNameTemplate(cfg.SessionNameTemplate)
+
+	quiesce := service.NewQuiesceState()
+	sessionSvc.SetQuiesceState(quiesce)
 
 	// Break circular dependency: SandboxService needs SessionInitializer (which is SessionService)
 	if sandboxSvc != nil {
@@ -78,6 +92,12 @@ func New(s *store.Store, cfg *config.Config, gitProvider git.Provider, sandboxPr
 
 	// Create chat service
 	chatSvc := service.NewChatService(s, sessionSvc, jobQueue, eventBroker, sandboxSvc, gitSvc)
+	historyPolicy, err := service.NewHistoryPolicy(cfg.ChatHistoryMaxMessages, cfg.ChatHistoryMaxTokens, cfg.ChatHistoryStrategy)
+	if err != nil {
+		// Config validation rejects unknown strategies, so this indicates a programming error
+		panic("failed to create chat history policy: " + err.Error())
+	}
+	chatSvc.SetHistoryPolicy(historyPolicy)
 
 	// Create remaining services
 	agentSvc := service.NewAgentService(s)
@@ -85,14 +105,9 @@ func New(s *store.Store, cfg *config.Config, gitProvider git.Provider, sandboxPr
 	projectSvc := service.NewProjectService(s, sandboxProvider)
 	preferenceSvc := service.NewPreferenceService(s)
 
-	// Convert agentTypes for models service
-	serviceAgentTypes := make([]service.AgentType, len(agentTypes))
-	for i, at := range agentTypes {
-		serviceAgentTypes[i] = service.AgentType{
-			ID:                     at.ID,
-			SupportedAuthProviders: at.SupportedAuthProviders,
-		}
-	}
+	serviceAgentTypes := ServiceAgentTypes()
+	agentSvc.SetAgentTypes(serviceAgentTypes)
+	sessionSvc.SetAgentTypes(serviceAgentTypes)
 	modelsSvc := service.NewModelsService(s, agentSvc, credSvc, sandboxSvc, serviceAgentTypes)
 
 	h := &Handler{
@@ -115,6 +130,9 @@ func New(s *store.Store, cfg *config.Config, gitProvider git.Provider, sandboxPr
 		jobQueue:          jobQueue,
 		eventBroker:       eventBroker,
 		systemManager:     systemManager,
+
+		terminalRecordings: &terminalRecordings{},
+		quiesce:            quiesce,
 	}
 
 	// Create Codex callback server (will be started on first use)
@@ -148,6 +166,12 @@ func (h *Handler) JobQueue() *jobs.Queue {
 	return h.jobQueue
 }
 
+// QuiesceState returns the handler's quiesce state, for sharing with the
+// dispatcher's session service.
+func (h *Handler) QuiesceState() *service.QuiesceState {
+	return h.quiesce
+}
+
 // EventBroker returns the handler's event broker for SSE.
 func (h *Handler) EventBroker() *events.Broker {
 	return h.eventBroker
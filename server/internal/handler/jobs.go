@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+
+	"github.com/obot-platform/discobot/server/internal/dispatcher"
+	"github.com/obot-platform/discobot/server/internal/middleware"
+)
+
+// JobLogMessage is a message sent over the job log WebSocket.
+type JobLogMessage struct {
+	Type    string `json:"type"`              // "log" or "done"
+	Time    string `json:"time,omitempty"`    // log: RFC 3339 timestamp
+	Message string `json:"message,omitempty"` // log: the line
+	Status  string `json:"status,omitempty"`  // done: the job's status after the run
+	Error   string `json:"error,omitempty"`   // done: the job's error, if it failed
+}
+
+// SetDispatcher sets the job dispatcher whose job logs are streamed by
+// StreamJobLogs. Called from main.go when the dispatcher is enabled.
+func (h *Handler) SetDispatcher(d *dispatcher.Service) {
+	h.dispatcher = d
+}
+
+// StreamJobLogs streams a job's log lines over a WebSocket: the lines logged
+// so far, then new lines as the job writes them, then a "done" message with
+// the job's status when the run ends. Only jobs run by this server have logs.
+// GET /api/jobs/{jobId}/logs
+func (h *Handler) StreamJobLogs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	jobID := chi.URLParam(r, "jobId")
+
+	// Jobs are visible to the members of their project
+	job, err := h.store.GetJobByID(ctx, jobID)
+	if err != nil || job.ProjectID == nil {
+		h.Error(w, http.StatusNotFound, "Job not found")
+		return
+	}
+	if _, err := h.projectService.GetMemberRole(ctx, *job.ProjectID, middleware.GetUserID(ctx)); err != nil {
+		h.Error(w, http.StatusNotFound, "Job not found")
+		return
+	}
+
+	if h.dispatcher == nil {
+		h.Error(w, http.StatusServiceUnavailable, "job dispatcher not enabled")
+		return
+	}
+
+	backlog, lines, cancel := h.dispatcher.SubscribeLogs(jobID)
+	defer cancel()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade job log connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// Read until the client goes away, so streaming stops with it
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, line := range backlog {
+		if err := conn.WriteJSON(jobLogLineMessage(line)); err != nil {
+			return
+		}
+	}
+
+	for {
+		select {
+		case <-closed:
+			return
+		case line, ok := <-lines:
+			if !ok {
+				done := JobLogMessage{Type: "done"}
+				if job, err := h.store.GetJobByID(context.WithoutCancel(ctx), jobID); err == nil {
+					done.Status = job.Status
+					if job.Error != nil {
+						done.Error = *job.Error
+					}
+				}
+				_ = conn.WriteJSON(done)
+				_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := conn.WriteJSON(jobLogLineMessage(line)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// jobLogLineMessage converts a job log line to a WebSocket message.
+func jobLogLineMessage(line dispatcher.LogLine) JobLogMessage {
+	return JobLogMessage{Type: "log", Time: line.Time.Format(time.RFC3339Nano), Message: line.Message}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/sandbox/sandboxapi"
+)
+
+// kvKeyPattern matches the keys accepted by the agent API's key-value store.
+var kvKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// GetSessionKV reads a value from a session's key-value store.
+// GET /api/projects/{projectId}/sessions/{sessionId}/kv/{key}
+func (h *Handler) GetSessionKV(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+	key := chi.URLParam(r, "key")
+
+	if !kvKeyPattern.MatchString(key) {
+		h.Error(w, http.StatusBadRequest, "Invalid key")
+		return
+	}
+
+	result, err := h.chatService.GetKV(ctx, projectID, sessionID, key)
+	if err != nil {
+		h.Error(w, kvErrorStatus(err), err.Error())
+		return
+	}
+
+	h.JSON(w, http.StatusOK, result)
+}
+
+// PutSessionKV creates or replaces a value in a session's key-value store.
+// PUT /api/projects/{projectId}/sessions/{sessionId}/kv/{key}
+func (h *Handler) PutSessionKV(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+	key := chi.URLParam(r, "key")
+
+	if !kvKeyPattern.MatchString(key) {
+		h.Error(w, http.StatusBadRequest, "Invalid key")
+		return
+	}
+
+	var req sandboxapi.PutKVRequest
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	result, err := h.chatService.PutKV(ctx, projectID, sessionID, key, &req)
+	if err != nil {
+		h.Error(w, kvErrorStatus(err), err.Error())
+		return
+	}
+
+	h.JSON(w, http.StatusOK, result)
+}
+
+// DeleteSessionKV deletes a value from a session's key-value store.
+// DELETE /api/projects/{projectId}/sessions/{sessionId}/kv/{key}
+func (h *Handler) DeleteSessionKV(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+	key := chi.URLParam(r, "key")
+
+	if !kvKeyPattern.MatchString(key) {
+		h.Error(w, http.StatusBadRequest, "Invalid key")
+		return
+	}
+
+	result, err := h.chatService.DeleteKV(ctx, projectID, sessionID, key)
+	if err != nil {
+		h.Error(w, kvErrorStatus(err), err.Error())
+		return
+	}
+
+	h.JSON(w, http.StatusOK, result)
+}
+
+// kvErrorStatus maps key-value store errors relayed from the sandbox to HTTP statuses.
+func kvErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "status 413"):
+		return http.StatusRequestEntityTooLarge
+	case strings.Contains(msg, "status 400"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
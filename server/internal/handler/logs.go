@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// logsReadBufferSize is the size of each chunk read from the sandbox log stream.
+const logsReadBufferSize = 32 * 1024
+
+// GetSessionLogs streams the output of a session's sandbox as chunked plain
+// text. Like the console, this works without the agent API, so it can be used
+// to tail agent startup output or diagnose a sandbox that failed to start.
+// GET /api/projects/{projectId}/sessions/{sessionId}/logs?tail=100&since=2024-01-02T15:04:05Z&follow=true&strip=true
+func (h *Handler) GetSessionLogs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+
+	var opts sandbox.LogOptions
+	query := r.URL.Query()
+	if v := query.Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			h.Error(w, http.StatusBadRequest, "tail must be a non-negative integer")
+			return
+		}
+		opts.Tail = n
+	}
+	if v := query.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			h.Error(w, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+			return
+		}
+		opts.Since = since
+	}
+	opts.Follow = query.Get("follow") == "true"
+	strip := query.Get("strip") == "true"
+
+	session, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || session.ProjectID != projectID {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	logs, err := h.sandboxService.Logs(ctx, sessionID, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, sandbox.ErrNotSupported):
+			h.Error(w, http.StatusNotImplemented, "Session's sandbox provider cannot stream logs")
+		case errors.Is(err, sandbox.ErrNotFound):
+			h.Error(w, http.StatusConflict, "Session's sandbox does not exist")
+		default:
+			h.Error(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	defer logs.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	w.WriteHeader(http.StatusOK)
+
+	var out io.Writer = w
+	if strip {
+		out = newControlStripper(w)
+	}
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, logsReadBufferSize)
+	for {
+		n, err := logs.Read(buf)
+		if n > 0 {
+			if _, werr := out.Write(buf[:n]); werr != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
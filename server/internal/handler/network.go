@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/sandbox/sandboxapi"
+)
+
+// maxNetworkTestTimeoutMs matches the agent API's per-step timeout limit.
+const maxNetworkTestTimeoutMs = 30000
+
+// TestSessionNetwork checks whether a session's sandbox can reach a URL or
+// host, reporting DNS, connection, proxy, TLS, and HTTP results.
+// POST /api/projects/{projectId}/sessions/{sessionId}/network/test
+func (h *Handler) TestSessionNetwork(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+
+	var req sandboxapi.NetworkTestRequest
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Target = strings.TrimSpace(req.Target)
+	if req.Target == "" {
+		h.Error(w, http.StatusBadRequest, "target is required")
+		return
+	}
+	if req.TimeoutMs < 0 || req.TimeoutMs > maxNetworkTestTimeoutMs {
+		h.Error(w, http.StatusBadRequest, "timeoutMs must be between 0 and 30000")
+		return
+	}
+
+	result, err := h.chatService.TestNetwork(ctx, projectID, sessionID, &req)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "not found"):
+			h.Error(w, http.StatusNotFound, msg)
+		case strings.Contains(msg, "status 400"):
+			h.Error(w, http.StatusBadRequest, msg)
+		default:
+			h.Error(w, http.StatusInternalServerError, msg)
+		}
+		return
+	}
+
+	h.JSON(w, http.StatusOK, result)
+}
+
+// GetSessionProxyStats returns per-domain request counts, bytes transferred,
+// and cache hit rates from the session's sandbox proxy, for auditing what the
+// agent accessed.
+// GET /api/projects/{projectId}/sessions/{sessionId}/proxy/stats
+func (h *Handler) GetSessionProxyStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+
+	result, err := h.chatService.GetProxyStats(ctx, projectID, sessionID)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "not found"):
+			h.Error(w, http.StatusNotFound, msg)
+		case strings.Contains(msg, "status 503"):
+			h.Error(w, http.StatusServiceUnavailable, "The sandbox proxy is not running")
+		default:
+			h.Error(w, http.StatusInternalServerError, msg)
+		}
+		return
+	}
+
+	h.JSON(w, http.StatusOK, result)
+}
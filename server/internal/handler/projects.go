@@ -2,11 +2,19 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/go-containerregistry/pkg/name"
 
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/jobs"
 	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/service"
+	"github.com/obot-platform/discobot/server/internal/store"
 )
 
 // ListProjects returns all projects for the current user
@@ -26,7 +34,9 @@ func (h *Handler) ListProjects(w http.ResponseWriter, r *http.Request) {
 	h.JSON(w, http.StatusOK, projects)
 }
 
-// CreateProject creates a new project
+// CreateProject creates a new project. The project can be seeded with an
+// initial workspace (defaultWorkspace) and/or the workspaces and agents of a
+// configured project template. If seeding fails, the project is deleted.
 func (h *Handler) CreateProject(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r.Context())
 	if userID == "" {
@@ -35,7 +45,9 @@ func (h *Handler) CreateProject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Name string `json:"name"`
+		Name             string                           `json:"name"`
+		Template         string                           `json:"template"`
+		DefaultWorkspace *config.ProjectTemplateWorkspace `json:"defaultWorkspace"`
 	}
 	if err := h.DecodeJSON(r, &req); err != nil {
 		h.Error(w, http.StatusBadRequest, "Invalid request body")
@@ -46,13 +58,100 @@ func (h *Handler) CreateProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var workspaces []config.ProjectTemplateWorkspace
+	var agents []string
+	if req.Template != "" {
+		template := h.cfg.ProjectTemplate(req.Template)
+		if template == nil {
+			h.Error(w, http.StatusBadRequest, "Unknown project template: "+req.Template)
+			return
+		}
+		workspaces = append(workspaces, template.Workspaces...)
+		agents = template.Agents
+	}
+	if req.DefaultWorkspace != nil {
+		if req.DefaultWorkspace.Path == "" {
+			h.Error(w, http.StatusBadRequest, "defaultWorkspace.path is required")
+			return
+		}
+		if req.DefaultWorkspace.SourceType == "" {
+			req.DefaultWorkspace.SourceType = "local"
+		}
+		workspaces = append([]config.ProjectTemplateWorkspace{*req.DefaultWorkspace}, workspaces...)
+	}
+	for _, agentType := range agents {
+		if !agentTypeEnabled(agentType) {
+			h.Error(w, http.StatusBadRequest, "Project template uses unknown agent type: "+agentType)
+			return
+		}
+	}
+
 	project, err := h.projectService.CreateProject(r.Context(), userID, req.Name)
 	if err != nil {
 		h.Error(w, http.StatusInternalServerError, "Failed to create project")
 		return
 	}
 
-	h.JSON(w, http.StatusCreated, project)
+	created, err := h.seedProject(r.Context(), project.ID, workspaces, agents)
+	if err != nil {
+		if delErr := h.projectService.DeleteProject(context.WithoutCancel(r.Context()), project.ID); delErr != nil {
+			log.Printf("Failed to delete project %s after seeding failed: %v", project.ID, delErr)
+		}
+		h.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.JSON(w, http.StatusCreated, struct {
+		*service.Project
+		Workspaces []*service.Workspace `json:"workspaces,omitempty"`
+	}{project, created})
+}
+
+// seedProject creates the given workspaces and agents in a new project and
+// enqueues the workspaces' initialization. The first agent is the default.
+func (h *Handler) seedProject(ctx context.Context, projectID string, workspaces []config.ProjectTemplateWorkspace, agents []string) ([]*service.Workspace, error) {
+	var created []*service.Workspace
+	for _, ws := range workspaces {
+		workspace, err := h.workspaceService.CreateWorkspace(ctx, projectID, ws.Path, ws.SourceType, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create workspace %s: %w", ws.Path, err)
+		}
+		if ws.DisplayName != "" {
+			modelWorkspace, err := h.store.GetWorkspaceByID(ctx, workspace.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get workspace %s: %w", ws.Path, err)
+			}
+			modelWorkspace.DisplayName = &ws.DisplayName
+			if err := h.store.UpdateWorkspace(ctx, modelWorkspace); err != nil {
+				return nil, fmt.Errorf("failed to update workspace %s: %w", ws.Path, err)
+			}
+			workspace.DisplayName = &ws.DisplayName
+		}
+		created = append(created, workspace)
+	}
+
+	for _, agentType := range agents {
+		if _, err := h.agentService.CreateAgent(ctx, projectID, agentType); err != nil {
+			return nil, fmt.Errorf("failed to create %s agent: %w", agentType, err)
+		}
+	}
+
+	// Initialize workspaces only once the whole project exists
+	for _, workspace := range created {
+		if err := h.jobQueue.Enqueue(ctx, jobs.WorkspaceInitPayload{ProjectID: projectID, WorkspaceID: workspace.ID}); err != nil {
+			return nil, fmt.Errorf("failed to enqueue workspace initialization: %w", err)
+		}
+	}
+	return created, nil
+}
+
+// ListProjectTemplates returns the configured project templates
+func (h *Handler) ListProjectTemplates(w http.ResponseWriter, _ *http.Request) {
+	templates := h.cfg.ProjectTemplates
+	if templates == nil {
+		templates = []config.ProjectTemplate{}
+	}
+	h.JSON(w, http.StatusOK, map[string]any{"templates": templates})
 }
 
 // GetProject returns a single project
@@ -81,15 +180,39 @@ func (h *Handler) UpdateProject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Name string `json:"name"`
+		Name          *string           `json:"name"`
+		SandboxLabels map[string]string `json:"sandboxLabels"`
+		SkipGitHooks  *bool             `json:"skipGitHooks"`
+		SandboxImage  *string           `json:"sandboxImage"`
 	}
 	if err := h.DecodeJSON(r, &req); err != nil {
 		h.Error(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	// Omitting name leaves it unchanged, but a project can't be renamed to nothing
+	if req.Name != nil && *req.Name == "" {
+		h.Error(w, http.StatusBadRequest, "Name is required")
+		return
+	}
+	if req.SandboxImage != nil && *req.SandboxImage != "" {
+		if _, err := name.ParseReference(*req.SandboxImage); err != nil {
+			h.Error(w, http.StatusBadRequest, "sandboxImage must be an image reference such as ghcr.io/org/sandbox:cuda")
+			return
+		}
+		// Projects can't pick arbitrary images: the server runs them with
+		// the sandbox's privileges
+		if !service.ProjectImageAllowed(h.cfg, projectID, *req.SandboxImage) {
+			h.Error(w, http.StatusBadRequest, "sandboxImage is not in SANDBOX_ALLOWED_IMAGES")
+			return
+		}
+	}
 
-	project, err := h.projectService.UpdateProject(r.Context(), projectID, req.Name)
+	project, err := h.projectService.UpdateProject(r.Context(), projectID, req.Name, req.SandboxLabels, req.SkipGitHooks, req.SandboxImage)
 	if err != nil {
+		if errors.Is(err, service.ErrReservedSandboxLabel) {
+			h.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		h.Error(w, http.StatusInternalServerError, "Failed to update project")
 		return
 	}
@@ -117,6 +240,41 @@ func (h *Handler) DeleteProject(w http.ResponseWriter, r *http.Request) {
 	h.JSON(w, http.StatusOK, map[string]bool{"success": true})
 }
 
+// FreezeProject freezes a project for maintenance. Its queued jobs stay
+// pending and mutating requests are rejected until it is unfrozen.
+// POST /api/projects/{projectId}/freeze
+func (h *Handler) FreezeProject(w http.ResponseWriter, r *http.Request) {
+	h.setProjectFrozen(w, r, true)
+}
+
+// UnfreezeProject unfreezes a project, resuming its queued jobs.
+// DELETE /api/projects/{projectId}/freeze
+func (h *Handler) UnfreezeProject(w http.ResponseWriter, r *http.Request) {
+	h.setProjectFrozen(w, r, false)
+}
+
+func (h *Handler) setProjectFrozen(w http.ResponseWriter, r *http.Request, frozen bool) {
+	if !middleware.IsProjectAdmin(r.Context()) {
+		h.Error(w, http.StatusForbidden, "Admin access required")
+		return
+	}
+
+	project, err := h.projectService.SetFrozen(r.Context(), chi.URLParam(r, "projectId"), frozen)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.Error(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		h.Error(w, http.StatusInternalServerError, "Failed to update project")
+		return
+	}
+
+	if !frozen && h.jobQueue != nil {
+		h.jobQueue.Notify()
+	}
+	h.JSON(w, http.StatusOK, project)
+}
+
 // ListProjectMembers returns project members
 func (h *Handler) ListProjectMembers(w http.ResponseWriter, r *http.Request) {
 	projectID := chi.URLParam(r, "projectId")
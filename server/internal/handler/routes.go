@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/obot-platform/discobot/server/internal/routes"
+	"github.com/obot-platform/discobot/server/internal/version"
 )
 
 // GetRoutes returns all registered API routes with their metadata.
@@ -11,3 +12,9 @@ import (
 func (h *Handler) GetRoutes(w http.ResponseWriter, _ *http.Request) {
 	h.JSON(w, http.StatusOK, routes.All())
 }
+
+// GetOpenAPI returns an OpenAPI 3 document generated from the registered routes.
+// It is rebuilt on every request, so it always reflects the current registry.
+func (h *Handler) GetOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	h.JSON(w, http.StatusOK, routes.OpenAPI(routes.All(), "Discobot API", version.Get()))
+}
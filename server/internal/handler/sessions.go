@@ -1,12 +1,21 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/go-containerregistry/pkg/name"
 
+	"github.com/obot-platform/discobot/server/internal/jobs"
 	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/service"
+	"github.com/obot-platform/discobot/server/internal/store"
 )
 
 // GetSession returns a single session
@@ -22,6 +31,57 @@ func (h *Handler) GetSession(w http.ResponseWriter, r *http.Request) {
 	h.JSON(w, http.StatusOK, session)
 }
 
+// maxBatchStatusSessions caps the number of session IDs per batch status request.
+const maxBatchStatusSessions = 200
+
+// GetSessionsStatus returns the statuses of many sessions in one request.
+// POST /api/projects/{projectId}/sessions/status
+func (h *Handler) GetSessionsStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	var req struct {
+		SessionIDs     []string `json:"sessionIds"`
+		IncludeSandbox bool     `json:"includeSandbox"`
+	}
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.SessionIDs) == 0 {
+		h.Error(w, http.StatusBadRequest, "sessionIds is required")
+		return
+	}
+	if len(req.SessionIDs) > maxBatchStatusSessions {
+		h.Error(w, http.StatusBadRequest, fmt.Sprintf("at most %d sessionIds are allowed per request", maxBatchStatusSessions))
+		return
+	}
+
+	statuses, err := h.sessionService.GetSessionStatuses(ctx, projectID, req.SessionIDs, req.IncludeSandbox)
+	if err != nil {
+		h.Error(w, http.StatusInternalServerError, "Failed to get session statuses")
+		return
+	}
+
+	// Report IDs that don't exist in this project so clients can drop them
+	found := make(map[string]bool, len(statuses))
+	for _, st := range statuses {
+		found[st.ID] = true
+	}
+	notFound := []string{}
+	for _, id := range req.SessionIDs {
+		if !found[id] {
+			notFound = append(notFound, id)
+			found[id] = true
+		}
+	}
+
+	h.JSON(w, http.StatusOK, map[string]any{
+		"sessions": statuses,
+		"notFound": notFound,
+	})
+}
+
 // UpdateSession updates a session
 func (h *Handler) UpdateSession(w http.ResponseWriter, r *http.Request) {
 	sessionID := chi.URLParam(r, "sessionId")
@@ -69,6 +129,10 @@ func (h *Handler) DeleteSession(w http.ResponseWriter, r *http.Request) {
 			h.Error(w, http.StatusNotFound, "Session not found")
 			return
 		}
+		if errors.Is(err, service.ErrCommitInProgress) {
+			h.Error(w, http.StatusConflict, "Session has a commit in progress")
+			return
+		}
 		h.Error(w, http.StatusInternalServerError, "Failed to initiate session deletion")
 		return
 	}
@@ -76,6 +140,47 @@ func (h *Handler) DeleteSession(w http.ResponseWriter, r *http.Request) {
 	h.JSON(w, http.StatusOK, map[string]bool{"success": true})
 }
 
+// maxBulkDeleteSessions caps the number of session IDs per bulk delete request.
+const maxBulkDeleteSessions = 200
+
+// BulkDeleteSessions initiates async deletion of many sessions: either the
+// listed sessionIds or every session matching filter ("stopped", "error", or
+// "completed"). Each session gets its own delete job, as with DeleteSession.
+// POST /api/projects/{projectId}/sessions/bulk-delete
+func (h *Handler) BulkDeleteSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	var req struct {
+		SessionIDs []string `json:"sessionIds"`
+		Filter     string   `json:"filter"`
+	}
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if (len(req.SessionIDs) == 0) == (req.Filter == "") {
+		h.Error(w, http.StatusBadRequest, "exactly one of sessionIds and filter is required")
+		return
+	}
+	if len(req.SessionIDs) > maxBulkDeleteSessions {
+		h.Error(w, http.StatusBadRequest, fmt.Sprintf("at most %d sessionIds are allowed per request", maxBulkDeleteSessions))
+		return
+	}
+
+	result, err := h.sessionService.BulkDeleteSessions(ctx, projectID, req.SessionIDs, req.Filter, h.jobQueue)
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownBulkDeleteFilter) {
+			h.Error(w, http.StatusBadRequest, fmt.Sprintf("filter must be %q, %q, or %q", service.BulkDeleteStopped, service.BulkDeleteError, service.BulkDeleteCompleted))
+			return
+		}
+		h.Error(w, http.StatusInternalServerError, "Failed to initiate session deletion")
+		return
+	}
+
+	h.JSON(w, http.StatusOK, result)
+}
+
 // ListMessages returns messages for a session by querying the container.
 func (h *Handler) ListMessages(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -91,11 +196,33 @@ func (h *Handler) ListMessages(w http.ResponseWriter, r *http.Request) {
 	h.JSON(w, http.StatusOK, map[string]any{"messages": messages})
 }
 
-// ListSessionsByWorkspace returns all sessions for a workspace.
+// ListSessionsByWorkspace returns the sessions for a workspace.
+// Optional query parameters: status (comma-separated statuses), q (name or
+// display name search), and sort ("updated" or "created", newest first).
+// Without them every session is returned, as before.
 func (h *Handler) ListSessionsByWorkspace(w http.ResponseWriter, r *http.Request) {
 	workspaceID := chi.URLParam(r, "workspaceId")
+	query := r.URL.Query()
 
-	sessions, err := h.sessionService.ListSessionsByWorkspace(r.Context(), workspaceID)
+	opts := store.SessionListOptions{
+		Search: strings.TrimSpace(query.Get("q")),
+		SortBy: query.Get("sort"),
+	}
+	if status := query.Get("status"); status != "" {
+		for _, s := range strings.Split(status, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				opts.Statuses = append(opts.Statuses, s)
+			}
+		}
+	}
+	switch opts.SortBy {
+	case "", store.SessionSortUpdated, store.SessionSortCreated:
+	default:
+		h.Error(w, http.StatusBadRequest, "sort must be one of: updated, created")
+		return
+	}
+
+	sessions, err := h.sessionService.ListSessionsByWorkspace(r.Context(), workspaceID, opts)
 	if err != nil {
 		h.Error(w, http.StatusInternalServerError, "Failed to list sessions")
 		return
@@ -104,17 +231,28 @@ func (h *Handler) ListSessionsByWorkspace(w http.ResponseWriter, r *http.Request
 	h.JSON(w, http.StatusOK, map[string]any{"sessions": sessions})
 }
 
-// CommitSession initiates async commit of a session
+// CommitSession initiates async commit of a session. With ?dryRun=true it
+// instead returns the agent's patches and a summary of what they change,
+// without touching the workspace or the commit status.
 func (h *Handler) CommitSession(w http.ResponseWriter, r *http.Request) {
 	sessionID := chi.URLParam(r, "sessionId")
 	ctx := r.Context()
 	projectID := middleware.GetProjectID(ctx)
 
-	if err := h.sessionService.CommitSession(ctx, projectID, sessionID, h.jobQueue); err != nil {
+	if r.URL.Query().Get("dryRun") == "true" {
+		h.previewCommit(w, r, projectID, sessionID)
+		return
+	}
+
+	if err := h.sessionService.CommitSession(ctx, projectID, sessionID, middleware.GetUserID(ctx), h.jobQueue); err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			h.Error(w, http.StatusNotFound, "Session not found")
 			return
 		}
+		if errors.Is(err, service.ErrSessionRemoving) {
+			h.Error(w, http.StatusConflict, "Session is being deleted")
+			return
+		}
 		h.Error(w, http.StatusInternalServerError, "Failed to initiate session commit")
 		return
 	}
@@ -122,4 +260,507 @@ func (h *Handler) CommitSession(w http.ResponseWriter, r *http.Request) {
 	h.JSON(w, http.StatusOK, map[string]bool{"success": true})
 }
 
+// previewCommit handles CommitSession in dry-run mode.
+func (h *Handler) previewCommit(w http.ResponseWriter, r *http.Request, projectID, sessionID string) {
+	preview, err := h.sessionService.PreviewCommit(r.Context(), projectID, sessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSessionRemoving):
+			h.Error(w, http.StatusConflict, "Session is being deleted")
+		case strings.Contains(err.Error(), "(parent_mismatch)"):
+			h.Error(w, http.StatusConflict, err.Error())
+		case strings.Contains(err.Error(), "session not found"):
+			h.Error(w, http.StatusNotFound, "Session not found")
+		default:
+			h.Error(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	h.JSON(w, http.StatusOK, preview)
+}
+
+// ResetSession discards the session's home directory changes by restarting
+// its sandbox from the base home, keeping the workspace and caches. Since the
+// changes can't be recovered, the request must set confirm to true. The
+// session is reinitializing until the sandbox is back.
+// POST /api/projects/{projectId}/sessions/{sessionId}/reset
+func (h *Handler) ResetSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	var req struct {
+		Confirm bool `json:"confirm"`
+	}
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !req.Confirm {
+		h.Error(w, http.StatusBadRequest, "Resetting discards the session's home directory changes; set confirm to true")
+		return
+	}
+
+	existing, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || existing.ProjectID != projectID {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	if err := h.sessionService.ResetSession(ctx, projectID, sessionID, h.jobQueue); err != nil {
+		if errors.Is(err, service.ErrInvalidSessionState) {
+			h.Error(w, http.StatusConflict, err.Error())
+			return
+		}
+		h.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.JSON(w, http.StatusAccepted, map[string]bool{"success": true})
+}
+
+// PrioritizeSession moves a session's queued init job ahead of other pending jobs,
+// or sets its priority explicitly. Higher priority jobs are claimed first.
+// POST /api/projects/{projectId}/sessions/{sessionId}/prioritize
+func (h *Handler) PrioritizeSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	// Body is optional; omitting priority bumps the job to the front of the queue
+	var req struct {
+		Priority *int `json:"priority"`
+	}
+	if err := h.DecodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	session, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || session.ProjectID != projectID {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	job, err := h.jobQueue.PrioritizeSessionInit(ctx, sessionID, req.Priority)
+	if err != nil {
+		if errors.Is(err, jobs.ErrNoPendingJob) {
+			h.Error(w, http.StatusConflict, "Session has no queued init job")
+			return
+		}
+		h.Error(w, http.StatusInternalServerError, "Failed to prioritize session")
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]any{
+		"jobId":    job.ID,
+		"priority": job.Priority,
+	})
+}
+
+// GetSessionHistory returns the session's recent status and commit status
+// transitions, oldest first.
+// GET /api/projects/{projectId}/sessions/{sessionId}/history
+func (h *Handler) GetSessionHistory(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	session, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || session.ProjectID != projectID {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	// Get limit from query params, default to 50
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 50
+	}
+
+	history, err := h.sessionService.GetHistory(ctx, sessionID, limit)
+	if err != nil {
+		h.Error(w, http.StatusInternalServerError, "Failed to get session history")
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]any{"history": history})
+}
+
+// ForwardSessionDockerSocket exposes the Docker daemon behind the session's
+// sandbox (e.g., inside its VZ VM) on a host Unix socket, limited to the
+// session's containers. Requires DEBUG_DOCKER_SOCKET.
+// POST /api/projects/{projectId}/sessions/{sessionId}/docker-socket
+func (h *Handler) ForwardSessionDockerSocket(w http.ResponseWriter, r *http.Request) {
+	if !h.cfg.DebugDockerSocket {
+		h.Error(w, http.StatusServiceUnavailable, "Docker socket forwarding not enabled")
+		return
+	}
+
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	session, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || session.ProjectID != projectID {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	socketPath, err := h.sandboxService.ForwardDockerSocket(ctx, sessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sandbox.ErrNotSupported):
+			h.Error(w, http.StatusNotImplemented, "Session's sandbox provider cannot forward a Docker socket")
+		case errors.Is(err, sandbox.ErrNotFound):
+			h.Error(w, http.StatusConflict, "Session's sandbox is not running")
+		default:
+			h.Error(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]string{
+		"socketPath": socketPath,
+		"dockerHost": "unix://" + socketPath,
+	})
+}
+
+// StopSessionDockerSocket stops forwarding the session's Docker socket.
+// DELETE /api/projects/{projectId}/sessions/{sessionId}/docker-socket
+func (h *Handler) StopSessionDockerSocket(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	session, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || session.ProjectID != projectID {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	if err := h.sandboxService.StopDockerSocket(ctx, sessionID); err != nil {
+		if errors.Is(err, sandbox.ErrNotSupported) {
+			h.Error(w, http.StatusNotImplemented, "Session's sandbox provider cannot forward a Docker socket")
+			return
+		}
+		h.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSessionFilesystemDiff returns the paths added, changed, or deleted in the
+// session's sandbox filesystem since it was created from its image (e.g.
+// installed packages). Unlike the session diff, this isn't limited to git.
+// GET /api/projects/{projectId}/sessions/{sessionId}/fsdiff
+func (h *Handler) GetSessionFilesystemDiff(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	session, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || session.ProjectID != projectID {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	changes, err := h.sandboxService.FilesystemDiff(ctx, sessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sandbox.ErrNotSupported):
+			h.Error(w, http.StatusNotImplemented, "Session's sandbox provider cannot diff filesystems")
+		case errors.Is(err, sandbox.ErrNotFound):
+			h.Error(w, http.StatusConflict, "Session's sandbox does not exist")
+		default:
+			h.Error(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]any{"changes": changes})
+}
+
+// GetSessionStats returns a sample of the CPU, memory, and network usage of
+// the session's sandbox.
+// GET /api/projects/{projectId}/sessions/{sessionId}/stats
+func (h *Handler) GetSessionStats(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	session, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || session.ProjectID != projectID {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	stats, err := h.sandboxService.Stats(ctx, sessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sandbox.ErrNotSupported):
+			h.Error(w, http.StatusNotImplemented, "Session's sandbox provider cannot report resource usage")
+		case errors.Is(err, sandbox.ErrNotFound):
+			h.Error(w, http.StatusConflict, "Session's sandbox does not exist")
+		case errors.Is(err, sandbox.ErrNotRunning):
+			h.Error(w, http.StatusConflict, "Session's sandbox is not running")
+		default:
+			h.Error(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	h.JSON(w, http.StatusOK, stats)
+}
+
+// GetSessionFileDescriptors returns the open file descriptor counts and
+// limits of the processes in the session's sandbox, with warnings for any
+// near their limit, so descriptor leaks are caught before tools start failing.
+// GET /api/projects/{projectId}/sessions/{sessionId}/fds
+func (h *Handler) GetSessionFileDescriptors(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+
+	result, err := h.chatService.GetFileDescriptors(ctx, projectID, sessionID)
+	if err != nil {
+		msg := err.Error()
+		switch {
+		case strings.Contains(msg, "not found"):
+			h.Error(w, http.StatusNotFound, msg)
+		case strings.Contains(msg, "status 503"):
+			h.Error(w, http.StatusServiceUnavailable, "The sandbox cannot report file descriptor usage")
+		default:
+			h.Error(w, http.StatusInternalServerError, msg)
+		}
+		return
+	}
+
+	h.JSON(w, http.StatusOK, result)
+}
+
+// CommitSessionImage saves the session's sandbox filesystem as an image that
+// can be used as a sandbox image later.
+// POST /api/projects/{projectId}/sessions/{sessionId}/commit-image
+func (h *Handler) CommitSessionImage(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	var req struct {
+		Image string `json:"image"`
+	}
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	prefix := committedImagePrefix(projectID)
+	tag, err := name.NewTag(req.Image)
+	if err != nil || !strings.HasPrefix(req.Image, prefix) {
+		h.Error(w, http.StatusBadRequest, fmt.Sprintf("image must be an image reference in this project's repository, such as %smy-env:v1", prefix))
+		return
+	}
+
+	session, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || session.ProjectID != projectID {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	// Tagging over an image sandboxes are created from would change every
+	// sandbox created from it afterwards
+	if h.isSandboxImage(tag) {
+		h.Error(w, http.StatusConflict, "image is the configured sandbox image")
+		return
+	}
+	repo := tag.Context().RepositoryStr()
+	spellings := []string{req.Image, repo + ":" + tag.TagStr()}
+	if tag.TagStr() == "latest" {
+		spellings = append(spellings, repo)
+	}
+	inUse, err := h.store.SandboxImageInUse(ctx, spellings...)
+	if err != nil {
+		h.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if inUse {
+		h.Error(w, http.StatusConflict, "image is a project's sandbox image")
+		return
+	}
+
+	image, err := h.sandboxService.CommitImage(ctx, sessionID, req.Image)
+	if err != nil {
+		switch {
+		case errors.Is(err, sandbox.ErrNotSupported):
+			h.Error(w, http.StatusNotImplemented, "Session's sandbox provider cannot commit images")
+		case errors.Is(err, sandbox.ErrNotFound):
+			h.Error(w, http.StatusConflict, "Session's sandbox does not exist")
+		default:
+			h.Error(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	h.JSON(w, http.StatusCreated, image)
+}
+
+// committedImagePrefix is the repository prefix images committed from a
+// project's sessions must use, so one project can't tag over images other
+// projects use.
+func committedImagePrefix(projectID string) string {
+	return "discobot-local/" + projectID + "/"
+}
+
+// isSandboxImage reports whether tag names SANDBOX_IMAGE or one of its
+// per-architecture overrides.
+func (h *Handler) isSandboxImage(tag name.Tag) bool {
+	images := []string{h.cfg.SandboxImage}
+	for _, image := range h.cfg.SandboxArchImages {
+		images = append(images, image)
+	}
+	for _, image := range images {
+		if ref, err := name.ParseReference(image); err == nil && ref.Name() == tag.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+// ForkSession creates a new session seeded from a snapshot of this session's
+// sandbox. The new session starts initializing immediately.
+// POST /api/projects/{projectId}/sessions/{sessionId}/fork
+func (h *Handler) ForkSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	// Body is optional; the fork is named after the original by default
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := h.DecodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	existing, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || existing.ProjectID != projectID {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	fork, err := h.sessionService.ForkSession(ctx, projectID, sessionID, req.Name, h.jobQueue)
+	if err != nil {
+		switch {
+		case errors.Is(err, sandbox.ErrNotSupported):
+			h.Error(w, http.StatusNotImplemented, "Session's sandbox provider cannot take snapshots")
+		case errors.Is(err, sandbox.ErrNotFound):
+			h.Error(w, http.StatusConflict, "Session's sandbox does not exist")
+		case errors.Is(err, service.ErrInvalidSessionState):
+			h.Error(w, http.StatusConflict, err.Error())
+		case errors.Is(err, service.ErrQuiesced):
+			h.Error(w, http.StatusServiceUnavailable, "The server is in maintenance and not accepting new sessions. Existing sessions are unaffected.")
+		default:
+			h.Error(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	h.JSON(w, http.StatusCreated, fork)
+}
+
+// PauseSession freezes the session's sandbox so it uses no CPU while idle.
+// POST /api/projects/{projectId}/sessions/{sessionId}/pause
+func (h *Handler) PauseSession(w http.ResponseWriter, r *http.Request) {
+	h.setSessionPaused(w, r, true)
+}
+
+// ResumeSession unpauses the session's sandbox.
+// POST /api/projects/{projectId}/sessions/{sessionId}/resume
+func (h *Handler) ResumeSession(w http.ResponseWriter, r *http.Request) {
+	h.setSessionPaused(w, r, false)
+}
+
+// setSessionPaused pauses or resumes a session and returns it.
+func (h *Handler) setSessionPaused(w http.ResponseWriter, r *http.Request, pause bool) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	existing, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || existing.ProjectID != projectID {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	var session *service.Session
+	if pause {
+		session, err = h.sessionService.Pause(ctx, projectID, sessionID)
+	} else {
+		session, err = h.sessionService.Resume(ctx, projectID, sessionID)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidSessionState):
+			h.Error(w, http.StatusConflict, err.Error())
+		case errors.Is(err, sandbox.ErrNotSupported):
+			h.Error(w, http.StatusNotImplemented, "Session's sandbox provider cannot pause sandboxes")
+		case errors.Is(err, sandbox.ErrNotFound):
+			h.Error(w, http.StatusConflict, "Session's sandbox does not exist")
+		case errors.Is(err, sandbox.ErrNotRunning):
+			h.Error(w, http.StatusConflict, "Session's sandbox is not running")
+		default:
+			h.Error(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	h.JSON(w, http.StatusOK, session)
+}
+
+// ResizeSessionDisk grows the data volume backing the session's sandbox.
+// On VZ this is the project VM's data disk, so the VM is restarted.
+// POST /api/projects/{projectId}/sessions/{sessionId}/resize-disk
+func (h *Handler) ResizeSessionDisk(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	var req struct {
+		SizeMB int `json:"sizeMB"`
+	}
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.SizeMB <= 0 {
+		h.Error(w, http.StatusBadRequest, "sizeMB must be positive")
+		return
+	}
+
+	session, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || session.ProjectID != projectID {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	if err := h.sandboxService.ResizeVolume(ctx, sessionID, req.SizeMB); err != nil {
+		switch {
+		case errors.Is(err, sandbox.ErrNotSupported):
+			h.Error(w, http.StatusNotImplemented, "Session's sandbox provider cannot resize volumes")
+		case errors.Is(err, sandbox.ErrVolumeShrink):
+			h.Error(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, sandbox.ErrNotFound):
+			h.Error(w, http.StatusConflict, "Session's sandbox does not exist")
+		default:
+			h.Error(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]int{"sizeMB": req.SizeMB})
+}
+
 // NOTE: CreateSession was removed - sessions are now created implicitly via /api/projects/{projectId}/chat
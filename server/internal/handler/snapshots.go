@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/service"
+)
+
+// snapshotDiffReadBufferSize is the size of each chunk read from a snapshot diff.
+const snapshotDiffReadBufferSize = 32 * 1024
+
+// snapshotError writes the response for an error from a snapshot operation.
+func (h *Handler) snapshotError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, sandbox.ErrNotSupported):
+		h.Error(w, http.StatusNotImplemented, "Session's sandbox provider cannot compare snapshots")
+	case errors.Is(err, sandbox.ErrNotFound):
+		h.Error(w, http.StatusNotFound, notFound)
+	case errors.Is(err, service.ErrInvalidSessionState):
+		h.Error(w, http.StatusConflict, err.Error())
+	default:
+		h.Error(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// CreateSessionSnapshot captures the session's sandbox so later states can
+// be compared against it.
+// POST /api/projects/{projectId}/sessions/{sessionId}/snapshots
+func (h *Handler) CreateSessionSnapshot(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	if !h.sessionInProject(r, sessionID, middleware.GetProjectID(r.Context())) {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	snapshot, err := h.sandboxService.Snapshot(r.Context(), sessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sandbox.ErrNotSupported):
+			h.Error(w, http.StatusNotImplemented, "Session's sandbox provider cannot take snapshots")
+		case errors.Is(err, sandbox.ErrNotFound):
+			h.Error(w, http.StatusConflict, "Session's sandbox does not exist")
+		default:
+			h.Error(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	h.JSON(w, http.StatusCreated, snapshot)
+}
+
+// ListSessionSnapshots lists the session's snapshots, oldest first.
+// GET /api/projects/{projectId}/sessions/{sessionId}/snapshots
+func (h *Handler) ListSessionSnapshots(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	if !h.sessionInProject(r, sessionID, middleware.GetProjectID(r.Context())) {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	snapshots, err := h.sandboxService.ListSnapshots(r.Context(), sessionID)
+	if err != nil {
+		h.snapshotError(w, err, "Session not found")
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]any{"snapshots": snapshots})
+}
+
+// DeleteSessionSnapshot deletes one of the session's snapshots.
+// DELETE /api/projects/{projectId}/sessions/{sessionId}/snapshots/{snapshotId}
+func (h *Handler) DeleteSessionSnapshot(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	if !h.sessionInProject(r, sessionID, middleware.GetProjectID(r.Context())) {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	if err := h.sandboxService.DeleteSnapshot(r.Context(), sessionID, chi.URLParam(r, "snapshotId")); err != nil {
+		h.snapshotError(w, err, "Snapshot not found")
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// DiffSessionSnapshot streams a unified diff of the session's workspace from
+// a snapshot to another snapshot (?to=) or, by default, to the workspace as
+// it is now. Files ignored by the workspace's .gitignore are left out.
+// GET /api/projects/{projectId}/sessions/{sessionId}/snapshots/{snapshotId}/diff?to=<snapshotId>
+func (h *Handler) DiffSessionSnapshot(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	sessionID := chi.URLParam(r, "sessionId")
+	if !h.sessionInProject(r, sessionID, middleware.GetProjectID(r.Context())) {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	diff, err := h.sandboxService.DiffSnapshots(ctx, sessionID, chi.URLParam(r, "snapshotId"), r.URL.Query().Get("to"))
+	if err != nil {
+		h.snapshotError(w, err, "Snapshot not found")
+		return
+	}
+	defer diff.Close()
+
+	// Wait for the first output, so a diff that fails to start is still an error
+	buf := make([]byte, snapshotDiffReadBufferSize)
+	n, err := diff.Read(buf)
+	if err != nil && !errors.Is(err, io.EOF) && n == 0 {
+		if errors.Is(err, sandbox.ErrNotSupported) {
+			// e.g. the workspace is stored in agentfs
+			h.Error(w, http.StatusNotImplemented, err.Error())
+			return
+		}
+		h.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	for {
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("Snapshot diff for session %s ended early: %v", sessionID, err)
+			}
+			return
+		}
+		n, err = diff.Read(buf)
+	}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/ssh"
+)
+
+// SSHConnectionResponse describes an active SSH connection.
+type SSHConnectionResponse struct {
+	ssh.ConnectionInfo
+	DurationSeconds int64 `json:"durationSeconds"`
+}
+
+// SetSSHServer sets the SSH server whose connections are exposed by the
+// SSH connection endpoints. Called from main.go when SSH is enabled.
+func (h *Handler) SetSSHServer(s *ssh.Server) {
+	h.sshServer = s
+}
+
+// ListSSHConnections returns the active SSH connections for sessions in the project.
+func (h *Handler) ListSSHConnections(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	result := []SSHConnectionResponse{}
+	if h.sshServer == nil {
+		h.JSON(w, http.StatusOK, map[string]any{"connections": result})
+		return
+	}
+
+	now := time.Now()
+	for _, conn := range h.sshServer.Connections() {
+		if !h.sessionInProject(r, conn.SessionID, projectID) {
+			continue
+		}
+		result = append(result, SSHConnectionResponse{
+			ConnectionInfo:  conn,
+			DurationSeconds: int64(now.Sub(conn.ConnectedAt).Seconds()),
+		})
+	}
+
+	h.JSON(w, http.StatusOK, map[string]any{"connections": result})
+}
+
+// CloseSSHConnection terminates an active SSH connection.
+func (h *Handler) CloseSSHConnection(w http.ResponseWriter, r *http.Request) {
+	connectionID := chi.URLParam(r, "connectionId")
+	projectID := middleware.GetProjectID(r.Context())
+
+	if h.sshServer == nil {
+		h.Error(w, http.StatusServiceUnavailable, "SSH server not enabled")
+		return
+	}
+
+	conn, err := h.sshServer.Connection(connectionID)
+	if err != nil || !h.sessionInProject(r, conn.SessionID, projectID) {
+		h.Error(w, http.StatusNotFound, "SSH connection not found")
+		return
+	}
+
+	if err := h.sshServer.CloseConnection(connectionID); err != nil {
+		if errors.Is(err, ssh.ErrConnectionNotFound) {
+			h.Error(w, http.StatusNotFound, "SSH connection not found")
+			return
+		}
+		h.Error(w, http.StatusInternalServerError, "Failed to close SSH connection")
+		return
+	}
+
+	h.JSON(w, http.StatusOK, map[string]bool{"success": true})
+}
+
+// sessionInProject reports whether the session exists and belongs to the project.
+func (h *Handler) sessionInProject(r *http.Request, sessionID, projectID string) bool {
+	session, err := h.store.GetSessionByID(r.Context(), sessionID)
+	if err != nil {
+		return false
+	}
+	return session.ProjectID == projectID
+}
@@ -1,13 +1,18 @@
 package handler
 
 import (
+	"context"
 	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/adrg/xdg"
 
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/service"
 	"github.com/obot-platform/discobot/server/internal/startup"
 	"github.com/obot-platform/discobot/server/internal/version"
 )
@@ -24,6 +29,116 @@ func (h *Handler) GetServerConfig(w http.ResponseWriter, _ *http.Request) {
 	})
 }
 
+// EffectiveConfigResponse is the configuration the server is running with
+type EffectiveConfigResponse struct {
+	Version         string           `json:"version"`
+	DefaultProvider string           `json:"defaultProvider,omitempty"`
+	Providers       []string         `json:"providers,omitempty"`
+	Settings        []config.Setting `json:"settings"`
+}
+
+// GetEffectiveConfig returns the effective server configuration with secrets
+// redacted, annotating each setting with its source (default, env, or .env).
+// GET /api/admin/config
+func (h *Handler) GetEffectiveConfig(w http.ResponseWriter, _ *http.Request) {
+	resp := EffectiveConfigResponse{
+		Version:  version.Get(),
+		Settings: h.cfg.Settings(),
+	}
+	if h.sandboxManager != nil {
+		resp.DefaultProvider = h.sandboxManager.DefaultProviderName()
+		resp.Providers = h.sandboxManager.ListProviders()
+	}
+	h.JSON(w, http.StatusOK, resp)
+}
+
+// QuiesceResponse reports whether the server is accepting new sessions
+type QuiesceResponse struct {
+	Quiesced bool       `json:"quiesced"`
+	Since    *time.Time `json:"since,omitempty"`
+}
+
+// Quiesce stops the server from accepting new sessions, for shedding load
+// before scaling down or during incidents. Existing sessions keep working.
+// POST /api/admin/quiesce
+func (h *Handler) Quiesce(w http.ResponseWriter, _ *http.Request) {
+	h.quiesce.Quiesce()
+	h.JSON(w, http.StatusOK, h.quiesceResponse())
+}
+
+// Unquiesce resumes accepting new sessions.
+// DELETE /api/admin/quiesce
+func (h *Handler) Unquiesce(w http.ResponseWriter, _ *http.Request) {
+	h.quiesce.Unquiesce()
+	h.JSON(w, http.StatusOK, h.quiesceResponse())
+}
+
+func (h *Handler) quiesceResponse() QuiesceResponse {
+	quiesced, since := h.quiesce.Quiesced()
+	if !quiesced {
+		return QuiesceResponse{}
+	}
+	return QuiesceResponse{Quiesced: true, Since: &since}
+}
+
+// readyzTimeout bounds how long Readyz waits on provider health checks.
+const readyzTimeout = 5 * time.Second
+
+// ReadyzResponse reports whether the server should receive new traffic
+type ReadyzResponse struct {
+	Status    string                       `json:"status"` // "ready", "quiesced", or "unavailable"
+	Providers map[string]ProviderReadiness `json:"providers,omitempty"`
+}
+
+// ProviderReadiness is the health of one sandbox provider. Only the default
+// provider is required; the others are reported but don't affect readiness.
+type ProviderReadiness struct {
+	Healthy  bool   `json:"healthy"`
+	Required bool   `json:"required"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Readyz reports whether the server should receive new traffic. It returns
+// 503 while quiesced so load balancers route new sessions elsewhere, and
+// while the default sandbox provider is unhealthy (e.g., Docker is
+// unreachable or VZ images are still downloading), with a per-provider
+// breakdown.
+// GET /readyz
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	resp := ReadyzResponse{Status: "ready"}
+	if h.sandboxManager != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), readyzTimeout)
+		defer cancel()
+
+		defaultProvider := h.sandboxManager.DefaultProviderName()
+		resp.Providers = make(map[string]ProviderReadiness)
+		if _, err := h.sandboxManager.GetProvider(defaultProvider); err != nil {
+			resp.Providers[defaultProvider] = ProviderReadiness{Required: true, Error: err.Error()}
+		}
+		for name, err := range h.sandboxManager.Health(ctx) {
+			readiness := ProviderReadiness{Healthy: err == nil, Required: name == defaultProvider}
+			if err != nil {
+				readiness.Error = err.Error()
+			}
+			resp.Providers[name] = readiness
+		}
+		for _, readiness := range resp.Providers {
+			if readiness.Required && !readiness.Healthy {
+				resp.Status = "unavailable"
+			}
+		}
+	}
+
+	if quiesced, _ := h.quiesce.Quiesced(); quiesced {
+		resp.Status = "quiesced"
+	}
+	if resp.Status != "ready" {
+		h.JSON(w, http.StatusServiceUnavailable, resp)
+		return
+	}
+	h.JSON(w, http.StatusOK, resp)
+}
+
 // GetSystemStatus checks system requirements and returns status (including startup tasks)
 func (h *Handler) GetSystemStatus(w http.ResponseWriter, _ *http.Request) {
 	// Use system manager to get complete system status
@@ -42,13 +157,15 @@ func (h *Handler) GetSystemStatus(w http.ResponseWriter, _ *http.Request) {
 
 // SupportInfoResponse contains diagnostic information for debugging and support
 type SupportInfoResponse struct {
-	Version    string                       `json:"version"`
-	Runtime    RuntimeInfo                  `json:"runtime"`
-	Config     ConfigInfo                   `json:"config"`
-	ServerLog  string                       `json:"server_log"`
-	LogPath    string                       `json:"log_path"`
-	LogExists  bool                         `json:"log_exists"`
-	SystemInfo startup.SystemStatusResponse `json:"system_info"`
+	Version         string                       `json:"version"`
+	Runtime         RuntimeInfo                  `json:"runtime"`
+	Config          ConfigInfo                   `json:"config"`
+	ServerLog       string                       `json:"server_log"`
+	LogPath         string                       `json:"log_path"`
+	LogExists       bool                         `json:"log_exists"`
+	SystemInfo      startup.SystemStatusResponse `json:"system_info"`
+	OrphanSandboxes service.OrphanSandboxStats   `json:"orphan_sandboxes"`
+	ActiveExecs     map[string]int               `json:"active_execs"` // Running commands per session
 }
 
 // RuntimeInfo contains Go runtime information
@@ -174,14 +291,22 @@ func (h *Handler) GetSupportInfo(w http.ResponseWriter, _ *http.Request) {
 		systemStatus = h.systemManager.GetSystemStatus()
 	}
 
+	// Count running commands per sandbox, if the provider tracks them
+	activeExecs := map[string]int{}
+	if esp, ok := h.sandboxProvider.(sandbox.ExecStatsProvider); ok {
+		activeExecs = esp.ActiveExecs()
+	}
+
 	response := SupportInfoResponse{
-		Version:    version.Get(),
-		Runtime:    runtimeInfo,
-		Config:     configInfo,
-		ServerLog:  logContent,
-		LogPath:    logPath,
-		LogExists:  logExists,
-		SystemInfo: systemStatus,
+		Version:         version.Get(),
+		Runtime:         runtimeInfo,
+		Config:          configInfo,
+		ServerLog:       logContent,
+		LogPath:         logPath,
+		LogExists:       logExists,
+		SystemInfo:      systemStatus,
+		OrphanSandboxes: service.GetOrphanSandboxStats(),
+		ActiveExecs:     activeExecs,
 	}
 
 	h.JSON(w, http.StatusOK, response)
@@ -13,6 +13,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
 
+	"github.com/obot-platform/discobot/server/internal/middleware"
 	"github.com/obot-platform/discobot/server/internal/sandbox"
 )
 
@@ -29,7 +30,7 @@ var upgrader = websocket.Upgrader{
 
 // TerminalMessage represents a message sent over the WebSocket
 type TerminalMessage struct {
-	Type string          `json:"type"` // "input", "output", "resize", "error"
+	Type string          `json:"type"` // "input", "output", "resize", "error", "recording"
 	Data json.RawMessage `json:"data,omitempty"`
 }
 
@@ -62,8 +63,15 @@ func (h *Handler) TerminalWebSocket(w http.ResponseWriter, r *http.Request) {
 		cols = 80
 	}
 
-	// Check if root access is requested
-	runAsRoot := r.URL.Query().Get("root") == "true"
+	// Check if a specific user is requested (root=true is shorthand for user=root)
+	requestedUser := r.URL.Query().Get("user")
+	if requestedUser == "" && r.URL.Query().Get("root") == "true" {
+		requestedUser = "root"
+	}
+	if err := h.sandboxService.CheckUserAllowed(requestedUser); err != nil {
+		h.Error(w, http.StatusForbidden, err.Error())
+		return
+	}
 
 	ctx := r.Context()
 
@@ -76,17 +84,21 @@ func (h *Handler) TerminalWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Determine user for terminal session
-	var user string
-	if runAsRoot {
-		user = "root"
-	} else {
+	user := requestedUser
+	if user == "" {
 		// Get default user from sandbox (uses UID:GID format for compatibility)
 		userInfo, err := client.GetUserInfo(ctx)
-		if err != nil {
+		switch {
+		case err == nil:
+			user = strconv.Itoa(userInfo.UID) + ":" + strconv.Itoa(userInfo.GID)
+		case h.sandboxService.CheckUserAllowed("root") == nil:
 			log.Printf("failed to get user info, falling back to root: %v", err)
 			user = "root"
-		} else {
-			user = strconv.Itoa(userInfo.UID) + ":" + strconv.Itoa(userInfo.GID)
+		default:
+			// Root needs the same opt-in as a requested root shell
+			log.Printf("failed to get user info for session %s: %v", sessionID, err)
+			h.Error(w, http.StatusInternalServerError, "failed to get sandbox user")
+			return
 		}
 	}
 
@@ -102,17 +114,32 @@ func (h *Handler) TerminalWebSocket(w http.ResponseWriter, r *http.Request) {
 	pty, err := h.sandboxService.Attach(ctx, sessionID, rows, cols, user)
 	if err != nil {
 		log.Printf("failed to attach to sandbox PTY: %v", err)
-		sendError(conn, "failed to attach to terminal")
+		if errors.Is(err, sandbox.ErrTooManyExecs) {
+			sendError(conn, sandbox.ErrTooManyExecs.Error())
+		} else {
+			sendError(conn, "failed to attach to terminal")
+		}
 		return
 	}
 	defer func() { _ = pty.Close() }()
 
+	// Record the session if asked to (?record=true); the client gets the
+	// recording ID for fetching the cast file
+	var rec *terminalRecording
+	if r.URL.Query().Get("record") == "true" {
+		rec = newTerminalRecording(sessionID, cols, rows)
+		h.terminalRecordings.add(rec)
+		data, _ := json.Marshal(map[string]string{"id": rec.ID})
+		_ = conn.WriteJSON(TerminalMessage{Type: "recording", Data: data})
+	}
+
 	// Handle the terminal session (core logic extracted for testability)
-	handleTerminalSession(ctx, pty, conn)
+	handleTerminalSession(ctx, pty, conn, rec)
 }
 
 // handleTerminalSession manages the bidirectional data flow between PTY and WebSocket.
 // This function is extracted from TerminalWebSocket for testability.
+// Output and resizes are also recorded to rec, if not nil.
 //
 // Goroutine coordination:
 //   - Input goroutine: Reads from WebSocket, writes to PTY. Exits when client stops writing.
@@ -121,7 +148,7 @@ func (h *Handler) TerminalWebSocket(w http.ResponseWriter, r *http.Request) {
 // Half-close support:
 //   - If client stops writing, input goroutine exits but output continues.
 //   - If PTY exits, both goroutines eventually exit and connection closes.
-func handleTerminalSession(ctx context.Context, pty sandbox.PTY, conn *websocket.Conn) {
+func handleTerminalSession(ctx context.Context, pty sandbox.PTY, conn *websocket.Conn, rec *terminalRecording) {
 	// Done channel to signal when PTY output is fully drained
 	outputDone := make(chan struct{})
 
@@ -158,6 +185,8 @@ func handleTerminalSession(ctx context.Context, pty sandbox.PTY, conn *websocket
 				}
 				if err := pty.Resize(ctx, resize.Rows, resize.Cols); err != nil {
 					log.Printf("PTY resize error: %v", err)
+				} else {
+					rec.resize(resize.Cols, resize.Rows)
 				}
 			}
 		}
@@ -177,6 +206,7 @@ func handleTerminalSession(ctx context.Context, pty sandbox.PTY, conn *websocket
 				return
 			}
 			if n > 0 {
+				rec.output(buf[:n])
 				// Properly JSON-encode the data to preserve ANSI escape codes
 				data, err := json.Marshal(string(buf[:n]))
 				if err != nil {
@@ -201,6 +231,7 @@ func handleTerminalSession(ctx context.Context, pty sandbox.PTY, conn *websocket
 
 	// Wait for output to be fully drained before closing
 	<-outputDone
+	rec.finish()
 
 	// Send a close message to the client before closing the connection
 	// This ensures the frontend receives a proper close event
@@ -208,6 +239,85 @@ func handleTerminalSession(ctx context.Context, pty sandbox.PTY, conn *websocket
 	_ = conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
 }
 
+// execRequest is the body of an exec request.
+type execRequest struct {
+	Command        []string          `json:"command"`
+	User           string            `json:"user,omitempty"`
+	WorkDir        string            `json:"workDir,omitempty"`
+	Env            map[string]string `json:"env,omitempty"`
+	TimeoutSeconds int               `json:"timeoutSeconds,omitempty"`
+}
+
+// execResponse is the result of an exec request, with output as text.
+type execResponse struct {
+	ExitCode  int    `json:"exitCode"`
+	Stdout    string `json:"stdout"`
+	Stderr    string `json:"stderr"`
+	Truncated bool   `json:"truncated,omitempty"`
+	TimedOut  bool   `json:"timedOut,omitempty"`
+}
+
+// ExecCommand runs a non-interactive command in the session's sandbox,
+// optionally as another allowed user (e.g. root for package installs).
+// POST /api/projects/{projectId}/sessions/{sessionId}/exec
+func (h *Handler) ExecCommand(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+
+	session, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || session.ProjectID != projectID {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	if h.sandboxService == nil {
+		h.Error(w, http.StatusServiceUnavailable, "sandbox provider not available")
+		return
+	}
+
+	var req execRequest
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if len(req.Command) == 0 {
+		h.Error(w, http.StatusBadRequest, "command is required")
+		return
+	}
+	if req.TimeoutSeconds < 0 {
+		h.Error(w, http.StatusBadRequest, "timeoutSeconds must not be negative")
+		return
+	}
+	if err := h.sandboxService.CheckUserAllowed(req.User); err != nil {
+		h.Error(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	result, err := h.sandboxService.Exec(ctx, sessionID, req.Command, sandbox.ExecOptions{
+		WorkDir: req.WorkDir,
+		Env:     req.Env,
+		User:    req.User,
+		Timeout: time.Duration(req.TimeoutSeconds) * time.Second,
+	})
+	if errors.Is(err, sandbox.ErrTooManyExecs) {
+		h.Error(w, http.StatusTooManyRequests, sandbox.ErrTooManyExecs.Error())
+		return
+	}
+	if err != nil {
+		h.Error(w, http.StatusInternalServerError, "failed to run command: "+err.Error())
+		return
+	}
+
+	h.JSON(w, http.StatusOK, execResponse{
+		ExitCode:  result.ExitCode,
+		Stdout:    string(result.Stdout),
+		Stderr:    string(result.Stderr),
+		Truncated: result.Truncated,
+		TimedOut:  result.TimedOut,
+	})
+}
+
 // GetTerminalHistory returns terminal history for a session
 func (h *Handler) GetTerminalHistory(w http.ResponseWriter, r *http.Request) {
 	sessionID := chi.URLParam(r, "sessionId")
@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/obot-platform/discobot/server/internal/middleware"
+)
+
+const (
+	// maxTerminalRecordingBytes caps the encoded events kept per recording;
+	// output past the cap is dropped and the recording marked truncated.
+	maxTerminalRecordingBytes = 1 << 20
+	// maxTerminalRecordings caps the recordings kept in memory across all
+	// sessions; the oldest is dropped when a new one starts.
+	maxTerminalRecordings = 32
+)
+
+// terminalRecording is an asciinema v2 recording of one terminal connection.
+// Only output and resizes are recorded, never input, so typed secrets that
+// aren't echoed stay out of the recording. Methods are safe on a nil
+// recording, which records nothing.
+type terminalRecording struct {
+	ID        string
+	SessionID string
+	StartedAt time.Time
+
+	mu        sync.Mutex
+	now       func() time.Time
+	width     int
+	height    int
+	events    bytes.Buffer // Encoded event lines
+	pending   []byte       // Incomplete UTF-8 sequence from the last output
+	truncated bool
+	endedAt   time.Time
+}
+
+// terminalRecordingInfo describes a recording in API responses.
+type terminalRecordingInfo struct {
+	ID        string     `json:"id"`
+	StartedAt time.Time  `json:"startedAt"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+	Width     int        `json:"width"`
+	Height    int        `json:"height"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+func newTerminalRecording(sessionID string, cols, rows int) *terminalRecording {
+	return &terminalRecording{
+		ID:        uuid.New().String(),
+		SessionID: sessionID,
+		StartedAt: time.Now(),
+		now:       time.Now,
+		width:     cols,
+		height:    rows,
+	}
+}
+
+// output records terminal output. Multi-byte characters split across reads
+// are held back until complete, since cast events must be valid UTF-8.
+func (rec *terminalRecording) output(p []byte) {
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	data := append(rec.pending, p...)
+	complete := len(data)
+	// A rune is at most 4 bytes, so only the last 3 can start an incomplete one
+	for i := len(data) - 1; i >= 0 && i >= len(data)-3; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				complete = i
+			}
+			break
+		}
+	}
+	rec.pending = append([]byte(nil), data[complete:]...)
+	if complete > 0 {
+		rec.event("o", string(data[:complete]))
+	}
+}
+
+// resize records a terminal size change.
+func (rec *terminalRecording) resize(cols, rows int) {
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.event("r", fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// finish marks the end of the recording.
+func (rec *terminalRecording) finish() {
+	if rec == nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.pending) > 0 {
+		rec.event("o", string(rec.pending))
+		rec.pending = nil
+	}
+	rec.endedAt = rec.now()
+}
+
+// event appends an event line. Must be called with mu held.
+func (rec *terminalRecording) event(code, data string) {
+	if rec.truncated {
+		return
+	}
+	line, err := json.Marshal([]any{rec.now().Sub(rec.StartedAt).Seconds(), code, data})
+	if err != nil {
+		return
+	}
+	if rec.events.Len()+len(line)+1 > maxTerminalRecordingBytes {
+		rec.truncated = true
+		return
+	}
+	rec.events.Write(line)
+	rec.events.WriteByte('\n')
+}
+
+// info returns the recording's metadata.
+func (rec *terminalRecording) info() terminalRecordingInfo {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	info := terminalRecordingInfo{
+		ID:        rec.ID,
+		StartedAt: rec.StartedAt,
+		Width:     rec.width,
+		Height:    rec.height,
+		Truncated: rec.truncated,
+	}
+	if !rec.endedAt.IsZero() {
+		endedAt := rec.endedAt
+		info.EndedAt = &endedAt
+	}
+	return info
+}
+
+// writeCast writes the recording as an asciinema v2 cast file.
+func (rec *terminalRecording) writeCast(w io.Writer) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	header, err := json.Marshal(map[string]any{
+		"version":   2,
+		"width":     rec.width,
+		"height":    rec.height,
+		"timestamp": rec.StartedAt.Unix(),
+		"env":       map[string]string{"TERM": "xterm-256color"},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(append(header, '\n')); err != nil {
+		return err
+	}
+	_, err = w.Write(rec.events.Bytes())
+	return err
+}
+
+// terminalRecordings keeps the most recent terminal recordings in memory.
+type terminalRecordings struct {
+	mu         sync.Mutex
+	recordings []*terminalRecording // Oldest first
+}
+
+// add stores a recording, dropping the oldest one if at capacity.
+func (r *terminalRecordings) add(rec *terminalRecording) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.recordings) >= maxTerminalRecordings {
+		r.recordings = r.recordings[1:]
+	}
+	r.recordings = append(r.recordings, rec)
+}
+
+// get returns a session's recording by ID, or nil.
+func (r *terminalRecordings) get(sessionID, id string) *terminalRecording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rec := range r.recordings {
+		if rec.SessionID == sessionID && rec.ID == id {
+			return rec
+		}
+	}
+	return nil
+}
+
+// list returns a session's recordings, oldest first.
+func (r *terminalRecordings) list(sessionID string) []*terminalRecording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var recs []*terminalRecording
+	for _, rec := range r.recordings {
+		if rec.SessionID == sessionID {
+			recs = append(recs, rec)
+		}
+	}
+	return recs
+}
+
+// ListTerminalRecordings lists the session's terminal recordings still in memory.
+// GET /api/projects/{projectId}/sessions/{sessionId}/terminal/recordings
+func (h *Handler) ListTerminalRecordings(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+
+	session, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || session.ProjectID != middleware.GetProjectID(ctx) {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	infos := []terminalRecordingInfo{}
+	for _, rec := range h.terminalRecordings.list(sessionID) {
+		infos = append(infos, rec.info())
+	}
+	h.JSON(w, http.StatusOK, map[string]any{"recordings": infos})
+}
+
+// GetTerminalCast returns a terminal recording as an asciinema v2 cast file.
+// Recordings are made for terminals opened with ?record=true.
+// GET /api/projects/{projectId}/sessions/{sessionId}/terminal/{terminalId}/cast
+func (h *Handler) GetTerminalCast(w http.ResponseWriter, r *http.Request) {
+	sessionID := chi.URLParam(r, "sessionId")
+	ctx := r.Context()
+
+	session, err := h.store.GetSessionByID(ctx, sessionID)
+	if err != nil || session.ProjectID != middleware.GetProjectID(ctx) {
+		h.Error(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	rec := h.terminalRecordings.get(sessionID, chi.URLParam(r, "terminalId"))
+	if rec == nil {
+		h.Error(w, http.StatusNotFound, "Terminal recording not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "terminal-"+rec.ID+".cast"))
+	_ = rec.writeCast(w)
+}
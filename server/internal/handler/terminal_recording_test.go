@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTerminalRecording_Cast(t *testing.T) {
+	rec := newTerminalRecording("session-1", 80, 24)
+	now := rec.StartedAt
+	rec.now = func() time.Time { return now }
+
+	now = now.Add(500 * time.Millisecond)
+	rec.output([]byte("$ echo h\xc3")) // "é" split across reads
+	now = now.Add(500 * time.Millisecond)
+	rec.output([]byte("\xa9\r\n"))
+	rec.resize(120, 40)
+	rec.finish()
+
+	var cast bytes.Buffer
+	if err := rec.writeCast(&cast); err != nil {
+		t.Fatalf("writeCast: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(cast.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected header and 3 events, got %q", lines)
+	}
+
+	var header map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+		t.Fatalf("invalid header: %v", err)
+	}
+	if header["version"] != float64(2) || header["width"] != float64(80) || header["height"] != float64(24) {
+		t.Errorf("unexpected header: %v", header)
+	}
+
+	want := [][]any{
+		{0.5, "o", "$ echo h"},
+		{1.0, "o", "é\r\n"},
+		{1.0, "r", "120x40"},
+	}
+	for i, line := range lines[1:] {
+		var event []any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", line, err)
+		}
+		if len(event) != 3 || event[0] != want[i][0] || event[1] != want[i][1] || event[2] != want[i][2] {
+			t.Errorf("event %d = %v, want %v", i, event, want[i])
+		}
+	}
+
+	if info := rec.info(); info.EndedAt == nil || info.Truncated {
+		t.Errorf("expected an ended, untruncated recording, got %+v", info)
+	}
+}
+
+func TestTerminalRecording_Truncates(t *testing.T) {
+	rec := newTerminalRecording("session-1", 80, 24)
+	chunk := bytes.Repeat([]byte("x"), 4096)
+	for range maxTerminalRecordingBytes/len(chunk) + 1 {
+		rec.output(chunk)
+	}
+	if !rec.info().Truncated {
+		t.Error("expected the recording to be truncated")
+	}
+	if rec.events.Len() > maxTerminalRecordingBytes {
+		t.Errorf("expected at most %d bytes of events, got %d", maxTerminalRecordingBytes, rec.events.Len())
+	}
+}
+
+func TestTerminalRecordings_DropsOldest(t *testing.T) {
+	var recs terminalRecordings
+	first := newTerminalRecording("session-1", 80, 24)
+	recs.add(first)
+	for range maxTerminalRecordings {
+		recs.add(newTerminalRecording("session-2", 80, 24))
+	}
+
+	if recs.get("session-1", first.ID) != nil {
+		t.Error("expected the oldest recording to be dropped")
+	}
+	if got := len(recs.list("session-2")); got != maxTerminalRecordings {
+		t.Errorf("expected %d recordings, got %d", maxTerminalRecordings, got)
+	}
+	// Recordings are only found through their own session
+	last := recs.list("session-2")[maxTerminalRecordings-1]
+	if recs.get("session-1", last.ID) != nil || recs.get("session-2", last.ID) != last {
+		t.Error("expected recordings to be scoped to their session")
+	}
+}
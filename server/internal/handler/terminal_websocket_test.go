@@ -152,7 +152,7 @@ func TestHandleTerminalSession_NormalFlow(t *testing.T) {
 	go func() {
 		defer close(done)
 		defer pty.Close() // PTY cleanup is caller's responsibility
-		handleTerminalSession(ctx, pty, server)
+		handleTerminalSession(ctx, pty, server, nil)
 	}()
 
 	// Read initial output
@@ -239,7 +239,7 @@ func TestHandleTerminalSession_HalfClose_ClientStopsWriting(t *testing.T) {
 	go func() {
 		defer close(done)
 		defer pty.Close()
-		handleTerminalSession(ctx, pty, server)
+		handleTerminalSession(ctx, pty, server, nil)
 	}()
 
 	// Collect output from client
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/sandbox/sandboxapi"
+	"github.com/obot-platform/discobot/server/internal/service"
+)
+
+// maxUploadChunkSize matches the agent API's per-chunk limit.
+const maxUploadChunkSize = 16 << 20
+
+// uploadIDPattern matches the upload IDs generated by the agent API.
+var uploadIDPattern = regexp.MustCompile(`^[a-f0-9]{32}$`)
+
+// CreateSessionUpload starts a resumable upload to a session's workspace.
+// POST /api/projects/{projectId}/sessions/{sessionId}/files/uploads
+func (h *Handler) CreateSessionUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+
+	var req sandboxapi.CreateUploadRequest
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Path == "" {
+		h.Error(w, http.StatusBadRequest, "path is required")
+		return
+	}
+	if req.Size < 0 {
+		h.Error(w, http.StatusBadRequest, "size must not be negative")
+		return
+	}
+
+	result, err := h.chatService.CreateUpload(ctx, projectID, sessionID, &req)
+	if err != nil {
+		h.Error(w, uploadErrorStatus(err), err.Error())
+		return
+	}
+
+	h.JSON(w, http.StatusOK, result)
+}
+
+// GetSessionUpload returns a resumable upload's progress.
+// GET /api/projects/{projectId}/sessions/{sessionId}/files/uploads/{uploadId}
+func (h *Handler) GetSessionUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+	uploadID := chi.URLParam(r, "uploadId")
+
+	if !uploadIDPattern.MatchString(uploadID) {
+		h.Error(w, http.StatusBadRequest, "Invalid upload ID")
+		return
+	}
+
+	result, err := h.chatService.GetUpload(ctx, projectID, sessionID, uploadID)
+	if err != nil {
+		h.Error(w, uploadErrorStatus(err), err.Error())
+		return
+	}
+
+	h.JSON(w, http.StatusOK, result)
+}
+
+// AppendSessionUpload appends the raw request body to a resumable upload.
+// PUT /api/projects/{projectId}/sessions/{sessionId}/files/uploads/{uploadId}?offset=N
+// A chunk that doesn't start at the upload's current offset is rejected with
+// 409 and the offset to resume from.
+func (h *Handler) AppendSessionUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+	uploadID := chi.URLParam(r, "uploadId")
+
+	if !uploadIDPattern.MatchString(uploadID) {
+		h.Error(w, http.StatusBadRequest, "Invalid upload ID")
+		return
+	}
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		h.Error(w, http.StatusBadRequest, "offset query parameter required")
+		return
+	}
+
+	chunk, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxUploadChunkSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.Error(w, http.StatusRequestEntityTooLarge, "Chunk exceeds 16MB")
+			return
+		}
+		h.Error(w, http.StatusBadRequest, "Failed to read chunk")
+		return
+	}
+
+	result, err := h.chatService.AppendUpload(ctx, projectID, sessionID, uploadID, offset, chunk)
+	if err != nil {
+		var offsetErr *service.UploadOffsetError
+		if errors.As(err, &offsetErr) {
+			h.JSON(w, http.StatusConflict, sandboxapi.UploadOffsetConflictResponse{
+				Error:  offsetErr.Error(),
+				Offset: offsetErr.Offset,
+			})
+			return
+		}
+		h.Error(w, uploadErrorStatus(err), err.Error())
+		return
+	}
+
+	h.JSON(w, http.StatusOK, result)
+}
+
+// AbortSessionUpload cancels a resumable upload and discards its data.
+// DELETE /api/projects/{projectId}/sessions/{sessionId}/files/uploads/{uploadId}
+func (h *Handler) AbortSessionUpload(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	sessionID := chi.URLParam(r, "sessionId")
+	uploadID := chi.URLParam(r, "uploadId")
+
+	if !uploadIDPattern.MatchString(uploadID) {
+		h.Error(w, http.StatusBadRequest, "Invalid upload ID")
+		return
+	}
+
+	result, err := h.chatService.AbortUpload(ctx, projectID, sessionID, uploadID)
+	if err != nil {
+		h.Error(w, uploadErrorStatus(err), err.Error())
+		return
+	}
+
+	h.JSON(w, http.StatusOK, result)
+}
+
+// uploadErrorStatus maps upload errors relayed from the sandbox to HTTP statuses.
+func uploadErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "status 413"):
+		return http.StatusRequestEntityTooLarge
+	case strings.Contains(msg, "status 403"):
+		return http.StatusForbidden
+	case strings.Contains(msg, "status 400"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -1,12 +1,16 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/obot-platform/discobot/server/internal/jobs"
 	"github.com/obot-platform/discobot/server/internal/middleware"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/service"
+	"github.com/obot-platform/discobot/server/internal/store"
 )
 
 // ListWorkspaces returns all workspaces for a project
@@ -27,10 +31,25 @@ func (h *Handler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
 	projectID := middleware.GetProjectID(r.Context())
 
 	var req struct {
-		Path        string  `json:"path"`
-		DisplayName *string `json:"displayName"`
-		SourceType  string  `json:"sourceType"`
-		Provider    string  `json:"provider"`
+		Path          string  `json:"path"`
+		DisplayName   *string `json:"displayName"`
+		SourceType    string  `json:"sourceType"`
+		Provider      string  `json:"provider"`
+		NetworkMode   string  `json:"networkMode"`
+		RestartPolicy string  `json:"restartPolicy"`
+		StopSignal    string  `json:"stopSignal"`
+		CPUCores      float64 `json:"cpuCores"`
+		MemoryMB      int     `json:"memoryMB"`
+		DiskMB        int     `json:"diskMB"`
+		ExtraPorts    []int   `json:"extraPorts"`
+		// DisableProxyCache turns off the sandbox proxy's response cache
+		DisableProxyCache bool `json:"disableProxyCache"`
+		// CloneDepth, CloneAllBranches, and CloneSubmodules control how sandboxes clone the workspace
+		CloneDepth       int  `json:"cloneDepth"`
+		CloneAllBranches bool `json:"cloneAllBranches"`
+		CloneSubmodules  bool `json:"cloneSubmodules"`
+		// Filesystem forces overlayfs or agentfs for the workspace's sessions
+		Filesystem string `json:"filesystem"`
 	}
 	if err := h.DecodeJSON(r, &req); err != nil {
 		h.Error(w, http.StatusBadRequest, "Invalid request body")
@@ -40,6 +59,39 @@ func (h *Handler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
 		h.Error(w, http.StatusBadRequest, "Path is required")
 		return
 	}
+	if !sandbox.ValidNetworkMode(req.NetworkMode) {
+		h.Error(w, http.StatusBadRequest, "networkMode must be one of: proxied, isolated, open")
+		return
+	}
+	if !sandbox.ValidRestartPolicy(req.RestartPolicy) {
+		h.Error(w, http.StatusBadRequest, "restartPolicy must be one of: no, on-failure, unless-stopped")
+		return
+	}
+	if !sandbox.ValidStopSignal(req.StopSignal) {
+		h.Error(w, http.StatusBadRequest, "stopSignal must be SIGINT, SIGTERM or SIGQUIT (or INT, TERM, QUIT, 2, 15, 3)")
+		return
+	}
+	resources := sandbox.ResourceConfig{MemoryMB: req.MemoryMB, CPUCores: req.CPUCores, DiskMB: req.DiskMB}
+	if err := resources.Validate(); err != nil {
+		h.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := h.checkDiskLimit(req.Provider, req.DiskMB); err != nil {
+		h.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := sandbox.ValidateExtraPorts(req.ExtraPorts); err != nil {
+		h.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.CloneDepth < 0 {
+		h.Error(w, http.StatusBadRequest, "cloneDepth must not be negative")
+		return
+	}
+	if !sandbox.ValidFilesystem(req.Filesystem) {
+		h.Error(w, http.StatusBadRequest, "filesystem must be one of: overlayfs, agentfs")
+		return
+	}
 	if req.SourceType == "" {
 		req.SourceType = "local"
 	}
@@ -51,21 +103,48 @@ func (h *Handler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update display name if provided
-	if req.DisplayName != nil {
+	// Update display name, network mode, restart policy, stop signal, resource limits, extra ports, proxy caching, clone options, and filesystem if provided
+	if req.DisplayName != nil || req.NetworkMode != "" || req.RestartPolicy != "" || req.StopSignal != "" || resources != (sandbox.ResourceConfig{}) || len(req.ExtraPorts) > 0 ||
+		req.DisableProxyCache || req.CloneDepth > 0 || req.CloneAllBranches || req.CloneSubmodules || req.Filesystem != "" {
 		// Get the model workspace and update it
 		modelWorkspace, err := h.store.GetWorkspaceByID(r.Context(), workspace.ID)
 		if err != nil {
 			h.Error(w, http.StatusInternalServerError, "Failed to get workspace for update")
 			return
 		}
-		modelWorkspace.DisplayName = req.DisplayName
+		if req.DisplayName != nil {
+			modelWorkspace.DisplayName = req.DisplayName
+		}
+		modelWorkspace.NetworkMode = req.NetworkMode
+		modelWorkspace.RestartPolicy = req.RestartPolicy
+		modelWorkspace.StopSignal = req.StopSignal
+		modelWorkspace.CPUCores = req.CPUCores
+		modelWorkspace.MemoryMB = req.MemoryMB
+		modelWorkspace.DiskMB = req.DiskMB
+		modelWorkspace.ExtraPorts = req.ExtraPorts
+		modelWorkspace.DisableProxyCache = req.DisableProxyCache
+		modelWorkspace.CloneDepth = req.CloneDepth
+		modelWorkspace.CloneAllBranches = req.CloneAllBranches
+		modelWorkspace.CloneSubmodules = req.CloneSubmodules
+		modelWorkspace.Filesystem = req.Filesystem
 		if err := h.store.UpdateWorkspace(r.Context(), modelWorkspace); err != nil {
 			h.Error(w, http.StatusInternalServerError, "Failed to update workspace")
 			return
 		}
 		// Update the response object
 		workspace.DisplayName = req.DisplayName
+		workspace.NetworkMode = sandbox.EffectiveNetworkMode(req.NetworkMode)
+		workspace.RestartPolicy = req.RestartPolicy
+		workspace.StopSignal = req.StopSignal
+		workspace.CPUCores = req.CPUCores
+		workspace.MemoryMB = req.MemoryMB
+		workspace.DiskMB = req.DiskMB
+		workspace.ExtraPorts = req.ExtraPorts
+		workspace.DisableProxyCache = req.DisableProxyCache
+		workspace.CloneDepth = req.CloneDepth
+		workspace.CloneAllBranches = req.CloneAllBranches
+		workspace.CloneSubmodules = req.CloneSubmodules
+		workspace.Filesystem = req.Filesystem
 	}
 
 	// Enqueue workspace initialization job
@@ -90,6 +169,68 @@ func (h *Handler) GetWorkspace(w http.ResponseWriter, r *http.Request) {
 	h.JSON(w, http.StatusOK, workspace)
 }
 
+// GetWorkspaceDefaultAgent returns the workspace's default agent setting and
+// the agent its sessions without one fall back to: the workspace's default,
+// else the project's.
+// GET /api/projects/{projectId}/workspaces/{workspaceId}/default-agent
+func (h *Handler) GetWorkspaceDefaultAgent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	workspaceID := chi.URLParam(r, "workspaceId")
+
+	result, err := h.workspaceService.GetDefaultAgent(ctx, projectID, workspaceID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			h.Error(w, http.StatusNotFound, "Workspace not found")
+			return
+		}
+		h.Error(w, http.StatusInternalServerError, "Failed to get default agent")
+		return
+	}
+
+	h.JSON(w, http.StatusOK, result)
+}
+
+// SetWorkspaceDefaultAgent sets the agent used by the workspace's sessions
+// that don't have one, ahead of the project's default agent. A null agentId
+// clears it.
+// PUT /api/projects/{projectId}/workspaces/{workspaceId}/default-agent
+func (h *Handler) SetWorkspaceDefaultAgent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	projectID := middleware.GetProjectID(ctx)
+	workspaceID := chi.URLParam(r, "workspaceId")
+
+	var req struct {
+		AgentID *string `json:"agentId"`
+	}
+	if err := h.DecodeJSON(r, &req); err != nil {
+		h.Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.AgentID != nil && *req.AgentID == "" {
+		req.AgentID = nil
+	}
+
+	if err := h.workspaceService.SetDefaultAgent(ctx, projectID, workspaceID, req.AgentID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			h.Error(w, http.StatusNotFound, "Workspace not found")
+		case errors.Is(err, service.ErrAgentNotInProject):
+			h.Error(w, http.StatusBadRequest, "Agent not found in project")
+		default:
+			h.Error(w, http.StatusInternalServerError, "Failed to set default agent")
+		}
+		return
+	}
+
+	result, err := h.workspaceService.GetDefaultAgent(ctx, projectID, workspaceID)
+	if err != nil {
+		h.Error(w, http.StatusInternalServerError, "Failed to get default agent")
+		return
+	}
+	h.JSON(w, http.StatusOK, result)
+}
+
 // UpdateWorkspace updates a workspace
 func (h *Handler) UpdateWorkspace(w http.ResponseWriter, r *http.Request) {
 	workspaceID := chi.URLParam(r, "workspaceId")
@@ -138,6 +279,120 @@ func (h *Handler) UpdateWorkspace(w http.ResponseWriter, r *http.Request) {
 		modified = true
 	}
 
+	// Update network mode if provided. The new mode applies to sandboxes
+	// created after the change; running sandboxes keep their current rules.
+	if networkMode, ok := rawReq["networkMode"].(string); ok {
+		if !sandbox.ValidNetworkMode(networkMode) {
+			h.Error(w, http.StatusBadRequest, "networkMode must be one of: proxied, isolated, open")
+			return
+		}
+		workspace.NetworkMode = networkMode
+		modified = true
+	}
+
+	// Update restart policy if provided ("" reverts to the server default).
+	// Like network mode, it applies to sandboxes created after the change.
+	if restartPolicy, ok := rawReq["restartPolicy"].(string); ok {
+		if !sandbox.ValidRestartPolicy(restartPolicy) {
+			h.Error(w, http.StatusBadRequest, "restartPolicy must be one of: no, on-failure, unless-stopped")
+			return
+		}
+		workspace.RestartPolicy = restartPolicy
+		modified = true
+	}
+
+	// Update stop signal if provided ("" reverts to the server default). It
+	// is fixed when a sandbox is created, like the restart policy.
+	if stopSignal, ok := rawReq["stopSignal"].(string); ok {
+		if !sandbox.ValidStopSignal(stopSignal) {
+			h.Error(w, http.StatusBadRequest, "stopSignal must be SIGINT, SIGTERM or SIGQUIT (or INT, TERM, QUIT, 2, 15, 3)")
+			return
+		}
+		workspace.StopSignal = stopSignal
+		modified = true
+	}
+
+	// Update resource limits if provided (0 reverts to the server default).
+	// They apply to sandboxes created after the change.
+	if cpuCores, ok := rawReq["cpuCores"].(float64); ok {
+		workspace.CPUCores = cpuCores
+		modified = true
+	}
+	if memoryMB, ok := rawReq["memoryMB"].(float64); ok {
+		workspace.MemoryMB = int(memoryMB)
+		modified = true
+	}
+	if diskMB, ok := rawReq["diskMB"].(float64); ok {
+		workspace.DiskMB = int(diskMB)
+		modified = true
+	}
+	resources := sandbox.ResourceConfig{MemoryMB: workspace.MemoryMB, CPUCores: workspace.CPUCores, DiskMB: workspace.DiskMB}
+	if err := resources.Validate(); err != nil {
+		h.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, ok := rawReq["diskMB"]; ok {
+		if err := h.checkDiskLimit(workspace.Provider, workspace.DiskMB); err != nil {
+			h.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	// Update extra ports if the field was sent (null or [] clears them).
+	// Ports are published when a sandbox is created, so running sandboxes
+	// keep their current ports.
+	if raw, ok := rawReq["extraPorts"]; ok {
+		extraPorts, ok := parseExtraPorts(raw)
+		if !ok {
+			h.Error(w, http.StatusBadRequest, "extraPorts must be an array of port numbers")
+			return
+		}
+		if err := sandbox.ValidateExtraPorts(extraPorts); err != nil {
+			h.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		workspace.ExtraPorts = extraPorts
+		modified = true
+	}
+
+	// Update proxy caching if provided. The proxy config is written when a
+	// sandbox starts, so running sandboxes keep their current setting.
+	if disableProxyCache, ok := rawReq["disableProxyCache"].(bool); ok {
+		workspace.DisableProxyCache = disableProxyCache
+		modified = true
+	}
+
+	// Update clone options if provided. The workspace is cloned when a
+	// session's sandbox is first created, so existing sessions keep theirs.
+	if cloneDepth, ok := rawReq["cloneDepth"].(float64); ok {
+		if cloneDepth < 0 {
+			h.Error(w, http.StatusBadRequest, "cloneDepth must not be negative")
+			return
+		}
+		workspace.CloneDepth = int(cloneDepth)
+		modified = true
+	}
+	if cloneAllBranches, ok := rawReq["cloneAllBranches"].(bool); ok {
+		workspace.CloneAllBranches = cloneAllBranches
+		modified = true
+	}
+	if cloneSubmodules, ok := rawReq["cloneSubmodules"].(bool); ok {
+		workspace.CloneSubmodules = cloneSubmodules
+		modified = true
+	}
+
+	// Update filesystem if provided ("" reverts to auto-detection). It is
+	// passed to the agent when a sandbox is created, so it applies to
+	// sandboxes created after the change.
+	if filesystem, ok := rawReq["filesystem"].(string); ok {
+		if !sandbox.ValidFilesystem(filesystem) {
+			h.Error(w, http.StatusBadRequest, "filesystem must be one of: overlayfs, agentfs")
+			return
+		}
+		workspace.Filesystem = filesystem
+		modified = true
+	}
+
 	// Note: Provider cannot be updated after creation - it's set only on Create
 
 	// Save if we modified the workspace
@@ -194,3 +449,41 @@ func (h *Handler) GetProvider(w http.ResponseWriter, r *http.Request) {
 
 	h.JSON(w, http.StatusOK, status)
 }
+
+// parseExtraPorts converts a decoded JSON extraPorts value (null or an array
+// of integers) to ports. Returns false if the value has any other shape.
+func parseExtraPorts(raw any) ([]int, bool) {
+	if raw == nil {
+		return nil, true
+	}
+	values, ok := raw.([]any)
+	if !ok {
+		return nil, false
+	}
+	ports := make([]int, 0, len(values))
+	for _, v := range values {
+		n, ok := v.(float64)
+		if !ok || n != float64(int(n)) {
+			return nil, false
+		}
+		ports = append(ports, int(n))
+	}
+	return ports, true
+}
+
+// checkDiskLimit returns an error if diskMB sets a disk limit that the named
+// sandbox provider can't apply, such as Docker on a storage driver without
+// size options.
+func (h *Handler) checkDiskLimit(providerName string, diskMB int) error {
+	if diskMB == 0 || h.sandboxManager == nil {
+		return nil
+	}
+	provider, err := h.sandboxManager.GetProvider(providerName)
+	if err != nil {
+		return nil
+	}
+	if checker, ok := provider.(sandbox.DiskLimitChecker); ok {
+		return checker.CheckDiskLimits()
+	}
+	return nil
+}
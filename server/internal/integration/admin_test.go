@@ -0,0 +1,133 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestGetEffectiveConfig(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("ops@example.com")
+	client := ts.AuthenticatedClient(user)
+
+	resp := client.Get("/api/admin/config")
+	AssertStatus(t, resp, http.StatusForbidden)
+	resp.Body.Close()
+
+	ts.Config.AdminEmails = []string{"OPS@example.com"}
+	ts.Config.GitHubClientSecret = "gh-secret"
+
+	resp = client.Get("/api/admin/config")
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+
+	var result struct {
+		Providers []string `json:"providers"`
+		Settings  []struct {
+			Name     string `json:"name"`
+			Value    any    `json:"value"`
+			Source   string `json:"source"`
+			Redacted bool   `json:"redacted"`
+		} `json:"settings"`
+	}
+	ParseJSON(t, resp, &result)
+
+	if len(result.Providers) != 1 || result.Providers[0] != "mock" {
+		t.Errorf("Expected providers [mock], got %v", result.Providers)
+	}
+	values := map[string]any{}
+	for _, s := range result.Settings {
+		values[s.Name] = s.Value
+		if s.Source == "" {
+			t.Errorf("Expected a source for %s", s.Name)
+		}
+	}
+	if values["PORT"] != float64(8080) {
+		t.Errorf("Expected PORT 8080, got %v", values["PORT"])
+	}
+	for _, name := range []string{"SESSION_SECRET", "ENCRYPTION_KEY", "GITHUB_CLIENT_SECRET"} {
+		if values[name] != "[redacted]" {
+			t.Errorf("Expected %s to be redacted, got %v", name, values[name])
+		}
+	}
+	if values["GOOGLE_CLIENT_SECRET"] != "" {
+		t.Errorf("Expected unset GOOGLE_CLIENT_SECRET to be empty, got %v", values["GOOGLE_CLIENT_SECRET"])
+	}
+}
+
+func TestQuiesce(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("ops@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	agent := ts.CreateTestAgent(project, "Test Agent", "claude-code")
+	existing := ts.CreateTestSession(workspace, "Existing")
+	client := ts.AuthenticatedClient(user)
+	ts.Config.AdminEmails = []string{"ops@example.com"}
+
+	readyz := func() int {
+		t.Helper()
+		resp, err := http.Get(ts.Server.URL + "/readyz")
+		if err != nil {
+			t.Fatalf("Failed to get /readyz: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	newSession := func(id string) *http.Response {
+		return client.Post("/api/projects/"+project.ID+"/chat", map[string]any{
+			"id": id,
+			"messages": []map[string]any{
+				{"id": "msg-1", "role": "user", "parts": []map[string]any{{"type": "text", "text": "Hello"}}},
+			},
+			"workspaceId": workspace.ID,
+			"agentId":     agent.ID,
+		})
+	}
+
+	if code := readyz(); code != http.StatusOK {
+		t.Fatalf("Expected /readyz 200 before quiescing, got %d", code)
+	}
+
+	resp := client.Post("/api/admin/quiesce", nil)
+	AssertStatus(t, resp, http.StatusOK)
+	var state struct {
+		Quiesced bool    `json:"quiesced"`
+		Since    *string `json:"since"`
+	}
+	ParseJSON(t, resp, &state)
+	resp.Body.Close()
+	if !state.Quiesced || state.Since == nil {
+		t.Errorf("Expected quiesced with a start time, got %+v", state)
+	}
+
+	if code := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected /readyz 503 while quiesced, got %d", code)
+	}
+
+	resp = newSession("quiesced-session")
+	AssertStatus(t, resp, http.StatusServiceUnavailable)
+	resp.Body.Close()
+	if _, err := ts.Store.GetSessionByID(context.Background(), "quiesced-session"); err == nil {
+		t.Error("Expected no session to be created while quiesced")
+	}
+
+	// Existing sessions are still served
+	resp = client.Get("/api/projects/" + project.ID + "/sessions/" + existing.ID)
+	AssertStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+
+	resp = client.Delete("/api/admin/quiesce")
+	AssertStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+
+	if code := readyz(); code != http.StatusOK {
+		t.Errorf("Expected /readyz 200 after unquiescing, got %d", code)
+	}
+	resp = newSession("resumed-session")
+	AssertStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+}
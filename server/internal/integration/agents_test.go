@@ -2,6 +2,7 @@ package integration
 
 import (
 	"net/http"
+	"strings"
 	"testing"
 )
 
@@ -327,3 +328,36 @@ func TestSetDefaultAgent_UnsetsExistingDefault(t *testing.T) {
 		t.Errorf("Expected exactly 1 default agent, got %d", defaultCount)
 	}
 }
+
+func TestCreateAgent_RequiredCredentials(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	client := ts.AuthenticatedClient(user)
+
+	// Copilot CLI can't run without a GitHub Copilot credential
+	resp := client.Post("/api/projects/"+project.ID+"/agents", map[string]interface{}{
+		"agentType": "copilot-cli",
+	})
+	AssertStatus(t, resp, http.StatusBadRequest)
+	var errResp map[string]string
+	ParseJSON(t, resp, &errResp)
+	if !strings.Contains(errResp["error"], "github-copilot") {
+		t.Errorf("Expected error to name the missing provider, got %q", errResp["error"])
+	}
+
+	resp = client.Post("/api/projects/"+project.ID+"/credentials", map[string]string{
+		"provider": "github-copilot",
+		"name":     "My Copilot Token",
+		"apiKey":   "ghu-test-123456",
+	})
+	AssertStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+
+	resp = client.Post("/api/projects/"+project.ID+"/agents", map[string]interface{}{
+		"agentType": "copilot-cli",
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusCreated)
+}
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/obot-platform/discobot/server/internal/git"
+	"github.com/obot-platform/discobot/server/internal/service"
 )
 
 // createTestGitRepo creates a test git repository with some initial content
@@ -194,6 +195,62 @@ func TestGitReadFile(t *testing.T) {
 	}
 }
 
+func TestGitWriteFile_ExpectedHash(t *testing.T) {
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("gituser@example.com")
+	project := ts.CreateTestProject(user, "test-project")
+	client := ts.AuthenticatedClient(user)
+
+	repoPath := createTestGitRepo(t)
+	workspace := ts.CreateTestWorkspace(project, repoPath)
+	fileURL := fmt.Sprintf("/api/projects/%s/workspaces/%s/git/file", project.ID, workspace.ID)
+
+	resp := client.Get(fileURL + "?path=README.md")
+	AssertStatus(t, resp, http.StatusOK)
+	var read struct {
+		Hash string `json:"hash"`
+	}
+	ParseJSON(t, resp, &read)
+	if read.Hash != service.ContentHash([]byte("# Test Repo\n")) {
+		t.Fatalf("Unexpected hash: %q", read.Hash)
+	}
+
+	// First writer wins
+	resp = client.Post(fileURL, map[string]string{
+		"path":         "README.md",
+		"content":      "# Edited by A\n",
+		"expectedHash": read.Hash,
+	})
+	AssertStatus(t, resp, http.StatusOK)
+	var written struct {
+		Hash string `json:"hash"`
+	}
+	ParseJSON(t, resp, &written)
+
+	// Second writer with the same stale hash gets a conflict
+	resp = client.Post(fileURL, map[string]string{
+		"path":         "README.md",
+		"content":      "# Edited by B\n",
+		"expectedHash": read.Hash,
+	})
+	AssertStatus(t, resp, http.StatusConflict)
+	var conflict struct {
+		CurrentHash string `json:"currentHash"`
+	}
+	ParseJSON(t, resp, &conflict)
+	if conflict.CurrentHash != written.Hash {
+		t.Errorf("Expected currentHash %q, got %q", written.Hash, conflict.CurrentHash)
+	}
+
+	content, err := os.ReadFile(filepath.Join(repoPath, "README.md"))
+	if err != nil {
+		t.Fatalf("Failed to read README.md: %v", err)
+	}
+	if string(content) != "# Edited by A\n" {
+		t.Errorf("Conflicting write was applied: %q", content)
+	}
+}
+
 func TestGitWriteAndStage(t *testing.T) {
 	ts := NewTestServer(t)
 	user := ts.CreateTestUser("gituser@example.com")
@@ -377,6 +434,48 @@ func TestGitCheckout(t *testing.T) {
 	AssertStatus(t, resp, http.StatusOK)
 }
 
+func TestGitCheckout_Conflict(t *testing.T) {
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("gituser@example.com")
+	project := ts.CreateTestProject(user, "test-project")
+	client := ts.AuthenticatedClient(user)
+
+	// feature-branch changes README.md, which is also edited locally
+	repoPath := createTestGitRepo(t)
+	runGit(t, repoPath, "checkout", "-b", "feature-branch")
+	if err := os.WriteFile(filepath.Join(repoPath, "README.md"), []byte("# Feature\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, repoPath, "commit", "-am", "Feature change")
+	runGit(t, repoPath, "checkout", "-")
+
+	workspace := ts.CreateTestWorkspace(project, repoPath)
+	if err := os.WriteFile(filepath.Join(repoPath, "README.md"), []byte("# Local edit\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := client.Post(fmt.Sprintf("/api/projects/%s/workspaces/%s/git/checkout", project.ID, workspace.ID), map[string]string{
+		"ref": "feature-branch",
+	})
+	AssertStatus(t, resp, http.StatusConflict)
+
+	var result struct {
+		Error    string            `json:"error"`
+		Conflict git.ConflictError `json:"conflict"`
+	}
+	ParseJSON(t, resp, &result)
+
+	if result.Conflict.Reason != git.ConflictDirtyWorkTree {
+		t.Errorf("Expected reason %s, got %s", git.ConflictDirtyWorkTree, result.Conflict.Reason)
+	}
+	if len(result.Conflict.Files) != 1 || result.Conflict.Files[0] != "README.md" {
+		t.Errorf("Expected conflicting files [README.md], got %v", result.Conflict.Files)
+	}
+	if len(result.Conflict.Options) == 0 {
+		t.Error("Expected suggested options")
+	}
+}
+
 func TestGitWorkspaceIsolation(t *testing.T) {
 	// This test verifies that workspaces are isolated from each other
 	gitDir := t.TempDir()
@@ -1,8 +1,14 @@
 package integration
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"strings"
 	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/handler"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
 )
 
 func TestHealthEndpoint(t *testing.T) {
@@ -24,3 +30,40 @@ func TestHealthEndpoint(t *testing.T) {
 		t.Errorf("Expected status 'ok', got '%s'", result["status"])
 	}
 }
+
+func TestReadyzProviderHealth(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+
+	readyz := func() (int, handler.ReadyzResponse) {
+		t.Helper()
+		resp, err := http.Get(ts.Server.URL + "/readyz")
+		if err != nil {
+			t.Fatalf("Failed to get /readyz: %v", err)
+		}
+		defer resp.Body.Close()
+		var result handler.ReadyzResponse
+		ParseJSON(t, resp, &result)
+		return resp.StatusCode, result
+	}
+
+	code, result := readyz()
+	if code != http.StatusOK || result.Status != "ready" {
+		t.Fatalf("Expected /readyz 200 ready, got %d %q", code, result.Status)
+	}
+	if mock := result.Providers["mock"]; !mock.Healthy || !mock.Required {
+		t.Errorf("Expected mock provider healthy and required, got %+v", mock)
+	}
+
+	ts.MockSandbox.HealthyFunc = func(context.Context) error {
+		return fmt.Errorf("%w: images downloading", sandbox.ErrNotReady)
+	}
+	code, result = readyz()
+	if code != http.StatusServiceUnavailable || result.Status != "unavailable" {
+		t.Fatalf("Expected /readyz 503 unavailable, got %d %q", code, result.Status)
+	}
+	mock := result.Providers["mock"]
+	if mock.Healthy || !strings.Contains(mock.Error, "images downloading") {
+		t.Errorf("Expected mock provider unhealthy with its error, got %+v", mock)
+	}
+}
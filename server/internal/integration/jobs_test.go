@@ -0,0 +1,85 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
+)
+
+func TestStreamJobLogs(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspaceWithGitRepo(project)
+	agent := ts.CreateTestAgent(project, "Test Agent", "claude-code")
+	session := ts.CreateTestSessionWithAgent(workspace, agent, "Test Session")
+	ctx := context.Background()
+
+	if err := ts.Store.UpdateSessionStatus(ctx, session.ID, model.SessionStatusInitializing, nil); err != nil {
+		t.Fatalf("Failed to update session status: %v", err)
+	}
+	if err := ts.Handler.JobQueue().Enqueue(ctx, jobs.SessionInitPayload{
+		ProjectID:   project.ID,
+		SessionID:   session.ID,
+		WorkspaceID: workspace.ID,
+		AgentID:     agent.ID,
+	}); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	job, err := ts.Store.GetJobByResourceID(ctx, jobs.ResourceTypeSession, session.ID)
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+
+	dial := func(token, jobID string) (*websocket.Conn, *http.Response, error) {
+		url := "ws" + strings.TrimPrefix(ts.Server.URL, "http") + "/api/jobs/" + jobID + "/logs"
+		return websocket.DefaultDialer.Dial(url, http.Header{"Cookie": {"discobot_session=" + token}})
+	}
+
+	// Whether the job has run yet or not, the stream has its lines and ends
+	conn, _, err := dial(user.Token, job.ID)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	var messages []string
+	for {
+		var msg struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("Stream ended without a done message (got %v): %v", messages, err)
+		}
+		if msg.Type == "done" {
+			if msg.Status != string(model.JobStatusCompleted) {
+				t.Errorf("Expected job status %s, got %s (logs: %v)", model.JobStatusCompleted, msg.Status, messages)
+			}
+			break
+		}
+		messages = append(messages, msg.Message)
+	}
+	if !strings.Contains(strings.Join(messages, "\n"), "Initializing session "+session.ID) {
+		t.Errorf("Expected the executor's log lines, got %v", messages)
+	}
+
+	// Jobs of other projects aren't visible
+	other := ts.CreateTestUser("other@example.com")
+	if _, resp, err := dial(other.Token, job.ID); err == nil || resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for another user's job, got %v", err)
+	}
+	if _, resp, err := dial(user.Token, "nonexistent"); err == nil || resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown job, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package integration
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSessionKV(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	agent := ts.CreateTestAgent(project, "Test Agent", "claude-code")
+	client := ts.AuthenticatedClient(user)
+
+	// Stand in for the agent API's key-value store
+	var mu sync.Mutex
+	values := map[string]string{}
+	ts.MockSandbox.HTTPHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := strings.CutPrefix(r.URL.Path, "/kv/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case "PUT":
+			var req struct {
+				Value string `json:"value"`
+			}
+			body, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(body, &req)
+			if len(req.Value) > 8 {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				w.Write([]byte(`{"error":"Value exceeds 8 bytes"}`))
+				return
+			}
+			values[key] = req.Value
+			json.NewEncoder(w).Encode(map[string]any{"key": key, "value": req.Value, "size": len(req.Value)})
+		case "GET", "DELETE":
+			value, exists := values[key]
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":"Key not found"}`))
+				return
+			}
+			if r.Method == "DELETE" {
+				delete(values, key)
+				json.NewEncoder(w).Encode(map[string]any{"key": key})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"key": key, "value": value, "size": len(value)})
+		}
+	})
+
+	session := ts.CreateTestSessionWithSandbox(workspace, agent, "Test Session")
+	kvPath := "/api/projects/" + project.ID + "/sessions/" + session.ID + "/kv/"
+
+	resp := client.Put(kvPath+"progress", map[string]any{"value": "step 3"})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+
+	resp = client.Get(kvPath + "progress")
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+	var entry map[string]interface{}
+	ParseJSON(t, resp, &entry)
+	if entry["value"] != "step 3" {
+		t.Errorf("Expected value 'step 3', got %v", entry["value"])
+	}
+
+	resp = client.Put(kvPath+"progress", map[string]any{"value": "way too long"})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusRequestEntityTooLarge)
+
+	resp = client.Get(kvPath + ".hidden")
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+
+	resp = client.Delete(kvPath + "progress")
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+
+	resp = client.Get(kvPath + "progress")
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusNotFound)
+}
@@ -0,0 +1,141 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSessionNetworkTest(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	agent := ts.CreateTestAgent(project, "Test Agent", "claude-code")
+	client := ts.AuthenticatedClient(user)
+
+	// Stand in for the agent API's network test, with the proxy blocking everything
+	var gotTarget string
+	ts.MockSandbox.HTTPHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/network/test" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Target string `json:"target"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		gotTarget = req.Target
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"target":    req.Target,
+			"url":       "https://" + req.Target + "/",
+			"reachable": false,
+			"dns":       map[string]any{"ok": true, "durationMs": 2, "addresses": []string{"93.184.215.14"}},
+			"proxy":     map[string]any{"used": true, "url": "http://localhost:17080", "allowed": false, "error": "Proxy closed the connection (host blocked)"},
+			"connect":   map[string]any{"ok": true, "durationMs": 1},
+		})
+	})
+
+	session := ts.CreateTestSessionWithSandbox(workspace, agent, "Test Session")
+	testPath := "/api/projects/" + project.ID + "/sessions/" + session.ID + "/network/test"
+
+	resp := client.Post(testPath, map[string]any{"target": " example.com "})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+	var result struct {
+		Reachable bool `json:"reachable"`
+		DNS       struct {
+			OK        bool     `json:"ok"`
+			Addresses []string `json:"addresses"`
+		} `json:"dns"`
+		Proxy struct {
+			Used    bool  `json:"used"`
+			Allowed *bool `json:"allowed"`
+		} `json:"proxy"`
+	}
+	ParseJSON(t, resp, &result)
+	if gotTarget != "example.com" {
+		t.Errorf("Expected trimmed target 'example.com', got %q", gotTarget)
+	}
+	if result.Reachable || !result.DNS.OK || len(result.DNS.Addresses) != 1 {
+		t.Errorf("Expected unreachable target with resolved DNS, got %+v", result)
+	}
+	if !result.Proxy.Used || result.Proxy.Allowed == nil || *result.Proxy.Allowed {
+		t.Errorf("Expected the proxy to have blocked the target, got %+v", result.Proxy)
+	}
+
+	resp = client.Post(testPath, map[string]any{"target": ""})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+
+	resp = client.Post(testPath, map[string]any{"target": "example.com", "timeoutMs": 60000})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestSessionProxyStats(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	agent := ts.CreateTestAgent(project, "Test Agent", "claude-code")
+	client := ts.AuthenticatedClient(user)
+
+	// Stand in for the agent API relaying the proxy's stats
+	var proxyStopped atomic.Bool
+	ts.MockSandbox.HTTPHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/proxy/stats" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if proxyStopped.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"error": "proxy is not running"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"since":  "2026-01-01T00:00:00Z",
+			"totals": map[string]any{"requests": 3, "blocked": 1, "bytes_received": 2048},
+			"domains": map[string]any{
+				"registry.npmjs.org": map[string]any{"requests": 2, "bytes_received": 2048, "cache_hits": 1, "cache_misses": 1, "cache_hit_rate": 0.5},
+				"evil.example.com":   map[string]any{"requests": 1, "blocked": 1},
+			},
+		})
+	})
+
+	session := ts.CreateTestSessionWithSandbox(workspace, agent, "Test Session")
+	statsPath := "/api/projects/" + project.ID + "/sessions/" + session.ID + "/proxy/stats"
+
+	resp := client.Get(statsPath)
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+	var result struct {
+		Totals struct {
+			Requests int64 `json:"requests"`
+			Blocked  int64 `json:"blocked"`
+		} `json:"totals"`
+		Domains map[string]struct {
+			Requests      int64   `json:"requests"`
+			BytesReceived int64   `json:"bytes_received"`
+			CacheHitRate  float64 `json:"cache_hit_rate"`
+		} `json:"domains"`
+	}
+	ParseJSON(t, resp, &result)
+	if result.Totals.Requests != 3 || result.Totals.Blocked != 1 {
+		t.Errorf("Expected 3 requests with 1 blocked, got %+v", result.Totals)
+	}
+	if npm := result.Domains["registry.npmjs.org"]; npm.Requests != 2 || npm.BytesReceived != 2048 || npm.CacheHitRate != 0.5 {
+		t.Errorf("Unexpected registry.npmjs.org stats: %+v", npm)
+	}
+
+	proxyStopped.Store(true)
+	resp = client.Get(statsPath)
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusServiceUnavailable)
+}
@@ -1,8 +1,15 @@
 package integration
 
 import (
+	"context"
 	"net/http"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
 )
 
 func TestListProjects_Unauthenticated(t *testing.T) {
@@ -73,6 +80,105 @@ func TestCreateProject_MissingName(t *testing.T) {
 	AssertStatus(t, resp, http.StatusBadRequest)
 }
 
+func TestCreateProject_DefaultWorkspace(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	client := ts.AuthenticatedClient(user)
+	testPath := createWorkspaceTestGitRepo(t)
+
+	resp := client.Post("/api/projects", map[string]any{
+		"name":             "Test Project",
+		"defaultWorkspace": map[string]string{"path": testPath, "displayName": "Main"},
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusCreated)
+
+	var project struct {
+		ID         string `json:"id"`
+		Workspaces []struct {
+			Path        string `json:"path"`
+			SourceType  string `json:"sourceType"`
+			DisplayName string `json:"displayName"`
+		} `json:"workspaces"`
+	}
+	ParseJSON(t, resp, &project)
+
+	if len(project.Workspaces) != 1 {
+		t.Fatalf("Expected 1 workspace, got %d", len(project.Workspaces))
+	}
+	ws := project.Workspaces[0]
+	if ws.Path != testPath || ws.SourceType != "local" || ws.DisplayName != "Main" {
+		t.Errorf("Unexpected workspace: %+v", ws)
+	}
+}
+
+func TestCreateProject_Template(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	client := ts.AuthenticatedClient(user)
+	ts.Config.ProjectTemplates = []config.ProjectTemplate{{
+		Name:       "starter",
+		Workspaces: []config.ProjectTemplateWorkspace{{Path: createWorkspaceTestGitRepo(t), SourceType: "local"}},
+		Agents:     []string{"claude-code"},
+	}}
+
+	resp := client.Get("/api/project-templates")
+	AssertStatus(t, resp, http.StatusOK)
+	var list struct {
+		Templates []config.ProjectTemplate `json:"templates"`
+	}
+	ParseJSON(t, resp, &list)
+	resp.Body.Close()
+	if len(list.Templates) != 1 || list.Templates[0].Name != "starter" {
+		t.Errorf("Expected the starter template, got %+v", list.Templates)
+	}
+
+	resp = client.Post("/api/projects", map[string]string{"name": "Test Project", "template": "starter"})
+	AssertStatus(t, resp, http.StatusCreated)
+	var project struct {
+		ID string `json:"id"`
+	}
+	ParseJSON(t, resp, &project)
+	resp.Body.Close()
+
+	workspaces, err := ts.Store.ListWorkspacesByProject(context.Background(), project.ID)
+	if err != nil || len(workspaces) != 1 {
+		t.Errorf("Expected 1 workspace, got %d (err %v)", len(workspaces), err)
+	}
+	agents, err := ts.Store.ListAgentsByProject(context.Background(), project.ID)
+	if err != nil || len(agents) != 1 || agents[0].AgentType != "claude-code" || !agents[0].IsDefault {
+		t.Errorf("Expected a default claude-code agent, got %+v (err %v)", agents, err)
+	}
+
+	resp = client.Post("/api/projects", map[string]string{"name": "Other", "template": "missing"})
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestCreateProject_SeedFailureRemovesProject(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	client := ts.AuthenticatedClient(user)
+
+	resp := client.Post("/api/projects", map[string]any{
+		"name":             "Test Project",
+		"defaultWorkspace": map[string]string{"path": filepath.Join(t.TempDir(), "missing", "repo")},
+	})
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+
+	resp = client.Get("/api/projects")
+	defer resp.Body.Close()
+	var projects []map[string]any
+	ParseJSON(t, resp, &projects)
+	if len(projects) != 0 {
+		t.Errorf("Expected the project to be removed, got %v", projects)
+	}
+}
+
 func TestGetProject(t *testing.T) {
 	t.Parallel()
 	ts := NewTestServer(t)
@@ -127,6 +233,126 @@ func TestUpdateProject(t *testing.T) {
 	if result["name"] != "Updated Project" {
 		t.Errorf("Expected name 'Updated Project', got '%v'", result["name"])
 	}
+	if result["skipGitHooks"] != false {
+		t.Errorf("Expected skipGitHooks false by default, got '%v'", result["skipGitHooks"])
+	}
+
+	resp = client.Put("/api/projects/"+project.ID, map[string]string{"name": ""})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+
+	resp = client.Put("/api/projects/"+project.ID, map[string]any{"skipGitHooks": true})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+	ParseJSON(t, resp, &result)
+	if result["skipGitHooks"] != true || result["name"] != "Updated Project" {
+		t.Errorf("Expected skipGitHooks true and name unchanged, got %v and '%v'", result["skipGitHooks"], result["name"])
+	}
+
+	resp = client.Put("/api/projects/"+project.ID, map[string]any{"sandboxImage": "ghcr.io/example/sandbox:cuda"})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+	ParseJSON(t, resp, &result)
+	if result["sandboxImage"] != "ghcr.io/example/sandbox:cuda" || result["skipGitHooks"] != true {
+		t.Errorf("Expected sandboxImage set and skipGitHooks unchanged, got %v and %v", result["sandboxImage"], result["skipGitHooks"])
+	}
+
+	resp = client.Put("/api/projects/"+project.ID, map[string]any{"sandboxImage": "not a valid image"})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+
+	// Only images the operator allowed can be chosen
+	resp = client.Put("/api/projects/"+project.ID, map[string]any{"sandboxImage": "docker.io/attacker/sandbox:latest"})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+
+	resp = client.Put("/api/projects/"+project.ID, map[string]any{"sandboxImage": ""})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+	result = nil
+	ParseJSON(t, resp, &result)
+	if _, set := result["sandboxImage"]; set {
+		t.Errorf("Expected sandboxImage to be cleared, got %v", result["sandboxImage"])
+	}
+}
+
+func TestFreezeProject(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	client := ts.AuthenticatedClient(user)
+
+	resp := client.Post("/api/projects/"+project.ID+"/freeze", nil)
+	AssertStatus(t, resp, http.StatusOK)
+	var result map[string]interface{}
+	ParseJSON(t, resp, &result)
+	resp.Body.Close()
+	if result["frozen"] != true {
+		t.Errorf("Expected frozen project, got %v", result["frozen"])
+	}
+
+	// Mutations are rejected, reads still work
+	resp = client.Put("/api/projects/"+project.ID, map[string]string{"name": "Renamed"})
+	AssertStatus(t, resp, http.StatusConflict)
+	resp.Body.Close()
+	resp = client.Get("/api/projects/" + project.ID)
+	AssertStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+
+	// Queued jobs for the project are not dispatched
+	ctx := context.Background()
+	payload := jobs.WorkspaceInitPayload{ProjectID: project.ID, WorkspaceID: "missing-workspace"}
+	if err := ts.Handler.JobQueue().Enqueue(ctx, payload); err != nil {
+		t.Fatalf("Failed to enqueue job: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	job, err := ts.Store.GetJobByResourceID(ctx, jobs.ResourceTypeWorkspace, payload.WorkspaceID)
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if job.Attempts != 0 {
+		t.Fatalf("Expected job of frozen project to stay queued, got %d attempts", job.Attempts)
+	}
+
+	resp = client.Delete("/api/projects/" + project.ID + "/freeze")
+	AssertStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+
+	// Unfreezing resumes dispatch
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Attempts == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		if job, err = ts.Store.GetJobByID(ctx, job.ID); err != nil {
+			t.Fatalf("Failed to get job: %v", err)
+		}
+	}
+	if job.Attempts == 0 {
+		t.Error("Expected job to be dispatched after unfreezing")
+	}
+
+	resp = client.Put("/api/projects/"+project.ID, map[string]string{"name": "Renamed"})
+	AssertStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+}
+
+func TestFreezeProject_RequiresAdmin(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	owner := ts.CreateTestUser("owner@example.com")
+	project := ts.CreateTestProject(owner, "Test Project")
+	member := ts.CreateTestUser("member@example.com")
+	if err := ts.Store.CreateProjectMember(context.Background(), &model.ProjectMember{
+		ProjectID: project.ID,
+		UserID:    member.User.ID,
+		Role:      "member",
+	}); err != nil {
+		t.Fatalf("Failed to add member: %v", err)
+	}
+
+	resp := ts.AuthenticatedClient(member).Post("/api/projects/"+project.ID+"/freeze", nil)
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusForbidden)
 }
 
 func TestDeleteProject(t *testing.T) {
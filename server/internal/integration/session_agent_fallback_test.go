@@ -326,3 +326,72 @@ func TestSessionInitialize_DeletedAgentNoDefaultError(t *testing.T) {
 		t.Errorf("Expected session status to be error, got %s", updatedSession.Status)
 	}
 }
+
+// TestSessionInitialize_NilAgentFallsBackToWorkspaceDefault verifies that a
+// workspace's default agent is used ahead of the project's, and that the
+// project's is used again once the workspace's agent is deleted.
+func TestSessionInitialize_NilAgentFallsBackToWorkspaceDefault(t *testing.T) {
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+
+	// Create a workspace with a real git repo
+	workspace := ts.CreateTestWorkspaceWithGitRepo(project)
+
+	projectAgent := ts.CreateTestAgent(project, "Project Default", "claude-code")
+	workspaceAgent := ts.CreateTestAgent(project, "Workspace Default", "claude-code")
+	ctx := context.Background()
+	if err := ts.Store.SetDefaultAgent(ctx, project.ID, projectAgent.ID); err != nil {
+		t.Fatalf("Failed to set default agent: %v", err)
+	}
+	if err := ts.Store.SetWorkspaceDefaultAgent(ctx, workspace.ID, &workspaceAgent.ID); err != nil {
+		t.Fatalf("Failed to set workspace default agent: %v", err)
+	}
+
+	gitSvc := service.NewGitService(ts.Store, ts.GitProvider)
+	sessionSvc := service.NewSessionService(ts.Store, gitSvc, ts.MockSandbox, nil, nil, nil)
+
+	initialize := func(name string) *model.Session {
+		t.Helper()
+		session := &model.Session{
+			ProjectID:   project.ID,
+			WorkspaceID: workspace.ID,
+			Name:        name,
+			Status:      model.SessionStatusInitializing,
+		}
+		if err := ts.Store.CreateSession(ctx, session); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+		if err := sessionSvc.Initialize(ctx, session.ID); err != nil {
+			t.Fatalf("Initialize failed: %v", err)
+		}
+		updated, err := ts.Store.GetSessionByID(ctx, session.ID)
+		if err != nil {
+			t.Fatalf("Failed to get updated session: %v", err)
+		}
+		if updated.AgentID == nil {
+			t.Fatal("Expected AgentID to be set after initialization")
+		}
+		return updated
+	}
+
+	if session := initialize("Workspace Default"); *session.AgentID != workspaceAgent.ID {
+		t.Errorf("Expected AgentID to be %s (workspace default), got %s", workspaceAgent.ID, *session.AgentID)
+	}
+
+	// Deleting the workspace's agent clears its default
+	if err := ts.Store.DeleteAgent(ctx, workspaceAgent.ID); err != nil {
+		t.Fatalf("Failed to delete agent: %v", err)
+	}
+	fresh, err := ts.Store.GetWorkspaceByID(ctx, workspace.ID)
+	if err != nil {
+		t.Fatalf("Failed to get workspace: %v", err)
+	}
+	if fresh.DefaultAgentID != nil {
+		t.Errorf("Expected DefaultAgentID to be nil after agent deletion, got %v", *fresh.DefaultAgentID)
+	}
+
+	if session := initialize("Project Default"); *session.AgentID != projectAgent.ID {
+		t.Errorf("Expected AgentID to be %s (project default), got %s", projectAgent.ID, *session.AgentID)
+	}
+}
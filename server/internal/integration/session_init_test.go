@@ -419,3 +419,95 @@ func TestSessionInitialize_NoGitService(t *testing.T) {
 		t.Errorf("Expected WorkspaceCommit to be nil/empty without git service, got %v", *updatedSession.WorkspaceCommit)
 	}
 }
+
+// TestSessionInitialize_UsesProjectSandboxImage verifies that a project's
+// sandbox image is used instead of the global one, and that reconciliation
+// doesn't treat it as outdated.
+func TestSessionInitialize_UsesProjectSandboxImage(t *testing.T) {
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	project.SandboxImage = "ghcr.io/example/sandbox:cuda"
+	ctx := context.Background()
+	if err := ts.Store.UpdateProject(ctx, project); err != nil {
+		t.Fatalf("Failed to set project image: %v", err)
+	}
+	workspace := ts.CreateTestWorkspaceWithGitRepo(project)
+	agent := ts.CreateTestAgent(project, "Test Agent", "claude-code")
+	session := &model.Session{
+		ProjectID:   workspace.ProjectID,
+		WorkspaceID: workspace.ID,
+		AgentID:     &agent.ID,
+		Name:        "Test Session",
+		Status:      model.SessionStatusInitializing,
+	}
+	if err := ts.Store.CreateSession(ctx, session); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	sandboxSvc := service.NewSandboxService(ts.Store, ts.MockSandbox, ts.Config, nil, nil, nil)
+	gitSvc := service.NewGitService(ts.Store, ts.GitProvider)
+	sessionSvc := service.NewSessionService(ts.Store, gitSvc, ts.MockSandbox, sandboxSvc, nil, nil)
+	if err := sessionSvc.Initialize(ctx, session.ID); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	sbx, err := ts.MockSandbox.Get(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("Failed to get sandbox: %v", err)
+	}
+	if sbx.Image != project.SandboxImage {
+		t.Fatalf("Expected sandbox image %s, got %s", project.SandboxImage, sbx.Image)
+	}
+
+	if err := sandboxSvc.ReconcileSandboxes(ctx); err != nil {
+		t.Fatalf("ReconcileSandboxes failed: %v", err)
+	}
+	after, err := ts.MockSandbox.Get(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("Failed to get sandbox after reconciliation: %v", err)
+	}
+	if after.ID != sbx.ID || after.Image != project.SandboxImage {
+		t.Errorf("Expected sandbox %s with the project image to be kept, got %s with %s", sbx.ID, after.ID, after.Image)
+	}
+}
+
+// TestSessionInitialize_AgentDefaultEnv verifies that the default environment
+// of the session's agent type is set on its sandbox.
+func TestSessionInitialize_AgentDefaultEnv(t *testing.T) {
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/some/local/path")
+	agent := ts.CreateTestAgent(project, "Test Agent", "claude-code")
+
+	session := &model.Session{
+		ProjectID:   workspace.ProjectID,
+		WorkspaceID: workspace.ID,
+		AgentID:     &agent.ID,
+		Name:        "Test Session",
+		Status:      model.SessionStatusInitializing,
+	}
+	if err := ts.Store.CreateSession(context.Background(), session); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	sessionSvc := service.NewSessionService(ts.Store, nil, ts.MockSandbox, nil, nil, nil)
+	sessionSvc.SetAgentTypes([]service.AgentType{
+		{ID: "opencode", DefaultEnv: map[string]string{"OTHER": "1"}},
+		{ID: "claude-code", DefaultEnv: map[string]string{"DISABLE_AUTOUPDATER": "1"}},
+	})
+
+	ctx := context.Background()
+	if err := sessionSvc.Initialize(ctx, session.ID); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+
+	sb, err := ts.MockSandbox.Get(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("Failed to get sandbox: %v", err)
+	}
+	if len(sb.Env) != 1 || sb.Env["DISABLE_AUTOUPDATER"] != "1" {
+		t.Errorf("Expected the claude-code default env on the sandbox, got %v", sb.Env)
+	}
+}
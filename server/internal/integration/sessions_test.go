@@ -6,9 +6,18 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/obot-platform/discobot/server/internal/jobs"
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/service"
 )
 
 func TestListSessionsByWorkspace_Empty(t *testing.T) {
@@ -209,6 +218,53 @@ func TestGetSession(t *testing.T) {
 	}
 }
 
+func TestGetSession_Ports(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	workspace.ExtraPorts = []int{8080}
+	if err := ts.Store.UpdateWorkspace(context.Background(), workspace); err != nil {
+		t.Fatalf("Failed to update workspace: %v", err)
+	}
+	session := ts.CreateTestSession(workspace, "Test Session")
+	client := ts.AuthenticatedClient(user)
+	sessionPath := "/api/projects/" + project.ID + "/sessions/" + session.ID
+
+	// Without a sandbox the port is listed but not published
+	resp := client.Get(sessionPath)
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+
+	var result struct {
+		Ports []struct {
+			Port     int `json:"port"`
+			HostPort int `json:"hostPort"`
+		} `json:"ports"`
+	}
+	ParseJSON(t, resp, &result)
+	if len(result.Ports) != 1 || result.Ports[0].Port != 8080 || result.Ports[0].HostPort != 0 {
+		t.Fatalf("Expected unpublished port 8080, got %+v", result.Ports)
+	}
+
+	ctx := context.Background()
+	if _, err := ts.MockSandbox.Create(ctx, session.ID, sandbox.CreateOptions{ExtraPorts: workspace.ExtraPorts}); err != nil {
+		t.Fatalf("Failed to create mock sandbox: %v", err)
+	}
+	if err := ts.MockSandbox.Start(ctx, session.ID); err != nil {
+		t.Fatalf("Failed to start mock sandbox: %v", err)
+	}
+
+	resp = client.Get(sessionPath)
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+	ParseJSON(t, resp, &result)
+	if len(result.Ports) != 1 || result.Ports[0].Port != 8080 || result.Ports[0].HostPort == 0 {
+		t.Errorf("Expected port 8080 published on a host port, got %+v", result.Ports)
+	}
+}
+
 func TestUpdateSession(t *testing.T) {
 	t.Parallel()
 	ts := NewTestServer(t)
@@ -263,6 +319,137 @@ func TestDeleteSession(t *testing.T) {
 	}
 }
 
+func TestDeleteAndCommit_Conflict(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	client := ts.AuthenticatedClient(user)
+	sessionPath := func(session *model.Session) string {
+		return "/api/projects/" + project.ID + "/sessions/" + session.ID
+	}
+
+	// A live commit blocks deletion
+	committing := ts.CreateTestSession(workspace, "Committing")
+	committing.CommitStatus = model.CommitStatusCommitting
+	if err := ts.Store.UpdateSession(context.Background(), committing); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+	resp := client.Delete(sessionPath(committing))
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusConflict)
+	stored, err := ts.Store.GetSessionByID(context.Background(), committing.ID)
+	if err != nil || stored.Status == model.SessionStatusRemoving {
+		t.Errorf("Expected session to stay out of removing, got %+v (err %v)", stored, err)
+	}
+
+	// A commit that stopped making progress doesn't
+	if err := ts.Store.DB().Model(&model.Session{}).Where("id = ?", committing.ID).
+		UpdateColumn("updated_at", time.Now().Add(-time.Hour)).Error; err != nil {
+		t.Fatalf("Failed to age session: %v", err)
+	}
+	resp = client.Delete(sessionPath(committing))
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+
+	// A session being deleted can't be committed
+	removing := ts.CreateTestSession(workspace, "Removing")
+	if err := ts.Store.UpdateSessionStatus(context.Background(), removing.ID, model.SessionStatusRemoving, nil); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+	resp = client.Post(sessionPath(removing)+"/commit", nil)
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusConflict)
+}
+
+func TestBulkDeleteSessions(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	client := ts.AuthenticatedClient(user)
+	ctx := context.Background()
+	bulkPath := "/api/projects/" + project.ID + "/sessions/bulk-delete"
+
+	withStatus := func(name, status string) *model.Session {
+		session := ts.CreateTestSession(workspace, name)
+		if err := ts.Store.UpdateSessionStatus(ctx, session.ID, status, nil); err != nil {
+			t.Fatalf("Failed to update session status: %v", err)
+		}
+		return session
+	}
+	stopped1 := withStatus("Stopped 1", model.SessionStatusStopped)
+	stopped2 := withStatus("Stopped 2", model.SessionStatusStopped)
+	ready := withStatus("Ready", model.SessionStatusReady)
+	removing := withStatus("Removing", model.SessionStatusRemoving)
+	committing := withStatus("Committing", model.SessionStatusReady)
+	committing.CommitStatus = model.CommitStatusCommitting
+	if err := ts.Store.UpdateSession(ctx, committing); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+
+	type bulkResult struct {
+		Accepted         []string `json:"accepted"`
+		AlreadyRemoving  []string `json:"alreadyRemoving"`
+		CommitInProgress []string `json:"commitInProgress"`
+		NotFound         []string `json:"notFound"`
+		Failed           []string `json:"failed"`
+	}
+	bulkDelete := func(body any) bulkResult {
+		t.Helper()
+		resp := client.Post(bulkPath, body)
+		defer resp.Body.Close()
+		AssertStatus(t, resp, http.StatusOK)
+		var result bulkResult
+		ParseJSON(t, resp, &result)
+		return result
+	}
+
+	// By filter, only the stopped sessions are deleted
+	result := bulkDelete(map[string]any{"filter": "stopped"})
+	slices.Sort(result.Accepted)
+	want := []string{stopped1.ID, stopped2.ID}
+	slices.Sort(want)
+	if !slices.Equal(result.Accepted, want) {
+		t.Errorf("Expected the stopped sessions to be accepted, got %+v", result)
+	}
+	stored, err := ts.Store.GetSessionByID(ctx, ready.ID)
+	if err != nil || stored.Status != model.SessionStatusReady {
+		t.Errorf("Expected the ready session to be left alone, got %+v (err %v)", stored, err)
+	}
+
+	// By ID, each session is reported by what happened to it
+	result = bulkDelete(map[string]any{"sessionIds": []string{ready.ID, removing.ID, committing.ID, "nonexistent", ready.ID}})
+	if !slices.Equal(result.Accepted, []string{ready.ID}) ||
+		!slices.Equal(result.AlreadyRemoving, []string{removing.ID}) ||
+		!slices.Equal(result.CommitInProgress, []string{committing.ID}) ||
+		!slices.Equal(result.NotFound, []string{"nonexistent"}) ||
+		len(result.Failed) != 0 {
+		t.Errorf("Unexpected bulk delete result: %+v", result)
+	}
+
+	// Sessions of other projects aren't visible
+	otherProject := ts.CreateTestProject(user, "Other Project")
+	otherWorkspace := ts.CreateTestWorkspace(otherProject, "/home/user/other")
+	other := ts.CreateTestSession(otherWorkspace, "Other")
+	result = bulkDelete(map[string]any{"sessionIds": []string{other.ID}})
+	if !slices.Equal(result.NotFound, []string{other.ID}) || len(result.Accepted) != 0 {
+		t.Errorf("Expected another project's session to be not found, got %+v", result)
+	}
+
+	for _, body := range []any{
+		map[string]any{},
+		map[string]any{"filter": "stopped", "sessionIds": []string{ready.ID}},
+		map[string]any{"filter": "everything"},
+	} {
+		resp := client.Post(bulkPath, body)
+		resp.Body.Close()
+		AssertStatus(t, resp, http.StatusBadRequest)
+	}
+}
+
 func TestListSessionsByWorkspace_WithData(t *testing.T) {
 	t.Parallel()
 	ts := NewTestServer(t)
@@ -289,6 +476,70 @@ func TestListSessionsByWorkspace_WithData(t *testing.T) {
 	}
 }
 
+func TestListSessionsByWorkspace_Filters(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	client := ts.AuthenticatedClient(user)
+
+	ctx := context.Background()
+	for _, s := range []struct{ name, status string }{
+		{"Fix login bug", model.SessionStatusError},
+		{"Add dark mode", model.SessionStatusReady},
+		{"Fix 100% CPU", model.SessionStatusReady},
+		{"Refactor store", model.SessionStatusStopped},
+	} {
+		session := ts.CreateTestSession(workspace, s.name)
+		if err := ts.Store.UpdateSessionStatus(ctx, session.ID, s.status, nil); err != nil {
+			t.Fatalf("Failed to update session status: %v", err)
+		}
+	}
+
+	list := func(query string) []string {
+		t.Helper()
+		resp := client.Get("/api/projects/" + project.ID + "/workspaces/" + workspace.ID + "/sessions" + query)
+		defer resp.Body.Close()
+		AssertStatus(t, resp, http.StatusOK)
+
+		var result struct {
+			Sessions []struct {
+				Name string `json:"name"`
+			} `json:"sessions"`
+		}
+		ParseJSON(t, resp, &result)
+		names := make([]string, len(result.Sessions))
+		for i, s := range result.Sessions {
+			names[i] = s.Name
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Add dark mode", "Fix 100% CPU", "Fix login bug", "Refactor store"}},
+		{"?status=error", []string{"Fix login bug"}},
+		{"?status=error,stopped", []string{"Fix login bug", "Refactor store"}},
+		{"?q=fix", []string{"Fix 100% CPU", "Fix login bug"}},
+		{"?q=fix&status=ready", []string{"Fix 100% CPU"}},
+		{"?q=" + url.QueryEscape("%"), []string{"Fix 100% CPU"}},
+		{"?sort=updated", []string{"Add dark mode", "Fix 100% CPU", "Fix login bug", "Refactor store"}},
+	}
+	for _, tt := range tests {
+		if got := list(tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("list%s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	resp := client.Get("/api/projects/" + project.ID + "/workspaces/" + workspace.ID + "/sessions?sort=name")
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+}
+
 func TestListSessionFiles(t *testing.T) {
 	t.Parallel()
 	ts := NewTestServer(t)
@@ -325,6 +576,64 @@ func TestListSessionFiles(t *testing.T) {
 	}
 }
 
+func TestWriteSessionFile_ExpectedHash(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	agent := ts.CreateTestAgent(project, "Test Agent", "claude-code")
+	session := ts.CreateTestSessionWithSandbox(workspace, agent, "Test Session")
+	client := ts.AuthenticatedClient(user)
+	base := "/api/projects/" + project.ID + "/sessions/" + session.ID + "/files"
+
+	// Reads include the content hash (the mock always returns "# Mock Content")
+	resp := client.Get(base + "/read?path=README.md")
+	AssertStatus(t, resp, http.StatusOK)
+	var read struct {
+		Hash string `json:"hash"`
+	}
+	ParseJSON(t, resp, &read)
+	if read.Hash != service.ContentHash([]byte("# Mock Content")) {
+		t.Fatalf("Expected hash of mock content, got %q", read.Hash)
+	}
+
+	// A stale hash is rejected with the current one
+	resp = client.Put(base+"/write", map[string]string{
+		"path":         "README.md",
+		"content":      "new",
+		"expectedHash": service.ContentHash([]byte("old")),
+	})
+	AssertStatus(t, resp, http.StatusConflict)
+	var conflict struct {
+		CurrentHash string `json:"currentHash"`
+	}
+	ParseJSON(t, resp, &conflict)
+	if conflict.CurrentHash != read.Hash {
+		t.Errorf("Expected currentHash %q, got %q", read.Hash, conflict.CurrentHash)
+	}
+
+	// The hash from the read is accepted
+	resp = client.Put(base+"/write", map[string]string{
+		"path":         "README.md",
+		"content":      "new",
+		"expectedHash": read.Hash,
+	})
+	AssertStatus(t, resp, http.StatusOK)
+	var written struct {
+		Hash string `json:"hash"`
+	}
+	ParseJSON(t, resp, &written)
+	if written.Hash != service.ContentHash([]byte("new")) {
+		t.Errorf("Expected hash of written content, got %q", written.Hash)
+	}
+
+	// Without a hash, writes are unconditional
+	resp = client.Put(base+"/write", map[string]string{"path": "README.md", "content": "other"})
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+}
+
 func TestListMessages(t *testing.T) {
 	t.Parallel()
 	ts := NewTestServer(t)
@@ -679,3 +988,581 @@ waitLoop:
 		messagesMu.Unlock()
 	}
 }
+
+func TestPrioritizeSession(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	client := ts.AuthenticatedClient(user)
+
+	// Queue init jobs scheduled in the future so the dispatcher leaves them pending
+	enqueue := func(session *model.Session) *model.Job {
+		resType, resID := jobs.ResourceTypeSession, session.ID
+		job := &model.Job{
+			Type:         string(jobs.JobTypeSessionInit),
+			Payload:      json.RawMessage(`{}`),
+			Priority:     10,
+			MaxAttempts:  1,
+			ScheduledAt:  time.Now().Add(time.Hour),
+			ResourceType: &resType,
+			ResourceID:   &resID,
+		}
+		if err := ts.Store.CreateJob(context.Background(), job); err != nil {
+			t.Fatalf("Failed to create job: %v", err)
+		}
+		return job
+	}
+	first := ts.CreateTestSession(workspace, "First")
+	second := ts.CreateTestSession(workspace, "Second")
+	enqueue(first)
+	job := enqueue(second)
+
+	prioritize := func(sessionID string, body any) (*http.Response, map[string]any) {
+		resp := client.Post("/api/projects/"+project.ID+"/sessions/"+sessionID+"/prioritize", body)
+		var result map[string]any
+		if resp.StatusCode == http.StatusOK {
+			ParseJSON(t, resp, &result)
+		}
+		resp.Body.Close()
+		return resp, result
+	}
+
+	// Bumping moves the job ahead of every other pending job
+	resp, result := prioritize(second.ID, nil)
+	AssertStatus(t, resp, http.StatusOK)
+	if result["jobId"] != job.ID || result["priority"] != float64(11) {
+		t.Errorf("Expected job %s with priority 11, got %+v", job.ID, result)
+	}
+
+	// Bumping again is a no-op since it is already at the front
+	_, result = prioritize(second.ID, nil)
+	if result["priority"] != float64(11) {
+		t.Errorf("Expected priority to stay 11, got %v", result["priority"])
+	}
+
+	// Explicit priority is applied as-is
+	_, result = prioritize(second.ID, map[string]int{"priority": 3})
+	if result["priority"] != float64(3) {
+		t.Errorf("Expected priority 3, got %v", result["priority"])
+	}
+	stored, err := ts.Store.GetJobByID(context.Background(), job.ID)
+	if err != nil || stored.Priority != 3 {
+		t.Errorf("Expected stored priority 3, got %+v (err %v)", stored, err)
+	}
+
+	// Session without a queued init job
+	idle := ts.CreateTestSession(workspace, "Idle")
+	resp, _ = prioritize(idle.ID, nil)
+	AssertStatus(t, resp, http.StatusConflict)
+
+	// Unknown session
+	resp, _ = prioritize("nonexistent", nil)
+	AssertStatus(t, resp, http.StatusNotFound)
+}
+
+func TestResizeSessionDisk(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	session := ts.CreateTestSession(workspace, "Test Session")
+	client := ts.AuthenticatedClient(user)
+
+	resize := func(sessionID string, body any) *http.Response {
+		resp := client.Post("/api/projects/"+project.ID+"/sessions/"+sessionID+"/resize-disk", body)
+		resp.Body.Close()
+		return resp
+	}
+
+	// The mock provider has no resizable volumes
+	AssertStatus(t, resize(session.ID, map[string]int{"sizeMB": 2048}), http.StatusNotImplemented)
+
+	AssertStatus(t, resize(session.ID, map[string]int{"sizeMB": 0}), http.StatusBadRequest)
+	AssertStatus(t, resize("nonexistent", map[string]int{"sizeMB": 2048}), http.StatusNotFound)
+}
+
+func TestGetSessionFilesystemDiff(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	session := ts.CreateTestSession(workspace, "Test Session")
+	client := ts.AuthenticatedClient(user)
+
+	fsdiff := func(sessionID string) *http.Response {
+		resp := client.Get("/api/projects/" + project.ID + "/sessions/" + sessionID + "/fsdiff")
+		resp.Body.Close()
+		return resp
+	}
+
+	// The mock provider can't diff filesystems
+	AssertStatus(t, fsdiff(session.ID), http.StatusNotImplemented)
+	AssertStatus(t, fsdiff("nonexistent"), http.StatusNotFound)
+}
+
+func TestGetSessionStats(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	session := ts.CreateTestSession(workspace, "Test Session")
+	client := ts.AuthenticatedClient(user)
+
+	stats := func(sessionID string) *http.Response {
+		resp := client.Get("/api/projects/" + project.ID + "/sessions/" + sessionID + "/stats")
+		resp.Body.Close()
+		return resp
+	}
+
+	// The mock provider can't report resource usage
+	AssertStatus(t, stats(session.ID), http.StatusNotImplemented)
+	AssertStatus(t, stats("nonexistent"), http.StatusNotFound)
+}
+
+func TestGetSessionFileDescriptors(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	agent := ts.CreateTestAgent(project, "Test Agent", "claude-code")
+	client := ts.AuthenticatedClient(user)
+
+	// Stand in for the agent API reading /proc
+	var procMissing atomic.Bool
+	ts.MockSandbox.HTTPHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/fds" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if procMissing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"error": "file descriptor stats are not available"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"self": map[string]any{"pid": 42, "command": "node", "open": 31, "softLimit": 1024, "hardLimit": 524288},
+			"top": []map[string]any{
+				{"pid": 97, "command": "tsserver", "open": 900, "softLimit": 1024, "hardLimit": 524288},
+				{"pid": 42, "command": "node", "open": 31, "softLimit": 1024, "hardLimit": 524288},
+			},
+			"totalOpen": 931,
+			"system":    map[string]any{"allocated": 2112, "max": 1000000},
+			"warnings":  []string{"tsserver (pid 97) has 900 of 1024 file descriptors open"},
+		})
+	})
+
+	session := ts.CreateTestSessionWithSandbox(workspace, agent, "Test Session")
+	fdsPath := "/api/projects/" + project.ID + "/sessions/" + session.ID + "/fds"
+
+	resp := client.Get(fdsPath)
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+	var result struct {
+		Self struct {
+			Open      int    `json:"open"`
+			SoftLimit *int64 `json:"softLimit"`
+		} `json:"self"`
+		Top       []struct{ PID int } `json:"top"`
+		TotalOpen int                 `json:"totalOpen"`
+		Warnings  []string            `json:"warnings"`
+	}
+	ParseJSON(t, resp, &result)
+	if result.Self.Open != 31 || result.Self.SoftLimit == nil || *result.Self.SoftLimit != 1024 {
+		t.Errorf("Unexpected self stats: %+v", result.Self)
+	}
+	if len(result.Top) != 2 || result.Top[0].PID != 97 || result.TotalOpen != 931 {
+		t.Errorf("Unexpected process stats: %+v", result)
+	}
+	if len(result.Warnings) != 1 {
+		t.Errorf("Expected 1 warning, got %v", result.Warnings)
+	}
+
+	procMissing.Store(true)
+	resp = client.Get(fdsPath)
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusServiceUnavailable)
+
+	resp = client.Get("/api/projects/" + project.ID + "/sessions/nonexistent/fds")
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusNotFound)
+}
+
+func TestCommitSessionImage(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	session := ts.CreateTestSession(workspace, "Test Session")
+	client := ts.AuthenticatedClient(user)
+
+	commit := func(sessionID, image string) *http.Response {
+		resp := client.Post("/api/projects/"+project.ID+"/sessions/"+sessionID+"/commit-image", map[string]string{"image": image})
+		resp.Body.Close()
+		return resp
+	}
+
+	prefix := "discobot-local/" + project.ID + "/"
+	AssertStatus(t, commit(session.ID, ""), http.StatusBadRequest)
+	AssertStatus(t, commit(session.ID, prefix+"Not An Image"), http.StatusBadRequest)
+	// Images must be in the project's repository
+	AssertStatus(t, commit(session.ID, "my-env:v1"), http.StatusBadRequest)
+	AssertStatus(t, commit(session.ID, "discobot-local/other-project/my-env:v1"), http.StatusBadRequest)
+	// The mock provider can't commit images
+	AssertStatus(t, commit(session.ID, prefix+"my-env:v1"), http.StatusNotImplemented)
+	AssertStatus(t, commit("nonexistent", prefix+"my-env:v1"), http.StatusNotFound)
+
+	// Images sandboxes are created from can't be tagged over
+	ts.Config.SandboxImage = prefix + "base:v1"
+	AssertStatus(t, commit(session.ID, prefix+"base:v1"), http.StatusConflict)
+	project.SandboxImage = prefix + "env"
+	if err := ts.Store.UpdateProject(context.Background(), project); err != nil {
+		t.Fatalf("Failed to update project: %v", err)
+	}
+	AssertStatus(t, commit(session.ID, prefix+"env:latest"), http.StatusConflict)
+	AssertStatus(t, commit(session.ID, prefix+"env"), http.StatusConflict)
+}
+
+func TestForkSession(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspaceWithGitRepo(project)
+	agent := ts.CreateTestAgent(project, "Test Agent", "claude-code")
+	session := ts.CreateTestSessionWithSandbox(workspace, agent, "Test Session")
+	client := ts.AuthenticatedClient(user)
+	ctx := context.Background()
+
+	fork := func(sessionID string, body any) *http.Response {
+		return client.Post("/api/projects/"+project.ID+"/sessions/"+sessionID+"/fork", body)
+	}
+
+	resp := fork("nonexistent", nil)
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusNotFound)
+
+	// Idle, but there's no sandbox to snapshot
+	noSandbox := ts.CreateTestSession(workspace, "No Sandbox")
+	if err := ts.Store.UpdateSessionStatus(ctx, noSandbox.ID, model.SessionStatusReady, nil); err != nil {
+		t.Fatalf("Failed to update session status: %v", err)
+	}
+	resp = fork(noSandbox.ID, nil)
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusConflict)
+
+	// A chat is in progress
+	if err := ts.Store.UpdateSessionStatus(ctx, session.ID, model.SessionStatusRunning, nil); err != nil {
+		t.Fatalf("Failed to update session status: %v", err)
+	}
+	resp = fork(session.ID, nil)
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusConflict)
+	if err := ts.Store.UpdateSessionStatus(ctx, session.ID, model.SessionStatusReady, nil); err != nil {
+		t.Fatalf("Failed to update session status: %v", err)
+	}
+
+	resp = fork(session.ID, map[string]string{"name": "Forked"})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusCreated)
+	var forked map[string]any
+	ParseJSON(t, resp, &forked)
+	if forked["name"] != "Forked" || forked["forkedFrom"] != session.ID {
+		t.Fatalf("Expected a session named Forked forked from %s, got %v", session.ID, forked)
+	}
+	forkID, _ := forked["id"].(string)
+	if forkID == "" || forkID == session.ID {
+		t.Fatalf("Expected a new session ID, got %v", forked["id"])
+	}
+
+	// The fork's sandbox is created from the snapshot, which is then deleted
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		sb, err := ts.MockSandbox.Get(ctx, forkID)
+		if err == nil && len(ts.MockSandbox.Snapshots()) == 0 {
+			if sb.Image != "mock-snapshot-1" {
+				t.Errorf("Expected the fork's sandbox to be created from mock-snapshot-1, got %q", sb.Image)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Fork's sandbox was not created from the snapshot (err=%v, snapshots=%v)", err, ts.MockSandbox.Snapshots())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	sess, err := ts.Store.GetSessionByID(ctx, forkID)
+	if err != nil {
+		t.Fatalf("Failed to get forked session: %v", err)
+	}
+	if sess.SnapshotID != nil {
+		t.Errorf("Expected the fork's snapshot to be cleared, got %q", *sess.SnapshotID)
+	}
+	if sess.SnapshotImage == nil || *sess.SnapshotImage != "mock-snapshot-1" {
+		t.Errorf("Expected the fork's snapshot image to be recorded, got %v", sess.SnapshotImage)
+	}
+
+	// Reconciliation keeps the fork's sandbox on its snapshot image
+	before, err := ts.MockSandbox.Get(ctx, forkID)
+	if err != nil {
+		t.Fatalf("Failed to get fork's sandbox: %v", err)
+	}
+	sandboxSvc := service.NewSandboxService(ts.Store, ts.MockSandbox, ts.Config, nil, nil, nil)
+	if err := sandboxSvc.ReconcileSandboxes(ctx); err != nil {
+		t.Fatalf("ReconcileSandboxes failed: %v", err)
+	}
+	after, err := ts.MockSandbox.Get(ctx, forkID)
+	if err != nil {
+		t.Fatalf("Failed to get fork's sandbox after reconciliation: %v", err)
+	}
+	if after.ID != before.ID || after.Image != "mock-snapshot-1" {
+		t.Errorf("Expected the fork's sandbox %s to be kept on mock-snapshot-1, got %s with %s", before.ID, after.ID, after.Image)
+	}
+}
+
+func TestSessionSnapshots(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspaceWithGitRepo(project)
+	agent := ts.CreateTestAgent(project, "Test Agent", "claude-code")
+	session := ts.CreateTestSessionWithSandbox(workspace, agent, "Test Session")
+	other := ts.CreateTestSessionWithSandbox(workspace, agent, "Other Session")
+	client := ts.AuthenticatedClient(user)
+	ctx := context.Background()
+	base := "/api/projects/" + project.ID + "/sessions/"
+
+	snapshot := func(sessionID string) string {
+		t.Helper()
+		resp := client.Post(base+sessionID+"/snapshots", nil)
+		defer resp.Body.Close()
+		AssertStatus(t, resp, http.StatusCreated)
+		var snap map[string]any
+		ParseJSON(t, resp, &snap)
+		id, _ := snap["id"].(string)
+		if id == "" || snap["createdAt"] == nil {
+			t.Fatalf("Expected a snapshot with an id and createdAt, got %v", snap)
+		}
+		return id
+	}
+	diff := func(sessionID, snapshotID, query string) (*http.Response, string) {
+		resp := client.Get(base + sessionID + "/snapshots/" + snapshotID + "/diff" + query)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	first := snapshot(session.ID)
+	second := snapshot(session.ID)
+	otherSnap := snapshot(other.ID)
+
+	resp := client.Get(base + session.ID + "/snapshots")
+	AssertStatus(t, resp, http.StatusOK)
+	var list struct {
+		Snapshots []struct {
+			ID string `json:"id"`
+		} `json:"snapshots"`
+	}
+	ParseJSON(t, resp, &list)
+	resp.Body.Close()
+	if len(list.Snapshots) != 2 || list.Snapshots[0].ID != first || list.Snapshots[1].ID != second {
+		t.Fatalf("Expected snapshots [%s %s], got %+v", first, second, list.Snapshots)
+	}
+
+	resp, body := diff(session.ID, first, "?to="+second)
+	AssertStatus(t, resp, http.StatusOK)
+	if !strings.Contains(body, "--- "+first) || !strings.Contains(body, "+++ "+second) {
+		t.Errorf("Expected a diff from %s to %s, got %q", first, second, body)
+	}
+	resp, body = diff(session.ID, first, "")
+	AssertStatus(t, resp, http.StatusOK)
+	if !strings.Contains(body, "+++ live") {
+		t.Errorf("Expected a diff to the live workspace, got %q", body)
+	}
+
+	// Snapshots of other sessions can't be used
+	resp, _ = diff(session.ID, otherSnap, "")
+	AssertStatus(t, resp, http.StatusNotFound)
+	resp, _ = diff(session.ID, first, "?to="+otherSnap)
+	AssertStatus(t, resp, http.StatusNotFound)
+	resp = client.Delete(base + session.ID + "/snapshots/" + otherSnap)
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusNotFound)
+
+	resp = client.Delete(base + session.ID + "/snapshots/" + first)
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+	resp, _ = diff(session.ID, first, "")
+	AssertStatus(t, resp, http.StatusNotFound)
+
+	resp = client.Post(base+"nonexistent/snapshots", nil)
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusNotFound)
+
+	// Deleting the session deletes its snapshots, except one a fork's
+	// sandbox is still to be created from
+	pending := snapshot(session.ID)
+	fork := ts.CreateTestSession(workspace, "Fork")
+	fork.SnapshotID = &pending
+	if err := ts.Store.UpdateSession(ctx, fork); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+	gitSvc := service.NewGitService(ts.Store, ts.GitProvider)
+	sessionSvc := service.NewSessionService(ts.Store, gitSvc, ts.MockSandbox, nil, nil, nil)
+	if err := sessionSvc.PerformDeletion(ctx, project.ID, session.ID); err != nil {
+		t.Fatalf("PerformDeletion failed: %v", err)
+	}
+	snaps := ts.MockSandbox.Snapshots()
+	if _, exists := snaps[second]; exists {
+		t.Errorf("Expected snapshot %s to be deleted with its session", second)
+	}
+	for _, id := range []string{pending, otherSnap} {
+		if _, exists := snaps[id]; !exists {
+			t.Errorf("Expected snapshot %s to be kept", id)
+		}
+	}
+}
+
+func TestGetSessionLogs(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	session := ts.CreateTestSession(workspace, "Test Session")
+	client := ts.AuthenticatedClient(user)
+
+	logs := func(sessionID, query string) *http.Response {
+		resp := client.Get("/api/projects/" + project.ID + "/sessions/" + sessionID + "/logs" + query)
+		resp.Body.Close()
+		return resp
+	}
+
+	AssertStatus(t, logs(session.ID, "?tail=-1"), http.StatusBadRequest)
+	AssertStatus(t, logs(session.ID, "?since=yesterday"), http.StatusBadRequest)
+	// The mock provider can't stream logs
+	AssertStatus(t, logs(session.ID, "?tail=100&since=2024-01-02T15:04:05Z"), http.StatusNotImplemented)
+	AssertStatus(t, logs("nonexistent", ""), http.StatusNotFound)
+}
+
+func TestResetSession(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	session := ts.CreateTestSession(workspace, "Test Session")
+	client := ts.AuthenticatedClient(user)
+	ctx := context.Background()
+
+	if _, err := ts.MockSandbox.Create(ctx, session.ID, sandbox.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create sandbox: %v", err)
+	}
+	if err := ts.MockSandbox.Start(ctx, session.ID); err != nil {
+		t.Fatalf("Failed to start sandbox: %v", err)
+	}
+
+	var execCmd []string
+	var execUser string
+	ts.MockSandbox.ExecFunc = func(_ context.Context, _ string, cmd []string, opts sandbox.ExecOptions) (*sandbox.ExecResult, error) {
+		execCmd, execUser = cmd, opts.User
+		return &sandbox.ExecResult{}, nil
+	}
+
+	reset := func(sessionID string, body any) *http.Response {
+		resp := client.Post("/api/projects/"+project.ID+"/sessions/"+sessionID+"/reset", body)
+		resp.Body.Close()
+		return resp
+	}
+
+	AssertStatus(t, reset(session.ID, map[string]bool{}), http.StatusBadRequest)
+	AssertStatus(t, reset("nonexistent", map[string]bool{"confirm": true}), http.StatusNotFound)
+
+	// A chat is in progress
+	if err := ts.Store.UpdateSessionStatus(ctx, session.ID, model.SessionStatusRunning, nil); err != nil {
+		t.Fatalf("Failed to update session status: %v", err)
+	}
+	AssertStatus(t, reset(session.ID, map[string]bool{"confirm": true}), http.StatusConflict)
+	if execCmd != nil {
+		t.Fatalf("Expected no reset to be requested for a busy session, got %v", execCmd)
+	}
+
+	if err := ts.Store.UpdateSessionStatus(ctx, session.ID, model.SessionStatusReady, nil); err != nil {
+		t.Fatalf("Failed to update session status: %v", err)
+	}
+	AssertStatus(t, reset(session.ID, map[string]bool{"confirm": true}), http.StatusAccepted)
+	if len(execCmd) != 2 || execCmd[0] != "touch" || execUser != "root" {
+		t.Errorf("Expected the reset marker to be created as root, got %v as %q", execCmd, execUser)
+	}
+}
+
+func TestPauseResumeSession(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	session := ts.CreateTestSession(workspace, "Test Session")
+	client := ts.AuthenticatedClient(user)
+	ctx := context.Background()
+
+	if _, err := ts.MockSandbox.Create(ctx, session.ID, sandbox.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create sandbox: %v", err)
+	}
+	if err := ts.MockSandbox.Start(ctx, session.ID); err != nil {
+		t.Fatalf("Failed to start sandbox: %v", err)
+	}
+	if err := ts.Store.UpdateSessionStatus(ctx, session.ID, model.SessionStatusReady, nil); err != nil {
+		t.Fatalf("Failed to update session status: %v", err)
+	}
+
+	post := func(sessionID, action string) *http.Response {
+		return client.Post("/api/projects/"+project.ID+"/sessions/"+sessionID+"/"+action, nil)
+	}
+	expectStatus := func(action string, want string, wantSandbox sandbox.Status) {
+		t.Helper()
+		resp := post(session.ID, action)
+		defer resp.Body.Close()
+		AssertStatus(t, resp, http.StatusOK)
+
+		var result map[string]interface{}
+		ParseJSON(t, resp, &result)
+		if result["status"] != want {
+			t.Errorf("%s: expected status %q, got %v", action, want, result["status"])
+		}
+		sb, err := ts.MockSandbox.Get(ctx, session.ID)
+		if err != nil {
+			t.Fatalf("Failed to get sandbox: %v", err)
+		}
+		if sb.Status != wantSandbox {
+			t.Errorf("%s: expected sandbox %s, got %s", action, wantSandbox, sb.Status)
+		}
+	}
+
+	expectStatus("pause", model.SessionStatusPaused, sandbox.StatusPaused)
+	expectStatus("pause", model.SessionStatusPaused, sandbox.StatusPaused) // No-op
+	expectStatus("resume", model.SessionStatusReady, sandbox.StatusRunning)
+	expectStatus("resume", model.SessionStatusReady, sandbox.StatusRunning) // No-op
+
+	// Only ready sessions can be paused
+	if err := ts.Store.UpdateSessionStatus(ctx, session.ID, model.SessionStatusStopped, nil); err != nil {
+		t.Fatalf("Failed to update session status: %v", err)
+	}
+	for _, action := range []string{"pause", "resume"} {
+		resp := post(session.ID, action)
+		resp.Body.Close()
+		AssertStatus(t, resp, http.StatusConflict)
+	}
+
+	resp := post("nonexistent", "pause")
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusNotFound)
+}
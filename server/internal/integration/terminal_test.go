@@ -1,6 +1,7 @@
 package integration
 
 import (
+	"context"
 	"net/http"
 	"testing"
 	"time"
@@ -183,3 +184,38 @@ func TestCreateSession_CreatesSandbox(t *testing.T) {
 		t.Errorf("Expected sandbox to be created for session %s", sessionID)
 	}
 }
+
+func TestExecCommand_User(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	agent := ts.CreateTestAgent(project, "Test Agent", "claude-code")
+	session := ts.CreateTestSessionWithSandbox(workspace, agent, "Test Session")
+	client := ts.AuthenticatedClient(user)
+
+	var gotUser string
+	ts.MockSandbox.ExecFunc = func(_ context.Context, _ string, _ []string, opts sandbox.ExecOptions) (*sandbox.ExecResult, error) {
+		gotUser = opts.User
+		return &sandbox.ExecResult{Stdout: []byte("installed\n")}, nil
+	}
+	execPath := "/api/projects/" + project.ID + "/sessions/" + session.ID + "/exec"
+
+	resp := client.Post(execPath, map[string]any{"command": []string{"id"}, "user": "mallory"})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusForbidden)
+
+	resp = client.Post(execPath, map[string]any{"command": []string{"apt-get", "install", "-y", "jq"}, "user": "root"})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+
+	var result map[string]interface{}
+	ParseJSON(t, resp, &result)
+	if result["stdout"] != "installed\n" {
+		t.Errorf("Expected stdout 'installed', got %v", result["stdout"])
+	}
+	if gotUser != "root" {
+		t.Errorf("Expected command to run as root, got %q", gotUser)
+	}
+}
@@ -101,6 +101,11 @@ func NewTestServer(t *testing.T) *TestServer {
 		SessionSecret:  []byte("test-session-secret-32-bytes-long!!"),
 		EncryptionKey:  []byte("01234567890123456789012345678901"), // 32 bytes
 		WorkspaceDir:   workspaceDir,
+
+		CopyUploadMaxMB: 1024,
+
+		SandboxAllowedUsers:  []string{"root"},
+		SandboxAllowedImages: []string{"ghcr.io/example/*"},
 	}
 
 	db, err := database.New(cfg)
@@ -143,6 +148,7 @@ func NewTestServer(t *testing.T) *TestServer {
 	// Create sandbox manager and register mock provider
 	sandboxManager := sandbox.NewManager()
 	sandboxManager.RegisterProvider("mock", mockSandbox)
+	sandboxManager.SetDefault("mock")
 
 	// Create job queue early so it can be passed to services
 	jobQueue := jobs.NewQueue(s, cfg)
@@ -172,6 +178,8 @@ func NewTestServer(t *testing.T) *TestServer {
 
 	// Wire up job queue notification for immediate execution
 	h.JobQueue().SetNotifyFunc(disp.NotifyNewJob)
+	h.SetDispatcher(disp)
+	sessionSvc.SetQuiesceState(h.QuiesceState())
 
 	r := setupRouter(s, cfg, h)
 	server := httptest.NewServer(r)
@@ -215,6 +223,8 @@ func setupRouter(s *store.Store, cfg *config.Config, h *handler.Handler) *chi.Mu
 		_, _ = w.Write([]byte(`{"status":"ok"}`))
 	})
 
+	r.Get("/readyz", h.Readyz)
+
 	// Auth routes (no auth required)
 	r.Route("/auth", func(r chi.Router) {
 		r.Get("/login/{provider}", h.AuthLogin)
@@ -236,15 +246,28 @@ func setupRouter(s *store.Store, cfg *config.Config, h *handler.Handler) *chi.Mu
 			r.Delete("/{key}", h.DeletePreference)
 		})
 
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(middleware.ServerAdmin(cfg))
+			r.Get("/config", h.GetEffectiveConfig)
+			r.Post("/quiesce", h.Quiesce)
+			r.Delete("/quiesce", h.Unquiesce)
+		})
+
+		r.Get("/jobs/{jobId}/logs", h.StreamJobLogs)
+
 		r.Get("/projects", h.ListProjects)
 		r.Post("/projects", h.CreateProject)
+		r.Get("/project-templates", h.ListProjectTemplates)
 
 		r.Route("/projects/{projectId}", func(r chi.Router) {
 			r.Use(middleware.ProjectMember(s))
+			r.Use(middleware.ProjectNotFrozen(s))
 
 			r.Get("/", h.GetProject)
 			r.Put("/", h.UpdateProject)
 			r.Delete("/", h.DeleteProject)
+			r.Post("/freeze", h.FreezeProject)
+			r.Delete("/freeze", h.UnfreezeProject)
 
 			r.Get("/members", h.ListProjectMembers)
 			r.Delete("/members/{userId}", h.RemoveProjectMember)
@@ -258,6 +281,8 @@ func setupRouter(s *store.Store, cfg *config.Config, h *handler.Handler) *chi.Mu
 				r.Get("/{workspaceId}", h.GetWorkspace)
 				r.Put("/{workspaceId}", h.UpdateWorkspace)
 				r.Delete("/{workspaceId}", h.DeleteWorkspace)
+				r.Get("/{workspaceId}/default-agent", h.GetWorkspaceDefaultAgent)
+				r.Put("/{workspaceId}/default-agent", h.SetWorkspaceDefaultAgent)
 
 				// Sessions within workspace (list only - creation via /chat endpoint)
 				r.Get("/{workspaceId}/sessions", h.ListSessionsByWorkspace)
@@ -277,14 +302,44 @@ func setupRouter(s *store.Store, cfg *config.Config, h *handler.Handler) *chi.Mu
 			})
 
 			r.Route("/sessions", func(r chi.Router) {
+				r.Post("/bulk-delete", h.BulkDeleteSessions)
 				r.Get("/{sessionId}", h.GetSession)
 				r.Put("/{sessionId}", h.UpdateSession)
 				r.Patch("/{sessionId}", h.UpdateSession)
 				r.Delete("/{sessionId}", h.DeleteSession)
 				r.Post("/{sessionId}/commit", h.CommitSession)
+				r.Post("/{sessionId}/prioritize", h.PrioritizeSession)
+				r.Get("/{sessionId}/history", h.GetSessionHistory)
+				r.Post("/{sessionId}/docker-socket", h.ForwardSessionDockerSocket)
+				r.Delete("/{sessionId}/docker-socket", h.StopSessionDockerSocket)
+				r.Post("/{sessionId}/resize-disk", h.ResizeSessionDisk)
+				r.Get("/{sessionId}/fsdiff", h.GetSessionFilesystemDiff)
+				r.Get("/{sessionId}/stats", h.GetSessionStats)
+				r.Post("/{sessionId}/commit-image", h.CommitSessionImage)
+				r.Post("/{sessionId}/fork", h.ForkSession)
+				r.Post("/{sessionId}/snapshots", h.CreateSessionSnapshot)
+				r.Get("/{sessionId}/snapshots", h.ListSessionSnapshots)
+				r.Delete("/{sessionId}/snapshots/{snapshotId}", h.DeleteSessionSnapshot)
+				r.Get("/{sessionId}/snapshots/{snapshotId}/diff", h.DiffSessionSnapshot)
+				r.Get("/{sessionId}/logs", h.GetSessionLogs)
+				r.Post("/{sessionId}/reset", h.ResetSession)
+				r.Post("/{sessionId}/pause", h.PauseSession)
+				r.Post("/{sessionId}/resume", h.ResumeSession)
+				r.Post("/{sessionId}/network/test", h.TestSessionNetwork)
+				r.Get("/{sessionId}/proxy/stats", h.GetSessionProxyStats)
+				r.Get("/{sessionId}/fds", h.GetSessionFileDescriptors)
 				r.Get("/{sessionId}/files", h.ListSessionFiles)
 				r.Get("/{sessionId}/files/read", h.ReadSessionFile)
 				r.Put("/{sessionId}/files/write", h.WriteSessionFile)
+				r.Post("/{sessionId}/files/uploads", h.CreateSessionUpload)
+				r.Get("/{sessionId}/files/uploads/{uploadId}", h.GetSessionUpload)
+				r.Put("/{sessionId}/files/uploads/{uploadId}", h.AppendSessionUpload)
+				r.Delete("/{sessionId}/files/uploads/{uploadId}", h.AbortSessionUpload)
+				r.Put("/{sessionId}/files/upload", h.UploadSessionFiles)
+				r.Get("/{sessionId}/files/download", h.DownloadSessionFile)
+				r.Get("/{sessionId}/kv/{key}", h.GetSessionKV)
+				r.Put("/{sessionId}/kv/{key}", h.PutSessionKV)
+				r.Delete("/{sessionId}/kv/{key}", h.DeleteSessionKV)
 				r.Get("/{sessionId}/diff", h.GetSessionDiff)
 				r.Get("/{sessionId}/messages", h.ListMessages)
 			})
@@ -322,6 +377,9 @@ func setupRouter(s *store.Store, cfg *config.Config, h *handler.Handler) *chi.Mu
 			r.Get("/sessions/{sessionId}/terminal/ws", h.TerminalWebSocket)
 			r.Get("/sessions/{sessionId}/terminal/history", h.GetTerminalHistory)
 			r.Get("/sessions/{sessionId}/terminal/status", h.GetTerminalStatus)
+			r.Get("/sessions/{sessionId}/terminal/recordings", h.ListTerminalRecordings)
+			r.Get("/sessions/{sessionId}/terminal/{terminalId}/cast", h.GetTerminalCast)
+			r.Post("/sessions/{sessionId}/exec", h.ExecCommand)
 
 			// AI Chat endpoints (streaming)
 			r.Post("/chat", h.Chat)
@@ -363,6 +421,8 @@ func NewTestServerNoAuth(t *testing.T) *TestServer {
 		SessionSecret:  []byte("test-session-secret-32-bytes-long!!"),
 		EncryptionKey:  []byte("01234567890123456789012345678901"),
 		WorkspaceDir:   workspaceDir,
+
+		CopyUploadMaxMB: 1024,
 	}
 
 	db, err := database.New(cfg)
@@ -405,6 +465,7 @@ func NewTestServerNoAuth(t *testing.T) *TestServer {
 	// Create sandbox manager and register mock provider
 	sandboxManager := sandbox.NewManager()
 	sandboxManager.RegisterProvider("mock", mockSandbox)
+	sandboxManager.SetDefault("mock")
 
 	// Create job queue early so it can be passed to services
 	jobQueue := jobs.NewQueue(s, cfg)
@@ -434,6 +495,8 @@ func NewTestServerNoAuth(t *testing.T) *TestServer {
 
 	// Wire up job queue notification for immediate execution
 	h.JobQueue().SetNotifyFunc(disp.NotifyNewJob)
+	h.SetDispatcher(disp)
+	sessionSvc.SetQuiesceState(h.QuiesceState())
 
 	r := setupRouter(s, cfg, h)
 	server := httptest.NewServer(r)
@@ -818,6 +881,12 @@ func (tc *TestClient) Delete(path string) *http.Response {
 	return tc.do("DELETE", path, nil)
 }
 
+// PutBytes makes an authenticated PUT request with a raw binary body
+func (tc *TestClient) PutBytes(path string, body []byte) *http.Response {
+	tc.ts.T.Helper()
+	return tc.send("PUT", path, bytes.NewReader(body), "application/octet-stream")
+}
+
 func (tc *TestClient) do(method, path string, body interface{}) *http.Response {
 	tc.ts.T.Helper()
 
@@ -830,12 +899,18 @@ func (tc *TestClient) do(method, path string, body interface{}) *http.Response {
 		bodyReader = bytes.NewReader(jsonBody)
 	}
 
-	req, err := http.NewRequest(method, tc.ts.Server.URL+path, bodyReader)
+	return tc.send(method, path, bodyReader, "application/json")
+}
+
+func (tc *TestClient) send(method, path string, body io.Reader, contentType string) *http.Response {
+	tc.ts.T.Helper()
+
+	req, err := http.NewRequest(method, tc.ts.Server.URL+path, body)
 	if err != nil {
 		tc.ts.T.Fatalf("Failed to create request: %v", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 	req.AddCookie(&http.Cookie{
 		Name:  "discobot_session",
 		Value: tc.token,
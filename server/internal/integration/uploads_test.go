@@ -0,0 +1,237 @@
+package integration
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+func TestSessionUpload(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	agent := ts.CreateTestAgent(project, "Test Agent", "claude-code")
+	client := ts.AuthenticatedClient(user)
+
+	// Stand in for the agent API's upload endpoints, with a single upload
+	const uploadID = "0123456789abcdef0123456789abcdef"
+	var mu sync.Mutex
+	var size int
+	var received []byte
+	ts.MockSandbox.HTTPHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		status := func() map[string]any {
+			return map[string]any{
+				"uploadId": uploadID, "path": "big.bin", "size": size,
+				"offset": len(received), "complete": len(received) == size,
+			}
+		}
+
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/files/uploads":
+			var req struct {
+				Size int `json:"size"`
+			}
+			body, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(body, &req)
+			size, received = req.Size, nil
+			json.NewEncoder(w).Encode(status())
+		case r.URL.Path != "/files/uploads/"+uploadID:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"Upload not found"}`))
+		case r.Method == "GET":
+			json.NewEncoder(w).Encode(status())
+		case r.Method == "PUT":
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			if offset != len(received) {
+				w.WriteHeader(http.StatusConflict)
+				json.NewEncoder(w).Encode(map[string]any{"error": "wrong offset", "offset": len(received)})
+				return
+			}
+			chunk, _ := io.ReadAll(r.Body)
+			received = append(received, chunk...)
+			json.NewEncoder(w).Encode(status())
+		case r.Method == "DELETE":
+			json.NewEncoder(w).Encode(map[string]any{"uploadId": uploadID})
+		}
+	})
+
+	session := ts.CreateTestSessionWithSandbox(workspace, agent, "Test Session")
+	uploadsPath := "/api/projects/" + project.ID + "/sessions/" + session.ID + "/files/uploads"
+
+	resp := client.Post(uploadsPath, map[string]any{"path": "big.bin", "size": 10})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+	var upload map[string]any
+	ParseJSON(t, resp, &upload)
+	if upload["uploadId"] != uploadID {
+		t.Fatalf("Expected upload ID %s, got %v", uploadID, upload["uploadId"])
+	}
+
+	resp = client.PutBytes(uploadsPath+"/"+uploadID+"?offset=0", []byte("hello"))
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+
+	// Resending the same chunk reports where to resume
+	resp = client.PutBytes(uploadsPath+"/"+uploadID+"?offset=0", []byte("hello"))
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusConflict)
+	var conflict map[string]any
+	ParseJSON(t, resp, &conflict)
+	if conflict["offset"] != float64(5) {
+		t.Errorf("Expected conflict offset 5, got %v", conflict["offset"])
+	}
+
+	resp = client.PutBytes(uploadsPath+"/"+uploadID+"?offset=5", []byte("world"))
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+	ParseJSON(t, resp, &upload)
+	if upload["complete"] != true {
+		t.Errorf("Expected upload to be complete, got %v", upload)
+	}
+	if string(received) != "helloworld" {
+		t.Errorf("Expected sandbox to receive raw chunks, got %q", received)
+	}
+
+	resp = client.PutBytes(uploadsPath+"/"+uploadID, []byte("x"))
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+
+	resp = client.PutBytes(uploadsPath+"/"+uploadID+"?offset=10", []byte(strings.Repeat("x", 16<<20+1)))
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusRequestEntityTooLarge)
+
+	resp = client.Get(uploadsPath + "/not-an-upload")
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+
+	resp = client.Get(uploadsPath + "/" + strings.Repeat("f", 32))
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusNotFound)
+
+	resp = client.Delete(uploadsPath + "/" + uploadID)
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+}
+
+func TestSessionFileCopy(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	agent := ts.CreateTestAgent(project, "Test Agent", "claude-code")
+	session := ts.CreateTestSessionWithSandbox(workspace, agent, "Test Session")
+	client := ts.AuthenticatedClient(user)
+	base := "/api/projects/" + project.ID + "/sessions/" + session.ID + "/files"
+
+	upload := func(path string, parts map[string]string) *http.Response {
+		t.Helper()
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for name, content := range parts {
+			field, filename, _ := strings.Cut(name, ":")
+			part, err := mw.CreateFormFile(field, filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			part.Write([]byte(content))
+		}
+		mw.Close()
+		return client.send("PUT", base+"/upload?path="+url.QueryEscape(path), &body, mw.FormDataContentType())
+	}
+	download := func(path string) *http.Response {
+		t.Helper()
+		return client.Get(base + "/download?path=" + url.QueryEscape(path))
+	}
+
+	// Plain files are packed into a tar archive under their base names
+	resp := upload("data", map[string]string{"file:a.txt": "alpha", "file:dir/b.txt": "beta"})
+	AssertStatus(t, resp, http.StatusOK)
+	var result struct {
+		Path string `json:"path"`
+	}
+	ParseJSON(t, resp, &result)
+	if result.Path != sandbox.WorkspaceDir+"/data" {
+		t.Errorf("Expected path %s/data, got %q", sandbox.WorkspaceDir, result.Path)
+	}
+
+	resp = download(sandbox.WorkspaceDir + "/data")
+	AssertStatus(t, resp, http.StatusOK)
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-tar" {
+		t.Errorf("Expected Content-Type application/x-tar, got %q", ct)
+	}
+	files := make(map[string]string)
+	tr := tar.NewReader(resp.Body)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Invalid tar archive: %v", err)
+		}
+		content, _ := io.ReadAll(tr)
+		files[hdr.Name] = string(content)
+	}
+	resp.Body.Close()
+	if len(files) != 2 || files["a.txt"] != "alpha" || files["b.txt"] != "beta" {
+		t.Errorf("Unexpected archive contents %v", files)
+	}
+
+	// Archives are passed through as-is
+	resp = upload("", map[string]string{"archive:src.tar": "tar bytes"})
+	AssertStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+	resp = download(".")
+	AssertStatus(t, resp, http.StatusOK)
+	if got, _ := io.ReadAll(resp.Body); string(got) != "tar bytes" {
+		t.Errorf("Expected the uploaded archive back, got %q", got)
+	}
+	resp.Body.Close()
+
+	// Paths must stay inside the workspace
+	for _, resp := range []*http.Response{
+		upload("../.ssh", map[string]string{"file:authorized_keys": "key"}),
+		upload("/etc", map[string]string{"file:passwd": "root"}),
+		download("data/../../../etc/passwd"),
+		download("/etc/passwd"),
+	} {
+		AssertStatus(t, resp, http.StatusBadRequest)
+		resp.Body.Close()
+	}
+
+	for _, tc := range []struct {
+		resp *http.Response
+		want int
+	}{
+		{upload("data", nil), http.StatusBadRequest},
+		{upload("data", map[string]string{"file:a.txt": "a", "archive:b.tar": "b"}), http.StatusBadRequest},
+		{download(""), http.StatusBadRequest},
+		{download("missing"), http.StatusNotFound},
+		{client.Get("/api/projects/" + project.ID + "/sessions/nonexistent/files/download?path=data"), http.StatusNotFound},
+	} {
+		AssertStatus(t, tc.resp, tc.want)
+		tc.resp.Body.Close()
+	}
+
+	// Bodies over COPY_UPLOAD_MAX_MB are refused
+	ts.Config.CopyUploadMaxMB = 1
+	resp = upload("data", map[string]string{"file:big.bin": strings.Repeat("x", 2<<20)})
+	AssertStatus(t, resp, http.StatusRequestEntityTooLarge)
+	resp.Body.Close()
+}
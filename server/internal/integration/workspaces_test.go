@@ -140,6 +140,326 @@ func TestCreateWorkspace_DefaultSourceType(t *testing.T) {
 	}
 }
 
+func TestCreateWorkspace_NetworkMode(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	client := ts.AuthenticatedClient(user)
+
+	testPath := createWorkspaceTestGitRepo(t)
+
+	resp := client.Post("/api/projects/"+project.ID+"/workspaces", map[string]string{
+		"path":        testPath,
+		"networkMode": "offline",
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+
+	resp = client.Post("/api/projects/"+project.ID+"/workspaces", map[string]string{
+		"path":        testPath,
+		"networkMode": "isolated",
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusCreated)
+
+	var workspace map[string]interface{}
+	ParseJSON(t, resp, &workspace)
+	if workspace["networkMode"] != "isolated" {
+		t.Errorf("Expected networkMode 'isolated', got '%v'", workspace["networkMode"])
+	}
+
+	resp = client.Put("/api/projects/"+project.ID+"/workspaces/"+workspace["id"].(string), map[string]string{
+		"networkMode": "open",
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+
+	var updated map[string]interface{}
+	ParseJSON(t, resp, &updated)
+	if updated["networkMode"] != "open" {
+		t.Errorf("Expected networkMode 'open', got '%v'", updated["networkMode"])
+	}
+}
+
+func TestCreateWorkspace_Resources(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	client := ts.AuthenticatedClient(user)
+
+	testPath := createWorkspaceTestGitRepo(t)
+
+	for _, body := range []map[string]any{
+		{"path": testPath, "memoryMB": 128},
+		{"path": testPath, "memoryMB": -1},
+		{"path": testPath, "cpuCores": -0.5},
+		{"path": testPath, "diskMB": -1},
+		{"path": testPath, "diskMB": 512},
+	} {
+		resp := client.Post("/api/projects/"+project.ID+"/workspaces", body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %v, got %d", body, resp.StatusCode)
+		}
+	}
+
+	resp := client.Post("/api/projects/"+project.ID+"/workspaces", map[string]any{
+		"path":     testPath,
+		"cpuCores": 1.5,
+		"memoryMB": 2048,
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusCreated)
+
+	var workspace map[string]interface{}
+	ParseJSON(t, resp, &workspace)
+	if workspace["cpuCores"] != 1.5 || workspace["memoryMB"] != float64(2048) {
+		t.Errorf("Expected cpuCores 1.5 and memoryMB 2048, got %v and %v", workspace["cpuCores"], workspace["memoryMB"])
+	}
+
+	workspacePath := "/api/projects/" + project.ID + "/workspaces/" + workspace["id"].(string)
+
+	resp = client.Put(workspacePath, map[string]any{"memoryMB": 100})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+
+	resp = client.Put(workspacePath, map[string]any{"memoryMB": 0, "diskMB": 10240})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+
+	var updated map[string]interface{}
+	ParseJSON(t, resp, &updated)
+	if _, ok := updated["memoryMB"]; ok {
+		t.Errorf("Expected memoryMB to be cleared, got %v", updated["memoryMB"])
+	}
+	if updated["cpuCores"] != 1.5 || updated["diskMB"] != float64(10240) {
+		t.Errorf("Expected cpuCores 1.5 and diskMB 10240, got %v and %v", updated["cpuCores"], updated["diskMB"])
+	}
+}
+
+func TestCreateWorkspace_ExtraPorts(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	client := ts.AuthenticatedClient(user)
+
+	testPath := createWorkspaceTestGitRepo(t)
+
+	for _, ports := range [][]int{{0}, {3002}, {8080, 8080}} {
+		resp := client.Post("/api/projects/"+project.ID+"/workspaces", map[string]any{
+			"path":       testPath,
+			"extraPorts": ports,
+		})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected 400 for extraPorts %v, got %d", ports, resp.StatusCode)
+		}
+	}
+
+	resp := client.Post("/api/projects/"+project.ID+"/workspaces", map[string]any{
+		"path":       testPath,
+		"extraPorts": []int{3000, 8080},
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusCreated)
+
+	var workspace map[string]interface{}
+	ParseJSON(t, resp, &workspace)
+	if ports, _ := workspace["extraPorts"].([]interface{}); len(ports) != 2 || ports[0] != float64(3000) || ports[1] != float64(8080) {
+		t.Errorf("Expected extraPorts [3000 8080], got %v", workspace["extraPorts"])
+	}
+
+	workspacePath := "/api/projects/" + project.ID + "/workspaces/" + workspace["id"].(string)
+
+	resp = client.Put(workspacePath, map[string]any{"extraPorts": []any{"8080"}})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+
+	resp = client.Put(workspacePath, map[string]any{"extraPorts": nil})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+
+	var updated map[string]interface{}
+	ParseJSON(t, resp, &updated)
+	if _, ok := updated["extraPorts"]; ok {
+		t.Errorf("Expected extraPorts to be cleared, got %v", updated["extraPorts"])
+	}
+}
+
+func TestCreateWorkspace_DisableProxyCache(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	client := ts.AuthenticatedClient(user)
+
+	resp := client.Post("/api/projects/"+project.ID+"/workspaces", map[string]any{
+		"path":              createWorkspaceTestGitRepo(t),
+		"disableProxyCache": true,
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusCreated)
+
+	var workspace map[string]interface{}
+	ParseJSON(t, resp, &workspace)
+	if workspace["disableProxyCache"] != true {
+		t.Errorf("Expected disableProxyCache true, got %v", workspace["disableProxyCache"])
+	}
+
+	resp = client.Put("/api/projects/"+project.ID+"/workspaces/"+workspace["id"].(string), map[string]any{
+		"disableProxyCache": false,
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+
+	var updated map[string]interface{}
+	ParseJSON(t, resp, &updated)
+	if _, ok := updated["disableProxyCache"]; ok {
+		t.Errorf("Expected disableProxyCache to be cleared, got %v", updated["disableProxyCache"])
+	}
+}
+
+func TestCreateWorkspace_CloneOptions(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	client := ts.AuthenticatedClient(user)
+
+	testPath := createWorkspaceTestGitRepo(t)
+
+	resp := client.Post("/api/projects/"+project.ID+"/workspaces", map[string]any{
+		"path":       testPath,
+		"cloneDepth": -1,
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+
+	resp = client.Post("/api/projects/"+project.ID+"/workspaces", map[string]any{
+		"path":             testPath,
+		"cloneDepth":       1,
+		"cloneAllBranches": true,
+		"cloneSubmodules":  true,
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusCreated)
+
+	var workspace map[string]interface{}
+	ParseJSON(t, resp, &workspace)
+	if workspace["cloneDepth"] != float64(1) || workspace["cloneAllBranches"] != true || workspace["cloneSubmodules"] != true {
+		t.Errorf("Expected cloneDepth 1, cloneAllBranches and cloneSubmodules true, got %v/%v/%v", workspace["cloneDepth"], workspace["cloneAllBranches"], workspace["cloneSubmodules"])
+	}
+
+	// A depth of 0 goes back to cloning the full history
+	resp = client.Put("/api/projects/"+project.ID+"/workspaces/"+workspace["id"].(string), map[string]any{
+		"cloneDepth":      0,
+		"cloneSubmodules": false,
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+
+	var updated map[string]interface{}
+	ParseJSON(t, resp, &updated)
+	if _, ok := updated["cloneDepth"]; ok || updated["cloneAllBranches"] != true || updated["cloneSubmodules"] != nil {
+		t.Errorf("Expected full-history clone of all branches without submodules, got %v/%v/%v", updated["cloneDepth"], updated["cloneAllBranches"], updated["cloneSubmodules"])
+	}
+}
+
+func TestCreateWorkspace_StopSignal(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	client := ts.AuthenticatedClient(user)
+
+	testPath := createWorkspaceTestGitRepo(t)
+
+	resp := client.Post("/api/projects/"+project.ID+"/workspaces", map[string]any{
+		"path":       testPath,
+		"stopSignal": "quit now",
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+
+	resp = client.Post("/api/projects/"+project.ID+"/workspaces", map[string]any{
+		"path":       testPath,
+		"stopSignal": "SIGQUIT",
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusCreated)
+
+	var workspace map[string]interface{}
+	ParseJSON(t, resp, &workspace)
+	if workspace["stopSignal"] != "SIGQUIT" {
+		t.Errorf("Expected stopSignal SIGQUIT, got %v", workspace["stopSignal"])
+	}
+
+	// An empty signal goes back to the server default
+	resp = client.Put("/api/projects/"+project.ID+"/workspaces/"+workspace["id"].(string), map[string]any{
+		"stopSignal": "",
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+
+	var updated map[string]interface{}
+	ParseJSON(t, resp, &updated)
+	if _, ok := updated["stopSignal"]; ok {
+		t.Errorf("Expected stopSignal to be cleared, got %v", updated["stopSignal"])
+	}
+}
+
+func TestCreateWorkspace_Filesystem(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	client := ts.AuthenticatedClient(user)
+
+	testPath := createWorkspaceTestGitRepo(t)
+
+	resp := client.Post("/api/projects/"+project.ID+"/workspaces", map[string]any{
+		"path":       testPath,
+		"filesystem": "zfs",
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+
+	resp = client.Post("/api/projects/"+project.ID+"/workspaces", map[string]any{
+		"path":       testPath,
+		"filesystem": "agentfs",
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusCreated)
+
+	var workspace map[string]interface{}
+	ParseJSON(t, resp, &workspace)
+	if workspace["filesystem"] != "agentfs" {
+		t.Errorf("Expected filesystem agentfs, got %v", workspace["filesystem"])
+	}
+
+	resp = client.Put("/api/projects/"+project.ID+"/workspaces/"+workspace["id"].(string), map[string]any{
+		"filesystem": "btrfs",
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusBadRequest)
+
+	// An empty filesystem goes back to auto-detection
+	resp = client.Put("/api/projects/"+project.ID+"/workspaces/"+workspace["id"].(string), map[string]any{
+		"filesystem": "",
+	})
+	defer resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+
+	var updated map[string]interface{}
+	ParseJSON(t, resp, &updated)
+	if _, ok := updated["filesystem"]; ok {
+		t.Errorf("Expected filesystem to be cleared, got %v", updated["filesystem"])
+	}
+}
+
 func TestGetWorkspace(t *testing.T) {
 	t.Parallel()
 	ts := NewTestServer(t)
@@ -726,3 +1046,92 @@ func TestUpdateWorkspace_ProviderImmutable(t *testing.T) {
 		t.Errorf("Expected provider to remain 'docker', got '%v'", updated["provider"])
 	}
 }
+
+func TestWorkspaceDefaultAgent(t *testing.T) {
+	t.Parallel()
+	ts := NewTestServer(t)
+	user := ts.CreateTestUser("test@example.com")
+	project := ts.CreateTestProject(user, "Test Project")
+	workspace := ts.CreateTestWorkspace(project, "/home/user/code")
+	projectAgent := ts.CreateTestAgent(project, "Project Default", "claude-code")
+	workspaceAgent := ts.CreateTestAgent(project, "Workspace Default", "claude-code")
+	client := ts.AuthenticatedClient(user)
+	defaultAgentPath := "/api/projects/" + project.ID + "/workspaces/" + workspace.ID + "/default-agent"
+
+	type defaultAgent struct {
+		AgentID          *string `json:"agentId"`
+		EffectiveAgentID *string `json:"effectiveAgentId"`
+	}
+	get := func() defaultAgent {
+		t.Helper()
+		resp := client.Get(defaultAgentPath)
+		defer resp.Body.Close()
+		AssertStatus(t, resp, http.StatusOK)
+		var result defaultAgent
+		ParseJSON(t, resp, &result)
+		return result
+	}
+	str := func(s *string) string {
+		if s == nil {
+			return "<nil>"
+		}
+		return *s
+	}
+
+	// Neither is set
+	if got := get(); got.AgentID != nil || got.EffectiveAgentID != nil {
+		t.Errorf("Expected no default agent, got %s, %s", str(got.AgentID), str(got.EffectiveAgentID))
+	}
+
+	// The project default applies until the workspace sets its own
+	resp := client.Post("/api/projects/"+project.ID+"/agents/default", map[string]string{"agentId": projectAgent.ID})
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+	if got := get(); got.AgentID != nil || str(got.EffectiveAgentID) != projectAgent.ID {
+		t.Errorf("Expected the project default, got %s, %s", str(got.AgentID), str(got.EffectiveAgentID))
+	}
+
+	resp = client.Put(defaultAgentPath, map[string]any{"agentId": workspaceAgent.ID})
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+	if got := get(); str(got.AgentID) != workspaceAgent.ID || str(got.EffectiveAgentID) != workspaceAgent.ID {
+		t.Errorf("Expected the workspace default, got %s, %s", str(got.AgentID), str(got.EffectiveAgentID))
+	}
+
+	// It's returned with the workspace
+	resp = client.Get("/api/projects/" + project.ID + "/workspaces/" + workspace.ID)
+	defer resp.Body.Close()
+	var ws struct {
+		DefaultAgentID string `json:"defaultAgentId"`
+	}
+	ParseJSON(t, resp, &ws)
+	if ws.DefaultAgentID != workspaceAgent.ID {
+		t.Errorf("Expected defaultAgentId %s on the workspace, got %q", workspaceAgent.ID, ws.DefaultAgentID)
+	}
+
+	// Agents of other projects can't be used
+	otherProject := ts.CreateTestProject(user, "Other Project")
+	otherAgent := ts.CreateTestAgent(otherProject, "Other", "claude-code")
+	for _, agentID := range []string{otherAgent.ID, "nonexistent"} {
+		resp = client.Put(defaultAgentPath, map[string]any{"agentId": agentID})
+		resp.Body.Close()
+		AssertStatus(t, resp, http.StatusBadRequest)
+	}
+
+	// Clearing it falls back to the project default
+	resp = client.Put(defaultAgentPath, map[string]any{"agentId": nil})
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusOK)
+	if got := get(); got.AgentID != nil || str(got.EffectiveAgentID) != projectAgent.ID {
+		t.Errorf("Expected the project default after clearing, got %s, %s", str(got.AgentID), str(got.EffectiveAgentID))
+	}
+
+	// Workspaces of other projects aren't visible
+	otherWorkspace := ts.CreateTestWorkspace(otherProject, "/home/user/other")
+	resp = client.Get("/api/projects/" + project.ID + "/workspaces/" + otherWorkspace.ID + "/default-agent")
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusNotFound)
+	resp = client.Put("/api/projects/"+project.ID+"/workspaces/"+otherWorkspace.ID+"/default-agent", map[string]any{"agentId": projectAgent.ID})
+	resp.Body.Close()
+	AssertStatus(t, resp, http.StatusNotFound)
+}
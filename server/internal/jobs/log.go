@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// Logger receives the log lines of a running job.
+type Logger interface {
+	Log(message string)
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a context whose Logf calls are also written to l.
+// The dispatcher sets it for each job it executes.
+func WithLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// Logf logs like log.Printf and, when ctx belongs to a job run by the
+// dispatcher, also writes the line to the job's log so it can be streamed.
+func Logf(ctx context.Context, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	log.Print(msg)
+	if l, ok := ctx.Value(loggerKey{}).(Logger); ok {
+		l.Log(msg)
+	}
+}
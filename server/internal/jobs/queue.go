@@ -28,6 +28,12 @@ func (q *Queue) SetNotifyFunc(f func()) {
 	q.notifyFunc = f
 }
 
+// Notify wakes the dispatcher, e.g. after a project is unfrozen and its
+// queued jobs become claimable again.
+func (q *Queue) Notify() {
+	q.notify()
+}
+
 // notify calls the notify function if set.
 func (q *Queue) notify() {
 	if q.notifyFunc != nil {
@@ -91,6 +97,10 @@ func (q *Queue) Enqueue(ctx context.Context, payload JobPayload) error {
 		ResourceType: &resType,
 		ResourceID:   &resID,
 	}
+	if p, ok := payload.(ProjectScoped); ok && p.ProjectKey() != "" {
+		projectID := p.ProjectKey()
+		job.ProjectID = &projectID
+	}
 
 	if err := q.store.CreateJob(ctx, job); err != nil {
 		return err
@@ -98,3 +108,45 @@ func (q *Queue) Enqueue(ctx context.Context, payload JobPayload) error {
 	q.notify()
 	return nil
 }
+
+// ErrNoPendingJob is returned when a resource has no queued job to reprioritize.
+var ErrNoPendingJob = errors.New("no pending job for resource")
+
+// PrioritizeSessionInit changes the priority of a session's pending init job.
+// If priority is nil, the job is moved ahead of all other pending jobs.
+// Returns ErrNoPendingJob if the session has no queued init job (e.g. it is
+// already running or has finished).
+func (q *Queue) PrioritizeSessionInit(ctx context.Context, sessionID string, priority *int) (*model.Job, error) {
+	job, err := q.store.GetPendingJobForResource(ctx, string(JobTypeSessionInit), ResourceTypeSession, sessionID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrNoPendingJob
+		}
+		return nil, err
+	}
+
+	newPriority := job.Priority
+	if priority != nil {
+		newPriority = *priority
+	} else {
+		highest, ok, err := q.store.MaxPendingJobPriority(ctx, job.ID)
+		if err != nil {
+			return nil, err
+		}
+		if ok && highest >= job.Priority {
+			newPriority = highest + 1
+		}
+	}
+
+	if newPriority != job.Priority {
+		if err := q.store.UpdatePendingJobPriority(ctx, job.ID, newPriority); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return nil, ErrNoPendingJob
+			}
+			return nil, err
+		}
+		job.Priority = newPriority
+		q.notify()
+	}
+	return job, nil
+}
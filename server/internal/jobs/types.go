@@ -35,6 +35,12 @@ type DuplicateAllower interface {
 	AllowDuplicates() bool
 }
 
+// ProjectScoped is an optional interface payloads can implement to tie the job
+// to a project. Jobs of a frozen project are not claimed until it is unfrozen.
+type ProjectScoped interface {
+	ProjectKey() string
+}
+
 // SessionInitPayload is the payload for session_init jobs.
 type SessionInitPayload struct {
 	ProjectID   string `json:"projectId"`
@@ -45,6 +51,7 @@ type SessionInitPayload struct {
 
 func (p SessionInitPayload) JobType() JobType              { return JobTypeSessionInit }
 func (p SessionInitPayload) ResourceKey() (string, string) { return ResourceTypeSession, p.SessionID }
+func (p SessionInitPayload) ProjectKey() string            { return p.ProjectID }
 
 // WorkspaceInitPayload is the payload for workspace_init jobs.
 type WorkspaceInitPayload struct {
@@ -56,6 +63,7 @@ func (p WorkspaceInitPayload) JobType() JobType { return JobTypeWorkspaceInit }
 func (p WorkspaceInitPayload) ResourceKey() (string, string) {
 	return ResourceTypeWorkspace, p.WorkspaceID
 }
+func (p WorkspaceInitPayload) ProjectKey() string { return p.ProjectID }
 
 // SessionDeletePayload is the payload for session_delete jobs.
 type SessionDeletePayload struct {
@@ -66,12 +74,14 @@ type SessionDeletePayload struct {
 func (p SessionDeletePayload) JobType() JobType              { return JobTypeSessionDelete }
 func (p SessionDeletePayload) ResourceKey() (string, string) { return ResourceTypeSession, p.SessionID }
 func (p SessionDeletePayload) Priority() int                 { return 5 }
+func (p SessionDeletePayload) ProjectKey() string            { return p.ProjectID }
 
 // SessionCommitPayload is the payload for session_commit jobs.
 type SessionCommitPayload struct {
 	ProjectID   string `json:"projectId"`
 	SessionID   string `json:"sessionId"`
 	WorkspaceID string `json:"workspaceId"`
+	UserID      string `json:"userId,omitempty"` // User who triggered the commit
 }
 
 func (p SessionCommitPayload) JobType() JobType { return JobTypeSessionCommit }
@@ -80,3 +90,4 @@ func (p SessionCommitPayload) ResourceKey() (string, string) {
 }
 func (p SessionCommitPayload) MaxAttempts() int      { return 1 }
 func (p SessionCommitPayload) AllowDuplicates() bool { return true }
+func (p SessionCommitPayload) ProjectKey() string    { return p.ProjectID }
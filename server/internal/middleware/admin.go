@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+)
+
+// ServerAdmin middleware restricts routes to the users listed in ADMIN_EMAILS.
+// When auth is disabled the anonymous user is the only user, so everyone is
+// an admin. Must run after Auth.
+func ServerAdmin(cfg *config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if cfg.AuthEnabled && !isServerAdmin(cfg, GetUserEmail(r.Context())) {
+				http.Error(w, `{"error":"Server admin access required"}`, http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func isServerAdmin(cfg *config.Config, email string) bool {
+	if email == "" {
+		return false
+	}
+	for _, admin := range cfg.AdminEmails {
+		if strings.EqualFold(admin, email) {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 
@@ -51,6 +52,35 @@ func ProjectMember(s *store.Store) func(http.Handler) http.Handler {
 	}
 }
 
+// ProjectNotFrozen middleware rejects mutating requests to a frozen project.
+// Reads are always allowed, as is the freeze endpoint itself so the project
+// can be unfrozen. Must run after ProjectMember.
+func ProjectNotFrozen(s *store.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				next.ServeHTTP(w, r)
+				return
+			}
+			if strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/freeze") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			project, err := s.GetProjectByID(r.Context(), GetProjectID(r.Context()))
+			if err == nil && project.Frozen {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(`{"error":"Project is frozen for maintenance; unfreeze it to make changes"}`))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // GetProjectID extracts project ID from context
 func GetProjectID(ctx context.Context) string {
 	if id, ok := ctx.Value(ProjectIDKey).(string); ok {
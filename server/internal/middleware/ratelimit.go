@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/model"
+)
+
+// RateLimiter limits requests to expensive endpoints with a token bucket per
+// client. Clients are identified by user ID, or by IP address when the
+// request is anonymous. A nil *RateLimiter allows every request.
+type RateLimiter struct {
+	rate  float64 // Tokens added per second
+	burst float64 // Bucket capacity
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimitPruneInterval is how often idle buckets are dropped.
+const rateLimitPruneInterval = time.Minute
+
+// NewRateLimiter creates a limiter allowing perMinute requests per client on
+// average, with bursts of up to burst requests (minimum 1). Returns nil (no
+// limiting) if perMinute is 0 or less.
+func NewRateLimiter(perMinute, burst int) *RateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &RateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(max(burst, 1)),
+		now:     time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Limit wraps a handler, rejecting requests over the client's limit with
+// 429 Too Many Requests and a Retry-After header. It must run after Auth so
+// the user is known. Don't use it on SSE or terminal routes: those are
+// long-lived connections, not repeated requests.
+func (l *RateLimiter) Limit(next http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, retryAfter := l.allow(rateLimitKey(r))
+		if !ok {
+			seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"error":"Too many requests, try again later"}`))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// allow takes a token from the client's bucket. If the bucket is empty it
+// returns false and how long until a token is available.
+func (l *RateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	} else {
+		b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*l.rate, l.burst)
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / l.rate
+		return false, time.Duration(wait * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// prune drops buckets that have been idle long enough to refill completely,
+// since a new bucket starts out full anyway.
+func (l *RateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < rateLimitPruneInterval {
+		return
+	}
+	l.lastPrune = now
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// rateLimitKey identifies the client: the authenticated user, or the remote
+// IP for the anonymous user (RealIP has already applied X-Forwarded-For from
+// trusted proxies).
+func rateLimitKey(r *http.Request) string {
+	if userID := GetUserID(r.Context()); userID != "" && userID != model.AnonymousUserID {
+		return "user:" + userID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
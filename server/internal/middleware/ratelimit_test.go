@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/model"
+)
+
+func TestRateLimiter_Limit(t *testing.T) {
+	limiter := NewRateLimiter(60, 2)
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+
+	handler := limiter.Limit(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	request := func(userID, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/chat", nil)
+		req.RemoteAddr = remoteAddr
+		if userID != "" {
+			req = req.WithContext(context.WithValue(req.Context(), UserIDKey, userID))
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	// The burst is allowed, then requests are rejected until a token refills
+	for i := range 2 {
+		if rec := request("user-1", "10.0.0.1:1234"); rec.Code != http.StatusNoContent {
+			t.Fatalf("request %d: expected 204, got %d", i+1, rec.Code)
+		}
+	}
+	rec := request("user-1", "10.0.0.1:1234")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 after the burst, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("expected Retry-After 1, got %q", got)
+	}
+
+	// Other users have their own bucket
+	if rec := request("user-2", "10.0.0.1:1234"); rec.Code != http.StatusNoContent {
+		t.Errorf("expected another user to be allowed, got %d", rec.Code)
+	}
+
+	now = now.Add(time.Second)
+	if rec := request("user-1", "10.0.0.1:1234"); rec.Code != http.StatusNoContent {
+		t.Errorf("expected a request to be allowed after refill, got %d", rec.Code)
+	}
+}
+
+func TestRateLimiter_AnonymousByIP(t *testing.T) {
+	limiter := NewRateLimiter(60, 1)
+	limiter.now = func() time.Time { return time.Unix(1000, 0) }
+
+	handler := limiter.Limit(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	request := func(remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodPost, "/chat", nil)
+		req.RemoteAddr = remoteAddr
+		req = req.WithContext(context.WithValue(req.Context(), UserIDKey, model.AnonymousUserID))
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	if code := request("10.0.0.1:1234"); code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", code)
+	}
+	if code := request("10.0.0.1:5678"); code != http.StatusTooManyRequests {
+		t.Errorf("expected the same IP to be limited, got %d", code)
+	}
+	if code := request("10.0.0.2:1234"); code != http.StatusNoContent {
+		t.Errorf("expected another IP to be allowed, got %d", code)
+	}
+}
+
+func TestRateLimiter_PrunesIdleBuckets(t *testing.T) {
+	// Buckets take 100s to refill
+	limiter := NewRateLimiter(6, 10)
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+
+	limiter.allow("user:a")
+	now = now.Add(40 * time.Second)
+	limiter.allow("user:b")
+
+	// a has been idle long enough to refill; b hasn't
+	now = now.Add(70 * time.Second)
+	limiter.allow("user:c")
+	if _, ok := limiter.buckets["user:a"]; ok {
+		t.Error("expected idle bucket to be pruned")
+	}
+	if len(limiter.buckets) != 2 {
+		t.Errorf("expected 2 buckets, got %d", len(limiter.buckets))
+	}
+}
+
+func TestNewRateLimiter_Disabled(t *testing.T) {
+	limiter := NewRateLimiter(0, 10)
+	if limiter != nil {
+		t.Fatal("expected a nil limiter when disabled")
+	}
+	called := false
+	handler := limiter.Limit(func(http.ResponseWriter, *http.Request) { called = true })
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+	if !called {
+		t.Error("expected a nil limiter to pass requests through")
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RealIP sets r.RemoteAddr to the client's IP address as reported by the
+// X-Forwarded-For or X-Real-IP header, but only for requests that come from
+// one of trustedProxies. Anyone else could set those headers to choose the
+// address that per-IP rate limits and request logs see, so their requests
+// keep the connection's address.
+func RealIP(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := forwardedClientIP(r, trustedProxies); ip.IsValid() {
+				r.RemoteAddr = ip.String()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClientIP returns the client IP reported by a trusted proxy, or the
+// zero Addr if the request didn't come from one or reports no valid IP.
+// X-Forwarded-For is read from the right, skipping further trusted proxies:
+// entries left of the first untrusted hop were supplied by the client.
+func forwardedClientIP(r *http.Request, trustedProxies []netip.Prefix) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !isTrustedProxy(peer, trustedProxies) {
+		return netip.Addr{}
+	}
+
+	if values := r.Header.Values("X-Forwarded-For"); len(values) > 0 {
+		hops := strings.Split(strings.Join(values, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				return netip.Addr{}
+			}
+			if i == 0 || !isTrustedProxy(addr, trustedProxies) {
+				return addr.Unmap()
+			}
+		}
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap()
+	}
+	return netip.Addr{}
+}
+
+// isTrustedProxy reports whether addr is in one of trustedProxies.
+func isTrustedProxy(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
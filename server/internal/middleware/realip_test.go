@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.1/32")}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		xRealIP    string
+		want       string
+	}{
+		{"untrusted peer keeps its address", "203.0.113.5:1234", []string{"1.2.3.4"}, "5.6.7.8", "203.0.113.5:1234"},
+		{"trusted proxy", "10.1.2.3:1234", []string{"198.51.100.7"}, "", "198.51.100.7"},
+		{"spoofed entries left of the client are ignored", "10.1.2.3:1234", []string{"1.2.3.4, 198.51.100.7"}, "", "198.51.100.7"},
+		{"chain of trusted proxies", "10.1.2.3:1234", []string{"198.51.100.7, 192.168.1.1", "10.9.9.9"}, "", "198.51.100.7"},
+		{"only trusted hops", "10.1.2.3:1234", []string{"10.4.4.4"}, "", "10.4.4.4"},
+		{"X-Real-IP from a trusted proxy", "192.168.1.1:80", nil, "198.51.100.7", "198.51.100.7"},
+		{"invalid header", "10.1.2.3:1234", []string{"not-an-ip"}, "", "10.1.2.3:1234"},
+		{"no headers", "10.1.2.3:1234", nil, "", "10.1.2.3:1234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := RealIP(trusted)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.xRealIP != "" {
+				req.Header.Set("X-Real-IP", tt.xRealIP)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
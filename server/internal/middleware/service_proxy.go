@@ -2,13 +2,19 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/obot-platform/discobot/server/internal/sandbox"
 )
@@ -18,29 +24,45 @@ import (
 // Service IDs are normalized lowercase (a-z0-9_- only).
 var serviceSubdomainPattern = regexp.MustCompile(`^([0-9A-Za-z]{10,26})-svc-([a-z0-9_-]+)\.`)
 
-// findSessionID finds the actual session ID with correct casing.
+// serviceProxyRetryAfter is the Retry-After (in seconds) sent while a
+// session's sandbox isn't ready to serve requests.
+const serviceProxyRetryAfter = 5
+
+// serviceProxyBusyRetryAfter is the Retry-After (in seconds) sent when a
+// request is rejected by the connection limits.
+const serviceProxyBusyRetryAfter = 1
+
+// serviceProxyStartTimeout bounds a sandbox start triggered by a proxied request.
+const serviceProxyStartTimeout = 5 * time.Minute
+
+// SessionStarter starts a session's sandbox and waits until it is ready.
+// The service proxy calls it in the background when a request arrives for a
+// session whose sandbox isn't running.
+type SessionStarter func(ctx context.Context, sessionID string) error
+
+// findSandbox finds the sandbox for a session ID from a URL.
 // DNS/URLs are case-insensitive, so we need to do a case-insensitive lookup.
-func findSessionID(ctx context.Context, provider sandbox.Provider, urlSessionID string) (string, error) {
+func findSandbox(ctx context.Context, provider sandbox.Provider, urlSessionID string) (*sandbox.Sandbox, error) {
 	// First try exact match (fast path)
 	sb, err := provider.Get(ctx, urlSessionID)
 	if err == nil && sb != nil {
-		return sb.SessionID, nil
+		return sb, nil
 	}
 
 	// Fall back to case-insensitive search via List
 	sandboxes, err := provider.List(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to list sandboxes: %w", err)
+		return nil, fmt.Errorf("failed to list sandboxes: %w", err)
 	}
 
 	lowerURLSessionID := strings.ToLower(urlSessionID)
 	for _, sb := range sandboxes {
 		if strings.ToLower(sb.SessionID) == lowerURLSessionID {
-			return sb.SessionID, nil
+			return sb, nil
 		}
 	}
 
-	return "", fmt.Errorf("session not found: %s", urlSessionID)
+	return nil, fmt.Errorf("session not found: %s", urlSessionID)
 }
 
 // ServiceProxy creates middleware that intercepts requests to service subdomains
@@ -54,11 +76,39 @@ func findSessionID(ctx context.Context, provider sandbox.Provider, urlSessionID
 //
 // This properly handles:
 // - HTTP/1.1 and HTTP/2
-// - WebSocket upgrades
+// - WebSocket upgrades (tunneled over a hijacked connection)
 // - Server-Sent Events (SSE)
 // - Chunked transfer encoding
 // - Request/response streaming
-func ServiceProxy(provider sandbox.Provider) func(http.Handler) http.Handler {
+//
+// Requests for a session whose sandbox isn't running get a 503 with a
+// Retry-After header: an HTML page for browsers, JSON otherwise. If start is
+// non-nil, the first such request also starts the sandbox in the background
+// and the HTML page reloads until it is up. Readiness of the service itself
+// is handled by the agent-api, which starts it on demand.
+//
+// Requests over the limits get a 503 with a Retry-After header once they have
+// waited limits.QueueTimeout for a free slot.
+func ServiceProxy(provider sandbox.Provider, start SessionStarter, limits ServiceProxyLimits) func(http.Handler) http.Handler {
+	var starting sync.Map // session ID -> struct{}, for starts in progress
+	limiter := newConnLimiter(limits)
+
+	startSession := func(sessionID string) {
+		if _, inProgress := starting.LoadOrStore(sessionID, struct{}{}); inProgress {
+			return
+		}
+		go func() {
+			defer starting.Delete(sessionID)
+			// Not tied to the request, which returns immediately
+			ctx, cancel := context.WithTimeout(context.Background(), serviceProxyStartTimeout)
+			defer cancel()
+			log.Printf("[ServiceProxy] Starting sandbox for session %s on proxied request", sessionID)
+			if err := start(ctx, sessionID); err != nil {
+				log.Printf("[ServiceProxy] Failed to start sandbox for session %s: %v", sessionID, err)
+			}
+		}()
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			host := r.Host
@@ -76,7 +126,7 @@ func ServiceProxy(provider sandbox.Provider) func(http.Handler) http.Handler {
 			ctx := r.Context()
 
 			// Find the actual session ID with correct casing
-			sessionID, err := findSessionID(ctx, provider, urlSessionID)
+			sb, err := findSandbox(ctx, provider, urlSessionID)
 			if err != nil {
 				writeJSONError(w, http.StatusBadGateway, "Failed to find session", map[string]string{
 					"sessionId": urlSessionID,
@@ -85,6 +135,44 @@ func ServiceProxy(provider sandbox.Provider) func(http.Handler) http.Handler {
 				})
 				return
 			}
+			sessionID := sb.SessionID
+
+			if sb.Status == sandbox.StatusPaused && start != nil {
+				// Resume on demand like a stopped sandbox is started; the
+				// sandbox watcher marks the session ready
+				if err := provider.Unpause(ctx, sessionID); err != nil {
+					log.Printf("[ServiceProxy] Failed to resume paused sandbox for session %s: %v", sessionID, err)
+				} else {
+					sb.Status = sandbox.StatusRunning
+				}
+			}
+
+			if sb.Status != sandbox.StatusRunning {
+				if start != nil {
+					startSession(sessionID)
+					writeSandboxUnavailable(w, r, sessionID, serviceID, true,
+						"The sandbox for this session is starting. This page will reload when it is ready.")
+				} else {
+					writeSandboxUnavailable(w, r, sessionID, serviceID, false,
+						fmt.Sprintf("The sandbox for this session is %s. Open the session in Discobot to start it.", sb.Status))
+				}
+				return
+			}
+
+			// Hold a slot for the whole request. ReverseProxy.ServeHTTP returns
+			// only once a WebSocket or SSE connection is closed, so those are
+			// counted until then.
+			release, ok := limiter.acquire(ctx, sessionID)
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(serviceProxyBusyRetryAfter))
+				writeJSONError(w, http.StatusServiceUnavailable, "Too many connections", map[string]string{
+					"sessionId": sessionID,
+					"serviceId": serviceID,
+					"message":   "Too many concurrent requests to this session's services, try again later",
+				})
+				return
+			}
+			defer release()
 
 			// Get HTTP client for the sandbox (handles transport-level routing)
 			client, err := provider.HTTPClient(ctx, sessionID)
@@ -97,40 +185,26 @@ func ServiceProxy(provider sandbox.Provider) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Target URL for the agent-api
-			// The agent-api expects: /services/:id/http/*
-			target, _ := url.Parse("http://sandbox")
+			// WebSocket and other upgrades are tunneled over a raw connection,
+			// which works whether or not the transport supports upgrades
+			if isUpgradeRequest(r) && serveUpgrade(w, r, provider, client, sessionID, serviceID) {
+				return
+			}
 
 			// Create reverse proxy
 			proxy := &httputil.ReverseProxy{
 				Director: func(req *http.Request) {
-					req.URL.Scheme = target.Scheme
-					req.URL.Host = target.Host
-					req.URL.Path = "/services/" + serviceID + "/http" + r.URL.Path
-					req.URL.RawQuery = r.URL.RawQuery
-
-					// Set the Host header to the target
-					req.Host = target.Host
-
-					// Set x-forwarded-* headers
-					req.Header.Set("X-Forwarded-Path", r.URL.Path)
-					req.Header.Set("X-Forwarded-Host", r.Host)
-					req.Header.Set("X-Forwarded-Proto", getScheme(r))
-
-					// Preserve or append X-Forwarded-For
-					clientIP := r.RemoteAddr
-					if idx := strings.LastIndex(clientIP, ":"); idx != -1 {
-						clientIP = clientIP[:idx]
-					}
-					if prior := r.Header.Get("X-Forwarded-For"); prior != "" {
-						req.Header.Set("X-Forwarded-For", prior+", "+clientIP)
-					} else {
-						req.Header.Set("X-Forwarded-For", clientIP)
-					}
+					rewriteServiceRequest(req, r, serviceID)
 				},
 				Transport: client.Transport,
 				ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
 					log.Printf("[ServiceProxy] Error proxying request to %s: %v", r.URL.String(), err)
+					if errors.Is(err, syscall.ECONNREFUSED) {
+						// The sandbox is running but its agent-api isn't listening yet
+						writeSandboxUnavailable(w, r, sessionID, serviceID, true,
+							"The sandbox for this session is starting. This page will reload when it is ready.")
+						return
+					}
 					writeJSONError(w, http.StatusBadGateway, "Service unavailable", map[string]string{
 						"sessionId": sessionID,
 						"serviceId": serviceID,
@@ -146,6 +220,36 @@ func ServiceProxy(provider sandbox.Provider) func(http.Handler) http.Handler {
 	}
 }
 
+// rewriteServiceRequest points req, a copy of the client's request r, at the
+// service's route on the agent-api (/services/:id/http/*) and sets the
+// X-Forwarded-* headers.
+func rewriteServiceRequest(req, r *http.Request, serviceID string) {
+	target, _ := url.Parse("http://sandbox")
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	req.URL.Path = "/services/" + serviceID + "/http" + r.URL.Path
+	req.URL.RawQuery = r.URL.RawQuery
+
+	// Set the Host header to the target
+	req.Host = target.Host
+
+	// Set x-forwarded-* headers
+	req.Header.Set("X-Forwarded-Path", r.URL.Path)
+	req.Header.Set("X-Forwarded-Host", r.Host)
+	req.Header.Set("X-Forwarded-Proto", getScheme(r))
+
+	// Preserve or append X-Forwarded-For
+	clientIP := r.RemoteAddr
+	if idx := strings.LastIndex(clientIP, ":"); idx != -1 {
+		clientIP = clientIP[:idx]
+	}
+	if prior := r.Header.Get("X-Forwarded-For"); prior != "" {
+		req.Header.Set("X-Forwarded-For", prior+", "+clientIP)
+	} else {
+		req.Header.Set("X-Forwarded-For", clientIP)
+	}
+}
+
 // writeJSONError writes a JSON error response.
 func writeJSONError(w http.ResponseWriter, status int, errorType string, fields map[string]string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -159,6 +263,71 @@ func writeJSONError(w http.ResponseWriter, status int, errorType string, fields
 	fmt.Fprintf(w, "{%s}", strings.Join(parts, ","))
 }
 
+// sandboxUnavailablePage is shown to browsers while a session's sandbox
+// isn't running.
+var sandboxUnavailablePage = template.Must(template.New("unavailable").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	{{if .Refresh}}<meta http-equiv="refresh" content="{{.RetryAfter}}">{{end}}
+	<title>Sandbox not ready</title>
+	<style>
+		body {
+			font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, Helvetica, Arial, sans-serif;
+			display: flex;
+			justify-content: center;
+			align-items: center;
+			min-height: 100vh;
+			margin: 0;
+			background: #f5f5f5;
+			color: #333;
+		}
+		@media (prefers-color-scheme: dark) {
+			body { background: #1a1a1a; color: #e0e0e0; }
+			p { color: #999; }
+		}
+		.container { text-align: center; padding: 2rem; }
+		h1 { font-size: 1.25rem; font-weight: 500; margin: 0 0 0.5rem; }
+		p { font-size: 0.875rem; color: #666; margin: 0; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<h1>Sandbox not ready</h1>
+		<p>{{.Message}}</p>
+	</div>
+</body>
+</html>
+`))
+
+// writeSandboxUnavailable writes a 503 for a service whose sandbox isn't
+// ready. Browsers get an HTML page that reloads itself if refresh is set;
+// other clients get JSON.
+func writeSandboxUnavailable(w http.ResponseWriter, r *http.Request, sessionID, serviceID string, refresh bool, message string) {
+	w.Header().Set("Retry-After", strconv.Itoa(serviceProxyRetryAfter))
+	w.Header().Set("Cache-Control", "no-store")
+
+	accept := r.Header.Get("Accept")
+	if !strings.Contains(accept, "text/html") && !strings.Contains(accept, "application/xhtml+xml") {
+		writeJSONError(w, http.StatusServiceUnavailable, "Sandbox not ready", map[string]string{
+			"sessionId": sessionID,
+			"serviceId": serviceID,
+			"message":   message,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if err := sandboxUnavailablePage.Execute(w, map[string]any{
+		"Refresh":    refresh,
+		"RetryAfter": serviceProxyRetryAfter,
+		"Message":    message,
+	}); err != nil {
+		log.Printf("[ServiceProxy] Failed to render unavailable page: %v", err)
+	}
+}
+
 // getScheme returns the request scheme (http or https).
 func getScheme(r *http.Request) string {
 	if r.TLS != nil {
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ServiceProxyLimits caps the connections the service proxy opens to
+// sandboxes, so one busy service can't exhaust its sandbox's connection
+// capacity or the server's file descriptors.
+type ServiceProxyLimits struct {
+	MaxConnsPerSession int           // Concurrent proxied requests per session (0 = unlimited)
+	MaxConns           int           // Concurrent proxied requests across all sessions (0 = unlimited)
+	QueueTimeout       time.Duration // How long a request waits for a free slot before a 503 (0 = don't wait)
+}
+
+// connLimiter counts in-flight proxied requests per session and overall.
+// WebSocket and SSE requests hold their slot until the connection closes.
+type connLimiter struct {
+	limits ServiceProxyLimits
+	global chan struct{} // nil if unlimited
+
+	mu       sync.Mutex
+	sessions map[string]*sessionConns
+}
+
+// sessionConns is a session's semaphore. users counts holders and waiters,
+// so the entry is dropped once nobody needs it.
+type sessionConns struct {
+	sem   chan struct{}
+	users int
+}
+
+func newConnLimiter(limits ServiceProxyLimits) *connLimiter {
+	l := &connLimiter{limits: limits, sessions: make(map[string]*sessionConns)}
+	if limits.MaxConns > 0 {
+		l.global = make(chan struct{}, limits.MaxConns)
+	}
+	return l
+}
+
+// acquire takes a slot for sessionID, waiting up to QueueTimeout for one to
+// free up. It returns a function that releases the slot, or false if the
+// limit is still reached (or ctx is done) when the wait ends.
+func (l *connLimiter) acquire(ctx context.Context, sessionID string) (func(), bool) {
+	if l.limits.QueueTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.limits.QueueTimeout)
+		defer cancel()
+	}
+
+	var sess *sessionConns
+	if l.limits.MaxConnsPerSession > 0 {
+		sess = l.sessionConns(sessionID)
+		if !l.wait(ctx, sess.sem) {
+			l.dropSessionConns(sessionID)
+			return nil, false
+		}
+	}
+
+	if l.global != nil && !l.wait(ctx, l.global) {
+		if sess != nil {
+			<-sess.sem
+			l.dropSessionConns(sessionID)
+		}
+		return nil, false
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if l.global != nil {
+				<-l.global
+			}
+			if sess != nil {
+				<-sess.sem
+				l.dropSessionConns(sessionID)
+			}
+		})
+	}, true
+}
+
+// wait takes a slot from sem, giving up when ctx is done. Without a queue
+// timeout it doesn't wait at all.
+func (l *connLimiter) wait(ctx context.Context, sem chan struct{}) bool {
+	if l.limits.QueueTimeout <= 0 {
+		select {
+		case sem <- struct{}{}:
+			return true
+		default:
+			return false
+		}
+	}
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sessionConns returns the session's semaphore, registering a user of it.
+func (l *connLimiter) sessionConns(sessionID string) *sessionConns {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sess, ok := l.sessions[sessionID]
+	if !ok {
+		sess = &sessionConns{sem: make(chan struct{}, l.limits.MaxConnsPerSession)}
+		l.sessions[sessionID] = sess
+	}
+	sess.users++
+	return sess
+}
+
+// dropSessionConns unregisters a user of the session's semaphore.
+func (l *connLimiter) dropSessionConns(sessionID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if sess, ok := l.sessions[sessionID]; ok {
+		if sess.users--; sess.users <= 0 {
+			delete(l.sessions, sessionID)
+		}
+	}
+}
@@ -2,11 +2,15 @@ package middleware
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gorilla/websocket"
+
 	"github.com/obot-platform/discobot/server/internal/sandbox"
 )
 
@@ -135,6 +139,7 @@ func TestServiceSubdomainPattern(t *testing.T) {
 type mockSandboxProvider struct {
 	sandboxes map[string]*sandbox.Sandbox
 	client    *http.Client
+	unpaused  []string
 }
 
 func (m *mockSandboxProvider) ImageExists(_ context.Context) bool {
@@ -145,6 +150,10 @@ func (m *mockSandboxProvider) Image() string {
 	return "test-image"
 }
 
+func (m *mockSandboxProvider) Healthy(_ context.Context) error {
+	return nil
+}
+
 func (m *mockSandboxProvider) Create(_ context.Context, _ string, _ sandbox.CreateOptions) (*sandbox.Sandbox, error) {
 	return nil, nil
 }
@@ -157,6 +166,15 @@ func (m *mockSandboxProvider) Stop(_ context.Context, _ string, _ time.Duration)
 	return nil
 }
 
+func (m *mockSandboxProvider) Pause(_ context.Context, _ string) error {
+	return nil
+}
+
+func (m *mockSandboxProvider) Unpause(_ context.Context, sessionID string) error {
+	m.unpaused = append(m.unpaused, sessionID)
+	return nil
+}
+
 func (m *mockSandboxProvider) Remove(_ context.Context, _ string, _ ...sandbox.RemoveOption) error {
 	return nil
 }
@@ -196,6 +214,10 @@ func (m *mockSandboxProvider) HTTPClient(_ context.Context, _ string) (*http.Cli
 	return m.client, nil
 }
 
+func (m *mockSandboxProvider) WaitForStatus(ctx context.Context, sessionID string, _ sandbox.Status, _ time.Duration) (*sandbox.Sandbox, error) {
+	return m.Get(ctx, sessionID)
+}
+
 func (m *mockSandboxProvider) Watch(_ context.Context) (<-chan sandbox.StateEvent, error) {
 	return nil, nil
 }
@@ -213,7 +235,7 @@ func TestServiceProxyNonServiceSubdomain(t *testing.T) {
 		w.Write([]byte("next handler"))
 	})
 
-	middleware := ServiceProxy(provider)(next)
+	middleware := ServiceProxy(provider, nil, ServiceProxyLimits{})(next)
 
 	tests := []struct {
 		name string
@@ -253,7 +275,7 @@ func TestServiceProxySessionNotFound(t *testing.T) {
 		t.Error("next handler should not be called")
 	})
 
-	middleware := ServiceProxy(provider)(next)
+	middleware := ServiceProxy(provider, nil, ServiceProxyLimits{})(next)
 
 	req := httptest.NewRequest("GET", "http://nonexistent1234-svc-myservice.localhost:3000/", nil)
 	req.Host = "nonexistent1234-svc-myservice.localhost:3000"
@@ -272,8 +294,326 @@ func TestServiceProxySessionNotFound(t *testing.T) {
 	}
 }
 
-// TestFindSessionIDCaseInsensitive verifies case-insensitive session ID lookup
-func TestFindSessionIDCaseInsensitive(t *testing.T) {
+// TestServiceProxySandboxNotRunning verifies that requests for a stopped
+// sandbox get a 503 and, with a starter, start it once
+func TestServiceProxySandboxNotRunning(t *testing.T) {
+	const host = "abcdefghijklmnop-svc-web.localhost:3000"
+	provider := &mockSandboxProvider{
+		sandboxes: map[string]*sandbox.Sandbox{
+			"abcdefghijklmnop": {SessionID: "abcdefghijklmnop", Status: sandbox.StatusStopped},
+		},
+	}
+	next := http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		t.Error("next handler should not be called")
+	})
+
+	t.Run("without starter", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "http://"+host+"/", nil)
+		req.Host = host
+		rr := httptest.NewRecorder()
+		ServiceProxy(provider, nil, ServiceProxyLimits{})(next).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Errorf("status = %d, want %d", rr.Code, http.StatusServiceUnavailable)
+		}
+		if rr.Header().Get("Retry-After") == "" {
+			t.Error("expected Retry-After header")
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+	})
+
+	t.Run("with starter", func(t *testing.T) {
+		started := make(chan string, 2)
+		release := make(chan struct{})
+		start := func(_ context.Context, sessionID string) error {
+			started <- sessionID
+			<-release
+			return nil
+		}
+		proxy := ServiceProxy(provider, start, ServiceProxyLimits{})(next)
+
+		for range 2 {
+			req := httptest.NewRequest("GET", "http://"+host+"/", nil)
+			req.Host = host
+			req.Header.Set("Accept", "text/html")
+			rr := httptest.NewRecorder()
+			proxy.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusServiceUnavailable {
+				t.Errorf("status = %d, want %d", rr.Code, http.StatusServiceUnavailable)
+			}
+			if !strings.Contains(rr.Body.String(), `http-equiv="refresh"`) {
+				t.Errorf("expected auto-refreshing page, got %q", rr.Body.String())
+			}
+		}
+
+		select {
+		case id := <-started:
+			if id != "abcdefghijklmnop" {
+				t.Errorf("started session %q", id)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected sandbox to be started")
+		}
+		close(release)
+		select {
+		case <-started:
+			t.Error("expected a single start while one is in progress")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+// TestServiceProxySandboxPaused verifies paused sandboxes are only resumed
+// when auto-start is enabled
+func TestServiceProxySandboxPaused(t *testing.T) {
+	const host = "abcdefghijklmnop-svc-web.localhost:3000"
+	provider := &mockSandboxProvider{
+		sandboxes: map[string]*sandbox.Sandbox{
+			"abcdefghijklmnop": {SessionID: "abcdefghijklmnop", Status: sandbox.StatusPaused},
+		},
+	}
+	next := http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		t.Error("next handler should not be called")
+	})
+
+	req := httptest.NewRequest("GET", "http://"+host+"/", nil)
+	req.Host = host
+	rr := httptest.NewRecorder()
+	ServiceProxy(provider, nil, ServiceProxyLimits{})(next).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+	if len(provider.unpaused) != 0 {
+		t.Errorf("unpaused %v without auto-start", provider.unpaused)
+	}
+}
+
+// TestConnLimiter verifies the per-session and global limits, queuing, and
+// that released sessions are forgotten
+func TestConnLimiter(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("per session", func(t *testing.T) {
+		l := newConnLimiter(ServiceProxyLimits{MaxConnsPerSession: 1})
+		release, ok := l.acquire(ctx, "a")
+		if !ok {
+			t.Fatal("expected first request for a to be allowed")
+		}
+		if _, ok := l.acquire(ctx, "a"); ok {
+			t.Error("expected second request for a to be rejected")
+		}
+		releaseB, ok := l.acquire(ctx, "b")
+		if !ok {
+			t.Error("expected request for b to be allowed")
+		}
+		releaseB()
+		release()
+		release() // Releasing twice frees a single slot
+		if _, ok := l.acquire(ctx, "a"); !ok {
+			t.Error("expected request for a to be allowed after release")
+		}
+	})
+
+	t.Run("global", func(t *testing.T) {
+		l := newConnLimiter(ServiceProxyLimits{MaxConnsPerSession: 2, MaxConns: 1})
+		release, _ := l.acquire(ctx, "a")
+		if _, ok := l.acquire(ctx, "b"); ok {
+			t.Error("expected request over the global limit to be rejected")
+		}
+		release()
+		if _, ok := l.acquire(ctx, "b"); !ok {
+			t.Error("expected request for b to be allowed after release")
+		}
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		l := newConnLimiter(ServiceProxyLimits{MaxConnsPerSession: 1, QueueTimeout: time.Second})
+		release, _ := l.acquire(ctx, "a")
+		time.AfterFunc(20*time.Millisecond, release)
+		start := time.Now()
+		if _, ok := l.acquire(ctx, "a"); !ok {
+			t.Error("expected queued request to get the released slot")
+		}
+		if time.Since(start) >= time.Second {
+			t.Error("expected queued request to proceed before the timeout")
+		}
+
+		l = newConnLimiter(ServiceProxyLimits{MaxConnsPerSession: 1, QueueTimeout: 20 * time.Millisecond})
+		l.acquire(ctx, "a")
+		if _, ok := l.acquire(ctx, "a"); ok {
+			t.Error("expected request to be rejected after the queue timeout")
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		l := newConnLimiter(ServiceProxyLimits{MaxConnsPerSession: 1})
+		release, _ := l.acquire(ctx, "a")
+		l.acquire(ctx, "a") // Rejected
+		release()
+		if len(l.sessions) != 0 {
+			t.Errorf("expected no tracked sessions, got %d", len(l.sessions))
+		}
+	})
+}
+
+// TestServiceProxyConnLimit verifies that a request held open by the backend
+// counts against the session's limit until it finishes
+func TestServiceProxyConnLimit(t *testing.T) {
+	const host = "abcdefghijklmnop-svc-web.localhost:3000"
+	hold := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("hold") != "" {
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-hold
+		}
+	}))
+	defer backend.Close()
+
+	provider := &mockSandboxProvider{
+		sandboxes: map[string]*sandbox.Sandbox{
+			"abcdefghijklmnop": {SessionID: "abcdefghijklmnop", Status: sandbox.StatusRunning},
+		},
+		client: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, backend.Listener.Addr().String())
+			},
+		}},
+	}
+	next := http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		t.Error("next handler should not be called")
+	})
+	proxy := httptest.NewServer(ServiceProxy(provider, nil, ServiceProxyLimits{MaxConnsPerSession: 1})(next))
+	defer proxy.Close()
+
+	get := func(query string) *http.Response {
+		req, _ := http.NewRequest("GET", proxy.URL+"/"+query, nil)
+		req.Host = host
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp
+	}
+
+	held := get("?hold=1")
+	if held.StatusCode != http.StatusOK {
+		t.Fatalf("held request status = %d, want %d", held.StatusCode, http.StatusOK)
+	}
+
+	resp := get("")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status while held = %d, want %d", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+
+	close(hold)
+	held.Body.Close()
+
+	// The slot is released once the proxied request returns
+	deadline := time.Now().Add(time.Second)
+	for {
+		resp := get("")
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status after release = %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestServiceProxyWebSocket verifies that WebSocket upgrades are tunneled to
+// the service and that refused upgrades are relayed
+func TestServiceProxyWebSocket(t *testing.T) {
+	const host = "abcdefghijklmnop-svc-web.localhost:3000"
+	upgrader := websocket.Upgrader{}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/services/web/http/ws" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("X-Forwarded-Host"); got != host {
+			t.Errorf("X-Forwarded-Host = %q, want %q", got, host)
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			msgType, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(msgType, msg); err != nil {
+				return
+			}
+		}
+	}))
+	defer backend.Close()
+
+	provider := &mockSandboxProvider{
+		sandboxes: map[string]*sandbox.Sandbox{
+			"abcdefghijklmnop": {SessionID: "abcdefghijklmnop", Status: sandbox.StatusRunning},
+		},
+		client: &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, backend.Listener.Addr().String())
+			},
+		}},
+	}
+	next := http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		t.Error("next handler should not be called")
+	})
+	proxy := httptest.NewServer(ServiceProxy(provider, nil, ServiceProxyLimits{})(next))
+	defer proxy.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(proxy.URL, "http")
+	header := http.Header{"Host": {host}}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"/ws", header)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	for _, msg := range []string{"hello", "world"} {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, got, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if string(got) != msg {
+			t.Errorf("echo = %q, want %q", got, msg)
+		}
+	}
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL+"/missing", header)
+	if err == nil {
+		t.Fatal("expected dial to an unknown path to fail")
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("refused upgrade response = %v, want status %d", resp, http.StatusNotFound)
+	}
+}
+
+// TestFindSandboxCaseInsensitive verifies case-insensitive session ID lookup
+func TestFindSandboxCaseInsensitive(t *testing.T) {
 	provider := &mockSandboxProvider{
 		sandboxes: map[string]*sandbox.Sandbox{
 			"AbCdEfGhIjKlMnOp": {SessionID: "AbCdEfGhIjKlMnOp"},
@@ -312,7 +652,7 @@ func TestFindSessionIDCaseInsensitive(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := findSessionID(ctx, provider, tt.urlID)
+			got, err := findSandbox(ctx, provider, tt.urlID)
 
 			if tt.wantError {
 				if err == nil {
@@ -326,8 +666,8 @@ func TestFindSessionIDCaseInsensitive(t *testing.T) {
 				return
 			}
 
-			if got != tt.wantID {
-				t.Errorf("findSessionID() = %q, want %q", got, tt.wantID)
+			if got.SessionID != tt.wantID {
+				t.Errorf("findSandbox() = %q, want %q", got.SessionID, tt.wantID)
 			}
 		})
 	}
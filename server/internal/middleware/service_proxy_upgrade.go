@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// errDialNotSupported is returned by dialSandbox when neither the provider
+// nor its HTTP transport can open a raw connection to the sandbox.
+var errDialNotSupported = errors.New("sandbox connection cannot be dialed")
+
+// isUpgradeRequest reports whether r asks to switch protocols, as a
+// WebSocket handshake does: an Upgrade header and "upgrade" among the
+// Connection header's tokens.
+func isUpgradeRequest(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for token := range strings.SplitSeq(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// dialSandbox opens a raw connection to the session's agent-api, through the
+// same transport the sandbox's HTTP client uses when it can dial, otherwise
+// through the provider's PortDialer. Returns errDialNotSupported if neither
+// can.
+func dialSandbox(ctx context.Context, provider sandbox.Provider, sessionID string, transport http.RoundTripper) (net.Conn, error) {
+	if t, ok := transport.(*http.Transport); ok && t.DialContext != nil {
+		return t.DialContext(ctx, "tcp", "sandbox:80")
+	}
+	if dialer, ok := provider.(sandbox.PortDialer); ok {
+		conn, err := dialer.Dial(ctx, sessionID, sandbox.AgentAPIPort)
+		if !errors.Is(err, sandbox.ErrNotSupported) {
+			return conn, err
+		}
+	}
+	return nil, errDialNotSupported
+}
+
+// proxyUpgrade sends an upgrade request over backend, a connection to the
+// agent-api. If the agent-api switches protocols, the client connection is
+// hijacked and bytes are copied both ways until either side closes; any
+// other response is relayed as is. backend is closed when it returns. An
+// error means nothing was written to w.
+func proxyUpgrade(w http.ResponseWriter, req *http.Request, backend net.Conn) error {
+	defer backend.Close()
+
+	if err := req.Write(backend); err != nil {
+		return fmt.Errorf("failed to send upgrade request: %w", err)
+	}
+	backendBuf := bufio.NewReader(backend)
+	resp, err := http.ReadResponse(backendBuf, req)
+	if err != nil {
+		return fmt.Errorf("failed to read upgrade response: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Refused, e.g. the service isn't running or doesn't speak WebSocket
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		for k, values := range resp.Header {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+		return nil
+	}
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), req.Header.Get("Upgrade")) {
+		return fmt.Errorf("backend switched to protocol %q, requested %q", resp.Header.Get("Upgrade"), req.Header.Get("Upgrade"))
+	}
+
+	client, clientBuf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return fmt.Errorf("failed to hijack client connection: %w", err)
+	}
+	defer client.Close()
+
+	fmt.Fprintf(clientBuf, "HTTP/1.1 %s\r\n", resp.Status)
+	_ = resp.Header.Write(clientBuf)
+	_, _ = clientBuf.WriteString("\r\n")
+	if err := clientBuf.Flush(); err != nil {
+		log.Printf("[ServiceProxy] Failed to write upgrade response to %s: %v", req.URL.Path, err)
+		return nil
+	}
+
+	// Either side closing ends the tunnel; closing both unblocks the other copy.
+	// The buffered readers may already hold bytes sent right after the handshake.
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(backend, clientBuf.Reader)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(client, backendBuf)
+		done <- struct{}{}
+	}()
+	<-done
+	client.Close()
+	backend.Close()
+	<-done
+	return nil
+}
+
+// serveUpgrade proxies an upgrade request to the session's service over a
+// raw connection. It returns false without writing a response if the
+// sandbox can't be dialed, so the caller can fall back to the reverse proxy.
+func serveUpgrade(w http.ResponseWriter, r *http.Request, provider sandbox.Provider, client *http.Client, sessionID, serviceID string) bool {
+	backend, err := dialSandbox(r.Context(), provider, sessionID, client.Transport)
+	if errors.Is(err, errDialNotSupported) {
+		return false
+	}
+	if err != nil {
+		log.Printf("[ServiceProxy] Failed to connect to sandbox for upgrade to %s: %v", r.URL.Path, err)
+		writeJSONError(w, http.StatusBadGateway, "Service unavailable", map[string]string{
+			"sessionId": sessionID,
+			"serviceId": serviceID,
+			"message":   err.Error(),
+		})
+		return true
+	}
+
+	req := r.Clone(r.Context())
+	req.RequestURI = ""
+	rewriteServiceRequest(req, r, serviceID)
+	// Only the upgrade survives as a hop-by-hop header
+	req.Header.Set("Connection", "Upgrade")
+
+	if err := proxyUpgrade(w, req, backend); err != nil {
+		log.Printf("[ServiceProxy] Error proxying upgrade to %s: %v", req.URL.Path, err)
+		writeJSONError(w, http.StatusBadGateway, "Service unavailable", map[string]string{
+			"sessionId": sessionID,
+			"serviceId": serviceID,
+			"message":   err.Error(),
+		})
+	}
+	return true
+}
@@ -40,6 +40,10 @@ type Job struct {
 	// Example: resource_type="session", resource_id="abc123"
 	ResourceType *string `gorm:"column:resource_type;type:text;index:idx_job_resource" json:"resource_type,omitempty"`
 	ResourceID   *string `gorm:"column:resource_id;type:text;index:idx_job_resource" json:"resource_id,omitempty"`
+
+	// ProjectID is the project the job belongs to, if any. Jobs of frozen
+	// projects stay pending until the project is unfrozen.
+	ProjectID *string `gorm:"column:project_id;type:text;index" json:"project_id,omitempty"`
 }
 
 // TableName returns the table name for Job.
@@ -59,6 +59,22 @@ type Project struct {
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 
+	// SandboxLabels are extra labels applied to sandboxes in this project.
+	// They override SANDBOX_EXTRA_LABELS; reserved discobot.* keys are ignored.
+	SandboxLabels map[string]string `gorm:"column:sandbox_labels;type:text;serializer:json" json:"sandbox_labels,omitempty"`
+
+	// Frozen pauses the project for maintenance: its queued jobs are not
+	// dispatched and mutating API requests are rejected until unfrozen.
+	Frozen bool `gorm:"not null;default:false" json:"frozen"`
+
+	// SkipGitHooks disables the workspace's git hooks when session commits
+	// are applied to it, for hooks that break or can't run on the server.
+	SkipGitHooks bool `gorm:"column:skip_git_hooks;not null;default:false" json:"skip_git_hooks"`
+
+	// SandboxImage is the image this project's sandboxes are created from,
+	// e.g. one with a CUDA toolchain. Empty uses SANDBOX_IMAGE.
+	SandboxImage string `gorm:"column:sandbox_image;type:text;default:''" json:"sandbox_image,omitempty"`
+
 	Members    []ProjectMember `gorm:"foreignKey:ProjectID" json:"-"`
 	Workspaces []Workspace     `gorm:"foreignKey:ProjectID" json:"-"`
 	Agents     []Agent         `gorm:"foreignKey:ProjectID" json:"-"`
@@ -154,21 +170,35 @@ const (
 const (
 	WorkspaceProviderVZ     = "vz"     // Run in Virtualization.framework VMs (macOS only)
 	WorkspaceProviderDocker = "docker" // Run in Docker containers
+	WorkspaceProviderPodman = "podman" // Run in rootless Podman containers (PODMAN_ENABLED)
 	WorkspaceProviderLocal  = "local"  // Run in local directory without isolation
 )
 
 // Workspace represents a working directory (local folder or git repo).
 type Workspace struct {
-	ID           string    `gorm:"primaryKey;type:text" json:"id"`
-	ProjectID    string    `gorm:"column:project_id;not null;type:text;index" json:"projectId"`
-	Path         string    `gorm:"not null;type:text" json:"path"`
-	DisplayName  *string   `gorm:"column:display_name;type:text" json:"displayName,omitempty"`
-	SourceType   string    `gorm:"column:source_type;not null;type:text" json:"sourceType"`
-	Provider     string    `gorm:"type:text;default:''" json:"provider,omitempty"`
-	Status       string    `gorm:"not null;type:text;default:initializing" json:"status"`
-	ErrorMessage *string   `gorm:"column:error_message;type:text" json:"errorMessage,omitempty"`
-	CreatedAt    time.Time `gorm:"autoCreateTime" json:"createdAt"`
-	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+	ID                string    `gorm:"primaryKey;type:text" json:"id"`
+	ProjectID         string    `gorm:"column:project_id;not null;type:text;index" json:"projectId"`
+	Path              string    `gorm:"not null;type:text" json:"path"`
+	DisplayName       *string   `gorm:"column:display_name;type:text" json:"displayName,omitempty"`
+	SourceType        string    `gorm:"column:source_type;not null;type:text" json:"sourceType"`
+	Provider          string    `gorm:"type:text;default:''" json:"provider,omitempty"`
+	NetworkMode       string    `gorm:"column:network_mode;type:text;default:''" json:"networkMode,omitempty"`       // proxied (default), isolated, or open
+	RestartPolicy     string    `gorm:"column:restart_policy;type:text;default:''" json:"restartPolicy,omitempty"`   // empty uses SANDBOX_RESTART_POLICY
+	StopSignal        string    `gorm:"column:stop_signal;type:text;default:''" json:"stopSignal,omitempty"`         // empty uses SANDBOX_STOP_SIGNAL
+	CPUCores          float64   `gorm:"column:cpu_cores;default:0" json:"cpuCores,omitempty"`                        // 0 uses SANDBOX_CPU_LIMIT
+	MemoryMB          int       `gorm:"column:memory_mb;default:0" json:"memoryMB,omitempty"`                        // 0 uses SANDBOX_MEMORY_LIMIT_MB
+	DiskMB            int       `gorm:"column:disk_mb;default:0" json:"diskMB,omitempty"`                            // 0 means no disk limit
+	ExtraPorts        []int     `gorm:"column:extra_ports;type:text;serializer:json" json:"extraPorts,omitempty"`    // Sandbox ports exposed besides the agent API
+	DisableProxyCache bool      `gorm:"column:disable_proxy_cache;default:false" json:"disableProxyCache,omitempty"` // Proxy filters but doesn't cache responses
+	CloneDepth        int       `gorm:"column:clone_depth;default:0" json:"cloneDepth,omitempty"`                    // Commits of history cloned into sandboxes, 0 for all
+	CloneAllBranches  bool      `gorm:"column:clone_all_branches;default:false" json:"cloneAllBranches,omitempty"`   // Clone every branch, not just the current one
+	CloneSubmodules   bool      `gorm:"column:clone_submodules;default:false" json:"cloneSubmodules,omitempty"`      // Clone and initialize git submodules
+	Filesystem        string    `gorm:"column:filesystem;type:text" json:"filesystem,omitempty"`                     // "overlayfs" or "agentfs"; empty lets the agent detect it
+	DefaultAgentID    *string   `gorm:"column:default_agent_id;type:text" json:"defaultAgentId,omitempty"`           // Agent for sessions without one; nil uses the project default
+	Status            string    `gorm:"not null;type:text;default:initializing" json:"status"`
+	ErrorMessage      *string   `gorm:"column:error_message;type:text" json:"errorMessage,omitempty"`
+	CreatedAt         time.Time `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt         time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
 
 	Project  *Project  `gorm:"foreignKey:ProjectID" json:"-"`
 	Sessions []Session `gorm:"foreignKey:WorkspaceID" json:"-"`
@@ -193,6 +223,7 @@ const (
 	SessionStatusReady           = "ready"            // Session is ready for use
 	SessionStatusRunning         = "running"          // Session has an active chat completion in progress
 	SessionStatusStopped         = "stopped"          // Sandbox is stopped, will restart on demand
+	SessionStatusPaused          = "paused"           // Sandbox is paused, will resume on demand
 	SessionStatusError           = "error"            // Something failed during setup
 	SessionStatusRemoving        = "removing"         // Session is being deleted
 	SessionStatusRemoved         = "removed"          // Session has been deleted
@@ -211,12 +242,12 @@ const (
 type Session struct {
 	ID              string    `gorm:"primaryKey;type:text" json:"id"`
 	ProjectID       string    `gorm:"column:project_id;not null;type:text;index" json:"projectId"`
-	WorkspaceID     string    `gorm:"column:workspace_id;not null;type:text;index" json:"workspaceId"`
+	WorkspaceID     string    `gorm:"column:workspace_id;not null;type:text;index;index:idx_session_workspace_status,priority:1" json:"workspaceId"`
 	AgentID         *string   `gorm:"column:agent_id;type:text;index" json:"agentId,omitempty"`
 	Name            string    `gorm:"not null;type:text" json:"name"`
 	DisplayName     *string   `gorm:"column:display_name;type:text" json:"displayName,omitempty"`
 	Description     *string   `gorm:"type:text" json:"description,omitempty"`
-	Status          string    `gorm:"not null;type:text;default:initializing" json:"status"`
+	Status          string    `gorm:"not null;type:text;default:initializing;index:idx_session_workspace_status,priority:2" json:"status"`
 	CommitStatus    string    `gorm:"column:commit_status;type:text;default:''" json:"commitStatus"`
 	CommitError     *string   `gorm:"column:commit_error;type:text" json:"commitError,omitempty"`
 	BaseCommit      *string   `gorm:"column:base_commit;type:text" json:"baseCommit,omitempty"`
@@ -229,6 +260,22 @@ type Session struct {
 	CreatedAt       time.Time `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
 
+	// HistoryTrimmedThrough is the ID of the newest chat message that was last
+	// dropped or summarized by the chat history policy instead of being sent
+	// verbatim; HistoryTrimmedCount is how many messages that covered.
+	HistoryTrimmedThrough *string `gorm:"column:history_trimmed_through;type:text" json:"historyTrimmedThrough,omitempty"`
+	HistoryTrimmedCount   int     `gorm:"column:history_trimmed_count;not null;default:0" json:"historyTrimmedCount,omitempty"`
+
+	// ForkedFrom is the session this one was forked from. SnapshotID is the
+	// sandbox snapshot of that session to create this session's first
+	// sandbox from; it is cleared once the sandbox exists.
+	ForkedFrom *string `gorm:"column:forked_from;type:text" json:"forkedFrom,omitempty"`
+	SnapshotID *string `gorm:"column:snapshot_id;type:text" json:"-"`
+	// SnapshotImage is the snapshot image a forked session's sandbox was
+	// created from. Reconciliation treats it as the sandbox's expected image,
+	// so the forked container filesystem isn't replaced.
+	SnapshotImage *string `gorm:"column:snapshot_image;type:text" json:"-"`
+
 	Project   *Project   `gorm:"foreignKey:ProjectID" json:"-"`
 	Workspace *Workspace `gorm:"foreignKey:WorkspaceID" json:"-"`
 	Agent     *Agent     `gorm:"foreignKey:AgentID" json:"-"`
@@ -280,15 +327,16 @@ func NewTextParts(text string) json.RawMessage {
 
 // Credential represents stored credentials for AI providers.
 type Credential struct {
-	ID            string    `gorm:"primaryKey;type:text" json:"id"`
-	ProjectID     string    `gorm:"column:project_id;not null;type:text;uniqueIndex:idx_project_provider" json:"project_id"`
-	Provider      string    `gorm:"not null;type:text;uniqueIndex:idx_project_provider" json:"provider"`
-	Name          string    `gorm:"not null;type:text" json:"name"`
-	AuthType      string    `gorm:"column:auth_type;not null;type:text" json:"auth_type"`
-	EncryptedData []byte    `gorm:"column:encrypted_data" json:"-"`
-	IsConfigured  bool      `gorm:"column:is_configured;default:false" json:"is_configured"`
-	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID            string     `gorm:"primaryKey;type:text" json:"id"`
+	ProjectID     string     `gorm:"column:project_id;not null;type:text;uniqueIndex:idx_project_provider" json:"project_id"`
+	Provider      string     `gorm:"not null;type:text;uniqueIndex:idx_project_provider" json:"provider"`
+	Name          string     `gorm:"not null;type:text" json:"name"`
+	AuthType      string     `gorm:"column:auth_type;not null;type:text" json:"auth_type"`
+	EncryptedData []byte     `gorm:"column:encrypted_data" json:"-"`
+	IsConfigured  bool       `gorm:"column:is_configured;default:false" json:"is_configured"`
+	LastUsedAt    *time.Time `gorm:"column:last_used_at" json:"last_used_at,omitempty"` // Last time the credential was passed to a sandbox
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 
 	Project *Project `gorm:"foreignKey:ProjectID" json:"-"`
 }
@@ -323,6 +371,35 @@ func (t *TerminalHistory) BeforeCreate(_ *gorm.DB) error {
 	return nil
 }
 
+// Session transition kinds
+const (
+	TransitionKindStatus       = "status"
+	TransitionKindCommitStatus = "commit_status"
+)
+
+// SessionTransition records a change to a session's status or commit status.
+// Only the most recent transitions per session are kept.
+type SessionTransition struct {
+	ID        string    `gorm:"primaryKey;type:text" json:"id"`
+	SessionID string    `gorm:"column:session_id;not null;type:text;index" json:"sessionId"`
+	Kind      string    `gorm:"not null;type:text" json:"kind"` // status or commit_status
+	From      string    `gorm:"column:from_status;not null;type:text;default:''" json:"from"`
+	To        string    `gorm:"column:to_status;not null;type:text" json:"to"`
+	Reason    string    `gorm:"type:text;default:''" json:"reason,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
+
+	Session *Session `gorm:"foreignKey:SessionID" json:"-"`
+}
+
+func (SessionTransition) TableName() string { return "session_transitions" }
+
+func (t *SessionTransition) BeforeCreate(_ *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
 // Event type constants
 const (
 	EventTypeSessionUpdated = "session_updated"
@@ -385,6 +462,7 @@ func AllModels() []interface{} {
 		&Message{},
 		&Credential{},
 		&TerminalHistory{},
+		&SessionTransition{},
 		&ProjectEvent{},
 		&Job{},
 		&DispatcherLeader{},
@@ -49,6 +49,7 @@ type DevicePollResponse struct {
 	Scope        string    `json:"scope,omitempty"`
 	Error        string    `json:"error,omitempty"`
 	ErrorDesc    string    `json:"error_description,omitempty"`
+	ExpiresIn    int       `json:"expires_in,omitempty"`
 	ExpiresAt    time.Time `json:"expires_at,omitempty"`
 	RefreshToken string    `json:"refresh_token,omitempty"`
 }
@@ -134,6 +135,11 @@ func (p *GitHubCopilotProvider) PollForToken(ctx context.Context, deviceCode str
 		return nil, fmt.Errorf("failed to parse poll response: %w", err)
 	}
 
+	// Tokens only expire when the GitHub App has token expiration enabled
+	if pollResp.ExpiresIn > 0 {
+		pollResp.ExpiresAt = time.Now().Add(time.Duration(pollResp.ExpiresIn) * time.Second)
+	}
+
 	return &pollResp, nil
 }
 
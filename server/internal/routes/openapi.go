@@ -0,0 +1,198 @@
+package routes
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// OpenAPIVersion is the OpenAPI specification version of generated documents.
+const OpenAPIVersion = "3.0.3"
+
+// OpenAPIDocument is an OpenAPI 3 document generated from route metadata.
+type OpenAPIDocument struct {
+	OpenAPI string                                  `json:"openapi"`
+	Info    OpenAPIInfo                             `json:"info"`
+	Tags    []OpenAPITag                            `json:"tags,omitempty"`
+	Paths   map[string]map[string]*OpenAPIOperation `json:"paths"`
+}
+
+// OpenAPIInfo is the document's info object.
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// OpenAPITag groups operations (one tag per route group).
+type OpenAPITag struct {
+	Name string `json:"name"`
+}
+
+// OpenAPIOperation describes a single method on a path.
+type OpenAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary,omitempty"`
+	Tags        []string                   `json:"tags,omitempty"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter describes a path or query parameter.
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   map[string]any `json:"schema"`
+	Example  string         `json:"example,omitempty"`
+}
+
+// OpenAPIRequestBody describes a JSON request body.
+type OpenAPIRequestBody struct {
+	Content map[string]OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIMediaType holds the schema and example for a content type.
+type OpenAPIMediaType struct {
+	Schema  map[string]any `json:"schema,omitempty"`
+	Example any            `json:"example,omitempty"`
+}
+
+// OpenAPIResponse describes a response.
+type OpenAPIResponse struct {
+	Description string `json:"description"`
+}
+
+// chiRegexParam matches chi path parameters with a regexp, like {id:[0-9]+}.
+var chiRegexParam = regexp.MustCompile(`\{([^}:]+):[^}]*\}`)
+
+// operationIDInvalid matches characters not allowed in generated operation IDs.
+var operationIDInvalid = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// OpenAPI builds an OpenAPI 3 document from registered route metadata. Route
+// groups become tags, params keep their path/query location, and example
+// bodies become request body examples with a schema inferred from them.
+func OpenAPI(routes []RouteInfo, title, version string) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info:    OpenAPIInfo{Title: title, Version: version},
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+	}
+
+	groups := make(map[string]bool)
+	for _, route := range routes {
+		path := openAPIPath(route.Path)
+		method := strings.ToLower(route.Method)
+
+		op := &OpenAPIOperation{
+			OperationID: operationID(route.Method, path),
+			Summary:     route.Description,
+			Responses:   map[string]OpenAPIResponse{"default": {Description: "Response"}},
+		}
+		if route.Group != "" {
+			op.Tags = []string{route.Group}
+			groups[route.Group] = true
+		}
+		for _, p := range route.Params {
+			in := p.In
+			if in == "" {
+				in = "path"
+			}
+			op.Parameters = append(op.Parameters, OpenAPIParameter{
+				Name:     p.Name,
+				In:       in,
+				Required: p.Required || in == "path",
+				Schema:   map[string]any{"type": "string"},
+				Example:  p.Example,
+			})
+		}
+		if strings.HasSuffix(route.Path, "/*") && !slices.ContainsFunc(op.Parameters, func(p OpenAPIParameter) bool {
+			return p.Name == wildcardParam && p.In == "path"
+		}) {
+			// The rest of the path matched by the wildcard
+			op.Parameters = append(op.Parameters, OpenAPIParameter{
+				Name:     wildcardParam,
+				In:       "path",
+				Required: true,
+				Schema:   map[string]any{"type": "string"},
+			})
+		}
+		if route.Body != nil {
+			op.RequestBody = &OpenAPIRequestBody{
+				Content: map[string]OpenAPIMediaType{
+					"application/json": {Schema: schemaFromExample(route.Body), Example: route.Body},
+				},
+			}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*OpenAPIOperation)
+		}
+		doc.Paths[path][method] = op
+	}
+
+	for name := range groups {
+		doc.Tags = append(doc.Tags, OpenAPITag{Name: name})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+
+	return doc
+}
+
+// wildcardParam is the path parameter a trailing chi wildcard becomes.
+const wildcardParam = "path"
+
+// openAPIPath converts a chi pattern to an OpenAPI path template.
+func openAPIPath(pattern string) string {
+	path := chiRegexParam.ReplaceAllString(pattern, "{$1}")
+	if strings.HasSuffix(path, "/*") {
+		path = strings.TrimSuffix(path, "*") + "{" + wildcardParam + "}"
+	}
+	return path
+}
+
+// operationID derives a stable operation ID such as "get_api_projects_projectId".
+func operationID(method, path string) string {
+	id := operationIDInvalid.ReplaceAllString(path, "_")
+	return strings.ToLower(method) + "_" + strings.Trim(id, "_")
+}
+
+// schemaFromExample infers a JSON schema from an example value.
+func schemaFromExample(example any) map[string]any {
+	data, err := json.Marshal(example)
+	if err != nil {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil
+	}
+	return inferSchema(v)
+}
+
+func inferSchema(v any) map[string]any {
+	switch v := v.(type) {
+	case map[string]any:
+		props := make(map[string]any, len(v))
+		for k, val := range v {
+			props[k] = inferSchema(val)
+		}
+		return map[string]any{"type": "object", "properties": props}
+	case []any:
+		schema := map[string]any{"type": "array"}
+		if len(v) > 0 {
+			schema["items"] = inferSchema(v[0])
+		}
+		return schema
+	case string:
+		return map[string]any{"type": "string"}
+	case float64:
+		return map[string]any{"type": "number"}
+	case bool:
+		return map[string]any{"type": "boolean"}
+	default:
+		return map[string]any{}
+	}
+}
@@ -0,0 +1,102 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestOpenAPI(t *testing.T) {
+	reg := NewRegistry()
+	r := chi.NewRouter()
+	noop := func(http.ResponseWriter, *http.Request) {}
+
+	projReg := reg.WithPrefix("/api/projects/{projectId}")
+	projReg.Register(r, Route{
+		Method: "GET", Pattern: "/sessions/{sessionId}/files",
+		Handler: noop,
+		Meta: Meta{
+			Group:       "Files",
+			Description: "List files",
+			Params:      []Param{{Name: "sessionId", Example: "abc123"}, {Name: "path", In: "query", Example: "."}},
+		},
+	})
+	projReg.Register(r, Route{
+		Method: "POST", Pattern: "/chat",
+		Handler: noop,
+		Meta: Meta{
+			Group:       "Chat",
+			Description: "AI Chat",
+			Body:        map[string]any{"messages": []map[string]any{{"role": "user"}}, "stream": true},
+		},
+	})
+
+	projReg.Register(r, Route{
+		Method: "GET", Pattern: "/static/*",
+		Handler: noop,
+		Meta:    Meta{Group: "Files", Description: "Static files"},
+	})
+
+	doc := OpenAPI(reg.Routes(), "Test API", "1.0")
+
+	if doc.OpenAPI != OpenAPIVersion || doc.Info.Title != "Test API" || doc.Info.Version != "1.0" {
+		t.Errorf("Unexpected document header: %+v", doc)
+	}
+	if len(doc.Tags) != 2 || doc.Tags[0].Name != "Chat" || doc.Tags[1].Name != "Files" {
+		t.Errorf("Expected sorted Chat, Files tags, got %+v", doc.Tags)
+	}
+
+	files := doc.Paths["/api/projects/{projectId}/sessions/{sessionId}/files"]["get"]
+	if files == nil {
+		t.Fatalf("Missing files operation, paths: %v", doc.Paths)
+	}
+	if files.OperationID != "get_api_projects_projectId_sessions_sessionId_files" {
+		t.Errorf("Unexpected operation ID %q", files.OperationID)
+	}
+	if len(files.Parameters) != 3 {
+		t.Fatalf("Expected 3 parameters, got %+v", files.Parameters)
+	}
+	if p := files.Parameters[1]; p.Name != "sessionId" || p.In != "path" || !p.Required || p.Example != "abc123" {
+		t.Errorf("Unexpected path parameter %+v", p)
+	}
+	if p := files.Parameters[2]; p.Name != "path" || p.In != "query" || p.Required {
+		t.Errorf("Unexpected query parameter %+v", p)
+	}
+
+	// The wildcard's path parameter is declared
+	static := doc.Paths["/api/projects/{projectId}/static/{path}"]["get"]
+	if static == nil {
+		t.Fatalf("Missing static operation, paths: %v", doc.Paths)
+	}
+	if p := static.Parameters[len(static.Parameters)-1]; p.Name != "path" || p.In != "path" || !p.Required {
+		t.Errorf("Unexpected wildcard parameter %+v", p)
+	}
+
+	chat := doc.Paths["/api/projects/{projectId}/chat"]["post"]
+	if chat == nil || chat.RequestBody == nil {
+		t.Fatalf("Missing chat request body: %+v", chat)
+	}
+	schema := chat.RequestBody.Content["application/json"].Schema
+	props, _ := schema["properties"].(map[string]any)
+	if schema["type"] != "object" || props["stream"].(map[string]any)["type"] != "boolean" {
+		t.Errorf("Unexpected inferred schema %v", schema)
+	}
+	messages, _ := props["messages"].(map[string]any)
+	if messages["type"] != "array" || messages["items"].(map[string]any)["type"] != "object" {
+		t.Errorf("Unexpected array schema %v", messages)
+	}
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	tests := map[string]string{
+		"/api/projects/{projectId}": "/api/projects/{projectId}",
+		"/api/items/{id:[0-9]+}":    "/api/items/{id}",
+		"/static/*":                 "/static/{path}",
+	}
+	for in, want := range tests {
+		if got := openAPIPath(in); got != want {
+			t.Errorf("openAPIPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package docker
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	containerTypes "github.com/docker/docker/api/types/container"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// transferDir is where CopyTo and CopyFrom stage archives. Docker's archive
+// API only sees the container's image layers and volumes, not the overlay
+// the agent mounts over the home directory, so files pass through a
+// directory on the data volume and are moved to or from the workspace by a
+// command run inside the container.
+const transferDir = dataVolumePath + "/transfers"
+
+// newTransferScript creates the staging directory $1, owned by the owner of
+// directory $2, and prints that owner as uid:gid.
+const newTransferScript = `set -e
+owner=$(stat -c %u:%g "$2")
+mkdir -p "$1"
+chown "$owner" "$1"
+echo "$owner"`
+
+// copyInScript copies the contents of $1 into directory $2, creating it.
+const copyInScript = `set -e
+mkdir -p "$2"
+cp -R -p "$1"/. "$2"/`
+
+// copyOutScript copies $1 into directory $2, exiting 3 if it doesn't exist.
+const copyOutScript = `[ -e "$1" ] || [ -L "$1" ] || exit 3
+cp -a "$1" "$2"/`
+
+// CopyTo extracts a tar archive into destPath in the session's container.
+// The files end up owned by the workspace's owner, and are written as that
+// user so symlinks in the workspace can't redirect them anywhere the sandbox
+// itself couldn't write. Implements sandbox.FileCopier.
+func (p *Provider) CopyTo(ctx context.Context, sessionID, destPath string, archive io.Reader) error {
+	containerID, err := p.getContainerID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	staging, owner, err := p.newTransfer(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	defer p.removeTransfer(sessionID, staging)
+
+	if err := p.client.CopyToContainer(ctx, containerID, staging, archive, containerTypes.CopyToContainerOptions{}); err != nil {
+		if cerrdefs.IsNotFound(err) {
+			p.clearContainerID(sessionID)
+			return sandbox.ErrNotFound
+		}
+		return fmt.Errorf("failed to copy files into sandbox: %w", err)
+	}
+
+	if _, err := p.transferExec(ctx, sessionID, "root", "chown -R \"$1\" \"$2\"", owner, staging); err != nil {
+		return err
+	}
+	if _, err := p.transferExec(ctx, sessionID, owner, copyInScript, staging, destPath); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CopyFrom returns a tar archive of srcPath in the session's container. The
+// path is first copied to the data volume as the workspace's owner, so it
+// can't reach files the sandbox itself couldn't read. Implements
+// sandbox.FileCopier.
+func (p *Provider) CopyFrom(ctx context.Context, sessionID, srcPath string) (io.ReadCloser, error) {
+	containerID, err := p.getContainerID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	staging, owner, err := p.newTransfer(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	keep := false
+	defer func() {
+		if !keep {
+			p.removeTransfer(sessionID, staging)
+		}
+	}()
+
+	result, err := p.Exec(ctx, sessionID, []string{"sh", "-c", copyOutScript, "sh", srcPath, staging}, sandbox.ExecOptions{User: owner})
+	if err != nil {
+		return nil, err
+	}
+	switch result.ExitCode {
+	case 0:
+	case 3:
+		return nil, fmt.Errorf("%w: %s", fs.ErrNotExist, srcPath)
+	default:
+		return nil, fmt.Errorf("failed to copy files out of sandbox: %s", strings.TrimSpace(string(result.Stderr)))
+	}
+
+	reader, _, err := p.client.CopyFromContainer(ctx, containerID, path.Join(staging, path.Base(srcPath)))
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			p.clearContainerID(sessionID)
+			return nil, sandbox.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to copy files out of sandbox: %w", err)
+	}
+
+	keep = true
+	return &transferReader{ReadCloser: reader, cleanup: func() { p.removeTransfer(sessionID, staging) }}, nil
+}
+
+// transferReader is a CopyFrom archive. Closing it removes its staging
+// directory.
+type transferReader struct {
+	io.ReadCloser
+	cleanup func()
+}
+
+func (t *transferReader) Close() error {
+	err := t.ReadCloser.Close()
+	t.cleanup()
+	return err
+}
+
+// newTransfer creates a staging directory under transferDir, owned by the
+// workspace's owner, and returns it with that owner (uid:gid).
+func (p *Provider) newTransfer(ctx context.Context, sessionID string) (dir, owner string, err error) {
+	dir = path.Join(transferDir, strings.ToLower(rand.Text()))
+	out, err := p.transferExec(ctx, sessionID, "root", newTransferScript, dir, sandbox.WorkspaceDir)
+	if err != nil {
+		return "", "", err
+	}
+	return dir, strings.TrimSpace(out), nil
+}
+
+// removeTransfer deletes a staging directory. It runs even if the request
+// that created it was canceled.
+func (p *Provider) removeTransfer(sessionID, dir string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := p.transferExec(ctx, sessionID, "root", "rm -rf \"$1\"", dir); err != nil {
+		log.Printf("Warning: failed to remove file transfer directory %s of sandbox %s: %v", dir, sessionID, err)
+	}
+}
+
+// transferExec runs a shell script in the session's container as user,
+// returning its stdout, or an error with its stderr if it fails.
+func (p *Provider) transferExec(ctx context.Context, sessionID, user, script string, args ...string) (string, error) {
+	cmd := append([]string{"sh", "-c", script, "sh"}, args...)
+	result, err := p.Exec(ctx, sessionID, cmd, sandbox.ExecOptions{User: user})
+	if err != nil {
+		return "", err
+	}
+	if result.ExitCode != 0 {
+		return "", fmt.Errorf("%w: %s", sandbox.ErrExecFailed, strings.TrimSpace(string(result.Stderr)))
+	}
+	return string(result.Stdout), nil
+}
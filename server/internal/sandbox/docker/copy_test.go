@@ -0,0 +1,48 @@
+package docker
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyScripts(t *testing.T) {
+	dir := t.TempDir()
+	staging := filepath.Join(dir, "staging")
+	workspace := filepath.Join(dir, "workspace")
+	if err := os.MkdirAll(filepath.Join(staging, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(staging, "sub/a.txt"), []byte("alpha"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Copying in creates the destination and keeps file modes
+	dest := filepath.Join(workspace, "new/dir")
+	if out, err := exec.Command("sh", "-c", copyInScript, "sh", staging, dest).CombinedOutput(); err != nil {
+		t.Fatalf("copy in failed: %v: %s", err, out)
+	}
+	info, err := os.Stat(filepath.Join(dest, "sub/a.txt"))
+	if err != nil {
+		t.Fatalf("expected the file to be copied in: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// Copying out keeps the source's base name
+	out := t.TempDir()
+	if b, err := exec.Command("sh", "-c", copyOutScript, "sh", filepath.Join(workspace, "new"), out).CombinedOutput(); err != nil {
+		t.Fatalf("copy out failed: %v: %s", err, b)
+	}
+	if _, err := os.Stat(filepath.Join(out, "new/dir/sub/a.txt")); err != nil {
+		t.Errorf("expected the directory to be copied out: %v", err)
+	}
+
+	// A missing source exits 3 so CopyFrom can report it as not found
+	err = exec.Command("sh", "-c", copyOutScript, "sh", filepath.Join(workspace, "missing"), out).Run()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 3 {
+		t.Errorf("expected exit code 3 for a missing source, got %v", err)
+	}
+}
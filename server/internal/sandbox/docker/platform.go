@@ -0,0 +1,100 @@
+package docker
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	imageTypes "github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// resolvePlatform returns the platform sandbox images are pulled and run for:
+// the configured platform if set, otherwise the daemon's OS and architecture.
+// Returns nil if the daemon can't be queried, leaving the choice to Docker.
+func resolvePlatform(ctx context.Context, cli *client.Client, configured string) *ocispec.Platform {
+	if configured != "" {
+		return parsePlatform(configured)
+	}
+	version, err := cli.ServerVersion(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to get Docker daemon version, not pinning sandbox platform: %v", err)
+		return nil
+	}
+	if version.Os == "" || version.Arch == "" {
+		return nil
+	}
+	return &ocispec.Platform{OS: version.Os, Architecture: version.Arch}
+}
+
+// parsePlatform parses an os/arch[/variant] platform string. The format is
+// validated when the config is loaded.
+func parsePlatform(s string) *ocispec.Platform {
+	parts := strings.SplitN(s, "/", 3)
+	if len(parts) < 2 {
+		return nil
+	}
+	platform := &ocispec.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		platform.Variant = parts[2]
+	}
+	return platform
+}
+
+// formatPlatform formats a platform as os/arch[/variant], or "" for nil.
+func formatPlatform(platform *ocispec.Platform) string {
+	if platform == nil {
+		return ""
+	}
+	s := platform.OS + "/" + platform.Architecture
+	if platform.Variant != "" {
+		s += "/" + platform.Variant
+	}
+	return s
+}
+
+// imageMatchesPlatform reports whether a local image was built for platform.
+// Any image matches a nil platform, and the variant is only compared when
+// both sides specify one.
+func imageMatchesPlatform(info imageTypes.InspectResponse, platform *ocispec.Platform) bool {
+	if platform == nil {
+		return true
+	}
+	if info.Os != platform.OS || info.Architecture != platform.Architecture {
+		return false
+	}
+	return info.Variant == "" || platform.Variant == "" || info.Variant == platform.Variant
+}
+
+// hasImage reports whether image exists locally for the sandbox platform.
+func (p *Provider) hasImage(ctx context.Context, image string) bool {
+	info, err := p.client.ImageInspect(ctx, image)
+	return err == nil && imageMatchesPlatform(info, p.platform)
+}
+
+// Status reports the sandbox image and platform the provider resolved, and
+// how many sandboxes are active against cfg.MaxSandboxesPerHost (0 = unlimited).
+// Implements sandbox.StatusProvider.
+func (p *Provider) Status() sandbox.ProviderStatus {
+	details := map[string]any{
+		"image":         p.image,
+		"platform":      formatPlatform(p.platform),
+		"max_sandboxes": p.cfg.MaxSandboxesPerHost,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if active, err := p.activeSandboxes(ctx); err == nil {
+		details["sandboxes"] = active
+	}
+
+	return sandbox.ProviderStatus{
+		Available: true,
+		State:     "ready",
+		Details:   details,
+	}
+}
@@ -0,0 +1,38 @@
+package docker
+
+import (
+	"testing"
+
+	imageTypes "github.com/docker/docker/api/types/image"
+)
+
+func TestParsePlatform(t *testing.T) {
+	for _, s := range []string{"linux/amd64", "linux/arm/v7"} {
+		if got := formatPlatform(parsePlatform(s)); got != s {
+			t.Errorf("formatPlatform(parsePlatform(%q)) = %q", s, got)
+		}
+	}
+	if got := formatPlatform(nil); got != "" {
+		t.Errorf("formatPlatform(nil) = %q, want empty", got)
+	}
+}
+
+func TestImageMatchesPlatform(t *testing.T) {
+	tests := []struct {
+		info     imageTypes.InspectResponse
+		platform string
+		want     bool
+	}{
+		{info: imageTypes.InspectResponse{Os: "linux", Architecture: "amd64"}, platform: "", want: true},
+		{info: imageTypes.InspectResponse{Os: "linux", Architecture: "amd64"}, platform: "linux/amd64", want: true},
+		{info: imageTypes.InspectResponse{Os: "linux", Architecture: "amd64"}, platform: "linux/arm64"},
+		{info: imageTypes.InspectResponse{Os: "linux", Architecture: "arm"}, platform: "linux/arm/v7", want: true},
+		{info: imageTypes.InspectResponse{Os: "linux", Architecture: "arm", Variant: "v6"}, platform: "linux/arm/v7"},
+	}
+	for _, tt := range tests {
+		if got := imageMatchesPlatform(tt.info, parsePlatform(tt.platform)); got != tt.want {
+			t.Errorf("imageMatchesPlatform(%s/%s/%s, %q) = %v, want %v",
+				tt.info.Os, tt.info.Architecture, tt.info.Variant, tt.platform, got, tt.want)
+		}
+	}
+}
@@ -2,18 +2,26 @@
 package docker
 
 import (
+	"archive/tar"
 	"bytes"
+	"cmp"
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"net"
 	"net/http"
+	"net/url"
+	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -31,6 +39,7 @@ import (
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
 	dockercontext "github.com/docker/go-sdk/context"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 
 	"github.com/obot-platform/discobot/server/internal/config"
 	"github.com/obot-platform/discobot/server/internal/sandbox"
@@ -40,6 +49,10 @@ const (
 	// labelSecret is the label key for storing the raw shared secret.
 	labelSecret = "discobot.secret"
 
+	// labelRenamedFrom marks a data volume created by Rename with the session
+	// ID whose volume the renamed container still mounts.
+	labelRenamedFrom = "discobot.session.renamed-from"
+
 	// containerPort is the fixed port exposed by all sandboxes.
 	containerPort = 3002
 
@@ -49,10 +62,31 @@ const (
 	// dataVolumePath is where the persistent data volume is mounted inside the container.
 	dataVolumePath = "/.data"
 
+	// proxyUpstreamAuthPath is where the agent reads the upstream proxy's
+	// credentials, kept out of the container environment.
+	proxyUpstreamAuthPath = dataVolumePath + "/proxy-upstream-auth"
+
 	// dataVolumePrefix is the prefix for data volume names.
 	dataVolumePrefix = "discobot-data-"
+
+	// execMarkerEnv is set to a random token in the environment of every
+	// Exec and ExecStream command so killExec can find its processes.
+	execMarkerEnv = "DISCOBOT_EXEC_MARKER"
 )
 
+// killExecScript signals every process in the container whose environment
+// contains the marker ($1), i.e. the exec'd command and the children that
+// inherited it: SIGTERM first, then SIGKILL for any still running.
+const killExecScript = `signal() {
+	for d in /proc/[0-9]*; do
+		tr '\0' '\n' < "$d/environ" 2>/dev/null | grep -qxF "$2" && kill -"$1" "${d#/proc/}" 2>/dev/null
+	done
+}
+signal TERM "$1"
+sleep 2
+signal KILL "$1"
+true`
+
 // DetectDockerHost resolves the Docker host from the current Docker context.
 // This handles Docker Desktop, Colima, Rancher Desktop, Podman, and custom
 // contexts automatically. Returns empty string if detection fails.
@@ -67,6 +101,28 @@ func DetectDockerHost() string {
 	return host
 }
 
+// NewClient creates a Docker client for the configured host. It uses
+// DOCKER_HOST if set, otherwise the host from the current Docker context,
+// falling back to the Docker SDK defaults.
+func NewClient(cfg *config.Config) (*client.Client, error) {
+	clientOpts := []client.Opt{
+		client.FromEnv,
+		client.WithAPIVersionNegotiation(),
+	}
+
+	if cfg.DockerHost != "" {
+		clientOpts = append(clientOpts, client.WithHost(cfg.DockerHost))
+	} else if host := DetectDockerHost(); host != "" {
+		clientOpts = append(clientOpts, client.WithHost(host))
+	}
+
+	cli, err := client.NewClientWithOpts(clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create docker client: %w", err)
+	}
+	return cli, nil
+}
+
 // SessionProjectResolver looks up the project ID for a session from the database.
 type SessionProjectResolver func(ctx context.Context, sessionID string) (projectID string, err error)
 
@@ -75,13 +131,46 @@ type Provider struct {
 	client *client.Client
 	cfg    *config.Config
 
+	// platform is the platform sandbox images are pulled and run for (nil
+	// leaves the choice to Docker), and image the sandbox image selected for
+	// its architecture. Both are resolved when the provider is created.
+	platform *ocispec.Platform
+	image    string
+
+	// diskLimitErr is why the daemon's storage driver can't apply disk
+	// limits (nil if it can, or if that couldn't be determined)
+	diskLimitErr error
+
 	// containerIDs maps sessionID -> Docker container ID
 	containerIDs   map[string]string
 	containerIDsMu sync.RWMutex
 
+	// execCounts maps sessionID -> number of running Exec/Attach/ExecStream
+	// commands, capped at cfg.SandboxMaxExecs
+	execCounts   map[string]int
+	execCountsMu sync.Mutex
+
+	// lastActivity maps sessionID -> when a command last started or finished,
+	// or an HTTP client was last handed out. Guarded by execCountsMu.
+	lastActivity map[string]time.Time
+
+	// pendingCreates counts Create and Start calls that passed the
+	// cfg.MaxSandboxesPerHost check but whose containers may not exist or be
+	// running yet. createMu serializes the check so concurrent calls can't all
+	// take the last slot.
+	pendingCreates int
+	createMu       sync.Mutex
+
 	// vsockDialer is an optional custom dialer for VSOCK connections
 	vsockDialer func(ctx context.Context, network, addr string) (net.Conn, error)
 
+	// host overrides the Docker host (DOCKER_HOST and the Docker context)
+	host string
+
+	// rootless, if set, creates unprivileged containers for a rootless
+	// daemon (Podman)
+	rootless *RootlessConfig
+
 	// sessionProjectResolver looks up session -> project mapping from the database.
 	sessionProjectResolver SessionProjectResolver
 
@@ -116,6 +205,32 @@ func WithVsockDialer(dialer func(ctx context.Context, network, addr string) (net
 	}
 }
 
+// WithHost connects to the daemon at host (e.g. unix:///run/user/1000/podman/podman.sock)
+// instead of DOCKER_HOST or the current Docker context.
+func WithHost(host string) Option {
+	return func(p *Provider) {
+		p.host = host
+	}
+}
+
+// RootlessConfig configures sandbox containers for a rootless daemon.
+type RootlessConfig struct {
+	// UsernsMode is the container's user namespace mode, e.g. "keep-id" to
+	// map the daemon user's UID into the container. Empty uses the daemon default.
+	UsernsMode string
+	// Runtime is the OCI runtime, e.g. "crun". Empty uses the daemon default.
+	Runtime string
+}
+
+// WithRootless configures the provider for a rootless daemon: containers are
+// not privileged, so Docker-in-Docker is unavailable, and the agent falls
+// back to agentfs if it can't mount overlayfs.
+func WithRootless(cfg RootlessConfig) Option {
+	return func(p *Provider) {
+		p.rootless = &cfg
+	}
+}
+
 // WithSystemManager configures the Docker provider with a system manager for tracking startup tasks
 func WithSystemManager(sm SystemManager) Option {
 	return func(p *Provider) {
@@ -134,6 +249,8 @@ func NewProvider(cfg *config.Config, sessionProjectResolver SessionProjectResolv
 	p := &Provider{
 		cfg:                    cfg,
 		containerIDs:           make(map[string]string),
+		execCounts:             make(map[string]int),
+		lastActivity:           make(map[string]time.Time),
 		sessionProjectResolver: sessionProjectResolver,
 	}
 
@@ -162,22 +279,19 @@ func NewProvider(cfg *config.Config, sessionProjectResolver SessionProjectResolv
 		if err != nil {
 			return nil, fmt.Errorf("failed to create docker client with vsock: %w", err)
 		}
-	} else {
-		// Use standard Docker client (local socket or configured host)
-		clientOpts := []client.Opt{
-			client.FromEnv,
+	} else if p.host != "" {
+		cli, err = client.NewClientWithOpts(
+			client.WithHost(p.host),
 			client.WithAPIVersionNegotiation(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create docker client for %s: %w", p.host, err)
 		}
-
-		if cfg.DockerHost != "" {
-			clientOpts = append(clientOpts, client.WithHost(cfg.DockerHost))
-		} else if host := DetectDockerHost(); host != "" {
-			clientOpts = append(clientOpts, client.WithHost(host))
-		}
-
-		cli, err = client.NewClientWithOpts(clientOpts...)
+	} else {
+		// Use standard Docker client (local socket or configured host)
+		cli, err = NewClient(cfg)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create docker client: %w", err)
+			return nil, err
 		}
 	}
 
@@ -193,6 +307,26 @@ func NewProvider(cfg *config.Config, sessionProjectResolver SessionProjectResolv
 		return nil, fmt.Errorf("failed to connect to docker daemon: %w", err)
 	}
 
+	// Check the daemon supports the configured log driver, so a typo fails
+	// here rather than on every sandbox create
+	if err := p.verifyLogDriver(ctx); err != nil {
+		_ = cli.Close()
+		return nil, err
+	}
+	if info, err := cli.Info(ctx); err == nil {
+		p.diskLimitErr = checkStorageDriver(info.Driver, info.DriverStatus)
+	}
+
+	// Pin the sandbox platform so multi-arch images resolve to a variant the
+	// daemon can run, and pick the image configured for its architecture
+	p.platform = resolvePlatform(ctx, cli, cfg.SandboxPlatform)
+	if p.platform != nil {
+		p.image = cfg.SandboxImageFor(p.platform.Architecture)
+	} else {
+		p.image = cfg.SandboxImage
+	}
+	log.Printf("Docker sandbox image %s, platform %s", p.image, cmp.Or(formatPlatform(p.platform), "daemon default"))
+
 	// Kick off image pull in the background (non-blocking).
 	// EnsureImage is synchronized: the first caller triggers the pull, all others wait.
 	go func() {
@@ -202,7 +336,7 @@ func NewProvider(cfg *config.Config, sessionProjectResolver SessionProjectResolv
 		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 2*time.Minute)
 		defer cleanupCancel()
 
-		if err := p.cleanupOldSandboxImages(cleanupCtx, cfg.SandboxImage); err != nil {
+		if err := p.cleanupOldSandboxImages(cleanupCtx, p.image); err != nil {
 			log.Printf("Warning: Failed to clean up old sandbox images: %v", err)
 		}
 
@@ -213,11 +347,103 @@ func NewProvider(cfg *config.Config, sessionProjectResolver SessionProjectResolv
 	return p, nil
 }
 
+// restartPolicy returns the Docker restart policy for a sandbox, falling back
+// to SANDBOX_RESTART_POLICY when policy is empty. Docker only accepts a retry
+// limit for on-failure.
+func (p *Provider) restartPolicy(policy string) containerTypes.RestartPolicy {
+	if policy == "" {
+		policy = p.cfg.SandboxRestartPolicy
+	}
+	switch policy {
+	case sandbox.RestartPolicyOnFailure:
+		return containerTypes.RestartPolicy{
+			Name:              containerTypes.RestartPolicyOnFailure,
+			MaximumRetryCount: max(p.cfg.SandboxRestartMaxRetries, 0),
+		}
+	case sandbox.RestartPolicyUnlessStopped:
+		return containerTypes.RestartPolicy{Name: containerTypes.RestartPolicyUnlessStopped}
+	default:
+		return containerTypes.RestartPolicy{Name: containerTypes.RestartPolicyDisabled}
+	}
+}
+
+// logConfig returns the log configuration for sandbox containers. An empty
+// config leaves the daemon's default driver in place.
+func (p *Provider) logConfig() containerTypes.LogConfig {
+	if p.cfg.SandboxLogDriver == "" || p.cfg.SandboxLogDriver == "daemon" {
+		return containerTypes.LogConfig{}
+	}
+	return containerTypes.LogConfig{
+		Type:   p.cfg.SandboxLogDriver,
+		Config: p.cfg.SandboxLogOptions,
+	}
+}
+
+// verifyLogDriver reports an error if the daemon doesn't support
+// SANDBOX_LOG_DRIVER. If the daemon's drivers can't be listed, the check is
+// skipped.
+func (p *Provider) verifyLogDriver(ctx context.Context) error {
+	info, err := p.client.Info(ctx)
+	if err != nil {
+		log.Printf("Warning: failed to get Docker daemon info, not checking log driver: %v", err)
+		return nil
+	}
+	return checkLogDriver(p.cfg.SandboxLogDriver, info.Plugins.Log)
+}
+
+// checkLogDriver reports an error if the daemon doesn't support the log
+// driver. Docker lists its log plugins without "none", which is built in.
+func checkLogDriver(driver string, supported []string) error {
+	if driver == "" || driver == "daemon" || driver == "none" || slices.Contains(supported, driver) {
+		return nil
+	}
+	return fmt.Errorf("SANDBOX_LOG_DRIVER %q is not supported by the Docker daemon (available: %s)", driver, strings.Join(supported, ", "))
+}
+
+// checkStorageDriver returns an error wrapping sandbox.ErrNotSupported if
+// the storage driver can't apply a per-container size (StorageOpt "size").
+// overlay2 only can on xfs, and then only with the pquota mount option,
+// which Docker doesn't report, so that is left for container creation to
+// catch.
+func checkStorageDriver(driver string, status [][2]string) error {
+	switch driver {
+	case "btrfs", "zfs", "devicemapper", "windowsfilter":
+		return nil
+	case "overlay2":
+		for _, kv := range status {
+			if kv[0] == "Backing Filesystem" && kv[1] == "xfs" {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: disk limits need a Docker storage driver that supports size options (overlay2 on xfs with pquota, btrfs, or zfs), but the daemon uses %s", sandbox.ErrNotSupported, driver)
+}
+
+// CheckDiskLimits reports whether sandboxes can be given a disk limit.
+// Implements sandbox.DiskLimitChecker.
+func (p *Provider) CheckDiskLimits() error {
+	return p.diskLimitErr
+}
+
 // containerName generates a consistent container name from session ID.
 func containerName(sessionID string) string {
 	return fmt.Sprintf("discobot-session-%s", sessionID)
 }
 
+// containerSessionID returns the session ID of one of our containers, or ""
+// if it isn't one. Container labels can't change, so the name takes
+// precedence over the discobot.session.id label for containers moved to
+// another session by Rename.
+func containerSessionID(name string, labels map[string]string) string {
+	if labels["discobot.session.id"] == "" {
+		return ""
+	}
+	if id, ok := strings.CutPrefix(strings.TrimPrefix(name, "/"), containerName("")); ok && id != "" {
+		return id
+	}
+	return labels["discobot.session.id"]
+}
+
 // volumeName returns the Docker volume name for a session's data volume.
 func volumeName(sessionID string) string {
 	return fmt.Sprintf("%s%s", dataVolumePrefix, sessionID)
@@ -225,17 +451,44 @@ func volumeName(sessionID string) string {
 
 // ImageExists checks if the configured sandbox image is available locally.
 func (p *Provider) ImageExists(ctx context.Context) bool {
-	_, err := p.client.ImageInspect(ctx, p.cfg.SandboxImage)
-	return err == nil
+	return p.hasImage(ctx, p.image)
+}
+
+// Healthy pings the Docker daemon.
+func (p *Provider) Healthy(ctx context.Context) error {
+	if _, err := p.client.Ping(ctx); err != nil {
+		return fmt.Errorf("docker daemon unreachable: %w", err)
+	}
+	return nil
 }
 
-// Image returns the configured sandbox image name.
+// Image returns the sandbox image selected for the daemon's architecture.
 func (p *Provider) Image() string {
-	return p.cfg.SandboxImage
+	return p.image
 }
 
 // Create creates a new Docker container for the given session.
 func (p *Provider) Create(ctx context.Context, sessionID string, opts sandbox.CreateOptions) (*sandbox.Sandbox, error) {
+	return p.create(ctx, sessionID, "", opts)
+}
+
+// create creates the session's container from image, or from the configured
+// sandbox image if image is empty. The session's data volume is created if
+// it doesn't already exist.
+func (p *Provider) create(ctx context.Context, sessionID, image string, opts sandbox.CreateOptions) (*sandbox.Sandbox, error) {
+	if !sandbox.ValidNetworkMode(opts.NetworkMode) {
+		return nil, fmt.Errorf("%w: invalid network mode %q", sandbox.ErrStartFailed, opts.NetworkMode)
+	}
+	if !sandbox.ValidRestartPolicy(opts.RestartPolicy) {
+		return nil, fmt.Errorf("%w: invalid restart policy %q", sandbox.ErrStartFailed, opts.RestartPolicy)
+	}
+	if !sandbox.ValidFilesystem(opts.Filesystem) {
+		return nil, fmt.Errorf("%w: invalid filesystem %q", sandbox.ErrStartFailed, opts.Filesystem)
+	}
+	if opts.Resources.DiskMB > 0 && p.diskLimitErr != nil {
+		return nil, p.diskLimitErr
+	}
+
 	// Check if sandbox already exists in cache
 	p.containerIDsMu.RLock()
 	cachedID, existsInCache := p.containerIDs[sessionID]
@@ -261,17 +514,36 @@ func (p *Provider) Create(ctx context.Context, sessionID string, opts sandbox.Cr
 		p.clearContainerID(sessionID)
 	}
 
-	// Use the globally configured sandbox image
-	image := p.cfg.SandboxImage
+	// Enforce the host-wide sandbox limit before creating anything
+	releaseCapacity, err := p.reserveCapacity(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseCapacity()
 
-	// Wait for image to be available (pulled on startup or by first caller)
-	if err := p.EnsureImage(ctx); err != nil {
-		return nil, fmt.Errorf("%w: %v", sandbox.ErrInvalidImage, err)
+	// Use the requested image (a project's override), or else the globally
+	// configured sandbox image for this platform, unless creating from a
+	// snapshot
+	switch {
+	case image != "":
+	case opts.Image != "":
+		image = opts.Image
+		// Pulled on first use; local images must already be loaded
+		if err := p.pullSandboxImage(ctx, image); err != nil {
+			return nil, fmt.Errorf("%w: %v", sandbox.ErrInvalidImage, err)
+		}
+	default:
+		image = p.image
+
+		// Wait for image to be available (pulled on startup or by first caller)
+		if err := p.EnsureImage(ctx); err != nil {
+			return nil, fmt.Errorf("%w: %v", sandbox.ErrInvalidImage, err)
+		}
 	}
 
 	// Create data volume for persistent storage
 	dataVolName := volumeName(sessionID)
-	_, err := p.client.VolumeCreate(ctx, volumeTypes.CreateOptions{
+	_, err = p.client.VolumeCreate(ctx, volumeTypes.CreateOptions{
 		Name: dataVolName,
 		Labels: map[string]string{
 			"discobot.session.id": sessionID,
@@ -321,6 +593,99 @@ func (p *Provider) Create(ctx context.Context, sessionID string, opts sandbox.Cr
 		env = append(env, fmt.Sprintf("WORKSPACE_COMMIT=%s", opts.WorkspaceCommit))
 	}
 
+	// Without these the agent clones the source's current branch with full history
+	if opts.CloneDepth > 0 {
+		env = append(env, fmt.Sprintf("WORKSPACE_CLONE_DEPTH=%d", opts.CloneDepth))
+	}
+	if opts.CloneAllBranches {
+		env = append(env, "WORKSPACE_CLONE_ALL_BRANCHES=true")
+	}
+	if opts.CloneSubmodules {
+		env = append(env, "WORKSPACE_SUBMODULES=true")
+	}
+
+	// Without this the agent picks overlayfs or agentfs itself
+	if opts.Filesystem != "" {
+		env = append(env, fmt.Sprintf("DISCOBOT_FILESYSTEM=%s", opts.Filesystem))
+	}
+
+	// Tell the agent to treat proxy startup failure as fatal instead of
+	// falling back to direct network access
+	if p.cfg.ProxyRequired {
+		env = append(env, "PROXY_REQUIRED=true")
+	}
+	// Nested containers can't always reach the sandbox's resolver, so the
+	// agent configures its Docker daemon with the same servers
+	if len(p.cfg.DockerDNS) > 0 {
+		env = append(env, "DOCKER_DNS="+strings.Join(p.cfg.DockerDNS, ","))
+	}
+
+	// Operator-supplied proxy config replaces the agent's built-in default.
+	// It's set here rather than read from the workspace so sandbox code can't change it.
+	if len(p.cfg.SandboxProxyConfig) > 0 {
+		env = append(env, "PROXY_CONFIG="+base64.StdEncoding.EncodeToString(p.cfg.SandboxProxyConfig))
+	}
+	// Chain the sandbox proxy through the operator's upstream proxy. Every
+	// exec in the container inherits its environment, so the URL goes there
+	// without credentials; those are written to a root-only file once the
+	// container exists, and the agent merges both into the root-only proxy
+	// config.
+	var upstreamAuth string
+	if p.cfg.SandboxProxyUpstream != "" {
+		var upstream string
+		upstream, upstreamAuth = splitProxyUserinfo(p.cfg.SandboxProxyUpstream)
+		env = append(env, "PROXY_UPSTREAM="+upstream)
+		if len(p.cfg.SandboxProxyUpstreamNoProxy) > 0 {
+			env = append(env, "PROXY_UPSTREAM_NO_PROXY="+strings.Join(p.cfg.SandboxProxyUpstreamNoProxy, ","))
+		}
+	}
+	// Workspaces can opt out of caching; the agent turns it off in the config it writes
+	if opts.DisableProxyCache {
+		env = append(env, "PROXY_CACHE_DISABLED=true")
+	}
+
+	// Operator-supplied init script, run by the agent as root before hooks
+	// and the agent API start. Like the proxy config, only the server sets it.
+	if len(p.cfg.SandboxInitScript) > 0 {
+		env = append(env, "INIT_SCRIPT="+base64.StdEncoding.EncodeToString(p.cfg.SandboxInitScript))
+		if p.cfg.SandboxInitScriptFatal {
+			env = append(env, "INIT_SCRIPT_FATAL=true")
+		}
+	}
+
+	// The agent checks these against the kernel and retries without them if the mount fails
+	if p.cfg.SandboxOverlayOpts != "" {
+		env = append(env, "OVERLAY_OPTIONS="+p.cfg.SandboxOverlayOpts)
+	}
+
+	// The agent evicts least recently used directories from the shared
+	// project cache volume when it grows past the limit
+	if p.cfg.SandboxCacheMaxSizeMB > 0 {
+		env = append(env,
+			fmt.Sprintf("CACHE_MAX_SIZE_MB=%d", p.cfg.SandboxCacheMaxSizeMB),
+			fmt.Sprintf("CACHE_EVICT_TARGET_PERCENT=%d", p.cfg.SandboxCacheEvictTargetPercent),
+		)
+	}
+
+	// The agent raises the soft open file limit before starting anything, so
+	// every process in the sandbox inherits it
+	if p.cfg.SandboxNoFileLimit > 0 {
+		env = append(env, fmt.Sprintf("NOFILE_LIMIT=%d", p.cfg.SandboxNoFileLimit))
+	}
+
+	// The agent enforces the network mode with firewall rules inside the sandbox.
+	// Docker networking is left alone so the server can still reach the agent API.
+	env = append(env, "NETWORK_MODE="+sandbox.EffectiveNetworkMode(opts.NetworkMode))
+
+	env = appendExtraEnv(env, opts.Env)
+
+	// Signal Docker sends on ContainerStop. The agent (PID 1) shuts down on
+	// SIGTERM, SIGINT, and SIGQUIT and forwards it to the agent API's process group.
+	stopSignal := opts.StopSignal
+	if stopSignal == "" {
+		stopSignal = p.cfg.SandboxStopSignal
+	}
+
 	// Container configuration
 	containerConfig := &containerTypes.Config{
 		Image:        image,
@@ -332,6 +697,7 @@ func (p *Provider) Create(ctx context.Context, sessionID string, opts sandbox.Cr
 		AttachStdin:  true,
 		AttachStdout: true,
 		AttachStderr: true,
+		StopSignal:   stopSignal,
 	}
 
 	// Host configuration with resource limits
@@ -344,6 +710,12 @@ func (p *Provider) Create(ctx context.Context, sessionID string, opts sandbox.Cr
 				Target: dataVolumePath,
 			},
 		},
+		// Let the daemon restart crashed sandboxes without waiting for reconcile
+		RestartPolicy: p.restartPolicy(opts.RestartPolicy),
+		// Keep agent output retrievable and bounded (SANDBOX_LOG_DRIVER)
+		LogConfig: p.logConfig(),
+		// Resolvers for the sandbox (DOCKER_DNS); nil keeps the daemon's
+		DNS: p.cfg.DockerDNS,
 		// CAP_SYS_ADMIN is required for FUSE mounts (agentfs)
 		CapAdd: []string{"SYS_ADMIN"},
 		// /dev/fuse device is required for FUSE filesystems
@@ -358,12 +730,12 @@ func (p *Provider) Create(ctx context.Context, sessionID string, opts sandbox.Cr
 		},
 	}
 
-	// Apply resource limits
-	if opts.Resources.MemoryMB > 0 {
-		hostConfig.Memory = int64(opts.Resources.MemoryMB) * 1024 * 1024
-	}
-	if opts.Resources.CPUCores > 0 {
-		hostConfig.NanoCPUs = int64(opts.Resources.CPUCores * 1e9)
+	applyResources(&hostConfig.Resources, opts.Resources, opts.ResourceRequests)
+
+	// Disk limits need a storage driver that supports them (e.g. overlay2 on
+	// xfs with pquota); Docker refuses to create the container otherwise.
+	if opts.Resources.DiskMB > 0 {
+		hostConfig.StorageOpt = map[string]string{"size": fmt.Sprintf("%dM", opts.Resources.DiskMB)}
 	}
 
 	// Mount workspace directory (always a local path)
@@ -417,24 +789,42 @@ func (p *Provider) Create(ctx context.Context, sessionID string, opts sandbox.Cr
 
 	// Enable privileged mode for running Docker daemon inside container
 	// The container runs its own Docker daemon (started by discobot-agent if dockerd is available)
-	hostConfig.Privileged = true
+	if p.rootless != nil {
+		applyRootless(containerConfig, hostConfig, *p.rootless)
+	} else {
+		hostConfig.Privileged = true
+	}
 
-	// Always expose port 3002 with a random host port
-	port := nat.Port(fmt.Sprintf("%d/tcp", containerPort))
-	containerConfig.ExposedPorts = nat.PortSet{port: struct{}{}}
-	hostConfig.PortBindings = nat.PortMap{
-		port: []nat.PortBinding{{
+	// Always expose port 3002, plus any extra ports, each with a random host port
+	if err := sandbox.ValidateExtraPorts(opts.ExtraPorts); err != nil {
+		return nil, fmt.Errorf("%w: %v", sandbox.ErrStartFailed, err)
+	}
+	containerConfig.ExposedPorts = nat.PortSet{}
+	hostConfig.PortBindings = nat.PortMap{}
+	for _, port := range append([]int{containerPort}, opts.ExtraPorts...) {
+		natPort := nat.Port(fmt.Sprintf("%d/tcp", port))
+		containerConfig.ExposedPorts[natPort] = struct{}{}
+		hostConfig.PortBindings[natPort] = []nat.PortBinding{{
 			HostIP:   "127.0.0.1",
 			HostPort: "", // Empty = Docker assigns random available port
-		}},
+		}}
 	}
 
 	// Create container
-	resp, err := p.client.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, name)
+	resp, err := p.client.ContainerCreate(ctx, containerConfig, hostConfig, nil, p.platform, name)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", sandbox.ErrStartFailed, err)
 	}
 
+	// Always written, so credentials from an earlier container on this
+	// session's data volume don't outlive a change of upstream
+	if p.cfg.SandboxProxyUpstream != "" {
+		if err := p.writeProxyUpstreamAuth(ctx, resp.ID, upstreamAuth); err != nil {
+			_ = p.client.ContainerRemove(ctx, resp.ID, containerTypes.RemoveOptions{Force: true})
+			return nil, fmt.Errorf("%w: %v", sandbox.ErrStartFailed, err)
+		}
+	}
+
 	// Store mapping
 	p.containerIDsMu.Lock()
 	p.containerIDs[sessionID] = resp.ID
@@ -453,6 +843,176 @@ func (p *Provider) Create(ctx context.Context, sessionID string, opts sandbox.Cr
 	}, nil
 }
 
+// splitProxyUserinfo returns rawURL without its credentials, and the
+// credentials as user:password ("" if it has none).
+func splitProxyUserinfo(rawURL string) (string, string) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL, ""
+	}
+	auth := u.User.Username()
+	if password, ok := u.User.Password(); ok {
+		auth += ":" + password
+	}
+	u.User = nil
+	return u.String(), auth
+}
+
+// writeProxyUpstreamAuth writes auth to proxyUpstreamAuthPath in the created
+// container, readable only by root.
+func (p *Provider) writeProxyUpstreamAuth(ctx context.Context, containerID, auth string) error {
+	archive, err := proxyUpstreamAuthArchive(auth)
+	if err != nil {
+		return err
+	}
+	if err := p.client.CopyToContainer(ctx, containerID, path.Dir(proxyUpstreamAuthPath), archive, containerTypes.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("failed to write proxy upstream credentials: %w", err)
+	}
+	return nil
+}
+
+// proxyUpstreamAuthArchive returns a tar archive holding auth as the
+// root-owned, 0600 file proxyUpstreamAuthPath.
+func proxyUpstreamAuthArchive(auth string) (io.Reader, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    path.Base(proxyUpstreamAuthPath),
+		Mode:    0600,
+		Size:    int64(len(auth)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write([]byte(auth)); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// reserveCapacity reserves a slot for a new or resumed sandbox under
+// cfg.MaxSandboxesPerHost, counting managed containers that aren't stopped plus
+// creates and starts still in progress. It returns
+// sandbox.ErrCapacityExceeded if the host is full. The returned release func
+// frees the reservation once the container exists or is running (and is
+// counted itself), or creating or starting it failed.
+func (p *Provider) reserveCapacity(ctx context.Context) (func(), error) {
+	limit := p.cfg.MaxSandboxesPerHost
+	if limit <= 0 {
+		return func() {}, nil
+	}
+
+	p.createMu.Lock()
+	defer p.createMu.Unlock()
+
+	active, err := p.activeSandboxes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if active+p.pendingCreates >= limit {
+		return nil, fmt.Errorf("%w: %d of %d sandboxes in use; stop another session and try again",
+			sandbox.ErrCapacityExceeded, active+p.pendingCreates, limit)
+	}
+	p.pendingCreates++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.createMu.Lock()
+			defer p.createMu.Unlock()
+			p.pendingCreates--
+		})
+	}, nil
+}
+
+// activeSandboxes returns the number of managed containers on the host that
+// aren't stopped, whichever session or project they belong to.
+func (p *Provider) activeSandboxes(ctx context.Context) (int, error) {
+	containers, err := p.client.ContainerList(ctx, containerTypes.ListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", "discobot.managed=true"),
+		),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count sandboxes: %w", err)
+	}
+	return countActive(containers), nil
+}
+
+// countActive counts the containers that hold host resources: created,
+// running, paused, or restarting, but not exited or dead.
+func countActive(containers []containerTypes.Summary) int {
+	n := 0
+	for _, c := range containers {
+		if c.State != containerTypes.StateExited && c.State != containerTypes.StateDead {
+			n++
+		}
+	}
+	return n
+}
+
+// applyRootless configures a sandbox container for a rootless daemon. It
+// isn't privileged, and the agent still runs as root (PID 1 needs to mount
+// filesystems), which user namespace modes like keep-id would otherwise change.
+// It keeps rootlessCaps so the agent can install the egress firewall.
+func applyRootless(containerConfig *containerTypes.Config, hostConfig *containerTypes.HostConfig, cfg RootlessConfig) {
+	hostConfig.Privileged = false
+	containerConfig.User = "0:0"
+	for _, capability := range rootlessCaps {
+		if !slices.Contains(hostConfig.CapAdd, capability) {
+			hostConfig.CapAdd = append(hostConfig.CapAdd, capability)
+		}
+	}
+	if cfg.UsernsMode != "" {
+		hostConfig.UsernsMode = containerTypes.UsernsMode(cfg.UsernsMode)
+	}
+	if cfg.Runtime != "" {
+		hostConfig.Runtime = cfg.Runtime
+	}
+}
+
+// rootlessCaps are the capabilities rootless sandbox containers are given
+// beyond the runtime's defaults: iptables needs them for the network mode's
+// DISCOBOT-EGRESS chain. The user namespace confines them to the container's
+// own network namespace.
+var rootlessCaps = []string{"NET_ADMIN", "NET_RAW"}
+
+// cpuSharesPerCore is Docker's default CPU shares, which we treat as one
+// core's worth of weight.
+const cpuSharesPerCore = 1024
+
+// applyResources sets a container's resource limits and requests. Limits are
+// hard caps (--memory, --cpus). Requests map to a soft memory reservation
+// (--memory-reservation), which the kernel tries to leave a container under
+// memory pressure, and to CPU shares (--cpu-shares), which weight containers
+// only while the CPUs are busy. Requests are capped at the limits.
+func applyResources(res *containerTypes.Resources, limits sandbox.ResourceConfig, requests sandbox.ResourceRequests) {
+	if limits.MemoryMB > 0 {
+		res.Memory = int64(limits.MemoryMB) * 1024 * 1024
+	}
+	if limits.CPUCores > 0 {
+		res.NanoCPUs = int64(limits.CPUCores * 1e9)
+	}
+
+	if memoryMB := requests.MemoryMB; memoryMB > 0 {
+		if limits.MemoryMB > 0 && memoryMB > limits.MemoryMB {
+			memoryMB = limits.MemoryMB
+		}
+		res.MemoryReservation = int64(memoryMB) * 1024 * 1024
+	}
+	if cores := requests.CPUCores; cores > 0 {
+		if limits.CPUCores > 0 && cores > limits.CPUCores {
+			cores = limits.CPUCores
+		}
+		// Docker rejects shares below 2
+		res.CPUShares = max(int64(cores*cpuSharesPerCore), 2)
+	}
+}
+
 // hashSecret creates a salted SHA-256 hash of the secret.
 // Returns the format "salt:hash" where both are hex-encoded.
 // The salt is 16 random bytes, making each hash unique even for identical secrets.
@@ -510,7 +1070,7 @@ func (p *Provider) EnsureImage(ctx context.Context) error {
 func (p *Provider) doEnsureImage() {
 	defer close(p.ensureImageDone)
 
-	image := p.cfg.SandboxImage
+	image := p.image
 
 	// Local images can't be pulled from a registry. They are loaded externally
 	// (e.g., via ensureImageInVM in the VZ provider which transfers from host Docker).
@@ -528,11 +1088,11 @@ func (p *Provider) doEnsureImage() {
 		return
 	}
 
-	// Check if image already exists — no task registration needed
+	// Check if image already exists for our platform — no task registration needed
 	checkCtx, checkCancel := context.WithTimeout(context.Background(), 10*time.Second)
-	_, err := p.client.ImageInspect(checkCtx, image)
+	exists := p.hasImage(checkCtx, image)
 	checkCancel()
-	if err == nil {
+	if exists {
 		log.Printf("Sandbox image already exists: %s", image)
 		return
 	}
@@ -582,17 +1142,30 @@ func isLocalImage(image string) bool {
 	return strings.HasPrefix(image, "discobot-local/") || strings.HasPrefix(image, "sha256:")
 }
 
+// imageRepository returns an image reference without its tag or digest.
+func imageRepository(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
 // pullSandboxImage pulls the sandbox image if it doesn't exist locally and can be pulled.
 func (p *Provider) pullSandboxImage(ctx context.Context, image string) error {
-	// Check if image already exists locally
-	_, err := p.client.ImageInspect(ctx, image)
-	if err == nil {
+	// Check if image already exists locally. An image built for another
+	// platform is pulled again, unless it is local and can't be pulled.
+	info, err := p.client.ImageInspect(ctx, image)
+	if err == nil && (imageMatchesPlatform(info, p.platform) || isLocalImage(image)) {
 		log.Printf("Sandbox image already exists locally, skipping pull: %s", image)
 		if p.systemManager != nil {
 			p.systemManager.UpdateTaskProgress("docker-pull", 100, "Image already exists")
 		}
 		return nil
 	}
+	if err == nil {
+		log.Printf("Sandbox image %s is %s/%s, pulling it for %s", image, info.Os, info.Architecture, formatPlatform(p.platform))
+	}
 
 	// Image doesn't exist locally. Check if it's a local-only image that can't be pulled.
 	if isLocalImage(image) {
@@ -602,7 +1175,7 @@ func (p *Provider) pullSandboxImage(ctx context.Context, image string) error {
 
 	// Image doesn't exist, pull it (works for both tags and digest references)
 	log.Printf("Pulling sandbox image: %s", image)
-	reader, err := p.client.ImagePull(ctx, image, imageTypes.PullOptions{})
+	reader, err := p.client.ImagePull(ctx, image, imageTypes.PullOptions{Platform: formatPlatform(p.platform)})
 	if err != nil {
 		return fmt.Errorf("failed to pull sandbox image %s: %w", image, err)
 	}
@@ -680,6 +1253,9 @@ func (p *Provider) processPullProgress(reader io.Reader, taskID string) error {
 
 // cleanupOldSandboxImages removes old sandbox images with the discobot label.
 // This helps clean up images from previous versions when the sandbox image is updated.
+// Images that sandboxes still use and local images tagged in another
+// repository (such as a project's image) are kept, since images built from
+// the sandbox image carry its label too.
 func (p *Provider) cleanupOldSandboxImages(ctx context.Context, currentImage string) error {
 	// List all images with the discobot sandbox label
 	images, err := p.client.ImageList(ctx, imageTypes.ListOptions{
@@ -698,12 +1274,37 @@ func (p *Provider) cleanupOldSandboxImages(ctx context.Context, currentImage str
 		currentImageInfo.ID = "" // Empty ID means nothing will match it in the cleanup loop
 	}
 
+	// Sandboxes may use a project's image instead of the current one
+	inUse := map[string]bool{}
+	containers, err := p.client.ContainerList(ctx, containerTypes.ListOptions{
+		All: true,
+		Filters: filters.NewArgs(
+			filters.Arg("label", "discobot.managed=true"),
+		),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list sandboxes: %w", err)
+	}
+	for _, c := range containers {
+		inUse[c.ImageID] = true
+	}
+
 	deletedCount := 0
 	for _, img := range images {
 		// Skip the current image
 		if currentImageInfo.ID != "" && img.ID == currentImageInfo.ID {
 			continue
 		}
+		// Images committed from sandboxes belong to users, not to us
+		if img.Labels[sandbox.CommittedImageLabel] == "true" {
+			continue
+		}
+		if inUse[img.ID] || slices.ContainsFunc(img.RepoTags, func(tag string) bool {
+			// Local images from another repository can't be pulled back
+			return isLocalImage(tag) && imageRepository(tag) != imageRepository(currentImage)
+		}) {
+			continue
+		}
 
 		// Delete the old image
 		log.Printf("Removing old sandbox image: %s (ID: %s)", img.RepoTags, img.ID)
@@ -728,7 +1329,7 @@ func (p *Provider) cleanupOldSandboxImages(ctx context.Context, currentImage str
 // CleanupImages removes old sandbox images, keeping only the current one.
 // Implements sandbox.ImageCleaner.
 func (p *Provider) CleanupImages(ctx context.Context) error {
-	return p.cleanupOldSandboxImages(ctx, p.cfg.SandboxImage)
+	return p.cleanupOldSandboxImages(ctx, p.image)
 }
 
 // Start starts a previously created sandbox.
@@ -738,6 +1339,24 @@ func (p *Provider) Start(ctx context.Context, sessionID string) error {
 		return err
 	}
 
+	// A stopped sandbox gave up its slot under cfg.MaxSandboxesPerHost, so
+	// resuming it needs one again
+	info, err := p.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			p.clearContainerID(sessionID)
+			return sandbox.ErrNotFound
+		}
+		return fmt.Errorf("failed to inspect sandbox: %w", err)
+	}
+	if info.State != nil && (info.State.Status == containerTypes.StateExited || info.State.Status == containerTypes.StateDead) {
+		releaseCapacity, err := p.reserveCapacity(ctx)
+		if err != nil {
+			return err
+		}
+		defer releaseCapacity()
+	}
+
 	if err := p.client.ContainerStart(ctx, containerID, containerTypes.StartOptions{}); err != nil {
 		return fmt.Errorf("%w: %v", sandbox.ErrStartFailed, err)
 	}
@@ -745,7 +1364,8 @@ func (p *Provider) Start(ctx context.Context, sessionID string) error {
 	return nil
 }
 
-// Stop stops a running sandbox gracefully.
+// Stop stops a running sandbox gracefully. Stopped sandboxes don't count
+// toward cfg.MaxSandboxesPerHost, so this frees the sandbox's slot.
 func (p *Provider) Stop(ctx context.Context, sessionID string, timeout time.Duration) error {
 	containerID, err := p.getContainerID(ctx, sessionID)
 	if err != nil {
@@ -757,10 +1377,66 @@ func (p *Provider) Stop(ctx context.Context, sessionID string, timeout time.Dura
 		Timeout: &timeoutSeconds,
 	}
 
-	if err := p.client.ContainerStop(ctx, containerID, stopOptions); err != nil {
-		return fmt.Errorf("failed to stop sandbox: %w", err)
+	if err := p.client.ContainerStop(ctx, containerID, stopOptions); err != nil {
+		return fmt.Errorf("failed to stop sandbox: %w", err)
+	}
+
+	return nil
+}
+
+// Pause freezes the sandbox container's processes (docker pause).
+func (p *Provider) Pause(ctx context.Context, sessionID string) error {
+	containerID, err := p.getContainerID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	info, err := p.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			p.clearContainerID(sessionID)
+			return sandbox.ErrNotFound
+		}
+		return fmt.Errorf("failed to inspect sandbox: %w", err)
+	}
+	switch {
+	case info.State == nil || !info.State.Running:
+		return sandbox.ErrNotRunning
+	case info.State.Paused:
+		return nil
+	}
+
+	if err := p.client.ContainerPause(ctx, containerID); err != nil {
+		return fmt.Errorf("failed to pause sandbox: %w", err)
+	}
+	return nil
+}
+
+// Unpause resumes a paused sandbox container (docker unpause).
+func (p *Provider) Unpause(ctx context.Context, sessionID string) error {
+	containerID, err := p.getContainerID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	info, err := p.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			p.clearContainerID(sessionID)
+			return sandbox.ErrNotFound
+		}
+		return fmt.Errorf("failed to inspect sandbox: %w", err)
+	}
+	switch {
+	case info.State == nil || !info.State.Running:
+		return sandbox.ErrNotRunning
+	case !info.State.Paused:
+		return nil
+	}
+
+	if err := p.client.ContainerUnpause(ctx, containerID); err != nil {
+		return fmt.Errorf("failed to unpause sandbox: %w", err)
 	}
-
 	return nil
 }
 
@@ -780,6 +1456,12 @@ func (p *Provider) Remove(ctx context.Context, sessionID string, opts ...sandbox
 	}
 
 	if containerID != "" {
+		// A fork's sandbox runs its snapshot image, which goes with it
+		var image string
+		if info, err := p.client.ContainerInspect(ctx, containerID); err == nil && info.Config != nil {
+			image = info.Config.Image
+		}
+
 		removeOptions := containerTypes.RemoveOptions{
 			Force:         true,
 			RemoveVolumes: true, // Only removes anonymous volumes, not named volumes
@@ -788,20 +1470,31 @@ func (p *Provider) Remove(ctx context.Context, sessionID string, opts ...sandbox
 		if err := p.client.ContainerRemove(ctx, containerID, removeOptions); err != nil {
 			return fmt.Errorf("failed to remove sandbox container: %w", err)
 		}
+		p.removeSnapshotImage(ctx, image)
 
 		// Remove from mapping
 		p.containerIDsMu.Lock()
 		delete(p.containerIDs, sessionID)
 		p.containerIDsMu.Unlock()
+
+		p.execCountsMu.Lock()
+		delete(p.lastActivity, sessionID)
+		p.execCountsMu.Unlock()
 	}
 
-	// Explicitly remove the named data volume if requested
+	// Explicitly remove the named data volume if requested, along with the
+	// volume a renamed container was still using
 	if cfg.RemoveVolumes {
-		dataVolName := volumeName(sessionID)
-		if err := p.client.VolumeRemove(ctx, dataVolName, true); err != nil {
-			// Don't fail if volume doesn't exist
-			if !cerrdefs.IsNotFound(err) {
-				return fmt.Errorf("failed to remove data volume %s: %w", dataVolName, err)
+		volumeNames := []string{volumeName(sessionID)}
+		if vol, err := p.client.VolumeInspect(ctx, volumeNames[0]); err == nil && vol.Labels[labelRenamedFrom] != "" {
+			volumeNames = append(volumeNames, volumeName(vol.Labels[labelRenamedFrom]))
+		}
+		for _, dataVolName := range volumeNames {
+			if err := p.client.VolumeRemove(ctx, dataVolName, true); err != nil {
+				// Don't fail if volume doesn't exist
+				if !cerrdefs.IsNotFound(err) {
+					return fmt.Errorf("failed to remove data volume %s: %w", dataVolName, err)
+				}
 			}
 		}
 	}
@@ -809,6 +1502,69 @@ func (p *Provider) Remove(ctx context.Context, sessionID string, opts ...sandbox
 	return nil
 }
 
+// Rename moves a session's sandbox to newSessionID by renaming its container
+// and creating the new session's data volume. Implements sandbox.Renamer.
+//
+// Docker can't rename volumes or change container labels and environment, so
+// the container keeps mounting the old session's data volume and its
+// SESSION_ID, and is identified by its new name from then on. The new volume
+// is empty: a sandbox recreated for newSessionID starts without the old
+// session's data unless it is copied over first. Removing the new session's
+// volumes also removes the old one.
+func (p *Provider) Rename(ctx context.Context, oldSessionID, newSessionID string) error {
+	if oldSessionID == newSessionID {
+		return fmt.Errorf("cannot rename session %s to itself", oldSessionID)
+	}
+
+	containerID, err := p.getContainerID(ctx, oldSessionID)
+	if err != nil {
+		return err
+	}
+
+	newName := containerName(newSessionID)
+	if _, err := p.client.ContainerInspect(ctx, newName); err == nil {
+		return fmt.Errorf("%w: container %s already exists", sandbox.ErrAlreadyExists, newName)
+	} else if !cerrdefs.IsNotFound(err) {
+		return fmt.Errorf("failed to check for container %s: %w", newName, err)
+	}
+
+	newVolName := volumeName(newSessionID)
+	if _, err := p.client.VolumeInspect(ctx, newVolName); err == nil {
+		return fmt.Errorf("%w: volume %s already exists", sandbox.ErrAlreadyExists, newVolName)
+	} else if !cerrdefs.IsNotFound(err) {
+		return fmt.Errorf("failed to check for volume %s: %w", newVolName, err)
+	}
+	if _, err := p.client.VolumeCreate(ctx, volumeTypes.CreateOptions{
+		Name: newVolName,
+		Labels: map[string]string{
+			"discobot.session.id": newSessionID,
+			"discobot.managed":    "true",
+			labelRenamedFrom:      oldSessionID,
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to create data volume: %w", err)
+	}
+
+	if err := p.client.ContainerRename(ctx, containerID, newName); err != nil {
+		_ = p.client.VolumeRemove(ctx, newVolName, true)
+		return fmt.Errorf("failed to rename sandbox container: %w", err)
+	}
+
+	p.containerIDsMu.Lock()
+	delete(p.containerIDs, oldSessionID)
+	p.containerIDs[newSessionID] = containerID
+	p.containerIDsMu.Unlock()
+
+	p.execCountsMu.Lock()
+	if t, ok := p.lastActivity[oldSessionID]; ok {
+		delete(p.lastActivity, oldSessionID)
+		p.lastActivity[newSessionID] = t
+	}
+	p.execCountsMu.Unlock()
+
+	return nil
+}
+
 // Get returns the current state of a sandbox.
 func (p *Provider) Get(ctx context.Context, sessionID string) (*sandbox.Sandbox, error) {
 	containerID, err := p.getContainerID(ctx, sessionID)
@@ -842,13 +1598,17 @@ func (p *Provider) Get(ctx context.Context, sessionID string) (*sandbox.Sandbox,
 
 	// Determine status
 	switch {
+	case info.State.Paused:
+		// Paused containers also report Running
+		s.Status = sandbox.StatusPaused
+		if started, err := time.Parse(time.RFC3339Nano, info.State.StartedAt); err == nil {
+			s.StartedAt = &started
+		}
 	case info.State.Running:
 		s.Status = sandbox.StatusRunning
 		if started, err := time.Parse(time.RFC3339Nano, info.State.StartedAt); err == nil {
 			s.StartedAt = &started
 		}
-	case info.State.Paused:
-		s.Status = sandbox.StatusStopped
 	case info.State.Dead || info.State.OOMKilled:
 		s.Status = sandbox.StatusFailed
 		s.Error = info.State.Error
@@ -909,6 +1669,300 @@ func (p *Provider) GetSecret(ctx context.Context, sessionID string) (string, err
 	return secret, nil
 }
 
+// FilesystemDiff returns the changes in the session's container filesystem
+// (`docker diff`). Implements sandbox.FilesystemDiffer.
+func (p *Provider) FilesystemDiff(ctx context.Context, sessionID string) ([]sandbox.FileChange, error) {
+	containerID, err := p.getContainerID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := p.client.ContainerDiff(ctx, containerID)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			p.clearContainerID(sessionID)
+			return nil, sandbox.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to diff sandbox filesystem: %w", err)
+	}
+	return fileChanges(changes), nil
+}
+
+// Logs returns the output of the session's container (`docker logs`).
+// Implements sandbox.LogStreamer.
+func (p *Provider) Logs(ctx context.Context, sessionID string, opts sandbox.LogOptions) (io.ReadCloser, error) {
+	containerID, err := p.getContainerID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := p.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			p.clearContainerID(sessionID)
+			return nil, sandbox.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to inspect sandbox: %w", err)
+	}
+
+	logOpts := containerTypes.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     opts.Follow,
+		Tail:       "all",
+	}
+	if opts.Tail > 0 {
+		logOpts.Tail = strconv.Itoa(opts.Tail)
+	}
+	if !opts.Since.IsZero() {
+		logOpts.Since = opts.Since.Format(time.RFC3339Nano)
+	}
+
+	raw, err := p.client.ContainerLogs(ctx, containerID, logOpts)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			p.clearContainerID(sessionID)
+			return nil, sandbox.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read sandbox logs: %w", err)
+	}
+
+	// TTY containers log a raw stream; others multiplex stdout and stderr
+	if info.Config != nil && info.Config.Tty {
+		return raw, nil
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(pw, pw, raw)
+		pw.CloseWithError(err)
+	}()
+	return &demuxedLogs{PipeReader: pr, raw: raw}, nil
+}
+
+// demuxedLogs is a demultiplexed container log stream. Closing it also
+// closes the underlying Docker stream, which stops the copying goroutine.
+type demuxedLogs struct {
+	*io.PipeReader
+	raw io.Closer
+}
+
+func (d *demuxedLogs) Close() error {
+	err := d.raw.Close()
+	_ = d.PipeReader.Close()
+	return err
+}
+
+// largeImageBytes is the committed image size above which CommitImage warns.
+const largeImageBytes = 10 << 30
+
+// CommitImage saves the session's container filesystem as imageRef
+// (`docker commit`). Implements sandbox.ImageCommitter.
+func (p *Provider) CommitImage(ctx context.Context, sessionID, imageRef string) (*sandbox.CommittedImage, error) {
+	containerID, err := p.getContainerID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := p.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			p.clearContainerID(sessionID)
+			return nil, sandbox.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to inspect sandbox: %w", err)
+	}
+
+	// The container's config includes everything discobot set at creation;
+	// start from the base image's config so only that carries over
+	var base *containerTypes.Config
+	if baseImage, err := p.client.ImageInspect(ctx, info.Image); err == nil && baseImage.Config != nil {
+		base = &containerTypes.Config{Env: baseImage.Config.Env, Labels: baseImage.Config.Labels}
+	} else if err != nil {
+		log.Printf("Warning: failed to inspect base image of sandbox %s: %v", sessionID, err)
+	}
+
+	resp, err := p.client.ContainerCommit(ctx, containerID, containerTypes.CommitOptions{
+		Reference: imageRef,
+		Comment:   "Committed from discobot session " + sessionID,
+		Pause:     true,
+		Config:    commitConfig(info.Config, base, sessionID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit sandbox: %w", err)
+	}
+
+	image := &sandbox.CommittedImage{ID: resp.ID, Ref: imageRef}
+	if inspect, err := p.client.ImageInspect(ctx, resp.ID); err == nil {
+		image.SizeBytes = inspect.Size
+	}
+	if image.SizeBytes > largeImageBytes {
+		image.Warnings = append(image.Warnings, fmt.Sprintf(
+			"image is %.1f GB; large images are slow to start and push, consider removing caches before committing",
+			float64(image.SizeBytes)/(1<<30)))
+	}
+	return image, nil
+}
+
+// appendExtraEnv appends the variables in extra, sorted by name, to env,
+// skipping any env already sets.
+func appendExtraEnv(env []string, extra map[string]string) []string {
+	set := make(map[string]bool, len(env))
+	for _, kv := range env {
+		k, _, _ := strings.Cut(kv, "=")
+		set[k] = true
+	}
+	for _, k := range slices.Sorted(maps.Keys(extra)) {
+		if !set[k] {
+			env = append(env, k+"="+extra[k])
+		}
+	}
+	return env
+}
+
+// commitConfig returns the config overrides for committing a sandbox
+// container. Docker merges the container's own env and labels into whatever
+// is passed, so values discobot added (session ID, secret hash, proxy
+// settings) can only be blanked, not dropped. Values that came from the base
+// image keep their image value.
+func commitConfig(container, base *containerTypes.Config, sessionID string) *containerTypes.Config {
+	cfg := &containerTypes.Config{
+		Labels: map[string]string{
+			"io.discobot.sandbox-image":  "false",
+			sandbox.CommittedImageLabel:  "true",
+			"io.discobot.committed-from": sessionID,
+		},
+	}
+	if container == nil {
+		return cfg
+	}
+
+	baseEnv := make(map[string]string)
+	var baseLabels map[string]string
+	if base != nil {
+		for _, kv := range base.Env {
+			k, _, _ := strings.Cut(kv, "=")
+			baseEnv[k] = kv
+		}
+		baseLabels = base.Labels
+	}
+
+	for _, kv := range container.Env {
+		k, _, _ := strings.Cut(kv, "=")
+		if orig, ok := baseEnv[k]; ok {
+			cfg.Env = append(cfg.Env, orig)
+		} else {
+			cfg.Env = append(cfg.Env, k+"=")
+		}
+	}
+	for k := range container.Labels {
+		if _, set := cfg.Labels[k]; set {
+			continue
+		}
+		if v, ok := baseLabels[k]; ok {
+			cfg.Labels[k] = v
+		} else {
+			cfg.Labels[k] = ""
+		}
+	}
+	return cfg
+}
+
+// fileChanges converts Docker's filesystem changes, sorted by path.
+func fileChanges(changes []containerTypes.FilesystemChange) []sandbox.FileChange {
+	result := make([]sandbox.FileChange, 0, len(changes))
+	for _, c := range changes {
+		kind := sandbox.FileModified
+		switch c.Kind {
+		case containerTypes.ChangeAdd:
+			kind = sandbox.FileAdded
+		case containerTypes.ChangeDelete:
+			kind = sandbox.FileDeleted
+		}
+		result = append(result, sandbox.FileChange{Path: c.Path, Kind: kind})
+	}
+	slices.SortFunc(result, func(a, b sandbox.FileChange) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return result
+}
+
+// Stats samples the resource usage of the session's container. Implements
+// sandbox.StatsProvider.
+func (p *Provider) Stats(ctx context.Context, sessionID string) (*sandbox.Stats, error) {
+	containerID, err := p.getContainerID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A stopped container reports zeroed stats rather than an error
+	info, err := p.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			p.clearContainerID(sessionID)
+			return nil, sandbox.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to inspect sandbox: %w", err)
+	}
+	if info.State == nil || !info.State.Running {
+		return nil, sandbox.ErrNotRunning
+	}
+
+	// Without streaming, Docker takes two samples about a second apart and
+	// returns the earlier one as precpu_stats, so the CPU delta is meaningful
+	resp, err := p.client.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			p.clearContainerID(sessionID)
+			return nil, sandbox.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get sandbox stats: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var raw containerTypes.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode sandbox stats: %w", err)
+	}
+	return containerStats(&raw), nil
+}
+
+// containerStats converts a Docker stats sample the way `docker stats` does.
+func containerStats(raw *containerTypes.StatsResponse) *sandbox.Stats {
+	stats := &sandbox.Stats{
+		MemoryLimitBytes: raw.MemoryStats.Limit,
+		Timestamp:        raw.Read,
+	}
+
+	// CPU: the container's share of the host's CPU time between the samples,
+	// scaled by the number of CPUs
+	cpuDelta := float64(raw.CPUStats.CPUUsage.TotalUsage) - float64(raw.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(raw.CPUStats.SystemUsage) - float64(raw.PreCPUStats.SystemUsage)
+	onlineCPUs := float64(raw.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(raw.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		stats.CPUPercent = cpuDelta / systemDelta * onlineCPUs * 100
+	}
+
+	// Memory: exclude inactive page cache, which the kernel reclaims under
+	// pressure (cgroup v1 reports it as total_inactive_file, v2 as inactive_file)
+	stats.MemoryUsedBytes = raw.MemoryStats.Usage
+	inactive, ok := raw.MemoryStats.Stats["total_inactive_file"]
+	if !ok {
+		inactive = raw.MemoryStats.Stats["inactive_file"]
+	}
+	if inactive < stats.MemoryUsedBytes {
+		stats.MemoryUsedBytes -= inactive
+	}
+
+	for _, n := range raw.Networks {
+		stats.NetworkRxBytes += n.RxBytes
+		stats.NetworkTxBytes += n.TxBytes
+	}
+	return stats
+}
+
 // extractEnv parses Docker's env slice (KEY=VALUE format) into a map.
 func (p *Provider) extractEnv(envSlice []string) map[string]string {
 	env := make(map[string]string)
@@ -944,16 +1998,30 @@ func (p *Provider) extractPorts(settings *containerTypes.NetworkSettings) []sand
 
 // Exec runs a non-interactive command in the sandbox.
 func (p *Provider) Exec(ctx context.Context, sessionID string, cmd []string, opts sandbox.ExecOptions) (*sandbox.ExecResult, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
 	containerID, err := p.getContainerID(ctx, sessionID)
 	if err != nil {
 		return nil, err
 	}
 
+	release, err := p.acquireExec(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	// Convert environment to slice
-	env := make([]string, 0, len(opts.Env))
+	marker := execMarkerEnv + "=" + rand.Text()
+	env := make([]string, 0, len(opts.Env)+1)
 	for k, v := range opts.Env {
 		env = append(env, fmt.Sprintf("%s=%s", k, v))
 	}
+	env = append(env, marker)
 
 	execConfig := containerTypes.ExecOptions{
 		Cmd:          cmd,
@@ -984,9 +2052,23 @@ func (p *Provider) Exec(ctx context.Context, sessionID string, cmd []string, opt
 		}()
 	}
 
-	// Read stdout and stderr
-	var stdout, stderr bytes.Buffer
-	_, err = stdcopy.StdCopy(&stdout, &stderr, resp.Reader)
+	// The hijacked connection ignores ctx, so close it to unblock the read
+	// when the timeout (or caller) cancels.
+	stopClose := context.AfterFunc(ctx, resp.Close)
+	defer stopClose()
+
+	// Read stdout and stderr, keeping at most MaxOutputBytes in memory
+	output := sandbox.NewExecOutput(opts)
+	_, err = stdcopy.StdCopy(output.Stdout(), output.Stderr(), resp.Reader)
+	if ctx.Err() != nil {
+		// Docker keeps running a command after its client goes away, so
+		// stop it rather than leave it burning CPU in the sandbox
+		p.killExec(containerID, marker)
+		if opts.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return output.TimedOutResult(), nil
+		}
+		return nil, ctx.Err()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", sandbox.ErrExecFailed, err)
 	}
@@ -997,11 +2079,7 @@ func (p *Provider) Exec(ctx context.Context, sessionID string, cmd []string, opt
 		return nil, fmt.Errorf("%w: %v", sandbox.ErrExecFailed, err)
 	}
 
-	return &sandbox.ExecResult{
-		ExitCode: inspect.ExitCode,
-		Stdout:   stdout.Bytes(),
-		Stderr:   stderr.Bytes(),
-	}, nil
+	return output.Result(inspect.ExitCode), nil
 }
 
 // detectShell determines the best available shell in the container.
@@ -1083,6 +2161,11 @@ func (p *Provider) Attach(ctx context.Context, sessionID string, opts sandbox.At
 		return nil, err
 	}
 
+	release, err := p.acquireExec(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Determine shell to use
 	cmd := opts.Cmd
 	if len(cmd) == 0 {
@@ -1107,6 +2190,7 @@ func (p *Provider) Attach(ctx context.Context, sessionID string, opts sandbox.At
 
 	execCreate, err := p.client.ContainerExecCreate(ctx, containerID, execConfig)
 	if err != nil {
+		release()
 		return nil, fmt.Errorf("%w: %v", sandbox.ErrAttachFailed, err)
 	}
 
@@ -1114,6 +2198,7 @@ func (p *Provider) Attach(ctx context.Context, sessionID string, opts sandbox.At
 		Tty: true,
 	})
 	if err != nil {
+		release()
 		return nil, fmt.Errorf("%w: %v", sandbox.ErrAttachFailed, err)
 	}
 
@@ -1129,22 +2214,31 @@ func (p *Provider) Attach(ctx context.Context, sessionID string, opts sandbox.At
 		client:    p.client,
 		execID:    execCreate.ID,
 		hijacked:  resp,
+		release:   release,
 		closeOnce: sync.Once{},
 	}, nil
 }
 
 // ExecStream runs a command with bidirectional streaming I/O (no TTY).
+// Cancelling ctx or closing the stream stops the command if it is still running.
 func (p *Provider) ExecStream(ctx context.Context, sessionID string, cmd []string, opts sandbox.ExecStreamOptions) (sandbox.Stream, error) {
 	containerID, err := p.getContainerID(ctx, sessionID)
 	if err != nil {
 		return nil, err
 	}
 
+	release, err := p.acquireExec(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Convert environment to slice
-	env := make([]string, 0, len(opts.Env))
+	marker := execMarkerEnv + "=" + rand.Text()
+	env := make([]string, 0, len(opts.Env)+1)
 	for k, v := range opts.Env {
 		env = append(env, fmt.Sprintf("%s=%s", k, v))
 	}
+	env = append(env, marker)
 
 	execConfig := containerTypes.ExecOptions{
 		Cmd:          cmd,
@@ -1159,6 +2253,7 @@ func (p *Provider) ExecStream(ctx context.Context, sessionID string, cmd []strin
 
 	execCreate, err := p.client.ContainerExecCreate(ctx, containerID, execConfig)
 	if err != nil {
+		release()
 		return nil, fmt.Errorf("failed to create exec: %w", err)
 	}
 
@@ -1166,6 +2261,7 @@ func (p *Provider) ExecStream(ctx context.Context, sessionID string, cmd []strin
 		Tty: false,
 	})
 	if err != nil {
+		release()
 		return nil, fmt.Errorf("failed to attach exec: %w", err)
 	}
 
@@ -1181,16 +2277,90 @@ func (p *Provider) ExecStream(ctx context.Context, sessionID string, cmd []strin
 		_, _ = stdcopy.StdCopy(stdoutWriter, stderrWriter, resp.Reader)
 	}()
 
-	return &dockerStream{
+	stream := &dockerStream{
 		client:       p.client,
 		execID:       execCreate.ID,
 		hijacked:     resp,
 		stdoutReader: stdoutReader,
 		stderrReader: stderrReader,
+		release:      release,
+		kill:         func() { p.killExec(containerID, marker) },
 		closeOnce:    sync.Once{},
+	}
+	stream.stopCtx = context.AfterFunc(ctx, func() { _ = stream.Close() })
+	return stream, nil
+}
+
+// killExec stops the processes of an Exec or ExecStream command, found by
+// the marker in their environment. It runs a detached exec as root, so it
+// returns without waiting for them to exit.
+func (p *Provider) killExec(containerID, marker string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	execCreate, err := p.client.ContainerExecCreate(ctx, containerID, containerTypes.ExecOptions{
+		Cmd:  []string{"sh", "-c", killExecScript, "sh", marker},
+		User: "0",
+	})
+	if err == nil {
+		err = p.client.ContainerExecStart(ctx, execCreate.ID, containerTypes.ExecStartOptions{Detach: true})
+	}
+	if err != nil {
+		log.Printf("Failed to stop cancelled exec in container %s: %v", containerID[:min(12, len(containerID))], err)
+	}
+}
+
+// acquireExec reserves one of the session's concurrent exec slots. It returns
+// sandbox.ErrTooManyExecs if cfg.SandboxMaxExecs commands are already running.
+// The returned release func frees the slot and is safe to call more than once.
+func (p *Provider) acquireExec(sessionID string) (func(), error) {
+	p.execCountsMu.Lock()
+	defer p.execCountsMu.Unlock()
+
+	if limit := p.cfg.SandboxMaxExecs; limit > 0 && p.execCounts[sessionID] >= limit {
+		return nil, fmt.Errorf("%w (limit %d)", sandbox.ErrTooManyExecs, limit)
+	}
+	p.execCounts[sessionID]++
+	p.lastActivity[sessionID] = time.Now()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.execCountsMu.Lock()
+			defer p.execCountsMu.Unlock()
+			p.lastActivity[sessionID] = time.Now()
+			if p.execCounts[sessionID] <= 1 {
+				delete(p.execCounts, sessionID)
+			} else {
+				p.execCounts[sessionID]--
+			}
+		})
 	}, nil
 }
 
+// ActiveExecs returns the number of running commands per session.
+// Implements sandbox.ExecStatsProvider.
+func (p *Provider) ActiveExecs() map[string]int {
+	p.execCountsMu.Lock()
+	defer p.execCountsMu.Unlock()
+	return maps.Clone(p.execCounts)
+}
+
+// touch records that the session's sandbox is in use.
+func (p *Provider) touch(sessionID string) {
+	p.execCountsMu.Lock()
+	defer p.execCountsMu.Unlock()
+	p.lastActivity[sessionID] = time.Now()
+}
+
+// LastActivity returns when each session's sandbox was last used.
+// Implements sandbox.ActivityReporter.
+func (p *Provider) LastActivity() map[string]time.Time {
+	p.execCountsMu.Lock()
+	defer p.execCountsMu.Unlock()
+	return maps.Clone(p.lastActivity)
+}
+
 // List returns all sandboxes managed by discobot.
 func (p *Provider) List(ctx context.Context) ([]*sandbox.Sandbox, error) {
 	// List all containers with our label
@@ -1206,8 +2376,11 @@ func (p *Provider) List(ctx context.Context) ([]*sandbox.Sandbox, error) {
 
 	result := make([]*sandbox.Sandbox, 0, len(containers))
 	for _, c := range containers {
-		// Extract session ID from labels
-		sessionID := c.Labels["discobot.session.id"]
+		var name string
+		if len(c.Names) > 0 {
+			name = c.Names[0]
+		}
+		sessionID := containerSessionID(name, c.Labels)
 		if sessionID == "" {
 			continue
 		}
@@ -1234,13 +2407,17 @@ func (p *Provider) List(ctx context.Context) ([]*sandbox.Sandbox, error) {
 
 		// Determine status
 		switch {
+		case info.State.Paused:
+			// Paused containers also report Running
+			sb.Status = sandbox.StatusPaused
+			if started, err := time.Parse(time.RFC3339Nano, info.State.StartedAt); err == nil {
+				sb.StartedAt = &started
+			}
 		case info.State.Running:
 			sb.Status = sandbox.StatusRunning
 			if started, err := time.Parse(time.RFC3339Nano, info.State.StartedAt); err == nil {
 				sb.StartedAt = &started
 			}
-		case info.State.Paused:
-			sb.Status = sandbox.StatusStopped
 		case info.State.Dead || info.State.OOMKilled:
 			sb.Status = sandbox.StatusFailed
 			sb.Error = info.State.Error
@@ -1333,6 +2510,7 @@ type dockerPTY struct {
 	client    *client.Client
 	execID    string
 	hijacked  types.HijackedResponse
+	release   func() // Frees the provider's exec slot
 	closeOnce sync.Once
 }
 
@@ -1354,6 +2532,7 @@ func (p *dockerPTY) Resize(ctx context.Context, rows, cols int) error {
 func (p *dockerPTY) Close() error {
 	p.closeOnce.Do(func() {
 		p.hijacked.Close()
+		p.release()
 	})
 	return nil
 }
@@ -1386,6 +2565,9 @@ type dockerStream struct {
 	hijacked     types.HijackedResponse
 	stdoutReader *io.PipeReader
 	stderrReader *io.PipeReader
+	release      func()      // Frees the provider's exec slot
+	kill         func()      // Stops the command's processes
+	stopCtx      func() bool // Stops closing the stream when ctx is cancelled
 	closeOnce    sync.Once
 }
 
@@ -1411,7 +2593,18 @@ func (s *dockerStream) CloseWrite() error {
 
 func (s *dockerStream) Close() error {
 	s.closeOnce.Do(func() {
+		if s.stopCtx != nil {
+			s.stopCtx()
+		}
 		s.hijacked.Close()
+
+		// Closing the connection doesn't stop the command
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if inspect, err := s.client.ContainerExecInspect(ctx, s.execID); err == nil && inspect.Running && s.kill != nil {
+			s.kill()
+		}
+		s.release()
 	})
 	return nil
 }
@@ -1471,6 +2664,8 @@ func (p *Provider) HTTPClient(ctx context.Context, sessionID string) (*http.Clie
 	transport := &http.Transport{
 		DisableKeepAlives: true,
 		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			// Keep-alives are off, so every request dials and counts as activity
+			p.touch(sessionID)
 			// Always connect to the sandbox's mapped port, ignoring the addr from the URL
 			var d net.Dialer
 			return d.DialContext(ctx, "tcp", baseURL)
@@ -1480,6 +2675,32 @@ func (p *Provider) HTTPClient(ctx context.Context, sessionID string) (*http.Clie
 	return &http.Client{Transport: transport}, nil
 }
 
+// Dial connects to port inside the session's container through its host
+// port mapping. Implements sandbox.PortDialer.
+func (p *Provider) Dial(ctx context.Context, sessionID string, port int) (net.Conn, error) {
+	sb, err := p.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if sb.Status != sandbox.StatusRunning {
+		return nil, sandbox.ErrNotRunning
+	}
+
+	for _, mapping := range sb.Ports {
+		if mapping.ContainerPort != port || mapping.Protocol != "tcp" {
+			continue
+		}
+		hostIP := mapping.HostIP
+		if hostIP == "" || hostIP == "0.0.0.0" {
+			hostIP = "127.0.0.1"
+		}
+		p.touch(sessionID)
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", net.JoinHostPort(hostIP, strconv.Itoa(mapping.HostPort)))
+	}
+	return nil, fmt.Errorf("sandbox does not expose port %d", port)
+}
+
 // Watch returns a channel that receives sandbox state change events.
 // It first replays the current state of all existing sandboxes, then streams
 // state changes as they occur by watching Docker events.
@@ -1523,6 +2744,32 @@ func (p *Provider) Watch(ctx context.Context) (<-chan sandbox.StateEvent, error)
 	return eventCh, nil
 }
 
+// WaitForStatus waits for the sandbox to reach the target status, following
+// Docker events for its container rather than polling.
+func (p *Provider) WaitForStatus(ctx context.Context, sessionID string, target sandbox.Status, timeout time.Duration) (*sandbox.Sandbox, error) {
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Events since now are delivered even if they happen before the
+	// subscription is established, so nothing between the first Get and the
+	// subscription is missed
+	now := time.Now()
+	msgCh, errCh := p.client.Events(watchCtx, events.ListOptions{
+		Since: fmt.Sprintf("%d.%09d", now.Unix(), now.Nanosecond()),
+		Filters: filters.NewArgs(
+			filters.Arg("type", string(events.ContainerEventType)),
+			filters.Arg("container", containerName(sessionID)),
+		),
+	})
+	eventCh := make(chan sandbox.StateEvent, 16)
+	go func() {
+		defer close(eventCh)
+		p.processDockerEvents(watchCtx, eventCh, msgCh, errCh)
+	}()
+
+	return sandbox.WaitForEvents(ctx, sessionID, target, timeout, p.Get, eventCh)
+}
+
 // watchDockerEvents watches Docker container events and translates them to sandbox events.
 // It automatically reconnects if the connection is lost.
 func (p *Provider) watchDockerEvents(ctx context.Context, eventCh chan<- sandbox.StateEvent, filterArgs filters.Args) {
@@ -1589,8 +2836,8 @@ func (p *Provider) processDockerEvents(ctx context.Context, eventCh chan<- sandb
 // translateDockerEvent converts a Docker event to a sandbox StateEvent.
 // Returns nil if the event should be ignored.
 func (p *Provider) translateDockerEvent(msg events.Message) *sandbox.StateEvent {
-	// Extract session ID from container labels
-	sessionID := msg.Actor.Attributes["discobot.session.id"]
+	// Extract session ID from the container name and labels
+	sessionID := containerSessionID(msg.Actor.Attributes["name"], msg.Actor.Attributes)
 	if sessionID == "" {
 		// Not one of our containers or missing session ID
 		return nil
@@ -1598,12 +2845,15 @@ func (p *Provider) translateDockerEvent(msg events.Message) *sandbox.StateEvent
 
 	var status sandbox.Status
 	var errMsg string
+	var restarting bool
 
 	switch msg.Action {
 	case "create":
 		status = sandbox.StatusCreated
-	case "start":
+	case "start", "unpause":
 		status = sandbox.StatusRunning
+	case "pause":
+		status = sandbox.StatusPaused
 	case "stop", "kill":
 		status = sandbox.StatusStopped
 	case "die":
@@ -1616,6 +2866,7 @@ func (p *Provider) translateDockerEvent(msg events.Message) *sandbox.StateEvent
 			status = sandbox.StatusFailed
 			errMsg = fmt.Sprintf("container died with exit code %s", exitCode)
 		}
+		restarting = p.isRestarting(msg.Actor.ID)
 	case "destroy":
 		status = sandbox.StatusRemoved
 		// Clear container ID from cache since it's been deleted
@@ -1624,14 +2875,29 @@ func (p *Provider) translateDockerEvent(msg events.Message) *sandbox.StateEvent
 		status = sandbox.StatusFailed
 		errMsg = "out of memory"
 	default:
-		// Ignore other events (pause, unpause, attach, etc.)
+		// Ignore other events (attach, exec, etc.)
 		return nil
 	}
 
 	return &sandbox.StateEvent{
-		SessionID: sessionID,
-		Status:    status,
-		Timestamp: time.Unix(msg.Time, msg.TimeNano),
-		Error:     errMsg,
+		SessionID:  sessionID,
+		Status:     status,
+		Timestamp:  time.Unix(msg.Time, msg.TimeNano),
+		Error:      errMsg,
+		Restarting: restarting,
+	}
+}
+
+// isRestarting reports whether the daemon is restarting a container that just
+// died. Docker decides whether to restart before emitting the die event, so
+// the container's state already reflects its restart policy.
+func (p *Provider) isRestarting(containerID string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	info, err := p.client.ContainerInspect(ctx, containerID)
+	if err != nil || info.State == nil {
+		return false
 	}
+	return info.State.Restarting
 }
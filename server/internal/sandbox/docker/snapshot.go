@@ -0,0 +1,529 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	containerTypes "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	imageTypes "github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	volumeTypes "github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/pkg/stdcopy"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+const (
+	// snapshotImagePrefix is the repository prefix for snapshot images.
+	snapshotImagePrefix = "discobot-local/snapshot-"
+
+	// snapshotVolumePrefix is the prefix for snapshot data volume names.
+	snapshotVolumePrefix = "discobot-snapshot-"
+
+	// labelSnapshotSessionID marks a snapshot volume with the SESSION_ID the
+	// snapshotted sandbox ran with. The agent keys its overlayfs and agentfs
+	// data on the volume by it, so those are renamed for the new session.
+	labelSnapshotSessionID = "discobot.snapshot.session-id"
+
+	// labelForkedFrom marks a data volume created by CreateFromSnapshot with
+	// the snapshot it was copied from.
+	labelForkedFrom = "discobot.session.forked-from"
+)
+
+// snapshotIDPattern matches the IDs generated by Snapshot.
+var snapshotIDPattern = regexp.MustCompile(`^[a-f0-9]{24}$`)
+
+// snapshotImage returns the image a snapshot's container filesystem is committed to.
+func snapshotImage(snapshotID string) string {
+	return snapshotImagePrefix + snapshotID
+}
+
+// snapshotVolumeName returns the volume a snapshot's data volume is copied to.
+func snapshotVolumeName(snapshotID string) string {
+	return snapshotVolumePrefix + snapshotID
+}
+
+// copyVolumeScript copies directory $1 into $2, then renames the agent's
+// per-session overlayfs directory and agentfs database from session $3 to $4.
+const copyVolumeScript = `cp -a "$1"/. "$2"/ && cd "$2" && if [ -n "$3" ] && [ "$3" != "$4" ]; then
+	if [ -d ".overlayfs/$3" ]; then mv ".overlayfs/$3" ".overlayfs/$4"; fi
+	for f in .agentfs/"$3".db*; do
+		if [ -e "$f" ]; then mv "$f" ".agentfs/$4${f#.agentfs/$3}"; fi
+	done
+fi`
+
+// Snapshot captures the session's sandbox: its container filesystem (the
+// writable layer on top of the sandbox image) is committed to
+// discobot-local/snapshot-<id>, and its data volume, which holds the
+// workspace and the home directory's overlayfs upper layer, is copied to a
+// snapshot volume. A running sandbox is paused while both are captured so
+// they match. Implements sandbox.Snapshotter.
+func (p *Provider) Snapshot(ctx context.Context, sessionID string) (string, error) {
+	containerID, err := p.getContainerID(ctx, sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := p.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			p.clearContainerID(sessionID)
+			return "", sandbox.ErrNotFound
+		}
+		return "", fmt.Errorf("failed to inspect sandbox: %w", err)
+	}
+
+	dataVolName, agentSessionID := p.dataVolumeOf(sessionID, info)
+
+	if info.State.Running && !info.State.Paused {
+		if err := p.client.ContainerPause(ctx, containerID); err != nil {
+			return "", fmt.Errorf("failed to pause sandbox: %w", err)
+		}
+		defer func() {
+			if err := p.client.ContainerUnpause(context.WithoutCancel(ctx), containerID); err != nil {
+				log.Printf("Warning: failed to unpause sandbox %s after snapshot: %v", sessionID, err)
+			}
+		}()
+	}
+
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate snapshot ID: %w", err)
+	}
+	snapshotID := hex.EncodeToString(buf)
+
+	var base *containerTypes.Config
+	if baseImage, err := p.client.ImageInspect(ctx, info.Image); err == nil && baseImage.Config != nil {
+		base = &containerTypes.Config{Env: baseImage.Config.Env, Labels: baseImage.Config.Labels}
+	} else if err != nil {
+		log.Printf("Warning: failed to inspect base image of sandbox %s: %v", sessionID, err)
+	}
+	cfg := commitConfig(info.Config, base, sessionID)
+	cfg.Labels["io.discobot.snapshot-id"] = snapshotID
+
+	if _, err := p.client.ContainerCommit(ctx, containerID, containerTypes.CommitOptions{
+		Reference: snapshotImage(snapshotID),
+		Comment:   "Snapshot of discobot session " + sessionID,
+		Pause:     false, // Already paused above if running
+		Config:    cfg,
+	}); err != nil {
+		return "", fmt.Errorf("failed to commit sandbox: %w", err)
+	}
+
+	snapVolName := snapshotVolumeName(snapshotID)
+	if _, err := p.client.VolumeCreate(ctx, volumeTypes.CreateOptions{
+		Name: snapVolName,
+		Labels: map[string]string{
+			"discobot.managed":     "true",
+			"discobot.type":        "snapshot",
+			"discobot.session.id":  sessionID,
+			labelSnapshotSessionID: agentSessionID,
+		},
+	}); err != nil {
+		_ = p.DeleteSnapshot(context.WithoutCancel(ctx), snapshotID)
+		return "", fmt.Errorf("failed to create snapshot volume: %w", err)
+	}
+
+	if err := p.copyVolume(ctx, snapshotImage(snapshotID), dataVolName, snapVolName, "", ""); err != nil {
+		_ = p.DeleteSnapshot(context.WithoutCancel(ctx), snapshotID)
+		return "", fmt.Errorf("failed to copy data volume: %w", err)
+	}
+
+	return snapshotID, nil
+}
+
+// CreateFromSnapshot creates newSessionID's container from the snapshot
+// image, with a data volume copied from the snapshot's. The agent's
+// per-session overlayfs and agentfs data are renamed for newSessionID, so
+// the new sandbox boots with the snapshotted home directory and workspace.
+// Implements sandbox.Snapshotter.
+func (p *Provider) CreateFromSnapshot(ctx context.Context, newSessionID, snapshotID string, opts sandbox.CreateOptions) (*sandbox.Sandbox, error) {
+	if !snapshotIDPattern.MatchString(snapshotID) {
+		return nil, fmt.Errorf("%w: invalid snapshot ID %q", sandbox.ErrNotFound, snapshotID)
+	}
+	image := snapshotImage(snapshotID)
+	if _, err := p.client.ImageInspect(ctx, image); err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: snapshot %s", sandbox.ErrNotFound, snapshotID)
+		}
+		return nil, fmt.Errorf("failed to inspect snapshot image: %w", err)
+	}
+	snapVol, err := p.client.VolumeInspect(ctx, snapshotVolumeName(snapshotID))
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: snapshot %s", sandbox.ErrNotFound, snapshotID)
+		}
+		return nil, fmt.Errorf("failed to inspect snapshot volume: %w", err)
+	}
+
+	dataVolName := volumeName(newSessionID)
+	if _, err := p.client.VolumeInspect(ctx, dataVolName); err == nil {
+		return nil, fmt.Errorf("%w: volume %s already exists", sandbox.ErrAlreadyExists, dataVolName)
+	} else if !cerrdefs.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to check for volume %s: %w", dataVolName, err)
+	}
+	if _, err := p.client.VolumeCreate(ctx, volumeTypes.CreateOptions{
+		Name: dataVolName,
+		Labels: map[string]string{
+			"discobot.session.id": newSessionID,
+			"discobot.managed":    "true",
+			labelForkedFrom:       snapshotID,
+		},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create data volume: %w", err)
+	}
+
+	if err := p.copyVolume(ctx, image, snapVol.Name, dataVolName, snapVol.Labels[labelSnapshotSessionID], newSessionID); err != nil {
+		_ = p.client.VolumeRemove(context.WithoutCancel(ctx), dataVolName, true)
+		return nil, fmt.Errorf("failed to copy snapshot volume: %w", err)
+	}
+
+	sb, err := p.create(ctx, newSessionID, image, opts)
+	if err != nil {
+		_ = p.client.VolumeRemove(context.WithoutCancel(ctx), dataVolName, true)
+		return nil, err
+	}
+	return sb, nil
+}
+
+// DeleteSnapshot removes a snapshot's volume and image. The image is kept
+// while sandboxes or DiffSnapshots helpers created from it still exist,
+// since their containers use it; removing the last of them deletes it.
+// Implements sandbox.Snapshotter.
+func (p *Provider) DeleteSnapshot(ctx context.Context, snapshotID string) error {
+	if !snapshotIDPattern.MatchString(snapshotID) {
+		return fmt.Errorf("%w: invalid snapshot ID %q", sandbox.ErrNotFound, snapshotID)
+	}
+
+	if err := p.client.VolumeRemove(ctx, snapshotVolumeName(snapshotID), true); err != nil && !cerrdefs.IsNotFound(err) {
+		return fmt.Errorf("failed to remove snapshot volume: %w", err)
+	}
+
+	if _, err := p.client.ImageRemove(ctx, snapshotImage(snapshotID), imageTypes.RemoveOptions{PruneChildren: true}); err != nil {
+		switch {
+		case cerrdefs.IsNotFound(err):
+		case cerrdefs.IsConflict(err):
+			log.Printf("Keeping snapshot image %s until the sandboxes created from it are removed", snapshotImage(snapshotID))
+		default:
+			return fmt.Errorf("failed to remove snapshot image: %w", err)
+		}
+	}
+	return nil
+}
+
+// removeSnapshotImage removes image, the image a removed sandbox or diff
+// helper was created from, if it is a snapshot image whose snapshot was deleted and no other
+// sandbox uses it.
+func (p *Provider) removeSnapshotImage(ctx context.Context, image string) {
+	snapshotID, ok := strings.CutPrefix(image, snapshotImagePrefix)
+	if !ok || !snapshotIDPattern.MatchString(snapshotID) {
+		return
+	}
+	if _, err := p.client.VolumeInspect(ctx, snapshotVolumeName(snapshotID)); err == nil {
+		// The snapshot still exists; DeleteSnapshot removes the image
+		return
+	}
+
+	if _, err := p.client.ImageRemove(ctx, image, imageTypes.RemoveOptions{PruneChildren: true}); err != nil &&
+		!cerrdefs.IsNotFound(err) && !cerrdefs.IsConflict(err) {
+		log.Printf("Warning: failed to remove snapshot image %s: %v", image, err)
+	}
+}
+
+// dataVolumeOf returns the data volume a session's container mounts and the
+// SESSION_ID it runs with, which the agent keys its per-session data on.
+// Renamed containers keep mounting their original session's volume.
+func (p *Provider) dataVolumeOf(sessionID string, info containerTypes.InspectResponse) (volName, agentSessionID string) {
+	volName = volumeName(sessionID)
+	for _, m := range info.Mounts {
+		if m.Destination == dataVolumePath && m.Name != "" {
+			volName = m.Name
+		}
+	}
+	return volName, p.extractEnv(info.Config.Env)["SESSION_ID"]
+}
+
+// ListSnapshots returns the snapshots taken of the session's sandbox that
+// haven't been deleted, oldest first. Implements sandbox.SnapshotDiffer.
+func (p *Provider) ListSnapshots(ctx context.Context, sessionID string) ([]sandbox.SnapshotInfo, error) {
+	resp, err := p.client.VolumeList(ctx, volumeTypes.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", "discobot.type=snapshot"),
+			filters.Arg("label", "discobot.session.id="+sessionID),
+		),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshot volumes: %w", err)
+	}
+
+	snapshots := []sandbox.SnapshotInfo{}
+	for _, vol := range resp.Volumes {
+		id, ok := strings.CutPrefix(vol.Name, snapshotVolumePrefix)
+		if !ok || !snapshotIDPattern.MatchString(id) {
+			continue
+		}
+		createdAt, _ := time.Parse(time.RFC3339, vol.CreatedAt)
+		snapshots = append(snapshots, sandbox.SnapshotInfo{ID: id, CreatedAt: createdAt})
+	}
+	slices.SortFunc(snapshots, func(a, b sandbox.SnapshotInfo) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// snapshotDiffScript writes a unified diff of the workspace on data volume
+// $1 (agent session $2) to the one on data volume $3 (agent session $4),
+// using $5 as scratch space. Each side's home directory is rebuilt
+// read-only by stacking the agent's overlayfs upper layer on the base, then
+// its workspace is staged into a scratch index and written as a tree, so
+// the diff is git's between two trees and skips .gitignored files. New
+// objects go to the scratch directory; the volumes are never written.
+// Sessions using agentfs keep their changes in a database rather than an
+// upper layer, so the script exits with snapshotDiffAgentFSExitCode for them.
+//
+// The workspaces are the user's, so only the mounts run as root: git runs as
+// user $6 (if set) without privileges, and against scratch repositories
+// rather than the workspaces' own, so their config can't name fsmonitor
+// commands, hooks or filter drivers for it to run. System and global config
+// are ignored too. Only each side's info/exclude is carried over.
+const snapshotDiffScript = `set -e
+work="$5"
+user="$6"
+if [ -e "$1/.agentfs/$2.db" ] || [ -e "$3/.agentfs/$4.db" ]; then
+	echo "workspace is stored in agentfs" >&2
+	exit 4
+fi
+trap 'umount "$work/a" "$work/b" 2>/dev/null || true' EXIT
+view() {
+	mkdir -p "$3"
+	if [ -d "$1/.overlayfs/$2/upper" ]; then
+		mount -t overlay overlay -o "ro,lowerdir=$1/.overlayfs/$2/upper:$1/discobot" "$3"
+	else
+		mount --bind "$1/discobot" "$3"
+	fi
+}
+unprivileged() {
+	if [ -n "$user" ]; then
+		setpriv --reuid="$user" --regid="$(id -g "$user")" --clear-groups --bounding-set=-all --no-new-privs "$@"
+	else
+		"$@"
+	fi
+}
+export GIT_CONFIG_NOSYSTEM=1 GIT_CONFIG_GLOBAL=/dev/null
+g() {
+	unprivileged git -c safe.directory='*' -c core.fsmonitor= -c core.hooksPath=/dev/null "$@"
+}
+tree() {
+	g init -q --template= "$work/git-$1"
+	if [ -f "$work/$1/workspace/.git/info/exclude" ]; then
+		unprivileged mkdir -p "$work/git-$1/.git/info"
+		cat "$work/$1/workspace/.git/info/exclude" | unprivileged tee "$work/git-$1/.git/info/exclude" >/dev/null
+	fi
+	GIT_INDEX_FILE="$work/index-$1" g --git-dir="$work/git-$1/.git" --work-tree="$work/$1/workspace" add -A
+	GIT_INDEX_FILE="$work/index-$1" g --git-dir="$work/git-$1/.git" write-tree
+}
+view "$1" "$2" "$work/a"
+view "$3" "$4" "$work/b"
+mkdir -p "$work/objects"
+if [ -n "$user" ]; then
+	chown "$user" "$work" "$work/objects"
+fi
+export GIT_OBJECT_DIRECTORY="$work/objects"
+export GIT_ALTERNATE_OBJECT_DIRECTORIES="$work/a/workspace/.git/objects:$work/b/workspace/.git/objects"
+from=$(tree a)
+to=$(tree b)
+g --git-dir="$work/git-b/.git" diff --no-color --no-ext-diff --no-textconv "$from" "$to"`
+
+// snapshotDiffUser is the sandbox image's user, who owns the workspaces and
+// runs git in DiffSnapshots' helper container.
+const snapshotDiffUser = "discobot"
+
+// snapshotDiffAgentFSExitCode is snapshotDiffScript's exit code when either
+// side's workspace is stored in agentfs.
+const snapshotDiffAgentFSExitCode = 4
+
+// snapshotVolume returns the data volume of one of the session's snapshots
+// and the SESSION_ID the snapshotted sandbox ran with.
+func (p *Provider) snapshotVolume(ctx context.Context, sessionID, snapshotID string) (volName, agentSessionID string, err error) {
+	if !snapshotIDPattern.MatchString(snapshotID) {
+		return "", "", fmt.Errorf("%w: invalid snapshot ID %q", sandbox.ErrNotFound, snapshotID)
+	}
+	vol, err := p.client.VolumeInspect(ctx, snapshotVolumeName(snapshotID))
+	if err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return "", "", fmt.Errorf("%w: snapshot %s", sandbox.ErrNotFound, snapshotID)
+		}
+		return "", "", fmt.Errorf("failed to inspect snapshot volume: %w", err)
+	}
+	if vol.Labels["discobot.session.id"] != sessionID {
+		return "", "", fmt.Errorf("%w: snapshot %s", sandbox.ErrNotFound, snapshotID)
+	}
+	return vol.Name, vol.Labels[labelSnapshotSessionID], nil
+}
+
+// DiffSnapshots streams a unified diff of the session's workspace between
+// two of its snapshots, or from a snapshot to the sandbox's data volume if
+// toID is empty. A helper container from the first snapshot's image mounts
+// both volumes read-only and runs snapshotDiffScript; it needs CAP_SYS_ADMIN
+// for the overlay mounts, like the sandbox itself, but has no network and
+// runs git as snapshotDiffUser. The container is removed when the returned
+// reader is closed. Implements sandbox.SnapshotDiffer.
+func (p *Provider) DiffSnapshots(ctx context.Context, sessionID, fromID, toID string) (io.ReadCloser, error) {
+	fromVol, fromAgentSessionID, err := p.snapshotVolume(ctx, sessionID, fromID)
+	if err != nil {
+		return nil, err
+	}
+
+	var toVol, toAgentSessionID string
+	if toID != "" {
+		toVol, toAgentSessionID, err = p.snapshotVolume(ctx, sessionID, toID)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		containerID, err := p.getContainerID(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		info, err := p.client.ContainerInspect(ctx, containerID)
+		if err != nil {
+			if cerrdefs.IsNotFound(err) {
+				p.clearContainerID(sessionID)
+				return nil, sandbox.ErrNotFound
+			}
+			return nil, fmt.Errorf("failed to inspect sandbox: %w", err)
+		}
+		toVol, toAgentSessionID = p.dataVolumeOf(sessionID, info)
+	}
+
+	image := snapshotImage(fromID)
+	if _, err := p.client.ImageInspect(ctx, image); err != nil {
+		if cerrdefs.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: snapshot %s", sandbox.ErrNotFound, fromID)
+		}
+		return nil, fmt.Errorf("failed to inspect snapshot image: %w", err)
+	}
+
+	resp, err := p.client.ContainerCreate(ctx, &containerTypes.Config{
+		Image:      image,
+		User:       "root",
+		Entrypoint: []string{"/bin/sh", "-c"},
+		Cmd:        []string{snapshotDiffScript, "sh", "/from", fromAgentSessionID, "/to", toAgentSessionID, "/tmp/diff", snapshotDiffUser},
+		Labels:     map[string]string{"discobot.type": "snapshot-diff"},
+	}, &containerTypes.HostConfig{
+		NetworkMode: "none",
+		Mounts: []mount.Mount{
+			{Type: mount.TypeVolume, Source: fromVol, Target: "/from", ReadOnly: true},
+			{Type: mount.TypeVolume, Source: toVol, Target: "/to", ReadOnly: true},
+		},
+		CapAdd: []string{"SYS_ADMIN"},
+	}, nil, p.platform, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create diff container: %w", err)
+	}
+	remove := func() {
+		ctx := context.WithoutCancel(ctx)
+		_ = p.client.ContainerRemove(ctx, resp.ID, containerTypes.RemoveOptions{Force: true})
+		// The snapshot may have been deleted while the diff was running
+		p.removeSnapshotImage(ctx, image)
+	}
+
+	if err := p.client.ContainerStart(ctx, resp.ID, containerTypes.StartOptions{}); err != nil {
+		remove()
+		return nil, fmt.Errorf("failed to start diff container: %w", err)
+	}
+	raw, err := p.client.ContainerLogs(ctx, resp.ID, containerTypes.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+	})
+	if err != nil {
+		remove()
+		return nil, fmt.Errorf("failed to read diff output: %w", err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		var stderr bytes.Buffer
+		_, err := stdcopy.StdCopy(pw, &stderr, raw)
+		if err == nil {
+			statusCh, errCh := p.client.ContainerWait(ctx, resp.ID, containerTypes.WaitConditionNotRunning)
+			select {
+			case err = <-errCh:
+			case status := <-statusCh:
+				switch status.StatusCode {
+				case 0:
+				case snapshotDiffAgentFSExitCode:
+					err = fmt.Errorf("%w: snapshot diffs don't support agentfs workspaces", sandbox.ErrNotSupported)
+				default:
+					err = fmt.Errorf("diff exited with code %d: %s", status.StatusCode, strings.TrimSpace(stderr.String()))
+				}
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	return &snapshotDiff{demuxedLogs: demuxedLogs{PipeReader: pr, raw: raw}, remove: remove}, nil
+}
+
+// snapshotDiff is the output of a DiffSnapshots helper container. Closing it
+// stops the stream and removes the container.
+type snapshotDiff struct {
+	demuxedLogs
+	remove func()
+}
+
+func (d *snapshotDiff) Close() error {
+	err := d.demuxedLogs.Close()
+	d.remove()
+	return err
+}
+
+// copyVolume copies volume src into dst with a short-lived container from
+// image (which must have a shell and cp), renaming the agent's per-session
+// data from fromSessionID to toSessionID if both are set.
+func (p *Provider) copyVolume(ctx context.Context, image, src, dst, fromSessionID, toSessionID string) error {
+	resp, err := p.client.ContainerCreate(ctx, &containerTypes.Config{
+		Image:      image,
+		User:       "root",
+		Entrypoint: []string{"/bin/sh", "-c"},
+		Cmd:        []string{copyVolumeScript, "sh", "/from", "/to", fromSessionID, toSessionID},
+		Labels:     map[string]string{"discobot.type": "volume-copy"},
+	}, &containerTypes.HostConfig{
+		Mounts: []mount.Mount{
+			{Type: mount.TypeVolume, Source: src, Target: "/from", ReadOnly: true},
+			{Type: mount.TypeVolume, Source: dst, Target: "/to"},
+		},
+	}, nil, p.platform, "")
+	if err != nil {
+		return fmt.Errorf("failed to create copy container: %w", err)
+	}
+	defer func() {
+		_ = p.client.ContainerRemove(context.WithoutCancel(ctx), resp.ID, containerTypes.RemoveOptions{Force: true})
+	}()
+
+	if err := p.client.ContainerStart(ctx, resp.ID, containerTypes.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start copy container: %w", err)
+	}
+
+	statusCh, errCh := p.client.ContainerWait(ctx, resp.ID, containerTypes.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to wait for copy container: %w", err)
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return fmt.Errorf("copy exited with code %d", status.StatusCode)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+package sandbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DockerDialFunc opens a connection to a Docker daemon (e.g., over VSOCK into a VM).
+type DockerDialFunc func(ctx context.Context) (net.Conn, error)
+
+// DockerSocketProxy forwards connections on a host Unix socket to a Docker
+// daemon reached through a DockerDialFunc, so the standard Docker CLI can be
+// pointed at a sandbox runtime with DOCKER_HOST=unix://<socket>.
+//
+// Each connection is copied in both directions until either side finishes,
+// at which point both sides are closed. With a DockerSocketScope, requests
+// are read one at a time and checked against the scope instead. Close stops
+// accepting, closes every active connection, and removes the socket file.
+type DockerSocketProxy struct {
+	socketPath string
+	listener   net.Listener
+	dial       DockerDialFunc
+
+	// scope limits what the socket can reach (nil = the whole daemon), with
+	// client for its own lookups
+	scope  *DockerSocketScope
+	client *http.Client
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+
+	done      chan struct{}
+	err       error
+	closeOnce sync.Once
+}
+
+// NewDockerSocketProxy listens on socketPath and starts forwarding connections,
+// limited to scope if it is non-nil. A stale socket file at socketPath is
+// replaced. The socket is only accessible to the current user.
+func NewDockerSocketProxy(socketPath string, dial DockerDialFunc, scope *DockerSocketScope) (*DockerSocketProxy, error) {
+	listener, err := listenPrivate(socketPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &DockerSocketProxy{
+		socketPath: socketPath,
+		listener:   listener,
+		dial:       dial,
+		scope:      scope,
+		client:     daemonClient(dial),
+		ctx:        ctx,
+		cancel:     cancel,
+		conns:      make(map[net.Conn]struct{}),
+		done:       make(chan struct{}),
+	}
+	go p.acceptLoop()
+	return p, nil
+}
+
+// listenPrivate listens on a Unix socket at socketPath that only the current
+// user can connect to. The socket is created and restricted in a private
+// directory and then moved into place, so there is no moment where others
+// can connect to it.
+func listenPrivate(socketPath string) (net.Listener, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(socketPath), ".dsp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tmpPath := filepath.Join(dir, "s")
+	listener, err := net.Listen("unix", tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Unix socket: %w", err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmpPath, 0600); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to chmod socket: %w", err)
+	}
+	// Rename replaces a stale socket file at socketPath
+	if err := os.Rename(tmpPath, socketPath); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to move socket to %s: %w", socketPath, err)
+	}
+	return listener, nil
+}
+
+// SocketPath returns the Unix socket path the proxy listens on.
+func (p *DockerSocketProxy) SocketPath() string {
+	return p.socketPath
+}
+
+// Done returns a channel that is closed when the proxy stops, either via
+// Close or because the listener failed.
+func (p *DockerSocketProxy) Done() <-chan struct{} {
+	return p.done
+}
+
+// Err returns the error that stopped the proxy, or nil if it was closed
+// normally or is still running.
+func (p *DockerSocketProxy) Err() error {
+	select {
+	case <-p.done:
+		return p.err
+	default:
+		return nil
+	}
+}
+
+// ActiveConns returns the number of connections currently being forwarded.
+func (p *DockerSocketProxy) ActiveConns() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.conns)
+}
+
+// Close stops the proxy and waits for all connections to be torn down.
+func (p *DockerSocketProxy) Close() error {
+	p.stop(nil)
+	p.wg.Wait()
+	return nil
+}
+
+// stop shuts down the listener and all active connections, recording err as
+// the reason the proxy stopped.
+func (p *DockerSocketProxy) stop(err error) {
+	p.closeOnce.Do(func() {
+		p.err = err
+		p.cancel()
+		_ = p.listener.Close()
+		_ = os.Remove(p.socketPath)
+
+		p.mu.Lock()
+		for conn := range p.conns {
+			_ = conn.Close()
+		}
+		p.mu.Unlock()
+
+		close(p.done)
+	})
+}
+
+func (p *DockerSocketProxy) acceptLoop() {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			if p.ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				log.Printf("Docker socket proxy %s stopped: %v", p.socketPath, err)
+				p.stop(err)
+			}
+			return
+		}
+		if !p.track(conn) {
+			_ = conn.Close()
+			return
+		}
+		p.wg.Add(1)
+		go p.handle(conn)
+	}
+}
+
+// track registers conn so Close can reach it. Returns false if the proxy is stopping.
+func (p *DockerSocketProxy) track(conn net.Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ctx.Err() != nil {
+		return false
+	}
+	p.conns[conn] = struct{}{}
+	return true
+}
+
+func (p *DockerSocketProxy) untrack(conn net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.conns[conn]; ok {
+		delete(p.conns, conn)
+		_ = conn.Close()
+	}
+}
+
+// handle forwards one client connection to the Docker daemon.
+func (p *DockerSocketProxy) handle(clientConn net.Conn) {
+	defer p.wg.Done()
+	defer p.untrack(clientConn)
+
+	daemonConn, err := p.dial(p.ctx)
+	if err != nil {
+		log.Printf("Docker socket proxy %s: failed to connect to Docker daemon: %v", p.socketPath, err)
+		return
+	}
+	if !p.track(daemonConn) {
+		_ = daemonConn.Close()
+		return
+	}
+	defer p.untrack(daemonConn)
+
+	if p.scope != nil {
+		p.forwardScoped(clientConn, daemonConn)
+		return
+	}
+	splice(clientConn, daemonConn, clientConn, daemonConn)
+}
+
+// splice copies between the client and daemon in both directions, reading
+// from clientR and daemonR, until either side finishes.
+func splice(clientConn, daemonConn net.Conn, clientR, daemonR io.Reader) {
+	// Closing both sides as soon as either copy finishes unblocks the other,
+	// so a peer that hangs can't leak the remaining goroutine.
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(daemonConn, clientR)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(clientConn, daemonR)
+		done <- struct{}{}
+	}()
+
+	<-done
+	_ = clientConn.Close()
+	_ = daemonConn.Close()
+	<-done
+}
+
+// forwardScoped forwards HTTP requests one at a time, refusing those outside
+// the scope with 403. Once the daemon switches protocols (attach, exec) the
+// connection is spliced as-is.
+func (p *DockerSocketProxy) forwardScoped(clientConn, daemonConn net.Conn) {
+	clientR := bufio.NewReader(clientConn)
+	daemonR := bufio.NewReader(daemonConn)
+	for {
+		req, err := http.ReadRequest(clientR)
+		if err != nil {
+			return
+		}
+
+		check := p.scope.check(p.ctx, p.client, req)
+		if check.denied != "" {
+			_, _ = io.Copy(io.Discard, req.Body)
+			body, _ := json.Marshal(map[string]string{"message": check.denied})
+			resp := &http.Response{
+				StatusCode:    http.StatusForbidden,
+				ProtoMajor:    1,
+				ProtoMinor:    1,
+				Header:        http.Header{"Content-Type": {"application/json"}},
+				Body:          io.NopCloser(bytes.NewReader(body)),
+				ContentLength: int64(len(body)),
+				Close:         req.Close,
+			}
+			if resp.Write(clientConn) != nil || req.Close {
+				return
+			}
+			continue
+		}
+
+		if err := req.Write(daemonConn); err != nil {
+			return
+		}
+		resp, err := http.ReadResponse(daemonR, req)
+		if err != nil {
+			return
+		}
+		if check.filterList {
+			if err := p.scope.filterList(resp); err != nil {
+				return
+			}
+		}
+		if err := resp.Write(clientConn); err != nil {
+			return
+		}
+		if resp.StatusCode == http.StatusSwitchingProtocols {
+			splice(clientConn, daemonConn, clientR, daemonR)
+			return
+		}
+		if req.Close || resp.Close {
+			return
+		}
+	}
+}
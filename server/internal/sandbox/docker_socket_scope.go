@@ -0,0 +1,304 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DockerSocketSessionLabel marks containers created through a session-scoped
+// Docker socket with the session's ID.
+const DockerSocketSessionLabel = "discobot.docker-socket.session"
+
+// DockerSocketScope limits a forwarded Docker socket to one session: only its
+// sandbox (labelled discobot.session.id) and the containers created through
+// the socket (labelled DockerSocketSessionLabel) can be listed, inspected or
+// operated on. Images can be pulled, built and inspected, but not removed.
+// Creating containers that bind host paths, share another container's
+// namespaces or volumes, or gain privileges is refused, as is anything else,
+// such as volumes, networks and system-wide endpoints.
+//
+// This keeps one session from reaching the other sessions' sandboxes in a
+// shared project VM. It is not a full sandbox for the VM's daemon.
+type DockerSocketScope struct {
+	SessionID string
+}
+
+// errDockerNotFound is returned by getJSON when the daemon has no such object.
+var errDockerNotFound = errors.New("not found")
+
+// dockerAPIPrefix matches the optional /vX.Y version prefix of API paths.
+var dockerAPIPrefix = regexp.MustCompile(`^/v[0-9]+(\.[0-9]+)?`)
+
+// dockerScopeCheck is the outcome of checking a request against a scope.
+type dockerScopeCheck struct {
+	denied string // reason to refuse the request, if set
+
+	// filterList means the response is a container list to filter to the
+	// scope
+	filterList bool
+}
+
+// check decides whether req may be forwarded, rewriting container creation
+// to label the container with the session.
+func (s *DockerSocketScope) check(ctx context.Context, client *http.Client, req *http.Request) dockerScopeCheck {
+	path := dockerAPIPrefix.ReplaceAllString(req.URL.Path, "")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case path == "/_ping" || path == "/version" || path == "/info":
+		return dockerScopeCheck{}
+
+	case parts[0] == "images":
+		if req.Method == http.MethodDelete || path == "/images/prune" {
+			return dockerScopeCheck{denied: "removing images is not allowed"}
+		}
+		return dockerScopeCheck{}
+
+	case path == "/build" || path == "/auth" || parts[0] == "distribution":
+		return dockerScopeCheck{}
+
+	case path == "/containers/json":
+		return dockerScopeCheck{filterList: true}
+
+	case path == "/containers/create":
+		if err := s.labelCreate(ctx, client, req); err != nil {
+			return dockerScopeCheck{denied: err.Error()}
+		}
+		return dockerScopeCheck{}
+
+	case parts[0] == "containers" && len(parts) >= 2 && parts[1] != "prune":
+		if !s.containerInScope(ctx, client, parts[1]) {
+			return dockerScopeCheck{denied: "container " + parts[1] + " is not in this session"}
+		}
+		return dockerScopeCheck{}
+
+	case parts[0] == "exec" && len(parts) >= 2:
+		var exec struct {
+			ContainerID string
+		}
+		if err := s.getJSON(ctx, client, "/exec/"+url.PathEscape(parts[1])+"/json", &exec); err != nil ||
+			!s.containerInScope(ctx, client, exec.ContainerID) {
+			return dockerScopeCheck{denied: "exec " + parts[1] + " is not in this session"}
+		}
+		return dockerScopeCheck{}
+	}
+
+	return dockerScopeCheck{denied: path + " is not available on a session Docker socket"}
+}
+
+// inScope reports whether a container with labels belongs to the session.
+func (s *DockerSocketScope) inScope(labels map[string]string) bool {
+	return labels["discobot.session.id"] == s.SessionID || labels[DockerSocketSessionLabel] == s.SessionID
+}
+
+// containerInScope inspects the container and reports whether it belongs to
+// the session.
+func (s *DockerSocketScope) containerInScope(ctx context.Context, client *http.Client, id string) bool {
+	var container struct {
+		Config struct {
+			Labels map[string]string
+		}
+	}
+	if err := s.getJSON(ctx, client, "/containers/"+url.PathEscape(id)+"/json", &container); err != nil {
+		return false
+	}
+	return s.inScope(container.Config.Labels)
+}
+
+// volumeAllowed reports whether a named volume may be mounted: any volume
+// except discobot's own volumes for other sessions. Volumes that don't exist
+// yet are created by the daemon and are allowed.
+func (s *DockerSocketScope) volumeAllowed(ctx context.Context, client *http.Client, name string) bool {
+	if name == "" {
+		return true
+	}
+	var volume struct {
+		Labels map[string]string
+	}
+	if err := s.getJSON(ctx, client, "/volumes/"+url.PathEscape(name), &volume); err != nil {
+		return errors.Is(err, errDockerNotFound)
+	}
+	return volume.Labels["discobot.managed"] != "true" || s.inScope(volume.Labels)
+}
+
+// labelCreate adds the session label to a container create request and
+// refuses host configuration that would reach outside the session.
+func (s *DockerSocketScope) labelCreate(ctx context.Context, client *http.Client, req *http.Request) error {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read request: %v", err)
+	}
+	// Decode the fields the way the daemon does, matching keys without regard
+	// to case, so the checks see what it will
+	var config struct {
+		Labels     map[string]string
+		HostConfig struct {
+			Privileged  bool
+			Binds       []string
+			Mounts      []struct{ Type, Source string }
+			VolumesFrom []string
+			Devices     []any
+			CapAdd      []string
+			NetworkMode string
+			PidMode     string
+			IpcMode     string
+			UsernsMode  string
+		}
+	}
+	var create map[string]any
+	if err := json.Unmarshal(body, &create); err != nil {
+		return fmt.Errorf("invalid container config: %v", err)
+	}
+	if err := json.Unmarshal(body, &config); err != nil {
+		return fmt.Errorf("invalid container config: %v", err)
+	}
+	hostConfig := config.HostConfig
+
+	switch {
+	case hostConfig.Privileged:
+		return fmt.Errorf("privileged containers are not allowed")
+	case len(hostConfig.Devices) > 0 || len(hostConfig.CapAdd) > 0:
+		return fmt.Errorf("devices and added capabilities are not allowed")
+	case len(hostConfig.VolumesFrom) > 0:
+		return fmt.Errorf("volumes from other containers are not allowed")
+	case hostConfig.UsernsMode == "host":
+		return fmt.Errorf("the host user namespace is not allowed")
+	}
+	var volumes []string
+	for _, bind := range hostConfig.Binds {
+		// Only named volumes, not host paths
+		source, _, _ := strings.Cut(bind, ":")
+		if strings.HasPrefix(source, "/") {
+			return fmt.Errorf("bind mounts are not allowed")
+		}
+		volumes = append(volumes, source)
+	}
+	for _, mount := range hostConfig.Mounts {
+		switch mount.Type {
+		case "volume":
+			volumes = append(volumes, mount.Source)
+		case "tmpfs":
+		default:
+			return fmt.Errorf("%s mounts are not allowed", mount.Type)
+		}
+	}
+	for _, name := range volumes {
+		if !s.volumeAllowed(ctx, client, name) {
+			return fmt.Errorf("volume %s belongs to another session", name)
+		}
+	}
+	for _, mode := range []string{hostConfig.NetworkMode, hostConfig.PidMode, hostConfig.IpcMode} {
+		if mode == "host" {
+			return fmt.Errorf("host namespaces are not allowed")
+		}
+		if id, ok := strings.CutPrefix(mode, "container:"); ok && !s.containerInScope(ctx, client, id) {
+			return fmt.Errorf("container %s is not in this session", id)
+		}
+	}
+
+	// discobot's own labels are reserved, so a container can't pass for a
+	// sandbox or claim another session
+	labels := make(map[string]string)
+	for k, v := range config.Labels {
+		if !strings.HasPrefix(k, "discobot.") {
+			labels[k] = v
+		}
+	}
+	labels[DockerSocketSessionLabel] = s.SessionID
+	for k := range create {
+		if strings.EqualFold(k, "Labels") {
+			delete(create, k)
+		}
+	}
+	create["Labels"] = labels
+
+	body, err = json.Marshal(create)
+	if err != nil {
+		return fmt.Errorf("failed to encode container config: %v", err)
+	}
+	setBody(req.Header, &req.Body, &req.ContentLength, body)
+	req.TransferEncoding = nil
+	return nil
+}
+
+// filterList drops containers outside the session from a container list
+// response.
+func (s *DockerSocketScope) filterList(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	var containers []json.RawMessage
+	if err := json.Unmarshal(body, &containers); err != nil {
+		return err
+	}
+	kept := []json.RawMessage{}
+	for _, raw := range containers {
+		var container struct {
+			Labels map[string]string
+		}
+		if json.Unmarshal(raw, &container) == nil && s.inScope(container.Labels) {
+			kept = append(kept, raw)
+		}
+	}
+	body, err = json.Marshal(kept)
+	if err != nil {
+		return err
+	}
+	setBody(resp.Header, &resp.Body, &resp.ContentLength, body)
+	resp.TransferEncoding = nil
+	return nil
+}
+
+// getJSON fetches a daemon API path and decodes the JSON response.
+func (s *DockerSocketScope) getJSON(ctx context.Context, client *http.Client, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", errDockerNotFound, path)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// setBody replaces a request or response body with body.
+func setBody(header http.Header, rc *io.ReadCloser, contentLength *int64, body []byte) {
+	*rc = io.NopCloser(bytes.NewReader(body))
+	*contentLength = int64(len(body))
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+}
+
+// daemonClient returns an HTTP client for the scope's own lookups, dialing
+// the daemon through dial.
+func daemonClient(dial DockerDialFunc) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dial(ctx)
+			},
+		},
+	}
+}
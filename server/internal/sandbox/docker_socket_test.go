@@ -0,0 +1,273 @@
+package sandbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startEchoDaemon starts a TCP server standing in for the Docker daemon. It
+// echoes lines back and reports each accepted connection on the returned channel.
+func startEchoDaemon(t *testing.T) (DockerDialFunc, <-chan net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	accepted := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+			go func() {
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					_, _ = conn.Write(append(scanner.Bytes(), '\n'))
+				}
+			}()
+		}
+	}()
+
+	dial := func(ctx context.Context) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", ln.Addr().String())
+	}
+	return dial, accepted
+}
+
+// testSocketPath returns a short socket path (Unix sockets are limited to ~104 bytes).
+func testSocketPath(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "dsp")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return filepath.Join(dir, "docker.sock")
+}
+
+func TestDockerSocketProxy_Forwards(t *testing.T) {
+	dial, _ := startEchoDaemon(t)
+	socketPath := testSocketPath(t)
+
+	proxy, err := NewDockerSocketProxy(socketPath, dial, nil)
+	if err != nil {
+		t.Fatalf("NewDockerSocketProxy failed: %v", err)
+	}
+	defer proxy.Close()
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("socket not created: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("expected socket mode 0600, got %o", perm)
+	}
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("failed to dial proxy: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ping\n")); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Fatalf("expected echo, got %q, %v", line, err)
+	}
+}
+
+func TestDockerSocketProxy_DaemonCloseClosesClient(t *testing.T) {
+	dial, accepted := startEchoDaemon(t)
+	proxy, err := NewDockerSocketProxy(testSocketPath(t), dial, nil)
+	if err != nil {
+		t.Fatalf("NewDockerSocketProxy failed: %v", err)
+	}
+	defer proxy.Close()
+
+	conn, err := net.Dial("unix", proxy.SocketPath())
+	if err != nil {
+		t.Fatalf("failed to dial proxy: %v", err)
+	}
+	defer conn.Close()
+
+	// Daemon side goes away while the client is idle
+	daemonConn := <-accepted
+	_ = daemonConn.Close()
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expected client connection to be closed")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for proxy.ActiveConns() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected connections to be reaped, %d still active", proxy.ActiveConns())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDockerSocketProxy_Close(t *testing.T) {
+	dial, accepted := startEchoDaemon(t)
+	socketPath := testSocketPath(t)
+	proxy, err := NewDockerSocketProxy(socketPath, dial, nil)
+	if err != nil {
+		t.Fatalf("NewDockerSocketProxy failed: %v", err)
+	}
+
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("failed to dial proxy: %v", err)
+	}
+	defer conn.Close()
+	<-accepted
+
+	if err := proxy.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	select {
+	case <-proxy.Done():
+	default:
+		t.Fatal("expected Done to be closed")
+	}
+	if proxy.Err() != nil {
+		t.Errorf("expected nil error after Close, got %v", proxy.Err())
+	}
+	if _, err := os.Stat(socketPath); !os.IsNotExist(err) {
+		t.Errorf("expected socket to be removed, got %v", err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("expected active connection to be closed")
+	}
+}
+
+// startFakeDockerDaemon serves a minimal Docker API with two containers, one
+// belonging to session "s1" and one to "s2", and records create requests.
+func startFakeDockerDaemon(t *testing.T) (DockerDialFunc, <-chan map[string]any) {
+	t.Helper()
+	containers := map[string]map[string]string{
+		"mine":   {"discobot.session.id": "s1"},
+		"theirs": {"discobot.session.id": "s2"},
+	}
+	created := make(chan map[string]any, 10)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1.47/containers/json", func(w http.ResponseWriter, _ *http.Request) {
+		var list []map[string]any
+		for id, labels := range containers {
+			list = append(list, map[string]any{"Id": id, "Labels": labels})
+		}
+		_ = json.NewEncoder(w).Encode(list)
+	})
+	mux.HandleFunc("GET /containers/{id}/json", func(w http.ResponseWriter, r *http.Request) {
+		labels, ok := containers[r.PathValue("id")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"Config": map[string]any{"Labels": labels}})
+	})
+	mux.HandleFunc("POST /v1.47/containers/{id}/start", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /v1.47/containers/create", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		created <- body
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"Id":"new"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	dial := func(ctx context.Context) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", server.Listener.Addr().String())
+	}
+	return dial, created
+}
+
+func TestDockerSocketProxy_Scoped(t *testing.T) {
+	dial, created := startFakeDockerDaemon(t)
+	proxy, err := NewDockerSocketProxy(testSocketPath(t), dial, &DockerSocketScope{SessionID: "s1"})
+	if err != nil {
+		t.Fatalf("NewDockerSocketProxy failed: %v", err)
+	}
+	defer proxy.Close()
+
+	client := daemonClient(func(ctx context.Context) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", proxy.SocketPath())
+	})
+	do := func(method, path, body string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, "http://docker/v1.47"+path, strings.NewReader(body))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	// Only the session's own container is listed and can be operated on
+	status, body := do("GET", "/containers/json", "")
+	if status != http.StatusOK || !strings.Contains(body, "mine") || strings.Contains(body, "theirs") {
+		t.Errorf("container list = %d %s, want only mine", status, body)
+	}
+	if status, _ := do("POST", "/containers/mine/start", ""); status != http.StatusNoContent {
+		t.Errorf("starting own container = %d, want 204", status)
+	}
+	if status, _ := do("POST", "/containers/theirs/start", ""); status != http.StatusForbidden {
+		t.Errorf("starting another session's container = %d, want 403", status)
+	}
+	if status, _ := do("GET", "/volumes", ""); status != http.StatusForbidden {
+		t.Errorf("listing volumes = %d, want 403", status)
+	}
+
+	// Privileged containers are refused however the key is spelled
+	for _, body := range []string{
+		`{"Image":"alpine","HostConfig":{"Privileged":true}}`,
+		`{"Image":"alpine","hostconfig":{"privileged":true}}`,
+		`{"Image":"alpine","HostConfig":{"Binds":["/:/host"]}}`,
+		`{"Image":"alpine","HostConfig":{"PidMode":"container:theirs"}}`,
+	} {
+		if status, _ := do("POST", "/containers/create", body); status != http.StatusForbidden {
+			t.Errorf("create %s = %d, want 403", body, status)
+		}
+	}
+
+	// Created containers are labelled with the session, and can't claim
+	// discobot's own labels
+	status, _ = do("POST", "/containers/create", `{"Image":"alpine","labels":{"discobot.session.id":"s2","app":"x"}}`)
+	if status != http.StatusCreated {
+		t.Fatalf("create = %d, want 201", status)
+	}
+	labels, _ := (<-created)["Labels"].(map[string]any)
+	if labels[DockerSocketSessionLabel] != "s1" || labels["app"] != "x" || labels["discobot.session.id"] != nil {
+		t.Errorf("created container labels = %v", labels)
+	}
+}
@@ -33,4 +33,24 @@ var (
 
 	// ErrResourceLimit indicates a resource limit was exceeded.
 	ErrResourceLimit = errors.New("resource limit exceeded")
+
+	// ErrCapacityExceeded indicates the host is already running as many sandboxes
+	// as it is allowed to.
+	ErrCapacityExceeded = errors.New("sandbox capacity exceeded on this host")
+
+	// ErrTooManyExecs indicates the sandbox's concurrent command limit was reached.
+	ErrTooManyExecs = errors.New("too many concurrent commands in this sandbox")
+
+	// ErrVolumeShrink indicates a resize asked for less space than the volume has.
+	ErrVolumeShrink = errors.New("volumes can only grow")
+
+	// ErrNotReady indicates the provider can't create sandboxes yet, for
+	// example while its images are still downloading.
+	ErrNotReady = errors.New("sandbox provider not ready")
+
+	// ErrNotSupported indicates the provider doesn't support the operation.
+	ErrNotSupported = errors.New("operation not supported by sandbox provider")
+
+	// ErrOutsideWorkspace indicates a path resolves outside the sandbox's workspace.
+	ErrOutsideWorkspace = errors.New("path is outside the workspace")
 )
@@ -0,0 +1,83 @@
+package sandbox
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// DefaultExecMaxOutputBytes is the output cap applied to Exec when
+// ExecOptions.MaxOutputBytes is zero.
+const DefaultExecMaxOutputBytes = 16 * 1024 * 1024
+
+// ExecOutput collects the stdout and stderr of a non-interactive command,
+// sharing a single byte budget between the two streams. Writes past the budget
+// are discarded (but reported as successful so the command can keep running
+// and exit normally), and the output is flagged as truncated.
+type ExecOutput struct {
+	mu        sync.Mutex
+	stdout    bytes.Buffer
+	stderr    bytes.Buffer
+	remaining int64 // negative = unlimited
+	truncated bool
+}
+
+// NewExecOutput creates an output collector for the given options.
+func NewExecOutput(opts ExecOptions) *ExecOutput {
+	limit := opts.MaxOutputBytes
+	if limit == 0 {
+		limit = DefaultExecMaxOutputBytes
+	}
+	return &ExecOutput{remaining: limit}
+}
+
+// Stdout returns a writer that collects standard output.
+func (o *ExecOutput) Stdout() io.Writer {
+	return &execOutputWriter{out: o, buf: &o.stdout}
+}
+
+// Stderr returns a writer that collects standard error.
+func (o *ExecOutput) Stderr() io.Writer {
+	return &execOutputWriter{out: o, buf: &o.stderr}
+}
+
+// Result builds an ExecResult from the collected output.
+func (o *ExecOutput) Result(exitCode int) *ExecResult {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return &ExecResult{
+		ExitCode:  exitCode,
+		Stdout:    bytes.Clone(o.stdout.Bytes()),
+		Stderr:    bytes.Clone(o.stderr.Bytes()),
+		Truncated: o.truncated,
+	}
+}
+
+// TimedOutResult builds an ExecResult for a command stopped by its timeout.
+func (o *ExecOutput) TimedOutResult() *ExecResult {
+	result := o.Result(-1)
+	result.TimedOut = true
+	return result
+}
+
+type execOutputWriter struct {
+	out *ExecOutput
+	buf *bytes.Buffer
+}
+
+func (w *execOutputWriter) Write(p []byte) (int, error) {
+	o := w.out
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	data := p
+	if o.remaining >= 0 {
+		if int64(len(data)) > o.remaining {
+			data = data[:o.remaining]
+			o.truncated = true
+		}
+		o.remaining -= int64(len(data))
+	}
+	w.buf.Write(data)
+	return len(p), nil
+}
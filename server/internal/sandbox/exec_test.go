@@ -0,0 +1,94 @@
+package sandbox
+
+import (
+	"io"
+	"testing"
+)
+
+func TestExecOutput(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxBytes      int64
+		stdout        []string
+		stderr        []string
+		wantStdout    string
+		wantStderr    string
+		wantTruncated bool
+	}{
+		{
+			name:       "under the cap",
+			maxBytes:   100,
+			stdout:     []string{"hello "},
+			stderr:     []string{"warn"},
+			wantStdout: "hello ",
+			wantStderr: "warn",
+		},
+		{
+			name:          "cap is shared between stdout and stderr",
+			maxBytes:      8,
+			stdout:        []string{"hello"},
+			stderr:        []string{"warning"},
+			wantStdout:    "hello",
+			wantStderr:    "war",
+			wantTruncated: true,
+		},
+		{
+			name:          "writes after the cap are discarded",
+			maxBytes:      4,
+			stdout:        []string{"abcdef", "ghi"},
+			wantStdout:    "abcd",
+			wantTruncated: true,
+		},
+		{
+			name:       "exactly at the cap is not truncated",
+			maxBytes:   3,
+			stdout:     []string{"abc"},
+			wantStdout: "abc",
+		},
+		{
+			name:       "negative cap is unlimited",
+			maxBytes:   -1,
+			stdout:     []string{"abcdef", "ghi"},
+			wantStdout: "abcdefghi",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := NewExecOutput(ExecOptions{MaxOutputBytes: tt.maxBytes})
+			write := func(w io.Writer, chunks []string) {
+				for _, c := range chunks {
+					n, err := w.Write([]byte(c))
+					if err != nil || n != len(c) {
+						t.Fatalf("Write(%q) = %d, %v; want %d, nil", c, n, err, len(c))
+					}
+				}
+			}
+			write(out.Stdout(), tt.stdout)
+			write(out.Stderr(), tt.stderr)
+
+			result := out.Result(0)
+			if string(result.Stdout) != tt.wantStdout {
+				t.Errorf("Stdout = %q, want %q", result.Stdout, tt.wantStdout)
+			}
+			if string(result.Stderr) != tt.wantStderr {
+				t.Errorf("Stderr = %q, want %q", result.Stderr, tt.wantStderr)
+			}
+			if result.Truncated != tt.wantTruncated {
+				t.Errorf("Truncated = %v, want %v", result.Truncated, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestExecOutput_DefaultCap(t *testing.T) {
+	out := NewExecOutput(ExecOptions{})
+	if out.remaining != DefaultExecMaxOutputBytes {
+		t.Errorf("remaining = %d, want %d", out.remaining, DefaultExecMaxOutputBytes)
+	}
+
+	result := out.TimedOutResult()
+	if !result.TimedOut || result.ExitCode != -1 {
+		t.Errorf("TimedOutResult() = %+v, want TimedOut with exit code -1", result)
+	}
+}
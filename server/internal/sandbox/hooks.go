@@ -0,0 +1,151 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Sandbox lifecycle hook events.
+const (
+	// HookPreCreate runs before the provider creates the sandbox.
+	HookPreCreate = "pre-create"
+	// HookPostCreate runs after the sandbox is created and before it is
+	// started. A veto removes the new sandbox.
+	HookPostCreate = "post-create"
+)
+
+// Hook failure policies, for hooks that error instead of answering.
+const (
+	// HookFailClosed blocks sandbox creation when a hook fails.
+	HookFailClosed = "fail-closed"
+	// HookFailOpen logs the failure and creates the sandbox anyway.
+	HookFailOpen = "fail-open"
+)
+
+// ErrHookVetoed indicates a lifecycle hook refused to let a sandbox be created.
+var ErrHookVetoed = errors.New("sandbox creation vetoed by hook")
+
+// HookRequest is the session and sandbox metadata passed to a hook.
+type HookRequest struct {
+	Event       string            `json:"event"`
+	SessionID   string            `json:"sessionId"`
+	WorkspaceID string            `json:"workspaceId,omitempty"`
+	ProjectID   string            `json:"projectId,omitempty"`
+	Provider    string            `json:"provider"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	NetworkMode string            `json:"networkMode,omitempty"`
+
+	// Set for HookPostCreate only
+	SandboxID string `json:"sandboxId,omitempty"`
+	Image     string `json:"image,omitempty"`
+}
+
+// Hook is called around sandbox creation. Returning a *HookVeto refuses the
+// sandbox; any other error is a hook failure, handled per the failure policy.
+type Hook func(ctx context.Context, req HookRequest) error
+
+// HookVeto is returned by a hook that refuses a sandbox.
+type HookVeto struct {
+	Reason string
+}
+
+func (v *HookVeto) Error() string {
+	if v.Reason == "" {
+		return ErrHookVetoed.Error()
+	}
+	return ErrHookVetoed.Error() + ": " + v.Reason
+}
+
+// Is lets errors.Is match ErrHookVetoed.
+func (v *HookVeto) Is(target error) bool {
+	return target == ErrHookVetoed
+}
+
+// Hooks are the lifecycle hooks run by a ProviderProxy. Hooks for an event
+// run in order and the first veto wins.
+type Hooks struct {
+	PreCreate  []Hook
+	PostCreate []Hook
+
+	// FailOpen creates the sandbox even if a hook fails (errors without
+	// vetoing). By default a failing hook blocks creation.
+	FailOpen bool
+
+	// Logf reports hook failures ignored because of FailOpen.
+	Logf func(format string, args ...any)
+}
+
+// run calls the hooks for an event and returns the error that should stop
+// sandbox creation, if any.
+func (h *Hooks) run(ctx context.Context, hooks []Hook, req HookRequest) error {
+	for _, hook := range hooks {
+		err := hook(ctx, req)
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, ErrHookVetoed) || !h.FailOpen {
+			return fmt.Errorf("%s hook for session %s: %w", req.Event, req.SessionID, err)
+		}
+		if h.Logf != nil {
+			h.Logf("Ignoring failed %s hook for session %s (fail-open): %v", req.Event, req.SessionID, err)
+		}
+	}
+	return nil
+}
+
+// webhookResponse is the optional JSON body a webhook answers with.
+type webhookResponse struct {
+	Allow  *bool  `json:"allow"`
+	Reason string `json:"reason"`
+}
+
+// WebhookHook returns a Hook that POSTs the HookRequest as JSON to url. A 2xx
+// response allows the sandbox unless its body is {"allow": false}, optionally
+// with a "reason". Other statuses, timeouts and connection errors are hook
+// failures.
+func WebhookHook(url string, timeout time.Duration) Hook {
+	client := &http.Client{Timeout: timeout}
+	return func(ctx context.Context, req HookRequest) error {
+		body, err := json.Marshal(req)
+		if err != nil {
+			return err
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(httpReq)
+		if err != nil {
+			return fmt.Errorf("webhook request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if err != nil {
+			return fmt.Errorf("failed to read webhook response: %w", err)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("webhook returned %s", resp.Status)
+		}
+		if len(bytes.TrimSpace(respBody)) == 0 {
+			return nil
+		}
+
+		var result webhookResponse
+		if err := json.Unmarshal(respBody, &result); err != nil {
+			return fmt.Errorf("invalid webhook response: %w", err)
+		}
+		if result.Allow != nil && !*result.Allow {
+			return &HookVeto{Reason: result.Reason}
+		}
+		return nil
+	}
+}
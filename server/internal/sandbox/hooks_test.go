@@ -0,0 +1,174 @@
+package sandbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/sandbox/mock"
+)
+
+func newHookedProxy(t *testing.T, hooks *sandbox.Hooks) (*sandbox.ProviderProxy, *mock.Provider) {
+	t.Helper()
+	provider := mock.NewProvider()
+	manager := sandbox.NewManager()
+	manager.RegisterProvider("mock", provider)
+	proxy := sandbox.NewProviderProxy(manager, func(context.Context, string) (string, error) {
+		return "mock", nil
+	})
+	proxy.SetHooks(hooks)
+	return proxy, provider
+}
+
+func createOptions() sandbox.CreateOptions {
+	return sandbox.CreateOptions{Labels: map[string]string{
+		"discobot.session.id":   "s1",
+		"discobot.workspace.id": "w1",
+		"discobot.project.id":   "p1",
+	}}
+}
+
+func TestProviderProxy_Hooks(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("hooks receive metadata", func(t *testing.T) {
+		var got []sandbox.HookRequest
+		record := func(_ context.Context, req sandbox.HookRequest) error {
+			got = append(got, req)
+			return nil
+		}
+		proxy, _ := newHookedProxy(t, &sandbox.Hooks{
+			PreCreate:  []sandbox.Hook{record},
+			PostCreate: []sandbox.Hook{record},
+		})
+
+		if _, err := proxy.Create(ctx, "s1", createOptions()); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if len(got) != 2 || got[0].Event != sandbox.HookPreCreate || got[1].Event != sandbox.HookPostCreate {
+			t.Fatalf("Expected pre-create then post-create, got %+v", got)
+		}
+		if got[0].WorkspaceID != "w1" || got[0].ProjectID != "p1" || got[0].Provider != "mock" || got[0].SandboxID != "" {
+			t.Errorf("Unexpected pre-create request %+v", got[0])
+		}
+		if got[1].SandboxID == "" {
+			t.Error("Expected post-create request to carry the sandbox ID")
+		}
+	})
+
+	t.Run("pre-create veto skips creation", func(t *testing.T) {
+		proxy, provider := newHookedProxy(t, &sandbox.Hooks{
+			PreCreate: []sandbox.Hook{func(context.Context, sandbox.HookRequest) error {
+				return &sandbox.HookVeto{Reason: "quota"}
+			}},
+		})
+
+		_, err := proxy.Create(ctx, "s1", createOptions())
+		if !errors.Is(err, sandbox.ErrHookVetoed) {
+			t.Fatalf("Expected ErrHookVetoed, got %v", err)
+		}
+		if _, err := provider.Get(ctx, "s1"); !errors.Is(err, sandbox.ErrNotFound) {
+			t.Errorf("Expected no sandbox, got %v", err)
+		}
+	})
+
+	t.Run("post-create veto removes the sandbox", func(t *testing.T) {
+		proxy, provider := newHookedProxy(t, &sandbox.Hooks{
+			PostCreate: []sandbox.Hook{func(context.Context, sandbox.HookRequest) error {
+				return &sandbox.HookVeto{}
+			}},
+		})
+
+		if _, err := proxy.Create(ctx, "s1", createOptions()); !errors.Is(err, sandbox.ErrHookVetoed) {
+			t.Fatalf("Expected ErrHookVetoed, got %v", err)
+		}
+		if _, err := provider.Get(ctx, "s1"); !errors.Is(err, sandbox.ErrNotFound) {
+			t.Errorf("Expected vetoed sandbox to be removed, got %v", err)
+		}
+	})
+
+	t.Run("post-create veto keeps a sandbox that already existed", func(t *testing.T) {
+		proxy, provider := newHookedProxy(t, &sandbox.Hooks{
+			PostCreate: []sandbox.Hook{func(context.Context, sandbox.HookRequest) error {
+				return &sandbox.HookVeto{}
+			}},
+		})
+		existing, err := provider.Create(ctx, "s1", createOptions())
+		if err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		// A provider that hands back the session's sandbox if it has one
+		provider.CreateFunc = func(context.Context, string, sandbox.CreateOptions) (*sandbox.Sandbox, error) {
+			return existing, nil
+		}
+
+		if _, err := proxy.Create(ctx, "s1", createOptions()); !errors.Is(err, sandbox.ErrHookVetoed) {
+			t.Fatalf("Expected ErrHookVetoed, got %v", err)
+		}
+		if _, err := provider.Get(ctx, "s1"); err != nil {
+			t.Errorf("Expected the existing sandbox to be kept, got %v", err)
+		}
+	})
+
+	t.Run("failure policy", func(t *testing.T) {
+		failing := func(context.Context, sandbox.HookRequest) error {
+			return errors.New("connection refused")
+		}
+
+		proxy, _ := newHookedProxy(t, &sandbox.Hooks{PreCreate: []sandbox.Hook{failing}})
+		if _, err := proxy.Create(ctx, "s1", createOptions()); err == nil || errors.Is(err, sandbox.ErrHookVetoed) {
+			t.Errorf("Expected fail-closed hook failure, got %v", err)
+		}
+
+		proxy, _ = newHookedProxy(t, &sandbox.Hooks{PreCreate: []sandbox.Hook{failing}, FailOpen: true})
+		if _, err := proxy.Create(ctx, "s1", createOptions()); err != nil {
+			t.Errorf("Expected fail-open to create the sandbox, got %v", err)
+		}
+	})
+}
+
+func TestWebhookHook(t *testing.T) {
+	ctx := context.Background()
+	req := sandbox.HookRequest{Event: sandbox.HookPreCreate, SessionID: "s1"}
+
+	var status int
+	var body string
+	var received sandbox.HookRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	hook := sandbox.WebhookHook(srv.URL, time.Second)
+
+	status, body = http.StatusNoContent, ""
+	if err := hook(ctx, req); err != nil {
+		t.Errorf("Expected empty 2xx to allow, got %v", err)
+	}
+	if received.SessionID != "s1" || received.Event != sandbox.HookPreCreate {
+		t.Errorf("Expected request metadata to be posted, got %+v", received)
+	}
+
+	status, body = http.StatusOK, `{"allow": false, "reason": "over quota"}`
+	err := hook(ctx, req)
+	var veto *sandbox.HookVeto
+	if !errors.As(err, &veto) || veto.Reason != "over quota" {
+		t.Errorf("Expected veto with reason, got %v", err)
+	}
+
+	status, body = http.StatusOK, `{"allow": true}`
+	if err := hook(ctx, req); err != nil {
+		t.Errorf("Expected allow, got %v", err)
+	}
+
+	status, body = http.StatusInternalServerError, ""
+	if err := hook(ctx, req); err == nil || errors.Is(err, sandbox.ErrHookVetoed) {
+		t.Errorf("Expected non-veto failure for 500, got %v", err)
+	}
+}
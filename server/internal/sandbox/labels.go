@@ -0,0 +1,32 @@
+package sandbox
+
+import "strings"
+
+// ReservedLabelPrefix is the label prefix used for labels managed by discobot.
+// User-supplied labels with this prefix are ignored.
+const ReservedLabelPrefix = "discobot."
+
+// IsReservedLabel reports whether the label key is reserved for discobot.
+func IsReservedLabel(key string) bool {
+	return strings.HasPrefix(key, ReservedLabelPrefix)
+}
+
+// MergeLabels combines managed labels with user-supplied extra labels.
+// Extra label sets are applied in order, so later sets override earlier ones
+// (e.g. global defaults followed by per-project overrides). Reserved
+// discobot.* keys in the extra sets are dropped, and managed labels always win.
+func MergeLabels(managed map[string]string, extra ...map[string]string) map[string]string {
+	labels := make(map[string]string)
+	for _, set := range extra {
+		for k, v := range set {
+			if k == "" || IsReservedLabel(k) {
+				continue
+			}
+			labels[k] = v
+		}
+	}
+	for k, v := range managed {
+		labels[k] = v
+	}
+	return labels
+}
@@ -0,0 +1,69 @@
+package sandbox
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeLabels(t *testing.T) {
+	managed := map[string]string{
+		"discobot.session.id": "sess-1",
+		"discobot.managed":    "true",
+	}
+
+	tests := []struct {
+		name     string
+		extra    []map[string]string
+		expected map[string]string
+	}{
+		{
+			name:     "no extra labels",
+			expected: managed,
+		},
+		{
+			name: "extra labels are added",
+			extra: []map[string]string{
+				{"team": "platform", "cost-center": "42"},
+			},
+			expected: map[string]string{
+				"discobot.session.id": "sess-1",
+				"discobot.managed":    "true",
+				"team":                "platform",
+				"cost-center":         "42",
+			},
+		},
+		{
+			name: "later sets override earlier ones",
+			extra: []map[string]string{
+				{"team": "platform", "environment": "prod"},
+				{"team": "data"},
+			},
+			expected: map[string]string{
+				"discobot.session.id": "sess-1",
+				"discobot.managed":    "true",
+				"team":                "data",
+				"environment":         "prod",
+			},
+		},
+		{
+			name: "reserved labels are ignored",
+			extra: []map[string]string{
+				{"discobot.session.id": "spoofed", "discobot.custom": "x", "team": "platform"},
+			},
+			expected: map[string]string{
+				"discobot.session.id": "sess-1",
+				"discobot.managed":    "true",
+				"team":                "platform",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MergeLabels(managed, tt.extra...)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("MergeLabels() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// isolationArgs are the unshare(1) options commands run with when
+// LocalProviderIsolation is enabled: private mount, PID and network
+// namespaces, with /proc remounted so only the command's own processes are
+// visible, and the command killed if unshare is.
+//
+// This is best effort, not a security boundary like the Docker provider:
+// commands still run as the server's user. It keeps them from seeing or
+// signalling host processes and from reaching the network, and, when the
+// workspace root can be set up (see rootScript), from writing outside the
+// workspace.
+var isolationArgs = []string{"--mount", "--pid", "--fork", "--kill-child", "--mount-proc", "--net"}
+
+// rootScript runs inside the namespaces and roots the command at the
+// workspace: it builds a new root on a tmpfs mounted at $1 with the host's
+// system directories bound read-only, a private /tmp, and the workspace $2
+// bound read-write at its own path, then changes into it and runs the
+// remaining arguments from the directory it was started in. Anything else
+// on the host, such as home directories, isn't visible.
+const rootScript = `set -e
+root=$1 ws=$2 dir=$(pwd -P)
+shift 2
+mount -t tmpfs -o mode=0755 tmpfs "$root"
+for d in /bin /sbin /lib /lib32 /lib64 /libx32 /usr /etc /opt; do
+	if [ -L "$d" ]; then
+		ln -s "$(readlink "$d")" "$root$d"
+	elif [ -d "$d" ]; then
+		mkdir "$root$d"
+		mount --rbind "$d" "$root$d"
+		mount -o remount,bind,ro "$root$d"
+	fi
+done
+mkdir "$root/proc" "$root/dev" "$root/tmp"
+mount --rbind /proc "$root/proc"
+mount --rbind /dev "$root/dev"
+mount -t tmpfs tmpfs "$root/tmp"
+mkdir -p "$root$ws"
+mount --bind "$ws" "$root$ws"
+exec "$UNSHARE" --root="$root" --wd="$dir" -- "$@"
+`
+
+// isolation is how Exec commands are isolated. The zero value runs them
+// directly.
+type isolation struct {
+	// prefix is the unshare command line commands are appended to
+	prefix []string
+
+	// root is the mount point prefix's rootScript builds the workspace root
+	// on, and takes the workspace before the command (empty = not rooted)
+	root string
+}
+
+// newIsolation returns how commands can be isolated here, or an error if
+// isolation isn't supported. Namespaces are Linux-only, and creating them
+// without root needs unprivileged user namespaces, which some systems
+// disable. If the workspace root can't be set up, commands are isolated
+// without it and a warning is logged.
+func newIsolation(ctx context.Context) (isolation, error) {
+	if runtime.GOOS != "linux" {
+		return isolation{}, fmt.Errorf("namespaces are not supported on %s", runtime.GOOS)
+	}
+
+	unshare, err := exec.LookPath("unshare")
+	if err != nil {
+		return isolation{}, err
+	}
+	prefix := append([]string{unshare}, isolationArgs...)
+	if os.Geteuid() != 0 {
+		prefix = append(prefix, "--map-root-user")
+	}
+	prefix = append(prefix, "--")
+
+	// The new root is built on a tmpfs mounted in each command's own mount
+	// namespace, so this directory stays empty on the host
+	root, err := os.MkdirTemp("", "discobot-isolation-")
+	if err == nil {
+		rooted := isolation{
+			prefix: append(append([]string(nil), prefix...),
+				"env", "UNSHARE="+unshare, "sh", "-c", rootScript, "isolate", root),
+			root: root,
+		}
+		if err = probeIsolation(ctx, rooted); err == nil {
+			return rooted, nil
+		}
+		_ = os.Remove(root)
+	}
+	log.Printf("Warning: local provider can't root isolated commands at the workspace, they can write outside it: %v", err)
+
+	plain := isolation{prefix: prefix}
+	if err := probeIsolation(ctx, plain); err != nil {
+		return isolation{}, err
+	}
+	return plain, nil
+}
+
+// probeIsolation runs true isolated, since whether namespaces and mounts can
+// be created depends on the kernel and on any sandbox the server itself runs
+// in.
+func probeIsolation(ctx context.Context, iso isolation) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	dir := os.TempDir()
+	probe := iso.command(dir, []string{"true"})
+	cmd := exec.CommandContext(ctx, probe[0], probe[1:]...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// command returns cmd prefixed to run isolated in workspace. The zero
+// isolation returns cmd unchanged.
+func (iso isolation) command(workspace string, cmd []string) []string {
+	if iso.prefix == nil {
+		return cmd
+	}
+	args := append([]string(nil), iso.prefix...)
+	if iso.root != "" {
+		args = append(args, workspace)
+	}
+	return append(args, cmd...)
+}
@@ -0,0 +1,98 @@
+package local
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+func TestIsolationCommand(t *testing.T) {
+	cmd := []string{"git", "status"}
+	if got := (isolation{}).command("/ws", cmd); !slices.Equal(got, cmd) {
+		t.Errorf("zero isolation command() = %v, want %v", got, cmd)
+	}
+
+	prefix := []string{"unshare", "--pid", "--"}
+	got := isolation{prefix: prefix}.command("/ws", cmd)
+	if want := []string{"unshare", "--pid", "--", "git", "status"}; !slices.Equal(got, want) {
+		t.Errorf("command() = %v, want %v", got, want)
+	}
+	got = isolation{prefix: prefix, root: "/root"}.command("/ws", cmd)
+	if want := []string{"unshare", "--pid", "--", "/ws", "git", "status"}; !slices.Equal(got, want) {
+		t.Errorf("rooted command() = %v, want %v", got, want)
+	}
+	if !slices.Equal(prefix, []string{"unshare", "--pid", "--"}) {
+		t.Errorf("command() modified the prefix: %v", prefix)
+	}
+}
+
+func TestExec_Isolated(t *testing.T) {
+	ctx := context.Background()
+	iso, err := newIsolation(ctx)
+	if err != nil {
+		t.Skipf("isolation not supported here: %v", err)
+	}
+	if iso.root != "" {
+		t.Cleanup(func() { _ = os.Remove(iso.root) })
+	}
+
+	workspace, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Provider{
+		isolation: iso,
+		processes: map[string]*processInfo{
+			"session": {workspacePath: workspace},
+		},
+	}
+
+	// The command is the first process of its own PID namespace and the only
+	// one in /proc, starts in the workspace and has no network interfaces
+	// besides loopback
+	script := `echo $$; pwd; echo /proc/[0-9]*; tail -n +3 /proc/net/dev | cut -d: -f1 | tr -d ' '`
+	result, err := p.Exec(ctx, "session", []string{"sh", "-c", script}, sandbox.ExecOptions{})
+	if err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("Exec() exit code = %d, stderr: %s", result.ExitCode, result.Stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(string(result.Stdout)), "\n")
+	if len(lines) < 3 || lines[0] != "1" || lines[1] != workspace {
+		t.Fatalf("Exec() output = %q, want PID 1 in %s", result.Stdout, workspace)
+	}
+	if lines[2] != "/proc/1" {
+		t.Errorf("processes in /proc = %q, want only /proc/1", lines[2])
+	}
+	if ifaces := lines[3:]; !slices.Equal(ifaces, []string{"lo"}) {
+		t.Errorf("network interfaces = %v, want only lo", ifaces)
+	}
+
+	if iso.root == "" {
+		t.Skip("workspace root not supported here")
+	}
+
+	// Rooted at the workspace, only it is writable and the rest of the
+	// host's files, such as the test's own source, aren't visible
+	source, err := filepath.Abs("isolation_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script = `touch file; [ -w /usr ] && echo /usr; [ -e "$0" ] && echo "$0"; echo done`
+	result, err = p.Exec(ctx, "session", []string{"sh", "-c", script, source}, sandbox.ExecOptions{})
+	if err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if got := strings.TrimSpace(string(result.Stdout)); got != "done" {
+		t.Errorf("Exec() output = %q, want only done (%s)", got, result.Stderr)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "file")); err != nil {
+		t.Errorf("file written in the workspace: %v", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -28,6 +29,10 @@ type Provider struct {
 	cfg        *config.Config
 	binaryPath string // Path to agent API binary
 
+	// isolation runs Exec commands in their own namespaces (zero value = run
+	// them directly). See isolationArgs.
+	isolation isolation
+
 	// processes maps sessionID -> process info
 	processes   map[string]*processInfo
 	processesMu sync.RWMutex
@@ -76,6 +81,16 @@ func NewProvider(cfg *config.Config) (*Provider, error) {
 		eventCh:    make(chan sandbox.StateEvent, 100),
 	}
 
+	if cfg.LocalProviderIsolation {
+		iso, err := newIsolation(context.Background())
+		if err != nil {
+			log.Printf("Warning: local provider isolation is not available, running commands without it: %v", err)
+		} else {
+			log.Printf("Local provider running commands in isolated namespaces (best effort, not a security boundary)")
+			p.isolation = iso
+		}
+	}
+
 	return p, nil
 }
 
@@ -84,6 +99,14 @@ func (p *Provider) ImageExists(_ context.Context) bool {
 	return true
 }
 
+// Healthy checks that the agent API binary is still present.
+func (p *Provider) Healthy(_ context.Context) error {
+	if _, err := os.Stat(p.binaryPath); err != nil {
+		return fmt.Errorf("agent API binary: %w", err)
+	}
+	return nil
+}
+
 // Image returns "local" as the image name.
 func (p *Provider) Image() string {
 	return "local"
@@ -100,6 +123,15 @@ func (p *Provider) Create(_ context.Context, sessionID string, opts sandbox.Crea
 		return nil, sandbox.ErrAlreadyExists
 	}
 
+	// The agent runs directly on the host, where it can't firewall itself
+	if opts.NetworkMode == sandbox.NetworkModeIsolated {
+		return nil, fmt.Errorf("%w: network mode %q is not supported by the local provider", sandbox.ErrStartFailed, opts.NetworkMode)
+	}
+	// Nor does it run the MITM proxy, so it can't honor PROXY_REQUIRED
+	if p.cfg.ProxyRequired {
+		return nil, fmt.Errorf("%w: PROXY_REQUIRED is set, but the local provider runs without the sandbox proxy", sandbox.ErrStartFailed)
+	}
+
 	// Validate workspace path
 	if opts.WorkspacePath == "" {
 		return nil, fmt.Errorf("%w: workspace path is required", sandbox.ErrStartFailed)
@@ -357,6 +389,16 @@ func (p *Provider) Stop(_ context.Context, sessionID string, timeout time.Durati
 	return nil
 }
 
+// Pause is not supported: local sandboxes are plain processes on the host.
+func (p *Provider) Pause(_ context.Context, _ string) error {
+	return sandbox.ErrNotSupported
+}
+
+// Unpause is not supported.
+func (p *Provider) Unpause(_ context.Context, _ string) error {
+	return sandbox.ErrNotSupported
+}
+
 // Remove removes the sandbox (stops the process if running).
 func (p *Provider) Remove(ctx context.Context, sessionID string, _ ...sandbox.RemoveOption) error {
 	// Stop the process first if running
@@ -421,6 +463,18 @@ func (p *Provider) Get(_ context.Context, sessionID string) (*sandbox.Sandbox, e
 	}, nil
 }
 
+// WaitForStatus waits for the sandbox process to reach the target status,
+// following the provider's state events.
+func (p *Provider) WaitForStatus(ctx context.Context, sessionID string, target sandbox.Status, timeout time.Duration) (*sandbox.Sandbox, error) {
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, err := p.Watch(watchCtx)
+	if err != nil {
+		events = nil
+	}
+	return sandbox.WaitForEvents(ctx, sessionID, target, timeout, p.Get, events)
+}
+
 // GetSecret returns the shared secret for the sandbox.
 func (p *Provider) GetSecret(_ context.Context, sessionID string) (string, error) {
 	p.processesMu.RLock()
@@ -450,7 +504,8 @@ func (p *Provider) List(ctx context.Context) ([]*sandbox.Sandbox, error) {
 	return sandboxes, nil
 }
 
-// Exec runs a non-interactive command in the workspace directory.
+// Exec runs a non-interactive command in the workspace directory, in its own
+// namespaces if isolation is enabled and supported.
 func (p *Provider) Exec(ctx context.Context, sessionID string, cmd []string, opts sandbox.ExecOptions) (*sandbox.ExecResult, error) {
 	p.processesMu.RLock()
 	info, exists := p.processes[sessionID]
@@ -464,8 +519,17 @@ func (p *Provider) Exec(ctx context.Context, sessionID string, cmd []string, opt
 		return nil, fmt.Errorf("command is required")
 	}
 
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
 	// Create command
+	cmd = p.isolation.command(info.workspacePath, cmd)
 	execCmd := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
+	// Don't wait forever on output pipes held open by orphaned children
+	execCmd.WaitDelay = time.Second
 	execCmd.Dir = info.workspacePath
 
 	// Set working directory if specified
@@ -487,28 +551,23 @@ func (p *Provider) Exec(ctx context.Context, sessionID string, cmd []string, opt
 		execCmd.Stdin = opts.Stdin
 	}
 
-	// Capture stdout and stderr
-	var stdout, stderr []byte
-	var err error
+	// Capture stdout and stderr, keeping at most MaxOutputBytes in memory
+	output := sandbox.NewExecOutput(opts)
+	execCmd.Stdout = output.Stdout()
+	execCmd.Stderr = output.Stderr()
 
-	stdout, err = execCmd.Output()
+	err := execCmd.Run()
+	if opts.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return output.TimedOutResult(), nil
+	}
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
-			stderr = exitErr.Stderr
-			return &sandbox.ExecResult{
-				ExitCode: exitErr.ExitCode(),
-				Stdout:   stdout,
-				Stderr:   stderr,
-			}, nil
+			return output.Result(exitErr.ExitCode()), nil
 		}
 		return nil, fmt.Errorf("failed to execute command: %w", err)
 	}
 
-	return &sandbox.ExecResult{
-		ExitCode: 0,
-		Stdout:   stdout,
-		Stderr:   stderr,
-	}, nil
+	return output.Result(0), nil
 }
 
 // Attach creates an interactive PTY session (not supported for local provider).
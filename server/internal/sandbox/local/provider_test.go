@@ -0,0 +1,28 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+func TestCreate_ProxyRequired(t *testing.T) {
+	p := &Provider{
+		cfg:       &config.Config{ProxyRequired: true},
+		processes: make(map[string]*processInfo),
+		eventCh:   make(chan sandbox.StateEvent, 10),
+	}
+
+	_, err := p.Create(context.Background(), "s1", sandbox.CreateOptions{WorkspacePath: t.TempDir()})
+	if !errors.Is(err, sandbox.ErrStartFailed) {
+		t.Fatalf("Expected ErrStartFailed with PROXY_REQUIRED set, got %v", err)
+	}
+
+	p.cfg.ProxyRequired = false
+	if _, err := p.Create(context.Background(), "s1", sandbox.CreateOptions{WorkspacePath: t.TempDir()}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+}
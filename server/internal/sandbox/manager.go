@@ -2,10 +2,14 @@ package sandbox
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"runtime"
+	"sync"
 	"time"
 )
 
@@ -121,6 +125,28 @@ func (m *Manager) ListProviderStatuses() map[string]ProviderStatus {
 	return statuses
 }
 
+// Health calls Healthy on every registered provider concurrently and returns
+// each result by provider name (nil = healthy).
+func (m *Manager) Health(ctx context.Context) map[string]error {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]error, len(m.providers))
+	)
+	for name, provider := range m.providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := provider.Healthy(ctx)
+			mu.Lock()
+			results[name] = err
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
 // Shutdown gracefully shuts down all providers that support cleanup.
 // Providers implementing a Close() method will have it called.
 func (m *Manager) Shutdown() {
@@ -141,6 +167,7 @@ func (m *Manager) Shutdown() {
 type ProviderProxy struct {
 	manager        *Manager
 	providerGetter func(ctx context.Context, sessionID string) (string, error)
+	hooks          *Hooks
 }
 
 // NewProviderProxy creates a new provider proxy that uses providerGetter to determine
@@ -152,6 +179,11 @@ func NewProviderProxy(manager *Manager, providerGetter func(ctx context.Context,
 	}
 }
 
+// SetHooks sets the lifecycle hooks run around sandbox creation.
+func (p *ProviderProxy) SetHooks(hooks *Hooks) {
+	p.hooks = hooks
+}
+
 // ListProviders returns the names of all available providers.
 func (p *ProviderProxy) ListProviders() []string {
 	return p.manager.ListProviders()
@@ -166,6 +198,15 @@ func (p *ProviderProxy) ImageExists(ctx context.Context) bool {
 	return provider.ImageExists(ctx)
 }
 
+// Healthy reports the health of the default provider.
+func (p *ProviderProxy) Healthy(ctx context.Context) error {
+	provider := p.manager.GetDefault()
+	if provider == nil {
+		return fmt.Errorf("%w: no provider registered", ErrNotReady)
+	}
+	return provider.Healthy(ctx)
+}
+
 // Image returns the image name from the default provider.
 func (p *ProviderProxy) Image() string {
 	provider := p.manager.GetDefault()
@@ -175,7 +216,8 @@ func (p *ProviderProxy) Image() string {
 	return provider.Image()
 }
 
-// Create creates a sandbox using the provider determined by providerGetter.
+// Create creates a sandbox using the provider determined by providerGetter,
+// running the pre-create and post-create hooks around it if any are set.
 func (p *ProviderProxy) Create(ctx context.Context, sessionID string, opts CreateOptions) (*Sandbox, error) {
 	providerName, err := p.providerGetter(ctx, sessionID)
 	if err != nil {
@@ -187,7 +229,67 @@ func (p *ProviderProxy) Create(ctx context.Context, sessionID string, opts Creat
 		return nil, err
 	}
 
-	return provider.Create(ctx, sessionID, opts)
+	return p.createWithHooks(ctx, providerName, sessionID, opts, provider, func() (*Sandbox, error) {
+		return provider.Create(ctx, sessionID, opts)
+	})
+}
+
+// createWithHooks runs create between the pre- and post-create hooks.
+func (p *ProviderProxy) createWithHooks(ctx context.Context, providerName, sessionID string, opts CreateOptions, provider Provider, create func() (*Sandbox, error)) (*Sandbox, error) {
+	if p.hooks == nil {
+		return create()
+	}
+
+	req := HookRequest{
+		Event:       HookPreCreate,
+		SessionID:   sessionID,
+		WorkspaceID: opts.Labels["discobot.workspace.id"],
+		ProjectID:   opts.Labels["discobot.project.id"],
+		Provider:    providerName,
+		Labels:      opts.Labels,
+		NetworkMode: EffectiveNetworkMode(opts.NetworkMode),
+	}
+	if err := p.hooks.run(ctx, p.hooks.PreCreate, req); err != nil {
+		return nil, err
+	}
+
+	// Note any sandbox the session already has, so a veto only rolls back
+	// what this call created
+	var existingID string
+	if existing, err := provider.Get(ctx, sessionID); err == nil {
+		existingID = existing.ID
+	}
+
+	sb, err := create()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Event = HookPostCreate
+	req.SandboxID = sb.ID
+	req.Image = sb.Image
+	if err := p.hooks.run(ctx, p.hooks.PostCreate, req); err != nil {
+		if sb.ID != existingID {
+			p.removeVetoed(ctx, provider, sessionID, sb.ID)
+		}
+		return nil, err
+	}
+
+	return sb, nil
+}
+
+// removeVetoed removes the sandbox sandboxID created for sessionID after a
+// post-create hook vetoed it, unless the session's sandbox has been replaced
+// since. The sandbox was never started; its volumes are kept in case it was
+// recreated for an existing session.
+func (p *ProviderProxy) removeVetoed(ctx context.Context, provider Provider, sessionID, sandboxID string) {
+	current, err := provider.Get(ctx, sessionID)
+	if err != nil || current.ID != sandboxID {
+		return
+	}
+	if err := provider.Remove(ctx, sessionID); err != nil && p.hooks.Logf != nil {
+		p.hooks.Logf("Failed to remove vetoed sandbox for session %s: %v", sessionID, err)
+	}
 }
 
 // Start starts a sandbox using the provider determined by providerGetter.
@@ -220,6 +322,36 @@ func (p *ProviderProxy) Stop(ctx context.Context, sessionID string, timeout time
 	return provider.Stop(ctx, sessionID, timeout)
 }
 
+// Pause pauses a sandbox using the provider determined by providerGetter.
+func (p *ProviderProxy) Pause(ctx context.Context, sessionID string) error {
+	providerName, err := p.providerGetter(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return err
+	}
+
+	return provider.Pause(ctx, sessionID)
+}
+
+// Unpause resumes a sandbox using the provider determined by providerGetter.
+func (p *ProviderProxy) Unpause(ctx context.Context, sessionID string) error {
+	providerName, err := p.providerGetter(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return err
+	}
+
+	return provider.Unpause(ctx, sessionID)
+}
+
 // Remove removes a sandbox using the provider determined by providerGetter.
 func (p *ProviderProxy) Remove(ctx context.Context, sessionID string, opts ...RemoveOption) error {
 	providerName, err := p.providerGetter(ctx, sessionID)
@@ -250,6 +382,21 @@ func (p *ProviderProxy) Get(ctx context.Context, sessionID string) (*Sandbox, er
 	return provider.Get(ctx, sessionID)
 }
 
+// WaitForStatus waits using the provider determined by providerGetter.
+func (p *ProviderProxy) WaitForStatus(ctx context.Context, sessionID string, target Status, timeout time.Duration) (*Sandbox, error) {
+	providerName, err := p.providerGetter(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	return provider.WaitForStatus(ctx, sessionID, target, timeout)
+}
+
 // GetSecret gets the secret using the provider determined by providerGetter.
 func (p *ProviderProxy) GetSecret(ctx context.Context, sessionID string) (string, error) {
 	providerName, err := p.providerGetter(ctx, sessionID)
@@ -340,6 +487,310 @@ func (p *ProviderProxy) HTTPClient(ctx context.Context, sessionID string) (*http
 	return provider.HTTPClient(ctx, sessionID)
 }
 
+// DockerSocketProxy forwards a Docker socket using the provider determined by providerGetter.
+// Returns ErrNotSupported if that provider doesn't implement DockerSocketProvider.
+func (p *ProviderProxy) DockerSocketProxy(ctx context.Context, sessionID, socketPath string) (*DockerSocketProxy, error) {
+	provider, err := p.dockerSocketProvider(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return provider.DockerSocketProxy(ctx, sessionID, socketPath)
+}
+
+// StopDockerSocketProxy stops a forwarded Docker socket using the provider determined by providerGetter.
+func (p *ProviderProxy) StopDockerSocketProxy(ctx context.Context, sessionID string) error {
+	provider, err := p.dockerSocketProvider(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	return provider.StopDockerSocketProxy(ctx, sessionID)
+}
+
+func (p *ProviderProxy) dockerSocketProvider(ctx context.Context, sessionID string) (DockerSocketProvider, error) {
+	providerName, err := p.providerGetter(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	dsp, ok := provider.(DockerSocketProvider)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s provider cannot forward a Docker socket", ErrNotSupported, providerName)
+	}
+	return dsp, nil
+}
+
+// ResizeVolume grows a session's storage using the provider determined by providerGetter.
+// Returns ErrNotSupported if that provider doesn't implement VolumeResizer.
+func (p *ProviderProxy) ResizeVolume(ctx context.Context, sessionID string, newSizeMB int) error {
+	providerName, err := p.providerGetter(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return err
+	}
+
+	resizer, ok := provider.(VolumeResizer)
+	if !ok {
+		return fmt.Errorf("%w: %s provider cannot resize volumes", ErrNotSupported, providerName)
+	}
+	return resizer.ResizeVolume(ctx, sessionID, newSizeMB)
+}
+
+// FilesystemDiff returns a session's filesystem changes using the provider determined by providerGetter.
+// Returns ErrNotSupported if that provider doesn't implement FilesystemDiffer.
+func (p *ProviderProxy) FilesystemDiff(ctx context.Context, sessionID string) ([]FileChange, error) {
+	providerName, err := p.providerGetter(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	differ, ok := provider.(FilesystemDiffer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s provider cannot diff sandbox filesystems", ErrNotSupported, providerName)
+	}
+	return differ.FilesystemDiff(ctx, sessionID)
+}
+
+// Stats samples a session's resource usage using the provider determined by providerGetter.
+// Returns ErrNotSupported if that provider doesn't implement StatsProvider.
+func (p *ProviderProxy) Stats(ctx context.Context, sessionID string) (*Stats, error) {
+	providerName, err := p.providerGetter(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	statsProvider, ok := provider.(StatsProvider)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s provider cannot report resource usage", ErrNotSupported, providerName)
+	}
+	return statsProvider.Stats(ctx, sessionID)
+}
+
+// CommitImage saves a session's sandbox as an image using the provider
+// determined by providerGetter. Returns ErrNotSupported if that provider
+// doesn't implement ImageCommitter.
+func (p *ProviderProxy) CommitImage(ctx context.Context, sessionID, imageRef string) (*CommittedImage, error) {
+	providerName, err := p.providerGetter(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	committer, ok := provider.(ImageCommitter)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s provider cannot commit images", ErrNotSupported, providerName)
+	}
+	return committer.CommitImage(ctx, sessionID, imageRef)
+}
+
+// Snapshot captures a session's sandbox using the provider determined by
+// providerGetter. Returns ErrNotSupported if that provider doesn't implement
+// Snapshotter.
+func (p *ProviderProxy) Snapshot(ctx context.Context, sessionID string) (string, error) {
+	providerName, err := p.providerGetter(ctx, sessionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return "", err
+	}
+
+	snapshotter, ok := provider.(Snapshotter)
+	if !ok {
+		return "", fmt.Errorf("%w: %s provider cannot snapshot sandboxes", ErrNotSupported, providerName)
+	}
+	return snapshotter.Snapshot(ctx, sessionID)
+}
+
+// CreateFromSnapshot creates newSessionID's sandbox from a snapshot using the
+// provider determined by providerGetter, running the same lifecycle hooks as
+// Create. Returns ErrNotSupported if that provider doesn't implement
+// Snapshotter.
+func (p *ProviderProxy) CreateFromSnapshot(ctx context.Context, newSessionID, snapshotID string, opts CreateOptions) (*Sandbox, error) {
+	providerName, err := p.providerGetter(ctx, newSessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotter, ok := provider.(Snapshotter)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s provider cannot snapshot sandboxes", ErrNotSupported, providerName)
+	}
+	return p.createWithHooks(ctx, providerName, newSessionID, opts, provider, func() (*Sandbox, error) {
+		return snapshotter.CreateFromSnapshot(ctx, newSessionID, snapshotID, opts)
+	})
+}
+
+// DeleteSnapshot delegates to all providers that implement Snapshotter, since
+// a snapshot ID doesn't identify its provider. Providers that don't have the
+// snapshot ignore it.
+func (p *ProviderProxy) DeleteSnapshot(ctx context.Context, snapshotID string) error {
+	var errs []error
+	for name, provider := range p.manager.providers {
+		if snapshotter, ok := provider.(Snapshotter); ok {
+			if err := snapshotter.DeleteSnapshot(ctx, snapshotID); err != nil && !errors.Is(err, ErrNotFound) {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ListSnapshots lists a session's snapshots using the provider determined
+// by providerGetter. Returns ErrNotSupported if that provider doesn't
+// implement SnapshotDiffer.
+func (p *ProviderProxy) ListSnapshots(ctx context.Context, sessionID string) ([]SnapshotInfo, error) {
+	providerName, err := p.providerGetter(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	differ, ok := provider.(SnapshotDiffer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s provider cannot compare snapshots", ErrNotSupported, providerName)
+	}
+	return differ.ListSnapshots(ctx, sessionID)
+}
+
+// DiffSnapshots streams the diff between a session's snapshots using the
+// provider determined by providerGetter. Returns ErrNotSupported if that
+// provider doesn't implement SnapshotDiffer.
+func (p *ProviderProxy) DiffSnapshots(ctx context.Context, sessionID, fromID, toID string) (io.ReadCloser, error) {
+	providerName, err := p.providerGetter(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	differ, ok := provider.(SnapshotDiffer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s provider cannot compare snapshots", ErrNotSupported, providerName)
+	}
+	return differ.DiffSnapshots(ctx, sessionID, fromID, toID)
+}
+
+// Logs streams a session's sandbox output using the provider determined by
+// providerGetter. Returns ErrNotSupported if that provider doesn't implement
+// LogStreamer.
+func (p *ProviderProxy) Logs(ctx context.Context, sessionID string, opts LogOptions) (io.ReadCloser, error) {
+	providerName, err := p.providerGetter(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	streamer, ok := provider.(LogStreamer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s provider cannot stream sandbox logs", ErrNotSupported, providerName)
+	}
+	return streamer.Logs(ctx, sessionID, opts)
+}
+
+// CopyTo extracts a tar archive into a session's sandbox using the provider
+// determined by providerGetter. Returns ErrNotSupported if that provider
+// doesn't implement FileCopier.
+func (p *ProviderProxy) CopyTo(ctx context.Context, sessionID, destPath string, archive io.Reader) error {
+	providerName, err := p.providerGetter(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return err
+	}
+
+	copier, ok := provider.(FileCopier)
+	if !ok {
+		return fmt.Errorf("%w: %s provider cannot copy files", ErrNotSupported, providerName)
+	}
+	return copier.CopyTo(ctx, sessionID, destPath, archive)
+}
+
+// CopyFrom archives a path in a session's sandbox using the provider
+// determined by providerGetter. Returns ErrNotSupported if that provider
+// doesn't implement FileCopier.
+func (p *ProviderProxy) CopyFrom(ctx context.Context, sessionID, srcPath string) (io.ReadCloser, error) {
+	providerName, err := p.providerGetter(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	copier, ok := provider.(FileCopier)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s provider cannot copy files", ErrNotSupported, providerName)
+	}
+	return copier.CopyFrom(ctx, sessionID, srcPath)
+}
+
+// Dial connects to a port inside a session's sandbox using the provider
+// determined by providerGetter. Returns ErrNotSupported if that provider
+// doesn't implement PortDialer.
+func (p *ProviderProxy) Dial(ctx context.Context, sessionID string, port int) (net.Conn, error) {
+	providerName, err := p.providerGetter(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider for session: %w", err)
+	}
+
+	provider, err := p.manager.GetProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer, ok := provider.(PortDialer)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s provider cannot dial sandbox ports", ErrNotSupported, providerName)
+	}
+	return dialer.Dial(ctx, sessionID, port)
+}
+
 // Watch watches all providers and merges events.
 func (p *ProviderProxy) Watch(ctx context.Context) (<-chan StateEvent, error) {
 	merged := make(chan StateEvent, 100)
@@ -392,3 +843,33 @@ func (p *ProviderProxy) CleanupImages(ctx context.Context) error {
 	}
 	return nil
 }
+
+// ActiveExecs merges running command counts from all providers that
+// implement ExecStatsProvider.
+func (p *ProviderProxy) ActiveExecs() map[string]int {
+	counts := make(map[string]int)
+	for _, provider := range p.manager.providers {
+		if esp, ok := provider.(ExecStatsProvider); ok {
+			for sessionID, n := range esp.ActiveExecs() {
+				counts[sessionID] += n
+			}
+		}
+	}
+	return counts
+}
+
+// LastActivity merges sandbox activity from all providers that implement
+// ActivityReporter, keeping the most recent time for each session.
+func (p *ProviderProxy) LastActivity() map[string]time.Time {
+	activity := make(map[string]time.Time)
+	for _, provider := range p.manager.providers {
+		if ar, ok := provider.(ActivityReporter); ok {
+			for sessionID, t := range ar.LastActivity() {
+				if t.After(activity[sessionID]) {
+					activity[sessionID] = t
+				}
+			}
+		}
+	}
+	return activity
+}
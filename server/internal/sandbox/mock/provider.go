@@ -2,10 +2,16 @@
 package mock
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"fmt"
 	"io"
+	"io/fs"
+	"maps"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,8 +31,12 @@ const DefaultMockImage = "mock:latest"
 type Provider struct {
 	mu        sync.RWMutex
 	sandboxes map[string]*sandbox.Sandbox
-	secrets   map[string]string // sessionID -> raw secret
-	image     string            // configured sandbox image
+	secrets   map[string]string    // sessionID -> raw secret
+	image     string               // configured sandbox image
+	snapshots map[string]string    // snapshotID -> snapshotted session ID
+	snapTimes map[string]time.Time // snapshotID -> when it was taken
+	nextSnap  int
+	copies    map[string][]byte // sessionID + "\x00" + path -> archive copied there
 
 	// Event subscribers for Watch functionality
 	subscribersMu sync.RWMutex
@@ -37,16 +47,20 @@ type Provider struct {
 	HTTPHandler http.Handler
 
 	// Configurable behaviors for testing
-	CreateFunc     func(ctx context.Context, sessionID string, opts sandbox.CreateOptions) (*sandbox.Sandbox, error)
-	StartFunc      func(ctx context.Context, sessionID string) error
-	StopFunc       func(ctx context.Context, sessionID string, timeout time.Duration) error
-	RemoveFunc     func(ctx context.Context, sessionID string, opts ...sandbox.RemoveOption) error
-	GetFunc        func(ctx context.Context, sessionID string) (*sandbox.Sandbox, error)
-	GetSecretFunc  func(ctx context.Context, sessionID string) (string, error)
-	ExecFunc       func(ctx context.Context, sessionID string, cmd []string, opts sandbox.ExecOptions) (*sandbox.ExecResult, error)
-	AttachFunc     func(ctx context.Context, sessionID string, opts sandbox.AttachOptions) (sandbox.PTY, error)
-	ExecStreamFunc func(ctx context.Context, sessionID string, cmd []string, opts sandbox.ExecStreamOptions) (sandbox.Stream, error)
-	WatchFunc      func(ctx context.Context) (<-chan sandbox.StateEvent, error)
+	CreateFunc        func(ctx context.Context, sessionID string, opts sandbox.CreateOptions) (*sandbox.Sandbox, error)
+	StartFunc         func(ctx context.Context, sessionID string) error
+	StopFunc          func(ctx context.Context, sessionID string, timeout time.Duration) error
+	PauseFunc         func(ctx context.Context, sessionID string) error
+	UnpauseFunc       func(ctx context.Context, sessionID string) error
+	RemoveFunc        func(ctx context.Context, sessionID string, opts ...sandbox.RemoveOption) error
+	GetFunc           func(ctx context.Context, sessionID string) (*sandbox.Sandbox, error)
+	WaitForStatusFunc func(ctx context.Context, sessionID string, target sandbox.Status, timeout time.Duration) (*sandbox.Sandbox, error)
+	GetSecretFunc     func(ctx context.Context, sessionID string) (string, error)
+	ExecFunc          func(ctx context.Context, sessionID string, cmd []string, opts sandbox.ExecOptions) (*sandbox.ExecResult, error)
+	AttachFunc        func(ctx context.Context, sessionID string, opts sandbox.AttachOptions) (sandbox.PTY, error)
+	ExecStreamFunc    func(ctx context.Context, sessionID string, cmd []string, opts sandbox.ExecStreamOptions) (sandbox.Stream, error)
+	WatchFunc         func(ctx context.Context) (<-chan sandbox.StateEvent, error)
+	HealthyFunc       func(ctx context.Context) error
 }
 
 // NewProvider creates a new mock provider with default behavior.
@@ -54,6 +68,9 @@ func NewProvider() *Provider {
 	return &Provider{
 		sandboxes: make(map[string]*sandbox.Sandbox),
 		secrets:   make(map[string]string),
+		snapshots: make(map[string]string),
+		snapTimes: make(map[string]time.Time),
+		copies:    make(map[string][]byte),
 		image:     DefaultMockImage,
 	}
 }
@@ -63,6 +80,9 @@ func NewProviderWithImage(image string) *Provider {
 	return &Provider{
 		sandboxes: make(map[string]*sandbox.Sandbox),
 		secrets:   make(map[string]string),
+		snapshots: make(map[string]string),
+		snapTimes: make(map[string]time.Time),
+		copies:    make(map[string][]byte),
 		image:     image,
 	}
 }
@@ -72,6 +92,14 @@ func (p *Provider) ImageExists(_ context.Context) bool {
 	return true
 }
 
+// Healthy reports the mock provider as healthy unless HealthyFunc is set.
+func (p *Provider) Healthy(ctx context.Context) error {
+	if p.HealthyFunc != nil {
+		return p.HealthyFunc(ctx)
+	}
+	return nil
+}
+
 // Image returns the configured sandbox image name.
 func (p *Provider) Image() string {
 	return p.image
@@ -104,16 +132,25 @@ func (p *Provider) Create(ctx context.Context, sessionID string, opts sandbox.Cr
 			Protocol:      "tcp",
 		},
 	}
+	for i, port := range opts.ExtraPorts {
+		ports = append(ports, sandbox.AssignedPort{
+			ContainerPort: port,
+			HostPort:      40889 + i,
+			HostIP:        "0.0.0.0",
+			Protocol:      "tcp",
+		})
+	}
 
 	now := time.Now()
 	s := &sandbox.Sandbox{
 		ID:        "mock-" + sessionID,
 		SessionID: sessionID,
 		Status:    sandbox.StatusCreated,
-		Image:     p.image,
+		Image:     cmp.Or(opts.Image, p.image),
 		CreatedAt: now,
 		Metadata:  map[string]string{"mock": "true"},
 		Ports:     ports,
+		Env:       maps.Clone(opts.Env),
 	}
 	p.sandboxes[sessionID] = s
 
@@ -191,6 +228,54 @@ func (p *Provider) Stop(ctx context.Context, sessionID string, timeout time.Dura
 	return nil
 }
 
+// Pause marks a running mock sandbox as paused.
+func (p *Provider) Pause(ctx context.Context, sessionID string) error {
+	if p.PauseFunc != nil {
+		return p.PauseFunc(ctx, sessionID)
+	}
+	return p.setPaused(sessionID, true)
+}
+
+// Unpause marks a paused mock sandbox as running.
+func (p *Provider) Unpause(ctx context.Context, sessionID string) error {
+	if p.UnpauseFunc != nil {
+		return p.UnpauseFunc(ctx, sessionID)
+	}
+	return p.setPaused(sessionID, false)
+}
+
+// setPaused moves a sandbox between running and paused.
+func (p *Provider) setPaused(sessionID string, paused bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, exists := p.sandboxes[sessionID]
+	if !exists {
+		return sandbox.ErrNotFound
+	}
+
+	from, to := sandbox.StatusRunning, sandbox.StatusPaused
+	if !paused {
+		from, to = to, from
+	}
+	switch s.Status {
+	case to:
+		return nil
+	case from:
+	default:
+		return sandbox.ErrNotRunning
+	}
+
+	s.Status = to
+	p.emitEvent(sandbox.StateEvent{
+		SessionID: sessionID,
+		Status:    to,
+		Timestamp: time.Now(),
+	})
+
+	return nil
+}
+
 // Remove removes a mock sandbox and optionally its associated data.
 // By default, secrets are preserved (simulates Docker volume preservation).
 // Pass sandbox.RemoveVolumes() to delete secrets (simulates complete cleanup).
@@ -225,6 +310,149 @@ func (p *Provider) Remove(ctx context.Context, sessionID string, opts ...sandbox
 	return nil
 }
 
+// Snapshot records a snapshot of the session's sandbox.
+// Implements sandbox.Snapshotter.
+func (p *Provider) Snapshot(_ context.Context, sessionID string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.sandboxes[sessionID]; !exists {
+		return "", sandbox.ErrNotFound
+	}
+	p.nextSnap++
+	snapshotID := fmt.Sprintf("mock-snapshot-%d", p.nextSnap)
+	p.snapshots[snapshotID] = sessionID
+	p.snapTimes[snapshotID] = time.Now()
+	return snapshotID, nil
+}
+
+// CreateFromSnapshot creates a mock sandbox like Create, using the snapshot
+// ID as its image. Implements sandbox.Snapshotter.
+func (p *Provider) CreateFromSnapshot(ctx context.Context, newSessionID, snapshotID string, opts sandbox.CreateOptions) (*sandbox.Sandbox, error) {
+	p.mu.RLock()
+	_, exists := p.snapshots[snapshotID]
+	p.mu.RUnlock()
+	if !exists {
+		return nil, sandbox.ErrNotFound
+	}
+
+	s, err := p.Create(ctx, newSessionID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if stored, ok := p.sandboxes[newSessionID]; ok {
+		stored.Image = snapshotID
+	}
+	cpy := *s
+	cpy.Image = snapshotID
+	return &cpy, nil
+}
+
+// DeleteSnapshot forgets a snapshot. Implements sandbox.Snapshotter.
+func (p *Provider) DeleteSnapshot(_ context.Context, snapshotID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.snapshots[snapshotID]; !exists {
+		return sandbox.ErrNotFound
+	}
+	delete(p.snapshots, snapshotID)
+	delete(p.snapTimes, snapshotID)
+	return nil
+}
+
+// ListSnapshots returns the session's snapshots in the order they were
+// taken. Implements sandbox.SnapshotDiffer.
+func (p *Provider) ListSnapshots(_ context.Context, sessionID string) ([]sandbox.SnapshotInfo, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := []sandbox.SnapshotInfo{}
+	for id, owner := range p.snapshots {
+		if owner == sessionID {
+			result = append(result, sandbox.SnapshotInfo{ID: id, CreatedAt: p.snapTimes[id]})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.Before(result[j].CreatedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+// DiffSnapshots returns a placeholder diff naming both sides, "live" for
+// the session's current workspace. Implements sandbox.SnapshotDiffer.
+func (p *Provider) DiffSnapshots(_ context.Context, sessionID, fromID, toID string) (io.ReadCloser, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.snapshots[fromID] != sessionID {
+		return nil, fmt.Errorf("%w: snapshot %s", sandbox.ErrNotFound, fromID)
+	}
+	to := toID
+	if toID == "" {
+		if _, exists := p.sandboxes[sessionID]; !exists {
+			return nil, sandbox.ErrNotFound
+		}
+		to = "live"
+	} else if p.snapshots[toID] != sessionID {
+		return nil, fmt.Errorf("%w: snapshot %s", sandbox.ErrNotFound, toID)
+	}
+	return io.NopCloser(strings.NewReader(fmt.Sprintf("--- %s\n+++ %s\n", fromID, to))), nil
+}
+
+// Snapshots returns the snapshots that haven't been deleted, keyed by
+// snapshot ID with the snapshotted session ID as value.
+func (p *Provider) Snapshots() map[string]string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	result := make(map[string]string, len(p.snapshots))
+	for k, v := range p.snapshots {
+		result[k] = v
+	}
+	return result
+}
+
+// CopyTo records the archive as the contents of destPath, for CopyFrom to
+// return. Implements sandbox.FileCopier.
+func (p *Provider) CopyTo(_ context.Context, sessionID, destPath string, archive io.Reader) error {
+	data, err := io.ReadAll(archive)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.sandboxes[sessionID]; !exists {
+		return sandbox.ErrNotFound
+	}
+	p.copies[sessionID+"\x00"+destPath] = data
+	return nil
+}
+
+// CopyFrom returns the last archive copied to srcPath with CopyTo.
+// Implements sandbox.FileCopier.
+func (p *Provider) CopyFrom(_ context.Context, sessionID, srcPath string) (io.ReadCloser, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if _, exists := p.sandboxes[sessionID]; !exists {
+		return nil, sandbox.ErrNotFound
+	}
+	data, ok := p.copies[sessionID+"\x00"+srcPath]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", fs.ErrNotExist, srcPath)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
 // Get returns a mock sandbox.
 func (p *Provider) Get(ctx context.Context, sessionID string) (*sandbox.Sandbox, error) {
 	if p.GetFunc != nil {
@@ -244,6 +472,22 @@ func (p *Provider) Get(ctx context.Context, sessionID string) (*sandbox.Sandbox,
 	return &cpy, nil
 }
 
+// WaitForStatus waits for the sandbox to reach the target status, following
+// the events emitted by Create/Start/Stop/Remove.
+func (p *Provider) WaitForStatus(ctx context.Context, sessionID string, target sandbox.Status, timeout time.Duration) (*sandbox.Sandbox, error) {
+	if p.WaitForStatusFunc != nil {
+		return p.WaitForStatusFunc(ctx, sessionID, target, timeout)
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, err := p.Watch(watchCtx)
+	if err != nil {
+		events = nil
+	}
+	return sandbox.WaitForEvents(ctx, sessionID, target, timeout, p.Get, events)
+}
+
 // GetSecret returns the raw shared secret for the sandbox.
 func (p *Provider) GetSecret(ctx context.Context, sessionID string) (string, error) {
 	if p.GetSecretFunc != nil {
@@ -0,0 +1,51 @@
+// Package podman provides a sandbox.Provider for rootless Podman. Podman
+// serves a Docker-compatible API, so the provider is the Docker provider
+// connected to the Podman socket, with containers configured for rootless
+// operation: unprivileged, in a keep-id user namespace, and run by crun.
+package podman
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/obot-platform/discobot/server/internal/config"
+	"github.com/obot-platform/discobot/server/internal/sandbox/docker"
+)
+
+// NewProvider creates a sandbox provider for the Podman service at
+// cfg.PodmanHost, or at DefaultHost if that isn't set.
+func NewProvider(cfg *config.Config, sessionProjectResolver docker.SessionProjectResolver, opts ...docker.Option) (*docker.Provider, error) {
+	host := cfg.PodmanHost
+	if host == "" {
+		var err error
+		if host, err = DefaultHost(); err != nil {
+			return nil, err
+		}
+	}
+
+	opts = append([]docker.Option{
+		docker.WithHost(host),
+		docker.WithRootless(docker.RootlessConfig{
+			UsernsMode: cfg.PodmanUserns,
+			Runtime:    cfg.PodmanRuntime,
+		}),
+	}, opts...)
+	provider, err := docker.NewProvider(cfg, sessionProjectResolver, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("podman at %s: %w", host, err)
+	}
+	return provider, nil
+}
+
+// DefaultHost returns the socket of the current user's Podman service
+// (`systemctl --user start podman.socket`): $XDG_RUNTIME_DIR/podman/podman.sock,
+// or /run/user/<uid>/podman/podman.sock if XDG_RUNTIME_DIR isn't set.
+func DefaultHost() (string, error) {
+	runtimeDir := cmp.Or(os.Getenv("XDG_RUNTIME_DIR"), fmt.Sprintf("/run/user/%d", os.Getuid()))
+	if !filepath.IsAbs(runtimeDir) {
+		return "", fmt.Errorf("XDG_RUNTIME_DIR must be an absolute path, got %q", runtimeDir)
+	}
+	return "unix://" + filepath.Join(runtimeDir, "podman", "podman.sock"), nil
+}
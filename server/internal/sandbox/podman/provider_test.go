@@ -0,0 +1,25 @@
+package podman
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestDefaultHost(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1234")
+	if got, err := DefaultHost(); err != nil || got != "unix:///run/user/1234/podman/podman.sock" {
+		t.Errorf("DefaultHost() = %q, %v", got, err)
+	}
+
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	want := fmt.Sprintf("unix:///run/user/%d/podman/podman.sock", os.Getuid())
+	if got, err := DefaultHost(); err != nil || got != want {
+		t.Errorf("DefaultHost() without XDG_RUNTIME_DIR = %q, %v, want %q", got, err, want)
+	}
+
+	t.Setenv("XDG_RUNTIME_DIR", "relative")
+	if _, err := DefaultHost(); err == nil {
+		t.Error("expected an error for a relative XDG_RUNTIME_DIR")
+	}
+}
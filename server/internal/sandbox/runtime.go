@@ -4,8 +4,13 @@ package sandbox
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"path"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -19,6 +24,11 @@ type Provider interface {
 	// Image returns the configured sandbox image name.
 	Image() string
 
+	// Healthy reports whether the provider can create sandboxes right now.
+	// It returns nil when ready, or an error describing what is wrong (for
+	// example, an unreachable container runtime or images still downloading).
+	Healthy(ctx context.Context) error
+
 	// Create creates a new sandbox for the given session.
 	// The sandbox is created but not started.
 	// A single port (3002) is always exposed and assigned a random host port.
@@ -32,6 +42,15 @@ type Provider interface {
 	// The timeout specifies how long to wait before force-killing.
 	Stop(ctx context.Context, sessionID string, timeout time.Duration) error
 
+	// Pause freezes a running sandbox's processes. It keeps its memory but
+	// uses no CPU until Unpause. Pausing a paused sandbox is a no-op; a
+	// sandbox that isn't running returns ErrNotRunning.
+	Pause(ctx context.Context, sessionID string) error
+
+	// Unpause resumes a paused sandbox. Unpausing a running sandbox is a
+	// no-op; a stopped sandbox returns ErrNotRunning.
+	Unpause(ctx context.Context, sessionID string) error
+
 	// Remove removes a sandbox and optionally its associated data volumes.
 	// The sandbox must be stopped first.
 	// By default, data volumes are preserved (useful for rebuilds).
@@ -41,6 +60,12 @@ type Provider interface {
 	// Get returns the current state of a sandbox.
 	Get(ctx context.Context, sessionID string) (*Sandbox, error)
 
+	// WaitForStatus blocks until the sandbox reaches the target status and
+	// returns it, or returns ErrWaitTimeout once timeout (0 = none) expires.
+	// Providers wait on their event stream rather than polling Get; see
+	// WaitForEvents for the shared semantics.
+	WaitForStatus(ctx context.Context, sessionID string, target Status, timeout time.Duration) (*Sandbox, error)
+
 	// GetSecret returns the shared secret for the sandbox.
 	// This is the raw secret stored during creation, not the hashed version.
 	GetSecret(ctx context.Context, sessionID string) (string, error)
@@ -75,7 +100,7 @@ type Provider interface {
 	// The channel is closed when the context is cancelled or when an
 	// unrecoverable error occurs. Callers should watch for channel closure.
 	//
-	// Events include: created, running, stopped, failed, removed.
+	// Events include: created, running, paused, stopped, failed, removed.
 	// The "removed" status indicates a sandbox was deleted (possibly externally).
 	//
 	// For Docker, this watches the Docker events API for container lifecycle events.
@@ -92,6 +117,28 @@ type DockerProxyProvider interface {
 	DockerTransport(projectID string) (http.RoundTripper, error)
 }
 
+// DiskLimitChecker is an optional interface for providers that can only
+// apply ResourceConfig.DiskMB on some hosts, such as Docker, which needs a
+// storage driver that supports size options.
+type DiskLimitChecker interface {
+	// CheckDiskLimits returns an error wrapping ErrNotSupported if sandboxes
+	// can't be given a disk limit.
+	CheckDiskLimits() error
+}
+
+// DockerSocketProvider is an optional interface that sandbox providers can implement
+// to forward a session's Docker daemon (e.g., the one inside its VZ VM) to a Unix
+// socket on the host, so the Docker CLI can be used with DOCKER_HOST=unix://<socket>.
+type DockerSocketProvider interface {
+	// DockerSocketProxy starts forwarding socketPath to the Docker daemon serving
+	// the session. If a proxy is already running for the session on the same
+	// path it is returned as-is; a proxy on a different path is replaced.
+	DockerSocketProxy(ctx context.Context, sessionID, socketPath string) (*DockerSocketProxy, error)
+
+	// StopDockerSocketProxy stops the session's proxy, if any.
+	StopDockerSocketProxy(ctx context.Context, sessionID string) error
+}
+
 // ProviderStatus represents the current status of a sandbox provider.
 type ProviderStatus struct {
 	Available bool   `json:"available"`
@@ -114,6 +161,205 @@ type ImageCleaner interface {
 	CleanupImages(ctx context.Context) error
 }
 
+// ExecStatsProvider is an optional interface that sandbox providers can implement
+// to report how many commands (Exec, Attach, ExecStream) are running per sandbox.
+type ExecStatsProvider interface {
+	// ActiveExecs returns the number of running commands keyed by session ID.
+	// Sessions with no running commands are omitted.
+	ActiveExecs() map[string]int
+}
+
+// ActivityReporter is an optional interface that sandbox providers can implement
+// to report when each sandbox was last used through the provider (Exec, Attach,
+// ExecStream, or HTTPClient). The idle monitor uses it alongside chat activity.
+type ActivityReporter interface {
+	// LastActivity returns the time of the most recent use keyed by session ID.
+	// Sandboxes the provider hasn't seen used since it started are omitted.
+	LastActivity() map[string]time.Time
+}
+
+// VolumeResizer is an optional interface that sandbox providers can implement
+// to grow a session's persistent storage without recreating its sandbox.
+type VolumeResizer interface {
+	// ResizeVolume grows the storage backing the session's data volume to
+	// newSizeMB. Returns ErrVolumeShrink if that is not larger than the
+	// current size. The sandbox may be restarted to apply the new size.
+	ResizeVolume(ctx context.Context, sessionID string, newSizeMB int) error
+}
+
+// FileChangeKind is how a path changed in a sandbox's filesystem.
+type FileChangeKind string
+
+const (
+	FileAdded    FileChangeKind = "added"
+	FileModified FileChangeKind = "modified"
+	FileDeleted  FileChangeKind = "deleted"
+)
+
+// FileChange is a path changed in a sandbox's filesystem relative to its image.
+type FileChange struct {
+	Path string         `json:"path"`
+	Kind FileChangeKind `json:"kind"`
+}
+
+// FilesystemDiffer is an optional interface that sandbox providers can
+// implement to report what changed in a sandbox's filesystem since it was
+// created from its image (like `docker diff`).
+type FilesystemDiffer interface {
+	// FilesystemDiff returns the changed paths, sorted by path. Changes in
+	// volumes (such as the session's data volume) are not included.
+	FilesystemDiff(ctx context.Context, sessionID string) ([]FileChange, error)
+}
+
+// CommittedImageLabel marks images created by ImageCommitter. Such images
+// are never treated as old sandbox images by ImageCleaner.
+const CommittedImageLabel = "io.discobot.committed-image"
+
+// CommittedImage describes an image created from a sandbox.
+type CommittedImage struct {
+	ID        string   `json:"id"`
+	Ref       string   `json:"ref"`
+	SizeBytes int64    `json:"sizeBytes"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// ImageCommitter is an optional interface that sandbox providers can
+// implement to save a sandbox's filesystem as a reusable image (like
+// `docker commit`).
+type ImageCommitter interface {
+	// CommitImage saves the sandbox's filesystem as imageRef. Volumes (such
+	// as the session's data volume and workspace) are not included, and the
+	// environment variables and labels discobot set on the sandbox are
+	// blanked so they don't leak into sandboxes created from the image.
+	CommitImage(ctx context.Context, sessionID, imageRef string) (*CommittedImage, error)
+}
+
+// Snapshotter is an optional interface that sandbox providers can implement
+// to capture a sandbox's exact state (its filesystem and data volume) and
+// create new sandboxes from it, e.g. to fork a session.
+type Snapshotter interface {
+	// Snapshot captures the session's sandbox and returns an ID for
+	// CreateFromSnapshot. Returns ErrNotFound if the session has no sandbox.
+	Snapshot(ctx context.Context, sessionID string) (snapshotID string, err error)
+
+	// CreateFromSnapshot creates newSessionID's sandbox like Create, starting
+	// from the snapshot's filesystem and data instead of a fresh image and
+	// empty volume. Returns ErrNotFound if the snapshot doesn't exist.
+	CreateFromSnapshot(ctx context.Context, newSessionID, snapshotID string, opts CreateOptions) (*Sandbox, error)
+
+	// DeleteSnapshot removes a snapshot. Sandboxes already created from it
+	// are not affected.
+	DeleteSnapshot(ctx context.Context, snapshotID string) error
+}
+
+// SnapshotInfo describes a snapshot of a session's sandbox.
+type SnapshotInfo struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SnapshotDiffer is an optional interface for Snapshotter providers that can
+// list a session's snapshots and compare them.
+type SnapshotDiffer interface {
+	// ListSnapshots returns the snapshots taken of the session's sandbox,
+	// oldest first.
+	ListSnapshots(ctx context.Context, sessionID string) ([]SnapshotInfo, error)
+
+	// DiffSnapshots streams a unified diff of the session's workspace from
+	// snapshot fromID to snapshot toID, or to the workspace as it is now if
+	// toID is empty. Returns ErrNotFound if either snapshot isn't one of the
+	// session's, or toID is empty and the session has no sandbox. The caller
+	// must close the returned reader.
+	DiffSnapshots(ctx context.Context, sessionID, fromID, toID string) (io.ReadCloser, error)
+}
+
+// LogOptions selects which sandbox output Logs returns.
+type LogOptions struct {
+	Follow bool      // Keep streaming new output until the context is canceled or the sandbox stops
+	Tail   int       // Only return the last Tail lines (0 returns all output)
+	Since  time.Time // Only return output written after Since (zero returns all output)
+}
+
+// LogStreamer is an optional interface that sandbox providers can implement
+// to expose a sandbox's output (like `docker logs`), so startup failures can
+// be diagnosed without access to the host.
+type LogStreamer interface {
+	// Logs returns the sandbox's combined stdout and stderr. The caller must
+	// close the returned reader.
+	Logs(ctx context.Context, sessionID string, opts LogOptions) (io.ReadCloser, error)
+}
+
+// Renamer is an optional interface that sandbox providers can implement to
+// move a sandbox to another session ID, e.g. when a session is re-keyed after
+// being imported from another instance.
+type Renamer interface {
+	// Rename makes oldSessionID's sandbox the sandbox of newSessionID.
+	// Returns ErrNotFound if oldSessionID has no sandbox and ErrAlreadyExists
+	// if newSessionID already has one.
+	Rename(ctx context.Context, oldSessionID, newSessionID string) error
+}
+
+// PortDialer is an optional interface that sandbox providers can implement
+// to connect to any TCP port exposed by a sandbox (the agent API port or one
+// of CreateOptions.ExtraPorts), including when the port isn't reachable from
+// the host directly, such as inside a VM.
+type PortDialer interface {
+	// Dial connects to port inside the session's sandbox. Returns
+	// ErrNotRunning if the sandbox isn't running.
+	Dial(ctx context.Context, sessionID string, port int) (net.Conn, error)
+}
+
+// Stats is a point-in-time sample of a running sandbox's resource usage.
+type Stats struct {
+	CPUPercent       float64   `json:"cpuPercent"`       // Share of one CPU (can exceed 100 on multi-core hosts)
+	MemoryUsedBytes  uint64    `json:"memoryUsedBytes"`  // Excluding reclaimable page cache
+	MemoryLimitBytes uint64    `json:"memoryLimitBytes"` // Effective limit (host memory if unlimited)
+	NetworkRxBytes   uint64    `json:"networkRxBytes"`   // Total received since the sandbox started
+	NetworkTxBytes   uint64    `json:"networkTxBytes"`   // Total sent since the sandbox started
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+// StatsProvider is an optional interface that sandbox providers can
+// implement to report a sandbox's resource usage.
+type StatsProvider interface {
+	// Stats samples the sandbox's resource usage. Returns ErrNotRunning if
+	// the sandbox isn't running.
+	Stats(ctx context.Context, sessionID string) (*Stats, error)
+}
+
+// FileCopier is an optional interface that sandbox providers can implement
+// to copy files into and out of a sandbox as tar archives (like `docker cp`).
+// Paths are absolute paths inside the sandbox; callers confine them to the
+// workspace with WorkspacePath.
+type FileCopier interface {
+	// CopyTo extracts the tar archive into the directory destPath, creating
+	// it if needed. The sandbox must be running.
+	CopyTo(ctx context.Context, sessionID, destPath string, archive io.Reader) error
+
+	// CopyFrom returns a tar archive of srcPath, a file or directory, with
+	// entries named relative to srcPath's parent. Returns an error wrapping
+	// fs.ErrNotExist if srcPath doesn't exist. The caller must close the
+	// returned reader.
+	CopyFrom(ctx context.Context, sessionID, srcPath string) (io.ReadCloser, error)
+}
+
+// WorkspaceDir is where the workspace is checked out inside a sandbox.
+const WorkspaceDir = "/home/discobot/workspace"
+
+// WorkspacePath resolves p, either absolute or relative to WorkspaceDir, to
+// a clean absolute path. Returns ErrOutsideWorkspace if the result isn't
+// WorkspaceDir or inside it.
+func WorkspacePath(p string) (string, error) {
+	if !path.IsAbs(p) {
+		p = path.Join(WorkspaceDir, p)
+	}
+	p = path.Clean(p)
+	if p != WorkspaceDir && !strings.HasPrefix(p, WorkspaceDir+"/") {
+		return "", fmt.Errorf("%w: %s", ErrOutsideWorkspace, p)
+	}
+	return p, nil
+}
+
 // RemoveOption configures sandbox removal behavior.
 type RemoveOption func(*RemoveConfig)
 
@@ -173,6 +419,7 @@ const (
 	StatusRunning Status = "running" // Sandbox is running
 	StatusStopped Status = "stopped" // Sandbox has stopped
 	StatusFailed  Status = "failed"  // Sandbox failed to start or crashed
+	StatusPaused  Status = "paused"  // Sandbox processes are frozen; memory is kept
 )
 
 // StateEvent represents a sandbox state change event.
@@ -182,6 +429,10 @@ type StateEvent struct {
 	Status    Status    // The new status (or StatusRemoved for deletion)
 	Timestamp time.Time // When the event occurred
 	Error     string    // Error message if status is StatusFailed
+
+	// Restarting is set when the sandbox exited but its restart policy will
+	// start it again, so the outage is expected to be brief.
+	Restarting bool
 }
 
 // StateEventType indicates what kind of state change occurred.
@@ -198,6 +449,10 @@ const (
 type CreateOptions struct {
 	Labels map[string]string // Sandbox labels/tags for identification
 
+	// Image overrides the provider's sandbox image, e.g. for a project that
+	// needs a different toolchain. Empty uses the provider's image.
+	Image string
+
 	// SharedSecret is the secret used for authenticating requests to the sandbox.
 	// The provider stores this secret and makes a salted+hashed version available
 	// to the sandbox via the DISCOBOT_SECRET environment variable.
@@ -220,6 +475,166 @@ type CreateOptions struct {
 
 	// Resources defines resource limits for the sandbox.
 	Resources ResourceConfig
+
+	// ResourceRequests defines the resources the sandbox is guaranteed when
+	// the host is under contention. Requests below the limits in Resources
+	// let sandboxes be oversubscribed.
+	ResourceRequests ResourceRequests
+
+	// StopSignal is the signal sent to the sandbox on stop (e.g. "SIGQUIT"),
+	// set from the workspace's stopSignal. Empty uses the provider default
+	// (SANDBOX_STOP_SIGNAL, or SIGTERM).
+	StopSignal string
+
+	// NetworkMode controls outbound network access (see NetworkMode* constants).
+	// Empty means NetworkModeProxied.
+	NetworkMode string
+
+	// RestartPolicy controls whether the runtime restarts the sandbox when it
+	// exits (see RestartPolicy* constants). Empty uses the provider default
+	// (SANDBOX_RESTART_POLICY).
+	RestartPolicy string
+
+	// ExtraPorts are TCP ports inside the sandbox to expose besides the
+	// agent API port, e.g. for dev servers. See ValidateExtraPorts.
+	ExtraPorts []int
+
+	// DisableProxyCache tells the agent to turn off the proxy's response
+	// cache. Filtering and header injection still apply.
+	DisableProxyCache bool
+
+	// CloneDepth limits how many commits of history the agent clones into
+	// the workspace. 0 clones the full history.
+	CloneDepth int
+
+	// CloneAllBranches clones every branch of the workspace source instead
+	// of only its current branch.
+	CloneAllBranches bool
+
+	// CloneSubmodules clones the workspace's git submodules along with it
+	// and initializes them.
+	CloneSubmodules bool
+
+	// Filesystem forces the agent's session filesystem (see Filesystem*
+	// constants). Empty lets the agent detect it.
+	Filesystem string
+
+	// Env holds extra environment variables for the sandbox, such as the
+	// defaults of the session's agent type. They never replace the
+	// variables discobot sets itself.
+	Env map[string]string
+}
+
+// AgentAPIPort is the port the agent API listens on inside the sandbox.
+const AgentAPIPort = 3002
+
+// MaxExtraPorts is the most extra ports a sandbox can expose.
+const MaxExtraPorts = 16
+
+// ValidateExtraPorts checks that ports are distinct TCP ports other than
+// AgentAPIPort, and that there are at most MaxExtraPorts of them.
+func ValidateExtraPorts(ports []int) error {
+	if len(ports) > MaxExtraPorts {
+		return fmt.Errorf("at most %d extra ports can be exposed, got %d", MaxExtraPorts, len(ports))
+	}
+	seen := make(map[int]bool, len(ports))
+	for _, port := range ports {
+		switch {
+		case port < 1 || port > 65535:
+			return fmt.Errorf("extra port %d is not a valid port", port)
+		case port == AgentAPIPort:
+			return fmt.Errorf("extra port %d is reserved for the agent API", port)
+		case seen[port]:
+			return fmt.Errorf("extra port %d is listed more than once", port)
+		}
+		seen[port] = true
+	}
+	return nil
+}
+
+// Sandbox network modes. The agent enforces them with firewall rules inside
+// the sandbox, so code in the sandbox can't bypass them by unsetting proxy
+// environment variables.
+const (
+	// NetworkModeProxied allows outbound connections only through the sandbox's
+	// MITM proxy (the default).
+	NetworkModeProxied = "proxied"
+	// NetworkModeIsolated blocks all outbound connections. The sandbox remains
+	// reachable by the server.
+	NetworkModeIsolated = "isolated"
+	// NetworkModeOpen allows direct outbound connections.
+	NetworkModeOpen = "open"
+)
+
+// ValidNetworkMode reports whether mode is a known network mode (or empty for the default).
+func ValidNetworkMode(mode string) bool {
+	switch mode {
+	case "", NetworkModeProxied, NetworkModeIsolated, NetworkModeOpen:
+		return true
+	default:
+		return false
+	}
+}
+
+// EffectiveNetworkMode returns mode, or NetworkModeProxied if mode is empty.
+func EffectiveNetworkMode(mode string) string {
+	if mode == "" {
+		return NetworkModeProxied
+	}
+	return mode
+}
+
+// Sandbox restart policies, applied by the runtime (e.g. the Docker daemon)
+// without involving the server.
+const (
+	// RestartPolicyNo never restarts the sandbox automatically.
+	RestartPolicyNo = "no"
+	// RestartPolicyOnFailure restarts the sandbox when it exits with an error,
+	// up to SANDBOX_RESTART_MAX_RETRIES times.
+	RestartPolicyOnFailure = "on-failure"
+	// RestartPolicyUnlessStopped restarts the sandbox whenever it exits, unless
+	// it was stopped explicitly.
+	RestartPolicyUnlessStopped = "unless-stopped"
+)
+
+// ValidRestartPolicy reports whether policy is a known restart policy (or empty for the default).
+func ValidRestartPolicy(policy string) bool {
+	switch policy {
+	case "", RestartPolicyNo, RestartPolicyOnFailure, RestartPolicyUnlessStopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// stopSignals are the signals a sandbox can be stopped with, in the forms
+// Docker accepts: the agent shuts down cleanly on SIGINT, SIGTERM and
+// SIGQUIT only, and any other signal would kill it without cleanup.
+var stopSignals = []string{"SIGINT", "INT", "2", "SIGTERM", "TERM", "15", "SIGQUIT", "QUIT", "3"}
+
+// ValidStopSignal reports whether signal is one the agent handles on stop
+// (or is empty for the default).
+func ValidStopSignal(signal string) bool {
+	return signal == "" || slices.Contains(stopSignals, signal)
+}
+
+// Session filesystems the agent can layer over the workspace.
+const (
+	// FilesystemOverlayFS uses an overlayfs upper directory per session.
+	FilesystemOverlayFS = "overlayfs"
+	// FilesystemAgentFS uses an agentfs database per session, which copes
+	// better with very large trees such as node_modules.
+	FilesystemAgentFS = "agentfs"
+)
+
+// ValidFilesystem reports whether fs is a known session filesystem (or empty for auto-detection).
+func ValidFilesystem(fs string) bool {
+	switch fs {
+	case "", FilesystemOverlayFS, FilesystemAgentFS:
+		return true
+	default:
+		return false
+	}
 }
 
 // ResourceConfig defines resource limits for the sandbox.
@@ -230,19 +645,69 @@ type ResourceConfig struct {
 	Timeout  time.Duration // Max sandbox lifetime (0 = no limit)
 }
 
+// MinMemoryMB is the smallest memory limit a sandbox can be given; the agent
+// does not start reliably with less.
+const MinMemoryMB = 256
+
+// MinDiskMB and MaxDiskMB bound the disk limit a sandbox can be given: less
+// than MinDiskMB doesn't leave room for the agent and its tools, and Docker
+// storage drivers reject sizes far beyond any real disk.
+const (
+	MinDiskMB = 1024
+	MaxDiskMB = 16 << 20 // 16 TiB
+)
+
+// Validate checks that the limits are usable. Zero values mean "no limit"
+// and are always valid.
+func (r ResourceConfig) Validate() error {
+	switch {
+	case r.CPUCores < 0:
+		return fmt.Errorf("cpuCores must not be negative, got %g", r.CPUCores)
+	case r.MemoryMB < 0:
+		return fmt.Errorf("memoryMB must not be negative, got %d", r.MemoryMB)
+	case r.MemoryMB > 0 && r.MemoryMB < MinMemoryMB:
+		return fmt.Errorf("memoryMB must be at least %d, got %d", MinMemoryMB, r.MemoryMB)
+	case r.DiskMB < 0:
+		return fmt.Errorf("diskMB must not be negative, got %d", r.DiskMB)
+	case r.DiskMB > 0 && (r.DiskMB < MinDiskMB || r.DiskMB > MaxDiskMB):
+		return fmt.Errorf("diskMB must be between %d and %d, got %d", MinDiskMB, MaxDiskMB, r.DiskMB)
+	}
+	return nil
+}
+
+// ResourceRequests defines the resources a sandbox needs, as opposed to the
+// most it may use. A sandbox can use up to its limits while the host has
+// capacity to spare, but is only guaranteed its requests under contention.
+// Requests above the corresponding limit are capped at the limit.
+type ResourceRequests struct {
+	MemoryMB int     // Memory reclaimed last under host memory pressure, in MB (0 = none)
+	CPUCores float64 // CPU share relative to other sandboxes, in cores (0 = runtime default)
+}
+
 // ExecOptions configures non-interactive command execution.
 type ExecOptions struct {
 	WorkDir string            // Working directory for command
 	Env     map[string]string // Additional environment variables
 	User    string            // User to run as (empty = default)
 	Stdin   io.Reader         // Optional stdin input
+
+	// Timeout bounds the command's run time (0 = no timeout beyond ctx).
+	// When it elapses, the partial output is returned with TimedOut set.
+	Timeout time.Duration
+
+	// MaxOutputBytes caps the combined stdout and stderr kept in memory
+	// (0 = DefaultExecMaxOutputBytes, negative = unlimited). Output beyond
+	// the cap is discarded and Truncated is set on the result.
+	MaxOutputBytes int64
 }
 
 // ExecResult contains the result of a non-interactive command execution.
 type ExecResult struct {
-	ExitCode int    // Exit code of the command
-	Stdout   []byte // Standard output
-	Stderr   []byte // Standard error
+	ExitCode  int    `json:"exitCode"`            // Exit code of the command (-1 if it timed out)
+	Stdout    []byte `json:"stdout"`              // Standard output
+	Stderr    []byte `json:"stderr"`              // Standard error
+	Truncated bool   `json:"truncated,omitempty"` // Output exceeded MaxOutputBytes and was cut off
+	TimedOut  bool   `json:"timedOut,omitempty"`  // Command was stopped because Timeout elapsed
 }
 
 // AttachOptions configures interactive PTY session creation.
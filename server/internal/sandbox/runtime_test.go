@@ -0,0 +1,80 @@
+package sandbox
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateExtraPorts(t *testing.T) {
+	tooMany := make([]int, MaxExtraPorts+1)
+	for i := range tooMany {
+		tooMany[i] = 8000 + i
+	}
+
+	tests := []struct {
+		name    string
+		ports   []int
+		wantErr bool
+	}{
+		{name: "none", ports: nil},
+		{name: "valid ports", ports: []int{3000, 8080, 65535}},
+		{name: "zero", ports: []int{0}, wantErr: true},
+		{name: "out of range", ports: []int{70000}, wantErr: true},
+		{name: "agent API port", ports: []int{AgentAPIPort}, wantErr: true},
+		{name: "duplicate", ports: []int{8080, 8080}, wantErr: true},
+		{name: "too many", ports: tooMany, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateExtraPorts(tt.ports)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateExtraPorts(%v) error = %v, wantErr %v", tt.ports, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidStopSignal(t *testing.T) {
+	for _, signal := range []string{"", "SIGQUIT", "QUIT", "SIGINT", "15"} {
+		if !ValidStopSignal(signal) {
+			t.Errorf("ValidStopSignal(%q) = false, want true", signal)
+		}
+	}
+	for _, signal := range []string{"sigquit", "SIG-QUIT", "0", "SIGTERM; rm -rf /", "100", "SIGKILL", "SIGRTMIN+3", "SIGUSR1", "9"} {
+		if ValidStopSignal(signal) {
+			t.Errorf("ValidStopSignal(%q) = true, want false", signal)
+		}
+	}
+}
+
+func TestWorkspacePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "", want: WorkspaceDir},
+		{path: ".", want: WorkspaceDir},
+		{path: "src/main.go", want: WorkspaceDir + "/src/main.go"},
+		{path: "src/../README.md", want: WorkspaceDir + "/README.md"},
+		{path: WorkspaceDir + "/docs/", want: WorkspaceDir + "/docs"},
+		{path: "..", wantErr: true},
+		{path: "src/../../.ssh", wantErr: true},
+		{path: "/etc/passwd", wantErr: true},
+		{path: WorkspaceDir + "-other/file", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := WorkspacePath(tt.path)
+		if tt.wantErr {
+			if !errors.Is(err, ErrOutsideWorkspace) {
+				t.Errorf("WorkspacePath(%q) = %q, %v; want ErrOutsideWorkspace", tt.path, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("WorkspacePath(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
@@ -129,6 +129,9 @@ type ReadFileResponse struct {
 	Content  string `json:"content"`
 	Encoding string `json:"encoding"` // "utf8" or "base64"
 	Size     int64  `json:"size"`
+	// Hash is the SHA-256 of the decoded content, added by the server so
+	// clients can pass it back as WriteFileRequest.ExpectedHash.
+	Hash string `json:"hash,omitempty"`
 }
 
 // WriteFileRequest is the POST /files/write request body.
@@ -136,12 +139,17 @@ type WriteFileRequest struct {
 	Path     string `json:"path"`
 	Content  string `json:"content"`
 	Encoding string `json:"encoding,omitempty"` // defaults to "utf8"
+	// ExpectedHash, if set, makes the write conditional: the server rejects
+	// it with a conflict unless the file's current content has this hash.
+	// Checked by the server; not sent to the sandbox.
+	ExpectedHash string `json:"expectedHash,omitempty"`
 }
 
 // WriteFileResponse is the POST /files/write response.
 type WriteFileResponse struct {
 	Path string `json:"path"`
 	Size int64  `json:"size"`
+	Hash string `json:"hash,omitempty"` // SHA-256 of the written content, added by the server
 }
 
 // DeleteFileRequest is the POST /files/delete request body.
@@ -167,6 +175,147 @@ type RenameFileResponse struct {
 	NewPath string `json:"newPath"`
 }
 
+// KVEntry is the GET /kv/{key} and PUT /kv/{key} response.
+type KVEntry struct {
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	Size      int64  `json:"size"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// PutKVRequest is the PUT /kv/{key} request body.
+type PutKVRequest struct {
+	Value string `json:"value"`
+}
+
+// DeleteKVResponse is the DELETE /kv/{key} response.
+type DeleteKVResponse struct {
+	Key string `json:"key"`
+}
+
+// CreateUploadRequest is the POST /files/uploads request body.
+type CreateUploadRequest struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"` // Total bytes the upload will contain
+}
+
+// UploadStatus is the response to every resumable upload request except
+// DELETE: the upload's progress, and whether the file is in place.
+type UploadStatus struct {
+	UploadID string `json:"uploadId"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Offset   int64  `json:"offset"` // Bytes received; the next chunk starts here
+	Complete bool   `json:"complete"`
+}
+
+// UploadOffsetConflictResponse is the 409 response to a chunk that doesn't
+// start at the upload's current offset.
+type UploadOffsetConflictResponse struct {
+	Error  string `json:"error"`
+	Offset int64  `json:"offset"`
+}
+
+// AbortUploadResponse is the DELETE /files/uploads/{id} response.
+type AbortUploadResponse struct {
+	UploadID string `json:"uploadId"`
+}
+
+// NetworkTestRequest is the POST /network/test request body.
+type NetworkTestRequest struct {
+	Target    string `json:"target"`              // URL or host[:port]; bare hosts are treated as https
+	TimeoutMs int    `json:"timeoutMs,omitempty"` // Per-step timeout (default 10000, max 30000)
+}
+
+// NetworkTestStep is the outcome of one step of a network test.
+type NetworkTestStep struct {
+	OK         bool   `json:"ok"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+// NetworkTestProxy describes the sandbox proxy's part in a network test.
+type NetworkTestProxy struct {
+	Used    bool   `json:"used"`
+	URL     string `json:"url,omitempty"`
+	Allowed *bool  `json:"allowed,omitempty"` // Unset if the proxy wasn't reached
+	Error   string `json:"error,omitempty"`
+}
+
+// NetworkTestResponse is the POST /network/test response.
+type NetworkTestResponse struct {
+	Target    string           `json:"target"`
+	URL       string           `json:"url"`
+	Reachable bool             `json:"reachable"`
+	DNS       NetworkTestDNS   `json:"dns"`
+	Proxy     NetworkTestProxy `json:"proxy"`
+	Connect   NetworkTestStep  `json:"connect"` // To the target, or to the proxy when one is used
+	TLS       *NetworkTestTLS  `json:"tls,omitempty"`
+	HTTP      *NetworkTestHTTP `json:"http,omitempty"`
+}
+
+// NetworkTestDNS is the DNS step of a network test.
+type NetworkTestDNS struct {
+	NetworkTestStep
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// NetworkTestTLS is the TLS step of a network test.
+type NetworkTestTLS struct {
+	NetworkTestStep
+	Protocol string `json:"protocol,omitempty"`
+	Issuer   string `json:"issuer,omitempty"`
+}
+
+// NetworkTestHTTP is the HTTP step of a network test.
+type NetworkTestHTTP struct {
+	NetworkTestStep
+	Status int `json:"status,omitempty"`
+}
+
+// ProxyDomainStats holds the sandbox proxy's traffic counters for one domain.
+type ProxyDomainStats struct {
+	Requests      int64   `json:"requests"` // Including blocked requests
+	Blocked       int64   `json:"blocked"`
+	BytesSent     int64   `json:"bytes_sent"`
+	BytesReceived int64   `json:"bytes_received"`
+	CacheHits     int64   `json:"cache_hits"`
+	CacheMisses   int64   `json:"cache_misses"`
+	CacheHitRate  float64 `json:"cache_hit_rate"`
+}
+
+// ProxyStatsResponse is the GET /proxy/stats response.
+type ProxyStatsResponse struct {
+	Since   string                      `json:"since"` // ISO timestamp when the proxy started counting
+	Totals  ProxyDomainStats            `json:"totals"`
+	Domains map[string]ProxyDomainStats `json:"domains"`
+}
+
+// ProcessFDStats holds a sandbox process's open file descriptor count and
+// its RLIMIT_NOFILE limits.
+type ProcessFDStats struct {
+	PID       int    `json:"pid"`
+	Command   string `json:"command"`
+	Open      int    `json:"open"`
+	SoftLimit *int64 `json:"softLimit"` // nil if unlimited
+	HardLimit *int64 `json:"hardLimit"` // nil if unlimited
+}
+
+// SystemFDStats holds the kernel-wide file handle counts (fs.file-nr).
+type SystemFDStats struct {
+	Allocated int64 `json:"allocated"`
+	Max       int64 `json:"max"`
+}
+
+// FileDescriptorsResponse is the GET /fds response.
+type FileDescriptorsResponse struct {
+	Self      ProcessFDStats   `json:"self"`
+	Top       []ProcessFDStats `json:"top"` // Processes with the most open, descending
+	TotalOpen int              `json:"totalOpen"`
+	System    *SystemFDStats   `json:"system"`
+	Warnings  []string         `json:"warnings"` // Processes or the system near their limit
+}
+
 // FileDiffEntry represents a single changed file in the diff.
 type FileDiffEntry struct {
 	Path      string `json:"path"`
@@ -5,7 +5,9 @@ package vm
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"path/filepath"
 
 	"github.com/obot-platform/discobot/server/internal/sandbox"
 )
@@ -64,6 +66,24 @@ type StatusReporter interface {
 	Status() sandbox.ProviderStatus
 }
 
+// SizedVMCreator is an optional interface for VM managers that can size a
+// new project VM for the sandbox it is created for.
+type SizedVMCreator interface {
+	// GetOrCreateSizedVM is GetOrCreateVM, but a VM it creates is grown if
+	// its configured size can't hold requests. An existing VM is returned
+	// as it is.
+	GetOrCreateSizedVM(ctx context.Context, projectID string, requests sandbox.ResourceRequests) (ProjectVM, error)
+}
+
+// DataDiskResizer is an optional interface for VM managers that can grow a
+// project's data disk. A running VM is shut down first; the guest grows its
+// filesystem to fill the disk on the next boot.
+type DataDiskResizer interface {
+	// ResizeDataDisk grows the project's data disk to sizeBytes. Returns
+	// sandbox.ErrVolumeShrink if that is not larger than the current size.
+	ResizeDataDisk(projectID string, sizeBytes int64) error
+}
+
 // Config contains common configuration for VM managers.
 type Config struct {
 	// DataDir is where VM disk images and state are stored.
@@ -106,3 +126,8 @@ type Config struct {
 	// If set, the directory is mounted at /host-home inside the guest.
 	HomeDir string
 }
+
+// ConsoleLogPath returns the path of the console log for a project VM.
+func ConsoleLogPath(consoleLogDir, projectID string) string {
+	return filepath.Join(consoleLogDir, fmt.Sprintf("project-%s", projectID), "console.log")
+}
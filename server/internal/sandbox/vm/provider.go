@@ -1,11 +1,17 @@
 package vm
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"maps"
+	"net"
 	"net/http"
+	"os"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -72,6 +78,15 @@ type Provider struct {
 	idleSince   map[string]time.Time
 	idleSinceMu sync.Mutex
 
+	// dockerSockets maps sessionID -> forwarded Docker socket proxy.
+	dockerSockets   map[string]*sandbox.DockerSocketProxy
+	dockerSocketsMu sync.Mutex
+
+	// Startup warming (see WithWarming). Without a lister only "local" is warmed.
+	warmLister  WarmProjectLister
+	warmWorkers int
+	warmBudget  time.Duration
+
 	// stopCh signals background goroutines to stop.
 	stopCh chan struct{}
 }
@@ -106,6 +121,7 @@ func NewProvider(cfg *config.Config, vmManager ProjectVMManager, resolver Sessio
 		sessionProjectResolver: resolver,
 		systemManager:          systemManager,
 		idleSince:              make(map[string]time.Time),
+		dockerSockets:          make(map[string]*sandbox.DockerSocketProxy),
 		stopCh:                 make(chan struct{}),
 	}
 
@@ -113,15 +129,13 @@ func NewProvider(cfg *config.Config, vmManager ProjectVMManager, resolver Sessio
 		opt(p)
 	}
 
-	// Pre-warm the "local" project VM and start idle cleanup after the manager is ready
+	// Pre-warm project VMs and start idle cleanup after the manager is ready
 	go func() {
 		<-vmManager.Ready()
 		if vmManager.Err() != nil {
 			return
 		}
-		if _, err := p.getOrCreateDockerProvider(context.Background(), "local"); err != nil {
-			log.Printf("failed to warm VM for local project: %v", err)
-		}
+		p.warmProjects()
 
 		// Start idle VM cleanup after ready
 		if p.idleTimeout > 0 {
@@ -135,7 +149,7 @@ func NewProvider(cfg *config.Config, vmManager ProjectVMManager, resolver Sessio
 // ImageExists checks if the Docker image exists.
 // Checks VM Docker daemons first (if any VMs are running), then falls back to host Docker.
 func (p *Provider) ImageExists(ctx context.Context) bool {
-	image := p.cfg.SandboxImage
+	image := p.Image()
 
 	// First, check if image exists in any running VM's Docker daemon
 	p.dockerProvidersMu.RLock()
@@ -157,9 +171,10 @@ func (p *Provider) ImageExists(ctx context.Context) bool {
 	return err == nil
 }
 
-// Image returns the sandbox image name.
+// Image returns the sandbox image name. Project VMs have the host's
+// architecture, so this is the image configured for it.
 func (p *Provider) Image() string {
-	return p.cfg.SandboxImage
+	return p.cfg.SandboxImageFor(runtime.GOARCH)
 }
 
 // Create creates a sandbox in the project's VM.
@@ -169,7 +184,7 @@ func (p *Provider) Create(ctx context.Context, sessionID string, opts sandbox.Cr
 		return nil, fmt.Errorf("failed to resolve project for session %s: %w", sessionID, err)
 	}
 
-	dockerProv, err := p.getOrCreateDockerProvider(ctx, projectID)
+	dockerProv, err := p.getOrCreateDockerProvider(ctx, projectID, opts.ResourceRequests)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get docker provider: %w", err)
 	}
@@ -195,8 +210,29 @@ func (p *Provider) Stop(ctx context.Context, sessionID string, timeout time.Dura
 	return dockerProv.Stop(ctx, sessionID, timeout)
 }
 
+// Pause pauses a sandbox's container. The project VM keeps running, since
+// other sessions in the project share it.
+func (p *Provider) Pause(ctx context.Context, sessionID string) error {
+	_, dockerProv, err := p.getDockerProviderForSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	return dockerProv.Pause(ctx, sessionID)
+}
+
+// Unpause resumes a sandbox's container.
+func (p *Provider) Unpause(ctx context.Context, sessionID string) error {
+	_, dockerProv, err := p.getDockerProviderForSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	return dockerProv.Unpause(ctx, sessionID)
+}
+
 // Remove removes a sandbox.
 func (p *Provider) Remove(ctx context.Context, sessionID string, opts ...sandbox.RemoveOption) error {
+	_ = p.StopDockerSocketProxy(ctx, sessionID)
+
 	_, dockerProv, err := p.getDockerProviderForSession(ctx, sessionID)
 	if err != nil {
 		return err
@@ -204,13 +240,31 @@ func (p *Provider) Remove(ctx context.Context, sessionID string, opts ...sandbox
 	return dockerProv.Remove(ctx, sessionID, opts...)
 }
 
-// Get returns sandbox info.
+// Get returns sandbox info. Ports are published inside the project VM, not on
+// the host, so only their container ports are reported; use Dial to reach them.
 func (p *Provider) Get(ctx context.Context, sessionID string) (*sandbox.Sandbox, error) {
 	_, dockerProv, err := p.getDockerProviderForSession(ctx, sessionID)
 	if err != nil {
 		return nil, err
 	}
-	return dockerProv.Get(ctx, sessionID)
+	sb, err := dockerProv.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sb.Ports {
+		sb.Ports[i].HostIP = ""
+		sb.Ports[i].HostPort = 0
+	}
+	return sb, nil
+}
+
+// WaitForStatus waits using the Docker provider in the session's project VM.
+func (p *Provider) WaitForStatus(ctx context.Context, sessionID string, target sandbox.Status, timeout time.Duration) (*sandbox.Sandbox, error) {
+	_, dockerProv, err := p.getDockerProviderForSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return dockerProv.WaitForStatus(ctx, sessionID, target, timeout)
 }
 
 // GetSecret returns the shared secret for a sandbox.
@@ -309,6 +363,36 @@ func (p *Provider) HTTPClient(ctx context.Context, sessionID string) (*http.Clie
 	}, nil
 }
 
+// Dial connects to port inside the session's container over VSOCK, via the
+// port the container publishes inside the project VM. Implements
+// sandbox.PortDialer.
+func (p *Provider) Dial(ctx context.Context, sessionID string, port int) (net.Conn, error) {
+	projectID, dockerProv, err := p.getDockerProviderForSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	pvm, ok := p.GetVMForProject(projectID)
+	if !ok {
+		return nil, fmt.Errorf("no VM found for project %q", projectID)
+	}
+
+	sb, err := dockerProv.Get(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sandbox info: %w", err)
+	}
+	if sb.Status != sandbox.StatusRunning {
+		return nil, sandbox.ErrNotRunning
+	}
+
+	for _, mapping := range sb.Ports {
+		if mapping.ContainerPort == port && mapping.Protocol == "tcp" {
+			return pvm.PortDialer(uint32(mapping.HostPort))(ctx, "tcp", "")
+		}
+	}
+	return nil, fmt.Errorf("sandbox does not expose port %d", port)
+}
+
 // Watch merges state events from all Docker providers.
 func (p *Provider) Watch(ctx context.Context) (<-chan sandbox.StateEvent, error) {
 	p.dockerProvidersMu.RLock()
@@ -353,6 +437,15 @@ func (p *Provider) Close() error {
 	log.Printf("Shutting down VM+Docker provider")
 
 	close(p.stopCh)
+
+	// Stop forwarded Docker sockets before their VMs go away
+	p.dockerSocketsMu.Lock()
+	for sessionID, proxy := range p.dockerSockets {
+		_ = proxy.Close()
+		delete(p.dockerSockets, sessionID)
+	}
+	p.dockerSocketsMu.Unlock()
+
 	p.vmManager.Shutdown()
 
 	// Close all Docker providers
@@ -388,6 +481,32 @@ func (p *Provider) CleanupImages(ctx context.Context) error {
 	return nil
 }
 
+// ActiveExecs merges running command counts from all per-project Docker providers.
+// Implements sandbox.ExecStatsProvider.
+func (p *Provider) ActiveExecs() map[string]int {
+	p.dockerProvidersMu.RLock()
+	defer p.dockerProvidersMu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, dockerProv := range p.dockerProviders {
+		maps.Copy(counts, dockerProv.ActiveExecs())
+	}
+	return counts
+}
+
+// LastActivity merges sandbox activity from all per-project Docker providers.
+// Implements sandbox.ActivityReporter.
+func (p *Provider) LastActivity() map[string]time.Time {
+	p.dockerProvidersMu.RLock()
+	defer p.dockerProvidersMu.RUnlock()
+
+	activity := make(map[string]time.Time)
+	for _, dockerProv := range p.dockerProviders {
+		maps.Copy(activity, dockerProv.LastActivity())
+	}
+	return activity
+}
+
 // Status returns the current status of the VM provider.
 // Implements sandbox.StatusProvider.
 func (p *Provider) Status() sandbox.ProviderStatus {
@@ -446,6 +565,236 @@ func (p *Provider) DockerTransport(projectID string) (http.RoundTripper, error)
 	}, nil
 }
 
+// DockerSocketProxy forwards socketPath on the host to the Docker daemon inside
+// the session's project VM, scoped to the session's containers since the VM is
+// shared by the project's sessions. Implements sandbox.DockerSocketProvider.
+func (p *Provider) DockerSocketProxy(ctx context.Context, sessionID, socketPath string) (*sandbox.DockerSocketProxy, error) {
+	projectID, err := p.sessionProjectResolver(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to resolve project for session %s: %v", sandbox.ErrNotFound, sessionID, err)
+	}
+	projectVM, ok := p.GetVMForProject(projectID)
+	if !ok {
+		return nil, fmt.Errorf("%w: no running VM for project %s (session %s)", sandbox.ErrNotFound, projectID, sessionID)
+	}
+
+	p.dockerSocketsMu.Lock()
+	defer p.dockerSocketsMu.Unlock()
+
+	if existing, ok := p.dockerSockets[sessionID]; ok {
+		// Reattach to a proxy that is still serving the requested path
+		if existing.SocketPath() == socketPath {
+			select {
+			case <-existing.Done():
+			default:
+				return existing, nil
+			}
+		}
+		_ = existing.Close()
+		delete(p.dockerSockets, sessionID)
+	}
+
+	dialer := projectVM.DockerDialer()
+	proxy, err := sandbox.NewDockerSocketProxy(socketPath, func(ctx context.Context) (net.Conn, error) {
+		return dialer(ctx, "vsock", "")
+	}, &sandbox.DockerSocketScope{SessionID: sessionID})
+	if err != nil {
+		return nil, err
+	}
+	p.dockerSockets[sessionID] = proxy
+	log.Printf("Forwarding Docker socket %s for session %s (project %s)", socketPath, sessionID, projectID)
+	return proxy, nil
+}
+
+// StopDockerSocketProxy stops the session's forwarded Docker socket, if any.
+// Implements sandbox.DockerSocketProvider.
+func (p *Provider) StopDockerSocketProxy(_ context.Context, sessionID string) error {
+	p.dockerSocketsMu.Lock()
+	proxy, ok := p.dockerSockets[sessionID]
+	delete(p.dockerSockets, sessionID)
+	p.dockerSocketsMu.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return proxy.Close()
+}
+
+// Stats samples the resource usage of the session's container inside its
+// project VM. Implements sandbox.StatsProvider.
+func (p *Provider) Stats(ctx context.Context, sessionID string) (*sandbox.Stats, error) {
+	_, dockerProv, err := p.getDockerProviderForSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return dockerProv.Stats(ctx, sessionID)
+}
+
+// CommitImage commits the session's container inside its project VM. The
+// image is stored in that VM's Docker, so only sandboxes in the same project
+// can use it. Implements sandbox.ImageCommitter.
+func (p *Provider) CommitImage(ctx context.Context, sessionID, imageRef string) (*sandbox.CommittedImage, error) {
+	_, dockerProv, err := p.getDockerProviderForSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return dockerProv.CommitImage(ctx, sessionID, imageRef)
+}
+
+// consoleLogMaxBytes caps how much of the VM console log Logs returns.
+const consoleLogMaxBytes = 1024 * 1024
+
+// Logs returns the output of the session's container inside its project VM.
+// If the project VM isn't running (for example because the guest failed to
+// boot), the VM's serial console log is returned instead. The console log
+// has no timestamps and is returned as a snapshot, so only opts.Tail applies
+// to it. Implements sandbox.LogStreamer.
+func (p *Provider) Logs(ctx context.Context, sessionID string, opts sandbox.LogOptions) (io.ReadCloser, error) {
+	projectID, err := p.sessionProjectResolver(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to resolve project for session %s: %v", sandbox.ErrNotFound, sessionID, err)
+	}
+
+	p.dockerProvidersMu.RLock()
+	dockerProv, exists := p.dockerProviders[projectID]
+	p.dockerProvidersMu.RUnlock()
+	if exists {
+		return dockerProv.Logs(ctx, sessionID, opts)
+	}
+
+	data, err := readFileTail(ConsoleLogPath(p.cfg.VZConsoleLogDir, projectID), consoleLogMaxBytes)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: no running VM or console log for project %s", sandbox.ErrNotFound, projectID)
+		}
+		return nil, fmt.Errorf("failed to read console log: %w", err)
+	}
+	return io.NopCloser(strings.NewReader(string(tailLines(data, opts.Tail)))), nil
+}
+
+// readFileTail returns up to the last maxBytes of the file at path.
+func readFileTail(path string, maxBytes int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := max(info.Size()-maxBytes, 0)
+	data := make([]byte, info.Size()-offset)
+	n, err := f.ReadAt(data, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+// tailLines returns the last n lines of data, or all of data if n <= 0.
+func tailLines(data []byte, n int) []byte {
+	if n <= 0 {
+		return data
+	}
+	end := len(data)
+	if end > 0 && data[end-1] == '\n' {
+		end-- // A trailing newline doesn't start another line
+	}
+	for i := end - 1; i >= 0; i-- {
+		if data[i] == '\n' {
+			n--
+			if n == 0 {
+				return data[i+1:]
+			}
+		}
+	}
+	return data
+}
+
+// FilesystemDiff returns the changes in the session's container inside its
+// project VM. Implements sandbox.FilesystemDiffer.
+func (p *Provider) FilesystemDiff(ctx context.Context, sessionID string) ([]sandbox.FileChange, error) {
+	_, dockerProv, err := p.getDockerProviderForSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return dockerProv.FilesystemDiff(ctx, sessionID)
+}
+
+// CopyTo extracts a tar archive into the session's container inside its
+// project VM. Implements sandbox.FileCopier.
+func (p *Provider) CopyTo(ctx context.Context, sessionID, destPath string, archive io.Reader) error {
+	_, dockerProv, err := p.getDockerProviderForSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	return dockerProv.CopyTo(ctx, sessionID, destPath, archive)
+}
+
+// CopyFrom archives a path in the session's container inside its project
+// VM. Implements sandbox.FileCopier.
+func (p *Provider) CopyFrom(ctx context.Context, sessionID, srcPath string) (io.ReadCloser, error) {
+	_, dockerProv, err := p.getDockerProviderForSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return dockerProv.CopyFrom(ctx, sessionID, srcPath)
+}
+
+// ResizeVolume grows the data disk of the session's project VM to newSizeMB.
+// Sessions share their project VM's disk, so every session in the project
+// gets the extra space. The VM is restarted to apply the new size, which
+// stops the project's other sandboxes; they restart on next use.
+// Implements sandbox.VolumeResizer.
+func (p *Provider) ResizeVolume(ctx context.Context, sessionID string, newSizeMB int) error {
+	resizer, ok := p.vmManager.(DataDiskResizer)
+	if !ok {
+		return fmt.Errorf("%w: VM manager cannot resize data disks", sandbox.ErrNotSupported)
+	}
+
+	projectID, err := p.sessionProjectResolver(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("%w: failed to resolve project for session %s: %v", sandbox.ErrNotFound, sessionID, err)
+	}
+
+	// Drop the Docker provider first so nothing uses the VM while it's down
+	p.dockerProvidersMu.Lock()
+	delete(p.dockerProviders, projectID)
+	p.dockerProvidersMu.Unlock()
+
+	log.Printf("Resizing data disk for project %s to %d MB", projectID, newSizeMB)
+	if err := resizer.ResizeDataDisk(projectID, int64(newSizeMB)*1024*1024); err != nil {
+		return err
+	}
+
+	// Boot the VM again and bring the requesting session back up
+	dockerProv, err := p.getOrCreateDockerProvider(ctx, projectID, sandbox.ResourceRequests{})
+	if err != nil {
+		return fmt.Errorf("failed to restart project VM after resize: %w", err)
+	}
+	if err := dockerProv.Start(ctx, sessionID); err != nil && !errors.Is(err, sandbox.ErrAlreadyRunning) {
+		return fmt.Errorf("failed to restart sandbox after resize: %w", err)
+	}
+	return nil
+}
+
+// Healthy returns nil once the provider is ready to create VMs (see
+// IsReady), or sandbox.ErrNotReady with the VM manager's status otherwise.
+func (p *Provider) Healthy(_ context.Context) error {
+	select {
+	case <-p.vmManager.Ready():
+		if err := p.vmManager.Err(); err != nil {
+			return fmt.Errorf("%w: %w", sandbox.ErrNotReady, err)
+		}
+		return nil
+	default:
+		status := p.Status()
+		return fmt.Errorf("%w: %s", sandbox.ErrNotReady, cmp.Or(status.Message, status.State))
+	}
+}
+
 // IsReady returns true if the provider is ready to create VMs.
 func (p *Provider) IsReady() bool {
 	select {
@@ -494,7 +843,7 @@ func (p *Provider) getHostDockerClient() (*dockerclient.Client, error) {
 // ensureImageInVM loads the sandbox image from the host's Docker into the VM's Docker
 // when the image is local (discobot-local/ tag) and cannot be pulled from a registry.
 func (p *Provider) ensureImageInVM(ctx context.Context, dockerProv *docker.Provider) error {
-	image := p.cfg.SandboxImage
+	image := dockerProv.Image()
 
 	// Only handle local images (discobot-local/ prefixed tags).
 	// Registry images are pulled by ensureImage().
@@ -574,9 +923,10 @@ func (p *Provider) ensureImageInVM(ctx context.Context, dockerProv *docker.Provi
 }
 
 // getOrCreateDockerProvider gets or creates a Docker provider for the given project.
-// It ensures the project VM exists (creating one if needed) and sets up a Docker
-// provider connected to the VM's Docker daemon via the VM's dialer.
-func (p *Provider) getOrCreateDockerProvider(ctx context.Context, projectID string) (*docker.Provider, error) {
+// It ensures the project VM exists (creating one if needed, sized for requests
+// if the manager implements SizedVMCreator) and sets up a Docker provider
+// connected to the VM's Docker daemon via the VM's dialer.
+func (p *Provider) getOrCreateDockerProvider(ctx context.Context, projectID string, requests sandbox.ResourceRequests) (*docker.Provider, error) {
 	// Non-blocking check: fail immediately if not ready
 	select {
 	case <-p.vmManager.Ready():
@@ -587,8 +937,14 @@ func (p *Provider) getOrCreateDockerProvider(ctx context.Context, projectID stri
 		return nil, fmt.Errorf("VM provider not ready, still initializing")
 	}
 
-	// Get or create the project VM
-	pvm, err := p.vmManager.GetOrCreateVM(ctx, projectID)
+	// Get or create the project VM, big enough for the sandbox's requests
+	var pvm ProjectVM
+	var err error
+	if sizer, ok := p.vmManager.(SizedVMCreator); ok {
+		pvm, err = sizer.GetOrCreateSizedVM(ctx, projectID, requests)
+	} else {
+		pvm, err = p.vmManager.GetOrCreateVM(ctx, projectID)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get/create project VM: %w", err)
 	}
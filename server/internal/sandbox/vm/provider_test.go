@@ -0,0 +1,22 @@
+package vm
+
+import "testing"
+
+func TestTailLines(t *testing.T) {
+	tests := []struct {
+		data string
+		n    int
+		want string
+	}{
+		{"a\nb\nc\n", 0, "a\nb\nc\n"},
+		{"a\nb\nc\n", 2, "b\nc\n"},
+		{"a\nb\nc", 2, "b\nc"},
+		{"a\nb\nc\n", 5, "a\nb\nc\n"},
+		{"", 3, ""},
+	}
+	for _, tt := range tests {
+		if got := string(tailLines([]byte(tt.data), tt.n)); got != tt.want {
+			t.Errorf("tailLines(%q, %d) = %q, want %q", tt.data, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// defaultWarmProjectID is always warmed, even without recent sessions.
+const defaultWarmProjectID = "local"
+
+// WarmProjectLister returns the projects whose VMs should be warmed at
+// startup, most important first (e.g., most recently active sessions).
+type WarmProjectLister func(ctx context.Context) ([]string, error)
+
+// WithWarming warms the VMs of the projects returned by lister once the VM
+// manager is ready, using up to workers VMs booting at a time and giving up
+// on projects not finished within budget (0 = no limit). Without this option
+// only the "local" project is warmed.
+func WithWarming(lister WarmProjectLister, workers int, budget time.Duration) Option {
+	return func(p *Provider) {
+		p.warmLister = lister
+		p.warmWorkers = workers
+		p.warmBudget = budget
+	}
+}
+
+// warmProjects boots the VMs for the configured projects in priority order
+// and reports progress as the "vm-warm" startup task. Warming stops when the
+// provider is closed.
+func (p *Provider) warmProjects() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-p.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	projectIDs := []string{defaultWarmProjectID}
+	if p.warmLister != nil {
+		listed, err := p.warmLister(ctx)
+		if err != nil {
+			log.Printf("VM warming: failed to list projects, warming %s only: %v", defaultWarmProjectID, err)
+		} else if !slices.Contains(listed, defaultWarmProjectID) {
+			projectIDs = append(listed, defaultWarmProjectID)
+		} else {
+			projectIDs = listed
+		}
+	}
+
+	if p.warmBudget > 0 {
+		var cancelBudget context.CancelFunc
+		ctx, cancelBudget = context.WithTimeout(ctx, p.warmBudget)
+		defer cancelBudget()
+	}
+
+	if p.systemManager != nil {
+		p.systemManager.RegisterTask("vm-warm", fmt.Sprintf("Warming %d project VMs", len(projectIDs)))
+		p.systemManager.StartTask("vm-warm")
+	}
+
+	start := time.Now()
+	var done atomic.Int32
+	warmed, failed := warmInOrder(ctx, projectIDs, p.warmWorkers, func(ctx context.Context, projectID string) error {
+		_, err := p.getOrCreateDockerProvider(ctx, projectID, sandbox.ResourceRequests{})
+		n := int(done.Add(1))
+		if err != nil {
+			log.Printf("VM warming: failed to warm project %s (%d/%d): %v", projectID, n, len(projectIDs), err)
+		} else {
+			log.Printf("VM warming: warmed project %s (%d/%d)", projectID, n, len(projectIDs))
+		}
+		if p.systemManager != nil {
+			p.systemManager.UpdateTaskProgress("vm-warm", n*100/len(projectIDs), "Warmed "+projectID)
+		}
+		return err
+	})
+
+	skipped := len(projectIDs) - warmed - failed
+	log.Printf("VM warming: finished in %s: %d warmed, %d failed, %d skipped (budget exhausted or shutting down)",
+		time.Since(start).Round(time.Second), warmed, failed, skipped)
+	if p.systemManager != nil {
+		if warmed == 0 && failed > 0 {
+			p.systemManager.FailTask("vm-warm", fmt.Errorf("failed to warm %d project VMs", failed))
+		} else {
+			p.systemManager.CompleteTask("vm-warm")
+		}
+	}
+}
+
+// warmInOrder calls warm for each project with at most workers calls running
+// at once (minimum 1). Projects are started in order, so earlier ones are
+// warmed first; once ctx is done no new projects are started. Returns the
+// number of projects that were warmed and that failed.
+func warmInOrder(ctx context.Context, projectIDs []string, workers int, warm func(ctx context.Context, projectID string) error) (warmed, failed int) {
+	workers = max(workers, 1)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	next := make(chan string)
+	for range min(workers, len(projectIDs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for projectID := range next {
+				// The feeder may hand out a project as the budget expires
+				if ctx.Err() != nil {
+					continue
+				}
+				err := warm(ctx, projectID)
+				mu.Lock()
+				if err != nil {
+					failed++
+				} else {
+					warmed++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, projectID := range projectIDs {
+		select {
+		case next <- projectID:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	return warmed, failed
+}
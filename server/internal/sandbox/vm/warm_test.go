@@ -0,0 +1,84 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWarmInOrder_BoundsConcurrency(t *testing.T) {
+	projectIDs := []string{"p1", "p2", "p3", "p4", "p5", "p6"}
+
+	var running, peak atomic.Int32
+	var mu sync.Mutex
+	var started []string
+	warmed, failed := warmInOrder(context.Background(), projectIDs, 2, func(_ context.Context, projectID string) error {
+		mu.Lock()
+		started = append(started, projectID)
+		mu.Unlock()
+
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+
+		if projectID == "p3" {
+			return errors.New("boot failed")
+		}
+		return nil
+	})
+
+	if warmed != 5 || failed != 1 {
+		t.Errorf("expected 5 warmed and 1 failed, got %d and %d", warmed, failed)
+	}
+	if got := peak.Load(); got > 2 {
+		t.Errorf("expected at most 2 concurrent warm-ups, got %d", got)
+	}
+	// Workers take projects in order, so the first two always start first
+	if len(started) != len(projectIDs) || !slices.Contains(started[:2], "p1") || !slices.Contains(started[:2], "p2") {
+		t.Errorf("expected projects to start in priority order, got %v", started)
+	}
+}
+
+func TestWarmInOrder_StopsAtBudget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+	warmed, failed := warmInOrder(ctx, []string{"p1", "p2", "p3"}, 1, func(_ context.Context, _ string) error {
+		calls.Add(1)
+		cancel()
+		return nil
+	})
+
+	if calls.Load() != 1 || warmed != 1 || failed != 0 {
+		t.Errorf("expected only the first project to be warmed after the budget ran out, got %d calls (%d warmed, %d failed)",
+			calls.Load(), warmed, failed)
+	}
+}
+
+func TestWarmProjects_StopsOnClose(t *testing.T) {
+	p := &Provider{stopCh: make(chan struct{})}
+	p.warmLister = func(ctx context.Context) ([]string, error) {
+		close(p.stopCh)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+			t.Error("expected closing the provider to cancel warming")
+			return nil, errors.New("not canceled")
+		}
+	}
+
+	// With warming canceled no VM is booted, so the nil VM manager is never used
+	p.warmProjects()
+}
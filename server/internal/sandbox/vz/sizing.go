@@ -0,0 +1,27 @@
+package vz
+
+import (
+	"math"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// vmOverheadMB is the memory a project VM keeps for its kernel and Docker
+// daemon on top of what its sandboxes request.
+const vmOverheadMB = 512
+
+// vmSize returns the CPU count and memory in bytes for a new project VM.
+// cpuCount and memoryBytes are the configured (or default) size; the VM is
+// grown if that can't hold the resources requested by the sandbox it is
+// created for. Requested CPUs are capped at hostCPUs.
+func vmSize(cpuCount int, memoryBytes uint64, hostCPUs int, requests sandbox.ResourceRequests) (uint, uint64) {
+	if requests.CPUCores > 0 {
+		cpuCount = max(cpuCount, min(int(math.Ceil(requests.CPUCores)), hostCPUs))
+	}
+	if requests.MemoryMB > 0 {
+		if want := uint64(requests.MemoryMB+vmOverheadMB) * 1024 * 1024; want > memoryBytes {
+			memoryBytes = want
+		}
+	}
+	return uint(cpuCount), memoryBytes
+}
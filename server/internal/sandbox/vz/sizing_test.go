@@ -0,0 +1,31 @@
+package vz
+
+import (
+	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+func TestVMSize(t *testing.T) {
+	const gb = 1024 * 1024 * 1024
+	tests := []struct {
+		name      string
+		requests  sandbox.ResourceRequests
+		wantCPUs  uint
+		wantBytes uint64
+	}{
+		{"no requests", sandbox.ResourceRequests{}, 2, 4 * gb},
+		{"requests fit", sandbox.ResourceRequests{MemoryMB: 2048, CPUCores: 1.5}, 2, 4 * gb},
+		{"memory request grows the VM", sandbox.ResourceRequests{MemoryMB: 6 * 1024}, 2, 6*gb + vmOverheadMB*1024*1024},
+		{"CPU request grows the VM", sandbox.ResourceRequests{CPUCores: 2.5}, 3, 4 * gb},
+		{"CPUs capped at the host's", sandbox.ResourceRequests{CPUCores: 16}, 8, 4 * gb},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpus, bytes := vmSize(2, 4*gb, 8, tt.requests)
+			if cpus != tt.wantCPUs || bytes != tt.wantBytes {
+				t.Errorf("vmSize() = %d CPUs, %d bytes; want %d CPUs, %d bytes", cpus, bytes, tt.wantCPUs, tt.wantBytes)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"runtime"
 	"time"
 
 	containerTypes "github.com/docker/docker/api/types/container"
@@ -18,13 +19,15 @@ import (
 // NewProvider creates a new VZ+Docker hybrid provider.
 // It creates a VZ VMManager (which handles async image download if needed)
 // and returns a generic vm.Provider that uses it for VM management.
-func NewProvider(cfg *config.Config, vmConfig *vm.Config, resolver vm.SessionProjectResolver, systemManager vm.SystemManager) (*vm.Provider, error) {
+// Extra options (e.g., vm.WithWarming) are applied after the VZ defaults.
+func NewProvider(cfg *config.Config, vmConfig *vm.Config, resolver vm.SessionProjectResolver, systemManager vm.SystemManager, extraOpts ...vm.Option) (*vm.Provider, error) {
 	vmManager, err := NewVMManager(*vmConfig, systemManager)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create VZ VM manager: %w", err)
 	}
 
-	sandboxImage := cfg.SandboxImage
+	// The guest has the host's architecture
+	sandboxImage := cfg.SandboxImageFor(runtime.GOARCH)
 
 	opts := []vm.Option{
 		vm.WithPostVMSetup(func(ctx context.Context, projectID string, dockerProv *docker.Provider) error {
@@ -43,6 +46,7 @@ func NewProvider(cfg *config.Config, vmConfig *vm.Config, resolver vm.SessionPro
 		}
 	}
 
+	opts = append(opts, extraOpts...)
 	return vm.NewProvider(cfg, vmManager, resolver, systemManager, opts...), nil
 }
 
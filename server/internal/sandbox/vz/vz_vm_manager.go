@@ -413,6 +413,13 @@ func (m *VMManager) downloadAndInit(imageRef string) {
 
 // GetOrCreateVM returns an existing VM for the project or creates a new one.
 func (m *VMManager) GetOrCreateVM(ctx context.Context, projectID string) (vm.ProjectVM, error) {
+	return m.GetOrCreateSizedVM(ctx, projectID, sandbox.ResourceRequests{})
+}
+
+// GetOrCreateSizedVM returns an existing VM for the project or creates a new
+// one, grown beyond VZ_CPU_COUNT and VZ_MEMORY_MB if needed to hold requests.
+// Implements vm.SizedVMCreator.
+func (m *VMManager) GetOrCreateSizedVM(ctx context.Context, projectID string, requests sandbox.ResourceRequests) (vm.ProjectVM, error) {
 	m.projectVMMu.Lock()
 	defer m.projectVMMu.Unlock()
 
@@ -423,7 +430,7 @@ func (m *VMManager) GetOrCreateVM(ctx context.Context, projectID string) (vm.Pro
 
 	// Create new VM for project
 	log.Printf("Creating new project VM for project: %s", projectID)
-	pvm, err := m.createProjectVM(ctx, projectID)
+	pvm, err := m.createProjectVM(ctx, projectID, requests)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create project VM: %w", err)
 	}
@@ -475,6 +482,45 @@ func (m *VMManager) RemoveVM(projectID string) error {
 	return nil
 }
 
+// ResizeDataDisk grows a project's data disk image to sizeBytes, shutting
+// down the project's VM first if it is running. The image is a raw sparse
+// file, so growing it only extends its length; the guest's fstab entry
+// (x-systemd.growfs) expands the filesystem on the next boot.
+// Implements vm.DataDiskResizer.
+func (m *VMManager) ResizeDataDisk(projectID string, sizeBytes int64) error {
+	m.projectVMMu.Lock()
+	defer m.projectVMMu.Unlock()
+
+	dataDiskPath := m.dataDiskPath(projectID)
+	info, err := os.Stat(dataDiskPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat data disk for project %s: %w", projectID, err)
+	}
+	if sizeBytes <= info.Size() {
+		return fmt.Errorf("%w: data disk for project %s is already %d MB",
+			sandbox.ErrVolumeShrink, projectID, info.Size()/(1024*1024))
+	}
+
+	if pvm, exists := m.projectVMs[projectID]; exists {
+		log.Printf("Shutting down project VM %s to resize its data disk", projectID)
+		if err := pvm.Shutdown(); err != nil {
+			return fmt.Errorf("failed to shutdown VM for project %s: %w", projectID, err)
+		}
+		delete(m.projectVMs, projectID)
+	}
+
+	if err := os.Truncate(dataDiskPath, sizeBytes); err != nil {
+		return fmt.Errorf("failed to resize data disk: %w", err)
+	}
+	log.Printf("Resized data disk %s from %d to %d bytes", dataDiskPath, info.Size(), sizeBytes)
+	return nil
+}
+
+// dataDiskPath returns the path of a project's data disk image.
+func (m *VMManager) dataDiskPath(projectID string) string {
+	return filepath.Join(m.config.DataDir, fmt.Sprintf("project-%s-data.img", projectID))
+}
+
 // Shutdown stops all project VMs and shuts down the manager.
 func (m *VMManager) Shutdown() {
 	close(m.stopCh)
@@ -492,7 +538,7 @@ func (m *VMManager) Shutdown() {
 }
 
 // createProjectVM creates and starts a new VM for a project.
-func (m *VMManager) createProjectVM(ctx context.Context, projectID string) (*vzProjectVM, error) {
+func (m *VMManager) createProjectVM(ctx context.Context, projectID string, requests sandbox.ResourceRequests) (*vzProjectVM, error) {
 	// Ensure data directory exists
 	if err := os.MkdirAll(m.config.DataDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
@@ -502,7 +548,7 @@ func (m *VMManager) createProjectVM(ctx context.Context, projectID string) (*vzP
 	rootDiskPath := m.config.BaseDiskPath
 
 	// Data disk (writable) - per-project persistent storage
-	dataDiskPath := filepath.Join(m.config.DataDir, fmt.Sprintf("project-%s-data.img", projectID))
+	dataDiskPath := m.dataDiskPath(projectID)
 
 	// Create data disk if it doesn't exist
 	if _, err := os.Stat(dataDiskPath); os.IsNotExist(err) {
@@ -518,7 +564,7 @@ func (m *VMManager) createProjectVM(ctx context.Context, projectID string) (*vzP
 	}
 
 	// Create console log file
-	consoleLogPath := filepath.Join(m.config.ConsoleLogDir, fmt.Sprintf("project-%s", projectID), "console.log")
+	consoleLogPath := vm.ConsoleLogPath(m.config.ConsoleLogDir, projectID)
 	if err := os.MkdirAll(filepath.Dir(consoleLogPath), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create console log directory: %w", err)
 	}
@@ -531,7 +577,7 @@ func (m *VMManager) createProjectVM(ctx context.Context, projectID string) (*vzP
 	log.Printf("Console log: %s", consoleLogPath)
 
 	// Build and start VM
-	vzVM, socketDevice, consoleRead, consoleWrite, err := m.buildAndStartVM(rootDiskPath, dataDiskPath, projectID)
+	vzVM, socketDevice, consoleRead, consoleWrite, err := m.buildAndStartVM(rootDiskPath, dataDiskPath, projectID, requests)
 	if err != nil {
 		consoleLog.Close()
 		return nil, fmt.Errorf("failed to build and start VM: %w", err)
@@ -582,7 +628,8 @@ func (m *VMManager) createProjectVM(ctx context.Context, projectID string) (*vzP
 
 // buildAndStartVM creates and starts a VM with the given disk images.
 // rootDiskPath is mounted read-only as /dev/vda, dataDiskPath is mounted read-write as /dev/vdb.
-func (m *VMManager) buildAndStartVM(rootDiskPath, dataDiskPath, _ string) (*vz.VirtualMachine, *vz.VirtioSocketDevice, *os.File, *os.File, error) {
+// The VM is sized to hold requests, the first sandbox's resource requests.
+func (m *VMManager) buildAndStartVM(rootDiskPath, dataDiskPath, projectID string, requests sandbox.ResourceRequests) (*vz.VirtualMachine, *vz.VirtioSocketDevice, *os.File, *os.File, error) {
 	// Build kernel command line
 	// Root disk is read-only, data disk (/dev/vdb) is where writable data goes
 	cmdLine := []string{
@@ -611,14 +658,20 @@ func (m *VMManager) buildAndStartVM(rootDiskPath, dataDiskPath, _ string) (*vz.V
 	}
 
 	// Determine CPU and memory (default to all host CPUs)
-	cpuCount := uint(runtime.NumCPU())
+	configuredCPUs := runtime.NumCPU()
 	if m.config.CPUCount > 0 {
-		cpuCount = uint(m.config.CPUCount)
+		configuredCPUs = m.config.CPUCount
 	}
 
-	memorySize := getDefaultMemoryBytes()
+	configuredMemory := getDefaultMemoryBytes()
 	if m.config.MemoryMB > 0 {
-		memorySize = uint64(m.config.MemoryMB) * 1024 * 1024
+		configuredMemory = uint64(m.config.MemoryMB) * 1024 * 1024
+	}
+
+	cpuCount, memorySize := vmSize(configuredCPUs, configuredMemory, runtime.NumCPU(), requests)
+	if cpuCount != uint(configuredCPUs) || memorySize != configuredMemory {
+		log.Printf("Growing VM for project %s to %d CPUs and %d MB for the sandbox's resource requests",
+			projectID, cpuCount, memorySize/(1024*1024))
 	}
 
 	// Create VM configuration
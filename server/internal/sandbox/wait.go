@@ -0,0 +1,88 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrWaitTimeout is returned by WaitForStatus when the sandbox doesn't reach
+// the target status before the deadline.
+var ErrWaitTimeout = errors.New("timed out waiting for sandbox status")
+
+// waitPollInterval is how often WaitForEvents checks the status when it has
+// no event stream to wait on.
+const waitPollInterval = time.Second
+
+// WaitForEvents implements Provider.WaitForStatus on top of a state event
+// stream. It checks the status with get, then re-checks it whenever an event
+// for the session arrives, until the target status is reached, the timeout
+// expires, or ctx is done. events must be subscribed before calling so no
+// change is missed; if it is nil or closes, WaitForEvents falls back to
+// polling get.
+//
+// Waiting for StatusRemoved succeeds (returning a nil sandbox) once the
+// sandbox no longer exists; for other targets a missing sandbox returns
+// ErrNotFound. A failure event that the restart policy won't recover from
+// ends the wait early with an error.
+func WaitForEvents(ctx context.Context, sessionID string, target Status, timeout time.Duration, get func(context.Context, string) (*Sandbox, error), events <-chan StateEvent) (*Sandbox, error) {
+	waitCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var poll <-chan time.Time
+	if events == nil {
+		ticker := time.NewTicker(waitPollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		sb, err := get(waitCtx, sessionID)
+		switch {
+		case errors.Is(err, ErrNotFound) && target == StatusRemoved:
+			return nil, nil
+		case err != nil && waitCtx.Err() == nil:
+			return nil, err
+		case err == nil && sb.Status == target:
+			return sb, nil
+		}
+
+		current := Status("unknown")
+		if sb != nil {
+			current = sb.Status
+		}
+
+	wait:
+		for {
+			select {
+			case <-waitCtx.Done():
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				return nil, fmt.Errorf("%w %s: sandbox is %s after %s", ErrWaitTimeout, target, current, timeout)
+			case <-poll:
+				break wait
+			case event, ok := <-events:
+				if !ok {
+					events = nil
+					ticker := time.NewTicker(waitPollInterval)
+					defer ticker.Stop()
+					poll = ticker.C
+					break wait
+				}
+				if event.SessionID != sessionID {
+					continue
+				}
+				if event.Status == StatusFailed && target != StatusFailed && !event.Restarting {
+					return nil, fmt.Errorf("sandbox failed while waiting for %s: %s", target, event.Error)
+				}
+				break wait
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStatus is a sandbox status that tests change while WaitForEvents runs.
+type fakeStatus struct {
+	mu     sync.Mutex
+	status Status // Empty means the sandbox doesn't exist
+}
+
+func (f *fakeStatus) set(status Status) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status = status
+}
+
+func (f *fakeStatus) get(_ context.Context, sessionID string) (*Sandbox, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status == "" {
+		return nil, ErrNotFound
+	}
+	return &Sandbox{SessionID: sessionID, Status: f.status}, nil
+}
+
+func TestWaitForEvents_ReachesTarget(t *testing.T) {
+	f := &fakeStatus{status: StatusCreated}
+	events := make(chan StateEvent, 2)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		events <- StateEvent{SessionID: "other", Status: StatusRunning}
+		f.set(StatusRunning)
+		events <- StateEvent{SessionID: "session-1", Status: StatusRunning}
+	}()
+
+	sb, err := WaitForEvents(context.Background(), "session-1", StatusRunning, time.Second, f.get, events)
+	if err != nil || sb.Status != StatusRunning {
+		t.Fatalf("expected a running sandbox, got %+v (err %v)", sb, err)
+	}
+}
+
+func TestWaitForEvents_Timeout(t *testing.T) {
+	f := &fakeStatus{status: StatusStopped}
+	_, err := WaitForEvents(context.Background(), "session-1", StatusRunning, 20*time.Millisecond, f.get, make(chan StateEvent))
+	if !errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expected ErrWaitTimeout, got %v", err)
+	}
+}
+
+func TestWaitForEvents_Removed(t *testing.T) {
+	f := &fakeStatus{}
+	if _, err := WaitForEvents(context.Background(), "session-1", StatusRemoved, time.Second, f.get, make(chan StateEvent)); err != nil {
+		t.Errorf("expected a missing sandbox to satisfy StatusRemoved, got %v", err)
+	}
+	if _, err := WaitForEvents(context.Background(), "session-1", StatusRunning, time.Second, f.get, make(chan StateEvent)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestWaitForEvents_Failure(t *testing.T) {
+	f := &fakeStatus{status: StatusFailed}
+	events := make(chan StateEvent, 3)
+	// A restart keeps the wait going; the next failure without one ends it
+	events <- StateEvent{SessionID: "session-1", Status: StatusFailed, Restarting: true}
+	events <- StateEvent{SessionID: "session-1", Status: StatusFailed, Error: "exited with code 1"}
+
+	_, err := WaitForEvents(context.Background(), "session-1", StatusRunning, time.Second, f.get, events)
+	if err == nil || errors.Is(err, ErrWaitTimeout) {
+		t.Fatalf("expected the failure to end the wait, got %v", err)
+	}
+}
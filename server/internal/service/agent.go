@@ -2,12 +2,18 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/obot-platform/discobot/server/internal/model"
 	"github.com/obot-platform/discobot/server/internal/store"
 )
 
+// ErrMissingCredentials is returned when creating an agent whose type
+// requires credentials the project doesn't have.
+var ErrMissingCredentials = errors.New("missing required credentials")
+
 // Agent represents an agent configuration (for API responses)
 type Agent struct {
 	ID        string `json:"id"`
@@ -17,7 +23,8 @@ type Agent struct {
 
 // AgentService handles agent operations
 type AgentService struct {
-	store *store.Store
+	store      *store.Store
+	agentTypes []AgentType
 }
 
 // NewAgentService creates a new agent service
@@ -25,6 +32,12 @@ func NewAgentService(s *store.Store) *AgentService {
 	return &AgentService{store: s}
 }
 
+// SetAgentTypes sets the agent types whose required credentials CreateAgent
+// checks. Without them, agents of any type are created unchecked.
+func (s *AgentService) SetAgentTypes(agentTypes []AgentType) {
+	s.agentTypes = agentTypes
+}
+
 // ListAgents returns all agents for a project
 func (s *AgentService) ListAgents(ctx context.Context, projectID string) ([]*Agent, error) {
 	dbAgents, err := s.store.ListAgentsByProject(ctx, projectID)
@@ -49,8 +62,13 @@ func (s *AgentService) GetAgent(ctx context.Context, agentID string) (*Agent, er
 	return s.mapAgent(ag), nil
 }
 
-// CreateAgent creates a new agent
+// CreateAgent creates a new agent. Returns ErrMissingCredentials if the
+// agent type requires credentials the project doesn't have.
 func (s *AgentService) CreateAgent(ctx context.Context, projectID, agentType string) (*Agent, error) {
+	if err := s.checkRequiredCredentials(ctx, projectID, agentType); err != nil {
+		return nil, err
+	}
+
 	// Check if this will be the first agent for the project
 	existingAgents, err := s.store.ListAgentsByProject(ctx, projectID)
 	if err != nil {
@@ -78,6 +96,30 @@ func (s *AgentService) CreateAgent(ctx context.Context, projectID, agentType str
 	return s.mapAgent(ag), nil
 }
 
+// checkRequiredCredentials returns ErrMissingCredentials, naming the
+// missing providers, unless the project has a credential for every provider
+// agentType requires.
+func (s *AgentService) checkRequiredCredentials(ctx context.Context, projectID, agentType string) error {
+	at := findAgentType(s.agentTypes, agentType)
+	if at == nil {
+		return nil
+	}
+
+	var missing []string
+	for _, provider := range at.RequiredCredentialProviders {
+		if _, err := s.store.GetCredentialByProvider(ctx, projectID, provider); err != nil {
+			if !errors.Is(err, store.ErrNotFound) {
+				return fmt.Errorf("failed to get %s credential: %w", provider, err)
+			}
+			missing = append(missing, provider)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s agents need credentials for %s", ErrMissingCredentials, agentType, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
 // UpdateAgent updates an agent
 func (s *AgentService) UpdateAgent(ctx context.Context, agentID string) (*Agent, error) {
 	ag, err := s.store.GetAgentByID(ctx, agentID)
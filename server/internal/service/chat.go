@@ -1,6 +1,7 @@
 package service
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
@@ -31,10 +32,23 @@ type ChatService struct {
 	sandboxService *SandboxService
 	gitService     *GitService
 
+	// historyPolicy trims chat history before it is sent to the agent (nil = send everything)
+	historyPolicy *HistoryPolicy
+
 	// Git user config cache - populated once on first use
 	gitConfigOnce sync.Once
 	gitUserName   string
 	gitUserEmail  string
+
+	// completions holds the cancel func of the context each session's
+	// in-progress SendToSandbox stream was started with.
+	completionsMu sync.Mutex
+	completions   map[string]*activeCompletion
+}
+
+// activeCompletion is a completion stream started by SendToSandbox.
+type activeCompletion struct {
+	cancel context.CancelFunc
 }
 
 // NewChatService creates a new chat service.
@@ -49,6 +63,12 @@ func NewChatService(s *store.Store, sessionService *SessionService, jobEnqueuer
 	}
 }
 
+// SetHistoryPolicy sets the policy used to trim chat history before it is sent
+// to the agent. A nil policy forwards the full history.
+func (c *ChatService) SetHistoryPolicy(policy *HistoryPolicy) {
+	c.historyPolicy = policy
+}
+
 // NewSessionRequest contains the parameters for creating a new chat session.
 type NewSessionRequest struct {
 	// SessionID is the client-provided session ID (required)
@@ -97,8 +117,12 @@ func (c *ChatService) NewSession(ctx context.Context, req NewSessionRequest) (st
 		return "", fmt.Errorf("agent does not belong to this project")
 	}
 
-	// Try to derive session name from first user message text
+	// Try to derive session name from first user message text, or from
+	// the name template if one is set
 	name := deriveSessionName(req.Messages)
+	if c.sessionService.nameTemplate != "" {
+		name = c.sessionService.generateName(ctx, req.WorkspaceID, firstUserText(req.Messages))
+	}
 
 	// Use SessionService to create the session with client-provided ID
 	sess, err := c.sessionService.CreateSessionWithID(ctx, req.SessionID, req.ProjectID, req.WorkspaceID, name, req.AgentID, req.Model, req.Reasoning)
@@ -226,6 +250,20 @@ func (c *ChatService) SendToSandbox(ctx context.Context, projectID, sessionID st
 		effectiveReasoning = *session.Reasoning
 	}
 
+	// Trim history to the configured window, recording what was left out
+	trimmed, err := c.historyPolicy.Apply(messages)
+	if err != nil {
+		log.Printf("Warning: failed to trim chat history for %s, sending full history: %v", sessionID, err)
+	}
+	messages = trimmed.Messages
+	if trimmed.Dropped > 0 && (session.HistoryTrimmedThrough == nil || *session.HistoryTrimmedThrough != trimmed.LastDroppedID) {
+		session.HistoryTrimmedThrough = &trimmed.LastDroppedID
+		session.HistoryTrimmedCount = trimmed.Dropped
+		if err := c.store.UpdateSession(ctx, session); err != nil {
+			log.Printf("Warning: failed to record chat history trim for %s: %v", sessionID, err)
+		}
+	}
+
 	if c.sandboxService == nil {
 		return nil, fmt.Errorf("sandbox provider not available")
 	}
@@ -258,7 +296,48 @@ func (c *ChatService) SendToSandbox(ctx context.Context, projectID, sessionID st
 		modelID = *session.Model
 	}
 
-	return client.SendMessages(ctx, messages, modelID, opts)
+	// Send with a context CancelCompletion can cancel, so cancelling also
+	// stops the server side of the stream.
+	ctx, cancel := context.WithCancel(ctx)
+	c.trackCompletion(ctx, sessionID, cancel)
+	sseCh, err := client.SendMessages(ctx, messages, modelID, opts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return sseCh, nil
+}
+
+// trackCompletion records cancel as the way to stop sessionID's completion
+// stream until ctx is done.
+func (c *ChatService) trackCompletion(ctx context.Context, sessionID string, cancel context.CancelFunc) {
+	completion := &activeCompletion{cancel: cancel}
+
+	c.completionsMu.Lock()
+	if c.completions == nil {
+		c.completions = make(map[string]*activeCompletion)
+	}
+	c.completions[sessionID] = completion
+	c.completionsMu.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		c.completionsMu.Lock()
+		defer c.completionsMu.Unlock()
+		if c.completions[sessionID] == completion {
+			delete(c.completions, sessionID)
+		}
+	})
+}
+
+// cancelStream cancels the context of sessionID's completion stream, if one
+// is active.
+func (c *ChatService) cancelStream(sessionID string) {
+	c.completionsMu.Lock()
+	completion := c.completions[sessionID]
+	c.completionsMu.Unlock()
+	if completion != nil {
+		completion.cancel()
+	}
 }
 
 // GetStream returns a channel of SSE events for an in-progress completion.
@@ -312,7 +391,9 @@ func (c *ChatService) GetMessages(ctx context.Context, projectID, sessionID stri
 	return client.GetMessages(ctx, opts)
 }
 
-// CancelCompletion cancels an in-progress chat completion in the sandbox.
+// CancelCompletion cancels an in-progress chat completion in the sandbox, so
+// the agent stops its model request, and then cancels the context the
+// completion's stream was started with in SendToSandbox.
 // Returns ErrNoActiveCompletion if no completion is active.
 // The sandbox is automatically reconciled if not running.
 func (c *ChatService) CancelCompletion(ctx context.Context, projectID, sessionID string) (*CancelCompletionResponse, error) {
@@ -322,6 +403,7 @@ func (c *ChatService) CancelCompletion(ctx context.Context, projectID, sessionID
 	if c.sandboxService == nil {
 		return nil, fmt.Errorf("sandbox provider not available")
 	}
+	defer c.cancelStream(sessionID)
 	client, err := c.sandboxService.GetClient(ctx, sessionID)
 	if err != nil {
 		return nil, err
@@ -398,7 +480,16 @@ func (c *ChatService) ReadFile(ctx context.Context, projectID, sessionID, path s
 	if err != nil {
 		return nil, err
 	}
-	return client.ReadFile(ctx, path)
+	resp, err := client.ReadFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := decodeFileContent(resp.Content, resp.Encoding)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode file content: %w", err)
+	}
+	resp.Hash = ContentHash(content)
+	return resp, nil
 }
 
 // ReadFileFromBase reads a file from the base commit (for deleted files).
@@ -446,11 +537,16 @@ func (c *ChatService) ReadFileFromBase(ctx context.Context, projectID, sessionID
 		Content:  string(content),
 		Encoding: "utf-8",
 		Size:     int64(len(content)),
+		Hash:     ContentHash(content),
 	}, nil
 }
 
 // WriteFile writes file content to the sandbox.
 // The sandbox is automatically reconciled if not running.
+// If req.ExpectedHash is set, the write fails with a *FileConflictError
+// unless the file's current content has that hash. The check and the write
+// are separate sandbox calls, so this catches stale editors rather than
+// guaranteeing atomicity.
 func (c *ChatService) WriteFile(ctx context.Context, projectID, sessionID string, req *sandboxapi.WriteFileRequest) (*sandboxapi.WriteFileResponse, error) {
 	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
 		return nil, err
@@ -462,7 +558,36 @@ func (c *ChatService) WriteFile(ctx context.Context, projectID, sessionID string
 	if err != nil {
 		return nil, err
 	}
-	return client.WriteFile(ctx, req)
+
+	if req.ExpectedHash != "" {
+		var currentHash string
+		current, err := client.ReadFile(ctx, req.Path)
+		switch {
+		case err == nil:
+			currentContent, err := decodeFileContent(current.Content, current.Encoding)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode file content: %w", err)
+			}
+			currentHash = ContentHash(currentContent)
+		case !strings.Contains(err.Error(), "status 404"):
+			return nil, err
+		}
+		if err := checkExpectedHash(req.Path, req.ExpectedHash, currentHash); err != nil {
+			return nil, err
+		}
+		unconditional := *req
+		unconditional.ExpectedHash = ""
+		req = &unconditional
+	}
+
+	resp, err := client.WriteFile(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if content, err := decodeFileContent(req.Content, req.Encoding); err == nil {
+		resp.Hash = ContentHash(content)
+	}
+	return resp, nil
 }
 
 // DeleteFile deletes a file or directory in the sandbox.
@@ -497,6 +622,161 @@ func (c *ChatService) RenameFile(ctx context.Context, projectID, sessionID strin
 	return client.RenameFile(ctx, req)
 }
 
+// CreateUpload starts a resumable upload of a large file to the session's workspace.
+func (c *ChatService) CreateUpload(ctx context.Context, projectID, sessionID string, req *sandboxapi.CreateUploadRequest) (*sandboxapi.UploadStatus, error) {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+	client, err := c.sandboxService.GetClient(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.CreateUpload(ctx, req)
+}
+
+// GetUpload returns a resumable upload's progress, so a client can resume it.
+func (c *ChatService) GetUpload(ctx context.Context, projectID, sessionID, uploadID string) (*sandboxapi.UploadStatus, error) {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+	client, err := c.sandboxService.GetClient(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetUpload(ctx, uploadID)
+}
+
+// AppendUpload appends a chunk starting at offset to a resumable upload.
+// A chunk at the wrong offset fails with *UploadOffsetError.
+func (c *ChatService) AppendUpload(ctx context.Context, projectID, sessionID, uploadID string, offset int64, chunk []byte) (*sandboxapi.UploadStatus, error) {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+	client, err := c.sandboxService.GetClient(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.AppendUpload(ctx, uploadID, offset, chunk)
+}
+
+// AbortUpload cancels a resumable upload and discards its data.
+func (c *ChatService) AbortUpload(ctx context.Context, projectID, sessionID, uploadID string) (*sandboxapi.AbortUploadResponse, error) {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+	client, err := c.sandboxService.GetClient(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.AbortUpload(ctx, uploadID)
+}
+
+// GetKV reads a value from the session's key-value store.
+func (c *ChatService) GetKV(ctx context.Context, projectID, sessionID, key string) (*sandboxapi.KVEntry, error) {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+	client, err := c.sandboxService.GetClient(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetKV(ctx, key)
+}
+
+// PutKV creates or replaces a value in the session's key-value store.
+func (c *ChatService) PutKV(ctx context.Context, projectID, sessionID, key string, req *sandboxapi.PutKVRequest) (*sandboxapi.KVEntry, error) {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+	client, err := c.sandboxService.GetClient(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.PutKV(ctx, key, req)
+}
+
+// DeleteKV deletes a value from the session's key-value store.
+func (c *ChatService) DeleteKV(ctx context.Context, projectID, sessionID, key string) (*sandboxapi.DeleteKVResponse, error) {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+	client, err := c.sandboxService.GetClient(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.DeleteKV(ctx, key)
+}
+
+// TestNetwork checks whether the session's sandbox can reach a URL or host,
+// going through the sandbox's proxy like the agent's own requests.
+func (c *ChatService) TestNetwork(ctx context.Context, projectID, sessionID string, req *sandboxapi.NetworkTestRequest) (*sandboxapi.NetworkTestResponse, error) {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+	client, err := c.sandboxService.GetClient(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.TestNetwork(ctx, req)
+}
+
+// GetProxyStats returns per-domain traffic statistics from the session's
+// sandbox proxy: what the agent accessed, how much it transferred, and how
+// often the cache was hit.
+func (c *ChatService) GetProxyStats(ctx context.Context, projectID, sessionID string) (*sandboxapi.ProxyStatsResponse, error) {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+	client, err := c.sandboxService.GetClient(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetProxyStats(ctx)
+}
+
+// GetFileDescriptors returns the open file descriptor counts and limits of
+// the session's sandbox processes, with warnings for any near their limit.
+func (c *ChatService) GetFileDescriptors(ctx context.Context, projectID, sessionID string) (*sandboxapi.FileDescriptorsResponse, error) {
+	if _, err := c.GetSession(ctx, projectID, sessionID); err != nil {
+		return nil, err
+	}
+	if c.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox provider not available")
+	}
+	client, err := c.sandboxService.GetClient(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetFileDescriptors(ctx)
+}
+
 // GetDiff retrieves diff information from the sandbox.
 // If path is non-empty, returns a single file diff.
 // If format is "files", returns just file paths.
@@ -641,8 +921,14 @@ func (c *ChatService) GetServiceOutput(ctx context.Context, projectID, sessionID
 // It looks for the first user message with text content.
 // Returns "New Session" if no suitable text is found.
 func deriveSessionName(messages json.RawMessage) string {
+	return cmp.Or(firstUserText(messages), defaultSessionName)
+}
+
+// firstUserText returns the text of the first user message with text
+// content, trimmed of surrounding whitespace, or "" if there is none.
+func firstUserText(messages json.RawMessage) string {
 	if len(messages) == 0 {
-		return "New Session"
+		return ""
 	}
 
 	// Minimal struct to extract just what we need
@@ -657,7 +943,7 @@ func deriveSessionName(messages json.RawMessage) string {
 
 	var msgs []minimalMessage
 	if err := json.Unmarshal(messages, &msgs); err != nil {
-		return "New Session"
+		return ""
 	}
 
 	// Find first user message with text
@@ -676,5 +962,5 @@ func deriveSessionName(messages json.RawMessage) string {
 		}
 	}
 
-	return "New Session"
+	return ""
 }
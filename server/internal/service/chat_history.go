@@ -0,0 +1,215 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox/sandboxapi"
+)
+
+// History strategy names, as accepted by CHAT_HISTORY_STRATEGY.
+const (
+	HistoryStrategyDropOldest = "drop-oldest"
+	HistoryStrategySummarize  = "summarize"
+)
+
+const (
+	// summaryLineMaxChars caps how much of each dropped message is quoted in a summary.
+	summaryLineMaxChars = 200
+	// summaryMaxLines caps how many dropped messages are listed in a summary.
+	summaryMaxLines = 50
+)
+
+// HistoryStrategy condenses the chat messages that fall outside the history
+// window. It returns the messages to send in their place (nil drops them).
+type HistoryStrategy interface {
+	Condense(dropped []sandboxapi.UIMessage) ([]sandboxapi.UIMessage, error)
+}
+
+// HistoryStrategyFunc adapts a function to the HistoryStrategy interface.
+type HistoryStrategyFunc func(dropped []sandboxapi.UIMessage) ([]sandboxapi.UIMessage, error)
+
+// Condense implements HistoryStrategy.
+func (f HistoryStrategyFunc) Condense(dropped []sandboxapi.UIMessage) ([]sandboxapi.UIMessage, error) {
+	return f(dropped)
+}
+
+// NewHistoryStrategy returns the built-in strategy with the given name.
+func NewHistoryStrategy(name string) (HistoryStrategy, error) {
+	switch name {
+	case "", HistoryStrategyDropOldest:
+		return HistoryStrategyFunc(dropOldest), nil
+	case HistoryStrategySummarize:
+		return HistoryStrategyFunc(summarizeOldest), nil
+	default:
+		return nil, fmt.Errorf("unknown chat history strategy %q", name)
+	}
+}
+
+// HistoryPolicy limits how much chat history is forwarded to the agent on each
+// turn. Messages before the window are handed to the Strategy. The most recent
+// user message is always kept, since it is the prompt being sent.
+type HistoryPolicy struct {
+	MaxMessages int // Messages kept verbatim (0 = unlimited)
+	MaxTokens   int // Approximate token budget for kept messages (0 = unlimited)
+	Strategy    HistoryStrategy
+}
+
+// NewHistoryPolicy creates a history policy, or returns nil if no limit is set.
+func NewHistoryPolicy(maxMessages, maxTokens int, strategy string) (*HistoryPolicy, error) {
+	if maxMessages <= 0 && maxTokens <= 0 {
+		return nil, nil
+	}
+	s, err := NewHistoryStrategy(strategy)
+	if err != nil {
+		return nil, err
+	}
+	return &HistoryPolicy{MaxMessages: maxMessages, MaxTokens: maxTokens, Strategy: s}, nil
+}
+
+// TrimmedHistory is the result of applying a HistoryPolicy.
+type TrimmedHistory struct {
+	Messages      json.RawMessage // Messages to send to the agent
+	Dropped       int             // Number of messages outside the window (0 = unchanged)
+	LastDroppedID string          // ID of the newest message outside the window
+}
+
+// Apply trims messages (a raw UIMessage array) to the policy's window.
+// A nil policy, or input that isn't a message array, is passed through unchanged.
+func (p *HistoryPolicy) Apply(messages json.RawMessage) (TrimmedHistory, error) {
+	unchanged := TrimmedHistory{Messages: messages}
+	if p == nil {
+		return unchanged, nil
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(messages, &raw); err != nil {
+		return unchanged, nil
+	}
+	msgs := make([]sandboxapi.UIMessage, len(raw))
+	for i, m := range raw {
+		if err := json.Unmarshal(m, &msgs[i]); err != nil {
+			return unchanged, nil
+		}
+	}
+
+	start := p.windowStart(raw, msgs)
+	if start == 0 {
+		return unchanged, nil
+	}
+
+	condensed, err := p.Strategy.Condense(msgs[:start])
+	if err != nil {
+		return unchanged, fmt.Errorf("failed to condense chat history: %w", err)
+	}
+
+	out := make([]json.RawMessage, 0, len(condensed)+len(raw)-start)
+	for _, m := range condensed {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return unchanged, err
+		}
+		out = append(out, data)
+	}
+	// Kept messages are forwarded byte-for-byte so no UIMessage fields are lost
+	out = append(out, raw[start:]...)
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return unchanged, err
+	}
+	return TrimmedHistory{
+		Messages:      data,
+		Dropped:       start,
+		LastDroppedID: msgs[start-1].ID,
+	}, nil
+}
+
+// windowStart returns the index of the first message kept verbatim.
+func (p *HistoryPolicy) windowStart(raw []json.RawMessage, msgs []sandboxapi.UIMessage) int {
+	start := 0
+	if p.MaxMessages > 0 && len(raw) > p.MaxMessages {
+		start = len(raw) - p.MaxMessages
+	}
+	if p.MaxTokens > 0 {
+		tokens := 0
+		for i := len(raw) - 1; i >= start; i-- {
+			tokens += estimateTokens(raw[i])
+			if tokens > p.MaxTokens {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	// Never drop the latest user message
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == "user" {
+			start = min(start, i)
+			break
+		}
+	}
+	return start
+}
+
+// estimateTokens approximates the token count of a message (~4 bytes per token).
+func estimateTokens(data []byte) int {
+	return (len(data) + 3) / 4
+}
+
+// dropOldest discards messages outside the window.
+func dropOldest([]sandboxapi.UIMessage) ([]sandboxapi.UIMessage, error) {
+	return nil, nil
+}
+
+// summarizeOldest replaces messages outside the window with a single system
+// message listing an excerpt of each, so the agent keeps some earlier context.
+func summarizeOldest(dropped []sandboxapi.UIMessage) ([]sandboxapi.UIMessage, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Summary of %d earlier messages omitted from this conversation:\n", len(dropped))
+
+	listed := dropped
+	if len(listed) > summaryMaxLines {
+		listed = listed[len(listed)-summaryMaxLines:]
+		fmt.Fprintf(&b, "- (%d older messages not shown)\n", len(dropped)-summaryMaxLines)
+	}
+	for _, m := range listed {
+		text := messageText(m)
+		if text == "" {
+			continue
+		}
+		if len(text) > summaryLineMaxChars {
+			text = strings.ToValidUTF8(text[:summaryLineMaxChars], "") + "…"
+		}
+		fmt.Fprintf(&b, "- %s: %s\n", m.Role, text)
+	}
+
+	parts, err := json.Marshal([]map[string]string{{"type": "text", "text": b.String()}})
+	if err != nil {
+		return nil, err
+	}
+	return []sandboxapi.UIMessage{{
+		ID:    "history-summary-" + dropped[len(dropped)-1].ID,
+		Role:  "system",
+		Parts: parts,
+	}}, nil
+}
+
+// messageText returns the text parts of a message joined on one line.
+func messageText(m sandboxapi.UIMessage) string {
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(m.Parts, &parts); err != nil {
+		return ""
+	}
+	var texts []string
+	for _, part := range parts {
+		if part.Type == "text" && strings.TrimSpace(part.Text) != "" {
+			texts = append(texts, strings.TrimSpace(part.Text))
+		}
+	}
+	return strings.Join(strings.Fields(strings.Join(texts, " ")), " ")
+}
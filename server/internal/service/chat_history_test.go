@@ -0,0 +1,158 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox/sandboxapi"
+)
+
+// testHistory builds a raw UIMessage array alternating user/assistant messages.
+func testHistory(t *testing.T, n int) json.RawMessage {
+	t.Helper()
+	msgs := make([]map[string]any, n)
+	for i := range msgs {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		msgs[i] = map[string]any{
+			"id":       fmt.Sprintf("msg-%d", i),
+			"role":     role,
+			"parts":    []map[string]string{{"type": "text", "text": fmt.Sprintf("message %d", i)}},
+			"metadata": map[string]int{"index": i},
+		}
+	}
+	data, err := json.Marshal(msgs)
+	if err != nil {
+		t.Fatalf("failed to marshal history: %v", err)
+	}
+	return data
+}
+
+func decodeHistory(t *testing.T, data json.RawMessage) []sandboxapi.UIMessage {
+	t.Helper()
+	var msgs []sandboxapi.UIMessage
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		t.Fatalf("failed to decode history: %v", err)
+	}
+	return msgs
+}
+
+func TestHistoryPolicy_NilPassesThrough(t *testing.T) {
+	history := testHistory(t, 10)
+
+	policy, err := NewHistoryPolicy(0, 0, HistoryStrategySummarize)
+	if err != nil || policy != nil {
+		t.Fatalf("NewHistoryPolicy(0, 0) = %v, %v; want nil, nil", policy, err)
+	}
+
+	result, err := policy.Apply(history)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if result.Dropped != 0 || string(result.Messages) != string(history) {
+		t.Errorf("Expected history to be unchanged, got %d dropped", result.Dropped)
+	}
+}
+
+func TestHistoryPolicy_DropOldest(t *testing.T) {
+	policy, err := NewHistoryPolicy(4, 0, HistoryStrategyDropOldest)
+	if err != nil {
+		t.Fatalf("NewHistoryPolicy failed: %v", err)
+	}
+
+	// Last message is a user message (index 8)
+	result, err := policy.Apply(testHistory(t, 9))
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if result.Dropped != 5 || result.LastDroppedID != "msg-4" {
+		t.Errorf("Expected 5 dropped through msg-4, got %d through %q", result.Dropped, result.LastDroppedID)
+	}
+
+	msgs := decodeHistory(t, result.Messages)
+	if len(msgs) != 4 || msgs[0].ID != "msg-5" || msgs[3].ID != "msg-8" {
+		t.Errorf("Expected msg-5..msg-8, got %+v", msgs)
+	}
+
+	// Kept messages are forwarded verbatim, including fields the server doesn't model
+	if !strings.Contains(string(result.Messages), `"metadata":{"index":8}`) {
+		t.Errorf("Expected unknown fields to be preserved, got %s", result.Messages)
+	}
+}
+
+func TestHistoryPolicy_KeepsLatestUserMessage(t *testing.T) {
+	policy, err := NewHistoryPolicy(1, 0, HistoryStrategyDropOldest)
+	if err != nil {
+		t.Fatalf("NewHistoryPolicy failed: %v", err)
+	}
+
+	// Last message (index 5) is an assistant message; latest user message is index 4
+	result, err := policy.Apply(testHistory(t, 6))
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	msgs := decodeHistory(t, result.Messages)
+	if len(msgs) != 2 || msgs[0].ID != "msg-4" {
+		t.Errorf("Expected window to start at latest user message msg-4, got %+v", msgs)
+	}
+}
+
+func TestHistoryPolicy_TokenBudget(t *testing.T) {
+	history := testHistory(t, 9)
+	var raw []json.RawMessage
+	if err := json.Unmarshal(history, &raw); err != nil {
+		t.Fatalf("failed to split history: %v", err)
+	}
+
+	// Budget for exactly the last three messages
+	budget := estimateTokens(raw[6]) + estimateTokens(raw[7]) + estimateTokens(raw[8])
+	policy, err := NewHistoryPolicy(0, budget, HistoryStrategyDropOldest)
+	if err != nil {
+		t.Fatalf("NewHistoryPolicy failed: %v", err)
+	}
+
+	result, err := policy.Apply(history)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if result.Dropped != 6 {
+		t.Errorf("Expected 6 dropped, got %d", result.Dropped)
+	}
+}
+
+func TestHistoryPolicy_Summarize(t *testing.T) {
+	policy, err := NewHistoryPolicy(3, 0, HistoryStrategySummarize)
+	if err != nil {
+		t.Fatalf("NewHistoryPolicy failed: %v", err)
+	}
+
+	result, err := policy.Apply(testHistory(t, 7))
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	msgs := decodeHistory(t, result.Messages)
+	if len(msgs) != 4 {
+		t.Fatalf("Expected summary plus 3 messages, got %d", len(msgs))
+	}
+	summary := msgs[0]
+	if summary.Role != "system" || summary.ID != "history-summary-msg-3" {
+		t.Errorf("Unexpected summary message: %+v", summary)
+	}
+	text := messageText(summary)
+	for _, want := range []string{"Summary of 4 earlier messages", "user: message 0", "assistant: message 3"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected summary to contain %q, got %q", want, text)
+		}
+	}
+}
+
+func TestNewHistoryStrategy_Unknown(t *testing.T) {
+	if _, err := NewHistoryPolicy(10, 0, "compress"); err == nil {
+		t.Error("Expected error for unknown strategy")
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/obot-platform/discobot/server/internal/config"
 	"github.com/obot-platform/discobot/server/internal/encryption"
+	"github.com/obot-platform/discobot/server/internal/events"
 	"github.com/obot-platform/discobot/server/internal/model"
 	"github.com/obot-platform/discobot/server/internal/oauth"
 	"github.com/obot-platform/discobot/server/internal/providers"
@@ -68,6 +69,10 @@ type CredentialInfo struct {
 	AuthType     string     `json:"authType"`
 	IsConfigured bool       `json:"isConfigured"`
 	ExpiresAt    *time.Time `json:"expiresAt,omitempty"` // For OAuth credentials
+	// ExpiringSoon is set when an OAuth credential expires within the warning
+	// window and can't be refreshed automatically, so the user should re-authenticate.
+	ExpiringSoon bool       `json:"expiringSoon,omitempty"`
+	LastUsedAt   *time.Time `json:"lastUsedAt,omitempty"` // Last time the credential was passed to a sandbox
 	CreatedAt    time.Time  `json:"createdAt"`
 	UpdatedAt    time.Time  `json:"updatedAt"`
 }
@@ -79,8 +84,19 @@ type CredentialService struct {
 	encryptor        *encryption.Encryptor
 	lastRefreshFail  map[string]time.Time // Track last refresh failure per provider
 	refreshFailMutex sync.RWMutex         // Protect the map
+	eventBroker      *events.Broker
 }
 
+// expiryWarnings records the credential_expiring events already published,
+// keyed by project and provider, so each expiry time is only announced once.
+// It's shared by all CredentialService instances (the handler's, the
+// dispatcher's and the expiry watcher's), which would otherwise each announce
+// the same expiry.
+var expiryWarnings = struct {
+	sync.Mutex
+	warned map[string]time.Time
+}{warned: make(map[string]time.Time)}
+
 // NewCredentialService creates a new credential service
 func NewCredentialService(s *store.Store, cfg *config.Config) (*CredentialService, error) {
 	enc, err := encryption.NewEncryptor(cfg.EncryptionKey)
@@ -96,6 +112,12 @@ func NewCredentialService(s *store.Store, cfg *config.Config) (*CredentialServic
 	}, nil
 }
 
+// SetEventBroker sets the broker used to publish credential_expiring events.
+// Without one, expiry is still reported in credential info but not pushed.
+func (s *CredentialService) SetEventBroker(broker *events.Broker) {
+	s.eventBroker = broker
+}
+
 // List returns all credentials for a project (safe info only, no secrets)
 func (s *CredentialService) List(ctx context.Context, projectID string) ([]CredentialInfo, error) {
 	creds, err := s.store.ListCredentialsByProject(ctx, projectID)
@@ -152,6 +174,7 @@ func (s *CredentialService) SetAPIKey(ctx context.Context, projectID, provider,
 		if err := s.store.UpdateCredential(ctx, existing); err != nil {
 			return nil, err
 		}
+		forgetExpiryWarning(projectID, provider)
 		info := s.toCredentialInfo(existing)
 		return &info, nil
 	}
@@ -200,6 +223,7 @@ func (s *CredentialService) SetOAuthTokens(ctx context.Context, projectID, provi
 		if err := s.store.UpdateCredential(ctx, existing); err != nil {
 			return nil, err
 		}
+		forgetExpiryWarning(projectID, provider)
 		info := s.toCredentialInfo(existing)
 		return &info, nil
 	}
@@ -360,7 +384,11 @@ func (s *CredentialService) RefreshOAuthTokens(ctx context.Context, projectID, p
 
 // Delete removes a credential
 func (s *CredentialService) Delete(ctx context.Context, projectID, provider string) error {
-	return s.store.DeleteCredential(ctx, projectID, provider)
+	if err := s.store.DeleteCredential(ctx, projectID, provider); err != nil {
+		return err
+	}
+	forgetExpiryWarning(projectID, provider)
+	return nil
 }
 
 // CredentialEnvVar represents a credential value with its target environment variable.
@@ -368,6 +396,10 @@ func (s *CredentialService) Delete(ctx context.Context, projectID, provider stri
 type CredentialEnvVar struct {
 	EnvVar string `json:"envVar"`
 	Value  string `json:"value"`
+	// File, if set, asks the agent to write Value to a file with this name in
+	// its private, non-persistent credentials directory and set EnvVar to the
+	// file's path instead. Used for tools that only read secrets from files.
+	File string `json:"file,omitempty"`
 }
 
 // GetAllDecrypted returns all credentials for a project as environment variable mappings.
@@ -380,11 +412,29 @@ func (s *CredentialService) GetAllDecrypted(ctx context.Context, projectID strin
 	}
 
 	result := make([]CredentialEnvVar, 0, len(creds))
+	var usedIDs []string
 	for _, c := range creds {
 		if !c.IsConfigured {
 			continue
 		}
 
+		// Registry credentials configure package managers rather than
+		// mapping to a provider's env var
+		if c.AuthType == AuthTypeRegistry {
+			var data RegistryCredential
+			if err := s.encryptor.DecryptJSON(c.EncryptedData, &data); err != nil {
+				continue
+			}
+			envVars, err := registryEnvVars(c.Provider, c.Name, &data)
+			if err != nil {
+				log.Printf("Warning: Skipping registry credential %s: %v", c.Provider, err)
+				continue
+			}
+			result = append(result, envVars...)
+			usedIDs = append(usedIDs, c.ID)
+			continue
+		}
+
 		// Get env var names for this provider
 		envVars := providers.GetEnvVars(c.Provider)
 		if len(envVars) == 0 {
@@ -404,6 +454,7 @@ func (s *CredentialService) GetAllDecrypted(ctx context.Context, projectID strin
 				EnvVar: envVars[0],
 				Value:  data.APIKey,
 			})
+			usedIDs = append(usedIDs, c.ID)
 		case AuthTypeOAuth:
 			// Use GetOAuthTokens to get auto-refresh behavior for expired tokens
 			tokens, err := s.GetOAuthTokens(ctx, projectID, c.Provider)
@@ -422,13 +473,112 @@ func (s *CredentialService) GetAllDecrypted(ctx context.Context, projectID strin
 					EnvVar: envVar,
 					Value:  tokens.AccessToken,
 				})
+				usedIDs = append(usedIDs, c.ID)
 			}
+			s.warnIfExpiring(ctx, projectID, c.Provider, tokens)
 		}
 	}
 
+	if err := s.store.MarkCredentialsUsed(ctx, usedIDs, time.Now()); err != nil {
+		log.Printf("Warning: Failed to record credential usage for project %s: %v", projectID, err)
+	}
+
 	return result, nil
 }
 
+// expiringSoon reports whether OAuth tokens expire within the warning window
+// and won't be renewed by GetOAuthTokens: the provider has no refresh support,
+// there is no refresh token, or the token is already expired (a refresh failed).
+func (s *CredentialService) expiringSoon(provider string, tokens *OAuthCredential, now time.Time) bool {
+	if tokens.ExpiresAt.IsZero() || tokens.ExpiresAt.After(now.Add(s.cfg.CredentialExpiryWarning)) {
+		return false
+	}
+	canRefresh := provider == ProviderAnthropic && tokens.RefreshToken != ""
+	return !canRefresh || !tokens.ExpiresAt.After(now)
+}
+
+// warnIfExpiring publishes a credential_expiring event the first time a
+// credential is found to be expiring soon for a given expiry time.
+func (s *CredentialService) warnIfExpiring(ctx context.Context, projectID, provider string, tokens *OAuthCredential) {
+	if s.eventBroker == nil || !s.expiringSoon(provider, tokens, time.Now()) {
+		return
+	}
+
+	key := projectID + "/" + provider
+	expiryWarnings.Lock()
+	if expiryWarnings.warned[key].Equal(tokens.ExpiresAt) {
+		expiryWarnings.Unlock()
+		return
+	}
+	expiryWarnings.warned[key] = tokens.ExpiresAt
+	expiryWarnings.Unlock()
+
+	log.Printf("Credential for provider %s in project %s expires at %s and needs re-authentication", provider, projectID, tokens.ExpiresAt.Format(time.RFC3339))
+	if err := s.eventBroker.PublishCredentialExpiring(ctx, projectID, provider, tokens.ExpiresAt); err != nil {
+		log.Printf("Warning: Failed to publish credential expiry event: %v", err)
+	}
+}
+
+// forgetExpiryWarning drops the record of a credential's expiry warning once
+// it is replaced or deleted.
+func forgetExpiryWarning(projectID, provider string) {
+	expiryWarnings.Lock()
+	delete(expiryWarnings.warned, projectID+"/"+provider)
+	expiryWarnings.Unlock()
+}
+
+// WatchExpiry checks all OAuth credentials every interval until ctx is done,
+// warning about those that have come within the expiry warning window (see
+// warnIfExpiring) without waiting for them to be used.
+func (s *CredentialService) WatchExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.checkExpiry(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkExpiry warns about every configured OAuth credential expiring soon.
+func (s *CredentialService) checkExpiry(ctx context.Context) {
+	creds, err := s.store.ListCredentialsByAuthType(ctx, AuthTypeOAuth)
+	if err != nil {
+		log.Printf("Warning: Failed to list OAuth credentials for expiry check: %v", err)
+		return
+	}
+
+	// Forget warnings for credentials that are gone, such as those removed
+	// along with their project
+	current := make(map[string]bool, len(creds))
+	for _, c := range creds {
+		current[c.ProjectID+"/"+c.Provider] = true
+	}
+	expiryWarnings.Lock()
+	for key := range expiryWarnings.warned {
+		if !current[key] {
+			delete(expiryWarnings.warned, key)
+		}
+	}
+	expiryWarnings.Unlock()
+
+	for _, c := range creds {
+		if !c.IsConfigured {
+			continue
+		}
+		var tokens OAuthCredential
+		if err := s.encryptor.DecryptJSON(c.EncryptedData, &tokens); err != nil {
+			log.Printf("Warning: Failed to decrypt credential %s for expiry check: %v", c.ID, err)
+			continue
+		}
+		s.warnIfExpiring(ctx, c.ProjectID, c.Provider, &tokens)
+	}
+}
+
 // isValidProvider checks if a provider is supported
 func isValidProvider(provider string) bool {
 	switch provider {
@@ -449,6 +599,7 @@ func (s *CredentialService) toCredentialInfo(c *model.Credential) CredentialInfo
 		Name:         c.Name,
 		AuthType:     c.AuthType,
 		IsConfigured: c.IsConfigured,
+		LastUsedAt:   c.LastUsedAt,
 		CreatedAt:    c.CreatedAt,
 		UpdatedAt:    c.UpdatedAt,
 	}
@@ -459,6 +610,7 @@ func (s *CredentialService) toCredentialInfo(c *model.Credential) CredentialInfo
 		if err := s.encryptor.DecryptJSON(c.EncryptedData, &tokens); err == nil {
 			if !tokens.ExpiresAt.IsZero() {
 				info.ExpiresAt = &tokens.ExpiresAt
+				info.ExpiringSoon = s.expiringSoon(c.Provider, &tokens, time.Now())
 			}
 		}
 	}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// FileConflictError is returned by a conditional file write when the file's
+// current content doesn't match the hash the client expected, i.e. someone
+// else changed it since the client read it.
+type FileConflictError struct {
+	Path        string
+	CurrentHash string // empty if the file no longer exists
+}
+
+func (e *FileConflictError) Error() string {
+	return fmt.Sprintf("file %s has changed since it was read", e.Path)
+}
+
+// UploadOffsetError is returned when an upload chunk doesn't start at the
+// upload's current offset, e.g. because an earlier attempt of the same chunk
+// was received. Offset is where the client should resume.
+type UploadOffsetError struct {
+	UploadID string
+	Offset   int64
+}
+
+func (e *UploadOffsetError) Error() string {
+	return fmt.Sprintf("upload %s chunk must start at offset %d", e.UploadID, e.Offset)
+}
+
+// ContentHash returns the hex SHA-256 of file content. It is the version
+// token returned by file reads and accepted as the expected hash of a
+// conditional write.
+func ContentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// decodeFileContent returns the raw bytes of sandbox file content.
+func decodeFileContent(content, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(content)
+	}
+	return []byte(content), nil
+}
+
+// checkExpectedHash returns a FileConflictError if expected doesn't match
+// the hash of the current content.
+func checkExpectedHash(path, expected, current string) error {
+	if expected == current {
+		return nil
+	}
+	return &FileConflictError{Path: path, CurrentHash: current}
+}
@@ -2,7 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"sync"
 
 	"github.com/obot-platform/discobot/server/internal/git"
 	"github.com/obot-platform/discobot/server/internal/store"
@@ -13,6 +16,10 @@ import (
 type GitService struct {
 	store    *store.Store
 	provider git.Provider
+
+	// writeMu serializes working tree writes so a conditional write's
+	// check and write can't interleave with another write.
+	writeMu sync.Mutex
 }
 
 // NewGitService creates a new git service.
@@ -42,8 +49,9 @@ func (s *GitService) EnsureWorkspaceRepo(ctx context.Context, workspaceID string
 	return s.provider.EnsureWorkspace(ctx, ws.ProjectID, workspaceID, ws.Path, "")
 }
 
-// Fetch fetches updates from remote for a workspace.
-func (s *GitService) Fetch(ctx context.Context, workspaceID string) error {
+// Fetch fetches updates from remote for a workspace, returning the
+// divergence from upstream, if any.
+func (s *GitService) Fetch(ctx context.Context, workspaceID string) (*git.ConflictError, error) {
 	return s.provider.Fetch(ctx, workspaceID)
 }
 
@@ -80,6 +88,29 @@ func (s *GitService) ReadFile(ctx context.Context, workspaceID, ref, path string
 
 // WriteFile writes content to a file in the workspace's working tree.
 func (s *GitService) WriteFile(ctx context.Context, workspaceID, path string, content []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.provider.WriteFile(ctx, workspaceID, path, content)
+}
+
+// WriteFileIfMatch writes content to a file in the working tree only if the
+// file's current content has expectedHash (see ContentHash). It returns a
+// *FileConflictError otherwise, including when the file has been deleted.
+func (s *GitService) WriteFileIfMatch(ctx context.Context, workspaceID, path string, content []byte, expectedHash string) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	var currentHash string
+	current, err := s.provider.ReadFile(ctx, workspaceID, "", path)
+	switch {
+	case err == nil:
+		currentHash = ContentHash(current)
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+	if err := checkExpectedHash(path, expectedHash, currentHash); err != nil {
+		return err
+	}
 	return s.provider.WriteFile(ctx, workspaceID, path, content)
 }
 
@@ -109,8 +140,8 @@ func (s *GitService) RemoveWorkspace(ctx context.Context, workspaceID string) er
 }
 
 // ApplyPatches applies mbox-format patches to the workspace.
-func (s *GitService) ApplyPatches(ctx context.Context, workspaceID string, patches []byte) (string, error) {
-	return s.provider.ApplyPatches(ctx, workspaceID, patches)
+func (s *GitService) ApplyPatches(ctx context.Context, workspaceID string, patches []byte, opts git.ApplyOptions) (string, error) {
+	return s.provider.ApplyPatches(ctx, workspaceID, patches, opts)
 }
 
 // Provider returns the underlying git provider.
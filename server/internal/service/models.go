@@ -31,6 +31,24 @@ type ModelsService struct {
 type AgentType struct {
 	ID                     string
 	SupportedAuthProviders []string
+
+	// DefaultEnv is set in the sandbox of every session using the agent.
+	DefaultEnv map[string]string
+
+	// RequiredCredentialProviders must all have a credential in the project
+	// before an agent of this type can be created.
+	RequiredCredentialProviders []string
+}
+
+// findAgentType returns the agent type with the given ID, or nil if there
+// is none.
+func findAgentType(agentTypes []AgentType, id string) *AgentType {
+	for i := range agentTypes {
+		if agentTypes[i].ID == id {
+			return &agentTypes[i]
+		}
+	}
+	return nil
 }
 
 // NewModelsService creates a new models service
@@ -53,13 +71,7 @@ func (s *ModelsService) GetModelsForAgent(ctx context.Context, agentID, projectI
 	}
 
 	// Find the agent type configuration
-	var agentType *AgentType
-	for i := range s.agentTypes {
-		if s.agentTypes[i].ID == agent.AgentType {
-			agentType = &s.agentTypes[i]
-			break
-		}
-	}
+	agentType := findAgentType(s.agentTypes, agent.AgentType)
 	if agentType == nil {
 		return nil, fmt.Errorf("unknown agent type: %s", agent.AgentType)
 	}
@@ -356,7 +356,7 @@ index 0000000..abc123
 	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, sandboxSvc, env.eventBroker, nil)
 
 	// Run PerformCommit
-	err = sessionSvc.PerformCommit(context.Background(), project.ID, session.ID)
+	err = sessionSvc.PerformCommit(context.Background(), project.ID, session.ID, "")
 	if err != nil {
 		t.Fatalf("PerformCommit failed: %v", err)
 	}
@@ -438,7 +438,7 @@ index 0000000..def456
 	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, sandboxSvc, env.eventBroker, nil)
 
 	// Run PerformCommit
-	err = sessionSvc.PerformCommit(context.Background(), project.ID, session.ID)
+	err = sessionSvc.PerformCommit(context.Background(), project.ID, session.ID, "")
 	if err != nil {
 		t.Fatalf("PerformCommit failed: %v", err)
 	}
@@ -556,7 +556,7 @@ index 0000000..abc123
 	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, sandboxSvc, env.eventBroker, nil)
 
 	// Run PerformCommit
-	err = sessionSvc.PerformCommit(context.Background(), project.ID, session.ID)
+	err = sessionSvc.PerformCommit(context.Background(), project.ID, session.ID, "")
 	if err != nil {
 		t.Fatalf("PerformCommit failed: %v", err)
 	}
@@ -676,7 +676,7 @@ index 0000000..abc123
 	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, sandboxSvc, env.eventBroker, nil)
 
 	// Run PerformCommit
-	err = sessionSvc.PerformCommit(context.Background(), project.ID, session.ID)
+	err = sessionSvc.PerformCommit(context.Background(), project.ID, session.ID, "")
 	if err != nil {
 		t.Fatalf("PerformCommit failed: %v", err)
 	}
@@ -756,7 +756,7 @@ index 0000000..abc123
 	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, sandboxSvc, env.eventBroker, nil)
 
 	// Run PerformCommit
-	err = sessionSvc.PerformCommit(context.Background(), project.ID, session.ID)
+	err = sessionSvc.PerformCommit(context.Background(), project.ID, session.ID, "")
 	if err != nil {
 		t.Fatalf("PerformCommit failed: %v", err)
 	}
@@ -876,7 +876,7 @@ index 0000000..abc123
 	sandboxSvc.SetSessionInitializer(sessionSvc)
 
 	// Run PerformCommit - should reconcile (start) the sandbox and complete successfully
-	err = sessionSvc.PerformCommit(context.Background(), project.ID, session.ID)
+	err = sessionSvc.PerformCommit(context.Background(), project.ID, session.ID, "")
 	if err != nil {
 		t.Fatalf("PerformCommit failed: %v", err)
 	}
@@ -915,3 +915,164 @@ index 0000000..abc123
 		t.Error("Expected at least one commits request")
 	}
 }
+
+func TestPerformCommit_SessionRemoving(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.cleanup()
+
+	project := env.createTestProject(t)
+	agent := env.createTestAgent(t, project.ID)
+	workspace, initialCommit := env.createTestWorkspace(t, project.ID)
+
+	session := env.createTestSession(t, project.ID, workspace.ID, agent.ID, initialCommit)
+	handler := newMockHandler()
+	env.mockSandbox.HTTPHandler = handler
+
+	// Deletion started after the commit job was queued
+	if err := env.store.UpdateSessionStatus(context.Background(), session.ID, model.SessionStatusRemoving, nil); err != nil {
+		t.Fatalf("Failed to update session: %v", err)
+	}
+
+	sandboxSvc := NewSandboxService(env.store, env.mockSandbox, &config.Config{}, nil, env.eventBroker, nil)
+	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, sandboxSvc, env.eventBroker, nil)
+
+	if err := sessionSvc.PerformCommit(context.Background(), project.ID, session.ID, ""); err != nil {
+		t.Fatalf("PerformCommit failed: %v", err)
+	}
+
+	// The commit is dropped without touching the agent or the session
+	if handler.getChatRequestCount() != 0 || handler.getCommitsRequestCount() != 0 {
+		t.Errorf("Expected no agent requests, got %d chat and %d commits", handler.getChatRequestCount(), handler.getCommitsRequestCount())
+	}
+	updatedSession, err := env.store.GetSessionByID(context.Background(), session.ID)
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if updatedSession.Status != model.SessionStatusRemoving || updatedSession.CommitStatus != session.CommitStatus {
+		t.Errorf("Expected session to stay removing with commit status %q, got %s/%q",
+			session.CommitStatus, updatedSession.Status, updatedSession.CommitStatus)
+	}
+}
+
+func TestCommitIdentity(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	name := "Jane Doe"
+	named := &model.User{Email: "jane@example.com", Name: &name, Provider: "github", ProviderID: "1"}
+	unnamed := &model.User{Email: "nameless@example.com", Provider: "github", ProviderID: "2"}
+	for _, u := range []*model.User{named, unnamed} {
+		if err := env.store.CreateUser(ctx, u); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+
+	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, nil, env.eventBroker, nil)
+	serverName, serverEmail := env.gitService.GetUserConfig(ctx)
+	server := gitIdentity{name: serverName, email: serverEmail}
+
+	tests := []struct {
+		name   string
+		userID string
+		want   gitIdentity
+	}{
+		{"user with name", named.ID, gitIdentity{name: "Jane Doe", email: "jane@example.com"}},
+		{"user without name", unnamed.ID, gitIdentity{name: "nameless@example.com", email: "nameless@example.com"}},
+		{"anonymous", model.AnonymousUserID, server},
+		{"no user", "", server},
+		{"unknown user", "missing", server},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sessionSvc.commitIdentity(ctx, tt.userID); got != tt.want {
+				t.Errorf("commitIdentity(%q) = %+v, want %+v", tt.userID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviewCommit(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.cleanup()
+	ctx := context.Background()
+
+	project := env.createTestProject(t)
+	agent := env.createTestAgent(t, project.ID)
+	workspace, initialCommit := env.createTestWorkspace(t, project.ID)
+	session := env.createTestSession(t, project.ID, workspace.ID, agent.ID, initialCommit)
+
+	handler := newMockHandler()
+	handler.commitsResponse = &sandboxapi.CommitsResponse{
+		Patches: `From abc123 Mon Sep 17 00:00:00 2001
+From: Agent <agent@example.com>
+Date: Mon, 1 Jan 2024 00:00:00 +0000
+Subject: Agent work
+
+---
+ agent.txt | 2 ++
+ 1 file changed, 2 insertions(+)
+
+diff --git a/agent.txt b/agent.txt
+new file mode 100644
+index 0000000..abc123
+--- /dev/null
++++ b/agent.txt
+@@ -0,0 +1,2 @@
++line one
++line two
+--
+`,
+		CommitCount: 1,
+	}
+	env.mockSandbox.HTTPHandler = handler
+
+	if _, err := env.mockSandbox.Create(ctx, session.ID, sandbox.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create sandbox: %v", err)
+	}
+	if err := env.mockSandbox.Start(ctx, session.ID); err != nil {
+		t.Fatalf("Failed to start sandbox: %v", err)
+	}
+
+	sandboxSvc := NewSandboxService(env.store, env.mockSandbox, &config.Config{}, nil, env.eventBroker, nil)
+	sandboxSvc.SetSessionInitializer(&testSessionInitializer{})
+	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, sandboxSvc, env.eventBroker, nil)
+
+	preview, err := sessionSvc.PreviewCommit(ctx, project.ID, session.ID)
+	if err != nil {
+		t.Fatalf("PreviewCommit failed: %v", err)
+	}
+	if preview.BaseCommit != initialCommit || preview.CommitCount != 1 || preview.Patches != handler.commitsResponse.Patches {
+		t.Errorf("Unexpected preview: %+v", preview)
+	}
+	if preview.Summary.FilesChanged != 1 || preview.Summary.Additions != 2 || preview.Summary.Deletions != 0 {
+		t.Errorf("Unexpected summary: %+v", preview.Summary)
+	}
+
+	// Nothing is applied and the commit status is untouched
+	if head := strings.TrimSpace(runGit(t, workspace.Path, "rev-parse", "HEAD")); head != initialCommit {
+		t.Errorf("Expected workspace to stay at %s, got %s", initialCommit, head)
+	}
+	if handler.getChatRequestCount() != 0 {
+		t.Errorf("Expected no chat requests, got %d", handler.getChatRequestCount())
+	}
+	updatedSession, err := env.store.GetSessionByID(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if updatedSession.CommitStatus != session.CommitStatus {
+		t.Errorf("Expected commit status %q, got %q", session.CommitStatus, updatedSession.CommitStatus)
+	}
+
+	// An agent with nothing committed gives an empty preview
+	handler.commitsResponse = nil
+	handler.commitsError = &sandboxapi.CommitsErrorResponse{Error: "no_commits", Message: "No commits found"}
+	handler.commitsHTTPCode = http.StatusNotFound
+	preview, err = sessionSvc.PreviewCommit(ctx, project.ID, session.ID)
+	if err != nil {
+		t.Fatalf("PreviewCommit failed: %v", err)
+	}
+	if preview.CommitCount != 0 || preview.Summary.FilesChanged != 0 {
+		t.Errorf("Expected empty preview, got %+v", preview)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"regexp"
@@ -23,11 +24,15 @@ type ProjectService struct {
 
 // Project represents a project (for API responses)
 type Project struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Slug      string    `json:"slug"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	Slug          string            `json:"slug"`
+	SandboxLabels map[string]string `json:"sandboxLabels,omitempty"`
+	Frozen        bool              `json:"frozen"`
+	SkipGitHooks  bool              `json:"skipGitHooks"`
+	SandboxImage  string            `json:"sandboxImage,omitempty"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	UpdatedAt     time.Time         `json:"updatedAt"`
 }
 
 // ProjectMember represents a project member (for API responses)
@@ -54,6 +59,10 @@ type ProjectInvitation struct {
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+// ErrReservedSandboxLabel is returned when a project sandbox label uses a
+// reserved discobot.* key.
+var ErrReservedSandboxLabel = errors.New("sandbox label key is reserved")
+
 // NewProjectService creates a new project service
 func NewProjectService(s *store.Store, p sandbox.Provider) *ProjectService {
 	return &ProjectService{
@@ -71,11 +80,15 @@ func (s *ProjectService) ListProjects(ctx context.Context, userID string) ([]Pro
 	projects := make([]Project, len(rows))
 	for i, row := range rows {
 		projects[i] = Project{
-			ID:        row.ID,
-			Name:      row.Name,
-			Slug:      row.Slug,
-			CreatedAt: row.CreatedAt,
-			UpdatedAt: row.UpdatedAt,
+			ID:            row.ID,
+			Name:          row.Name,
+			Slug:          row.Slug,
+			SandboxLabels: row.SandboxLabels,
+			Frozen:        row.Frozen,
+			SkipGitHooks:  row.SkipGitHooks,
+			SandboxImage:  row.SandboxImage,
+			CreatedAt:     row.CreatedAt,
+			UpdatedAt:     row.UpdatedAt,
 		}
 	}
 	return projects, nil
@@ -109,11 +122,15 @@ func (s *ProjectService) CreateProject(ctx context.Context, userID, name string)
 	}
 
 	return &Project{
-		ID:        project.ID,
-		Name:      project.Name,
-		Slug:      project.Slug,
-		CreatedAt: project.CreatedAt,
-		UpdatedAt: project.UpdatedAt,
+		ID:            project.ID,
+		Name:          project.Name,
+		Slug:          project.Slug,
+		SandboxLabels: project.SandboxLabels,
+		Frozen:        project.Frozen,
+		SkipGitHooks:  project.SkipGitHooks,
+		SandboxImage:  project.SandboxImage,
+		CreatedAt:     project.CreatedAt,
+		UpdatedAt:     project.UpdatedAt,
 	}, nil
 }
 
@@ -124,33 +141,70 @@ func (s *ProjectService) GetProject(ctx context.Context, projectID string) (*Pro
 		return nil, err
 	}
 	return &Project{
-		ID:        project.ID,
-		Name:      project.Name,
-		Slug:      project.Slug,
-		CreatedAt: project.CreatedAt,
-		UpdatedAt: project.UpdatedAt,
+		ID:            project.ID,
+		Name:          project.Name,
+		Slug:          project.Slug,
+		SandboxLabels: project.SandboxLabels,
+		Frozen:        project.Frozen,
+		SkipGitHooks:  project.SkipGitHooks,
+		SandboxImage:  project.SandboxImage,
+		CreatedAt:     project.CreatedAt,
+		UpdatedAt:     project.UpdatedAt,
 	}, nil
 }
 
-// UpdateProject updates a project
-func (s *ProjectService) UpdateProject(ctx context.Context, projectID, name string) (*Project, error) {
+// UpdateProject updates a project. A nil sandboxLabels map leaves the
+// project's sandbox labels unchanged; an empty map clears them. A nil name,
+// skipGitHooks or sandboxImage leaves the setting unchanged; an empty
+// sandboxImage goes back to the server's default image.
+func (s *ProjectService) UpdateProject(ctx context.Context, projectID string, name *string, sandboxLabels map[string]string, skipGitHooks *bool, sandboxImage *string) (*Project, error) {
+	for k := range sandboxLabels {
+		if k == "" || sandbox.IsReservedLabel(k) {
+			return nil, fmt.Errorf("%w: %q", ErrReservedSandboxLabel, k)
+		}
+	}
+
 	project, err := s.store.GetProjectByID(ctx, projectID)
 	if err != nil {
 		return nil, err
 	}
-	project.Name = name
+	if name != nil {
+		project.Name = *name
+	}
+	if sandboxLabels != nil {
+		project.SandboxLabels = sandboxLabels
+	}
+	if skipGitHooks != nil {
+		project.SkipGitHooks = *skipGitHooks
+	}
+	if sandboxImage != nil {
+		project.SandboxImage = *sandboxImage
+	}
 	if err := s.store.UpdateProject(ctx, project); err != nil {
 		return nil, err
 	}
 	return &Project{
-		ID:        project.ID,
-		Name:      project.Name,
-		Slug:      project.Slug,
-		CreatedAt: project.CreatedAt,
-		UpdatedAt: project.UpdatedAt,
+		ID:            project.ID,
+		Name:          project.Name,
+		Slug:          project.Slug,
+		SandboxLabels: project.SandboxLabels,
+		Frozen:        project.Frozen,
+		SkipGitHooks:  project.SkipGitHooks,
+		SandboxImage:  project.SandboxImage,
+		CreatedAt:     project.CreatedAt,
+		UpdatedAt:     project.UpdatedAt,
 	}, nil
 }
 
+// SetFrozen freezes or unfreezes a project. While frozen, the project's
+// queued jobs stay pending and mutating API requests are rejected.
+func (s *ProjectService) SetFrozen(ctx context.Context, projectID string, frozen bool) (*Project, error) {
+	if err := s.store.SetProjectFrozen(ctx, projectID, frozen); err != nil {
+		return nil, err
+	}
+	return s.GetProject(ctx, projectID)
+}
+
 // DeleteProject deletes a project and cleans up associated resources
 func (s *ProjectService) DeleteProject(ctx context.Context, projectID string) error {
 	// Delete from database first
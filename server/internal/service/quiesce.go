@@ -0,0 +1,53 @@
+package service
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrQuiesced is returned when creating or initializing a new session while
+// the server is quiesced.
+var ErrQuiesced = errors.New("server is in maintenance and not accepting new sessions")
+
+// QuiesceState records whether the server has stopped accepting new
+// sessions. Existing sessions are unaffected. It is shared by every
+// SessionService in the process (the handler's and the dispatcher's), so
+// toggling it applies to both session creation and session init jobs.
+// A nil QuiesceState is never quiesced.
+type QuiesceState struct {
+	mu    sync.RWMutex
+	since time.Time
+}
+
+// NewQuiesceState creates a QuiesceState that is accepting new sessions.
+func NewQuiesceState() *QuiesceState {
+	return &QuiesceState{}
+}
+
+// Quiesce stops the server from accepting new sessions. Quiescing an
+// already quiesced server keeps the original start time.
+func (q *QuiesceState) Quiesce() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.since.IsZero() {
+		q.since = time.Now()
+	}
+}
+
+// Unquiesce resumes accepting new sessions.
+func (q *QuiesceState) Unquiesce() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.since = time.Time{}
+}
+
+// Quiesced reports whether new sessions are being rejected, and since when.
+func (q *QuiesceState) Quiesced() (bool, time.Time) {
+	if q == nil {
+		return false, time.Time{}
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return !q.since.IsZero(), q.since
+}
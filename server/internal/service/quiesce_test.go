@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/model"
+)
+
+func TestQuiesceState(t *testing.T) {
+	var nilState *QuiesceState
+	if quiesced, _ := nilState.Quiesced(); quiesced {
+		t.Error("Expected nil state to accept new sessions")
+	}
+
+	q := NewQuiesceState()
+	q.Quiesce()
+	quiesced, since := q.Quiesced()
+	if !quiesced || since.IsZero() {
+		t.Fatalf("Expected quiesced with a start time, got %v/%v", quiesced, since)
+	}
+
+	// Quiescing again keeps the original start time
+	q.Quiesce()
+	if _, again := q.Quiesced(); !again.Equal(since) {
+		t.Errorf("Expected start time %v to be kept, got %v", since, again)
+	}
+
+	q.Unquiesce()
+	if quiesced, _ := q.Quiesced(); quiesced {
+		t.Error("Expected unquiesce to accept new sessions")
+	}
+}
+
+func TestSessionService_Quiesced(t *testing.T) {
+	ctx := context.Background()
+	testStore := setupTestStoreForPoller(t)
+
+	project := &model.Project{ID: "test-project", Name: "Test"}
+	workspace := &model.Workspace{ID: "test-ws", ProjectID: project.ID, Path: "/test", SourceType: "local"}
+	session := &model.Session{ID: "test-session", ProjectID: project.ID, WorkspaceID: workspace.ID, Status: model.SessionStatusInitializing}
+	if err := testStore.CreateProject(ctx, project); err != nil {
+		t.Fatal(err)
+	}
+	if err := testStore.CreateWorkspace(ctx, workspace); err != nil {
+		t.Fatal(err)
+	}
+	if err := testStore.CreateSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+
+	q := NewQuiesceState()
+	q.Quiesce()
+	svc := NewSessionService(testStore, nil, nil, nil, nil, nil)
+	svc.SetQuiesceState(q)
+
+	if _, err := svc.CreateSessionWithID(ctx, "new-session", project.ID, workspace.ID, "New", "", "", ""); !errors.Is(err, ErrQuiesced) {
+		t.Errorf("Expected ErrQuiesced creating a session, got %v", err)
+	}
+
+	// A session created before quiescing fails instead of being set up
+	if err := svc.Initialize(ctx, session.ID); err != nil {
+		t.Fatalf("Initialize failed: %v", err)
+	}
+	updated, err := testStore.GetSessionByID(ctx, session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status != model.SessionStatusError || updated.ErrorMessage == nil || *updated.ErrorMessage != ErrQuiesced.Error() {
+		t.Errorf("Expected session to fail with the maintenance message, got %s/%v", updated.Status, updated.ErrorMessage)
+	}
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// Package registry providers. Each project can have one credential per
+// registry type; they are set with SetRegistryCredential.
+const (
+	ProviderNPMRegistry   = "npm-registry"
+	ProviderPyPIRegistry  = "pypi-registry"
+	ProviderCargoRegistry = "cargo-registry"
+)
+
+// AuthTypeRegistry is the auth type of package registry credentials.
+const AuthTypeRegistry = "registry"
+
+// ErrInvalidRegistryCredential is returned when a registry credential is
+// missing its token or has an unusable URL or name.
+var ErrInvalidRegistryCredential = errors.New("invalid registry credential")
+
+// Default registry URLs, used when a credential doesn't set one.
+const (
+	defaultNPMRegistryURL  = "https://registry.npmjs.org/"
+	defaultPyPIRegistryURL = "https://pypi.org/simple/"
+)
+
+// Registry config delivered to the sandbox. The npm config is written by the
+// agent to a file in its non-persistent credentials directory; pip and cargo
+// are configured entirely through environment variables.
+const (
+	npmConfigEnvVar = "NPM_CONFIG_GLOBALCONFIG"
+	npmConfigFile   = "npmrc"
+)
+
+// cargoRegistryNamePattern matches names usable in CARGO_REGISTRIES_<NAME>_*.
+var cargoRegistryNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// RegistryCredential is a token for a private package registry.
+type RegistryCredential struct {
+	// URL is the registry URL: the npm registry, the pip simple index, or the
+	// cargo sparse index ("sparse+https://..."). Empty means the public registry.
+	URL      string `json:"url,omitempty"`
+	Username string `json:"username,omitempty"` // pip only; defaults to "__token__"
+	Token    string `json:"token"`
+	Scope    string `json:"scope,omitempty"` // npm only: use the registry for this @scope only
+}
+
+// isRegistryProvider reports whether provider is a package registry.
+func isRegistryProvider(provider string) bool {
+	switch provider {
+	case ProviderNPMRegistry, ProviderPyPIRegistry, ProviderCargoRegistry:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetRegistryCredential creates or updates a package registry credential.
+// For cargo, name is used as the registry name in Cargo.toml unless the
+// credential is for crates.io (no URL).
+func (s *CredentialService) SetRegistryCredential(ctx context.Context, projectID, provider, name string, data *RegistryCredential) (*CredentialInfo, error) {
+	if !isRegistryProvider(provider) {
+		return nil, ErrInvalidProvider
+	}
+	if _, err := registryEnvVars(provider, name, data); err != nil {
+		return nil, err
+	}
+
+	encrypted, err := s.encryptor.EncryptJSON(data)
+	if err != nil {
+		return nil, ErrEncryptionFailed
+	}
+
+	cred, err := s.store.GetCredentialByProvider(ctx, projectID, provider)
+	switch {
+	case err == nil:
+		cred.Name = name
+		cred.AuthType = AuthTypeRegistry
+		cred.EncryptedData = encrypted
+		cred.IsConfigured = true
+		err = s.store.UpdateCredential(ctx, cred)
+	case errors.Is(err, store.ErrNotFound):
+		cred = &model.Credential{
+			ProjectID:     projectID,
+			Provider:      provider,
+			Name:          name,
+			AuthType:      AuthTypeRegistry,
+			EncryptedData: encrypted,
+			IsConfigured:  true,
+		}
+		err = s.store.CreateCredential(ctx, cred)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info := s.toCredentialInfo(cred)
+	return &info, nil
+}
+
+// registryEnvVars returns the environment that configures the package
+// manager for a registry credential.
+func registryEnvVars(provider, name string, data *RegistryCredential) ([]CredentialEnvVar, error) {
+	if data.Token == "" {
+		return nil, fmt.Errorf("%w: token is required", ErrInvalidRegistryCredential)
+	}
+
+	switch provider {
+	case ProviderNPMRegistry:
+		u, err := parseRegistryURL(data.URL, defaultNPMRegistryURL, "https", "http")
+		if err != nil {
+			return nil, err
+		}
+		if data.Scope != "" && !strings.HasPrefix(data.Scope, "@") {
+			return nil, fmt.Errorf("%w: npm scope must start with @", ErrInvalidRegistryCredential)
+		}
+		var npmrc strings.Builder
+		switch {
+		case data.Scope != "":
+			fmt.Fprintf(&npmrc, "%s:registry=%s\n", data.Scope, u)
+		case u.String() != defaultNPMRegistryURL:
+			fmt.Fprintf(&npmrc, "registry=%s\n", u)
+		}
+		// Auth lines are keyed by the registry URL without its scheme
+		fmt.Fprintf(&npmrc, "//%s%s:_authToken=%s\n", u.Host, u.Path, data.Token)
+		return []CredentialEnvVar{{EnvVar: npmConfigEnvVar, Value: npmrc.String(), File: npmConfigFile}}, nil
+
+	case ProviderPyPIRegistry:
+		u, err := parseRegistryURL(data.URL, defaultPyPIRegistryURL, "https", "http")
+		if err != nil {
+			return nil, err
+		}
+		username := data.Username
+		if username == "" {
+			username = "__token__"
+		}
+		u.User = url.UserPassword(username, data.Token)
+		return []CredentialEnvVar{{EnvVar: "PIP_EXTRA_INDEX_URL", Value: u.String()}}, nil
+
+	case ProviderCargoRegistry:
+		if data.URL == "" {
+			return []CredentialEnvVar{{EnvVar: "CARGO_REGISTRY_TOKEN", Value: data.Token}}, nil
+		}
+		if _, err := parseRegistryURL(data.URL, "", "sparse+https", "sparse+http", "https", "http"); err != nil {
+			return nil, err
+		}
+		if !cargoRegistryNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("%w: cargo registry name %q must contain only letters, digits, - and _", ErrInvalidRegistryCredential, name)
+		}
+		prefix := "CARGO_REGISTRIES_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		return []CredentialEnvVar{
+			{EnvVar: prefix + "_INDEX", Value: data.URL},
+			{EnvVar: prefix + "_TOKEN", Value: data.Token},
+		}, nil
+	}
+
+	return nil, ErrInvalidProvider
+}
+
+// parseRegistryURL parses a registry URL, or def if raw is empty, and checks
+// that it uses one of the allowed schemes.
+func parseRegistryURL(raw, def string, schemes ...string) (*url.URL, error) {
+	if raw == "" {
+		raw = def
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("%w: invalid registry URL %q", ErrInvalidRegistryCredential, raw)
+	}
+	for _, scheme := range schemes {
+		if u.Scheme == scheme {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: registry URL must use %s", ErrInvalidRegistryCredential, strings.Join(schemes, " or "))
+}
@@ -1,12 +1,20 @@
 package service
 
 import (
+	"cmp"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -66,6 +74,16 @@ func (s *SandboxService) GetClient(ctx context.Context, sessionID string) (*Sess
 	}, nil
 }
 
+// EnsureRunning starts or reinitializes the session's sandbox if it isn't
+// running, and waits until it is ready.
+func (s *SandboxService) EnsureRunning(ctx context.Context, sessionID string) error {
+	return s.ensureSandboxReady(ctx, sessionID)
+}
+
+// sandboxRestartWait is how long ensureSandboxReady waits for a failed
+// sandbox to be restarted by the runtime before reinitializing it.
+const sandboxRestartWait = 5 * time.Second
+
 // ensureSandboxReady checks the session state from the database and ensures
 // the sandbox is ready. For states like "stopped" or "error", it triggers reconciliation.
 // For "initializing" states, it waits briefly then reconciles if still not ready.
@@ -80,6 +98,19 @@ func (s *SandboxService) ensureSandboxReady(ctx context.Context, sessionID strin
 		// Session status looks good — verify the container is actually running.
 		// This fast-path check avoids expensive reconciliation when everything is healthy.
 		sb, err := s.provider.Get(ctx, sessionID)
+		if err == nil && sb.Status == sandbox.StatusPaused {
+			// Paused outside Discobot (e.g. docker pause)
+			return s.resumeOrReconcile(ctx, sess)
+		}
+		if err == nil && sb.Status == sandbox.StatusFailed {
+			// The runtime's restart policy may already be bringing a crashed
+			// sandbox back; give it a moment before reinitializing
+			sb, err = s.provider.WaitForStatus(ctx, sessionID, sandbox.StatusRunning, sandboxRestartWait)
+			if err != nil && !errors.Is(err, sandbox.ErrNotFound) {
+				log.Printf("Session %s sandbox did not recover (%v), reconciling", sessionID, err)
+				return s.ReconcileSandbox(ctx, sessionID)
+			}
+		}
 		if errors.Is(err, sandbox.ErrNotFound) || (err == nil && sb.Status != sandbox.StatusRunning) {
 			log.Printf("Session %s status is %s but container not running, reconciling", sessionID, sess.Status)
 			return s.ReconcileSandbox(ctx, sessionID)
@@ -89,6 +120,8 @@ func (s *SandboxService) ensureSandboxReady(ctx context.Context, sessionID strin
 		}
 		// Container is running - all good
 		return nil
+	case model.SessionStatusPaused:
+		return s.resumeOrReconcile(ctx, sess)
 	case model.SessionStatusStopped, model.SessionStatusError:
 		return s.ReconcileSandbox(ctx, sessionID)
 	case model.SessionStatusInitializing, model.SessionStatusReinitializing,
@@ -103,6 +136,28 @@ func (s *SandboxService) ensureSandboxReady(ctx context.Context, sessionID strin
 	}
 }
 
+// resumeOrReconcile unpauses a paused sandbox and marks its session ready,
+// falling back to reconciliation if it can't be unpaused.
+func (s *SandboxService) resumeOrReconcile(ctx context.Context, sess *model.Session) error {
+	log.Printf("Session %s sandbox is paused, resuming", sess.ID)
+	if err := s.provider.Unpause(ctx, sess.ID); err != nil {
+		log.Printf("Session %s sandbox could not be resumed (%v), reconciling", sess.ID, err)
+		return s.ReconcileSandbox(ctx, sess.ID)
+	}
+
+	if sess.Status != model.SessionStatusReady {
+		if err := setSessionStatus(ctx, s.store, sess.ID, model.SessionStatusReady, nil); err != nil {
+			log.Printf("Warning: failed to update session status for %s: %v", sess.ID, err)
+		}
+		if s.eventBroker != nil {
+			if err := s.eventBroker.PublishSessionUpdated(ctx, sess.ProjectID, sess.ID, model.SessionStatusReady, ""); err != nil {
+				log.Printf("Warning: failed to publish session update event: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
 // waitForSessionReady polls the session status until it reaches a terminal state.
 func (s *SandboxService) waitForSessionReady(ctx context.Context, sessionID string) error {
 	const (
@@ -151,7 +206,7 @@ func (s *SandboxService) ReconcileSandbox(ctx context.Context, sessionID string)
 	projectID := sess.ProjectID
 
 	// Update status to reinitializing
-	if err := s.store.UpdateSessionStatus(ctx, sessionID, model.SessionStatusReinitializing, nil); err != nil {
+	if err := setSessionStatus(ctx, s.store, sessionID, model.SessionStatusReinitializing, nil); err != nil {
 		log.Printf("Warning: failed to update session status for %s: %v", sessionID, err)
 	}
 
@@ -243,20 +298,24 @@ func (s *SandboxService) CreateForSession(ctx context.Context, sessionID string)
 	sharedSecret := generateSandboxSecret(32)
 
 	// Create sandbox with session configuration
-	// Note: The sandbox image is configured globally on the provider via SANDBOX_IMAGE env var
+	// Note: The sandbox image is configured globally on the provider via SANDBOX_IMAGE env var,
+	// unless the project overrides it
 	opts := sandbox.CreateOptions{
 		SharedSecret: sharedSecret,
-		Labels: map[string]string{
+		Labels: s.sandboxLabels(ctx, session.ProjectID, map[string]string{
 			"discobot.session.id":   sessionID,
 			"discobot.workspace.id": session.WorkspaceID,
 			"discobot.project.id":   session.ProjectID,
-		},
-		WorkspacePath:   workspacePath,
-		WorkspaceSource: workspace.Path, // Original workspace path (local or git URL)
-		WorkspaceCommit: workspaceCommit,
-		Resources: sandbox.ResourceConfig{
-			Timeout: s.cfg.SandboxIdleTimeout,
-		},
+		}),
+		Image:            s.projectImage(ctx, session.ProjectID),
+		WorkspacePath:    workspacePath,
+		WorkspaceSource:  workspace.Path, // Original workspace path (local or git URL)
+		WorkspaceCommit:  workspaceCommit,
+		RestartPolicy:    workspace.RestartPolicy,
+		StopSignal:       workspace.StopSignal,
+		ExtraPorts:       workspace.ExtraPorts,
+		Resources:        s.resourceLimits(workspace),
+		ResourceRequests: s.resourceRequests(),
 	}
 
 	// Create the sandbox
@@ -285,13 +344,127 @@ func generateSandboxSecret(length int) string {
 	return hex.EncodeToString(bytes)
 }
 
+// resourceLimits returns the resource limits for a workspace's sandboxes.
+// Limits the workspace leaves unset fall back to the server defaults.
+func (s *SandboxService) resourceLimits(workspace *model.Workspace) sandbox.ResourceConfig {
+	limits := sandbox.ResourceConfig{
+		MemoryMB: workspace.MemoryMB,
+		CPUCores: workspace.CPUCores,
+		DiskMB:   workspace.DiskMB,
+	}
+	if s == nil || s.cfg == nil {
+		return limits
+	}
+	if limits.MemoryMB == 0 {
+		limits.MemoryMB = s.cfg.SandboxMemoryLimitMB
+	}
+	if limits.CPUCores == 0 {
+		limits.CPUCores = s.cfg.SandboxCPULimit
+	}
+	limits.Timeout = s.cfg.SandboxIdleTimeout
+	return limits
+}
+
+// resourceRequests returns the server-wide resource requests for sandboxes.
+func (s *SandboxService) resourceRequests() sandbox.ResourceRequests {
+	if s == nil || s.cfg == nil {
+		return sandbox.ResourceRequests{}
+	}
+	return sandbox.ResourceRequests{
+		MemoryMB: s.cfg.SandboxMemoryRequestMB,
+		CPUCores: s.cfg.SandboxCPURequest,
+	}
+}
+
+// sandboxLabels merges the managed discobot.* labels with the operator's
+// SANDBOX_EXTRA_LABELS and the project's sandbox label overrides.
+// Safe to call on a nil receiver, in which case only managed labels are returned.
+func (s *SandboxService) sandboxLabels(ctx context.Context, projectID string, managed map[string]string) map[string]string {
+	if s == nil {
+		return sandbox.MergeLabels(managed)
+	}
+
+	var projectLabels map[string]string
+	if project, err := s.store.GetProjectByID(ctx, projectID); err == nil {
+		projectLabels = project.SandboxLabels
+	} else {
+		log.Printf("Failed to load project %s for sandbox labels: %v", projectID, err)
+	}
+
+	var extraLabels map[string]string
+	if s.cfg != nil {
+		extraLabels = s.cfg.SandboxExtraLabels
+	}
+
+	return sandbox.MergeLabels(managed, extraLabels, projectLabels)
+}
+
+// ProjectImageAllowed reports whether a project may use image as its sandbox
+// image: images in SANDBOX_ALLOWED_IMAGES, and those committed from the
+// project's own sessions (in its discobot-local/{projectID}/ repository).
+func ProjectImageAllowed(cfg *config.Config, projectID, image string) bool {
+	if strings.HasPrefix(image, "discobot-local/"+projectID+"/") {
+		return true
+	}
+	return cfg != nil && cfg.SandboxImageAllowed(image)
+}
+
+// projectImage returns the project's sandbox image override, or "" to use
+// the provider's image. Safe to call on a nil receiver.
+func (s *SandboxService) projectImage(ctx context.Context, projectID string) string {
+	if s == nil {
+		return ""
+	}
+	project, err := s.store.GetProjectByID(ctx, projectID)
+	if err != nil {
+		log.Printf("Failed to load project %s for sandbox image: %v", projectID, err)
+		return ""
+	}
+	return s.allowedImage(project)
+}
+
+// allowedImage returns project's sandbox image override if it's still
+// allowed (see ProjectImageAllowed), or "" to use the provider's image. The
+// allowlist may have changed since the project chose it.
+func (s *SandboxService) allowedImage(project *model.Project) string {
+	if project.SandboxImage == "" {
+		return ""
+	}
+	if !ProjectImageAllowed(s.cfg, project.ID, project.SandboxImage) {
+		log.Printf("Project %s sandbox image %s is not in SANDBOX_ALLOWED_IMAGES, using the default image", project.ID, project.SandboxImage)
+		return ""
+	}
+	return project.SandboxImage
+}
+
 // GetForSession returns the sandbox state for a session.
 func (s *SandboxService) GetForSession(ctx context.Context, sessionID string) (*sandbox.Sandbox, error) {
 	return s.provider.Get(ctx, sessionID)
 }
 
-// Exec runs a non-interactive command in the session's sandbox.
+// ErrUserNotAllowed is returned when a terminal or exec request asks to run as
+// a user that isn't in SANDBOX_ALLOWED_USERS.
+var ErrUserNotAllowed = errors.New("user not allowed")
+
+// CheckUserAllowed reports whether terminal and exec requests may run as user.
+// The empty user (the sandbox's default user) is always allowed.
+func (s *SandboxService) CheckUserAllowed(user string) error {
+	if user == "" {
+		return nil
+	}
+	if s.cfg != nil && slices.Contains(s.cfg.SandboxAllowedUsers, user) {
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrUserNotAllowed, user)
+}
+
+// Exec runs a non-interactive command in the session's sandbox, starting the
+// sandbox first if needed.
 func (s *SandboxService) Exec(ctx context.Context, sessionID string, cmd []string, opts sandbox.ExecOptions) (*sandbox.ExecResult, error) {
+	if err := s.ensureSandboxReady(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	s.RecordActivity(sessionID)
 	return s.provider.Exec(ctx, sessionID, cmd, opts)
 }
 
@@ -322,14 +495,216 @@ func (s *SandboxService) DestroyForSession(ctx context.Context, sessionID string
 	return err
 }
 
+// DockerSocketPath returns the host Unix socket used to forward a session's
+// Docker daemon. The name is derived from a hash of the session ID so the
+// path stays under the ~104 byte Unix socket limit on macOS.
+func DockerSocketPath(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return filepath.Join(os.TempDir(), "discobot-docker-"+hex.EncodeToString(sum[:6])+".sock")
+}
+
+// ForwardDockerSocket exposes the Docker daemon serving the session's sandbox
+// on a host Unix socket and returns its path. Calling it again while the
+// socket is being forwarded reattaches to the existing proxy.
+// Returns sandbox.ErrNotSupported if the session's provider can't forward Docker.
+func (s *SandboxService) ForwardDockerSocket(ctx context.Context, sessionID string) (string, error) {
+	dsp, ok := s.provider.(sandbox.DockerSocketProvider)
+	if !ok {
+		return "", sandbox.ErrNotSupported
+	}
+	proxy, err := dsp.DockerSocketProxy(ctx, sessionID, DockerSocketPath(sessionID))
+	if err != nil {
+		return "", err
+	}
+	return proxy.SocketPath(), nil
+}
+
+// StopDockerSocket stops forwarding the session's Docker socket.
+func (s *SandboxService) StopDockerSocket(ctx context.Context, sessionID string) error {
+	dsp, ok := s.provider.(sandbox.DockerSocketProvider)
+	if !ok {
+		return sandbox.ErrNotSupported
+	}
+	return dsp.StopDockerSocketProxy(ctx, sessionID)
+}
+
+// FilesystemDiff returns the changes in the session's sandbox filesystem.
+// Returns sandbox.ErrNotSupported if the session's provider can't diff filesystems.
+func (s *SandboxService) FilesystemDiff(ctx context.Context, sessionID string) ([]sandbox.FileChange, error) {
+	differ, ok := s.provider.(sandbox.FilesystemDiffer)
+	if !ok {
+		return nil, sandbox.ErrNotSupported
+	}
+	return differ.FilesystemDiff(ctx, sessionID)
+}
+
+// Stats samples the resource usage of the session's sandbox.
+// Returns sandbox.ErrNotSupported if the session's provider can't report usage.
+func (s *SandboxService) Stats(ctx context.Context, sessionID string) (*sandbox.Stats, error) {
+	statsProvider, ok := s.provider.(sandbox.StatsProvider)
+	if !ok {
+		return nil, sandbox.ErrNotSupported
+	}
+	return statsProvider.Stats(ctx, sessionID)
+}
+
+// CommitImage saves the session's sandbox filesystem as imageRef.
+// Returns sandbox.ErrNotSupported if the session's provider can't commit images.
+func (s *SandboxService) CommitImage(ctx context.Context, sessionID, imageRef string) (*sandbox.CommittedImage, error) {
+	committer, ok := s.provider.(sandbox.ImageCommitter)
+	if !ok {
+		return nil, sandbox.ErrNotSupported
+	}
+	return committer.CommitImage(ctx, sessionID, imageRef)
+}
+
+// Snapshot captures the session's sandbox so it can be compared later with
+// DiffSnapshots.
+// Returns sandbox.ErrNotSupported if the session's provider can't take snapshots.
+func (s *SandboxService) Snapshot(ctx context.Context, sessionID string) (*sandbox.SnapshotInfo, error) {
+	snapshotter, ok := s.provider.(sandbox.Snapshotter)
+	if !ok {
+		return nil, sandbox.ErrNotSupported
+	}
+	id, err := snapshotter.Snapshot(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &sandbox.SnapshotInfo{ID: id, CreatedAt: time.Now().UTC()}, nil
+}
+
+// ListSnapshots returns the session's snapshots, oldest first.
+// Returns sandbox.ErrNotSupported if the session's provider can't compare snapshots.
+func (s *SandboxService) ListSnapshots(ctx context.Context, sessionID string) ([]sandbox.SnapshotInfo, error) {
+	differ, ok := s.provider.(sandbox.SnapshotDiffer)
+	if !ok {
+		return nil, sandbox.ErrNotSupported
+	}
+	return differ.ListSnapshots(ctx, sessionID)
+}
+
+// DeleteSnapshot deletes one of the session's snapshots. Returns
+// sandbox.ErrNotFound if it isn't one of the session's, and
+// ErrInvalidSessionState while a forked session is being created from it.
+func (s *SandboxService) DeleteSnapshot(ctx context.Context, sessionID, snapshotID string) error {
+	snapshots, err := s.ListSnapshots(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(snapshots, func(snap sandbox.SnapshotInfo) bool { return snap.ID == snapshotID }) {
+		return fmt.Errorf("%w: snapshot %s", sandbox.ErrNotFound, snapshotID)
+	}
+	pending, err := s.store.SnapshotPending(ctx, snapshotID)
+	if err != nil {
+		return fmt.Errorf("failed to check snapshot: %w", err)
+	}
+	if pending {
+		return fmt.Errorf("%w: a forked session is being created from snapshot %s", ErrInvalidSessionState, snapshotID)
+	}
+	snapshotter, ok := s.provider.(sandbox.Snapshotter)
+	if !ok {
+		return sandbox.ErrNotSupported
+	}
+	return snapshotter.DeleteSnapshot(ctx, snapshotID)
+}
+
+// DiffSnapshots streams a unified diff of the session's workspace from
+// snapshot fromID to snapshot toID, or to the live workspace if toID is
+// empty. The caller must close the returned reader.
+// Returns sandbox.ErrNotSupported if the session's provider can't compare snapshots.
+func (s *SandboxService) DiffSnapshots(ctx context.Context, sessionID, fromID, toID string) (io.ReadCloser, error) {
+	differ, ok := s.provider.(sandbox.SnapshotDiffer)
+	if !ok {
+		return nil, sandbox.ErrNotSupported
+	}
+	return differ.DiffSnapshots(ctx, sessionID, fromID, toID)
+}
+
+// Logs streams the output of the session's sandbox. The caller must close
+// the returned reader.
+// Returns sandbox.ErrNotSupported if the session's provider can't stream logs.
+func (s *SandboxService) Logs(ctx context.Context, sessionID string, opts sandbox.LogOptions) (io.ReadCloser, error) {
+	streamer, ok := s.provider.(sandbox.LogStreamer)
+	if !ok {
+		return nil, sandbox.ErrNotSupported
+	}
+	return streamer.Logs(ctx, sessionID, opts)
+}
+
+// CopyTo extracts a tar archive into destPath in the session's workspace,
+// starting the sandbox first if needed. destPath may be relative to the
+// workspace. Returns sandbox.ErrOutsideWorkspace if it escapes the workspace
+// and sandbox.ErrNotSupported if the session's provider can't copy files.
+func (s *SandboxService) CopyTo(ctx context.Context, sessionID, destPath string, archive io.Reader) error {
+	dest, err := sandbox.WorkspacePath(destPath)
+	if err != nil {
+		return err
+	}
+	copier, ok := s.provider.(sandbox.FileCopier)
+	if !ok {
+		return sandbox.ErrNotSupported
+	}
+	if err := s.ensureSandboxReady(ctx, sessionID); err != nil {
+		return err
+	}
+	s.RecordActivity(sessionID)
+	return copier.CopyTo(ctx, sessionID, dest, archive)
+}
+
+// CopyFrom returns a tar archive of srcPath in the session's workspace,
+// starting the sandbox first if needed. srcPath may be relative to the
+// workspace. Returns sandbox.ErrOutsideWorkspace if it escapes the workspace
+// and sandbox.ErrNotSupported if the session's provider can't copy files.
+func (s *SandboxService) CopyFrom(ctx context.Context, sessionID, srcPath string) (io.ReadCloser, error) {
+	src, err := sandbox.WorkspacePath(srcPath)
+	if err != nil {
+		return nil, err
+	}
+	copier, ok := s.provider.(sandbox.FileCopier)
+	if !ok {
+		return nil, sandbox.ErrNotSupported
+	}
+	if err := s.ensureSandboxReady(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	s.RecordActivity(sessionID)
+	return copier.CopyFrom(ctx, sessionID, src)
+}
+
+// Dial connects to port inside the session's sandbox.
+// Returns sandbox.ErrNotSupported if the session's provider can't dial ports.
+func (s *SandboxService) Dial(ctx context.Context, sessionID string, port int) (net.Conn, error) {
+	dialer, ok := s.provider.(sandbox.PortDialer)
+	if !ok {
+		return nil, sandbox.ErrNotSupported
+	}
+	return dialer.Dial(ctx, sessionID, port)
+}
+
+// ResizeVolume grows the session's data volume to sizeMB.
+// Returns sandbox.ErrNotSupported if the session's provider can't resize volumes.
+func (s *SandboxService) ResizeVolume(ctx context.Context, sessionID string, sizeMB int) error {
+	resizer, ok := s.provider.(sandbox.VolumeResizer)
+	if !ok {
+		return sandbox.ErrNotSupported
+	}
+	return resizer.ResizeVolume(ctx, sessionID, sizeMB)
+}
+
 // Provider returns the underlying provider for advanced operations.
 func (s *SandboxService) Provider() sandbox.Provider {
 	return s.provider
 }
 
-// ReconcileSandboxes checks all existing sandboxes and recreates any that
-// are using an outdated image. This should be called on server startup.
+// ReconcileSandboxes removes orphaned sandboxes, then checks all remaining
+// sandboxes and recreates any that are using an outdated image: their
+// project's image if it sets one, otherwise the provider's. This should be
+// called on server startup.
 func (s *SandboxService) ReconcileSandboxes(ctx context.Context) error {
+	if _, err := s.RemoveOrphanedSandboxes(ctx); err != nil {
+		log.Printf("Warning: Failed to remove orphaned sandboxes: %v", err)
+	}
+
 	expectedImage := s.provider.Image()
 	if expectedImage == "" {
 		log.Printf("No sandbox image configured, skipping reconciliation")
@@ -343,27 +718,37 @@ func (s *SandboxService) ReconcileSandboxes(ctx context.Context) error {
 
 	log.Printf("Reconciling %d sandboxes (expected image: %s)", len(sandboxes), expectedImage)
 
+	// Projects can override the image; look each one up once
+	projectImages := map[string]string{}
 	for _, sb := range sandboxes {
-		// Check if the sandbox uses the expected image
-		if sb.Image == expectedImage {
-			log.Printf("Sandbox for session %s uses correct image", sb.SessionID)
+		// Orphans were removed above, so a lookup failure here is transient;
+		// leave the sandbox alone rather than risk losing a live session.
+		session, err := s.store.GetSessionByID(ctx, sb.SessionID)
+		if err != nil {
+			log.Printf("Failed to get session %s, skipping image update: %v", sb.SessionID, err)
 			continue
 		}
-
-		log.Printf("Sandbox for session %s uses outdated image %s (expected %s), recreating...",
-			sb.SessionID, sb.Image, expectedImage)
-
-		// Check if the session exists; if not, remove orphaned sandbox
-		_, err := s.store.GetSessionByID(ctx, sb.SessionID)
-		if err != nil {
-			log.Printf("Failed to get session %s, removing orphaned sandbox: %v", sb.SessionID, err)
-			// Preserve volumes for orphaned sandboxes in case of recovery
-			if err := s.provider.Remove(ctx, sb.SessionID); err != nil {
-				log.Printf("Failed to remove orphaned sandbox for session %s: %v", sb.SessionID, err)
+		image, ok := projectImages[session.ProjectID]
+		if !ok {
+			project, err := s.store.GetProjectByID(ctx, session.ProjectID)
+			if err != nil {
+				log.Printf("Failed to get project %s, skipping image update: %v", session.ProjectID, err)
+				continue
 			}
+			image = cmp.Or(s.allowedImage(project), expectedImage)
+			projectImages[session.ProjectID] = image
+		}
+
+		// Check if the sandbox uses the expected image. A fork's sandbox runs
+		// the snapshot image it was created from, which holds its filesystem.
+		if sb.Image == image || (session.SnapshotImage != nil && sb.Image == *session.SnapshotImage) {
+			log.Printf("Sandbox for session %s uses correct image", sb.SessionID)
 			continue
 		}
 
+		log.Printf("Sandbox for session %s uses outdated image %s (expected %s), recreating...",
+			sb.SessionID, sb.Image, image)
+
 		// Remove the old sandbox (preserve volume for image update)
 		if err := s.provider.Remove(ctx, sb.SessionID); err != nil {
 			log.Printf("Failed to remove sandbox for session %s: %v", sb.SessionID, err)
@@ -377,7 +762,7 @@ func (s *SandboxService) ReconcileSandboxes(ctx context.Context) error {
 			continue
 		}
 
-		log.Printf("Successfully recreated sandbox for session %s with image %s", sb.SessionID, expectedImage)
+		log.Printf("Successfully recreated sandbox for session %s with image %s", sb.SessionID, image)
 	}
 
 	// After reconciliation, clean up old sandbox images that are no longer in use
@@ -393,7 +778,7 @@ func (s *SandboxService) ReconcileSandboxes(ctx context.Context) error {
 // ReconcileSessionStates checks sessions that the database considers active or
 // in-progress and verifies their sandbox state matches. If a sandbox has failed,
 // the session is marked as error. If the sandbox is stopped or doesn't exist,
-// the session is marked as stopped. For sessions marked "running", checks with
+// the session is marked as stopped, and if it is paused, as paused. For sessions marked "running", checks with
 // the agent API to verify a chat is actually in progress. This should be called
 // on server startup after ReconcileSandboxes.
 //
@@ -409,6 +794,7 @@ func (s *SandboxService) ReconcileSessionStates(ctx context.Context) error {
 	statesToReconcile := []string{
 		model.SessionStatusReady,
 		model.SessionStatusRunning,
+		model.SessionStatusPaused,
 		model.SessionStatusInitializing,
 		model.SessionStatusReinitializing,
 		model.SessionStatusCloning,
@@ -428,7 +814,7 @@ func (s *SandboxService) ReconcileSessionStates(ctx context.Context) error {
 		if errors.Is(err, sandbox.ErrNotFound) {
 			// Sandbox doesn't exist - mark as stopped, will be recreated on demand
 			log.Printf("Session %s (status: %s) has no sandbox, marking as stopped", session.ID, session.Status)
-			if err := s.store.UpdateSessionStatus(ctx, session.ID, model.SessionStatusStopped, nil); err != nil {
+			if err := setSessionStatus(ctx, s.store, session.ID, model.SessionStatusStopped, nil); err != nil {
 				log.Printf("Failed to update session %s status: %v", session.ID, err)
 			}
 			continue
@@ -442,7 +828,7 @@ func (s *SandboxService) ReconcileSessionStates(ctx context.Context) error {
 		if sb.Status == sandbox.StatusFailed {
 			log.Printf("Session %s has failed sandbox (error: %s), marking session as error", session.ID, sb.Error)
 			errMsg := fmt.Sprintf("Sandbox failed: %s", sb.Error)
-			if err := s.store.UpdateSessionStatus(ctx, session.ID, model.SessionStatusError, &errMsg); err != nil {
+			if err := setSessionStatus(ctx, s.store, session.ID, model.SessionStatusError, &errMsg); err != nil {
 				log.Printf("Failed to update session %s status: %v", session.ID, err)
 			}
 			continue
@@ -451,12 +837,23 @@ func (s *SandboxService) ReconcileSessionStates(ctx context.Context) error {
 		// Check if sandbox is stopped or just created (not running)
 		if sb.Status == sandbox.StatusStopped || sb.Status == sandbox.StatusCreated {
 			log.Printf("Session %s has %s sandbox, marking as stopped", session.ID, sb.Status)
-			if err := s.store.UpdateSessionStatus(ctx, session.ID, model.SessionStatusStopped, nil); err != nil {
+			if err := setSessionStatus(ctx, s.store, session.ID, model.SessionStatusStopped, nil); err != nil {
 				log.Printf("Failed to update session %s status: %v", session.ID, err)
 			}
 			continue
 		}
 
+		// Sandbox is paused - keep the session paused so it resumes on demand
+		if sb.Status == sandbox.StatusPaused {
+			if session.Status != model.SessionStatusPaused {
+				log.Printf("Session %s has paused sandbox, marking as paused", session.ID)
+				if err := setSessionStatus(ctx, s.store, session.ID, model.SessionStatusPaused, nil); err != nil {
+					log.Printf("Failed to update session %s status: %v", session.ID, err)
+				}
+			}
+			continue
+		}
+
 		// Sandbox exists and is running
 		if sb.Status == sandbox.StatusRunning {
 			// Special handling for "running" sessions - verify chat is actually in progress
@@ -470,7 +867,7 @@ func (s *SandboxService) ReconcileSessionStates(ctx context.Context) error {
 					// or the agent API is not responding
 					log.Printf("Failed to get chat status for session %s (assuming not running): %v", session.ID, err)
 					log.Printf("Session %s marked as running but chat status unavailable, updating to ready", session.ID)
-					if err := s.store.UpdateSessionStatus(ctx, session.ID, model.SessionStatusReady, nil); err != nil {
+					if err := setSessionStatus(ctx, s.store, session.ID, model.SessionStatusReady, nil); err != nil {
 						log.Printf("Failed to update session %s status: %v", session.ID, err)
 					}
 					continue
@@ -479,7 +876,7 @@ func (s *SandboxService) ReconcileSessionStates(ctx context.Context) error {
 				if !chatStatus.IsRunning {
 					// Chat is not actually running - reset to ready
 					log.Printf("Session %s marked as running but chat not active, updating to ready", session.ID)
-					if err := s.store.UpdateSessionStatus(ctx, session.ID, model.SessionStatusReady, nil); err != nil {
+					if err := setSessionStatus(ctx, s.store, session.ID, model.SessionStatusReady, nil); err != nil {
 						log.Printf("Failed to update session %s status: %v", session.ID, err)
 					}
 				} else {
@@ -495,7 +892,7 @@ func (s *SandboxService) ReconcileSessionStates(ctx context.Context) error {
 			// Update session status if it was in intermediate state
 			if session.Status != model.SessionStatusReady {
 				log.Printf("Session %s was in %s state but sandbox is running, updating to ready", session.ID, session.Status)
-				if err := s.store.UpdateSessionStatus(ctx, session.ID, model.SessionStatusReady, nil); err != nil {
+				if err := setSessionStatus(ctx, s.store, session.ID, model.SessionStatusReady, nil); err != nil {
 					log.Printf("Failed to update session %s status: %v", session.ID, err)
 				}
 			}
@@ -560,6 +957,25 @@ func (s *SandboxService) RecordActivity(sessionID string) {
 // Returns zero time if the session has no recorded activity.
 func (s *SandboxService) GetLastActivity(sessionID string) time.Time {
 	s.lastActivityMu.RLock()
-	defer s.lastActivityMu.RUnlock()
-	return s.lastActivityMap[sessionID]
+	last := s.lastActivityMap[sessionID]
+	s.lastActivityMu.RUnlock()
+
+	// Terminals, SSH, and the service proxy use the provider directly, and
+	// other SandboxService instances keep their own maps, so also ask the
+	// provider when it last saw the sandbox used.
+	if ar, ok := s.provider.(sandbox.ActivityReporter); ok {
+		if t := ar.LastActivity()[sessionID]; t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// ActiveExecs returns the number of commands, terminals, and SSH sessions
+// running in the session's sandbox, or 0 if the provider doesn't report them.
+func (s *SandboxService) ActiveExecs(sessionID string) int {
+	if esp, ok := s.provider.(sandbox.ExecStatsProvider); ok {
+		return esp.ActiveExecs()[sessionID]
+	}
+	return 0
 }
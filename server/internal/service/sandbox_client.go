@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"syscall"
 	"time"
@@ -853,6 +854,43 @@ func (c *SandboxChatClient) RenameFile(ctx context.Context, sessionID string, re
 	return &result, nil
 }
 
+// Health checks the sandbox agent-api's GET /health endpoint once, without retrying.
+// It returns an error if the sandbox is unreachable or reports itself unhealthy.
+func (c *SandboxChatClient) Health(ctx context.Context, sessionID string) (*sandboxapi.HealthResponse, error) {
+	client, err := c.getHTTPClient(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://sandbox/health", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if err := c.applyRequestAuth(ctx, req, sessionID, &RequestOptions{SkipCredentials: true}); err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("health check failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("sandbox returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result sandboxapi.HealthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if !result.Healthy {
+		return &result, fmt.Errorf("sandbox reports unhealthy")
+	}
+	return &result, nil
+}
+
 // GetUserInfo retrieves the default user info from the sandbox.
 // This is used to determine which user to run terminal sessions as.
 // Retries with exponential backoff on connection errors and 5xx responses.
@@ -1069,6 +1107,468 @@ func (c *SandboxChatClient) GetCommits(ctx context.Context, sessionID string, pa
 	return &result, nil
 }
 
+// ============================================================================
+// Key-Value Store Methods
+// ============================================================================
+
+// GetKV reads a value from the sandbox's key-value store.
+func (c *SandboxChatClient) GetKV(ctx context.Context, sessionID, key string) (*sandboxapi.KVEntry, error) {
+	resp, err := retryWithBackoff(ctx, func() (*http.Response, int, error) {
+		client, err := c.getHTTPClient(ctx, sessionID)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", "http://sandbox/kv/"+url.PathEscape(key), nil)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		if err := c.applyRequestAuth(ctx, req, sessionID, nil); err != nil {
+			return nil, 0, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return resp, resp.StatusCode, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get value: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("sandbox returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result sandboxapi.KVEntry
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// PutKV creates or replaces a value in the sandbox's key-value store.
+func (c *SandboxChatClient) PutKV(ctx context.Context, sessionID, key string, req *sandboxapi.PutKVRequest) (*sandboxapi.KVEntry, error) {
+	bodyBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := retryWithBackoff(ctx, func() (*http.Response, int, error) {
+		client, err := c.getHTTPClient(ctx, sessionID)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, "PUT", "http://sandbox/kv/"+url.PathEscape(key), bytes.NewReader(bodyBytes))
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create request: %w", err)
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+
+		if err := c.applyRequestAuth(ctx, httpReq, sessionID, nil); err != nil {
+			return nil, 0, err
+		}
+
+		resp, err := client.Do(httpReq)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return resp, resp.StatusCode, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to put value: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("sandbox returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result sandboxapi.KVEntry
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// DeleteKV deletes a value from the sandbox's key-value store.
+func (c *SandboxChatClient) DeleteKV(ctx context.Context, sessionID, key string) (*sandboxapi.DeleteKVResponse, error) {
+	resp, err := retryWithBackoff(ctx, func() (*http.Response, int, error) {
+		client, err := c.getHTTPClient(ctx, sessionID)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "DELETE", "http://sandbox/kv/"+url.PathEscape(key), nil)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		if err := c.applyRequestAuth(ctx, req, sessionID, nil); err != nil {
+			return nil, 0, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return resp, resp.StatusCode, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete value: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("sandbox returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result sandboxapi.DeleteKVResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// ============================================================================
+// Upload Methods
+// ============================================================================
+
+// CreateUpload starts a resumable upload in the sandbox.
+func (c *SandboxChatClient) CreateUpload(ctx context.Context, sessionID string, req *sandboxapi.CreateUploadRequest) (*sandboxapi.UploadStatus, error) {
+	bodyBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := retryWithBackoff(ctx, func() (*http.Response, int, error) {
+		client, err := c.getHTTPClient(ctx, sessionID)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", "http://sandbox/files/uploads", bytes.NewReader(bodyBytes))
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create request: %w", err)
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+
+		if err := c.applyRequestAuth(ctx, httpReq, sessionID, nil); err != nil {
+			return nil, 0, err
+		}
+
+		resp, err := client.Do(httpReq)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return resp, resp.StatusCode, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	return decodeUploadStatus(resp, "")
+}
+
+// GetUpload returns a resumable upload's progress.
+func (c *SandboxChatClient) GetUpload(ctx context.Context, sessionID, uploadID string) (*sandboxapi.UploadStatus, error) {
+	resp, err := retryWithBackoff(ctx, func() (*http.Response, int, error) {
+		client, err := c.getHTTPClient(ctx, sessionID)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", "http://sandbox/files/uploads/"+url.PathEscape(uploadID), nil)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		if err := c.applyRequestAuth(ctx, req, sessionID, nil); err != nil {
+			return nil, 0, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return resp, resp.StatusCode, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	return decodeUploadStatus(resp, uploadID)
+}
+
+// AppendUpload appends a chunk starting at offset to a resumable upload.
+// A chunk at the wrong offset fails with *UploadOffsetError, including a
+// retried chunk that was already received.
+func (c *SandboxChatClient) AppendUpload(ctx context.Context, sessionID, uploadID string, offset int64, chunk []byte) (*sandboxapi.UploadStatus, error) {
+	endpoint := fmt.Sprintf("http://sandbox/files/uploads/%s?offset=%d", url.PathEscape(uploadID), offset)
+
+	resp, err := retryWithBackoff(ctx, func() (*http.Response, int, error) {
+		client, err := c.getHTTPClient(ctx, sessionID)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, "PUT", endpoint, bytes.NewReader(chunk))
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create request: %w", err)
+		}
+		httpReq.Header.Set("Content-Type", "application/octet-stream")
+
+		if err := c.applyRequestAuth(ctx, httpReq, sessionID, nil); err != nil {
+			return nil, 0, err
+		}
+
+		resp, err := client.Do(httpReq)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return resp, resp.StatusCode, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to append upload chunk: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	return decodeUploadStatus(resp, uploadID)
+}
+
+// AbortUpload cancels a resumable upload and discards its data.
+func (c *SandboxChatClient) AbortUpload(ctx context.Context, sessionID, uploadID string) (*sandboxapi.AbortUploadResponse, error) {
+	resp, err := retryWithBackoff(ctx, func() (*http.Response, int, error) {
+		client, err := c.getHTTPClient(ctx, sessionID)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "DELETE", "http://sandbox/files/uploads/"+url.PathEscape(uploadID), nil)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		if err := c.applyRequestAuth(ctx, req, sessionID, nil); err != nil {
+			return nil, 0, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return resp, resp.StatusCode, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to abort upload: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("sandbox returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result sandboxapi.AbortUploadResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// decodeUploadStatus decodes an upload response, turning the sandbox's 409
+// offset conflict into *UploadOffsetError.
+func decodeUploadStatus(resp *http.Response, uploadID string) (*sandboxapi.UploadStatus, error) {
+	if resp.StatusCode == http.StatusConflict {
+		var conflict sandboxapi.UploadOffsetConflictResponse
+		if err := json.NewDecoder(resp.Body).Decode(&conflict); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		return nil, &UploadOffsetError{UploadID: uploadID, Offset: conflict.Offset}
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("sandbox returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result sandboxapi.UploadStatus
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// ============================================================================
+// Network Test Methods
+// ============================================================================
+
+// TestNetwork asks the sandbox to check whether it can reach a URL or host.
+func (c *SandboxChatClient) TestNetwork(ctx context.Context, sessionID string, req *sandboxapi.NetworkTestRequest) (*sandboxapi.NetworkTestResponse, error) {
+	bodyBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := retryWithBackoff(ctx, func() (*http.Response, int, error) {
+		client, err := c.getHTTPClient(ctx, sessionID)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", "http://sandbox/network/test", bytes.NewReader(bodyBytes))
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create request: %w", err)
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+
+		if err := c.applyRequestAuth(ctx, httpReq, sessionID, nil); err != nil {
+			return nil, 0, err
+		}
+
+		resp, err := client.Do(httpReq)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		return resp, resp.StatusCode, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to test network: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("sandbox returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result sandboxapi.NetworkTestResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// GetProxyStats retrieves per-domain traffic statistics from the sandbox's
+// MITM proxy. Retries with exponential backoff on connection errors and 5xx
+// responses other than 503, which the sandbox returns when the proxy isn't
+// running.
+func (c *SandboxChatClient) GetProxyStats(ctx context.Context, sessionID string) (*sandboxapi.ProxyStatsResponse, error) {
+	resp, err := retryWithBackoff(ctx, func() (*http.Response, int, error) {
+		client, err := c.getHTTPClient(ctx, sessionID)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", "http://sandbox/proxy/stats", nil)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		if err := c.applyRequestAuth(ctx, req, sessionID, nil); err != nil {
+			return nil, 0, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// 503 means the proxy isn't running, which retrying won't fix
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			return resp, 0, nil
+		}
+		return resp, resp.StatusCode, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proxy stats: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("sandbox returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result sandboxapi.ProxyStatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// GetFileDescriptors retrieves the open file descriptor counts and limits of
+// the sandbox's processes. Retries with exponential backoff on connection
+// errors and 5xx responses other than 503, which the sandbox returns when it
+// can't read /proc.
+func (c *SandboxChatClient) GetFileDescriptors(ctx context.Context, sessionID string) (*sandboxapi.FileDescriptorsResponse, error) {
+	resp, err := retryWithBackoff(ctx, func() (*http.Response, int, error) {
+		client, err := c.getHTTPClient(ctx, sessionID)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", "http://sandbox/fds", nil)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		if err := c.applyRequestAuth(ctx, req, sessionID, nil); err != nil {
+			return nil, 0, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// 503 means /proc isn't readable, which retrying won't fix
+		if resp.StatusCode == http.StatusServiceUnavailable {
+			return resp, 0, nil
+		}
+		return resp, resp.StatusCode, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file descriptor stats: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("sandbox returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result sandboxapi.FileDescriptorsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
 // ============================================================================
 // Hook Methods
 // ============================================================================
@@ -30,6 +30,10 @@ func (m *mockSandboxProvider) Image() string {
 	return "test-image"
 }
 
+func (m *mockSandboxProvider) Healthy(_ context.Context) error {
+	return nil
+}
+
 func (m *mockSandboxProvider) Create(_ context.Context, _ string, _ sandbox.CreateOptions) (*sandbox.Sandbox, error) {
 	return &sandbox.Sandbox{Status: sandbox.StatusCreated}, nil
 }
@@ -51,6 +55,14 @@ func (m *mockSandboxProvider) Stop(_ context.Context, sessionID string, _ time.D
 	return nil
 }
 
+func (m *mockSandboxProvider) Pause(_ context.Context, _ string) error {
+	return nil
+}
+
+func (m *mockSandboxProvider) Unpause(_ context.Context, _ string) error {
+	return nil
+}
+
 func (m *mockSandboxProvider) Remove(_ context.Context, _ string, _ ...sandbox.RemoveOption) error {
 	return nil
 }
@@ -93,6 +105,10 @@ func (t *testRoundTripper) RoundTrip(req *http.Request) (*http.Response, error)
 	return rec.Result(), nil
 }
 
+func (m *mockSandboxProvider) WaitForStatus(ctx context.Context, sessionID string, _ sandbox.Status, _ time.Duration) (*sandbox.Sandbox, error) {
+	return m.Get(ctx, sessionID)
+}
+
 func (m *mockSandboxProvider) Watch(_ context.Context) (<-chan sandbox.StateEvent, error) {
 	ch := make(chan sandbox.StateEvent)
 	close(ch)
@@ -467,6 +483,7 @@ type mockSandboxProviderWithTransport struct {
 
 func (m *mockSandboxProviderWithTransport) ImageExists(_ context.Context) bool { return true }
 func (m *mockSandboxProviderWithTransport) Image() string                      { return "test-image" }
+func (m *mockSandboxProviderWithTransport) Healthy(_ context.Context) error    { return nil }
 func (m *mockSandboxProviderWithTransport) Create(_ context.Context, _ string, _ sandbox.CreateOptions) (*sandbox.Sandbox, error) {
 	return &sandbox.Sandbox{Status: sandbox.StatusCreated}, nil
 }
@@ -477,6 +494,12 @@ func (m *mockSandboxProviderWithTransport) Start(_ context.Context, _ string) er
 func (m *mockSandboxProviderWithTransport) Stop(_ context.Context, _ string, _ time.Duration) error {
 	return nil
 }
+func (m *mockSandboxProviderWithTransport) Pause(_ context.Context, _ string) error {
+	return nil
+}
+func (m *mockSandboxProviderWithTransport) Unpause(_ context.Context, _ string) error {
+	return nil
+}
 func (m *mockSandboxProviderWithTransport) Remove(_ context.Context, _ string, _ ...sandbox.RemoveOption) error {
 	return nil
 }
@@ -498,6 +521,10 @@ func (m *mockSandboxProviderWithTransport) GetSecret(_ context.Context, _ string
 func (m *mockSandboxProviderWithTransport) HTTPClient(_ context.Context, _ string) (*http.Client, error) {
 	return &http.Client{Transport: m.transport}, nil
 }
+func (m *mockSandboxProviderWithTransport) WaitForStatus(ctx context.Context, sessionID string, _ sandbox.Status, _ time.Duration) (*sandbox.Sandbox, error) {
+	return m.Get(ctx, sessionID)
+}
+
 func (m *mockSandboxProviderWithTransport) Watch(_ context.Context) (<-chan sandbox.StateEvent, error) {
 	ch := make(chan sandbox.StateEvent)
 	close(ch)
@@ -160,6 +160,15 @@ func (m *SandboxIdleMonitor) checkIdleSessions(ctx context.Context) error {
 func (m *SandboxIdleMonitor) shouldStopSession(ctx context.Context, session *model.Session, lastActivity time.Time) bool {
 	logger := m.logger.With("session_id", session.ID, "project_id", session.ProjectID)
 
+	// An open terminal or SSH session may sit quietly for a long time, so
+	// don't stop the sandbox out from under it
+	if n := m.sandboxSvc.ActiveExecs(session.ID); n > 0 {
+		logger.Debug("session idle but commands still running, skipping stop",
+			"active_execs", n,
+			"idle_duration", time.Since(lastActivity))
+		return false
+	}
+
 	// Check if completion is currently running - don't stop if so
 	if session.Status == model.SessionStatusRunning {
 		client, err := m.sandboxSvc.GetClient(ctx, session.ID)
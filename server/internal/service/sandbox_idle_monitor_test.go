@@ -570,3 +570,79 @@ func TestSandboxIdleMonitor_MultipleIdleSessions(t *testing.T) {
 		}
 	}
 }
+
+// activityReportingProvider is a mockSandboxProvider that also reports
+// provider-level activity and running commands.
+type activityReportingProvider struct {
+	*mockSandboxProvider
+	activity map[string]time.Time
+	execs    map[string]int
+}
+
+func (p *activityReportingProvider) LastActivity() map[string]time.Time { return p.activity }
+func (p *activityReportingProvider) ActiveExecs() map[string]int        { return p.execs }
+
+// TestSandboxIdleMonitor_ProviderActivity verifies that activity reported by
+// the provider (terminals, SSH, service proxy) keeps a session running.
+func TestSandboxIdleMonitor_ProviderActivity(t *testing.T) {
+	tests := []struct {
+		name     string
+		activity map[string]time.Time
+		execs    map[string]int
+		wantStop bool
+	}{
+		{name: "no provider activity", wantStop: true},
+		{name: "recent provider activity", activity: map[string]time.Time{"test-session": time.Now()}},
+		{name: "stale provider activity", activity: map[string]time.Time{"test-session": time.Now().Add(-time.Minute)}, wantStop: true},
+		{name: "open terminal", execs: map[string]int{"test-session": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			testStore := setupTestStoreForIdleMonitor(t)
+
+			var stopCalled atomic.Bool
+			provider := &activityReportingProvider{
+				mockSandboxProvider: &mockSandboxProvider{
+					secret:  "test-secret",
+					handler: http.NotFoundHandler(),
+					onStop:  func(string) { stopCalled.Store(true) },
+				},
+				activity: tt.activity,
+				execs:    tt.execs,
+			}
+
+			sandboxSvc := NewSandboxService(testStore, provider, &config.Config{}, nil, nil, nil)
+			sessionSvc := NewSessionService(testStore, nil, provider, sandboxSvc, nil, nil)
+			monitor := NewSandboxIdleMonitor(testStore, sandboxSvc, sessionSvc, slog.Default(), time.Second, 100*time.Millisecond)
+
+			project := &model.Project{ID: "test-project", Name: "Test"}
+			if err := testStore.CreateProject(ctx, project); err != nil {
+				t.Fatal(err)
+			}
+			workspace := &model.Workspace{ID: "test-ws", ProjectID: project.ID, Path: "/test", SourceType: "local"}
+			if err := testStore.CreateWorkspace(ctx, workspace); err != nil {
+				t.Fatal(err)
+			}
+			session := &model.Session{
+				ID:          "test-session",
+				ProjectID:   project.ID,
+				WorkspaceID: workspace.ID,
+				Status:      model.SessionStatusReady,
+				UpdatedAt:   time.Now().Add(-2 * time.Second),
+			}
+			if err := testStore.CreateSession(ctx, session); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := monitor.checkIdleSessions(ctx); err != nil {
+				t.Fatalf("checkIdleSessions failed: %v", err)
+			}
+
+			if stopCalled.Load() != tt.wantStop {
+				t.Errorf("stopped = %v, want %v", stopCalled.Load(), tt.wantStop)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// OrphanSandboxStats reports orphaned sandbox cleanup counters since server start.
+type OrphanSandboxStats struct {
+	Found     int64      `json:"found"`      // Sandboxes found with no session record
+	Removed   int64      `json:"removed"`    // Orphans successfully removed
+	LastCheck *time.Time `json:"last_check"` // When the last orphan check ran (nil if never)
+}
+
+// orphanStats is process-wide because the handler and dispatcher each own a
+// SandboxService instance.
+var orphanStats struct {
+	found     atomic.Int64
+	removed   atomic.Int64
+	lastCheck atomic.Int64 // Unix nanoseconds, 0 if never run
+}
+
+// GetOrphanSandboxStats returns the orphaned sandbox cleanup counters.
+func GetOrphanSandboxStats() OrphanSandboxStats {
+	stats := OrphanSandboxStats{
+		Found:   orphanStats.found.Load(),
+		Removed: orphanStats.removed.Load(),
+	}
+	if ns := orphanStats.lastCheck.Load(); ns != 0 {
+		t := time.Unix(0, ns)
+		stats.LastCheck = &t
+	}
+	return stats
+}
+
+// RemoveOrphanedSandboxes removes managed sandboxes (and their volumes) whose
+// session no longer exists, e.g. when a session was deleted but removing its
+// container failed. A sandbox is only removed if the store definitively
+// reports the session as not found; lookup errors are logged and skipped so a
+// transient database problem never destroys a live session's sandbox.
+// Returns the number of sandboxes removed.
+func (s *SandboxService) RemoveOrphanedSandboxes(ctx context.Context) (int, error) {
+	sandboxes, err := s.provider.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sandboxes: %w", err)
+	}
+	orphanStats.lastCheck.Store(time.Now().UnixNano())
+
+	removed := 0
+	for _, sb := range sandboxes {
+		_, err := s.store.GetSessionByID(ctx, sb.SessionID)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Failed to look up session %s for sandbox %s, skipping orphan check: %v", sb.SessionID, sb.ID, err)
+			continue
+		}
+
+		orphanStats.found.Add(1)
+		log.Printf("Removing orphaned sandbox %s (session %s no longer exists)", sb.ID, sb.SessionID)
+		if err := s.provider.Remove(ctx, sb.SessionID, sandbox.RemoveVolumes()); err != nil {
+			log.Printf("Failed to remove orphaned sandbox %s for session %s: %v", sb.ID, sb.SessionID, err)
+			continue
+		}
+		orphanStats.removed.Add(1)
+		removed++
+	}
+
+	if removed > 0 {
+		log.Printf("Removed %d orphaned sandboxes", removed)
+	}
+	return removed, nil
+}
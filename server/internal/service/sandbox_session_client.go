@@ -160,6 +160,76 @@ func (c *SessionClient) RenameFile(ctx context.Context, req *sandboxapi.RenameFi
 	})
 }
 
+// CreateUpload starts a resumable upload in the sandbox.
+func (c *SessionClient) CreateUpload(ctx context.Context, req *sandboxapi.CreateUploadRequest) (*sandboxapi.UploadStatus, error) {
+	return withReconciliation(ctx, c, func() (*sandboxapi.UploadStatus, error) {
+		return c.inner.CreateUpload(ctx, c.sessionID, req)
+	})
+}
+
+// GetUpload returns a resumable upload's progress.
+func (c *SessionClient) GetUpload(ctx context.Context, uploadID string) (*sandboxapi.UploadStatus, error) {
+	return withReconciliation(ctx, c, func() (*sandboxapi.UploadStatus, error) {
+		return c.inner.GetUpload(ctx, c.sessionID, uploadID)
+	})
+}
+
+// AppendUpload appends a chunk to a resumable upload.
+func (c *SessionClient) AppendUpload(ctx context.Context, uploadID string, offset int64, chunk []byte) (*sandboxapi.UploadStatus, error) {
+	return withReconciliation(ctx, c, func() (*sandboxapi.UploadStatus, error) {
+		return c.inner.AppendUpload(ctx, c.sessionID, uploadID, offset, chunk)
+	})
+}
+
+// AbortUpload cancels a resumable upload.
+func (c *SessionClient) AbortUpload(ctx context.Context, uploadID string) (*sandboxapi.AbortUploadResponse, error) {
+	return withReconciliation(ctx, c, func() (*sandboxapi.AbortUploadResponse, error) {
+		return c.inner.AbortUpload(ctx, c.sessionID, uploadID)
+	})
+}
+
+// GetKV reads a value from the sandbox's key-value store.
+func (c *SessionClient) GetKV(ctx context.Context, key string) (*sandboxapi.KVEntry, error) {
+	return withReconciliation(ctx, c, func() (*sandboxapi.KVEntry, error) {
+		return c.inner.GetKV(ctx, c.sessionID, key)
+	})
+}
+
+// PutKV creates or replaces a value in the sandbox's key-value store.
+func (c *SessionClient) PutKV(ctx context.Context, key string, req *sandboxapi.PutKVRequest) (*sandboxapi.KVEntry, error) {
+	return withReconciliation(ctx, c, func() (*sandboxapi.KVEntry, error) {
+		return c.inner.PutKV(ctx, c.sessionID, key, req)
+	})
+}
+
+// DeleteKV deletes a value from the sandbox's key-value store.
+func (c *SessionClient) DeleteKV(ctx context.Context, key string) (*sandboxapi.DeleteKVResponse, error) {
+	return withReconciliation(ctx, c, func() (*sandboxapi.DeleteKVResponse, error) {
+		return c.inner.DeleteKV(ctx, c.sessionID, key)
+	})
+}
+
+// TestNetwork checks whether the sandbox can reach a URL or host.
+func (c *SessionClient) TestNetwork(ctx context.Context, req *sandboxapi.NetworkTestRequest) (*sandboxapi.NetworkTestResponse, error) {
+	return withReconciliation(ctx, c, func() (*sandboxapi.NetworkTestResponse, error) {
+		return c.inner.TestNetwork(ctx, c.sessionID, req)
+	})
+}
+
+// GetProxyStats retrieves traffic statistics from the sandbox's proxy.
+func (c *SessionClient) GetProxyStats(ctx context.Context) (*sandboxapi.ProxyStatsResponse, error) {
+	return withReconciliation(ctx, c, func() (*sandboxapi.ProxyStatsResponse, error) {
+		return c.inner.GetProxyStats(ctx, c.sessionID)
+	})
+}
+
+// GetFileDescriptors retrieves the open file descriptors of the sandbox's processes.
+func (c *SessionClient) GetFileDescriptors(ctx context.Context) (*sandboxapi.FileDescriptorsResponse, error) {
+	return withReconciliation(ctx, c, func() (*sandboxapi.FileDescriptorsResponse, error) {
+		return c.inner.GetFileDescriptors(ctx, c.sessionID)
+	})
+}
+
 // GetDiff retrieves diff information from the sandbox.
 func (c *SessionClient) GetDiff(ctx context.Context, path, format string) (any, error) {
 	return withReconciliation(ctx, c, func() (any, error) {
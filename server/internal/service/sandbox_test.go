@@ -345,6 +345,74 @@ func TestSandboxService_DestroyForSession(t *testing.T) {
 	}
 }
 
+func TestSandboxService_RemoveOrphanedSandboxes(t *testing.T) {
+	mockProvider := mock.NewProvider()
+	testStore := setupTestStore(t)
+	svc := NewSandboxService(testStore, mockProvider, &config.Config{}, nil, nil, nil)
+	ctx := context.Background()
+
+	// A sandbox with a session record, and one whose session no longer exists
+	createTestSession(t, testStore, "live-session", "/workspace")
+	if err := svc.CreateForSession(ctx, "live-session"); err != nil {
+		t.Fatalf("CreateForSession failed: %v", err)
+	}
+	if _, err := mockProvider.Create(ctx, "deleted-session", sandbox.CreateOptions{SharedSecret: "secret"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	before := GetOrphanSandboxStats()
+	removed, err := svc.RemoveOrphanedSandboxes(ctx)
+	if err != nil {
+		t.Fatalf("RemoveOrphanedSandboxes failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 orphan removed, got %d", removed)
+	}
+	if _, err := mockProvider.Get(ctx, "deleted-session"); err != sandbox.ErrNotFound {
+		t.Errorf("Expected orphaned sandbox to be removed, got %v", err)
+	}
+	if _, err := mockProvider.GetSecret(ctx, "deleted-session"); err == nil {
+		t.Error("Expected orphaned sandbox volumes/secrets to be removed")
+	}
+	if _, err := mockProvider.Get(ctx, "live-session"); err != nil {
+		t.Errorf("Expected live sandbox to be kept, got %v", err)
+	}
+
+	after := GetOrphanSandboxStats()
+	if after.Found-before.Found != 1 || after.Removed-before.Removed != 1 || after.LastCheck == nil {
+		t.Errorf("Unexpected orphan stats: before %+v, after %+v", before, after)
+	}
+}
+
+func TestSandboxService_RemoveOrphanedSandboxes_SkipsOnLookupError(t *testing.T) {
+	mockProvider := mock.NewProvider()
+	testStore := setupTestStore(t)
+	svc := NewSandboxService(testStore, mockProvider, &config.Config{}, nil, nil, nil)
+	ctx := context.Background()
+
+	if _, err := mockProvider.Create(ctx, "session-1", sandbox.CreateOptions{}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	// Simulate a database outage: lookups fail with an error other than not found
+	sqlDB, err := testStore.DB().DB()
+	if err != nil {
+		t.Fatalf("failed to get sql.DB: %v", err)
+	}
+	_ = sqlDB.Close()
+
+	removed, err := svc.RemoveOrphanedSandboxes(ctx)
+	if err != nil {
+		t.Fatalf("RemoveOrphanedSandboxes failed: %v", err)
+	}
+	if removed != 0 {
+		t.Errorf("Expected no sandboxes removed on lookup error, got %d", removed)
+	}
+	if _, err := mockProvider.Get(ctx, "session-1"); err != nil {
+		t.Errorf("Expected sandbox to be kept, got %v", err)
+	}
+}
+
 func TestSandboxService_DestroyForSession_NotFound(t *testing.T) {
 	mockProvider := mock.NewProvider()
 	testStore := setupTestStore(t)
@@ -55,6 +55,20 @@ func (w *SandboxWatcher) Start(ctx context.Context) error {
 	}
 }
 
+// isInitializingStatus reports whether a session status means the session
+// is still being set up by initialization.
+func isInitializingStatus(status string) bool {
+	switch status {
+	case model.SessionStatusInitializing,
+		model.SessionStatusReinitializing,
+		model.SessionStatusCloning,
+		model.SessionStatusPullingImage,
+		model.SessionStatusCreatingSandbox:
+		return true
+	}
+	return false
+}
+
 // handleEvent processes a sandbox state change event.
 func (w *SandboxWatcher) handleEvent(ctx context.Context, event sandbox.StateEvent) {
 	// Get the session to check if it exists and get its project ID
@@ -72,20 +86,37 @@ func (w *SandboxWatcher) handleEvent(ctx context.Context, event sandbox.StateEve
 
 	switch event.Status {
 	case sandbox.StatusRunning:
-		// Sandbox is running - session should be ready
-		if session.Status != model.SessionStatusReady {
+		// Sandbox is running - session should be ready. While the session is
+		// being initialized, the container starting doesn't mean the agent API
+		// is listening yet; initialization marks it ready once the startup
+		// probe passes.
+		if session.Status != model.SessionStatusReady && !isInitializingStatus(session.Status) {
 			newStatus = model.SessionStatusReady
 		}
 
+	case sandbox.StatusPaused:
+		// Sandbox paused (via the API or externally) - it resumes on demand
+		if session.Status == model.SessionStatusReady ||
+			session.Status == model.SessionStatusRunning {
+			newStatus = model.SessionStatusPaused
+		}
+
 	case sandbox.StatusStopped:
 		// Sandbox stopped - update session if it was running or in a transitional state
 		if session.Status == model.SessionStatusReady ||
+			session.Status == model.SessionStatusPaused ||
 			session.Status == model.SessionStatusInitializing ||
 			session.Status == model.SessionStatusCreatingSandbox {
 			newStatus = model.SessionStatusStopped
 		}
 
 	case sandbox.StatusFailed:
+		// The runtime's restart policy is bringing the sandbox back; the
+		// following start event keeps the session ready, so don't flap it.
+		if event.Restarting {
+			log.Printf("SandboxWatcher: sandbox for session %s exited, runtime is restarting it: %s", event.SessionID, event.Error)
+			return
+		}
 		// Sandbox failed - mark session as error
 		if session.Status != model.SessionStatusError {
 			newStatus = model.SessionStatusError
@@ -118,7 +149,7 @@ func (w *SandboxWatcher) handleEvent(ctx context.Context, event sandbox.StateEve
 	if newStatus != "" {
 		log.Printf("SandboxWatcher: updating session %s status from %s to %s", event.SessionID, session.Status, newStatus)
 
-		if err := w.store.UpdateSessionStatus(ctx, event.SessionID, newStatus, errMsg); err != nil {
+		if err := setSessionStatus(ctx, w.store, event.SessionID, newStatus, errMsg); err != nil {
 			log.Printf("SandboxWatcher: failed to update session %s status: %v", event.SessionID, err)
 			return
 		}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/store"
+)
+
+// newWatcherTestSession creates a store holding one session with the given status.
+func newWatcherTestSession(t *testing.T, status string) (*store.Store, *model.Session) {
+	t.Helper()
+	ctx := context.Background()
+	testStore := setupTestStoreForPoller(t)
+
+	project := &model.Project{ID: "test-project", Name: "Test"}
+	workspace := &model.Workspace{ID: "test-ws", ProjectID: project.ID, Path: "/test", SourceType: "local"}
+	session := &model.Session{
+		ID:          "test-session",
+		ProjectID:   project.ID,
+		WorkspaceID: workspace.ID,
+		Status:      status,
+	}
+	if err := testStore.CreateProject(ctx, project); err != nil {
+		t.Fatal(err)
+	}
+	if err := testStore.CreateWorkspace(ctx, workspace); err != nil {
+		t.Fatal(err)
+	}
+	if err := testStore.CreateSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	return testStore, session
+}
+
+func TestSandboxWatcher_HandleFailedEvent(t *testing.T) {
+	tests := []struct {
+		name       string
+		restarting bool
+		wantStatus string
+	}{
+		{name: "restarting keeps session ready", restarting: true, wantStatus: model.SessionStatusReady},
+		{name: "not restarting marks session error", restarting: false, wantStatus: model.SessionStatusError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			testStore, session := newWatcherTestSession(t, model.SessionStatusReady)
+
+			watcher := NewSandboxWatcher(nil, testStore, nil)
+			watcher.handleEvent(ctx, sandbox.StateEvent{
+				SessionID:  session.ID,
+				Status:     sandbox.StatusFailed,
+				Error:      "container died with exit code 137",
+				Restarting: tt.restarting,
+			})
+
+			got, err := testStore.GetSessionByID(ctx, session.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("expected session status %s, got %s", tt.wantStatus, got.Status)
+			}
+		})
+	}
+}
+
+func TestSandboxWatcher_HandleRunningEvent(t *testing.T) {
+	tests := []struct {
+		status     string
+		wantStatus string
+	}{
+		// Initialization marks the session ready once the startup probe passes
+		{status: model.SessionStatusCreatingSandbox, wantStatus: model.SessionStatusCreatingSandbox},
+		{status: model.SessionStatusReinitializing, wantStatus: model.SessionStatusReinitializing},
+		{status: model.SessionStatusStopped, wantStatus: model.SessionStatusReady},
+		{status: model.SessionStatusError, wantStatus: model.SessionStatusReady},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			ctx := context.Background()
+			testStore, session := newWatcherTestSession(t, tt.status)
+
+			watcher := NewSandboxWatcher(nil, testStore, nil)
+			watcher.handleEvent(ctx, sandbox.StateEvent{SessionID: session.ID, Status: sandbox.StatusRunning})
+
+			got, err := testStore.GetSessionByID(ctx, session.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("expected session status %s, got %s", tt.wantStatus, got.Status)
+			}
+		})
+	}
+}
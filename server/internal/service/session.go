@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/obot-platform/discobot/server/internal/events"
@@ -62,6 +63,46 @@ type Session struct {
 	Reasoning       string     `json:"reasoning,omitempty"`
 	WorkspacePath   string     `json:"workspacePath,omitempty"`
 	WorkspaceCommit string     `json:"workspaceCommit,omitempty"`
+
+	HistoryTrimmedThrough string `json:"historyTrimmedThrough,omitempty"`
+	HistoryTrimmedCount   int    `json:"historyTrimmedCount,omitempty"`
+
+	// ForkedFrom is the session this one was forked from
+	ForkedFrom string `json:"forkedFrom,omitempty"`
+	// snapshotID is the sandbox snapshot a forked session's first sandbox
+	// is created from
+	snapshotID string
+
+	// Ports are the workspace's extra sandbox ports (only set by GetSession)
+	Ports []SessionPort `json:"ports,omitempty"`
+}
+
+// SessionPort is an extra port exposed by a session's sandbox.
+type SessionPort struct {
+	Port int `json:"port"` // Port inside the sandbox
+	// HostPort is the published host port, if the sandbox is running and the
+	// port is reachable from the host (not inside a VM, see SandboxService.Dial)
+	HostPort int `json:"hostPort,omitempty"`
+}
+
+// SessionStatus is a lightweight view of a session's lifecycle state.
+type SessionStatus struct {
+	ID            string    `json:"id"`
+	Status        string    `json:"status"`
+	CommitStatus  string    `json:"commitStatus,omitempty"`
+	CommitError   string    `json:"commitError,omitempty"`
+	ErrorMessage  string    `json:"errorMessage,omitempty"`
+	SandboxStatus string    `json:"sandboxStatus,omitempty"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// CommitPreview is the result of a dry-run commit: the agent's patches and
+// what applying them would change.
+type CommitPreview struct {
+	BaseCommit  string           `json:"baseCommit"` // Workspace commit the patches would apply to
+	CommitCount int              `json:"commitCount"`
+	Patches     string           `json:"patches"` // Git format-patch output (mbox format)
+	Summary     git.PatchSummary `json:"summary"`
 }
 
 // FileNode represents a file in a session
@@ -75,6 +116,56 @@ type FileNode struct {
 	Changed         bool       `json:"changed,omitempty"`
 }
 
+var (
+	// ErrSessionRemoving is returned when committing a session that is being deleted.
+	ErrSessionRemoving = errors.New("session is being deleted")
+	// ErrCommitInProgress is returned when deleting a session with a commit in progress.
+	ErrCommitInProgress = errors.New("session has a commit in progress")
+	// ErrInvalidSessionState is returned when pausing a session that isn't
+	// ready, resuming one that isn't paused, or resetting one that is busy.
+	ErrInvalidSessionState = errors.New("invalid session state")
+	// ErrUnknownBulkDeleteFilter is returned for a bulk delete filter that
+	// isn't one of the BulkDeleteFilter constants.
+	ErrUnknownBulkDeleteFilter = errors.New("unknown bulk delete filter")
+)
+
+// Bulk delete filters select the sessions of a project to delete.
+const (
+	BulkDeleteStopped   = "stopped"   // Sessions whose sandbox is stopped
+	BulkDeleteError     = "error"     // Sessions whose setup failed
+	BulkDeleteCompleted = "completed" // Sessions whose changes were committed
+)
+
+// bulkDeleteFilters maps bulk delete filters to the sessions they select.
+var bulkDeleteFilters = map[string]store.SessionListOptions{
+	BulkDeleteStopped:   {Statuses: []string{model.SessionStatusStopped}},
+	BulkDeleteError:     {Statuses: []string{model.SessionStatusError}},
+	BulkDeleteCompleted: {CommitStatuses: []string{model.CommitStatusCompleted}},
+}
+
+// BulkDeleteResult reports what BulkDeleteSessions did with each session.
+type BulkDeleteResult struct {
+	Accepted         []string `json:"accepted"`         // Marked removing, with a delete job enqueued
+	AlreadyRemoving  []string `json:"alreadyRemoving"`  // Already being deleted
+	CommitInProgress []string `json:"commitInProgress"` // Held by a commit; retry once it finishes
+	NotFound         []string `json:"notFound"`         // Not in the project
+	Failed           []string `json:"failed"`           // Couldn't be marked removing
+}
+
+// homeResetMarker is the file in the sandbox that tells the agent to discard
+// the session's home directory changes when it next boots (see
+// agent/cmd/agent/reset.go).
+const homeResetMarker = "/.data/.reset-home"
+
+// commitLockTimeout is how long a pending or committing commit blocks
+// deletion of its session without making progress. It is longer than the
+// dispatcher's default job timeout, so the commit job has given up by then.
+const commitLockTimeout = 10 * time.Minute
+
+// sandboxStartWait bounds how long session init waits for a started sandbox
+// to report running.
+const sandboxStartWait = time.Minute
+
 // SessionService handles session operations
 type SessionService struct {
 	store           *store.Store
@@ -83,6 +174,10 @@ type SessionService struct {
 	sandboxService  *SandboxService
 	eventBroker     *events.Broker
 	jobEnqueuer     JobEnqueuer
+	startupProbe    *StartupProbe
+	quiesce         *QuiesceState
+	nameTemplate    string
+	agentTypes      []AgentType
 }
 
 // NewSessionService creates a new session service
@@ -97,9 +192,27 @@ func NewSessionService(s *store.Store, gitSvc *GitService, sandboxProv sandbox.P
 	}
 }
 
-// ListSessionsByWorkspace returns all sessions for a workspace.
-func (s *SessionService) ListSessionsByWorkspace(ctx context.Context, workspaceID string) ([]*Session, error) {
-	dbSessions, err := s.store.ListSessionsByWorkspace(ctx, workspaceID)
+// SetStartupProbe sets the probe a started sandbox must pass before its
+// session is marked ready. A nil probe marks sessions ready immediately.
+func (s *SessionService) SetStartupProbe(probe *StartupProbe) {
+	s.startupProbe = probe
+}
+
+// SetAgentTypes sets the agent types whose default environment is added to
+// the sandboxes of sessions using them.
+func (s *SessionService) SetAgentTypes(agentTypes []AgentType) {
+	s.agentTypes = agentTypes
+}
+
+// SetQuiesceState sets the state that decides whether new sessions are
+// accepted. Without one, they always are.
+func (s *SessionService) SetQuiesceState(q *QuiesceState) {
+	s.quiesce = q
+}
+
+// ListSessionsByWorkspace returns the sessions for a workspace matching opts.
+func (s *SessionService) ListSessionsByWorkspace(ctx context.Context, workspaceID string, opts store.SessionListOptions) ([]*Session, error) {
+	dbSessions, err := s.store.ListSessionsByWorkspace(ctx, workspaceID, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list sessions: %w", err)
 	}
@@ -118,12 +231,83 @@ func (s *SessionService) GetSession(ctx context.Context, sessionID string) (*Ses
 		return nil, fmt.Errorf("failed to get session: %w", err)
 	}
 
-	return s.mapSession(sess), nil
+	result := s.mapSession(sess)
+	result.Ports = s.sessionPorts(ctx, sess)
+	return result, nil
+}
+
+// sessionPorts returns the extra ports configured on the session's workspace,
+// with the host ports the sandbox published them on, if any.
+func (s *SessionService) sessionPorts(ctx context.Context, sess *model.Session) []SessionPort {
+	workspace, err := s.store.GetWorkspaceByID(ctx, sess.WorkspaceID)
+	if err != nil || len(workspace.ExtraPorts) == 0 {
+		return nil
+	}
+
+	hostPorts := make(map[int]int)
+	if s.sandboxProvider != nil {
+		if sb, err := s.sandboxProvider.Get(ctx, sess.ID); err == nil && sb.Status == sandbox.StatusRunning {
+			for _, p := range sb.Ports {
+				if p.Protocol == "tcp" {
+					hostPorts[p.ContainerPort] = p.HostPort
+				}
+			}
+		}
+	}
+
+	ports := make([]SessionPort, len(workspace.ExtraPorts))
+	for i, port := range workspace.ExtraPorts {
+		ports[i] = SessionPort{Port: port, HostPort: hostPorts[port]}
+	}
+	return ports
+}
+
+// GetSessionStatuses returns the statuses of the given sessions in a project.
+// Sessions that don't exist or belong to another project are omitted.
+// If includeSandbox is set, the live sandbox state is queried from the provider.
+func (s *SessionService) GetSessionStatuses(ctx context.Context, projectID string, sessionIDs []string, includeSandbox bool) ([]SessionStatus, error) {
+	sessions, err := s.store.ListSessionsByIDs(ctx, projectID, sessionIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	statuses := make([]SessionStatus, 0, len(sessions))
+	for _, sess := range sessions {
+		st := SessionStatus{
+			ID:           sess.ID,
+			Status:       sess.Status,
+			CommitStatus: sess.CommitStatus,
+			UpdatedAt:    sess.UpdatedAt,
+		}
+		if sess.CommitError != nil {
+			st.CommitError = *sess.CommitError
+		}
+		if sess.ErrorMessage != nil {
+			st.ErrorMessage = *sess.ErrorMessage
+		}
+		if includeSandbox && s.sandboxProvider != nil {
+			if sb, err := s.sandboxProvider.Get(ctx, sess.ID); err == nil {
+				st.SandboxStatus = string(sb.Status)
+			} else if errors.Is(err, sandbox.ErrNotFound) {
+				st.SandboxStatus = "not_found"
+			}
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
 }
 
 // CreateSession creates a new session with initializing status and auto-generated ID.
 // If initialMessage is provided, it creates the first user message in the session.
+// An empty name is generated from the name template (see SetNameTemplate).
 func (s *SessionService) CreateSession(ctx context.Context, projectID, workspaceID, name, agentID, initialMessage string) (*Session, error) {
+	if quiesced, _ := s.quiesce.Quiesced(); quiesced {
+		return nil, ErrQuiesced
+	}
+	if name == "" {
+		name = s.generateName(ctx, workspaceID, initialMessage)
+	}
+
 	var aidPtr *string
 	if agentID != "" {
 		aidPtr = &agentID
@@ -140,6 +324,7 @@ func (s *SessionService) CreateSession(ctx context.Context, projectID, workspace
 	if err := s.store.CreateSession(ctx, sess); err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
+	s.publishCreated(ctx, sess)
 
 	// Create the initial user message if provided
 	if initialMessage != "" {
@@ -158,7 +343,12 @@ func (s *SessionService) CreateSession(ctx context.Context, projectID, workspace
 }
 
 // CreateSessionWithID creates a new session with the provided client ID.
+// Callers name it themselves; see generateName.
 func (s *SessionService) CreateSessionWithID(ctx context.Context, sessionID, projectID, workspaceID, name, agentID, modelID, reasoning string) (*Session, error) {
+	if quiesced, _ := s.quiesce.Quiesced(); quiesced {
+		return nil, ErrQuiesced
+	}
+
 	var aidPtr *string
 	if agentID != "" {
 		aidPtr = &agentID
@@ -188,14 +378,24 @@ func (s *SessionService) CreateSessionWithID(ctx context.Context, sessionID, pro
 	if err := s.store.CreateSession(ctx, sess); err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
+	s.publishCreated(ctx, sess)
 
 	return s.mapSession(sess), nil
 }
 
+// publishCreated announces a new session to event subscribers.
+func (s *SessionService) publishCreated(ctx context.Context, sess *model.Session) {
+	if s.eventBroker != nil {
+		if err := s.eventBroker.PublishSessionUpdated(ctx, sess.ProjectID, sess.ID, sess.Status, ""); err != nil {
+			log.Printf("Failed to publish session created event: %v", err)
+		}
+	}
+}
+
 // UpdateStatus updates the session status and optional error message, and publishes an SSE event.
 func (s *SessionService) UpdateStatus(ctx context.Context, projectID, sessionID, status string, errorMsg *string) (*Session, error) {
 	// Use targeted column update to avoid overwriting concurrent changes to other fields
-	if err := s.store.UpdateSessionStatus(ctx, sessionID, status, errorMsg); err != nil {
+	if err := setSessionStatus(ctx, s.store, sessionID, status, errorMsg); err != nil {
 		return nil, fmt.Errorf("failed to update session status: %w", err)
 	}
 
@@ -216,6 +416,7 @@ func (s *SessionService) UpdateStatus(ctx context.Context, projectID, sessionID,
 			return nil, fmt.Errorf("failed to clear commit status: %w", err)
 		}
 		commitStatusChanged = model.CommitStatusNone
+		s.recordTransition(ctx, sessionID, model.TransitionKindCommitStatus, model.CommitStatusNone, "session resumed")
 	}
 
 	// Always publish SSE event for status changes
@@ -228,6 +429,51 @@ func (s *SessionService) UpdateStatus(ctx context.Context, projectID, sessionID,
 	return s.mapSession(sess), nil
 }
 
+// Pause freezes a ready session's sandbox so it uses no CPU while idle. The
+// sandbox keeps its memory, so Resume is near-instant. Sessions with a chat
+// completion running can't be paused. Pausing a paused session is a no-op.
+func (s *SessionService) Pause(ctx context.Context, projectID, sessionID string) (*Session, error) {
+	sess, err := s.store.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	switch sess.Status {
+	case model.SessionStatusPaused:
+		return s.mapSession(sess), nil
+	case model.SessionStatusReady:
+	default:
+		return nil, fmt.Errorf("%w: session is %s, only ready sessions can be paused", ErrInvalidSessionState, sess.Status)
+	}
+
+	if err := s.sandboxProvider.Pause(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	return s.UpdateStatus(ctx, projectID, sessionID, model.SessionStatusPaused, nil)
+}
+
+// Resume unpauses a paused session's sandbox. Resuming a ready session is a
+// no-op.
+func (s *SessionService) Resume(ctx context.Context, projectID, sessionID string) (*Session, error) {
+	sess, err := s.store.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	switch sess.Status {
+	case model.SessionStatusReady, model.SessionStatusRunning:
+		return s.mapSession(sess), nil
+	case model.SessionStatusPaused:
+	default:
+		return nil, fmt.Errorf("%w: session is %s, only paused sessions can be resumed", ErrInvalidSessionState, sess.Status)
+	}
+
+	if err := s.sandboxProvider.Unpause(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	return s.UpdateStatus(ctx, projectID, sessionID, model.SessionStatusReady, nil)
+}
+
 // UpdateSession updates a session
 func (s *SessionService) UpdateSession(ctx context.Context, sessionID, name string, displayName *string, status string) (*Session, error) {
 	sess, err := s.store.GetSessionByID(ctx, sessionID)
@@ -258,6 +504,8 @@ func (s *SessionService) UpdateSession(ctx context.Context, sessionID, name stri
 
 // DeleteSession initiates async deletion of a session.
 // It sets the session status to "removing", emits an SSE event, and enqueues a deletion job.
+// Returns ErrCommitInProgress if the session is being committed, unless the
+// commit has made no progress for commitLockTimeout.
 func (s *SessionService) DeleteSession(ctx context.Context, projectID, sessionID string, jobQueue JobEnqueuer) error {
 	// Get session to verify it exists
 	sess, err := s.store.GetSessionByID(ctx, sessionID)
@@ -270,10 +518,24 @@ func (s *SessionService) DeleteSession(ctx context.Context, projectID, sessionID
 		return nil // Already being deleted
 	}
 
-	// Update status to "removing"
-	sess.Status = model.SessionStatusRemoving
-	if err := s.store.UpdateSession(ctx, sess); err != nil {
-		return fmt.Errorf("failed to update session status: %w", err)
+	// Update status to "removing". The status and commit status act as a
+	// lock: the update only applies if no live commit holds the session.
+	if err := s.store.MarkSessionRemoving(ctx, sessionID, time.Now().Add(-commitLockTimeout)); err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("failed to update session status: %w", err)
+		}
+		// Lost a race with another delete, or a commit holds the session
+		current, getErr := s.store.GetSessionByID(ctx, sessionID)
+		if getErr != nil {
+			return fmt.Errorf("session not found: %w", getErr)
+		}
+		if current.Status == model.SessionStatusRemoving {
+			return nil
+		}
+		return ErrCommitInProgress
+	}
+	if sess.CommitStatus == model.CommitStatusPending || sess.CommitStatus == model.CommitStatusCommitting {
+		log.Printf("Session %s: deleting despite commit stuck in %s since %s", sessionID, sess.CommitStatus, sess.UpdatedAt.Format(time.RFC3339))
 	}
 
 	// Emit SSE event
@@ -293,26 +555,278 @@ func (s *SessionService) DeleteSession(ctx context.Context, projectID, sessionID
 	return nil
 }
 
+// BulkDeleteSessions deletes the given sessions of a project, or every
+// session matching filter if sessionIDs is empty, each through DeleteSession
+// so its sandbox and volumes are cleaned up by its own job. Sessions already
+// being deleted are reported rather than deleted again, so the call is
+// idempotent. Returns ErrUnknownBulkDeleteFilter for an unknown filter.
+func (s *SessionService) BulkDeleteSessions(ctx context.Context, projectID string, sessionIDs []string, filter string, jobQueue JobEnqueuer) (*BulkDeleteResult, error) {
+	var sessions []*model.Session
+	var err error
+	if len(sessionIDs) > 0 {
+		sessions, err = s.store.ListSessionsByIDs(ctx, projectID, sessionIDs)
+	} else {
+		opts, ok := bulkDeleteFilters[filter]
+		if !ok {
+			return nil, ErrUnknownBulkDeleteFilter
+		}
+		sessions, err = s.store.ListSessionsByProject(ctx, projectID, opts)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	result := &BulkDeleteResult{
+		Accepted:         []string{},
+		AlreadyRemoving:  []string{},
+		CommitInProgress: []string{},
+		NotFound:         []string{},
+		Failed:           []string{},
+	}
+	byID := make(map[string]*model.Session, len(sessions))
+	for _, sess := range sessions {
+		byID[sess.ID] = sess
+	}
+	if len(sessionIDs) == 0 {
+		for _, sess := range sessions {
+			sessionIDs = append(sessionIDs, sess.ID)
+		}
+	}
+
+	seen := make(map[string]bool, len(sessionIDs))
+	for _, id := range sessionIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		sess, ok := byID[id]
+		switch {
+		case !ok:
+			result.NotFound = append(result.NotFound, id)
+			continue
+		case sess.Status == model.SessionStatusRemoving || sess.Status == model.SessionStatusRemoved:
+			result.AlreadyRemoving = append(result.AlreadyRemoving, id)
+			continue
+		}
+
+		if err := s.DeleteSession(ctx, projectID, id, jobQueue); err != nil {
+			if errors.Is(err, ErrCommitInProgress) {
+				result.CommitInProgress = append(result.CommitInProgress, id)
+			} else {
+				log.Printf("Bulk delete: failed to delete session %s: %v", id, err)
+				result.Failed = append(result.Failed, id)
+			}
+			continue
+		}
+		result.Accepted = append(result.Accepted, id)
+	}
+	return result, nil
+}
+
 // CommitSession initiates async commit of a session.
-// It enqueues a commit job unconditionally. Multiple commit jobs can be queued
-// for the same workspace and will be executed sequentially by the job queue.
-func (s *SessionService) CommitSession(ctx context.Context, projectID, sessionID string, jobQueue JobEnqueuer) error {
+// It enqueues a commit job unless the session is being deleted, in which case
+// it returns ErrSessionRemoving. Multiple commit jobs can be queued for the
+// same workspace and will be executed sequentially by the job queue.
+// userID is the user who triggered the commit; their identity is used as the
+// git author and committer.
+func (s *SessionService) CommitSession(ctx context.Context, projectID, sessionID, userID string, jobQueue JobEnqueuer) error {
 	// Get session to verify it exists and get workspace ID
 	sess, err := s.store.GetSessionByID(ctx, sessionID)
 	if err != nil {
 		return fmt.Errorf("session not found: %w", err)
 	}
+	if sess.Status == model.SessionStatusRemoving {
+		return ErrSessionRemoving
+	}
 
 	// Enqueue commit job (multiple jobs for same workspace are allowed and serialized)
-	if err = jobQueue.Enqueue(ctx, jobs.SessionCommitPayload{ProjectID: projectID, SessionID: sessionID, WorkspaceID: sess.WorkspaceID}); err != nil {
+	if err = jobQueue.Enqueue(ctx, jobs.SessionCommitPayload{ProjectID: projectID, SessionID: sessionID, WorkspaceID: sess.WorkspaceID, UserID: userID}); err != nil {
 		return fmt.Errorf("failed to enqueue commit job: %w", err)
 	}
 
 	return nil
 }
 
-// publishCommitStatusChanged publishes an SSE event for commit status changes.
-func (s *SessionService) publishCommitStatusChanged(ctx context.Context, projectID, sessionID, commitStatus string) {
+// PreviewCommit is a dry run of CommitSession. It fetches the agent's
+// patches against the workspace's current commit and summarizes them without
+// applying them, sending /discobot-commit, or changing the commit status. If
+// the agent hasn't committed anything yet, the preview is empty.
+func (s *SessionService) PreviewCommit(ctx context.Context, projectID, sessionID string) (*CommitPreview, error) {
+	sess, err := s.store.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("session not found: %w", err)
+	}
+	if sess.ProjectID != projectID {
+		return nil, fmt.Errorf("session not found")
+	}
+	if sess.Status == model.SessionStatusRemoving {
+		return nil, ErrSessionRemoving
+	}
+	if s.sandboxService == nil {
+		return nil, fmt.Errorf("sandbox service not available")
+	}
+
+	gitStatus, err := s.gitService.Status(ctx, sess.WorkspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspace status: %w", err)
+	}
+	preview := &CommitPreview{BaseCommit: gitStatus.Commit}
+
+	client, err := s.sandboxService.GetClient(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sandbox client: %w", err)
+	}
+	commitsResp, err := client.GetCommits(ctx, gitStatus.Commit)
+	if err != nil {
+		if strings.Contains(err.Error(), "(no_commits)") {
+			preview.Summary = git.SummarizePatches(nil)
+			return preview, nil
+		}
+		return nil, fmt.Errorf("failed to get commits from agent: %w", err)
+	}
+
+	preview.CommitCount = commitsResp.CommitCount
+	preview.Patches = commitsResp.Patches
+	preview.Summary = git.SummarizePatches([]byte(commitsResp.Patches))
+	return preview, nil
+}
+
+// ResetSession discards the changes a session made to its home directory,
+// keeping its workspace (including uncommitted changes), caches, and data
+// volume. It asks the sandbox to clear its home overlay on the next boot,
+// stops it, and enqueues an init job that starts it again; the session is
+// reinitializing until then. Sessions that are busy (a chat is running, or
+// they are starting or being deleted) return ErrInvalidSessionState.
+func (s *SessionService) ResetSession(ctx context.Context, projectID, sessionID string, jobQueue JobEnqueuer) error {
+	sess, err := s.store.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("session not found: %w", err)
+	}
+
+	switch sess.Status {
+	case model.SessionStatusReady, model.SessionStatusStopped, model.SessionStatusPaused, model.SessionStatusError:
+	default:
+		return fmt.Errorf("%w: session is %s, it can only be reset when idle", ErrInvalidSessionState, sess.Status)
+	}
+	if s.sandboxService == nil {
+		return fmt.Errorf("no sandbox service available")
+	}
+
+	// The marker lives on the data volume, so write it from inside the sandbox
+	if err := s.sandboxService.EnsureRunning(ctx, sessionID); err != nil {
+		return fmt.Errorf("failed to start sandbox: %w", err)
+	}
+	result, err := s.sandboxService.Exec(ctx, sessionID, []string{"touch", homeResetMarker}, sandbox.ExecOptions{User: "root"})
+	if err != nil {
+		return fmt.Errorf("failed to request reset: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("failed to request reset: %s", strings.TrimSpace(string(result.Stderr)))
+	}
+
+	s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusReinitializing, nil)
+	if err := s.sandboxService.StopForSession(ctx, sessionID); err != nil {
+		// The sandbox keeps running with the marker set; the reset happens
+		// whenever it next restarts
+		s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusError, ptrString("reset failed to stop sandbox: "+err.Error()))
+		return fmt.Errorf("failed to stop sandbox: %w", err)
+	}
+
+	agentID := ""
+	if sess.AgentID != nil {
+		agentID = *sess.AgentID
+	}
+	if err := jobQueue.Enqueue(ctx, jobs.SessionInitPayload{
+		ProjectID:   projectID,
+		SessionID:   sessionID,
+		WorkspaceID: sess.WorkspaceID,
+		AgentID:     agentID,
+	}); err != nil {
+		log.Printf("Note: session init job may already exist for %s: %v", sessionID, err)
+	}
+	return nil
+}
+
+// ForkSession creates a new session seeded from a snapshot of sessionID's
+// sandbox, so it starts with the same workspace, home directory and
+// conversation the original has now. The snapshot is taken immediately; the
+// fork's sandbox is created from it by the session_init job enqueued here.
+// Busy sessions return ErrInvalidSessionState, and sessions whose sandbox
+// provider can't take snapshots return sandbox.ErrNotSupported.
+func (s *SessionService) ForkSession(ctx context.Context, projectID, sessionID, name string, jobQueue JobEnqueuer) (*Session, error) {
+	if quiesced, _ := s.quiesce.Quiesced(); quiesced {
+		return nil, ErrQuiesced
+	}
+
+	src, err := s.store.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("session not found: %w", err)
+	}
+	switch src.Status {
+	case model.SessionStatusReady, model.SessionStatusStopped, model.SessionStatusPaused:
+	default:
+		return nil, fmt.Errorf("%w: session is %s, it can only be forked when idle", ErrInvalidSessionState, src.Status)
+	}
+
+	snapshotter, ok := s.sandboxProvider.(sandbox.Snapshotter)
+	if !ok {
+		return nil, sandbox.ErrNotSupported
+	}
+	snapshotID, err := snapshotter.Snapshot(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot sandbox: %w", err)
+	}
+
+	if name == "" {
+		name = src.Name + " (fork)"
+	}
+	fork := &model.Session{
+		ProjectID:       projectID,
+		WorkspaceID:     src.WorkspaceID,
+		AgentID:         src.AgentID,
+		Model:           src.Model,
+		Reasoning:       src.Reasoning,
+		Name:            name,
+		Status:          model.SessionStatusInitializing,
+		BaseCommit:      src.BaseCommit,
+		WorkspacePath:   src.WorkspacePath,
+		WorkspaceCommit: src.WorkspaceCommit,
+		ForkedFrom:      &src.ID,
+		SnapshotID:      &snapshotID,
+
+		HistoryTrimmedThrough: src.HistoryTrimmedThrough,
+		HistoryTrimmedCount:   src.HistoryTrimmedCount,
+	}
+	if err := s.store.CreateSession(ctx, fork); err != nil {
+		if delErr := snapshotter.DeleteSnapshot(ctx, snapshotID); delErr != nil {
+			log.Printf("Failed to delete snapshot %s: %v", snapshotID, delErr)
+		}
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	agentID := ""
+	if fork.AgentID != nil {
+		agentID = *fork.AgentID
+	}
+	if err := jobQueue.Enqueue(ctx, jobs.SessionInitPayload{
+		ProjectID:   projectID,
+		SessionID:   fork.ID,
+		WorkspaceID: fork.WorkspaceID,
+		AgentID:     agentID,
+	}); err != nil {
+		// Log but don't fail - session was created, init can be retried
+		log.Printf("Warning: failed to enqueue session init for %s: %v", fork.ID, err)
+	}
+
+	return s.mapSession(fork), nil
+}
+
+// publishCommitStatusChanged records a commit status transition and publishes
+// an SSE event for it. reason describes the change (e.g. the commit error).
+func (s *SessionService) publishCommitStatusChanged(ctx context.Context, projectID, sessionID, commitStatus, reason string) {
+	s.recordTransition(ctx, sessionID, model.TransitionKindCommitStatus, commitStatus, reason)
+
 	if s.eventBroker != nil {
 		// Send empty string for session status since only commit status changed
 		if err := s.eventBroker.PublishSessionUpdated(ctx, projectID, sessionID, "", commitStatus); err != nil {
@@ -321,6 +835,66 @@ func (s *SessionService) publishCommitStatusChanged(ctx context.Context, project
 	}
 }
 
+// sessionHistoryLimit is the number of status transitions kept per session.
+const sessionHistoryLimit = 100
+
+// recordTransition appends a status or commit status transition to the
+// session's history.
+func (s *SessionService) recordTransition(ctx context.Context, sessionID, kind, to, reason string) {
+	recordSessionTransition(ctx, s.store, sessionID, kind, to, reason)
+}
+
+// setSessionStatus updates a session's status and error message and records
+// the transition in its history. Every status change should go through here
+// (or UpdateStatus) so the history doesn't miss transitions made outside the
+// session service, like the sandbox watcher's and the status poller's.
+func setSessionStatus(ctx context.Context, st *store.Store, sessionID, status string, errorMsg *string) error {
+	if err := st.UpdateSessionStatus(ctx, sessionID, status, errorMsg); err != nil {
+		return err
+	}
+	reason := ""
+	if errorMsg != nil {
+		reason = *errorMsg
+	}
+	recordSessionTransition(ctx, st, sessionID, model.TransitionKindStatus, status, reason)
+	return nil
+}
+
+// recordSessionTransition appends a status or commit status transition to the
+// session's history. The previous state is taken from the last recorded
+// transition of the same kind. Repeated transitions to the same state with the
+// same reason are skipped. Failures are logged, never returned.
+func recordSessionTransition(ctx context.Context, st *store.Store, sessionID, kind, to, reason string) {
+	from := ""
+	if last, err := st.GetLastSessionTransition(ctx, sessionID, kind); err == nil {
+		if last.To == to && last.Reason == reason {
+			return
+		}
+		from = last.To
+	}
+
+	transition := &model.SessionTransition{
+		SessionID: sessionID,
+		Kind:      kind,
+		From:      from,
+		To:        to,
+		Reason:    reason,
+	}
+	if err := st.CreateSessionTransition(ctx, transition, sessionHistoryLimit); err != nil {
+		log.Printf("Failed to record %s transition for session %s: %v", kind, sessionID, err)
+	}
+}
+
+// GetHistory returns up to limit of the session's most recent status and
+// commit status transitions, oldest first.
+func (s *SessionService) GetHistory(ctx context.Context, sessionID string, limit int) ([]*model.SessionTransition, error) {
+	transitions, err := s.store.ListSessionTransitions(ctx, sessionID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session history: %w", err)
+	}
+	return transitions, nil
+}
+
 // ReconcileCommitStates checks sessions stuck in pending/committing commit states
 // and re-enqueues commit jobs if needed. This should be called on server startup.
 func (s *SessionService) ReconcileCommitStates(ctx context.Context) error {
@@ -390,6 +964,19 @@ func (s *SessionService) PerformDeletion(ctx context.Context, projectID, session
 		}
 	}
 
+	// A fork deleted before its sandbox was created still holds its snapshot
+	if sess, err := s.store.GetSessionByID(ctx, sessionID); err == nil && sess.SnapshotID != nil {
+		if snapshotter, ok := s.sandboxProvider.(sandbox.Snapshotter); ok {
+			if err := snapshotter.DeleteSnapshot(ctx, *sess.SnapshotID); err != nil {
+				jobs.Logf(ctx, "Failed to delete snapshot %s for session %s: %v", *sess.SnapshotID, sessionID, err)
+			}
+		}
+	}
+
+	// Snapshots taken of the session go with it, except any a fork's sandbox
+	// is still to be created from
+	s.deleteSessionSnapshots(ctx, sessionID)
+
 	// Step 2: Delete from database (messages, terminal history, session)
 	if err := s.store.DeleteSession(ctx, sessionID); err != nil {
 		return fmt.Errorf("failed to delete session from database: %w", err)
@@ -398,11 +985,11 @@ func (s *SessionService) PerformDeletion(ctx context.Context, projectID, session
 	// Step 3: Emit "removed" event to notify clients
 	if s.eventBroker != nil {
 		if err := s.eventBroker.PublishSessionUpdated(ctx, projectID, sessionID, model.SessionStatusRemoved, ""); err != nil {
-			log.Printf("Failed to publish session removed event: %v", err)
+			jobs.Logf(ctx, "Failed to publish session removed event: %v", err)
 		}
 	}
 
-	log.Printf("Session %s deleted successfully", sessionID)
+	jobs.Logf(ctx, "Session %s deleted successfully", sessionID)
 	return nil
 }
 
@@ -463,6 +1050,21 @@ func (s *SessionService) mapSession(sess *model.Session) *Session {
 		reasoning = *sess.Reasoning
 	}
 
+	historyTrimmedThrough := ""
+	if sess.HistoryTrimmedThrough != nil {
+		historyTrimmedThrough = *sess.HistoryTrimmedThrough
+	}
+
+	forkedFrom := ""
+	if sess.ForkedFrom != nil {
+		forkedFrom = *sess.ForkedFrom
+	}
+
+	snapshotID := ""
+	if sess.SnapshotID != nil {
+		snapshotID = *sess.SnapshotID
+	}
+
 	timestamp := sess.UpdatedAt.Format(time.RFC3339)
 	if sess.UpdatedAt.IsZero() {
 		timestamp = time.Now().Format(time.RFC3339)
@@ -488,6 +1090,12 @@ func (s *SessionService) mapSession(sess *model.Session) *Session {
 		Reasoning:       reasoning,
 		WorkspacePath:   workspacePath,
 		WorkspaceCommit: workspaceCommit,
+
+		HistoryTrimmedThrough: historyTrimmedThrough,
+		HistoryTrimmedCount:   sess.HistoryTrimmedCount,
+
+		ForkedFrom: forkedFrom,
+		snapshotID: snapshotID,
 	}
 }
 
@@ -511,6 +1119,15 @@ func (s *SessionService) Initialize(
 		return fmt.Errorf("session not found: %w", err)
 	}
 
+	// A session created just before the server was quiesced is never set up.
+	// Retrying wouldn't help, so fail the session rather than the job.
+	// Reinitializing existing sessions (after a reset or lost sandbox) is
+	// still allowed.
+	if quiesced, _ := s.quiesce.Quiesced(); quiesced && sessionModel.Status == model.SessionStatusInitializing {
+		s.updateStatusWithEvent(ctx, sessionModel.ProjectID, sessionID, model.SessionStatusError, ptrString(ErrQuiesced.Error()))
+		return nil
+	}
+
 	// Get workspace info
 	workspace, err := s.store.GetWorkspaceByID(ctx, sessionModel.WorkspaceID)
 	if err != nil {
@@ -537,11 +1154,12 @@ func (s *SessionService) Initialize(
 		}
 	}
 
-	// If we need to fallback, try to get and assign the default agent
+	// If we need to fallback, try to get and assign the workspace's default
+	// agent, then the project's
 	if needsAgentFallback {
-		log.Printf("Session %s: %s, attempting to use default agent", sessionID, fallbackReason)
+		jobs.Logf(ctx, "Session %s: %s, attempting to use default agent", sessionID, fallbackReason)
 
-		defaultAgent, err := s.store.GetDefaultAgent(ctx, sessionModel.ProjectID)
+		defaultAgent, err := s.store.GetWorkspaceDefaultAgent(ctx, workspace)
 		if err != nil {
 			// No default agent available - fail with helpful message
 			s.updateStatusWithEvent(ctx, sessionModel.ProjectID, sessionID, model.SessionStatusError,
@@ -550,7 +1168,7 @@ func (s *SessionService) Initialize(
 		}
 
 		// Update session to use default agent
-		log.Printf("Session %s: assigning default agent %s (type: %s)", sessionID, defaultAgent.ID, defaultAgent.AgentType)
+		jobs.Logf(ctx, "Session %s: assigning default agent %s (type: %s)", sessionID, defaultAgent.ID, defaultAgent.AgentType)
 		sessionModel.AgentID = &defaultAgent.ID
 		if err := s.store.UpdateSession(ctx, sessionModel); err != nil {
 			return fmt.Errorf("failed to update session with default agent: %w", err)
@@ -566,6 +1184,18 @@ func (s *SessionService) Initialize(
 	return s.initializeSync(ctx, session.ProjectID, session, workspace, agent)
 }
 
+// agentEnv returns the default environment of agent's type, or nil if it has
+// none.
+func (s *SessionService) agentEnv(agent *model.Agent) map[string]string {
+	if agent == nil {
+		return nil
+	}
+	if at := findAgentType(s.agentTypes, agent.AgentType); at != nil {
+		return at.DefaultEnv
+	}
+	return nil
+}
+
 // initializeSync runs the initialization flow synchronously.
 // The flow is: ensure workspace -> save workspace info on session -> create sandbox.
 func (s *SessionService) initializeSync(
@@ -573,7 +1203,7 @@ func (s *SessionService) initializeSync(
 	projectID string,
 	session *Session,
 	workspace *model.Workspace,
-	_ *model.Agent,
+	agent *model.Agent,
 ) error {
 	sessionID := session.ID
 
@@ -590,7 +1220,7 @@ func (s *SessionService) initializeSync(
 		var err error
 		workspacePath, currentCommit, err = s.gitService.EnsureWorkspaceRepo(ctx, workspace.ID)
 		if err != nil {
-			log.Printf("Git setup failed for session %s: %v", sessionID, err)
+			jobs.Logf(ctx, "Git setup failed for session %s: %v", sessionID, err)
 			s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusError, ptrString("git setup failed: "+err.Error()))
 			return fmt.Errorf("git setup failed: %w", err)
 		}
@@ -611,7 +1241,7 @@ func (s *SessionService) initializeSync(
 		// First initialization - save workspace path and commit
 		workspaceCommit = currentCommit
 		if err := s.store.UpdateSessionWorkspace(ctx, sessionID, workspacePath, workspaceCommit); err != nil {
-			log.Printf("Failed to update session workspace info for %s: %v", sessionID, err)
+			jobs.Logf(ctx, "Failed to update session workspace info for %s: %v", sessionID, err)
 			s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusError, ptrString("failed to save workspace info: "+err.Error()))
 			return fmt.Errorf("failed to save workspace info: %w", err)
 		}
@@ -621,28 +1251,41 @@ func (s *SessionService) initializeSync(
 	// First check if sandbox already exists (from a previous failed attempt)
 	existingSandbox, err := s.sandboxProvider.Get(ctx, sessionID)
 	if err != nil && !errors.Is(err, sandbox.ErrNotFound) {
-		log.Printf("Failed to check for existing sandbox for session %s: %v", sessionID, err)
+		jobs.Logf(ctx, "Failed to check for existing sandbox for session %s: %v", sessionID, err)
 		s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusError, ptrString("failed to check sandbox: "+err.Error()))
 		return fmt.Errorf("failed to check sandbox: %w", err)
 	}
 
 	needsCreation := true
 	if existingSandbox != nil {
-		log.Printf("Sandbox already exists for session %s (status: %s)", sessionID, existingSandbox.Status)
+		jobs.Logf(ctx, "Sandbox already exists for session %s (status: %s)", sessionID, existingSandbox.Status)
 
 		switch existingSandbox.Status {
 		case sandbox.StatusRunning:
-			log.Printf("Sandbox for session %s is already running", sessionID)
+			jobs.Logf(ctx, "Sandbox for session %s is already running", sessionID)
 			needsCreation = false
 
+		case sandbox.StatusPaused:
+			if err := s.sandboxProvider.Unpause(ctx, sessionID); err != nil {
+				jobs.Logf(ctx, "Sandbox unpause failed for session %s: %v, will attempt to remove and recreate", sessionID, err)
+				if rmErr := s.sandboxProvider.Remove(ctx, sessionID); rmErr != nil {
+					jobs.Logf(ctx, "Failed to remove paused sandbox for session %s: %v", sessionID, rmErr)
+					s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusError, ptrString("sandbox unpause failed and removal failed: "+rmErr.Error()))
+					return fmt.Errorf("sandbox unpause failed and removal failed: %w", rmErr)
+				}
+			} else {
+				jobs.Logf(ctx, "Resumed paused sandbox for session %s", sessionID)
+				needsCreation = false
+			}
+
 		case sandbox.StatusCreated, sandbox.StatusStopped:
 			s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusCreatingSandbox, nil)
 			if err := s.sandboxProvider.Start(ctx, sessionID); err != nil {
 				if !errors.Is(err, sandbox.ErrAlreadyRunning) {
-					log.Printf("Sandbox start failed for session %s: %v, will attempt to remove and recreate", sessionID, err)
+					jobs.Logf(ctx, "Sandbox start failed for session %s: %v, will attempt to remove and recreate", sessionID, err)
 					// Start failed - try to remove and recreate
 					if rmErr := s.sandboxProvider.Remove(ctx, sessionID); rmErr != nil {
-						log.Printf("Failed to remove failed sandbox for session %s: %v", sessionID, rmErr)
+						jobs.Logf(ctx, "Failed to remove failed sandbox for session %s: %v", sessionID, rmErr)
 						s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusError, ptrString("sandbox start failed and removal failed: "+rmErr.Error()))
 						return fmt.Errorf("sandbox start failed and removal failed: %w", rmErr)
 					}
@@ -659,9 +1302,9 @@ func (s *SessionService) initializeSync(
 
 		default:
 			// Sandbox is in failed state - remove and recreate (preserve volumes)
-			log.Printf("Removing failed sandbox for session %s", sessionID)
+			jobs.Logf(ctx, "Removing failed sandbox for session %s", sessionID)
 			if err := s.sandboxProvider.Remove(ctx, sessionID); err != nil {
-				log.Printf("Failed to remove old sandbox for session %s: %v", sessionID, err)
+				jobs.Logf(ctx, "Failed to remove old sandbox for session %s: %v", sessionID, err)
 				s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusError, ptrString("failed to remove old sandbox: "+err.Error()))
 				return fmt.Errorf("failed to remove old sandbox: %w", err)
 			}
@@ -669,10 +1312,15 @@ func (s *SessionService) initializeSync(
 	}
 
 	if needsCreation {
-		// Check if image needs to be pulled and notify if so
-		if !s.sandboxProvider.ImageExists(ctx) {
+		// Check if image needs to be pulled and notify if so. A project's own
+		// image is pulled, if needed, while the sandbox is created.
+		image := s.sandboxService.projectImage(ctx, projectID)
+		if image != "" {
+			jobs.Logf(ctx, "Using project sandbox image %s for session %s", image, sessionID)
+			s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusCreatingSandbox, nil)
+		} else if !s.sandboxProvider.ImageExists(ctx) {
 			s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusPullingImage, nil)
-			log.Printf("Pulling sandbox image %s for session %s", s.sandboxProvider.Image(), sessionID)
+			jobs.Logf(ctx, "Pulling sandbox image %s for session %s", s.sandboxProvider.Image(), sessionID)
 		} else {
 			s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusCreatingSandbox, nil)
 		}
@@ -680,34 +1328,122 @@ func (s *SessionService) initializeSync(
 		sandboxSecret := generateSecret(32)
 		opts := sandbox.CreateOptions{
 			SharedSecret: sandboxSecret,
-			Labels: map[string]string{
+			Labels: s.sandboxService.sandboxLabels(ctx, projectID, map[string]string{
 				"discobot.session.id":   sessionID,
 				"discobot.workspace.id": workspace.ID,
 				"discobot.project.id":   projectID,
-			},
-			WorkspacePath:   workspacePath,
-			WorkspaceSource: workspace.Path, // Original source (git URL or local path) for WORKSPACE_PATH env var
-			WorkspaceCommit: workspaceCommit,
+			}),
+			Image:             image,
+			WorkspacePath:     workspacePath,
+			WorkspaceSource:   workspace.Path, // Original source (git URL or local path) for WORKSPACE_PATH env var
+			WorkspaceCommit:   workspaceCommit,
+			NetworkMode:       workspace.NetworkMode,
+			RestartPolicy:     workspace.RestartPolicy,
+			StopSignal:        workspace.StopSignal,
+			ExtraPorts:        workspace.ExtraPorts,
+			DisableProxyCache: workspace.DisableProxyCache,
+			CloneDepth:        workspace.CloneDepth,
+			CloneAllBranches:  workspace.CloneAllBranches,
+			CloneSubmodules:   workspace.CloneSubmodules,
+			Filesystem:        workspace.Filesystem,
+			Resources:         s.sandboxService.resourceLimits(workspace),
+			ResourceRequests:  s.sandboxService.resourceRequests(),
+			Env:               s.agentEnv(agent),
 		}
 
-		_, err := s.sandboxProvider.Create(ctx, sessionID, opts)
+		var err error
+		if session.snapshotID != "" {
+			// Forked session: start from the original session's snapshot
+			err = s.createFromSnapshot(ctx, sessionID, session.snapshotID, opts)
+		} else {
+			_, err = s.sandboxProvider.Create(ctx, sessionID, opts)
+		}
 		if err != nil {
-			log.Printf("Sandbox creation failed for session %s: %v", sessionID, err)
+			jobs.Logf(ctx, "Sandbox creation failed for session %s: %v", sessionID, err)
 			s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusError, ptrString("sandbox creation failed: "+err.Error()))
 			return fmt.Errorf("sandbox creation failed: %w", err)
 		}
 
 		// Start the sandbox
 		if err := s.sandboxProvider.Start(ctx, sessionID); err != nil {
-			log.Printf("Sandbox start failed for session %s: %v", sessionID, err)
+			jobs.Logf(ctx, "Sandbox start failed for session %s: %v", sessionID, err)
 			s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusError, ptrString("sandbox start failed: "+err.Error()))
 			return fmt.Errorf("sandbox start failed: %w", err)
 		}
 	}
 
+	// Step 4: Wait for the sandbox to report running, then to stabilize so a
+	// crash-looping sandbox isn't reported as ready
+	if _, err := s.sandboxProvider.WaitForStatus(ctx, sessionID, sandbox.StatusRunning, sandboxStartWait); err != nil {
+		jobs.Logf(ctx, "Sandbox for session %s did not start: %v", sessionID, err)
+		s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusError, ptrString("sandbox failed to start: "+err.Error()))
+		return fmt.Errorf("sandbox did not start: %w", err)
+	}
+	if s.startupProbe != nil {
+		client := NewSandboxChatClient(s.sandboxProvider, nil)
+		if err := s.startupProbe.Wait(ctx, s.sandboxProvider, client, sessionID); err != nil {
+			jobs.Logf(ctx, "Sandbox startup probe failed for session %s: %v", sessionID, err)
+			s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusError, ptrString("sandbox failed to start: "+err.Error()))
+			return fmt.Errorf("sandbox startup probe failed: %w", err)
+		}
+	}
+
 	// Success! Update status to running
 	s.updateStatusWithEvent(ctx, projectID, sessionID, model.SessionStatusReady, nil)
-	log.Printf("Session %s initialized successfully", sessionID)
+	jobs.Logf(ctx, "Session %s initialized successfully", sessionID)
+	return nil
+}
+
+// deleteSessionSnapshots deletes the snapshots taken of sessionID's sandbox
+// that no forked session is waiting to be created from. Failures are logged.
+func (s *SessionService) deleteSessionSnapshots(ctx context.Context, sessionID string) {
+	differ, ok := s.sandboxProvider.(sandbox.SnapshotDiffer)
+	if !ok {
+		return
+	}
+	snapshotter, ok := s.sandboxProvider.(sandbox.Snapshotter)
+	if !ok {
+		return
+	}
+	snapshots, err := differ.ListSnapshots(ctx, sessionID)
+	if err != nil {
+		if !errors.Is(err, sandbox.ErrNotSupported) {
+			jobs.Logf(ctx, "Failed to list snapshots for session %s: %v", sessionID, err)
+		}
+		return
+	}
+	for _, snap := range snapshots {
+		if pending, err := s.store.SnapshotPending(ctx, snap.ID); err != nil || pending {
+			continue
+		}
+		if err := snapshotter.DeleteSnapshot(ctx, snap.ID); err != nil {
+			jobs.Logf(ctx, "Failed to delete snapshot %s for session %s: %v", snap.ID, sessionID, err)
+		}
+	}
+}
+
+// createFromSnapshot creates a forked session's sandbox from the snapshot of
+// the session it was forked from, then deletes the snapshot: from then on
+// the session's own data volume holds its state. The snapshot image stays
+// while the sandbox uses it and is recorded on the session, so reconciliation
+// doesn't replace it with the project's image.
+func (s *SessionService) createFromSnapshot(ctx context.Context, sessionID, snapshotID string, opts sandbox.CreateOptions) error {
+	snapshotter, ok := s.sandboxProvider.(sandbox.Snapshotter)
+	if !ok {
+		return sandbox.ErrNotSupported
+	}
+	sb, err := snapshotter.CreateFromSnapshot(ctx, sessionID, snapshotID, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.ClearSessionSnapshot(ctx, sessionID, sb.Image); err != nil {
+		jobs.Logf(ctx, "Failed to clear snapshot for session %s: %v", sessionID, err)
+		return nil
+	}
+	if err := snapshotter.DeleteSnapshot(ctx, snapshotID); err != nil {
+		jobs.Logf(ctx, "Failed to delete snapshot %s for session %s: %v", snapshotID, sessionID, err)
+	}
 	return nil
 }
 
@@ -716,7 +1452,7 @@ func (s *SessionService) initializeSync(
 func (s *SessionService) updateStatusWithEvent(ctx context.Context, projectID, sessionID, status string, errorMsg *string) {
 	_, err := s.UpdateStatus(ctx, projectID, sessionID, status, errorMsg)
 	if err != nil {
-		log.Printf("Failed to update session %s status to %s: %v", sessionID, status, err)
+		jobs.Logf(ctx, "Failed to update session %s status to %s: %v", sessionID, status, err)
 	}
 }
 
@@ -746,7 +1482,7 @@ func ptrString(s string) *string {
 // 3. If pending: send /discobot-commit to agent, transition to committing
 // 4. If appliedCommit not set: fetch patches from agent-api, apply to workspace
 // 5. Transition to completed
-func (s *SessionService) PerformCommit(ctx context.Context, projectID, sessionID string) (retErr error) {
+func (s *SessionService) PerformCommit(ctx context.Context, projectID, sessionID, userID string) (retErr error) {
 	// Get session
 	sess, err := s.store.GetSessionByID(ctx, sessionID)
 	if err != nil {
@@ -771,6 +1507,16 @@ func (s *SessionService) PerformCommit(ctx context.Context, projectID, sessionID
 		}
 	}()
 
+	// Take the commit lock; a deletion that started after this job was
+	// queued wins, and the commit is dropped.
+	if err := s.store.BeginSessionCommit(ctx, sessionID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			jobs.Logf(ctx, "Session %s: skipping commit, session is being deleted", sessionID)
+			return nil
+		}
+		return fmt.Errorf("failed to start commit: %w", err)
+	}
+
 	// Get current git status and set up session for this commit
 	gitStatus, err := s.gitService.Status(ctx, sess.WorkspaceID)
 	if err != nil {
@@ -785,7 +1531,9 @@ func (s *SessionService) PerformCommit(ctx context.Context, projectID, sessionID
 	if err := s.store.UpdateSession(ctx, sess); err != nil {
 		return fmt.Errorf("failed to update session for commit: %w", err)
 	}
-	s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusPending)
+	s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusPending, "")
+
+	identity := s.commitIdentity(ctx, userID)
 
 	// Step 1: Handle workspace commit changes
 	if err := s.syncBaseCommit(ctx, projectID, workspace, sess); err != nil {
@@ -797,7 +1545,7 @@ func (s *SessionService) PerformCommit(ctx context.Context, projectID, sessionID
 
 	// Step 1.5: Optimistically check if agent already has patches ready
 	if sess.CommitStatus == model.CommitStatusPending && (sess.AppliedCommit == nil || *sess.AppliedCommit == "") {
-		if err := s.tryApplyExistingPatches(ctx, projectID, workspace, sess); err != nil {
+		if err := s.tryApplyExistingPatches(ctx, projectID, workspace, sess, identity); err != nil {
 			return err
 		}
 		if sess.CommitStatus == model.CommitStatusFailed {
@@ -807,7 +1555,7 @@ func (s *SessionService) PerformCommit(ctx context.Context, projectID, sessionID
 
 	// Step 2: Send /discobot-commit to agent (if pending)
 	if sess.CommitStatus == model.CommitStatusPending {
-		if err := s.sendCommitPrompt(ctx, projectID, workspace, sess); err != nil {
+		if err := s.sendCommitPrompt(ctx, projectID, workspace, sess, identity); err != nil {
 			return err
 		}
 		if sess.CommitStatus == model.CommitStatusFailed {
@@ -817,7 +1565,7 @@ func (s *SessionService) PerformCommit(ctx context.Context, projectID, sessionID
 
 	// Step 3: Fetch and apply patches (if not yet done)
 	if sess.AppliedCommit == nil || *sess.AppliedCommit == "" {
-		if err := s.fetchAndApplyPatches(ctx, projectID, workspace, sess); err != nil {
+		if err := s.fetchAndApplyPatches(ctx, projectID, workspace, sess, identity); err != nil {
 			return err
 		}
 		if sess.CommitStatus == model.CommitStatusFailed {
@@ -826,16 +1574,16 @@ func (s *SessionService) PerformCommit(ctx context.Context, projectID, sessionID
 	}
 
 	// Step 4: Complete
-	log.Printf("Session %s: commit completed with applied commit %s", sess.ID, *sess.AppliedCommit)
+	jobs.Logf(ctx, "Session %s: commit completed with applied commit %s", sess.ID, *sess.AppliedCommit)
 
 	sess.CommitStatus = model.CommitStatusCompleted
 	sess.CommitError = nil
 	if err := s.store.UpdateSession(ctx, sess); err != nil {
 		return fmt.Errorf("failed to update session commit status: %w", err)
 	}
-	s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusCompleted)
+	s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusCompleted, "")
 
-	log.Printf("Workspace %s committed successfully via session %s", workspace.ID, sess.ID)
+	jobs.Logf(ctx, "Workspace %s committed successfully via session %s", workspace.ID, sess.ID)
 	return nil
 }
 
@@ -853,7 +1601,7 @@ func (s *SessionService) syncBaseCommit(ctx context.Context, projectID string, w
 		return nil
 	}
 
-	log.Printf("Session %s: workspace commit changed from %s to %s, updating baseCommit", sess.ID, *sess.BaseCommit, gitStatus.Commit)
+	jobs.Logf(ctx, "Session %s: workspace commit changed from %s to %s, updating baseCommit", sess.ID, *sess.BaseCommit, gitStatus.Commit)
 	sess.BaseCommit = ptrString(gitStatus.Commit)
 	if err := s.store.UpdateSession(ctx, sess); err != nil {
 		return fmt.Errorf("failed to update session baseCommit: %w", err)
@@ -864,42 +1612,42 @@ func (s *SessionService) syncBaseCommit(ctx context.Context, projectID string, w
 
 // tryApplyExistingPatches checks if the agent already has patches ready and applies them.
 // This is called optimistically before sending /discobot-commit in case patches are already available.
-func (s *SessionService) tryApplyExistingPatches(ctx context.Context, projectID string, workspace *model.Workspace, sess *model.Session) error {
+func (s *SessionService) tryApplyExistingPatches(ctx context.Context, projectID string, workspace *model.Workspace, sess *model.Session, identity gitIdentity) error {
 	if s.sandboxService == nil {
 		return nil
 	}
 
-	log.Printf("Session %s: checking if agent has existing patches for commit %s", sess.ID, *sess.BaseCommit)
+	jobs.Logf(ctx, "Session %s: checking if agent has existing patches for commit %s", sess.ID, *sess.BaseCommit)
 
 	client, err := s.sandboxService.GetClient(ctx, sess.ID)
 	if err != nil {
-		log.Printf("Session %s: no existing patches available (error: %v), continuing with prompt", sess.ID, err)
+		jobs.Logf(ctx, "Session %s: no existing patches available (error: %v), continuing with prompt", sess.ID, err)
 		return nil
 	}
 
 	commitsResp, err := client.GetCommits(ctx, *sess.BaseCommit)
 	if err != nil {
-		log.Printf("Session %s: no existing patches available (error: %v), continuing with prompt", sess.ID, err)
+		jobs.Logf(ctx, "Session %s: no existing patches available (error: %v), continuing with prompt", sess.ID, err)
 		return nil
 	}
 	if commitsResp.CommitCount == 0 {
-		log.Printf("Session %s: no existing patches available (commit count: 0), continuing with prompt", sess.ID)
+		jobs.Logf(ctx, "Session %s: no existing patches available (commit count: 0), continuing with prompt", sess.ID)
 		return nil
 	}
 
 	// Agent has patches ready - apply them directly
-	log.Printf("Session %s: agent has %d existing commits, skipping prompt and applying patches", sess.ID, commitsResp.CommitCount)
-	return s.applyPatches(ctx, projectID, workspace, sess, commitsResp.Patches, commitsResp.CommitCount)
+	jobs.Logf(ctx, "Session %s: agent has %d existing commits, skipping prompt and applying patches", sess.ID, commitsResp.CommitCount)
+	return s.applyPatches(ctx, projectID, workspace, sess, commitsResp.Patches, commitsResp.CommitCount, identity)
 }
 
 // sendCommitPrompt sends the /discobot-commit command to the agent.
-func (s *SessionService) sendCommitPrompt(ctx context.Context, projectID string, workspace *model.Workspace, sess *model.Session) error {
+func (s *SessionService) sendCommitPrompt(ctx context.Context, projectID string, workspace *model.Workspace, sess *model.Session, identity gitIdentity) error {
 	if s.sandboxService == nil {
 		s.setCommitFailed(ctx, projectID, workspace, sess, "Sandbox service not available")
 		return nil
 	}
 
-	log.Printf("Session %s: sending /discobot-commit %s to agent", sess.ID, *sess.BaseCommit)
+	jobs.Logf(ctx, "Session %s: sending /discobot-commit %s to agent", sess.ID, *sess.BaseCommit)
 
 	commitMessage := fmt.Sprintf("/discobot-commit %s", *sess.BaseCommit)
 	messages, err := buildCommitMessage(sess.ID+"-commit", commitMessage)
@@ -908,12 +1656,10 @@ func (s *SessionService) sendCommitPrompt(ctx context.Context, projectID string,
 		return nil
 	}
 
-	// Get git user config from the server
-	gitUserName, gitUserEmail := s.gitService.GetUserConfig(ctx)
-
+	// The agent commits as the user who triggered the commit
 	opts := &RequestOptions{
-		GitUserName:  gitUserName,
-		GitUserEmail: gitUserEmail,
+		GitUserName:  identity.name,
+		GitUserEmail: identity.email,
 	}
 
 	client, err := s.sandboxService.GetClient(ctx, sess.ID)
@@ -941,24 +1687,24 @@ func (s *SessionService) sendCommitPrompt(ctx context.Context, projectID string,
 		}
 	}
 
-	log.Printf("Session %s: /discobot-commit message completed, transitioning to committing", sess.ID)
+	jobs.Logf(ctx, "Session %s: /discobot-commit message completed, transitioning to committing", sess.ID)
 
 	sess.CommitStatus = model.CommitStatusCommitting
 	if err := s.store.UpdateSession(ctx, sess); err != nil {
 		return fmt.Errorf("failed to update session status: %w", err)
 	}
-	s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusCommitting)
+	s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusCommitting, "")
 	return nil
 }
 
 // fetchAndApplyPatches fetches patches from the agent and applies them to the workspace.
-func (s *SessionService) fetchAndApplyPatches(ctx context.Context, projectID string, workspace *model.Workspace, sess *model.Session) error {
+func (s *SessionService) fetchAndApplyPatches(ctx context.Context, projectID string, workspace *model.Workspace, sess *model.Session, identity gitIdentity) error {
 	if s.sandboxService == nil {
 		s.setCommitFailed(ctx, projectID, workspace, sess, "Sandbox service not available")
 		return nil
 	}
 
-	log.Printf("Session %s: fetching commits from agent-api (parent=%s)", sess.ID, *sess.BaseCommit)
+	jobs.Logf(ctx, "Session %s: fetching commits from agent-api (parent=%s)", sess.ID, *sess.BaseCommit)
 
 	client, err := s.sandboxService.GetClient(ctx, sess.ID)
 	if err != nil {
@@ -977,21 +1723,29 @@ func (s *SessionService) fetchAndApplyPatches(ctx context.Context, projectID str
 		return nil
 	}
 
-	log.Printf("Session %s: received %d commits from agent, applying patches to workspace", sess.ID, commitsResp.CommitCount)
-	return s.applyPatches(ctx, projectID, workspace, sess, commitsResp.Patches, commitsResp.CommitCount)
+	jobs.Logf(ctx, "Session %s: received %d commits from agent, applying patches to workspace", sess.ID, commitsResp.CommitCount)
+	return s.applyPatches(ctx, projectID, workspace, sess, commitsResp.Patches, commitsResp.CommitCount, identity)
 }
 
 // applyPatches applies the given patches to the workspace and updates the session.
-func (s *SessionService) applyPatches(ctx context.Context, projectID string, workspace *model.Workspace, sess *model.Session, patches string, commitCount int) error {
+func (s *SessionService) applyPatches(ctx context.Context, projectID string, workspace *model.Workspace, sess *model.Session, patches string, commitCount int, identity gitIdentity) error {
 	if sess.CommitStatus != model.CommitStatusCommitting {
 		sess.CommitStatus = model.CommitStatusCommitting
 		if err := s.store.UpdateSession(ctx, sess); err != nil {
 			return fmt.Errorf("failed to update session status: %w", err)
 		}
-		s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusCommitting)
+		s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusCommitting, "")
 	}
 
-	finalCommit, err := s.gitService.ApplyPatches(ctx, sess.WorkspaceID, []byte(patches))
+	opts := git.ApplyOptions{
+		CommitterName:  identity.name,
+		CommitterEmail: identity.email,
+	}
+	if project, err := s.store.GetProjectByID(ctx, projectID); err == nil && project.SkipGitHooks {
+		opts.SkipHooks = true
+		jobs.Logf(ctx, "Session %s: skipping workspace git hooks while applying patches (project setting)", sess.ID)
+	}
+	finalCommit, err := s.gitService.ApplyPatches(ctx, sess.WorkspaceID, []byte(patches), opts)
 	if err != nil {
 		s.setCommitFailed(ctx, projectID, workspace, sess, fmt.Sprintf("Failed to apply patches to workspace: %v", err))
 		return nil
@@ -1001,11 +1755,35 @@ func (s *SessionService) applyPatches(ctx context.Context, projectID string, wor
 	if err := s.store.UpdateSession(ctx, sess); err != nil {
 		return fmt.Errorf("failed to update session applied commit: %w", err)
 	}
-	s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusCommitting)
-	log.Printf("Session %s: %d patches applied, final commit=%s", sess.ID, commitCount, finalCommit)
+	s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusCommitting, "")
+	jobs.Logf(ctx, "Session %s: %d patches applied, final commit=%s", sess.ID, commitCount, finalCommit)
 	return nil
 }
 
+// gitIdentity is the name and email commits are attributed to.
+type gitIdentity struct {
+	name, email string
+}
+
+// commitIdentity resolves the git identity for a commit triggered by userID
+// from the user's profile. Anonymous mode, and users that can't be found, fall
+// back to the server's git user config.
+func (s *SessionService) commitIdentity(ctx context.Context, userID string) gitIdentity {
+	if userID != "" && userID != model.AnonymousUserID {
+		user, err := s.store.GetUserByID(ctx, userID)
+		if err == nil {
+			name := user.Email
+			if user.Name != nil && *user.Name != "" {
+				name = *user.Name
+			}
+			return gitIdentity{name: name, email: user.Email}
+		}
+		log.Printf("Failed to look up user %s for commit identity, using server git config: %v", userID, err)
+	}
+	name, email := s.gitService.GetUserConfig(ctx)
+	return gitIdentity{name: name, email: email}
+}
+
 // setCommitFailed sets the commit status to failed with an error message.
 func (s *SessionService) setCommitFailed(ctx context.Context, projectID string, workspace *model.Workspace, sess *model.Session, errorMsg string) {
 	log.Printf("Workspace %s commit failed (via session %s): %s", workspace.ID, sess.ID, errorMsg)
@@ -1017,7 +1795,7 @@ func (s *SessionService) setCommitFailed(ctx context.Context, projectID string,
 		return
 	}
 
-	s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusFailed)
+	s.publishCommitStatusChanged(ctx, projectID, sess.ID, model.CommitStatusFailed, errorMsg)
 }
 
 // buildCommitMessage creates a UIMessage array for the /discobot-commit command.
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/obot-platform/discobot/server/internal/model"
+)
+
+func TestSessionHistory(t *testing.T) {
+	ctx := context.Background()
+	testStore := setupTestStoreForPoller(t)
+
+	project := &model.Project{ID: "test-project", Name: "Test"}
+	workspace := &model.Workspace{ID: "test-ws", ProjectID: project.ID, Path: "/test", SourceType: "local"}
+	session := &model.Session{ID: "test-session", ProjectID: project.ID, WorkspaceID: workspace.ID, Status: model.SessionStatusInitializing}
+	if err := testStore.CreateProject(ctx, project); err != nil {
+		t.Fatal(err)
+	}
+	if err := testStore.CreateWorkspace(ctx, workspace); err != nil {
+		t.Fatal(err)
+	}
+	if err := testStore.CreateSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewSessionService(testStore, nil, nil, nil, nil, nil)
+	svc.updateStatusWithEvent(ctx, project.ID, session.ID, model.SessionStatusCloning, nil)
+	svc.updateStatusWithEvent(ctx, project.ID, session.ID, model.SessionStatusCloning, nil) // duplicate, skipped
+	svc.updateStatusWithEvent(ctx, project.ID, session.ID, model.SessionStatusError, ptrString("sandbox creation failed: boom"))
+	svc.publishCommitStatusChanged(ctx, project.ID, session.ID, model.CommitStatusFailed, "patch did not apply")
+
+	history, err := svc.GetHistory(ctx, session.ID, 0)
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+
+	want := []string{
+		"status:  -> cloning ()",
+		"status: cloning -> error (sandbox creation failed: boom)",
+		"commit_status:  -> failed (patch did not apply)",
+	}
+	if len(history) != len(want) {
+		t.Fatalf("Expected %d transitions, got %d: %+v", len(want), len(history), history)
+	}
+	for i, tr := range history {
+		if got := fmt.Sprintf("%s: %s -> %s (%s)", tr.Kind, tr.From, tr.To, tr.Reason); got != want[i] {
+			t.Errorf("Transition %d = %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestSessionHistory_StatusPoller(t *testing.T) {
+	ctx := context.Background()
+	testStore := setupTestStoreForPoller(t)
+
+	session := &model.Session{ID: "test-session", ProjectID: "test-project", Status: model.SessionStatusRunning}
+	if err := testStore.CreateSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+
+	// Status changes made outside the session service are recorded too
+	poller := NewSessionStatusPoller(testStore, nil, nil, slog.Default())
+	if err := poller.updateSessionStatus(ctx, session, model.SessionStatusReady, ""); err != nil {
+		t.Fatalf("updateSessionStatus failed: %v", err)
+	}
+
+	history, err := testStore.ListSessionTransitions(ctx, session.ID, 0)
+	if err != nil {
+		t.Fatalf("ListSessionTransitions failed: %v", err)
+	}
+	if len(history) != 1 || history[0].To != model.SessionStatusReady {
+		t.Errorf("Expected a single transition to ready, got %+v", history)
+	}
+}
+
+func TestSessionHistory_Bounded(t *testing.T) {
+	ctx := context.Background()
+	testStore := setupTestStoreForPoller(t)
+
+	statuses := []string{model.SessionStatusReady, model.SessionStatusRunning}
+	for i := range sessionHistoryLimit + 10 {
+		if err := testStore.CreateSessionTransition(ctx, &model.SessionTransition{
+			SessionID: "test-session",
+			Kind:      model.TransitionKindStatus,
+			To:        statuses[i%2],
+		}, sessionHistoryLimit); err != nil {
+			t.Fatalf("CreateSessionTransition failed: %v", err)
+		}
+	}
+
+	history, err := testStore.ListSessionTransitions(ctx, "test-session", 0)
+	if err != nil {
+		t.Fatalf("ListSessionTransitions failed: %v", err)
+	}
+	if len(history) != sessionHistoryLimit {
+		t.Errorf("Expected history capped at %d, got %d", sessionHistoryLimit, len(history))
+	}
+
+	recent, err := testStore.ListSessionTransitions(ctx, "test-session", 5)
+	if err != nil {
+		t.Fatalf("ListSessionTransitions failed: %v", err)
+	}
+	if len(recent) != 5 || recent[4].ID != history[len(history)-1].ID {
+		t.Errorf("Expected the 5 most recent transitions ending with the newest")
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// defaultSessionName names sessions that have nothing better to go by.
+const defaultSessionName = "New Session"
+
+// maxSummaryLen caps the length in characters of a {summary} placeholder.
+const maxSummaryLen = 60
+
+// SetNameTemplate sets the template used to name sessions created without a
+// name (see config.SessionNamePlaceholders). An empty template names them
+// after the first user message.
+func (s *SessionService) SetNameTemplate(tmpl string) {
+	s.nameTemplate = tmpl
+}
+
+// generateName names a new session in workspaceID whose first user message
+// is message (empty if none) from the name template. Placeholders that can't
+// be resolved expand to nothing, and an empty result falls back to
+// defaultSessionName.
+func (s *SessionService) generateName(ctx context.Context, workspaceID, message string) string {
+	if s.nameTemplate == "" {
+		return cmp.Or(strings.TrimSpace(message), defaultSessionName)
+	}
+
+	now := time.Now()
+	var workspace, branch string
+	if strings.Contains(s.nameTemplate, "{workspace}") {
+		if ws, err := s.store.GetWorkspaceByID(ctx, workspaceID); err == nil {
+			workspace = workspaceName(ws.Path, ws.DisplayName)
+		}
+	}
+	if strings.Contains(s.nameTemplate, "{branch}") && s.gitService != nil {
+		branch = currentBranch(s.gitService.GetWorkDir(ctx, workspaceID))
+	}
+
+	name := strings.NewReplacer(
+		"{summary}", summarizeMessage(message),
+		"{workspace}", workspace,
+		"{branch}", branch,
+		"{date}", now.Format(time.DateOnly),
+		"{time}", now.Format("15:04"),
+	).Replace(s.nameTemplate)
+
+	// Drop brackets and separators left dangling by empty placeholders
+	name = strings.NewReplacer("()", "", "[]", "").Replace(name)
+	name = strings.Join(strings.Fields(name), " ")
+	name = strings.Trim(name, " -–—·:|/@")
+	return cmp.Or(name, defaultSessionName)
+}
+
+// currentBranch returns the branch checked out in the repository at workDir,
+// or "" if HEAD is detached or there's no repository. It reads HEAD directly
+// rather than running git status, which scans the whole working tree and
+// would hold up session creation in large repositories.
+func currentBranch(workDir string) string {
+	if workDir == "" {
+		return ""
+	}
+	gitDir := filepath.Join(workDir, ".git")
+	// In a linked worktree .git is a file pointing at the real git directory
+	if data, err := os.ReadFile(gitDir); err == nil {
+		dir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+		if !ok {
+			return ""
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(workDir, dir)
+		}
+		gitDir = dir
+	}
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	branch, ok := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: refs/heads/")
+	if !ok {
+		return "" // Detached HEAD holds a commit SHA
+	}
+	return branch
+}
+
+// workspaceName returns the display name of a workspace, or else the last
+// element of its path or repository URL without a ".git" suffix.
+func workspaceName(wsPath string, displayName *string) string {
+	if displayName != nil && *displayName != "" {
+		return *displayName
+	}
+	wsPath = strings.TrimSuffix(strings.TrimRight(wsPath, "/"), ".git")
+	if i := strings.LastIndexAny(wsPath, `/\:`); i >= 0 {
+		wsPath = wsPath[i+1:]
+	}
+	return wsPath
+}
+
+// summarizeMessage shortens a chat message into a session title: the first
+// line of prose, skipping code blocks and markdown markers, cut after its
+// first sentence and at maxSummaryLen characters on a word boundary.
+func summarizeMessage(message string) string {
+	inCode := false
+	for line := range strings.Lines(message) {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimLeft(line, "#>*-+ "))
+		if line == "" {
+			continue
+		}
+		return truncateSummary(firstSentence(line))
+	}
+	return ""
+}
+
+// firstSentence returns text up to and including the first ".", "?", or "!"
+// followed by a space, dropping a trailing period.
+func firstSentence(text string) string {
+	for i, r := range text {
+		if (r == '.' || r == '?' || r == '!') && strings.HasPrefix(text[i+1:], " ") {
+			text = text[:i+1]
+			break
+		}
+	}
+	return strings.TrimSuffix(text, ".")
+}
+
+// truncateSummary cuts text to maxSummaryLen characters, at the last space
+// if there is one, and marks the cut with an ellipsis.
+func truncateSummary(text string) string {
+	if utf8.RuneCountInString(text) <= maxSummaryLen {
+		return text
+	}
+	runes := []rune(text)[:maxSummaryLen-1]
+	cut := string(runes)
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:") + "…"
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/model"
+)
+
+func TestSummarizeMessage(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{message: "", want: ""},
+		{message: "Fix the login bug", want: "Fix the login bug"},
+		{message: "Fix the login bug. It crashes on empty passwords.", want: "Fix the login bug"},
+		{message: "Why is this failing? Here's the trace", want: "Why is this failing?"},
+		{message: "Bump version to 1.2.3 please", want: "Bump version to 1.2.3 please"},
+		{message: "## Refactor the parser\n\nIt's too slow", want: "Refactor the parser"},
+		{message: "- add tests\n- fix lint", want: "add tests"},
+		{message: "```go\nfunc main() {}\n```\nWhy doesn't this compile?", want: "Why doesn't this compile?"},
+		{message: "  \n\t\n", want: ""},
+		{
+			message: "Please update every handler in the server package to return structured errors instead of plain strings",
+			want:    "Please update every handler in the server package to…",
+		},
+		{message: strings.Repeat("a", 100), want: strings.Repeat("a", maxSummaryLen-1) + "…"},
+	}
+	for _, tt := range tests {
+		if got := summarizeMessage(tt.message); got != tt.want {
+			t.Errorf("summarizeMessage(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestWorkspaceName(t *testing.T) {
+	display := "Backend"
+	tests := []struct {
+		path        string
+		displayName *string
+		want        string
+	}{
+		{path: "/home/user/code/api", want: "api"},
+		{path: "/home/user/code/api/", want: "api"},
+		{path: "https://github.com/org/repo.git", want: "repo"},
+		{path: "git@github.com:org/repo.git", want: "repo"},
+		{path: "git@github.com:repo.git", want: "repo"},
+		{path: "/home/user/code/api", displayName: &display, want: "Backend"},
+	}
+	for _, tt := range tests {
+		if got := workspaceName(tt.path, tt.displayName); got != tt.want {
+			t.Errorf("workspaceName(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSessionService_GenerateName(t *testing.T) {
+	ctx := context.Background()
+	testStore := setupTestStoreForPoller(t)
+
+	project := &model.Project{ID: "test-project", Name: "Test"}
+	workspace := &model.Workspace{ID: "test-ws", ProjectID: project.ID, Path: "https://github.com/org/api.git", SourceType: "git"}
+	if err := testStore.CreateProject(ctx, project); err != nil {
+		t.Fatal(err)
+	}
+	if err := testStore.CreateWorkspace(ctx, workspace); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewSessionService(testStore, nil, nil, nil, nil, nil)
+	today := time.Now().Format(time.DateOnly)
+
+	tests := []struct {
+		template string
+		message  string
+		want     string
+	}{
+		{template: "", message: "Fix the login bug. Thanks!", want: "Fix the login bug. Thanks!"},
+		{template: "", message: "", want: defaultSessionName},
+		{template: "{summary}", message: "Fix the login bug. Thanks!", want: "Fix the login bug"},
+		{template: "{summary}", message: "", want: defaultSessionName},
+		{template: "{workspace}: {summary}", message: "Add tests", want: "api: Add tests"},
+		{template: "{workspace}: {summary}", message: "", want: "api"},
+		{template: "{summary} ({workspace})", message: "Add tests", want: "Add tests (api)"},
+		{template: "{summary} [{branch}]", message: "Add tests", want: "Add tests"}, // No git service
+		{template: "{date} {summary}", message: "Add tests", want: today + " Add tests"},
+	}
+	for _, tt := range tests {
+		svc.SetNameTemplate(tt.template)
+		if got := svc.generateName(ctx, workspace.ID, tt.message); got != tt.want {
+			t.Errorf("template %q, message %q: got %q, want %q", tt.template, tt.message, got, tt.want)
+		}
+	}
+
+	// Sessions created without a name get a generated one
+	svc.SetNameTemplate("{workspace}: {summary}")
+	sess, err := svc.CreateSession(ctx, project.ID, workspace.ID, "", "", "Add a health check\n\nIt should ping the DB")
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if sess.Name != "api: Add a health check" {
+		t.Errorf("Expected generated session name, got %q", sess.Name)
+	}
+	sess, err = svc.CreateSession(ctx, project.ID, workspace.ID, "Explicit", "", "Add a health check")
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if sess.Name != "Explicit" {
+		t.Errorf("Expected the given session name, got %q", sess.Name)
+	}
+}
+
+func TestCurrentBranch(t *testing.T) {
+	writeFile := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo := t.TempDir()
+	writeFile(filepath.Join(repo, ".git", "HEAD"), "ref: refs/heads/feature/login\n")
+	detached := t.TempDir()
+	writeFile(filepath.Join(detached, ".git", "HEAD"), "0123456789abcdef0123456789abcdef01234567\n")
+	worktree := t.TempDir()
+	writeFile(filepath.Join(worktree, ".git"), "gitdir: ../main/.git/worktrees/wt\n")
+	writeFile(filepath.Join(worktree, "..", "main", ".git", "worktrees", "wt", "HEAD"), "ref: refs/heads/wt-branch\n")
+
+	tests := []struct {
+		workDir string
+		want    string
+	}{
+		{workDir: repo, want: "feature/login"},
+		{workDir: detached, want: ""},
+		{workDir: worktree, want: "wt-branch"},
+		{workDir: t.TempDir(), want: ""},
+		{workDir: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := currentBranch(tt.workDir); got != tt.want {
+			t.Errorf("currentBranch(%q) = %q, want %q", tt.workDir, got, tt.want)
+		}
+	}
+}
+
+func TestSessionService_GenerateName_Branch(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	defer env.cleanup()
+	project := env.createTestProject(t)
+	workspace, _ := env.createTestWorkspace(t, project.ID)
+	runGit(t, workspace.Path, "checkout", "-b", "feature/login")
+
+	svc := NewSessionService(env.store, env.gitService, nil, nil, nil, nil)
+	svc.SetNameTemplate("{summary} [{branch}]")
+	if got := svc.generateName(ctx, workspace.ID, "Add tests"); got != "Add tests [feature/login]" {
+		t.Errorf("Expected the branch in the name, got %q", got)
+	}
+
+	// A detached HEAD has no branch, and the empty brackets are dropped
+	runGit(t, workspace.Path, "checkout", "--detach")
+	if got := svc.generateName(ctx, workspace.ID, "Add tests"); got != "Add tests" {
+		t.Errorf("Expected no branch for a detached HEAD, got %q", got)
+	}
+}
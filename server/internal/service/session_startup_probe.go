@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+)
+
+// ErrSandboxCrashLoop indicates a sandbox kept restarting while it was starting up.
+var ErrSandboxCrashLoop = errors.New("sandbox is crash-looping")
+
+// StartupProbe requires a freshly started sandbox to stay healthy before its
+// session is marked ready. The agent-api must pass Successes consecutive
+// health checks; if the sandbox restarts more than MaxRestarts times while
+// waiting, it is treated as a crash loop.
+type StartupProbe struct {
+	Interval    time.Duration // Time between probes
+	Successes   int           // Consecutive healthy probes required
+	Timeout     time.Duration // Max time to wait for the sandbox to stabilize
+	MaxRestarts int           // Restarts tolerated before declaring a crash loop
+}
+
+// NewStartupProbe creates a startup probe, or returns nil if successes is not
+// positive (sessions are marked ready as soon as the sandbox starts).
+func NewStartupProbe(successes int, interval, timeout time.Duration, maxRestarts int) *StartupProbe {
+	if successes <= 0 {
+		return nil
+	}
+	return &StartupProbe{
+		Interval:    interval,
+		Successes:   successes,
+		Timeout:     timeout,
+		MaxRestarts: maxRestarts,
+	}
+}
+
+// Wait blocks until the session's sandbox has passed the probe. It returns
+// ErrSandboxCrashLoop if the sandbox restarts too often, or an error describing
+// the last failure if the sandbox doesn't stabilize within Timeout.
+func (p *StartupProbe) Wait(ctx context.Context, provider sandbox.Provider, client *SandboxChatClient, sessionID string) error {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	var (
+		healthy     int
+		restarts    int
+		lastStarted time.Time
+		wasDown     bool
+		lastErr     error
+	)
+	for {
+		sb, err := provider.Get(ctx, sessionID)
+		switch {
+		case err != nil:
+			healthy = 0
+			lastErr = err
+
+		case sb.Status != sandbox.StatusRunning:
+			healthy = 0
+			lastErr = fmt.Errorf("sandbox is %s", sb.Status)
+			if sb.Error != "" {
+				lastErr = fmt.Errorf("sandbox is %s: %s", sb.Status, sb.Error)
+			}
+			if !wasDown && !lastStarted.IsZero() {
+				restarts++
+			}
+			wasDown = true
+
+		default:
+			// A new start time without an observed outage means the sandbox
+			// restarted between probes
+			if sb.StartedAt != nil {
+				if !wasDown && !lastStarted.IsZero() && !sb.StartedAt.Equal(lastStarted) {
+					restarts++
+					healthy = 0
+				}
+				lastStarted = *sb.StartedAt
+			}
+			wasDown = false
+
+			if _, err := client.Health(ctx, sessionID); err != nil {
+				healthy = 0
+				lastErr = err
+			} else {
+				healthy++
+			}
+		}
+
+		if restarts > p.MaxRestarts {
+			return fmt.Errorf("%w: restarted %d times within %s (last error: %v)",
+				ErrSandboxCrashLoop, restarts, time.Since(start).Round(time.Second), lastErr)
+		}
+		if healthy >= p.Successes {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr == nil {
+				lastErr = ctx.Err()
+			}
+			return fmt.Errorf("sandbox did not become healthy within %s: %w", p.Timeout, lastErr)
+		case <-ticker.C:
+		}
+	}
+}
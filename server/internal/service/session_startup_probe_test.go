@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/sandbox/mock"
+)
+
+// newProbeTestProvider returns a mock provider with a running sandbox whose
+// /health endpoint reports the given health.
+func newProbeTestProvider(t *testing.T, healthy bool) *mock.Provider {
+	t.Helper()
+	provider := mock.NewProvider()
+	ctx := context.Background()
+	if _, err := provider.Create(ctx, "session-1", sandbox.CreateOptions{SharedSecret: "secret"}); err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+	if err := provider.Start(ctx, "session-1"); err != nil {
+		t.Fatalf("failed to start sandbox: %v", err)
+	}
+	provider.HTTPHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if healthy {
+			_, _ = w.Write([]byte(`{"healthy":true,"connected":true}`))
+		} else {
+			_, _ = w.Write([]byte(`{"healthy":false,"connected":false}`))
+		}
+	})
+	return provider
+}
+
+func TestNewStartupProbe_Disabled(t *testing.T) {
+	if probe := NewStartupProbe(0, time.Second, time.Minute, 2); probe != nil {
+		t.Errorf("Expected nil probe when successes is 0, got %+v", probe)
+	}
+}
+
+func TestStartupProbe_Healthy(t *testing.T) {
+	provider := newProbeTestProvider(t, true)
+	probe := NewStartupProbe(3, time.Millisecond, time.Second, 2)
+
+	if err := probe.Wait(context.Background(), provider, NewSandboxChatClient(provider, nil), "session-1"); err != nil {
+		t.Fatalf("Expected probe to pass, got %v", err)
+	}
+}
+
+func TestStartupProbe_Unhealthy(t *testing.T) {
+	provider := newProbeTestProvider(t, false)
+	probe := NewStartupProbe(3, time.Millisecond, 50*time.Millisecond, 2)
+
+	err := probe.Wait(context.Background(), provider, NewSandboxChatClient(provider, nil), "session-1")
+	if err == nil {
+		t.Fatal("Expected probe to time out")
+	}
+	if errors.Is(err, ErrSandboxCrashLoop) {
+		t.Errorf("Expected timeout, got crash loop: %v", err)
+	}
+}
+
+func TestStartupProbe_CrashLoop(t *testing.T) {
+	provider := newProbeTestProvider(t, true)
+
+	// Every probe sees the sandbox either down or freshly restarted
+	calls := 0
+	provider.GetFunc = func(_ context.Context, sessionID string) (*sandbox.Sandbox, error) {
+		calls++
+		startedAt := time.Unix(int64(calls), 0)
+		sb := &sandbox.Sandbox{SessionID: sessionID, Status: sandbox.StatusRunning, StartedAt: &startedAt}
+		if calls%3 == 0 {
+			sb.Status = sandbox.StatusStopped
+			sb.Error = "exit code 1"
+		}
+		return sb, nil
+	}
+	probe := NewStartupProbe(5, time.Millisecond, time.Second, 2)
+
+	err := probe.Wait(context.Background(), provider, NewSandboxChatClient(provider, nil), "session-1")
+	if !errors.Is(err, ErrSandboxCrashLoop) {
+		t.Fatalf("Expected ErrSandboxCrashLoop, got %v", err)
+	}
+}
@@ -211,7 +211,7 @@ func (p *SessionStatusPoller) updateSessionStatus(ctx context.Context, session *
 	}
 
 	// Update in database
-	if err := p.store.UpdateSessionStatus(ctx, session.ID, newStatus, errorMsgPtr); err != nil {
+	if err := setSessionStatus(ctx, p.store, session.ID, newStatus, errorMsgPtr); err != nil {
 		logger.Error("failed to update session status", "error", err)
 		return err
 	}
@@ -1,11 +1,14 @@
 package service
 
 import (
+	"context"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
+	"github.com/obot-platform/discobot/server/internal/sandbox/mock"
 )
 
 func TestValidateSessionID(t *testing.T) {
@@ -149,6 +152,12 @@ func TestMapSessionFieldCoverage(t *testing.T) {
 		WorkspaceCommit: strPtr("commit789"),
 		Model:           strPtr("claude-opus-4-6"),
 		Reasoning:       strPtr("enabled"),
+
+		HistoryTrimmedThrough: strPtr("msg-42"),
+		HistoryTrimmedCount:   43,
+
+		ForkedFrom: strPtr("original-session"),
+		SnapshotID: strPtr("snapshot-1"),
 	}
 
 	// Create a mock SessionService (nil is fine since mapSession doesn't use it)
@@ -177,6 +186,12 @@ func TestMapSessionFieldCoverage(t *testing.T) {
 		"WorkspaceCommit": "WorkspaceCommit",
 		"Model":           "Model",
 		"Reasoning":       "Reasoning",
+
+		"HistoryTrimmedThrough": "HistoryTrimmedThrough",
+		"HistoryTrimmedCount":   "HistoryTrimmedCount",
+
+		"ForkedFrom": "ForkedFrom",
+		"SnapshotID": "snapshotID", // Unexported: only used by session init
 		// Excluded fields (not part of API response):
 		// - CreatedAt, UpdatedAt: mapped to Timestamp
 		// - Project, Workspace, Agent, Messages: relationships, not serialized
@@ -198,6 +213,10 @@ func TestMapSessionFieldCoverage(t *testing.T) {
 			modelFieldName == "Agent" || modelFieldName == "Messages" {
 			continue
 		}
+		// SnapshotImage is only used by sandbox reconciliation
+		if modelFieldName == "SnapshotImage" {
+			continue
+		}
 
 		// Check if field is documented in fieldMappings
 		serviceFieldName, mapped := fieldMappings[modelFieldName]
@@ -256,3 +275,53 @@ func TestMapSessionFieldCoverage(t *testing.T) {
 		t.Error("Files should be initialized to empty array, got nil")
 	}
 }
+
+func TestGetSessionStatuses(t *testing.T) {
+	ctx := context.Background()
+	testStore := setupTestStore(t)
+	mockProvider := mock.NewProviderWithImage(testImage)
+
+	createTestSession(t, testStore, "session-running", t.TempDir())
+	other := &model.Session{
+		ID:          "session-other-project",
+		ProjectID:   "other-project",
+		WorkspaceID: "test-workspace",
+		Name:        "Other",
+		Status:      model.SessionStatusReady,
+	}
+	if err := testStore.CreateSession(ctx, other); err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+
+	if _, err := mockProvider.Create(ctx, "session-running", sandbox.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+	if err := mockProvider.Start(ctx, "session-running"); err != nil {
+		t.Fatalf("failed to start sandbox: %v", err)
+	}
+
+	svc := NewSessionService(testStore, nil, mockProvider, nil, nil, nil)
+
+	ids := []string{"session-running", "session-other-project", "missing"}
+	statuses, err := svc.GetSessionStatuses(ctx, "test-project", ids, true)
+	if err != nil {
+		t.Fatalf("GetSessionStatuses: %v", err)
+	}
+	if len(statuses) != 1 {
+		t.Fatalf("expected 1 status (other project and missing omitted), got %d", len(statuses))
+	}
+	if statuses[0].ID != "session-running" || statuses[0].Status != model.SessionStatusReady {
+		t.Errorf("unexpected status %+v", statuses[0])
+	}
+	if statuses[0].SandboxStatus != string(sandbox.StatusRunning) {
+		t.Errorf("SandboxStatus = %q, want %q", statuses[0].SandboxStatus, sandbox.StatusRunning)
+	}
+
+	statuses, err = svc.GetSessionStatuses(ctx, "test-project", ids, false)
+	if err != nil {
+		t.Fatalf("GetSessionStatuses: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].SandboxStatus != "" {
+		t.Errorf("expected no sandbox status without includeSandbox, got %+v", statuses)
+	}
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -12,7 +13,9 @@ import (
 
 	"github.com/obot-platform/discobot/server/internal/events"
 	"github.com/obot-platform/discobot/server/internal/git"
+	"github.com/obot-platform/discobot/server/internal/jobs"
 	"github.com/obot-platform/discobot/server/internal/model"
+	"github.com/obot-platform/discobot/server/internal/sandbox"
 	"github.com/obot-platform/discobot/server/internal/store"
 )
 
@@ -32,15 +35,30 @@ func expandPath(path string) (string, error) {
 
 // Workspace represents a workspace with its sessions (for API responses)
 type Workspace struct {
-	ID           string     `json:"id"`
-	Path         string     `json:"path"`
-	DisplayName  *string    `json:"displayName,omitempty"`
-	SourceType   string     `json:"sourceType"`
-	Provider     string     `json:"provider,omitempty"`
-	Status       string     `json:"status"`
-	ErrorMessage string     `json:"errorMessage,omitempty"`
-	WorkDir      string     `json:"workDir,omitempty"`
-	Sessions     []*Session `json:"sessions"`
+	ID          string  `json:"id"`
+	Path        string  `json:"path"`
+	DisplayName *string `json:"displayName,omitempty"`
+	SourceType  string  `json:"sourceType"`
+	Provider    string  `json:"provider,omitempty"`
+	NetworkMode string  `json:"networkMode"`
+	// RestartPolicy overrides SANDBOX_RESTART_POLICY (empty uses the server default)
+	RestartPolicy string `json:"restartPolicy,omitempty"`
+	// StopSignal overrides SANDBOX_STOP_SIGNAL (empty uses the server default)
+	StopSignal        string     `json:"stopSignal,omitempty"`
+	CPUCores          float64    `json:"cpuCores,omitempty"` // 0 uses SANDBOX_CPU_LIMIT
+	MemoryMB          int        `json:"memoryMB,omitempty"` // 0 uses SANDBOX_MEMORY_LIMIT_MB
+	DiskMB            int        `json:"diskMB,omitempty"`
+	ExtraPorts        []int      `json:"extraPorts,omitempty"` // Sandbox ports exposed besides the agent API
+	DisableProxyCache bool       `json:"disableProxyCache,omitempty"`
+	CloneDepth        int        `json:"cloneDepth,omitempty"` // 0 clones the full history
+	CloneAllBranches  bool       `json:"cloneAllBranches,omitempty"`
+	CloneSubmodules   bool       `json:"cloneSubmodules,omitempty"`
+	Filesystem        string     `json:"filesystem,omitempty"`     // Empty lets the agent detect it
+	DefaultAgentID    *string    `json:"defaultAgentId,omitempty"` // Nil uses the project's default agent
+	Status            string     `json:"status"`
+	ErrorMessage      string     `json:"errorMessage,omitempty"`
+	WorkDir           string     `json:"workDir,omitempty"`
+	Sessions          []*Session `json:"sessions"`
 }
 
 // WorkspaceService handles workspace operations
@@ -221,16 +239,94 @@ func (s *WorkspaceService) UpdateWorkspace(ctx context.Context, workspaceID, pat
 	return s.mapWorkspace(ctx, ws), nil
 }
 
+// ErrAgentNotInProject is returned when setting a workspace's default agent
+// to an agent that doesn't exist in the workspace's project.
+var ErrAgentNotInProject = errors.New("agent not found in project")
+
+// WorkspaceDefaultAgent reports which agent a workspace's sessions use when
+// they don't have one.
+type WorkspaceDefaultAgent struct {
+	AgentID          *string `json:"agentId"`          // The workspace's own default; nil uses the project's
+	EffectiveAgentID *string `json:"effectiveAgentId"` // The workspace's default, else the project's; nil if neither is set
+}
+
+// getProjectWorkspace returns a workspace if it belongs to the project.
+func (s *WorkspaceService) getProjectWorkspace(ctx context.Context, projectID, workspaceID string) (*model.Workspace, error) {
+	ws, err := s.store.GetWorkspaceByID(ctx, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workspace: %w", err)
+	}
+	if ws.ProjectID != projectID {
+		return nil, fmt.Errorf("failed to get workspace: %w", store.ErrNotFound)
+	}
+	return ws, nil
+}
+
+// GetDefaultAgent returns the workspace's default agent setting and the agent
+// its sessions fall back to.
+func (s *WorkspaceService) GetDefaultAgent(ctx context.Context, projectID, workspaceID string) (*WorkspaceDefaultAgent, error) {
+	ws, err := s.getProjectWorkspace(ctx, projectID, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &WorkspaceDefaultAgent{AgentID: ws.DefaultAgentID}
+	agent, err := s.store.GetWorkspaceDefaultAgent(ctx, ws)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("failed to get default agent: %w", err)
+	}
+	if agent != nil {
+		result.EffectiveAgentID = &agent.ID
+	}
+	return result, nil
+}
+
+// SetDefaultAgent sets the agent used by the workspace's sessions that don't
+// have one, ahead of the project's default agent. A nil agentID clears it.
+// Returns ErrAgentNotInProject if the agent isn't in the workspace's project.
+func (s *WorkspaceService) SetDefaultAgent(ctx context.Context, projectID, workspaceID string, agentID *string) error {
+	ws, err := s.getProjectWorkspace(ctx, projectID, workspaceID)
+	if err != nil {
+		return err
+	}
+	if agentID != nil {
+		agent, err := s.store.GetAgentByID(ctx, *agentID)
+		if errors.Is(err, store.ErrNotFound) || (err == nil && agent.ProjectID != ws.ProjectID) {
+			return ErrAgentNotInProject
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get agent: %w", err)
+		}
+	}
+	if err := s.store.SetWorkspaceDefaultAgent(ctx, ws.ID, agentID); err != nil {
+		return fmt.Errorf("failed to set default agent: %w", err)
+	}
+	return nil
+}
+
 // mapWorkspace converts a model.Workspace to a service.Workspace
 func (s *WorkspaceService) mapWorkspace(ctx context.Context, ws *model.Workspace) *Workspace {
 	result := &Workspace{
-		ID:          ws.ID,
-		Path:        ws.Path,
-		DisplayName: ws.DisplayName,
-		SourceType:  ws.SourceType,
-		Provider:    ws.Provider,
-		Status:      ws.Status,
-		Sessions:    []*Session{},
+		ID:                ws.ID,
+		Path:              ws.Path,
+		DisplayName:       ws.DisplayName,
+		SourceType:        ws.SourceType,
+		Provider:          ws.Provider,
+		NetworkMode:       sandbox.EffectiveNetworkMode(ws.NetworkMode),
+		RestartPolicy:     ws.RestartPolicy,
+		StopSignal:        ws.StopSignal,
+		CPUCores:          ws.CPUCores,
+		MemoryMB:          ws.MemoryMB,
+		DiskMB:            ws.DiskMB,
+		ExtraPorts:        ws.ExtraPorts,
+		DisableProxyCache: ws.DisableProxyCache,
+		CloneDepth:        ws.CloneDepth,
+		CloneAllBranches:  ws.CloneAllBranches,
+		CloneSubmodules:   ws.CloneSubmodules,
+		Filesystem:        ws.Filesystem,
+		DefaultAgentID:    ws.DefaultAgentID,
+		Status:            ws.Status,
+		Sessions:          []*Session{},
 	}
 	if ws.ErrorMessage != nil {
 		result.ErrorMessage = *ws.ErrorMessage
@@ -266,7 +362,7 @@ func (s *WorkspaceService) GetWorkspaceWithSessions(ctx context.Context, workspa
 	// Create session service to fetch sessions
 	// Note: git service, sandbox provider, sandbox service, and job enqueuer are nil since ListSessionsByWorkspace doesn't need them
 	sessionSvc := NewSessionService(s.store, nil, nil, nil, s.eventBroker, nil)
-	sessions, err := sessionSvc.ListSessionsByWorkspace(ctx, workspaceID)
+	sessions, err := sessionSvc.ListSessionsByWorkspace(ctx, workspaceID, store.SessionListOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -305,17 +401,17 @@ func (s *WorkspaceService) Initialize(ctx context.Context, workspaceID string) e
 	ws.Status = model.WorkspaceStatusReady
 	ws.ErrorMessage = nil
 	if err := s.store.UpdateWorkspace(ctx, ws); err != nil {
-		log.Printf("Failed to update workspace %s: %v", workspaceID, err)
+		jobs.Logf(ctx, "Failed to update workspace %s: %v", workspaceID, err)
 	}
 
 	// Emit success event
 	if s.eventBroker != nil {
 		if err := s.eventBroker.PublishWorkspaceUpdated(ctx, ws.ProjectID, workspaceID, model.WorkspaceStatusReady); err != nil {
-			log.Printf("Failed to publish workspace update event: %v", err)
+			jobs.Logf(ctx, "Failed to publish workspace update event: %v", err)
 		}
 	}
 
-	log.Printf("Workspace %s initialized successfully (commit: %s)", workspaceID, commit)
+	jobs.Logf(ctx, "Workspace %s initialized successfully (commit: %s)", workspaceID, commit)
 	return nil
 }
 
@@ -29,7 +29,7 @@ func TestCommitSession_Success(t *testing.T) {
 
 	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, nil, env.eventBroker, mockEnqueuer)
 
-	err := sessionSvc.CommitSession(context.Background(), project.ID, session.ID, mockEnqueuer)
+	err := sessionSvc.CommitSession(context.Background(), project.ID, session.ID, "", mockEnqueuer)
 	if err != nil {
 		t.Fatalf("CommitSession failed: %v", err)
 	}
@@ -67,7 +67,7 @@ func TestCommitSession_EnqueueFailure(t *testing.T) {
 
 	sessionSvc := NewSessionService(env.store, env.gitService, env.mockSandbox, nil, env.eventBroker, mockEnqueuer)
 
-	err := sessionSvc.CommitSession(context.Background(), project.ID, session.ID, mockEnqueuer)
+	err := sessionSvc.CommitSession(context.Background(), project.ID, session.ID, "", mockEnqueuer)
 	if err == nil {
 		t.Fatal("Expected CommitSession to fail when enqueue fails")
 	}
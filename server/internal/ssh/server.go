@@ -16,9 +16,12 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"golang.org/x/crypto/ssh"
 
 	"github.com/obot-platform/discobot/server/internal/sandbox"
@@ -47,6 +50,30 @@ type Config struct {
 	// UserInfoFetcher is used to get the default user for sandbox sessions.
 	// If nil, commands run as root.
 	UserInfoFetcher UserInfoFetcher
+
+	// ResumePaused unpauses a paused sandbox when a connection for it
+	// arrives, instead of refusing the connection (SERVICE_PROXY_AUTO_START).
+	ResumePaused bool
+}
+
+// ErrConnectionNotFound is returned when a connection ID does not match any
+// active SSH connection.
+var ErrConnectionNotFound = errors.New("ssh connection not found")
+
+// ConnectionInfo describes an active SSH connection.
+type ConnectionInfo struct {
+	ID            string    `json:"id"`
+	SessionID     string    `json:"sessionId"`
+	User          string    `json:"user"`
+	RemoteAddr    string    `json:"remoteAddr"`
+	ClientVersion string    `json:"clientVersion"`
+	ConnectedAt   time.Time `json:"connectedAt"`
+}
+
+// trackedConn is an active connection along with its metadata.
+type trackedConn struct {
+	info ConnectionInfo
+	conn *ssh.ServerConn
 }
 
 // Server is an SSH server that routes connections to sandbox containers.
@@ -54,12 +81,13 @@ type Server struct {
 	config          *ssh.ServerConfig
 	provider        sandbox.Provider
 	userInfoFetcher UserInfoFetcher
+	resumePaused    bool
 	listener        net.Listener
 	addr            string
 
-	mu       sync.Mutex
-	sessions map[string]*sessionHandler // sessionID -> handler
-	closed   bool
+	mu     sync.Mutex
+	conns  map[string]*trackedConn // connection ID -> connection
+	closed bool
 }
 
 // New creates a new SSH server with the given configuration.
@@ -93,8 +121,9 @@ func New(cfg *Config) (*Server, error) {
 		config:          sshConfig,
 		provider:        cfg.SandboxProvider,
 		userInfoFetcher: cfg.UserInfoFetcher,
+		resumePaused:    cfg.ResumePaused,
 		addr:            cfg.Address,
-		sessions:        make(map[string]*sessionHandler),
+		conns:           make(map[string]*trackedConn),
 	}, nil
 }
 
@@ -151,6 +180,48 @@ func (s *Server) Addr() string {
 	return s.addr
 }
 
+// Connections returns the active SSH connections, oldest first.
+func (s *Server) Connections() []ConnectionInfo {
+	s.mu.Lock()
+	conns := make([]ConnectionInfo, 0, len(s.conns))
+	for _, tc := range s.conns {
+		conns = append(conns, tc.info)
+	}
+	s.mu.Unlock()
+
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].ConnectedAt.Before(conns[j].ConnectedAt)
+	})
+	return conns
+}
+
+// Connection returns the active SSH connection with the given ID.
+func (s *Server) Connection(id string) (ConnectionInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tc, ok := s.conns[id]
+	if !ok {
+		return ConnectionInfo{}, ErrConnectionNotFound
+	}
+	return tc.info, nil
+}
+
+// CloseConnection terminates the active SSH connection with the given ID.
+// Returns ErrConnectionNotFound if no such connection exists.
+func (s *Server) CloseConnection(id string) error {
+	s.mu.Lock()
+	tc, ok := s.conns[id]
+	s.mu.Unlock()
+	if !ok {
+		return ErrConnectionNotFound
+	}
+
+	log.Printf("Terminating SSH connection %s for session %s", id, tc.info.SessionID)
+	// Closing the connection ends the channel loop in handleConnection,
+	// which removes it from the tracked set.
+	return tc.conn.Close()
+}
+
 func (s *Server) handleConnection(netConn net.Conn) {
 	// Perform SSH handshake
 	sshConn, chans, reqs, err := ssh.NewServerConn(netConn, s.config)
@@ -172,6 +243,15 @@ func (s *Server) handleConnection(netConn net.Conn) {
 		sshConn.Close()
 		return
 	}
+	if sb.Status == sandbox.StatusPaused && s.resumePaused {
+		// Resume on demand; the sandbox watcher marks the session ready
+		if err := s.provider.Unpause(ctx, sessionID); err != nil {
+			log.Printf("SSH session %s: failed to resume paused sandbox: %v", sessionID, err)
+			sshConn.Close()
+			return
+		}
+		sb.Status = sandbox.StatusRunning
+	}
 	if sb.Status != sandbox.StatusRunning {
 		log.Printf("SSH session %s: sandbox not running (status=%s)", sessionID, sb.Status)
 		sshConn.Close()
@@ -181,13 +261,24 @@ func (s *Server) handleConnection(netConn net.Conn) {
 	// Create session handler
 	handler := newSessionHandler(sessionID, s.provider, s.userInfoFetcher)
 
+	connID := uuid.New().String()
 	s.mu.Lock()
-	s.sessions[sessionID] = handler
+	s.conns[connID] = &trackedConn{
+		info: ConnectionInfo{
+			ID:            connID,
+			SessionID:     sessionID,
+			User:          sshConn.User(),
+			RemoteAddr:    sshConn.RemoteAddr().String(),
+			ClientVersion: string(sshConn.ClientVersion()),
+			ConnectedAt:   time.Now(),
+		},
+		conn: sshConn,
+	}
 	s.mu.Unlock()
 
 	defer func() {
 		s.mu.Lock()
-		delete(s.sessions, sessionID)
+		delete(s.conns, connID)
 		s.mu.Unlock()
 		sshConn.Close()
 		log.Printf("SSH connection closed for session %s", sessionID)
@@ -337,6 +337,79 @@ func TestParseDirectTCPIPData(t *testing.T) {
 	}
 }
 
+func TestServer_TracksAndClosesConnections(t *testing.T) {
+	t.Parallel()
+	provider := mock.NewProvider()
+
+	ctx := context.Background()
+	sessionID := "test-session-tracked"
+	if _, err := provider.Create(ctx, sessionID, sandbox.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+	if err := provider.Start(ctx, sessionID); err != nil {
+		t.Fatalf("failed to start sandbox: %v", err)
+	}
+
+	srv, err := New(&Config{
+		Address:         "127.0.0.1:0",
+		HostKeyPath:     getSharedTestKeyPath(),
+		SandboxProvider: provider,
+	})
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	go srv.Start()
+	defer srv.Stop()
+	time.Sleep(100 * time.Millisecond)
+
+	client, err := ssh.Dial("tcp", srv.Addr(), &ssh.ClientConfig{
+		User:            sessionID,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	// Connection is registered after the sandbox check completes
+	var conns []ConnectionInfo
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if conns = srv.Connections(); len(conns) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(conns) != 1 {
+		t.Fatalf("expected 1 connection, got %d", len(conns))
+	}
+	if conns[0].SessionID != sessionID {
+		t.Errorf("SessionID = %q, want %q", conns[0].SessionID, sessionID)
+	}
+	if conns[0].RemoteAddr == "" {
+		t.Error("RemoteAddr is empty")
+	}
+
+	if err := srv.CloseConnection("unknown"); err != ErrConnectionNotFound {
+		t.Errorf("CloseConnection(unknown) = %v, want ErrConnectionNotFound", err)
+	}
+
+	if err := srv.CloseConnection(conns[0].ID); err != nil {
+		t.Fatalf("failed to close connection: %v", err)
+	}
+
+	// Client should observe the disconnect and the connection should be untracked
+	_ = client.Wait()
+	deadline = time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) && len(srv.Connections()) > 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(srv.Connections()); n != 0 {
+		t.Errorf("expected 0 connections after close, got %d", n)
+	}
+}
+
 func TestServer_Stop(t *testing.T) {
 	provider := mock.NewProvider()
 
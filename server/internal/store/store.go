@@ -4,6 +4,8 @@ package store
 import (
 	"context"
 	"errors"
+	"slices"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -101,6 +103,33 @@ func (s *Store) GetProjectByID(ctx context.Context, id string) (*model.Project,
 	return &project, nil
 }
 
+// ListProjectIDsByRecentActivity returns the IDs of up to limit projects
+// with a session updated since the given time, ordered by their most recently
+// updated session (newest first). A zero since or limit disables that filter.
+func (s *Store) ListProjectIDsByRecentActivity(ctx context.Context, since time.Time, limit int) ([]string, error) {
+	var ids []string
+	query := s.db.WithContext(ctx).Model(&model.Session{}).
+		Select("project_id").
+		Group("project_id").
+		Order("MAX(updated_at) DESC")
+	if !since.IsZero() {
+		query = query.Having("MAX(updated_at) >= ?", since)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Pluck("project_id", &ids).Error
+	return ids, err
+}
+
+// SandboxImageInUse reports whether any project's sandboxes are created from
+// one of images (spellings of the same image reference).
+func (s *Store) SandboxImageInUse(ctx context.Context, images ...string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&model.Project{}).Where("sandbox_image IN ?", images).Count(&count).Error
+	return count > 0, err
+}
+
 func (s *Store) ListProjectsByUser(ctx context.Context, userID string) ([]*model.Project, error) {
 	var projects []*model.Project
 	err := s.db.WithContext(ctx).
@@ -118,6 +147,21 @@ func (s *Store) UpdateProject(ctx context.Context, project *model.Project) error
 	return s.db.WithContext(ctx).Save(project).Error
 }
 
+// SetProjectFrozen freezes or unfreezes a project.
+// Returns ErrNotFound if the project doesn't exist.
+func (s *Store) SetProjectFrozen(ctx context.Context, id string, frozen bool) error {
+	result := s.db.WithContext(ctx).Model(&model.Project{}).
+		Where("id = ?", id).
+		Update("frozen", frozen)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 func (s *Store) DeleteProject(ctx context.Context, id string) error {
 	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// Delete all related records explicitly (no cascade in schema)
@@ -136,6 +180,9 @@ func (s *Store) DeleteProject(ctx context.Context, id string) error {
 			if err := tx.Where("session_id IN (SELECT id FROM sessions WHERE workspace_id = ?)", ws.ID).Delete(&model.TerminalHistory{}).Error; err != nil {
 				return err
 			}
+			if err := tx.Where("session_id IN (SELECT id FROM sessions WHERE workspace_id = ?)", ws.ID).Delete(&model.SessionTransition{}).Error; err != nil {
+				return err
+			}
 			// Delete sessions
 			if err := tx.Where("workspace_id = ?", ws.ID).Delete(&model.Session{}).Error; err != nil {
 				return err
@@ -262,6 +309,9 @@ func (s *Store) DeleteWorkspace(ctx context.Context, id string) error {
 		if err := tx.Where("session_id IN (SELECT id FROM sessions WHERE workspace_id = ?)", id).Delete(&model.TerminalHistory{}).Error; err != nil {
 			return err
 		}
+		if err := tx.Where("session_id IN (SELECT id FROM sessions WHERE workspace_id = ?)", id).Delete(&model.SessionTransition{}).Error; err != nil {
+			return err
+		}
 
 		// Delete sessions
 		if err := tx.Where("workspace_id = ?", id).Delete(&model.Session{}).Error; err != nil {
@@ -286,10 +336,67 @@ func (s *Store) GetSessionByID(ctx context.Context, id string) (*model.Session,
 	return &session, nil
 }
 
-// ListSessionsByWorkspace returns all sessions for a workspace.
-func (s *Store) ListSessionsByWorkspace(ctx context.Context, workspaceID string) ([]*model.Session, error) {
+// Session list sort orders (newest first)
+const (
+	SessionSortUpdated = "updated"
+	SessionSortCreated = "created"
+)
+
+// SessionListOptions filters and orders ListSessionsByWorkspace and
+// ListSessionsByProject. The zero value returns every session, unordered.
+type SessionListOptions struct {
+	// Statuses limits results to sessions with one of these statuses.
+	Statuses []string
+	// CommitStatuses limits results to sessions with one of these commit statuses.
+	CommitStatuses []string
+	// Search matches a case-insensitive substring of the name or display name.
+	Search string
+	// SortBy is SessionSortUpdated, SessionSortCreated, or empty for no ordering.
+	SortBy string
+}
+
+// likeEscaper escapes LIKE wildcards so search text matches literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// ListSessionsByWorkspace returns the sessions for a workspace matching opts.
+func (s *Store) ListSessionsByWorkspace(ctx context.Context, workspaceID string, opts SessionListOptions) ([]*model.Session, error) {
+	return listSessions(s.db.WithContext(ctx).Where("workspace_id = ?", workspaceID), opts)
+}
+
+// ListSessionsByProject returns the sessions in a project matching opts.
+func (s *Store) ListSessionsByProject(ctx context.Context, projectID string, opts SessionListOptions) ([]*model.Session, error) {
+	return listSessions(s.db.WithContext(ctx).Where("project_id = ?", projectID), opts)
+}
+
+// listSessions applies opts to a session query and runs it.
+func listSessions(query *gorm.DB, opts SessionListOptions) ([]*model.Session, error) {
+	if len(opts.Statuses) > 0 {
+		query = query.Where("status IN ?", opts.Statuses)
+	}
+	if len(opts.CommitStatuses) > 0 {
+		query = query.Where("commit_status IN ?", opts.CommitStatuses)
+	}
+	if opts.Search != "" {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(opts.Search)) + "%"
+		query = query.Where(`(LOWER(name) LIKE ? ESCAPE '\' OR LOWER(display_name) LIKE ? ESCAPE '\')`, pattern, pattern)
+	}
+	switch opts.SortBy {
+	case SessionSortUpdated:
+		query = query.Order("updated_at DESC")
+	case SessionSortCreated:
+		query = query.Order("created_at DESC")
+	}
+
+	var sessions []*model.Session
+	err := query.Find(&sessions).Error
+	return sessions, err
+}
+
+// ListSessionsByIDs returns the sessions in a project with any of the given IDs.
+// IDs that don't exist or belong to another project are omitted.
+func (s *Store) ListSessionsByIDs(ctx context.Context, projectID string, ids []string) ([]*model.Session, error) {
 	var sessions []*model.Session
-	err := s.db.WithContext(ctx).Where("workspace_id = ?", workspaceID).Find(&sessions).Error
+	err := s.db.WithContext(ctx).Where("project_id = ? AND id IN ?", projectID, ids).Find(&sessions).Error
 	return sessions, err
 }
 
@@ -327,6 +434,42 @@ func (s *Store) UpdateSessionStatus(ctx context.Context, id, status string, erro
 	return s.db.WithContext(ctx).Model(&model.Session{}).Where("id = ?", id).Updates(updates).Error
 }
 
+// MarkSessionRemoving atomically sets a session's status to removing. It
+// doesn't if the session is already being removed, or if it has a pending or
+// committing commit last updated after commitStaleBefore (a commit not
+// updated since then is assumed dead). Returns ErrNotFound if the session
+// was not updated.
+func (s *Store) MarkSessionRemoving(ctx context.Context, id string, commitStaleBefore time.Time) error {
+	result := s.db.WithContext(ctx).Model(&model.Session{}).
+		Where("id = ? AND status <> ?", id, model.SessionStatusRemoving).
+		Where("(commit_status NOT IN ? OR updated_at < ?)",
+			[]string{model.CommitStatusPending, model.CommitStatusCommitting}, commitStaleBefore).
+		Update("status", model.SessionStatusRemoving)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// BeginSessionCommit atomically sets a session's commit status to pending
+// unless the session is being removed. Returns ErrNotFound if the session
+// was not updated.
+func (s *Store) BeginSessionCommit(ctx context.Context, id string) error {
+	result := s.db.WithContext(ctx).Model(&model.Session{}).
+		Where("id = ? AND status <> ?", id, model.SessionStatusRemoving).
+		Update("commit_status", model.CommitStatusPending)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 func (s *Store) CreateSession(ctx context.Context, session *model.Session) error {
 	return s.db.WithContext(ctx).Create(session).Error
 }
@@ -346,6 +489,24 @@ func (s *Store) UpdateSessionWorkspace(ctx context.Context, id, workspacePath, w
 	return s.db.WithContext(ctx).Model(&model.Session{}).Where("id = ?", id).Updates(updates).Error
 }
 
+// ClearSessionSnapshot clears the snapshot a forked session's sandbox is
+// created from, once the sandbox exists, recording the image it was created
+// from.
+func (s *Store) ClearSessionSnapshot(ctx context.Context, id, image string) error {
+	return s.db.WithContext(ctx).Model(&model.Session{}).Where("id = ?", id).Updates(map[string]any{
+		"snapshot_id":    nil,
+		"snapshot_image": image,
+	}).Error
+}
+
+// SnapshotPending reports whether a forked session's sandbox is still to be
+// created from the snapshot.
+func (s *Store) SnapshotPending(ctx context.Context, snapshotID string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&model.Session{}).Where("snapshot_id = ?", snapshotID).Count(&count).Error
+	return count > 0, err
+}
+
 func (s *Store) DeleteSession(ctx context.Context, id string) error {
 	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// Delete messages
@@ -358,6 +519,11 @@ func (s *Store) DeleteSession(ctx context.Context, id string) error {
 			return err
 		}
 
+		// Delete status history
+		if err := tx.Where("session_id = ?", id).Delete(&model.SessionTransition{}).Error; err != nil {
+			return err
+		}
+
 		// Delete the session
 		return tx.Delete(&model.Session{}, "id = ?", id).Error
 	})
@@ -387,6 +553,29 @@ func (s *Store) GetDefaultAgent(ctx context.Context, projectID string) (*model.A
 	return &agent, nil
 }
 
+// GetWorkspaceDefaultAgent returns the agent for a workspace's sessions that
+// don't have one: the workspace's default agent if it is set and still in the
+// project, otherwise the project's default agent. Returns ErrNotFound if
+// neither exists.
+func (s *Store) GetWorkspaceDefaultAgent(ctx context.Context, workspace *model.Workspace) (*model.Agent, error) {
+	if workspace.DefaultAgentID != nil {
+		agent, err := s.GetAgentByID(ctx, *workspace.DefaultAgentID)
+		if err == nil && agent.ProjectID == workspace.ProjectID {
+			return agent, nil
+		}
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+	return s.GetDefaultAgent(ctx, workspace.ProjectID)
+}
+
+// SetWorkspaceDefaultAgent sets the workspace's default agent, or clears it
+// if agentID is nil so the project default applies.
+func (s *Store) SetWorkspaceDefaultAgent(ctx context.Context, workspaceID string, agentID *string) error {
+	return s.db.WithContext(ctx).Model(&model.Workspace{}).Where("id = ?", workspaceID).Update("default_agent_id", agentID).Error
+}
+
 func (s *Store) ListAgentsByProject(ctx context.Context, projectID string) ([]*model.Agent, error) {
 	var agents []*model.Agent
 	err := s.db.WithContext(ctx).Where("project_id = ?", projectID).Find(&agents).Error
@@ -407,6 +596,10 @@ func (s *Store) DeleteAgent(ctx context.Context, id string) error {
 		if err := tx.Model(&model.Session{}).Where("agent_id = ?", id).Update("agent_id", nil).Error; err != nil {
 			return err
 		}
+		// Workspaces that defaulted to it fall back to the project default
+		if err := tx.Model(&model.Workspace{}).Where("default_agent_id = ?", id).Update("default_agent_id", nil).Error; err != nil {
+			return err
+		}
 
 		// Delete the agent
 		return tx.Delete(&model.Agent{}, "id = ?", id).Error
@@ -455,6 +648,14 @@ func (s *Store) ListCredentialsByProject(ctx context.Context, projectID string)
 	return credentials, err
 }
 
+// ListCredentialsByAuthType returns the credentials of every project with the
+// given auth type.
+func (s *Store) ListCredentialsByAuthType(ctx context.Context, authType string) ([]*model.Credential, error) {
+	var credentials []*model.Credential
+	err := s.db.WithContext(ctx).Where("auth_type = ?", authType).Find(&credentials).Error
+	return credentials, err
+}
+
 func (s *Store) CreateCredential(ctx context.Context, credential *model.Credential) error {
 	return s.db.WithContext(ctx).Create(credential).Error
 }
@@ -463,6 +664,15 @@ func (s *Store) UpdateCredential(ctx context.Context, credential *model.Credenti
 	return s.db.WithContext(ctx).Save(credential).Error
 }
 
+// MarkCredentialsUsed sets last_used_at on the given credentials without
+// touching updated_at, which tracks changes to the credential itself.
+func (s *Store) MarkCredentialsUsed(ctx context.Context, ids []string, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Model(&model.Credential{}).Where("id IN ?", ids).UpdateColumn("last_used_at", at).Error
+}
+
 func (s *Store) DeleteCredential(ctx context.Context, projectID, provider string) error {
 	return s.db.WithContext(ctx).Delete(&model.Credential{}, "project_id = ? AND provider = ?", projectID, provider).Error
 }
@@ -483,6 +693,64 @@ func (s *Store) CreateTerminalHistory(ctx context.Context, entry *model.Terminal
 	return s.db.WithContext(ctx).Create(entry).Error
 }
 
+// --- Session Transitions ---
+
+// CreateSessionTransition records a transition and prunes the session's
+// history to the keep most recent entries (keep <= 0 disables pruning).
+func (s *Store) CreateSessionTransition(ctx context.Context, transition *model.SessionTransition, keep int) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(transition).Error; err != nil {
+			return err
+		}
+		if keep <= 0 {
+			return nil
+		}
+		var stale []string
+		if err := tx.Model(&model.SessionTransition{}).
+			Where("session_id = ?", transition.SessionID).
+			Order("created_at DESC, id DESC").
+			Offset(keep).
+			Pluck("id", &stale).Error; err != nil {
+			return err
+		}
+		if len(stale) == 0 {
+			return nil
+		}
+		return tx.Where("id IN ?", stale).Delete(&model.SessionTransition{}).Error
+	})
+}
+
+// ListSessionTransitions returns up to limit of the session's most recent
+// transitions, oldest first (limit <= 0 returns all).
+func (s *Store) ListSessionTransitions(ctx context.Context, sessionID string, limit int) ([]*model.SessionTransition, error) {
+	var transitions []*model.SessionTransition
+	query := s.db.WithContext(ctx).Where("session_id = ?", sessionID).Order("created_at DESC, id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+	slices.Reverse(transitions)
+	return transitions, nil
+}
+
+// GetLastSessionTransition returns the session's most recent transition of
+// the given kind, or ErrNotFound if none has been recorded.
+func (s *Store) GetLastSessionTransition(ctx context.Context, sessionID, kind string) (*model.SessionTransition, error) {
+	var transition model.SessionTransition
+	if err := s.db.WithContext(ctx).
+		Where("session_id = ? AND kind = ?", sessionID, kind).
+		Order("created_at DESC, id DESC").
+		First(&transition).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &transition, nil
+}
+
 // --- Jobs ---
 
 // CreateJob creates a new job in the queue.
@@ -530,6 +798,55 @@ func (s *Store) HasActiveJobForResource(ctx context.Context, resourceType, resou
 	return count > 0, err
 }
 
+// GetPendingJobForResource retrieves the pending job of the given type for a resource.
+// Returns ErrNotFound if no such job is pending.
+func (s *Store) GetPendingJobForResource(ctx context.Context, jobType, resourceType, resourceID string) (*model.Job, error) {
+	var job model.Job
+	err := s.db.WithContext(ctx).
+		Where("type = ? AND resource_type = ? AND resource_id = ? AND status = ?",
+			jobType, resourceType, resourceID, model.JobStatusPending).
+		Order("created_at ASC").
+		First(&job).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// MaxPendingJobPriority returns the highest priority among pending jobs,
+// excluding the given job ID. Returns ok=false if there are no other pending jobs.
+func (s *Store) MaxPendingJobPriority(ctx context.Context, excludeJobID string) (priority int, ok bool, err error) {
+	var result struct {
+		MaxPriority *int
+	}
+	err = s.db.WithContext(ctx).Model(&model.Job{}).
+		Select("MAX(priority) AS max_priority").
+		Where("status = ? AND id != ?", model.JobStatusPending, excludeJobID).
+		Scan(&result).Error
+	if err != nil || result.MaxPriority == nil {
+		return 0, false, err
+	}
+	return *result.MaxPriority, true, nil
+}
+
+// UpdatePendingJobPriority sets the priority of a job that is still pending.
+// Returns ErrNotFound if the job does not exist or has already been claimed.
+func (s *Store) UpdatePendingJobPriority(ctx context.Context, jobID string, priority int) error {
+	result := s.db.WithContext(ctx).Model(&model.Job{}).
+		Where("id = ? AND status = ?", jobID, model.JobStatusPending).
+		Update("priority", priority)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 // ClaimJob atomically claims a pending job of the given type.
 // Returns nil, nil if no job is available.
 func (s *Store) ClaimJob(ctx context.Context, jobType string, workerID string) (*model.Job, error) {
@@ -539,7 +856,7 @@ func (s *Store) ClaimJob(ctx context.Context, jobType string, workerID string) (
 // ClaimJobOfTypes atomically claims a pending job of any of the given types.
 // Jobs are selected by priority (highest first), then by scheduled time (oldest first).
 // If a job has resource_type/resource_id set, it will only be claimed if no other job
-// for the same resource is currently running.
+// for the same resource is currently running. Jobs of frozen projects are skipped.
 // Returns nil, nil if no job is available.
 func (s *Store) ClaimJobOfTypes(ctx context.Context, jobTypes []string, workerID string) (*model.Job, error) {
 	if len(jobTypes) == 0 {
@@ -555,6 +872,8 @@ func (s *Store) ClaimJobOfTypes(ctx context.Context, jobTypes []string, workerID
 		var candidates []model.Job
 		query := tx.Where("type IN ? AND status = ? AND scheduled_at <= ?",
 			jobTypes, model.JobStatusPending, time.Now()).
+			Where("project_id IS NULL OR project_id NOT IN (?)",
+				tx.Model(&model.Project{}).Select("id").Where("frozen = ?", true)).
 			Order("priority DESC, scheduled_at ASC, created_at ASC").
 			Limit(10) // Check up to 10 candidates to find one without resource conflicts
 
@@ -672,6 +991,36 @@ func (s *Store) CountRunningJobsByType(ctx context.Context, jobType string) (int
 	return count, err
 }
 
+// CountJobsByStatus returns the number of jobs in each status.
+func (s *Store) CountJobsByStatus(ctx context.Context) (map[string]int64, error) {
+	return s.countByStatus(ctx, &model.Job{})
+}
+
+// CountSessionsByStatus returns the number of sessions in each status.
+func (s *Store) CountSessionsByStatus(ctx context.Context) (map[string]int64, error) {
+	return s.countByStatus(ctx, &model.Session{})
+}
+
+// countByStatus counts the rows of a model's table grouped by status.
+func (s *Store) countByStatus(ctx context.Context, m any) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	err := s.db.WithContext(ctx).Model(m).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
 // CleanupStaleJobs resets jobs that have been running too long (worker died).
 // Returns the number of jobs reset.
 func (s *Store) CleanupStaleJobs(ctx context.Context, staleAfter time.Duration) (int64, error) {